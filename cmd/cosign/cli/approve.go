@@ -0,0 +1,85 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+func Approve() *ffcli.Command {
+	var (
+		flagset  = flag.NewFlagSet("cosign approve", flag.ExitOnError)
+		key      = flagset.String("key", "", "path to the private key to approve with")
+		kmsVal   = flagset.String("kms", "", "approve with a private key stored in a KMS")
+		comment  = flagset.String("comment", "", "a note to record with the approval, like a change ticket")
+		registry = addRegistryFlags(flagset)
+	)
+	return &ffcli.Command{
+		Name:       "approve",
+		ShortUsage: "cosign approve -key <key path>|-kms <kms uri> [-comment <text>] <image uri>",
+		ShortHelp:  "Record your approval to release an image",
+		LongHelp: `Record your approval to release an image.
+
+The approval is an attestation with the "approval" predicate type, signed by the approver and
+stored next to the image's signatures. "cosign verify -require-approvals N" only accepts images
+approved by at least N distinct trusted approvers, identified by their key or certificate email.
+
+EXAMPLES
+  # approve with your key
+  cosign approve -key alice.key -comment "CHG-1234" <IMAGE>
+
+  # approve with Google sign-in (experimental), identified by your email
+  COSIGN_EXPERIMENTAL=1 cosign approve <IMAGE>
+
+  # require two of three approvers before release
+  cosign verify -key release.pub -require-approvals 2 -approver-key alice.pub -approver-key bob.pub -approver-key carol.pub <IMAGE>`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if !cosign.Experimental() && *key == "" && *kmsVal == "" {
+				return &KeyParseError{}
+			}
+			if len(args) != 1 {
+				return flag.ErrHelp
+			}
+			ao := AttestOpts{
+				KeyRef:   *key,
+				KmsVal:   *kmsVal,
+				Registry: *registry,
+			}
+			return ApproveCmd(ctx, ao, args[0], *comment, GetPass)
+		},
+	}
+}
+
+// ApproveCmd attaches an approval of imageRef to it, signed with the key in ao.
+func ApproveCmd(ctx context.Context, ao AttestOpts, imageRef, comment string, pf cosign.PassFunc) error {
+	if ao.KeyRef != "" && ao.KmsVal != "" {
+		return &KeyParseError{}
+	}
+	predicate, err := json.Marshal(cosign.ApprovalPredicate{Comment: comment})
+	if err != nil {
+		return err
+	}
+	// Approvals from other people have to stay, so they are never replaced.
+	ao.PredicateType = "approval"
+	ao.Replace = false
+	return attestPredicate(ctx, ao, imageRef, predicate, pf)
+}
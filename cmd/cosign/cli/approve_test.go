@@ -0,0 +1,123 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/zalando/go-keyring"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+func TestApproveCmd(t *testing.T) {
+	keyring.MockInit()
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/release:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	td, err := ioutil.TempDir("", "cosign-approve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	pass := func(bool) ([]byte, error) { return []byte("hunter2"), nil }
+	keys := map[string]string{}
+	approvers := []cosign.PublicKey{}
+	for _, who := range []string{"release", "alice", "bob"} {
+		k, err := cosign.GenerateKeyPair(pass)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[who] = filepath.Join(td, who+".key")
+		if err := ioutil.WriteFile(keys[who], k.PrivateBytes, 0600); err != nil {
+			t.Fatal(err)
+		}
+		pub, err := cosign.ParsePublicKeyPem(k.PublicBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if who == "release" {
+			keys["release.pub"] = filepath.Join(td, who+".pub")
+			if err := ioutil.WriteFile(keys["release.pub"], k.PublicBytes, 0600); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		approvers = append(approvers, pub)
+	}
+
+	if err := SignCmd(ctx, SignOpts{KeyRef: keys["release"], Upload: true}, ref.String(), pass); err != nil {
+		t.Fatal(err)
+	}
+	verify := func() error {
+		release, err := cosign.LoadPublicKey(ctx, keys["release.pub"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = cosign.Verify(ctx, ref, cosign.CheckOpts{
+			Keys:              []cosign.PublicKey{release},
+			RequiredApprovals: 2,
+			Approvers:         &cosign.CheckOpts{Keys: approvers},
+		})
+		return err
+	}
+	if err := verify(); err == nil {
+		t.Error("expected error verifying without approvals")
+	}
+
+	// Approving twice still counts as one approver.
+	for i := 0; i < 2; i++ {
+		if err := ApproveCmd(ctx, AttestOpts{KeyRef: keys["alice"]}, ref.String(), "CHG-1234", pass); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Approvals from keys that aren't approvers don't count.
+	if err := ApproveCmd(ctx, AttestOpts{KeyRef: keys["release"]}, ref.String(), "", pass); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify(); err == nil {
+		t.Error("expected error verifying with a single approver")
+	}
+
+	if err := ApproveCmd(ctx, AttestOpts{KeyRef: keys["bob"]}, ref.String(), "", pass); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify(); err != nil {
+		t.Errorf("verifying with two approvers: %v", err)
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/atomic"
+	"github.com/sigstore/cosign/pkg/cosign/fulcio"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// atomicSignerAnnotation records the OpenPGP key whose atomic signature an imported signature vouches for.
+const atomicSignerAnnotation = "dev.sigstore.cosign/atomic-signer"
+
+func Atomic() *ffcli.Command {
+	return &ffcli.Command{
+		Name:       "atomic",
+		ShortUsage: "cosign atomic export|import",
+		ShortHelp:  "Convert between cosign signatures and the atomic signatures podman and CRI-O check",
+		LongHelp: `Convert between cosign signatures and the atomic signatures podman and CRI-O check.
+
+containers/image, which podman, CRI-O and skopeo use, checks OpenPGP signed "atomic container
+signature" payloads against the signedBy requirements of /etc/containers/policy.json. It reads
+them from a lookaside directory configured in /etc/containers/registries.d. export writes one for
+an image that has a verified cosign signature, and import attaches a cosign signature to an image
+that has a verified atomic one, so a single signing event satisfies both kinds of policy.`,
+		Subcommands: []*ffcli.Command{atomicExport(), atomicImport()},
+		Exec: func(context.Context, []string) error {
+			return flag.ErrHelp
+		},
+	}
+}
+
+// AtomicExportOpts holds the settings for AtomicExportCmd.
+type AtomicExportOpts struct {
+	// Key is the public key, or KMS reference, the cosign signature must verify against.
+	Key string
+	// PGPKey is the path to the OpenPGP secret key to sign the atomic signature with.
+	PGPKey string
+	// Sigstore is the lookaside directory to write the atomic signature to.
+	Sigstore string
+	// PolicyKeyPath, if set, is where hosts keep the OpenPGP public key; a policy.json
+	// transports section requiring it is printed.
+	PolicyKeyPath string
+	Registry      RegistryOpts
+	// RekorURL is the address of the transparency log, see cosign.TlogServer for the default.
+	RekorURL string
+}
+
+func atomicExport() *ffcli.Command {
+	var (
+		flagset       = flag.NewFlagSet("cosign atomic export", flag.ExitOnError)
+		key           = flagset.String("key", "", "path to the public key, or KMS reference, the cosign signature must verify against")
+		pgpKey        = flagset.String("pgp-key", "", "path to the OpenPGP secret key to sign with, such as one exported with `gpg --export-secret-keys`")
+		sigstore      = flagset.String("sigstore", "", "lookaside directory to write the atomic signature to")
+		policyKeyPath = flagset.String("policy-key-path", "", "path of the OpenPGP public key on the hosts checking the signature; prints the policy.json transports section requiring it")
+		registry      = addRegistryFlags(flagset)
+		rekorURL      string
+	)
+	addRekorURLFlag(flagset, &rekorURL)
+	return &ffcli.Command{
+		Name:       "export",
+		ShortUsage: "cosign atomic export -key <key path>|<kms uri> -pgp-key <path> -sigstore <dir> [-policy-key-path <path>] <image uri>",
+		ShortHelp:  "Write an atomic signature for an image with a verified cosign signature",
+		LongHelp: `Write an atomic signature for an image with a verified cosign signature.
+
+The atomic signature claims the image's digest and the reference it was exported as, and is
+written to <dir>/<repository>@sha256=<digest>/signature-<n>. Serve or copy that directory to the
+hosts, and point the registry's "sigstore" setting in /etc/containers/registries.d at it.
+The passphrase of an encrypted OpenPGP key is read like that of a cosign key.
+
+EXAMPLES
+  # export the atomic signature, and print the policy for hosts keeping the key in /etc/pki
+  cosign atomic export -key cosign.pub -pgp-key release.gpg -sigstore ./sigstore \
+    -policy-key-path /etc/pki/containers/release.gpg <IMAGE>`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 || *key == "" || *pgpKey == "" || *sigstore == "" {
+				return flag.ErrHelp
+			}
+			ao := AtomicExportOpts{
+				Key:           *key,
+				PGPKey:        *pgpKey,
+				Sigstore:      *sigstore,
+				PolicyKeyPath: *policyKeyPath,
+				Registry:      *registry,
+				RekorURL:      rekorURL,
+			}
+			return AtomicExportCmd(ctx, ao, args[0], GetPass, os.Stdout)
+		},
+	}
+}
+
+// AtomicExportCmd verifies the cosign signature of the image, and writes an atomic signature of it.
+func AtomicExportCmd(ctx context.Context, ao AtomicExportOpts, imageRef string, pf cosign.PassFunc, w io.Writer) error {
+	ref, err := ao.Registry.ParseReference(imageRef)
+	if err != nil {
+		return err
+	}
+	pubKey, err := cosign.LoadPublicKey(ctx, ao.Key)
+	if err != nil {
+		return errors.Wrap(err, "loading public key")
+	}
+	signer, err := loadPGPSigner(ao.PGPKey, pf)
+	if err != nil {
+		return errors.Wrap(err, "loading OpenPGP key")
+	}
+	co := cosign.CheckOpts{
+		Keys:               []cosign.PublicKey{pubKey},
+		ClaimVerification:  true,
+		TLog:               cosign.Experimental(),
+		RekorURL:           ao.RekorURL,
+		Roots:              fulcio.Roots,
+		RegistryClientOpts: ao.Registry.ClientOpts(ctx),
+	}
+	verified, err := cosign.Verify(ctx, ref, co)
+	if err != nil {
+		return errors.Wrapf(err, "verifying %s", imageRef)
+	}
+	digest, err := claimedDigest(verified[0])
+	if err != nil {
+		return err
+	}
+	created, err := cosign.CreationTime()
+	if err != nil {
+		return err
+	}
+	sig, err := atomic.Sign(atomic.NewPayload(ref, digest, "cosign", created), signer)
+	if err != nil {
+		return err
+	}
+	p, err := atomic.Write(ao.Sigstore, ref, digest, sig)
+	if err != nil {
+		return err
+	}
+	log.Infof("Wrote atomic signature of %s to %s", imageRef, p)
+	if ao.PolicyKeyPath == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(map[string]interface{}{"transports": atomic.PolicyTransports(ref, ao.PolicyKeyPath)}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// AtomicImportOpts holds the settings for AtomicImportCmd.
+type AtomicImportOpts struct {
+	// Keyring is the path to the OpenPGP public keys the atomic signature must verify against.
+	Keyring string
+	// Sigstore is the lookaside directory to read atomic signatures from.
+	Sigstore string
+	// KeyRef and KmsVal are the cosign key to sign with.
+	KeyRef   string
+	KmsVal   string
+	Registry RegistryOpts
+}
+
+func atomicImport() *ffcli.Command {
+	var (
+		flagset  = flag.NewFlagSet("cosign atomic import", flag.ExitOnError)
+		keyring  = flagset.String("keyring", "", "path to the OpenPGP public keys the atomic signature must verify against, such as one exported with `gpg --export`")
+		sigstore = flagset.String("sigstore", "", "lookaside directory to read atomic signatures from")
+		key      = flagset.String("key", "", "path to the private key to sign with")
+		kmsVal   = flagset.String("kms", "", "sign with a private key stored in a KMS")
+		registry = addRegistryFlags(flagset)
+	)
+	return &ffcli.Command{
+		Name:       "import",
+		ShortUsage: "cosign atomic import -keyring <path> -sigstore <dir> -key <key path>|-kms <kms uri> <image uri>",
+		ShortHelp:  "Sign an image with cosign if it has a verified atomic signature",
+		LongHelp: `Sign an image with cosign if it has a verified atomic signature.
+
+The image's atomic signatures are read from <dir>/<repository>@sha256=<digest>/signature-<n>.
+If one verifies against the keyring and claims the image's digest, the image is signed with the
+cosign key, recording the fingerprint of the OpenPGP key in the "` + atomicSignerAnnotation + `"
+annotation.
+
+EXAMPLES
+  # carry over signatures made with "podman push --sign-by"
+  cosign atomic import -keyring release.gpg -sigstore /var/lib/containers/sigstore -key cosign.key <IMAGE>`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 || *keyring == "" || *sigstore == "" || (*key == "" && *kmsVal == "") {
+				return flag.ErrHelp
+			}
+			ao := AtomicImportOpts{
+				Keyring:  *keyring,
+				Sigstore: *sigstore,
+				KeyRef:   *key,
+				KmsVal:   *kmsVal,
+				Registry: *registry,
+			}
+			return AtomicImportCmd(ctx, ao, args[0], GetPass)
+		},
+	}
+}
+
+// AtomicImportCmd verifies an atomic signature of the image, and signs it with the cosign key.
+func AtomicImportCmd(ctx context.Context, ao AtomicImportOpts, imageRef string, pf cosign.PassFunc) error {
+	if ao.KeyRef != "" && ao.KmsVal != "" {
+		return &KeyParseError{}
+	}
+	ref, err := ao.Registry.ParseReference(imageRef)
+	if err != nil {
+		return err
+	}
+	keyring, err := loadKeyring(ao.Keyring)
+	if err != nil {
+		return errors.Wrap(err, "loading keyring")
+	}
+	get, err := remote.Get(ref, ao.Registry.ClientOpts(ctx)...)
+	if err != nil {
+		return errors.Wrap(err, "getting remote image")
+	}
+	sigs, err := atomic.Read(ao.Sigstore, ref, get.Digest)
+	if err != nil {
+		return err
+	}
+	var signer *openpgp.Entity
+	for i, sig := range sigs {
+		p, e, err := atomic.Verify(sig, keyring)
+		if err != nil {
+			log.Debugf("atomic signature %d of %s: %v", i+1, imageRef, err)
+			continue
+		}
+		if p.Critical.Image.DockerManifestDigest != get.Digest.String() {
+			log.Debugf("atomic signature %d of %s is for %s", i+1, imageRef, p.Critical.Image.DockerManifestDigest)
+			continue
+		}
+		signer = e
+		break
+	}
+	if signer == nil {
+		return fmt.Errorf("no atomic signature of %s verified against %s", imageRef, ao.Keyring)
+	}
+
+	so := SignOpts{
+		KeyRef:      ao.KeyRef,
+		KmsVal:      ao.KmsVal,
+		Upload:      true,
+		Annotations: map[string]string{atomicSignerAnnotation: fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint)},
+		Registry:    ao.Registry,
+	}
+	is, err := newImageSigner(ctx, so, pf)
+	if err != nil {
+		return err
+	}
+	// Sign the digest the atomic signature is for, in case the tag moved since.
+	return is.sign(ctx, so, ref.Context().Digest(get.Digest.String()).String())
+}
+
+// loadPGPSigner returns the first entity in the keyring at path that has a private key, decrypting it if needed.
+func loadPGPSigner(path string, pf cosign.PassFunc) (*openpgp.Entity, error) {
+	keyring, err := loadKeyring(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range keyring {
+		if e.PrivateKey == nil {
+			continue
+		}
+		if e.PrivateKey.Encrypted {
+			pass, err := pf(false)
+			if err != nil {
+				return nil, err
+			}
+			if err := e.PrivateKey.Decrypt(pass); err != nil {
+				return nil, err
+			}
+			for _, sk := range e.Subkeys {
+				if sk.PrivateKey != nil && sk.PrivateKey.Encrypted {
+					if err := sk.PrivateKey.Decrypt(pass); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		return e, nil
+	}
+	return nil, fmt.Errorf("no private key in %s", path)
+}
+
+// claimedDigest returns the image digest a verified signature claims.
+func claimedDigest(sig cosign.VerifiedSignature) (v1.Hash, error) {
+	ss := sig.Claims
+	if ss == nil {
+		ss = &cosign.SimpleSigning{}
+		if err := json.Unmarshal(sig.Payload, ss); err != nil {
+			return v1.Hash{}, err
+		}
+	}
+	return v1.NewHash(ss.Critical.Image.DockerManifestDigest)
+}
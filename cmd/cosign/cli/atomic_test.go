@@ -0,0 +1,146 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+func TestAtomicExportImport(t *testing.T) {
+	keyring.MockInit()
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	imgs := []string{}
+	for _, repo := range []string{"signed", "unsigned"} {
+		ref, err := name.ParseReference(host + "/" + repo + ":latest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := random.Image(10, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+		imgs = append(imgs, ref.String())
+	}
+
+	td, err := ioutil.TempDir("", "cosign-atomic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	pass := func(bool) ([]byte, error) { return []byte("hunter2"), nil }
+	writeKeys := func(prefix string) (string, string) {
+		keys, err := cosign.GenerateKeyPair(pass)
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv, pub := filepath.Join(td, prefix+".key"), filepath.Join(td, prefix+".pub")
+		if err := ioutil.WriteFile(priv, keys.PrivateBytes, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(pub, keys.PublicBytes, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return priv, pub
+	}
+	cosignPriv, cosignPub := writeKeys("cosign")
+	importPriv, importPub := writeKeys("import")
+
+	config := &packet.Config{DefaultHash: crypto.SHA256}
+	entity, err := openpgp.NewEntity("release", "", "release@example.com", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, public := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := entity.SerializePrivate(secret, config); err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(public); err != nil {
+		t.Fatal(err)
+	}
+	pgpSecret, pgpPublic := filepath.Join(td, "release.gpg.key"), filepath.Join(td, "release.gpg")
+	if err := ioutil.WriteFile(pgpSecret, secret.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(pgpPublic, public.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sigstore := filepath.Join(td, "sigstore")
+
+	if err := SignCmd(ctx, SignOpts{KeyRef: cosignPriv, Upload: true}, imgs[0], pass); err != nil {
+		t.Fatal(err)
+	}
+	ao := AtomicExportOpts{Key: cosignPub, PGPKey: pgpSecret, Sigstore: sigstore, PolicyKeyPath: "/etc/pki/containers/release.gpg"}
+	out := &bytes.Buffer{}
+	if err := AtomicExportCmd(ctx, ao, imgs[0], pass, out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"keyPath": "/etc/pki/containers/release.gpg"`) || !strings.Contains(out.String(), host+"/signed") {
+		t.Errorf("policy = %s, want a signedBy requirement for the repository", out)
+	}
+	// Images without a verified cosign signature aren't exported.
+	if err := AtomicExportCmd(ctx, ao, imgs[1], pass, out); err == nil {
+		t.Error("expected error exporting an unsigned image")
+	}
+
+	im := AtomicImportOpts{Keyring: pgpPublic, Sigstore: sigstore, KeyRef: importPriv}
+	if err := AtomicImportCmd(ctx, im, imgs[0], pass); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(imgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	importKey, err := cosign.LoadPublicKey(ctx, importPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified, err := cosign.Verify(ctx, ref, cosign.CheckOpts{Keys: []cosign.PublicKey{importKey}, ClaimVerification: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := verified[0].Claims.Optional[atomicSignerAnnotation], fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint); got != want {
+		t.Errorf("%s = %q, want %q", atomicSignerAnnotation, got, want)
+	}
+
+	if err := AtomicImportCmd(ctx, im, imgs[1], pass); err == nil {
+		t.Error("expected error importing an image without atomic signatures")
+	}
+}
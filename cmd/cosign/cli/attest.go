@@ -0,0 +1,396 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+	"github.com/sigstore/cosign/pkg/cosign/provenance"
+)
+
+// inTotoStatementType is the _type of the in-toto statements attest creates.
+const inTotoStatementType = "https://in-toto.io/Statement/v0.1"
+
+// AttestOpts holds the settings for AttestCmd.
+type AttestOpts struct {
+	KeyRef string
+	KmsVal string
+	// PredicatePath is a JSON file holding the predicate to attest.
+	PredicatePath string
+	// PredicateType is a URI, or one of the short names in cosign.PredicateTypes.
+	PredicateType string
+	// MorePredicates are attested along with PredicatePath, and pushed together with it.
+	MorePredicates []PredicateFile
+	// PredicateFrom is a CI system, one of provenance.Systems, to generate SLSA provenance of the
+	// running build in and attest along with the predicate files, if any.
+	PredicateFrom string
+	// MoreImages are subjects of the same statements as the image passed to AttestCmd, for example the
+	// other platforms of a release, and the attestations are stored with each of them.
+	MoreImages []string
+	// Replace removes attestations with the same predicate type instead of adding to them.
+	Replace bool
+	// DryRun signs and prints the envelope and destination, without writing anything.
+	DryRun bool
+	// Compress stores the envelope zstd compressed, see cosign.SignatureMetadata.
+	Compress bool
+	Registry RegistryOpts
+	// Push controls how the attestations are pushed to the registry.
+	Push PushOpts
+	// RekorURL is the address of the transparency log, see cosign.TlogServer for the default.
+	RekorURL string
+}
+
+// PredicateFile is a JSON file holding a predicate to attest, and its type.
+type PredicateFile struct {
+	Path string
+	// Type is a URI, or one of the short names in cosign.PredicateTypes.
+	Type string
+}
+
+func Attest() *ffcli.Command {
+	var (
+		flagset    = flag.NewFlagSet("cosign attest", flag.ExitOnError)
+		key        = flagset.String("key", "", "path to the private key")
+		kmsVal     = flagset.String("kms", "", "sign via a private key stored in a KMS")
+		predicates = filesFlag{}
+		types      = filesFlag{}
+		replace    = flagset.Bool("replace", false, "replace the image's existing attestations of the same predicate type, instead of adding another")
+		dryRun     = flagset.Bool("dry-run", false, "sign, but only print what would be uploaded instead of writing to the registry")
+		compress   = flagset.Bool("compress", false, "store the attestation zstd compressed, for large predicates such as SBOMs")
+		from       = flagset.String("predicate-from", "", "attest SLSA provenance of the running CI build, generated from the environment of one of "+strings.Join(provenance.Systems(), ", "))
+		progress   = addProgressFlag(flagset)
+		registry   = addRegistryFlags(flagset)
+		push       = addPushFlags(flagset)
+		rekorURL   string
+	)
+	flagset.Var(&predicates, "predicate", "path to the JSON predicate to attest, may be repeated")
+	flagset.Var(&types, "type", "predicate type, a URI or one of "+predicateTypeNames()+", custom by default; repeat it once per -predicate to attest predicates of different types")
+	addRekorURLFlag(flagset, &rekorURL)
+	return &ffcli.Command{
+		Name:       "attest",
+		ShortUsage: "cosign attest -key <key path>|<kms uri> -predicate <path> [-type <type>] [-predicate <path> [-type <type>]...] [-predicate-from <ci system>] [-replace] [-compress] [-parallel <n>] [-progress] [-dry-run] <image uri> [<image uri>...]",
+		ShortHelp:  "Attest the supplied container image.",
+		LongHelp: `Attest the supplied container image.
+
+The predicate is wrapped in an in-toto statement about the image, signed in a DSSE envelope
+and stored next to the image's signatures. By default each attestation is added to those already
+there; with -replace, earlier attestations of the same predicate type are removed.
+With -compress, the envelope is stored zstd compressed. This keeps multi-megabyte predicates
+small in the registry; verifying them needs a cosign release that can decompress them.
+Several predicates can be attested at once by repeating -predicate, with one -type for all of
+them or one per predicate in the same order. Their attestations are pushed concurrently, -parallel
+at a time, and added to the image in a single write.
+With -predicate-from, SLSA provenance of the running build is attested too, or instead of
+predicate files. It is assembled from the environment variables and event payload of the CI
+system: github for GitHub Actions, gitlab for GitLab CI, or tekton for a TaskRun step that
+sets the TEKTON_* variables described in USAGE.md.
+When several images are given, each statement lists all of them as subjects, so a multi-arch
+release needs one attestation rather than one per platform. It is stored with every image, and
+verify-attestation accepts it for any of them.
+With COSIGN_EXPERIMENTAL=1, the envelope is also added to the transparency log as an intoto entry.
+
+EXAMPLES
+  # attach SLSA provenance to an image
+  cosign attest -key cosign.key -predicate provenance.json -type slsaprovenance <IMAGE>
+
+  # re-attest in CI without accumulating old attestations
+  cosign attest -key cosign.key -predicate scan.json -type vuln -replace <IMAGE>
+
+  # attach an SBOM and provenance from a release job in one go
+  cosign attest -key cosign.key -predicate sbom.spdx.json -type spdx -predicate provenance.json -type slsaprovenance <IMAGE>
+
+  # attach a large SBOM, compressed
+  cosign attest -key cosign.key -predicate sbom.spdx.json -type spdx -compress <IMAGE>
+
+  # attest the provenance of every platform of a release in one statement
+  cosign attest -key cosign.key -predicate provenance.json -type slsaprovenance <IMAGE>@<AMD64 DIGEST> <IMAGE>@<ARM64 DIGEST>
+
+  # attest the provenance of the GitHub Actions workflow run that built the image
+  cosign attest -key cosign.key -predicate-from github <IMAGE>
+
+  # print the signed envelope and where it would go, without pushing it
+  cosign attest -key cosign.key -predicate provenance.json -type slsaprovenance -dry-run <IMAGE>
+
+  # attest with Google sign-in (experimental)
+  COSIGN_EXPERIMENTAL=1 cosign attest -predicate provenance.json -type slsaprovenance <IMAGE>`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if !cosign.Experimental() && *key == "" && *kmsVal == "" {
+				return &KeyParseError{}
+			}
+			if len(args) == 0 || (len(predicates) == 0 && *from == "") {
+				return flag.ErrHelp
+			}
+			ao := AttestOpts{
+				KeyRef:        *key,
+				KmsVal:        *kmsVal,
+				PredicateFrom: *from,
+				MoreImages:    args[1:],
+				Replace:       *replace,
+				DryRun:        *dryRun,
+				Compress:      *compress,
+				Registry:      *registry,
+				Push:          *push,
+				RekorURL:      rekorURL,
+			}
+			if len(predicates) > 0 {
+				files, err := predicateFiles(predicates, types)
+				if err != nil {
+					return err
+				}
+				ao.PredicatePath, ao.PredicateType, ao.MorePredicates = files[0].Path, files[0].Type, files[1:]
+			} else if len(types) > 0 {
+				return errors.New("-type only applies to -predicate files, -predicate-from attests slsaprovenance")
+			}
+			return AttestCmd(withProgress(ctx, *progress), ao, args[0], GetPass)
+		},
+	}
+}
+
+// dryRunAttestation is printed by attest -dry-run in place of uploading the attestation.
+type dryRunAttestation struct {
+	Image    string           `json:"image"`
+	Tag      string           `json:"tag"`
+	Replace  bool             `json:"replace"`
+	Envelope *cosign.Envelope `json:"envelope"`
+	Cert     string           `json:"cert,omitempty"`
+}
+
+// predicateFiles pairs each predicate with its type: all of them get the only type if there is one,
+// custom if there is none.
+func predicateFiles(paths, types []string) ([]PredicateFile, error) {
+	switch len(types) {
+	case 0:
+		types = []string{"custom"}
+		fallthrough
+	case 1:
+		for len(types) < len(paths) {
+			types = append(types, types[0])
+		}
+	case len(paths):
+	default:
+		return nil, fmt.Errorf("%d -type flags for %d predicates, pass one for all of them or one per -predicate", len(types), len(paths))
+	}
+	files := make([]PredicateFile, 0, len(paths))
+	for i, path := range paths {
+		files = append(files, PredicateFile{Path: path, Type: types[i]})
+	}
+	return files, nil
+}
+
+// predicate is the JSON body of a predicate, and its type.
+type predicate struct {
+	typ  string
+	body []byte
+}
+
+// AttestCmd signs an in-toto statement about imageRef, and ao.MoreImages, carrying each predicate, and stores
+// them with the images.
+func AttestCmd(ctx context.Context, ao AttestOpts, imageRef string, pf cosign.PassFunc) error {
+	if ao.KeyRef != "" && ao.KmsVal != "" {
+		return &KeyParseError{}
+	}
+	files := ao.MorePredicates
+	if ao.PredicatePath != "" {
+		files = append([]PredicateFile{{Path: ao.PredicatePath, Type: ao.PredicateType}}, files...)
+	}
+	predicates := make([]predicate, 0, len(files)+1)
+	if ao.PredicateFrom != "" {
+		b, err := ciProvenance(ao.PredicateFrom)
+		if err != nil {
+			return err
+		}
+		predicates = append(predicates, predicate{typ: "slsaprovenance", body: b})
+	}
+	for _, f := range files {
+		b, err := readPredicate(f.Path)
+		if err != nil {
+			return err
+		}
+		predicates = append(predicates, predicate{typ: f.Type, body: b})
+	}
+	return attestPredicates(ctx, ao, imageRef, predicates, pf)
+}
+
+// ciProvenance returns SLSA provenance of the build running in the CI system, in canonical form.
+func ciProvenance(system string) ([]byte, error) {
+	p, err := provenance.Generate(system, os.Getenv)
+	if err != nil {
+		return nil, errors.Wrap(err, "generating provenance")
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Generated provenance of %s from builder %s", p.Metadata.BuildInvocationID, p.Builder.ID)
+	return cosign.CanonicalJSON(b)
+}
+
+// readPredicate returns the JSON predicate at path in canonical form.
+func readPredicate(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading predicate")
+	}
+	// The predicate is signed in canonical form, so reformatting the file doesn't change the attestation.
+	b, err = cosign.CanonicalJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("predicate %s is not valid JSON: %v", path, err)
+	}
+	return b, nil
+}
+
+// attestPredicate signs an in-toto statement about imageRef carrying the JSON predicate of type
+// ao.PredicateType, and stores it with the image. ao.PredicatePath is ignored.
+func attestPredicate(ctx context.Context, ao AttestOpts, imageRef string, body []byte, pf cosign.PassFunc) error {
+	return attestPredicates(ctx, ao, imageRef, []predicate{{typ: ao.PredicateType, body: body}}, pf)
+}
+
+// attestPredicates signs an in-toto statement about imageRef, and the images in ao.MoreImages, for each
+// predicate, and stores them with each of the images together.
+func attestPredicates(ctx context.Context, ao AttestOpts, imageRef string, predicates []predicate, pf cosign.PassFunc) error {
+	subjects, err := attestSubjects(ctx, ao, append([]string{imageRef}, ao.MoreImages...))
+	if err != nil {
+		return err
+	}
+
+	is, err := newImageSigner(ctx, SignOpts{KeyRef: ao.KeyRef, KmsVal: ao.KmsVal}, pf)
+	if err != nil {
+		return err
+	}
+	statementSubjects := make([]cosign.Subject, 0, len(subjects))
+	for _, s := range subjects {
+		statementSubjects = append(statementSubjects, cosign.Subject{
+			Name:   s.ref.Context().Name(),
+			Digest: map[string]string{s.desc.Digest.Algorithm: s.desc.Digest.Hex},
+		})
+	}
+	envs := make([]*cosign.Envelope, 0, len(predicates))
+	for _, p := range predicates {
+		st := cosign.Statement{
+			Type:          inTotoStatementType,
+			PredicateType: cosign.PredicateTypeURI(p.typ),
+			Subject:       statementSubjects,
+			Predicate:     p.body,
+		}
+		payload, err := json.Marshal(st)
+		if err != nil {
+			return err
+		}
+		env, err := cosign.SignEnvelope(ctx, is.signer, is.keyID, cosign.InTotoPayloadType, payload)
+		if err != nil {
+			return err
+		}
+		envs = append(envs, env)
+	}
+
+	if ao.DryRun {
+		log.Infof("Dry run, nothing was uploaded.")
+		for _, s := range subjects {
+			for _, env := range envs {
+				if err := printJSON(os.Stdout, dryRunAttestation{
+					Image:    s.ref.Context().Digest(s.desc.Digest.String()).String(),
+					Tag:      s.dstRef.String(),
+					Replace:  ao.Replace,
+					Envelope: env,
+					Cert:     is.cert,
+				}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	// The envelopes are the same for every image, so they are only logged once.
+	if cosign.Experimental() {
+		for _, env := range envs {
+			entry, err := cosign.UploadAttestationTLog(ctx, ao.RekorURL, env, is.pemBytes)
+			if err != nil {
+				return err
+			}
+			log.Infof("%s", entry)
+		}
+	}
+	md := cosign.SignatureMetadata{Cert: is.cert, Chain: is.chain, Compress: ao.Compress}
+	for _, s := range subjects {
+		po, err := ao.Push.pushOpts(ao.Registry, s.dstRef.Context())
+		if err != nil {
+			return err
+		}
+		if len(envs) == 1 {
+			log.Infof("Pushing attestation to: %s", s.dstRef.String())
+		} else {
+			log.Infof("Pushing %d attestations to: %s", len(envs), s.dstRef.String())
+		}
+		if ao.Replace {
+			err = cosign.ReplaceAttestations(ctx, envs, s.dstRef, md, po, ao.Registry.ClientOpts(ctx)...)
+		} else {
+			err = cosign.UploadAttestations(ctx, envs, s.dstRef, md, po, ao.Registry.ClientOpts(ctx)...)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "attesting %s", s.ref)
+		}
+	}
+	return nil
+}
+
+// attestSubject is an image the attestations are about, and where they are stored for it.
+type attestSubject struct {
+	ref    name.Reference
+	desc   *remote.Descriptor
+	dstRef name.Reference
+}
+
+// attestSubjects resolves the images to attest. Images given twice, by the same digest, are only
+// attested once.
+func attestSubjects(ctx context.Context, ao AttestOpts, imageRefs []string) ([]attestSubject, error) {
+	subjects := make([]attestSubject, 0, len(imageRefs))
+	seen := map[string]bool{}
+	for _, imageRef := range imageRefs {
+		ref, err := ao.Registry.ParseReference(imageRef)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing reference")
+		}
+		get, err := remote.Get(ref, ao.Registry.ClientOpts(ctx)...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting remote image %s", imageRef)
+		}
+		key := ref.Context().Digest(get.Digest.String()).String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		dstRef, err := cosign.AttestationRef(ref, get)
+		if err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, attestSubject{ref: ref, desc: get, dstRef: dstRef})
+	}
+	return subjects, nil
+}
@@ -0,0 +1,195 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// AttestBlobOpts holds the settings for AttestBlobCmd.
+type AttestBlobOpts struct {
+	KeyRef string
+	KmsVal string
+	// PredicatePath is a JSON file holding the predicate to attest.
+	PredicatePath string
+	// PredicateType is a URI, or one of the short names in cosign.PredicateTypes.
+	PredicateType string
+	// OutputAttestation is where the envelope is written, stdout if it is empty.
+	OutputAttestation string
+	// OutputCertificate is where the certificate and its chain are written when signing keyless.
+	OutputCertificate string
+	// RekorURL is the address of the transparency log, see cosign.TlogServer for the default.
+	RekorURL string
+}
+
+func AttestBlob() *ffcli.Command {
+	var (
+		flagset    = flag.NewFlagSet("cosign attest-blob", flag.ExitOnError)
+		key        = flagset.String("key", "", "path to the private key")
+		kmsVal     = flagset.String("kms", "", "sign via a private key stored in a KMS")
+		predicate  = flagset.String("predicate", "", "path to the JSON predicate to attest")
+		typ        = flagset.String("type", "custom", "predicate type, a URI or one of "+predicateTypeNames())
+		outputAtt  = flagset.String("output-attestation", "", "path to write the attestation to, stdout by default")
+		outputCert = flagset.String("output-certificate", "", "path to write the certificate and its chain to when signing keyless")
+		rekorURL   string
+	)
+	addRekorURLFlag(flagset, &rekorURL)
+	return &ffcli.Command{
+		Name:       "attest-blob",
+		ShortUsage: "cosign attest-blob -key <key path>|<kms uri> -predicate <path> [-type <type>] [-output-attestation <path>] <blob> [<blob>...]",
+		ShortHelp:  "Attest the supplied blobs.",
+		LongHelp: `Attest the supplied blobs.
+
+The predicate is wrapped in an in-toto statement whose subjects are the SHA256 digests of the
+blobs, named after the files, and signed in a DSSE envelope. There is no registry to store it in,
+so the envelope is written to stdout or -output-attestation, to publish next to the blobs.
+With COSIGN_EXPERIMENTAL=1, the envelope is also added to the transparency log as an intoto entry,
+and the Fulcio certificate it was signed with can be written to -output-certificate.
+Verify it with "cosign verify-blob-attestation".
+
+EXAMPLES
+  # attach SLSA provenance to a release tarball
+  cosign attest-blob -key cosign.key -predicate provenance.json -type slsaprovenance -output-attestation <FILE>.intoto.json <FILE>
+
+  # attest every artifact of a release in one statement
+  cosign attest-blob -key cosign.key -predicate provenance.json -type slsaprovenance <FILE> <FILE>... > release.intoto.json
+
+  # attest with Google sign-in (experimental), keeping the certificate to verify against
+  COSIGN_EXPERIMENTAL=1 cosign attest-blob -predicate provenance.json -type slsaprovenance -output-certificate <FILE>.pem <FILE> > <FILE>.intoto.json`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if !cosign.Experimental() && *key == "" && *kmsVal == "" {
+				return &KeyParseError{}
+			}
+			if len(args) == 0 || *predicate == "" {
+				return flag.ErrHelp
+			}
+			if *outputCert != "" && (*key != "" || *kmsVal != "") {
+				return errors.New("-output-certificate can only be used when signing keyless")
+			}
+			ao := AttestBlobOpts{
+				KeyRef:            *key,
+				KmsVal:            *kmsVal,
+				PredicatePath:     *predicate,
+				PredicateType:     *typ,
+				OutputAttestation: *outputAtt,
+				OutputCertificate: *outputCert,
+				RekorURL:          rekorURL,
+			}
+			_, err := AttestBlobCmd(ctx, ao, args, GetPass)
+			return err
+		},
+	}
+}
+
+// AttestBlobCmd signs an in-toto statement about the blobs carrying the predicate, and writes the envelope
+// to ao.OutputAttestation.
+func AttestBlobCmd(ctx context.Context, ao AttestBlobOpts, blobs []string, pf cosign.PassFunc) (*cosign.Envelope, error) {
+	if ao.KeyRef != "" && ao.KmsVal != "" {
+		return nil, &KeyParseError{}
+	}
+	body, err := readPredicate(ao.PredicatePath)
+	if err != nil {
+		return nil, err
+	}
+	subjects := make([]cosign.Subject, 0, len(blobs))
+	for _, blob := range blobs {
+		digest, err := blobDigest(blob)
+		if err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, cosign.Subject{
+			Name:   filepath.Base(blob),
+			Digest: map[string]string{digest.Algorithm: digest.Hex},
+		})
+	}
+
+	is, err := newImageSigner(ctx, SignOpts{KeyRef: ao.KeyRef, KmsVal: ao.KmsVal}, pf)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(cosign.Statement{
+		Type:          inTotoStatementType,
+		PredicateType: cosign.PredicateTypeURI(ao.PredicateType),
+		Subject:       subjects,
+		Predicate:     body,
+	})
+	if err != nil {
+		return nil, err
+	}
+	env, err := cosign.SignEnvelope(ctx, is.signer, is.keyID, cosign.InTotoPayloadType, payload)
+	if err != nil {
+		return nil, err
+	}
+	if cosign.Experimental() {
+		entry, err := cosign.UploadAttestationTLog(ctx, ao.RekorURL, env, is.pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("%s", entry)
+	}
+
+	if ao.OutputCertificate != "" && is.cert != "" {
+		if err := ioutil.WriteFile(filepath.Clean(ao.OutputCertificate), []byte(is.cert+is.chain), 0600); err != nil {
+			return nil, errors.Wrap(err, "writing certificate")
+		}
+		log.Infof("Certificate written to %s", ao.OutputCertificate)
+	}
+	if ao.OutputAttestation == "" {
+		return env, printJSON(os.Stdout, env)
+	}
+	f, err := os.Create(filepath.Clean(ao.OutputAttestation))
+	if err != nil {
+		return nil, errors.Wrap(err, "writing attestation")
+	}
+	err = printJSON(f, env)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "writing attestation")
+	}
+	log.Infof("Attestation written to %s", ao.OutputAttestation)
+	return env, nil
+}
+
+// blobDigest returns the SHA256 digest of the blob at path.
+func blobDigest(path string) (v1.Hash, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return v1.Hash{}, errors.Wrapf(err, "hashing %s", path)
+	}
+	return v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(h.Sum(nil))}, nil
+}
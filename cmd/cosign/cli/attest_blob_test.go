@@ -0,0 +1,106 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+func TestAttestBlobCmd(t *testing.T) {
+	ctx := context.Background()
+	td := t.TempDir()
+	pass := func(bool) ([]byte, error) { return []byte("hunter2"), nil }
+	keys, err := cosign.GenerateKeyPair(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(td, "cosign.key")
+	pubPath := filepath.Join(td, "cosign.pub")
+	if err := ioutil.WriteFile(keyPath, keys.PrivateBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(pubPath, keys.PublicBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	predicatePath := filepath.Join(td, "predicate.json")
+	if err := ioutil.WriteFile(predicatePath, []byte(`{"builder":{"id":"ci"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	blobs := []string{}
+	for name, content := range map[string]string{"app-linux-amd64.tar.gz": "amd64", "app-linux-arm64.tar.gz": "arm64", "other.tar.gz": "other"} {
+		path := filepath.Join(td, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if name != "other.tar.gz" {
+			blobs = append(blobs, path)
+		}
+	}
+
+	attPath := filepath.Join(td, "release.intoto.json")
+	ao := AttestBlobOpts{KeyRef: keyPath, PredicatePath: predicatePath, PredicateType: "slsaprovenance", OutputAttestation: attPath}
+	if _, err := AttestBlobCmd(ctx, ao, blobs, pass); err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func(c VerifyBlobAttestationCommand, blob string) (*cosign.VerifiedAttestation, error) {
+		c.Attestation = attPath
+		if c.Key == "" {
+			c.Key = pubPath
+		}
+		return c.verify(ctx, blob, &bytes.Buffer{})
+	}
+	// Each of the blobs verifies, by path or by digest.
+	for _, blob := range blobs {
+		va, err := verify(VerifyBlobAttestationCommand{PredicateType: "slsaprovenance"}, blob)
+		if err != nil {
+			t.Fatalf("verifying %s: %v", blob, err)
+		}
+		if len(va.Statement.Subject) != 2 {
+			t.Errorf("statement has %d subjects, want 2", len(va.Statement.Subject))
+		}
+		digest, err := blobDigest(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := verify(VerifyBlobAttestationCommand{Digest: digest.String()}, ""); err != nil {
+			t.Errorf("verifying digest of %s: %v", blob, err)
+		}
+	}
+
+	if _, err := verify(VerifyBlobAttestationCommand{}, filepath.Join(td, "other.tar.gz")); err == nil {
+		t.Error("verifying a blob the attestation isn't about, expected error")
+	}
+	if _, err := verify(VerifyBlobAttestationCommand{PredicateType: "vuln"}, blobs[0]); err == nil {
+		t.Error("verifying with another predicate type, expected error")
+	}
+	otherKeys, err := cosign.GenerateKeyPair(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub := filepath.Join(td, "other.pub")
+	if err := ioutil.WriteFile(otherPub, otherKeys.PublicBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verify(VerifyBlobAttestationCommand{Key: otherPub}, blobs[0]); err == nil {
+		t.Error("verifying with another key, expected error")
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/zalando/go-keyring"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+func TestAttestCmdReplace(t *testing.T) {
+	keyring.MockInit()
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/attest:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	td, err := ioutil.TempDir("", "cosign-attest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	pass := func(bool) ([]byte, error) { return []byte("hunter2"), nil }
+	keys, err := cosign.GenerateKeyPair(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(td, "cosign.key")
+	if err := ioutil.WriteFile(keyPath, keys.PrivateBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	predicatePath := filepath.Join(td, "predicate.json")
+	if err := ioutil.WriteFile(predicatePath, []byte(`{"builder":{"id":"ci"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	attest := func(predicateType string, replace bool) {
+		ao := AttestOpts{KeyRef: keyPath, PredicatePath: predicatePath, PredicateType: predicateType, Replace: replace}
+		if err := AttestCmd(ctx, ao, ref.String(), pass); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func(predicateType string) int {
+		atts, _, err := cosign.FetchAttestations(ctx, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(cosign.FilterAttestations(atts, predicateType))
+	}
+
+	attest("slsaprovenance", false)
+	attest("slsaprovenance", false)
+	attest("vuln", false)
+	if got := count("slsaprovenance"); got != 2 {
+		t.Fatalf("%d provenance attestations after appending twice, want 2", got)
+	}
+	attest("slsaprovenance", true)
+	if got := count("slsaprovenance"); got != 1 {
+		t.Errorf("%d provenance attestations after replacing, want 1", got)
+	}
+	if got := count("vuln"); got != 1 {
+		t.Errorf("%d vuln attestations after replacing provenance, want 1", got)
+	}
+
+	// Several predicates are pushed together, replacing the attestations of each of their types.
+	ao := AttestOpts{
+		KeyRef:         keyPath,
+		PredicatePath:  predicatePath,
+		PredicateType:  "slsaprovenance",
+		MorePredicates: []PredicateFile{{Path: predicatePath, Type: "vuln"}, {Path: predicatePath, Type: "spdx"}},
+		Replace:        true,
+		Push:           PushOpts{Parallelism: 2},
+	}
+	if err := AttestCmd(ctx, ao, ref.String(), pass); err != nil {
+		t.Fatal(err)
+	}
+	for _, predicateType := range []string{"slsaprovenance", "vuln", "spdx"} {
+		if got := count(predicateType); got != 1 {
+			t.Errorf("%d %s attestations after attesting them together, want 1", got, predicateType)
+		}
+	}
+
+	// The attestations verify against the key, and are about the image.
+	atts, desc, err := cosign.FetchAttestations(ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPath := filepath.Join(td, "cosign.pub")
+	if err := ioutil.WriteFile(pubPath, keys.PublicBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cosign.LoadPublicKey(ctx, pubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified, err := cosign.VerifyAttestations(ctx, desc, atts, cosign.CheckOpts{Keys: []cosign.PublicKey{pub}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(verified) != 3 {
+		t.Errorf("%d attestations verified, want 3", len(verified))
+	}
+}
+
+func TestAttestCmdSubjects(t *testing.T) {
+	keyring.MockInit()
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+
+	refs := []name.Reference{}
+	for _, platform := range []string{"amd64", "arm64"} {
+		ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/release:" + platform)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := random.Image(10, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+		refs = append(refs, ref)
+	}
+
+	td := t.TempDir()
+	pass := func(bool) ([]byte, error) { return []byte("hunter2"), nil }
+	keys, err := cosign.GenerateKeyPair(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(td, "cosign.key")
+	if err := ioutil.WriteFile(keyPath, keys.PrivateBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	predicatePath := filepath.Join(td, "predicate.json")
+	if err := ioutil.WriteFile(predicatePath, []byte(`{"builder":{"id":"ci"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second image is given twice, it is still only attested once.
+	ao := AttestOpts{
+		KeyRef:        keyPath,
+		PredicatePath: predicatePath,
+		PredicateType: "slsaprovenance",
+		MoreImages:    []string{refs[1].String(), refs[1].String()},
+	}
+	if err := AttestCmd(ctx, ao, refs[0].String(), pass); err != nil {
+		t.Fatal(err)
+	}
+
+	pubPath := filepath.Join(td, "cosign.pub")
+	if err := ioutil.WriteFile(pubPath, keys.PublicBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cosign.LoadPublicKey(ctx, pubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co := cosign.CheckOpts{Keys: []cosign.PublicKey{pub}}
+	var payload string
+	for _, ref := range refs {
+		atts, desc, err := cosign.FetchAttestations(ctx, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(atts) != 1 {
+			t.Fatalf("%d attestations on %s, want 1", len(atts), ref)
+		}
+		verified, err := cosign.VerifyAttestations(ctx, desc, atts, co)
+		if err != nil {
+			t.Fatalf("verifying %s: %v", ref, err)
+		}
+		if got := len(verified[0].Statement.Subject); got != 2 {
+			t.Errorf("statement has %d subjects, want 2", got)
+		}
+		// Both images carry the same statement.
+		if payload != "" && atts[0].Envelope.Payload != payload {
+			t.Errorf("%s has a different statement than %s", ref, refs[0])
+		}
+		payload = atts[0].Envelope.Payload
+	}
+}
+
+func TestAttestCmdPredicateFrom(t *testing.T) {
+	keyring.MockInit()
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/provenance:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	td := t.TempDir()
+	pass := func(bool) ([]byte, error) { return []byte("hunter2"), nil }
+	keys, err := cosign.GenerateKeyPair(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(td, "cosign.key")
+	if err := ioutil.WriteFile(keyPath, keys.PrivateBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	predicatePath := filepath.Join(td, "scan.json")
+	if err := ioutil.WriteFile(predicatePath, []byte(`{"scanner":{"uri":"pkg:github/aquasecurity/trivy"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]string{
+		"GITHUB_REPOSITORY":   "acme/app",
+		"GITHUB_SHA":          "0123456789abcdef0123456789abcdef01234567",
+		"GITHUB_RUN_ID":       "42",
+		"GITHUB_WORKFLOW_REF": "acme/app/.github/workflows/release.yml@refs/heads/main",
+	} {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	// The generated provenance is attested along with the predicate file.
+	ao := AttestOpts{KeyRef: keyPath, PredicateFrom: "github", PredicatePath: predicatePath, PredicateType: "vuln"}
+	if err := AttestCmd(ctx, ao, ref.String(), pass); err != nil {
+		t.Fatal(err)
+	}
+	atts, _, err := cosign.FetchAttestations(ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cosign.FilterAttestations(atts, "vuln")) != 1 {
+		t.Errorf("the vuln predicate wasn't attested along with the provenance")
+	}
+	provenance := cosign.FilterAttestations(atts, "slsaprovenance")
+	if len(provenance) != 1 {
+		t.Fatalf("%d provenance attestations, want 1", len(provenance))
+	}
+	st, err := provenance[0].Statement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := cosign.ParseSLSAProvenance(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://github.com/acme/app/.github/workflows/release.yml@refs/heads/main"; p.Builder.ID != want {
+		t.Errorf("builder = %q, want %q", p.Builder.ID, want)
+	}
+
+	ao = AttestOpts{KeyRef: keyPath, PredicateFrom: "tekton"}
+	if err := AttestCmd(ctx, ao, ref.String(), pass); err == nil {
+		t.Error("attesting Tekton provenance outside of a TaskRun, expected error")
+	}
+}
+
+func TestPredicateFiles(t *testing.T) {
+	tests := []struct {
+		paths, types []string
+		want         []string
+		wantErr      bool
+	}{
+		{paths: []string{"a.json"}, want: []string{"custom"}},
+		{paths: []string{"a.json", "b.json"}, types: []string{"spdx"}, want: []string{"spdx", "spdx"}},
+		{paths: []string{"a.json", "b.json"}, types: []string{"spdx", "vuln"}, want: []string{"spdx", "vuln"}},
+		{paths: []string{"a.json", "b.json", "c.json"}, types: []string{"spdx", "vuln"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		files, err := predicateFiles(tt.paths, tt.types)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("predicateFiles(%v, %v) error = %v, wantErr %v", tt.paths, tt.types, err, tt.wantErr)
+			continue
+		}
+		for i, f := range files {
+			if f.Path != tt.paths[i] || f.Type != tt.want[i] {
+				t.Errorf("predicateFiles(%v, %v)[%d] = %+v, want type %s", tt.paths, tt.types, i, f, tt.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/buildkit"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// Build builds and returns an ffcli command
+func Build() *ffcli.Command {
+	var (
+		flagset     = flag.NewFlagSet("cosign build", flag.ExitOnError)
+		key         = flagset.String("key", "", "path to the private key")
+		kmsVal      = flagset.String("kms", "", "sign via a private key stored in a KMS")
+		force       = flagset.Bool("f", false, "skip warnings and confirmations, and push a new signature even if the image already has one of the same payload with the same key")
+		annotations = annotationsMap{}
+		registry    = addRegistryFlags(flagset)
+		digest      = addDigestFlags(flagset)
+		rekorURL    string
+	)
+	flagset.Var(&annotations, "a", "extra key=value pairs to sign")
+	addRekorURLFlag(flagset, &rekorURL)
+	return &ffcli.Command{
+		Name:       "build",
+		ShortUsage: "cosign build [-key <key path>|-kms <kms uri>] [-a key=value] [-f] -- <docker buildx build args>...",
+		ShortHelp:  "Build and push an image with docker buildx, and sign it",
+		LongHelp: `Build and push an image with docker buildx, and sign it.
+
+The arguments after -- are passed to "docker buildx build", which pushes the image unless they
+set an --output. The images are signed by the digest BuildKit pushed them with, read from the
+build's metadata file, so a tag pushed over by another build in the meantime isn't signed.
+docker buildx v0.6.0 or later is required.
+
+EXAMPLES
+  # build, push and sign an image
+  cosign build -key cosign.key -- -t gcr.io/acme/app:v1 .
+
+  # build for several platforms, pushing and signing the image index
+  COSIGN_EXPERIMENTAL=1 cosign build -- --platform linux/amd64,linux/arm64 -t gcr.io/acme/app:v1 .
+
+  # sign the images a build run separately pushed, by digest
+  docker buildx build --push --metadata-file build.json -t gcr.io/acme/app:v1 .
+  cosign sign -key cosign.key -buildkit-metadata build.json`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			// A key file (or kms address) is required unless we're in experimental mode!
+			if !cosign.Experimental() && *key == "" && *kmsVal == "" {
+				return &KeyParseError{}
+			}
+			if len(args) == 0 {
+				return flag.ErrHelp
+			}
+			so := SignOpts{
+				KeyRef:      *key,
+				KmsVal:      *kmsVal,
+				Upload:      true,
+				Annotations: annotations.annotations,
+				Force:       *force,
+				Registry:    *registry,
+				Digest:      digest,
+				RekorURL:    rekorURL,
+			}
+			return BuildCmd(ctx, so, args, GetPass)
+		},
+	}
+}
+
+// BuildCmd runs "docker buildx build" with buildArgs, then signs the images it pushed by digest.
+// The build's output goes to stderr.
+func BuildCmd(ctx context.Context, so SignOpts, buildArgs []string, pf cosign.PassFunc) error {
+	images, err := buildkit.Build(ctx, buildArgs, os.Stderr, os.Stderr)
+	if err != nil {
+		return err
+	}
+	for _, img := range images {
+		log.Infof("Built %s", img)
+	}
+	// Keyless certificates are short lived, they're only requested once the build is done.
+	return SignImagesCmd(ctx, so, digestRefs(images), pf)
+}
+
+// buildkitImages returns the images the build whose metadata file is at path pushed, by digest.
+func buildkitImages(path string) ([]string, error) {
+	b, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading build metadata")
+	}
+	images, err := buildkit.Images(b)
+	if err != nil {
+		return nil, err
+	}
+	return digestRefs(images), nil
+}
+
+func digestRefs(images []name.Digest) []string {
+	refs := make([]string, 0, len(images))
+	for _, img := range images {
+		refs = append(refs, img.String())
+	}
+	return refs
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// SignChecksumsCmd writes a SHA256SUMS file covering the blobs to checksumsPath and signs it.
+func SignChecksumsCmd(ctx context.Context, keyPath, kmsVal, checksumsPath string, blobs []string, opts SignBlobOpts, pf cosign.PassFunc) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := cosign.WriteChecksums(&buf, blobs); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Clean(checksumsPath), buf.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	log.Infof("Checksums written to %s", checksumsPath)
+	return SignBlobCmd(ctx, keyPath, kmsVal, checksumsPath, opts, pf)
+}
+
+// VerifyChecksumsCmd verifies the signature on a SHA256SUMS file, then checks each blob against it.
+func VerifyChecksumsCmd(ctx context.Context, keyRef, kmsVal, certRef, sigRef, checksumsRef string, blobs []string) error {
+	if err := VerifyBlobCmd(ctx, keyRef, kmsVal, certRef, sigRef, checksumsRef); err != nil {
+		return err
+	}
+	f, err := os.Open(filepath.Clean(checksumsRef))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sums, err := cosign.ParseChecksums(f)
+	if err != nil {
+		return errors.Wrap(err, "parsing checksums")
+	}
+	for _, blob := range blobs {
+		if err := sums.Verify(blob); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%s: OK\n", blob)
+	}
+	return nil
+}
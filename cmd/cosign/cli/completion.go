@@ -0,0 +1,233 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+// rootName is the name the root command is completed and described as, since it has none in ffcli.
+const rootName = "cosign"
+
+// Completion returns the completion command for the command tree under root.
+func Completion(root *ffcli.Command) *ffcli.Command {
+	flagset := flag.NewFlagSet("cosign completion", flag.ExitOnError)
+	return &ffcli.Command{
+		Name:       "completion",
+		ShortUsage: "cosign completion bash|zsh|fish|powershell",
+		ShortHelp:  "Prints a shell completion script",
+		LongHelp: `Prints a shell completion script for cosign's subcommands and flags.
+
+EXAMPLES
+  # load completions in the current bash or zsh session
+  source <(cosign completion bash)
+
+  # install completions for fish
+  cosign completion fish > ~/.config/fish/completions/cosign.fish
+
+  # load completions in PowerShell
+  cosign completion powershell | Out-String | Invoke-Expression`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return flag.ErrHelp
+			}
+			return CompletionCmd(os.Stdout, root, args[0])
+		},
+	}
+}
+
+// CompletionCmd writes the completion script for shell to w.
+func CompletionCmd(w io.Writer, root *ffcli.Command, shell string) error {
+	paths := completionWords(root)
+	switch shell {
+	case "bash":
+		return bashCompletion(w, paths)
+	case "zsh":
+		// zsh runs the bash script through its bash compatibility layer.
+		if _, err := fmt.Fprintln(w, "autoload -U +X bashcompinit && bashcompinit"); err != nil {
+			return err
+		}
+		return bashCompletion(w, paths)
+	case "fish":
+		return fishCompletion(w, root)
+	case "powershell":
+		return powershellCompletion(w, paths)
+	default:
+		return fmt.Errorf("unsupported shell %q, must be one of bash, zsh, fish or powershell", shell)
+	}
+}
+
+// completionPath is a command and the words that can follow it.
+type completionPath struct {
+	path  string
+	words []string
+}
+
+// completionWords returns each command in the tree, by its path from the root, with its
+// subcommands and flags.
+func completionWords(root *ffcli.Command) []completionPath {
+	paths := []completionPath{}
+	walkCommands(root, rootName, func(path string, c *ffcli.Command) {
+		words := []string{}
+		for _, sc := range c.Subcommands {
+			words = append(words, sc.Name)
+		}
+		if c.FlagSet != nil {
+			c.FlagSet.VisitAll(func(f *flag.Flag) {
+				words = append(words, "-"+f.Name)
+			})
+		}
+		paths = append(paths, completionPath{path: path, words: words})
+	})
+	return paths
+}
+
+func walkCommands(c *ffcli.Command, path string, fn func(string, *ffcli.Command)) {
+	fn(path, c)
+	for _, sc := range c.Subcommands {
+		walkCommands(sc, path+" "+sc.Name, fn)
+	}
+}
+
+func bashCompletion(w io.Writer, paths []completionPath) error {
+	b := &strings.Builder{}
+	subPaths := []string{}
+	for _, p := range paths[1:] {
+		subPaths = append(subPaths, fmt.Sprintf("%q", p.path))
+	}
+	fmt.Fprintf(b, `_%[1]s() {
+	local cur path words i
+	cur="${COMP_WORDS[COMP_CWORD]}"
+	path=%[1]s
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		case "$path ${COMP_WORDS[i]}" in
+		%[2]s) path="$path ${COMP_WORDS[i]}" ;;
+		esac
+	done
+	case "$path" in
+`, rootName, strings.Join(subPaths, "|"))
+	for _, p := range paths {
+		fmt.Fprintf(b, "\t%q) words=%q ;;\n", p.path, strings.Join(p.words, " "))
+	}
+	fmt.Fprintf(b, `	esac
+	COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+complete -o default -F _%[1]s %[1]s
+`, rootName)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func fishCompletion(w io.Writer, root *ffcli.Command) error {
+	b := &strings.Builder{}
+	walkCommands(root, rootName, func(path string, c *ffcli.Command) {
+		// The command is selected when its own name has been seen, but none of its subcommands'.
+		cond := "__fish_use_subcommand"
+		if path != rootName {
+			cond = "__fish_seen_subcommand_from " + c.Name
+		}
+		if len(c.Subcommands) > 0 && path != rootName {
+			names := []string{}
+			for _, sc := range c.Subcommands {
+				names = append(names, sc.Name)
+			}
+			cond += "; and not __fish_seen_subcommand_from " + strings.Join(names, " ")
+		}
+		for _, sc := range c.Subcommands {
+			fmt.Fprintf(b, "complete -c %s -f -n %q -a %s -d %q\n", rootName, cond, sc.Name, sc.ShortHelp)
+		}
+		if c.FlagSet != nil {
+			c.FlagSet.VisitAll(func(f *flag.Flag) {
+				fmt.Fprintf(b, "complete -c %s -n %q -o %s -d %q\n", rootName, cond, f.Name, f.Usage)
+			})
+		}
+	})
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func powershellCompletion(w io.Writer, paths []completionPath) error {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Register-ArgumentCompleter -Native -CommandName %s -ScriptBlock {\n", rootName)
+	b.WriteString("    param($wordToComplete, $commandAst, $cursorPosition)\n    $words = @{\n")
+	for _, p := range paths {
+		quoted := []string{}
+		for _, w := range p.words {
+			quoted = append(quoted, "'"+w+"'")
+		}
+		fmt.Fprintf(b, "        '%s' = @(%s)\n", p.path, strings.Join(quoted, ", "))
+	}
+	fmt.Fprintf(b, `    }
+    $path = '%s'
+    foreach ($e in $commandAst.CommandElements | Select-Object -Skip 1) {
+        $w = $e.ToString()
+        if ($w -eq $wordToComplete) { break }
+        if ($words.ContainsKey("$path $w")) { $path = "$path $w" }
+    }
+    $words[$path] | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`, rootName)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// CommandHelp describes a command for -help-json.
+type CommandHelp struct {
+	Name        string        `json:"name"`
+	ShortUsage  string        `json:"shortUsage,omitempty"`
+	ShortHelp   string        `json:"shortHelp,omitempty"`
+	LongHelp    string        `json:"longHelp,omitempty"`
+	Flags       []FlagHelp    `json:"flags,omitempty"`
+	Subcommands []CommandHelp `json:"subcommands,omitempty"`
+}
+
+// FlagHelp describes a flag for -help-json.
+type FlagHelp struct {
+	Name    string `json:"name"`
+	Usage   string `json:"usage"`
+	Default string `json:"default,omitempty"`
+}
+
+// DescribeCommand returns the description of c and its subcommands.
+func DescribeCommand(c *ffcli.Command) CommandHelp {
+	ch := CommandHelp{Name: c.Name, ShortUsage: c.ShortUsage, ShortHelp: c.ShortHelp, LongHelp: c.LongHelp}
+	if ch.Name == "" {
+		ch.Name = rootName
+	}
+	if c.FlagSet != nil {
+		c.FlagSet.VisitAll(func(f *flag.Flag) {
+			ch.Flags = append(ch.Flags, FlagHelp{Name: f.Name, Usage: f.Usage, Default: f.DefValue})
+		})
+	}
+	for _, sc := range c.Subcommands {
+		ch.Subcommands = append(ch.Subcommands, DescribeCommand(sc))
+	}
+	return ch
+}
+
+// HelpJSONCmd prints the description of the command tree under root as JSON.
+func HelpJSONCmd(w io.Writer, root *ffcli.Command) error {
+	return printJSON(w, DescribeCommand(root))
+}
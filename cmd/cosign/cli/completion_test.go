@@ -0,0 +1,61 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+func TestCompletionCmd(t *testing.T) {
+	root := &ffcli.Command{Subcommands: []*ffcli.Command{Verify(), Trust()}}
+	tests := []struct {
+		shell string
+		want  []string
+	}{
+		{"bash", []string{`"cosign verify") words=`, `"cosign trust add") words="-identity -key -root"`, "complete -o default -F _cosign cosign"}},
+		{"zsh", []string{"bashcompinit", `"cosign trust"|"cosign trust add"`}},
+		{"fish", []string{`-n "__fish_seen_subcommand_from trust; and not __fish_seen_subcommand_from add list remove" -a add`, `-n "__fish_seen_subcommand_from verify" -o key`}},
+		{"powershell", []string{`'cosign trust remove' = @('-identity', '-key-id')`}},
+	}
+	for _, tt := range tests {
+		b := &bytes.Buffer{}
+		if err := CompletionCmd(b, root, tt.shell); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("%s completion doesn't contain %q:\n%s", tt.shell, want, b.String())
+			}
+		}
+	}
+	if err := CompletionCmd(&bytes.Buffer{}, root, "tcsh"); err == nil {
+		t.Error("expected error for an unsupported shell")
+	}
+}
+
+func TestDescribeCommand(t *testing.T) {
+	ch := DescribeCommand(&ffcli.Command{Subcommands: []*ffcli.Command{Trust()}})
+	if ch.Name != "cosign" || len(ch.Subcommands) != 1 || len(ch.Subcommands[0].Subcommands) != 3 {
+		t.Fatalf("DescribeCommand() = %+v, want cosign with trust and its subcommands", ch)
+	}
+	add := ch.Subcommands[0].Subcommands[0]
+	if add.Name != "add" || len(add.Flags) != 3 || add.Flags[1].Name != "key" {
+		t.Errorf("DescribeCommand() trust add = %+v, want its flags", add)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"flag"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/fulcio"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// CountersignOpts holds the settings for CountersignCmd.
+type CountersignOpts struct {
+	// SignedBy is the public key, or KMS reference, of the signatures to countersign.
+	SignedBy string
+	// SignedByIdentities are the certificate emails of the signatures to countersign, used when
+	// SignedBy is empty.
+	SignedByIdentities []string
+	// KeyRef and KmsVal are the key to countersign with.
+	KeyRef      string
+	KmsVal      string
+	Annotations map[string]string
+	Registry    RegistryOpts
+	// RekorURL is the address of the transparency log, see cosign.TlogServer for the default.
+	RekorURL string
+}
+
+func Countersign() *ffcli.Command {
+	var (
+		flagset     = flag.NewFlagSet("cosign countersign", flag.ExitOnError)
+		signedBy    = flagset.String("signed-by", "", "path to the public key, or KMS reference, of the signatures to countersign")
+		identities  = filesFlag{}
+		key         = flagset.String("key", "", "path to the private key to countersign with")
+		kmsVal      = flagset.String("kms", "", "countersign with a private key stored in a KMS")
+		annotations = annotationsMap{}
+		registry    = addRegistryFlags(flagset)
+		rekorURL    string
+	)
+	addRekorURLFlag(flagset, &rekorURL)
+	flagset.Var(&identities, "signed-by-identity", "certificate email of the signatures to countersign, may be repeated")
+	flagset.Var(&annotations, "a", "extra key=value pairs to sign")
+	return &ffcli.Command{
+		Name:       "countersign",
+		ShortUsage: "cosign countersign -key <key path>|-kms <kms uri> -signed-by <key path>|<kms uri> | -signed-by-identity <email> [-a key=value] <image uri>",
+		ShortHelp:  "Endorse the existing signatures of an image by signing them",
+		LongHelp: `Endorse the existing signatures of an image by signing them.
+
+Each signature of the image that verifies against -signed-by, or against the Fulcio roots with
+one of the -signed-by-identity emails, gets a countersignature naming the image and the signature.
+Countersignatures are stored next to the signatures, under the image digest with a ".countersign"
+suffix, and "cosign verify -countersign-key" or "-countersign-identity" only accepts signatures
+that were countersigned.
+
+EXAMPLES
+  # the security team approves the release signature
+  cosign countersign -key security.key -signed-by release.pub -a approved-by=security <IMAGE>
+
+  # countersign signatures made with Fulcio certificates for the release bot
+  cosign countersign -key security.key -signed-by-identity release-bot@example.com <IMAGE>
+
+  # then require the approval when verifying
+  cosign verify -key release.pub -countersign-key security.pub <IMAGE>`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 || (*signedBy == "" && len(identities) == 0) {
+				return flag.ErrHelp
+			}
+			// A key file is required unless we're in experimental mode!
+			if !cosign.Experimental() && *key == "" && *kmsVal == "" {
+				return &KeyParseError{}
+			}
+			co := CountersignOpts{
+				SignedBy:           *signedBy,
+				SignedByIdentities: identities,
+				KeyRef:             *key,
+				KmsVal:             *kmsVal,
+				Annotations:        annotations.annotations,
+				Registry:           *registry,
+				RekorURL:           rekorURL,
+			}
+			return CountersignCmd(ctx, co, args[0], GetPass)
+		},
+	}
+}
+
+// CountersignCmd countersigns the signatures of imageRef made by opts.SignedBy or opts.SignedByIdentities.
+func CountersignCmd(ctx context.Context, opts CountersignOpts, imageRef string, pf cosign.PassFunc) error {
+	if opts.KeyRef != "" && opts.KmsVal != "" {
+		return &KeyParseError{}
+	}
+	if opts.SignedBy != "" && len(opts.SignedByIdentities) > 0 {
+		return errors.New("-signed-by and -signed-by-identity can't be used together")
+	}
+	ref, err := opts.Registry.ParseReference(imageRef)
+	if err != nil {
+		return errors.Wrap(err, "parsing reference")
+	}
+	co := cosign.CheckOpts{
+		ClaimVerification:  true,
+		TLog:               cosign.Experimental(),
+		RekorURL:           opts.RekorURL,
+		RegistryClientOpts: opts.Registry.ClientOpts(ctx),
+	}
+	if opts.SignedBy != "" {
+		pubKey, err := cosign.LoadPublicKey(ctx, opts.SignedBy)
+		if err != nil {
+			return errors.Wrap(err, "loading public key")
+		}
+		co.Keys = []cosign.PublicKey{pubKey}
+	} else {
+		co.Roots = fulcio.Roots
+		co.Identities = opts.SignedByIdentities
+	}
+	verified, err := cosign.Verify(ctx, ref, co)
+	if err != nil {
+		return errors.Wrap(err, "verifying the signatures to countersign")
+	}
+	get, err := remote.Get(ref, co.RegistryClientOpts...)
+	if err != nil {
+		return errors.Wrap(err, "getting remote image")
+	}
+	dstRef, err := cosign.CountersignatureRef(ref, get)
+	if err != nil {
+		return err
+	}
+
+	is, err := newImageSigner(ctx, SignOpts{KeyRef: opts.KeyRef, KmsVal: opts.KmsVal, Registry: opts.Registry}, pf)
+	if err != nil {
+		return err
+	}
+	md := cosign.SignatureMetadata{
+		Cert:      is.cert,
+		Chain:     is.chain,
+		KeyID:     is.keyID,
+		Algorithm: is.signer.Algorithm(),
+	}
+	for _, vs := range verified {
+		payload, err := cosign.NewCountersignaturePayload(get.Descriptor, vs.SignedPayload, opts.Annotations)
+		if err != nil {
+			return err
+		}
+		signature, err := is.signer.Sign(ctx, payload)
+		if err != nil {
+			return errors.Wrap(err, "signing")
+		}
+		if err := cosign.Upload(ctx, signature, payload, dstRef, md, co.RegistryClientOpts...); err != nil {
+			return err
+		}
+		if cosign.Experimental() {
+			entry, err := cosign.UploadTLog(ctx, opts.RekorURL, signature, payload, is.pemBytes)
+			if err != nil {
+				return err
+			}
+			log.Infof("%s", entry)
+		}
+	}
+	log.Infof("Countersigned %d signature(s) of %s, pushed to %s", len(verified), imageRef, dstRef)
+	return nil
+}
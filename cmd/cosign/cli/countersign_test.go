@@ -0,0 +1,121 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/zalando/go-keyring"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+func TestCountersignCmd(t *testing.T) {
+	keyring.MockInit()
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/release:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	td, err := ioutil.TempDir("", "cosign-countersign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	pass := func(bool) ([]byte, error) { return []byte("hunter2"), nil }
+	writeKeys := func(prefix string) (string, string) {
+		keys, err := cosign.GenerateKeyPair(pass)
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv, pub := filepath.Join(td, prefix+".key"), filepath.Join(td, prefix+".pub")
+		if err := ioutil.WriteFile(priv, keys.PrivateBytes, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(pub, keys.PublicBytes, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return priv, pub
+	}
+	releasePriv, releasePub := writeKeys("release")
+	securityPriv, securityPub := writeKeys("security")
+	_, otherPub := writeKeys("other")
+
+	if err := SignCmd(ctx, SignOpts{KeyRef: releasePriv, Upload: true}, ref.String(), pass); err != nil {
+		t.Fatal(err)
+	}
+	verify := func(countersigner string) ([]cosign.VerifiedSignature, error) {
+		release, err := cosign.LoadPublicKey(ctx, releasePub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		countersign, err := cosign.LoadPublicKey(ctx, countersigner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cosign.Verify(ctx, ref, cosign.CheckOpts{
+			Keys:              []cosign.PublicKey{release},
+			ClaimVerification: true,
+			Countersigners:    &cosign.CheckOpts{Keys: []cosign.PublicKey{countersign}},
+		})
+	}
+	if _, err := verify(securityPub); err == nil {
+		t.Error("expected error verifying before the signature was countersigned")
+	}
+
+	// Only signatures made by -signed-by are countersigned.
+	if err := CountersignCmd(ctx, CountersignOpts{SignedBy: otherPub, KeyRef: securityPriv}, ref.String(), pass); err == nil {
+		t.Error("expected error countersigning without signatures from the other key")
+	}
+	co := CountersignOpts{SignedBy: releasePub, KeyRef: securityPriv, Annotations: map[string]string{"approved-by": "security"}}
+	if err := CountersignCmd(ctx, co, ref.String(), pass); err != nil {
+		t.Fatal(err)
+	}
+
+	verified, err := verify(securityPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(verified) != 1 || len(verified[0].Countersignatures) != 1 {
+		t.Fatalf("verified %+v, want one countersigned signature", verified)
+	}
+	if got := verified[0].Countersignatures[0].Claims.Optional["approved-by"]; got != "security" {
+		t.Errorf("countersignature annotation approved-by = %q, want security", got)
+	}
+	if _, err := verify(otherPub); err == nil {
+		t.Error("expected error verifying with a countersigner that didn't countersign")
+	}
+}
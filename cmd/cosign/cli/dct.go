@@ -0,0 +1,195 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/dct"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// Annotations migrate-dct records in the signatures, so verify can require the tag a digest was signed as.
+const (
+	dctTagAnnotation  = "dev.sigstore.cosign/dct-tag"
+	dctRoleAnnotation = "dev.sigstore.cosign/dct-role"
+)
+
+// Statuses of the digests in the migration report.
+const (
+	dctSigned  = "signed"
+	dctMissing = "missing"
+	dctFailed  = "failed"
+)
+
+// MigrateDCTOpts holds the settings for MigrateDCTCmd.
+type MigrateDCTOpts struct {
+	KeyRef string
+	KmsVal string
+	// Server is the notary server to read the trust data from.
+	Server   string
+	Registry RegistryOpts
+}
+
+// dctMigration is a line of the migration report.
+type dctMigration struct {
+	dct.Target
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func MigrateDCT() *ffcli.Command {
+	var (
+		flagset  = flag.NewFlagSet("cosign migrate-dct", flag.ExitOnError)
+		key      = flagset.String("key", "", "path to the private key to sign with")
+		kmsVal   = flagset.String("kms", "", "sign with a private key stored in a KMS")
+		server   = flagset.String("server", envOr("DOCKER_CONTENT_TRUST_SERVER", dct.DefaultServer), "notary server to read the trust data from, or set $DOCKER_CONTENT_TRUST_SERVER")
+		report   = flagset.String("report", "", "path to write the migration report to instead of stdout")
+		registry = addRegistryFlags(flagset)
+	)
+	return &ffcli.Command{
+		Name:       "migrate-dct",
+		ShortUsage: "cosign migrate-dct -key <key path>|-kms <kms uri> [-server <url>] [-report <path>] <repository>",
+		ShortHelp:  "Sign the digests in a repository's Docker Content Trust data with cosign",
+		LongHelp: `Sign the digests in a repository's Docker Content Trust data with cosign.
+
+The Notary v1 trust data of the repository is read, and its signatures checked, like the docker
+CLI does with DOCKER_CONTENT_TRUST=1. Every digest signed by the targets role or a delegation, such
+as targets/releases, is signed with the cosign key, recording the tag and role in the
+"` + dctTagAnnotation + `" and "` + dctRoleAnnotation + `" annotations. Digests that are no longer in
+the registry are reported, but not signed.
+
+The report is a JSON object per line: the tag, digest, size and role, and whether it was
+"` + dctSigned + `", "` + dctMissing + `" or "` + dctFailed + `".
+
+EXAMPLES
+  # move an image off DCT, keeping every tag it ever signed
+  cosign migrate-dct -key cosign.key -report migration.json dlorenc/demo
+
+  # then verify the tag a digest was signed as
+  cosign verify -key cosign.pub -a ` + dctTagAnnotation + `=v1.2 dlorenc/demo@sha256:<DIGEST>`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return flag.ErrHelp
+			}
+			if *key == "" && *kmsVal == "" {
+				return &KeyParseError{}
+			}
+			w := io.Writer(os.Stdout)
+			if *report != "" {
+				f, err := os.Create(*report)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			mo := MigrateDCTOpts{
+				KeyRef:   *key,
+				KmsVal:   *kmsVal,
+				Server:   *server,
+				Registry: *registry,
+			}
+			return MigrateDCTCmd(ctx, mo, args[0], GetPass, w)
+		},
+	}
+}
+
+// MigrateDCTCmd signs the digests in the trust data of the repository, and writes the migration report to w.
+func MigrateDCTCmd(ctx context.Context, mo MigrateDCTOpts, repoName string, pf cosign.PassFunc, w io.Writer) error {
+	if mo.KeyRef != "" && mo.KmsVal != "" {
+		return &KeyParseError{}
+	}
+	repo, err := mo.Registry.ParseRepository(repoName)
+	if err != nil {
+		return err
+	}
+	gun := dct.GUN(repo.RegistryStr(), repo.RepositoryStr())
+	rt, err := mo.Registry.notaryTransport(mo.Server, repo, gun)
+	if err != nil {
+		return errors.Wrapf(err, "connecting to %s", mo.Server)
+	}
+	targets, err := (&dct.Client{Server: mo.Server, Transport: rt}).Targets(ctx, gun)
+	if err != nil {
+		return errors.Wrapf(err, "reading the trust data of %s", gun)
+	}
+	log.Infof("Found %d signed tag(s) of %s", len(targets), gun)
+
+	so := SignOpts{KeyRef: mo.KeyRef, KmsVal: mo.KmsVal, Upload: true, Registry: mo.Registry}
+	is, err := newImageSigner(ctx, so, pf)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, t := range targets {
+		m := dctMigration{Target: t, Status: dctSigned}
+		ref := repo.Digest(t.Digest.String())
+		if _, err := remote.Head(ref, mo.Registry.ClientOpts(ctx)...); err != nil {
+			var te *transport.Error
+			if errors.As(err, &te) && te.StatusCode == http.StatusNotFound {
+				m.Status = dctMissing
+			} else {
+				m.Status, m.Error = dctFailed, err.Error()
+			}
+		} else {
+			so.Annotations = map[string]string{dctTagAnnotation: t.Tag, dctRoleAnnotation: t.Role}
+			if err := is.sign(ctx, so, ref.String()); err != nil {
+				m.Status, m.Error = dctFailed, err.Error()
+			}
+		}
+		if m.Status == dctFailed {
+			failed++
+		}
+		if err := printJSON(w, m); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to migrate %d of %d signed tag(s)", failed, len(targets))
+	}
+	return nil
+}
+
+// notaryTransport returns a transport for reading the trust data of repo from the notary server,
+// which takes the registry's credentials.
+func (ro RegistryOpts) notaryTransport(server string, repo name.Repository, gun string) (http.RoundTripper, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+	opts := []name.Option{}
+	if u.Scheme == "http" {
+		opts = append(opts, name.Insecure)
+	}
+	reg, err := name.NewRegistry(u.Host, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return ro.transport(reg, repo.Registry, []string{fmt.Sprintf("repository:%s:%s", gun, transport.PullScope)})
+}
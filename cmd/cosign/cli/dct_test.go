@@ -0,0 +1,185 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/zalando/go-keyring"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+// notaryServer serves trust data for gun whose root and targets are signed by a single key.
+func notaryServer(t *testing.T, gun string, tags map[string]string) *httptest.Server {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(signed interface{}) []byte {
+		b, err := json.Marshal(signed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		canonical, err := cosign.CanonicalJSON(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		digest := sha256.Sum256(canonical)
+		r, s, err := ecdsa.Sign(rand.Reader, priv, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		out, err := json.Marshal(map[string]interface{}{
+			"signed":     json.RawMessage(b),
+			"signatures": []map[string]string{{"keyid": "k", "method": "ecdsa", "sig": base64.StdEncoding.EncodeToString(sig)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	targets := map[string]interface{}{}
+	for tag, digest := range tags {
+		sum, err := hex.DecodeString(strings.TrimPrefix(digest, "sha256:"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		targets[tag] = map[string]interface{}{"hashes": map[string]string{"sha256": base64.StdEncoding.EncodeToString(sum)}, "length": 1}
+	}
+	expires := time.Now().Add(time.Hour)
+	role := map[string]interface{}{"keyids": []string{"k"}, "threshold": 1}
+	files := map[string][]byte{
+		"root": sign(map[string]interface{}{
+			"expires": expires,
+			"keys":    map[string]interface{}{"k": map[string]interface{}{"keytype": "ecdsa", "keyval": map[string]string{"public": base64.StdEncoding.EncodeToString(der)}}},
+			"roles":   map[string]interface{}{"root": role, "targets": role},
+		}),
+		"targets": sign(map[string]interface{}{"expires": expires, "targets": targets}),
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		b, ok := files[strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v2/"+gun+"/_trust/tuf/"), ".json")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(b)
+	}))
+}
+
+func TestMigrateDCTCmd(t *testing.T) {
+	keyring.MockInit()
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	repo := strings.TrimPrefix(s.URL, "http://") + "/app"
+
+	ref, err := name.ParseReference(repo + ":v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// v0 was signed, but its image has since been deleted.
+	notary := notaryServer(t, repo, map[string]string{"v1": digest.String(), "v0": "sha256:" + strings.Repeat("0", 64)})
+	defer notary.Close()
+
+	td, err := ioutil.TempDir("", "cosign-dct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	pass := func(bool) ([]byte, error) { return []byte("hunter2"), nil }
+	keys, err := cosign.GenerateKeyPair(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(td, "cosign.key")
+	if err := ioutil.WriteFile(keyPath, keys.PrivateBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	if err := MigrateDCTCmd(ctx, MigrateDCTOpts{KeyRef: keyPath, Server: notary.URL}, repo, pass, out); err != nil {
+		t.Fatal(err)
+	}
+	report := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		m := dctMigration{}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatal(err)
+		}
+		report[m.Tag] = m.Status
+	}
+	if report["v1"] != dctSigned || report["v0"] != dctMissing {
+		t.Errorf("report = %v, want v1 signed and v0 missing", report)
+	}
+
+	pub, err := cosign.ParsePublicKeyPem(keys.PublicBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified, err := cosign.Verify(ctx, ref.Context().Digest(digest.String()), cosign.CheckOpts{
+		Keys:              []cosign.PublicKey{pub},
+		ClaimVerification: true,
+		Annotations:       map[string]string{dctTagAnnotation: "v1", dctRoleAnnotation: "targets"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(verified) != 1 {
+		t.Errorf("got %d signatures, want 1", len(verified))
+	}
+}
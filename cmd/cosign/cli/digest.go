@@ -0,0 +1,107 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// DigestOpts enforces digest pinning, and records the digests image references resolved to.
+// A nil *DigestOpts allows tags and records nothing.
+type DigestOpts struct {
+	// Require refuses tag references, only image@sha256:... references are accepted.
+	Require bool
+	// OutputFile, if set, gets the digest reference of each image, one per line.
+	OutputFile string
+
+	mu       sync.Mutex
+	resolved []string
+}
+
+// addDigestFlags registers the digest pinning flags on fs.
+func addDigestFlags(fs *flag.FlagSet) *DigestOpts {
+	d := &DigestOpts{}
+	fs.BoolVar(&d.Require, "require-digest", false, "refuse tag references, images must be referenced by digest")
+	fs.StringVar(&d.OutputFile, "output-digest-file", "", "write the digest reference of each image to this path, one per line")
+	return d
+}
+
+// check returns an error for tag references if digests are required.
+func (d *DigestOpts) check(ref name.Reference) error {
+	if d == nil || !d.Require {
+		return nil
+	}
+	if _, ok := ref.(name.Digest); !ok {
+		return fmt.Errorf("%s is not a digest reference, use %s@sha256:... with -require-digest", ref, ref.Context())
+	}
+	return nil
+}
+
+// record notes the digest ref resolved to, reporting it if ref was a tag.
+func (d *DigestOpts) record(ref name.Reference, digest v1.Hash) {
+	pinned := ref.Context().Digest(digest.String())
+	if _, ok := ref.(name.Tag); ok {
+		log.Infof("Resolved %s to %s", ref, pinned)
+	}
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.resolved = append(d.resolved, pinned.String())
+}
+
+// resolve returns the digest reference ref points to, recording it. Verifying the digest rather
+// than the tag means the tag can't move to another image halfway through.
+func (d *DigestOpts) resolve(ref name.Reference, opts []remote.Option) (name.Reference, error) {
+	if dr, ok := ref.(name.Digest); ok {
+		digest, err := v1.NewHash(dr.DigestStr())
+		if err != nil {
+			return nil, err
+		}
+		d.record(ref, digest)
+		return ref, nil
+	}
+	get, err := remote.Get(ref, opts...)
+	if err != nil {
+		return nil, err
+	}
+	d.record(ref, get.Digest)
+	return ref.Context().Digest(get.Digest.String()), nil
+}
+
+// write writes the recorded digest references to OutputFile, if it is set.
+func (d *DigestOpts) write() error {
+	if d == nil || d.OutputFile == "" {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := strings.Join(d.resolved, "\n")
+	if out != "" {
+		out += "\n"
+	}
+	return ioutil.WriteFile(d.OutputFile, []byte(out), 0600)
+}
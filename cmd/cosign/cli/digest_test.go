@@ -0,0 +1,88 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestDigestOpts(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	tag, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/demo:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned := tag.Context().Digest(digest.String())
+
+	td, err := ioutil.TempDir("", "cosign-digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	d := &DigestOpts{Require: true, OutputFile: filepath.Join(td, "digests.txt")}
+
+	if err := d.check(tag); err == nil {
+		t.Error("expected error for a tag reference with -require-digest")
+	}
+	if err := d.check(pinned); err != nil {
+		t.Errorf("check(%s) = %v", pinned, err)
+	}
+	var nilOpts *DigestOpts
+	if err := nilOpts.check(tag); err != nil {
+		t.Errorf("check() without options = %v", err)
+	}
+
+	for _, ref := range []name.Reference{tag, pinned} {
+		got, err := d.resolve(ref, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != pinned.String() {
+			t.Errorf("resolve(%s) = %s, want %s", ref, got, pinned)
+		}
+	}
+	if err := d.write(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(d.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := pinned.String() + "\n" + pinned.String() + "\n"; string(b) != want {
+		t.Errorf("digest file = %q, want %q", b, want)
+	}
+}
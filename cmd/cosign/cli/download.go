@@ -16,49 +16,197 @@ package cli
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
 
-	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/peterbourgon/ff/v3/ffcli"
 	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/fulcio"
 )
 
 func Download() *ffcli.Command {
 	var (
-		flagset = flag.NewFlagSet("cosign download", flag.ExitOnError)
+		flagset  = flag.NewFlagSet("cosign download", flag.ExitOnError)
+		registry = addRegistryFlags(flagset)
 	)
+	registry.addMirrorFlag(flagset)
 	return &ffcli.Command{
-		Name:       "download",
-		ShortUsage: "cosign download <image uri>",
-		ShortHelp:  "Download signatures from the supplied container image",
+		Name:        "download",
+		ShortUsage:  "cosign download [signature|attestation] <image uri>",
+		ShortHelp:   "Download signatures or attestations from the supplied container image",
+		FlagSet:     flagset,
+		Subcommands: []*ffcli.Command{downloadSignature(), downloadAttestation()},
+		// Without a subcommand, signatures are downloaded as they always have been.
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return flag.ErrHelp
+			}
+			return DownloadCmd(ctx, args[0], *registry)
+		},
+	}
+}
+
+func downloadSignature() *ffcli.Command {
+	var (
+		flagset  = flag.NewFlagSet("cosign download signature", flag.ExitOnError)
+		registry = addRegistryFlags(flagset)
+	)
+	registry.addMirrorFlag(flagset)
+	return &ffcli.Command{
+		Name:       "signature",
+		ShortUsage: "cosign download signature <image uri>",
+		ShortHelp:  "Download the signatures of the supplied container image, one JSON object per line",
+		FlagSet:    flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return flag.ErrHelp
+			}
+			return DownloadCmd(ctx, args[0], *registry)
+		},
+	}
+}
+
+func downloadAttestation() *ffcli.Command {
+	var (
+		flagset       = flag.NewFlagSet("cosign download attestation", flag.ExitOnError)
+		registry      = addRegistryFlags(flagset)
+		predicateType = flagset.String("predicate-type", "", "only download attestations with this predicate type, a URI or one of "+predicateTypeNames())
+		progress      = addProgressFlag(flagset)
+	)
+	registry.addMirrorFlag(flagset)
+	return &ffcli.Command{
+		Name:       "attestation",
+		ShortUsage: "cosign download attestation [-predicate-type <type>] [-progress] <image uri>",
+		ShortHelp:  "Download the attestations of the supplied container image, one DSSE envelope per line",
 		FlagSet:    flagset,
 		Exec: func(ctx context.Context, args []string) error {
 			if len(args) != 1 {
 				return flag.ErrHelp
 			}
-			return DownloadCmd(ctx, args[0])
+			return DownloadAttestationCmd(withProgress(ctx, *progress), args[0], *predicateType, *registry)
 		},
 	}
 }
 
-func DownloadCmd(ctx context.Context, imageRef string) error {
-	ref, err := name.ParseReference(imageRef)
+// downloadedSignature is how a signature is printed. Certificates are PEM encoded.
+type downloadedSignature struct {
+	Base64Signature string
+	Payload         []byte
+	Cert            string      `json:",omitempty"`
+	Chain           string      `json:",omitempty"`
+	KeyID           string      `json:",omitempty"`
+	Algorithm       string      `json:",omitempty"`
+	SCT             *fulcio.SCT `json:",omitempty"`
+	// HardwareAttestation is PEM encoded.
+	HardwareAttestation string `json:",omitempty"`
+}
+
+// downloadedAttestation is how an attestation is printed: the DSSE envelope, with the
+// PEM encoded certificates it was signed with.
+type downloadedAttestation struct {
+	cosign.Envelope
+	Cert  string `json:"cert,omitempty"`
+	Chain string `json:"chain,omitempty"`
+}
+
+func DownloadCmd(ctx context.Context, imageRef string, ro RegistryOpts) error {
+	return downloadSignatures(ctx, imageRef, ro, os.Stdout)
+}
+
+// DownloadAttestationCmd prints the attestations of imageRef, only those with predicateType if it is set.
+func DownloadAttestationCmd(ctx context.Context, imageRef, predicateType string, ro RegistryOpts) error {
+	return downloadAttestations(ctx, imageRef, predicateType, ro, os.Stdout)
+}
+
+func downloadSignatures(ctx context.Context, imageRef string, ro RegistryOpts, w io.Writer) error {
+	ctx, err := ro.Context(ctx)
+	if err != nil {
+		return err
+	}
+	ref, err := ro.ParseReference(imageRef)
 	if err != nil {
 		return err
 	}
 
-	signatures, _, err := cosign.FetchSignatures(ctx, ref)
+	signatures, _, err := cosign.FetchSignatures(ctx, ref, ro.ClientOpts(ctx)...)
 	if err != nil {
 		return err
 	}
 	for _, sig := range signatures {
-		b, err := json.Marshal(sig)
-		if err != nil {
+		ds := downloadedSignature{
+			Base64Signature: sig.Base64Signature,
+			Payload:         sig.Payload,
+			KeyID:           sig.KeyID,
+			Algorithm:       sig.Algorithm,
+			SCT:             sig.SCT,
+		}
+		if sig.Cert != nil {
+			ds.Cert = string(cosign.CertToPem(sig.Cert))
+		}
+		ds.Chain = pemChain(sig.Chain)
+		ds.HardwareAttestation = pemChain(sig.HardwareAttestation)
+		if err := printJSON(w, ds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func downloadAttestations(ctx context.Context, imageRef, predicateType string, ro RegistryOpts, w io.Writer) error {
+	ctx, err := ro.Context(ctx)
+	if err != nil {
+		return err
+	}
+	ref, err := ro.ParseReference(imageRef)
+	if err != nil {
+		return err
+	}
+
+	atts, _, err := cosign.FetchAttestations(ctx, ref, ro.ClientOpts(ctx)...)
+	if err != nil {
+		return err
+	}
+	for _, att := range cosign.FilterAttestations(atts, predicateType) {
+		da := downloadedAttestation{Envelope: att.Envelope, Chain: pemChain(att.Chain)}
+		if att.Cert != nil {
+			da.Cert = string(cosign.CertToPem(att.Cert))
+		}
+		if err := printJSON(w, da); err != nil {
 			return err
 		}
-		fmt.Println(string(b))
 	}
 	return nil
 }
+
+// predicateTypeNames lists the short predicate type names for flag help.
+func predicateTypeNames() string {
+	names := []string{}
+	for n := range cosign.PredicateTypes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return "[" + strings.Join(names, ", ") + "]"
+}
+
+func pemChain(chain []*x509.Certificate) string {
+	pems := []string{}
+	for _, c := range chain {
+		pems = append(pems, string(cosign.CertToPem(c)))
+	}
+	return strings.Join(pems, "")
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+func TestDownload(t *testing.T) {
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/download:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := downloadAttestations(ctx, ref.String(), "", RegistryOpts{}, buf); !errors.Is(err, cosign.ErrNoAttestations) {
+		t.Fatalf("downloadAttestations() = %v, want ErrNoAttestations", err)
+	}
+
+	desc, err := remote.Get(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigRef, err := cosign.DestinationRef(ref, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cosign.Upload(ctx, []byte("sig"), []byte(`{"payload":1}`), sigRef, cosign.SignatureMetadata{KeyID: "key"}); err != nil {
+		t.Fatal(err)
+	}
+	attRef, err := cosign.AttestationRef(ref, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &cosign.Envelope{
+		PayloadType: "application/vnd.in-toto+json",
+		Payload:     "e30=",
+		Signatures:  []cosign.EnvelopeSignature{{Sig: "c2ln"}},
+	}
+	if err := cosign.UploadAttestation(ctx, env, attRef, cosign.SignatureMetadata{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := downloadSignatures(ctx, ref.String(), RegistryOpts{}, buf); err != nil {
+		t.Fatal(err)
+	}
+	ds := downloadedSignature{}
+	if err := json.Unmarshal(buf.Bytes(), &ds); err != nil {
+		t.Fatal(err)
+	}
+	if ds.Base64Signature != "c2ln" || string(ds.Payload) != `{"payload":1}` || ds.KeyID != "key" {
+		t.Errorf("downloaded signature = %+v", ds)
+	}
+
+	buf.Reset()
+	if err := downloadAttestations(ctx, ref.String(), "", RegistryOpts{}, buf); err != nil {
+		t.Fatal(err)
+	}
+	da := downloadedAttestation{}
+	if err := json.Unmarshal(buf.Bytes(), &da); err != nil {
+		t.Fatal(err)
+	}
+	if da.PayloadType != env.PayloadType || da.Payload != env.Payload || len(da.Signatures) != 1 {
+		t.Errorf("downloaded attestation = %+v", da)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// EncryptBlobCmd encrypts the blob to the age recipients, writing it to outPath, or the blob's path with
+// an .age extension by default. It returns the path of the encrypted blob, which is then signed in place
+// of the blob, so the signature, and its transparency log entry, don't reveal anything about the plaintext.
+func EncryptBlobCmd(recipients []string, blobRef, outPath string) (string, error) {
+	rs, err := cosign.ParseAgeRecipients(recipients)
+	if err != nil {
+		return "", err
+	}
+	if outPath == "" {
+		if blobRef == "-" {
+			return "", errors.New("-encrypted-output is required to encrypt stdin")
+		}
+		outPath = blobRef + ".age"
+	}
+	r, closer, err := blobReader(blobRef)
+	if err != nil {
+		return "", err
+	}
+	defer closer()
+	f, err := os.OpenFile(filepath.Clean(outPath), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	if err := cosign.EncryptBlob(f, r, rs); err != nil {
+		f.Close()
+		return "", errors.Wrap(err, "encrypting blob")
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	log.Infof("Encrypted blob written to %s", outPath)
+	return outPath, nil
+}
+
+// DecryptBlobCmd decrypts the age encrypted blob with the identities in identityPath, writing the plaintext
+// to outPath, or stdout if it is "-" or empty. It is run once the signature over the encrypted blob is verified.
+func DecryptBlobCmd(identityPath, blobRef, outPath string) error {
+	ids, err := cosign.LoadAgeIdentities(identityPath)
+	if err != nil {
+		return err
+	}
+	r, closer, err := blobReader(blobRef)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	if outPath == "" || outPath == "-" {
+		return cosign.DecryptBlob(os.Stdout, r, ids)
+	}
+	f, err := os.OpenFile(filepath.Clean(outPath), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	err = cosign.DecryptBlob(f, r, ids)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// Don't leave a partial plaintext behind.
+		os.Remove(outPath)
+		return err
+	}
+	log.Infof("Decrypted blob written to %s", outPath)
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+func TestEncryptBlobCmd(t *testing.T) {
+	ctx := context.Background()
+	td, err := ioutil.TempDir("", "cosign-encrypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idPath := filepath.Join(td, "key.txt")
+	if err := ioutil.WriteFile(idPath, []byte(id.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	blob := filepath.Join(td, "release.tar")
+	if err := ioutil.WriteFile(blob, []byte("confidential"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := EncryptBlobCmd([]string{id.Recipient().String()}, blob, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encrypted != blob+".age" {
+		t.Errorf("EncryptBlobCmd() = %s, want %s.age", encrypted, blob)
+	}
+
+	// The encrypted blob is what gets signed and verified.
+	keys, err := cosign.GenerateKeyPair(func(bool) ([]byte, error) { return []byte("hunter2"), nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, pub := filepath.Join(td, "cosign.key"), filepath.Join(td, "cosign.pub")
+	if err := ioutil.WriteFile(priv, keys.PrivateBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(pub, keys.PublicBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := SignBlobCmd(ctx, priv, "", encrypted, SignBlobOpts{Base64: true}, func(bool) ([]byte, error) { return []byte("hunter2"), nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyBlobCmd(ctx, pub, "", "", string(sig), encrypted); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(td, "decrypted.tar")
+	if err := DecryptBlobCmd(idPath, encrypted, out); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "confidential" {
+		t.Errorf("decrypted blob = %q, want %q", got, "confidential")
+	}
+
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(idPath, []byte(other.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptBlobCmd(idPath, encrypted, filepath.Join(td, "other.tar")); err == nil {
+		t.Error("expected error decrypting with an identity that isn't a recipient")
+	}
+	if _, err := os.Stat(filepath.Join(td, "other.tar")); !os.IsNotExist(err) {
+		t.Error("failed decryption left a file behind")
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/fulcio"
+)
+
+func Env() *ffcli.Command {
+	var (
+		flagset = flag.NewFlagSet("cosign env", flag.ExitOnError)
+		outJSON = flagset.Bool("json", false, "print JSON instead of text")
+	)
+	return &ffcli.Command{
+		Name:       "env",
+		ShortUsage: "cosign env [-json]",
+		ShortHelp:  "Prints the effective configuration, and where each setting comes from",
+		LongHelp: `Prints the effective configuration, and where each setting comes from.
+
+Each environment variable cosign reads is listed with the value in effect and its source: the
+environment, the config file, or cosign's default. Secrets are only shown as set or not.`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			return EnvCmd(os.Stdout, *outJSON)
+		},
+	}
+}
+
+// EnvVar is a setting cosign reads from the environment.
+type EnvVar struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// Sources of EnvVar values.
+const (
+	sourceEnv     = "env"
+	sourceConfig  = "config"
+	sourceDefault = "default"
+)
+
+// secretEnv are the variables whose values aren't printed.
+var secretEnv = map[string]bool{
+	"COSIGN_PASSWORD":          true,
+	"COSIGN_REGISTRY_PASSWORD": true,
+	"COSIGN_REGISTRY_TOKEN":    true,
+	cosign.TlogTokenEnv:        true,
+	cosign.TlogHeadersEnv:      true,
+}
+
+// EffectiveEnv returns the settings in effect. Values the config file set are reported as coming from it.
+func EffectiveEnv() ([]EnvVar, error) {
+	cfg, err := cosign.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	fromConfig := cfg.Env()
+	configPath, err := cosign.ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	ts, err := cosign.NewTrustStore()
+	if err != nil {
+		return nil, err
+	}
+	cache, err := cosign.NewCache(0)
+	if err != nil {
+		return nil, err
+	}
+	defaults := []struct {
+		name, value string
+	}{
+		{cosign.ConfigEnv, configPath},
+		{cosign.ServerEnv, cosign.TlogServer()},
+		{cosign.TlogTokenEnv, ""},
+		{cosign.TlogHeadersEnv, ""},
+		{"FULCIO_ADDRESS", fulcio.Server()},
+		{"FULCIO_CT_LOG_ADDRESS", fulcio.CTLogServer()},
+		{cosign.ExperimentalEnv, strconv.FormatBool(cosign.Experimental())},
+		{"COSIGN_REPOSITORY", ""},
+		{"COSIGN_OUTPUT", envOr("COSIGN_OUTPUT", "json")},
+		{"COSIGN_PASSWORD", ""},
+		{"COSIGN_REGISTRY_USERNAME", ""},
+		{"COSIGN_REGISTRY_PASSWORD", ""},
+		{"COSIGN_REGISTRY_TOKEN", ""},
+		{"COSIGN_INSECURE_REGISTRIES", ""},
+		{cosign.MirrorsEnv, ""},
+		{referenceEquivalentsEnv, ""},
+		{"COSIGN_CA_BUNDLE", ""},
+		{"COSIGN_CLIENT_CERT", ""},
+		{"COSIGN_CLIENT_KEY", ""},
+		{cosign.TrustDirEnv, ts.Dir},
+		{cosign.CacheDirEnv, cache.Dir},
+		{"DOCKER_CONFIG", ""},
+	}
+	vars := []EnvVar{}
+	for _, d := range defaults {
+		ev := EnvVar{Name: d.name, Value: d.value, Source: sourceDefault}
+		if v, ok := os.LookupEnv(d.name); ok {
+			ev.Value, ev.Source = v, sourceEnv
+			if fromConfig[d.name] == v {
+				ev.Source = sourceConfig
+			}
+		}
+		if secretEnv[d.name] && ev.Value != "" {
+			ev.Value = "<set>"
+		}
+		vars = append(vars, ev)
+	}
+	return vars, nil
+}
+
+// EnvCmd prints the effective settings to w.
+func EnvCmd(w io.Writer, outJSON bool) error {
+	vars, err := EffectiveEnv()
+	if err != nil {
+		return err
+	}
+	if outJSON {
+		return printJSON(w, vars)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tVALUE\tSOURCE")
+	for _, v := range vars {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", v.Name, v.Value, v.Source)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/fulcio"
+)
+
+func TestEffectiveEnv(t *testing.T) {
+	td, err := ioutil.TempDir("", "cosign-env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	p := filepath.Join(td, "config.yaml")
+	if err := ioutil.WriteFile(p, []byte("rekor-url: https://rekor.example.com\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{cosign.ConfigEnv, cosign.ServerEnv, "FULCIO_ADDRESS", "COSIGN_REGISTRY_TOKEN"} {
+		if v, ok := os.LookupEnv(k); ok {
+			defer os.Setenv(k, v)
+		} else {
+			defer os.Unsetenv(k)
+		}
+		os.Unsetenv(k)
+	}
+	os.Setenv(cosign.ConfigEnv, p)
+	os.Setenv(cosign.ServerEnv, "https://rekor.example.com")
+	os.Setenv("COSIGN_REGISTRY_TOKEN", "hunter2")
+
+	vars, err := EffectiveEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]EnvVar{}
+	for _, v := range vars {
+		got[v.Name] = v
+	}
+	for _, want := range []EnvVar{
+		{Name: cosign.ConfigEnv, Value: p, Source: sourceEnv},
+		{Name: cosign.ServerEnv, Value: "https://rekor.example.com", Source: sourceConfig},
+		{Name: "FULCIO_ADDRESS", Value: fulcio.Server(), Source: sourceDefault},
+		{Name: "COSIGN_REGISTRY_TOKEN", Value: "<set>", Source: sourceEnv},
+	} {
+		if got[want.Name] != want {
+			t.Errorf("EffectiveEnv() %s = %+v, want %+v", want.Name, got[want.Name], want)
+		}
+	}
+}
@@ -21,8 +21,6 @@ import (
 	"io"
 	"os"
 
-	"github.com/google/go-containerregistry/pkg/authn"
-	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/peterbourgon/ff/v3/ffcli"
 	"github.com/sigstore/cosign/pkg/cosign"
@@ -32,6 +30,7 @@ func Generate() *ffcli.Command {
 	var (
 		flagset     = flag.NewFlagSet("cosign generate", flag.ExitOnError)
 		annotations = annotationsMap{}
+		registry    = addRegistryFlags(flagset)
 	)
 	flagset.Var(&annotations, "a", "extra key=value pairs to sign")
 
@@ -57,23 +56,23 @@ EXAMPLES
 			if len(args) != 1 {
 				return flag.ErrHelp
 			}
-			return GenerateCmd(ctx, args[0], annotations.annotations, os.Stdout)
+			return GenerateCmd(ctx, args[0], annotations.annotations, os.Stdout, *registry)
 		},
 	}
 }
 
-func GenerateCmd(_ context.Context, imageRef string, annotations map[string]string, w io.Writer) error {
-	ref, err := name.ParseReference(imageRef)
+func GenerateCmd(ctx context.Context, imageRef string, annotations map[string]string, w io.Writer, ro RegistryOpts) error {
+	ref, err := ro.ParseReference(imageRef)
 	if err != nil {
 		return err
 	}
 
-	get, err := remote.Get(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	get, err := remote.Get(ref, ro.ClientOpts(ctx)...)
 	if err != nil {
 		return err
 	}
 
-	payload, err := (&cosign.ImagePayload{Img: get.Descriptor, Annotations: annotations}).MarshalJSON()
+	payload, err := (&cosign.ImagePayload{Img: get.Descriptor, Annotations: annotations, Reference: ref.Context().Name()}).MarshalJSON()
 	if err != nil {
 		return err
 	}
@@ -20,10 +20,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/sigstore/cosign/pkg/cosign"
 	"github.com/sigstore/cosign/pkg/cosign/kms"
+	"github.com/sigstore/cosign/pkg/cosign/log"
 
 	"github.com/peterbourgon/ff/v3/ffcli"
 	"golang.org/x/term"
@@ -38,11 +40,13 @@ func GenerateKeyPair() *ffcli.Command {
 	var (
 		flagset = flag.NewFlagSet("cosign generate-key-pair", flag.ExitOnError)
 		kmsVal  = flagset.String("kms", "", "create key pair in KMS service to use for signing")
+		format  = flagset.String("format", cosign.KeyFormatCosign, "encryption format for the private key, one of cosign|age")
+		alg     = flagset.String("algorithm", cosign.AlgorithmECDSAP256SHA256, "key algorithm, one of "+strings.Join(cosign.KeyAlgorithms, "|"))
 	)
 
 	return &ffcli.Command{
 		Name:       "generate-key-pair",
-		ShortUsage: "cosign generate-key-pair [-kms KMSPATH]",
+		ShortUsage: "cosign generate-key-pair [-kms KMSPATH] [-format cosign|age] [-algorithm <algorithm>]",
 		ShortHelp:  "generate-key-pair generates a key-pair",
 		LongHelp: `generate-key-pair generates a key-pair for signing.
 
@@ -50,21 +54,36 @@ EXAMPLES:
   # generate key-pair and write to cosign.key and cosign.pub files
   cosign generate-key-pair
 
+  # generate key-pair with the private key encrypted as an age file, so it can be decrypted with "age -d"
+  cosign generate-key-pair -format age
+
+  # generate an ECDSA P-384 key-pair, signing SHA-384 digests
+  cosign generate-key-pair -algorithm ecdsa-p384-sha384
+
   # generate a key-pair in Google Cloud KMS
   cosign generate-key-pair -kms gcpkms://projects/[PROJECT]/locations/global/keyRings/[KEYRING]/cryptoKeys/[KEY]
 
+  # generate a non-exportable key-pair in the local TPM 2.0, persisted at the given handle
+  cosign generate-key-pair -kms tpm://0x81000100
+
 CAVEATS:
   This command interactively prompts for a password. You can use
-  the COSIGN_PASSWORD environment variable to provide one.`,
+  the COSIGN_PASSWORD environment variable to provide one.
+
+  Existing ECDSA P-256 keys in the OpenSSH format, e.g. from "ssh-keygen -t ecdsa -b 256",
+  can also be used for signing without converting them.`,
 		FlagSet: flagset,
 		Exec: func(ctx context.Context, args []string) error {
-			return GenerateKeyPairCmd(ctx, *kmsVal)
+			return GenerateKeyPairCmd(ctx, *kmsVal, *format, *alg)
 		},
 	}
 }
 
-func GenerateKeyPairCmd(ctx context.Context, kmsVal string) error {
+func GenerateKeyPairCmd(ctx context.Context, kmsVal, format, algorithm string) error {
 	if kmsVal != "" {
+		if algorithm != cosign.AlgorithmECDSAP256SHA256 {
+			return errors.New("-algorithm is not supported with -kms, KMS keys are ECDSA P-256")
+		}
 		k, err := kms.Get(ctx, kmsVal)
 		if err != nil {
 			return err
@@ -80,11 +99,11 @@ func GenerateKeyPairCmd(ctx context.Context, kmsVal string) error {
 		if err := ioutil.WriteFile("cosign.pub", pemBytes, 0600); err != nil {
 			return err
 		}
-		fmt.Fprintln(os.Stderr, "Public key written to cosign.pub")
+		log.Infof("Public key written to cosign.pub")
 		return nil
 	}
 
-	keys, err := cosign.GenerateKeyPair(GetPass)
+	keys, err := cosign.GenerateKeyPairWithAlgorithm(GetPass, format, algorithm)
 	if err != nil {
 		return err
 	}
@@ -92,12 +111,12 @@ func GenerateKeyPairCmd(ctx context.Context, kmsVal string) error {
 	if err := ioutil.WriteFile("cosign.key", keys.PrivateBytes, 0600); err != nil {
 		return err
 	}
-	fmt.Fprintln(os.Stderr, "Private key written to cosign.key")
+	log.Infof("Private key written to cosign.key")
 
 	if err := ioutil.WriteFile("cosign.pub", keys.PublicBytes, 0600); err != nil {
 		return err
 	}
-	fmt.Fprintln(os.Stderr, "Public key written to cosign.pub")
+	log.Infof("Public key written to cosign.pub")
 	return nil
 }
 
@@ -0,0 +1,255 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// defaultGitNotesRef is where sign-git stores the bundles for signed commits and tags.
+const defaultGitNotesRef = "refs/notes/cosign"
+
+// GitOpts holds the settings for SignGitCmd and VerifyGitCmd.
+type GitOpts struct {
+	KeyRef string
+	KmsVal string
+	// CertRef is a certificate to verify against, instead of the one stored with the signature.
+	CertRef string
+	// NotesRef is the git notes ref the signature bundles are stored under.
+	NotesRef string
+	// Dir is the repository to run git in, the working directory if empty.
+	Dir string
+	// RekorURL is the address of the transparency log, see cosign.TlogServer for the default.
+	RekorURL string
+}
+
+func SignGit() *ffcli.Command {
+	var (
+		flagset  = flag.NewFlagSet("cosign sign-git", flag.ExitOnError)
+		key      = flagset.String("key", "", "path to the private key")
+		kmsVal   = flagset.String("kms", "", "sign via a private key stored in a KMS")
+		notesRef = flagset.String("notes-ref", defaultGitNotesRef, "git notes ref to store the signature bundle under")
+		rekorURL string
+	)
+	addRekorURLFlag(flagset, &rekorURL)
+	return &ffcli.Command{
+		Name:       "sign-git",
+		ShortUsage: "cosign sign-git -key <key path>|<kms uri> [-notes-ref <ref>] [<commit or tag>]",
+		ShortHelp:  "Sign a git commit or tag",
+		LongHelp: `Sign a git commit or annotated tag, HEAD by default.
+
+The raw git object is signed, and a bundle of the signature, certificate and transparency log
+entry is stored as a git note on it. Push the notes to share the signatures:
+
+  git push origin refs/notes/cosign
+
+EXAMPLES
+  # sign the current commit with Google sign-in (experimental)
+  COSIGN_EXPERIMENTAL=1 cosign sign-git
+
+  # sign a release tag with a key
+  cosign sign-git -key cosign.key v1.0.0`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if !cosign.Experimental() && *key == "" && *kmsVal == "" {
+				return &KeyParseError{}
+			}
+			if len(args) > 1 {
+				return flag.ErrHelp
+			}
+			rev := "HEAD"
+			if len(args) == 1 {
+				rev = args[0]
+			}
+			return SignGitCmd(ctx, GitOpts{KeyRef: *key, KmsVal: *kmsVal, NotesRef: *notesRef, RekorURL: rekorURL}, rev, GetPass)
+		},
+	}
+}
+
+func VerifyGit() *ffcli.Command {
+	var (
+		flagset  = flag.NewFlagSet("cosign verify-git", flag.ExitOnError)
+		key      = flagset.String("key", "", "path to the public key")
+		kmsVal   = flagset.String("kms", "", "verify via a public key stored in a KMS")
+		cert     = flagset.String("cert", "", "path to the public certificate, instead of the one stored with the signature")
+		notesRef = flagset.String("notes-ref", defaultGitNotesRef, "git notes ref the signature bundle is stored under")
+		rekorURL string
+	)
+	addRekorURLFlag(flagset, &rekorURL)
+	return &ffcli.Command{
+		Name:       "verify-git",
+		ShortUsage: "cosign verify-git [-key <key path>|<kms uri>|-cert <cert>] [-notes-ref <ref>] [<commit or tag>]",
+		ShortHelp:  "Verify the signature on a git commit or tag",
+		LongHelp: `Verify the signature on a git commit or annotated tag, HEAD by default.
+
+The bundle stored by "cosign sign-git" is read from the git notes. Without a key, the certificate
+in the bundle is verified against the Fulcio roots and its identity is printed. Fetch the notes
+first if the commit was signed elsewhere:
+
+  git fetch origin refs/notes/cosign:refs/notes/cosign
+
+EXAMPLES
+  # verify the current commit, and the transparency log entry (experimental)
+  COSIGN_EXPERIMENTAL=1 cosign verify-git
+
+  # verify a release tag with a key
+  cosign verify-git -key cosign.pub v1.0.0`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) > 1 {
+				return flag.ErrHelp
+			}
+			rev := "HEAD"
+			if len(args) == 1 {
+				rev = args[0]
+			}
+			return VerifyGitCmd(ctx, GitOpts{KeyRef: *key, KmsVal: *kmsVal, CertRef: *cert, NotesRef: *notesRef, RekorURL: rekorURL}, rev)
+		},
+	}
+}
+
+// SignGitCmd signs the commit or tag object rev resolves to, and stores the signature bundle in a git note on it.
+func SignGitCmd(ctx context.Context, o GitOpts, rev string, pf cosign.PassFunc) error {
+	if o.KeyRef != "" && o.KmsVal != "" {
+		return &KeyParseError{}
+	}
+	oid, obj, err := gitObject(ctx, o.Dir, rev)
+	if err != nil {
+		return err
+	}
+	is, err := newImageSigner(ctx, SignOpts{KeyRef: o.KeyRef, KmsVal: o.KmsVal}, pf)
+	if err != nil {
+		return err
+	}
+	signer, ok := is.signer.(cosign.DigestSigner)
+	if !ok {
+		return errors.New("this key can't sign git objects")
+	}
+	signature, digest, err := cosign.SignBlob(ctx, signer, bytes.NewReader(obj))
+	if err != nil {
+		return errors.Wrap(err, "signing git object")
+	}
+	bundle := cosign.NewBlobBundle(signature, digest, is.pemBytes)
+	bundle.VerificationMaterial.Chain = is.chain
+	if cosign.Experimental() {
+		entry, err := cosign.UploadTLog(ctx, o.RekorURL, signature, obj, is.pemBytes)
+		if err != nil {
+			return err
+		}
+		log.Infof("%s", entry)
+		bundle.VerificationMaterial.TlogEntry = &cosign.TlogInfo{LogIndex: entry.Index(), LogURL: entry.LogURL}
+	}
+	b, err := json.Marshal(bundle)
+	if err != nil {
+		return err
+	}
+	if _, err := git(ctx, o.Dir, bytes.NewReader(b), "notes", "--ref", notesRef(o), "add", "-f", "-F", "-", oid); err != nil {
+		return errors.Wrap(err, "storing signature note")
+	}
+	log.Infof("Signature for %s stored in %s", oid, notesRef(o))
+	return nil
+}
+
+// VerifyGitCmd verifies the signature stored in a git note on the commit or tag object rev resolves to.
+func VerifyGitCmd(ctx context.Context, o GitOpts, rev string) error {
+	if o.KeyRef != "" && o.KmsVal != "" {
+		return &KeyParseError{}
+	}
+	if o.RekorURL != "" {
+		ctx = withTlog(ctx, checkTlog(ctx), o.RekorURL)
+	}
+	oid, obj, err := gitObject(ctx, o.Dir, rev)
+	if err != nil {
+		return err
+	}
+	note, err := git(ctx, o.Dir, nil, "notes", "--ref", notesRef(o), "show", oid)
+	if err != nil {
+		return errors.Wrapf(err, "no signature found for %s in %s", oid, notesRef(o))
+	}
+	bundle, err := cosign.ParseBundle(note)
+	if err != nil {
+		return errors.Wrap(err, "parsing signature note")
+	}
+	pubKey, cert, err := bundleVerifier(ctx, o.KeyRef, o.KmsVal, o.CertRef, bundle)
+	if err != nil {
+		return err
+	}
+	if err := verifyBundleMessage(ctx, pubKey, cert, bundle.MessageSignature, bytes.NewReader(obj)); err != nil {
+		return err
+	}
+	if cert != nil {
+		fmt.Fprintf(os.Stderr, "%s signed by %s\n", oid, strings.Join(cert.EmailAddresses, ", "))
+	}
+	return nil
+}
+
+func notesRef(o GitOpts) string {
+	if o.NotesRef == "" {
+		return defaultGitNotesRef
+	}
+	return o.NotesRef
+}
+
+// gitObject resolves rev to a commit or tag object, and returns its id and raw content.
+// Lightweight tags resolve to the commit they point at.
+func gitObject(ctx context.Context, dir, rev string) (string, []byte, error) {
+	if strings.HasPrefix(rev, "-") {
+		return "", nil, fmt.Errorf("invalid revision %q", rev)
+	}
+	out, err := git(ctx, dir, nil, "rev-parse", "--verify", rev+"^{object}")
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "resolving %s", rev)
+	}
+	oid := strings.TrimSpace(string(out))
+	typ, err := git(ctx, dir, nil, "cat-file", "-t", oid)
+	if err != nil {
+		return "", nil, err
+	}
+	switch t := strings.TrimSpace(string(typ)); t {
+	case "commit", "tag":
+		obj, err := git(ctx, dir, nil, "cat-file", t, oid)
+		return oid, obj, err
+	default:
+		return "", nil, fmt.Errorf("%s is a %s, only commits and tags can be signed", rev, t)
+	}
+}
+
+// git runs a git command in dir and returns its output.
+func git(ctx context.Context, dir string, stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stdin = stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
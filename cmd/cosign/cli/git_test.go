@@ -0,0 +1,92 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+func TestSignVerifyGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	ctx := context.Background()
+	td, err := ioutil.TempDir("", "cosign-git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	repo := filepath.Join(td, "repo")
+	if err := os.Mkdir(repo, 0755); err != nil {
+		t.Fatal(err)
+	}
+	run := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		if _, err := git(ctx, repo, nil, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "-q")
+	run("commit", "-q", "--allow-empty", "-m", "first")
+	run("tag", "-a", "-m", "release", "v1.0.0")
+
+	pass := func(bool) ([]byte, error) { return []byte("hunter2"), nil }
+	keys, err := cosign.GenerateKeyPair(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(td, "cosign.key")
+	if err := ioutil.WriteFile(keyPath, keys.PrivateBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	pubPath := filepath.Join(td, "cosign.pub")
+	if err := ioutil.WriteFile(pubPath, keys.PublicBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rev := range []string{"HEAD", "v1.0.0"} {
+		if err := SignGitCmd(ctx, GitOpts{KeyRef: keyPath, Dir: repo}, rev, pass); err != nil {
+			t.Fatalf("SignGitCmd(%s) = %v", rev, err)
+		}
+		if err := VerifyGitCmd(ctx, GitOpts{KeyRef: pubPath, Dir: repo}, rev); err != nil {
+			t.Errorf("VerifyGitCmd(%s) = %v", rev, err)
+		}
+	}
+
+	// A new commit isn't signed, and signatures don't verify with another key.
+	run("commit", "-q", "--allow-empty", "-m", "second")
+	if err := VerifyGitCmd(ctx, GitOpts{KeyRef: pubPath, Dir: repo}, "HEAD"); err == nil {
+		t.Error("expected error verifying an unsigned commit")
+	}
+	other, err := cosign.GenerateKeyPair(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPath := filepath.Join(td, "other.pub")
+	if err := ioutil.WriteFile(otherPath, other.PublicBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyGitCmd(ctx, GitOpts{KeyRef: otherPath, Dir: repo}, "v1.0.0"); err == nil {
+		t.Error("expected error verifying with another key")
+	}
+}
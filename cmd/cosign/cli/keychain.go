@@ -0,0 +1,144 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+	"github.com/zalando/go-keyring"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// keychainService is the service name passphrases are stored under in the OS keychain.
+const keychainService = "cosign"
+
+func Keychain() *ffcli.Command {
+	var (
+		storeFlags  = flag.NewFlagSet("cosign keychain store", flag.ExitOnError)
+		storeKey    = storeFlags.String("key", "", "path to the private key whose passphrase to store")
+		deleteFlags = flag.NewFlagSet("cosign keychain delete", flag.ExitOnError)
+		deleteKey   = deleteFlags.String("key", "", "path to the private key whose passphrase to delete")
+	)
+	return &ffcli.Command{
+		Name:       "keychain",
+		ShortUsage: "cosign keychain store|delete -key <key path>",
+		ShortHelp:  "Manage private key passphrases in the OS keychain",
+		LongHelp: `Store private key passphrases in the macOS Keychain, Windows Credential Manager
+or the Secret Service on Linux, so they don't have to be typed in for every signature.
+
+Stored passphrases are used whenever the key is used with -key, unless COSIGN_PASSWORD is set.
+
+EXAMPLES
+  # store the passphrase for cosign.key, after checking that it decrypts the key
+  cosign keychain store -key cosign.key
+
+  # remove the passphrase for cosign.key from the keychain
+  cosign keychain delete -key cosign.key`,
+		Subcommands: []*ffcli.Command{
+			{
+				Name:       "store",
+				ShortUsage: "cosign keychain store -key <key path>",
+				ShortHelp:  "Store the passphrase of a private key in the OS keychain",
+				FlagSet:    storeFlags,
+				Exec: func(ctx context.Context, args []string) error {
+					if *storeKey == "" {
+						return flag.ErrHelp
+					}
+					return KeychainStoreCmd(ctx, *storeKey, GetPass)
+				},
+			},
+			{
+				Name:       "delete",
+				ShortUsage: "cosign keychain delete -key <key path>",
+				ShortHelp:  "Delete the passphrase of a private key from the OS keychain",
+				FlagSet:    deleteFlags,
+				Exec: func(ctx context.Context, args []string) error {
+					if *deleteKey == "" {
+						return flag.ErrHelp
+					}
+					return KeychainDeleteCmd(ctx, *deleteKey)
+				},
+			},
+		},
+		Exec: func(context.Context, []string) error {
+			return flag.ErrHelp
+		},
+	}
+}
+
+// KeychainStoreCmd stores the passphrase for the key at keyPath in the OS keychain.
+func KeychainStoreCmd(_ context.Context, keyPath string, pf cosign.PassFunc) error {
+	kb, err := ioutil.ReadFile(filepath.Clean(keyPath))
+	if err != nil {
+		return err
+	}
+	pass, err := pf(false)
+	if err != nil {
+		return err
+	}
+	// Don't store a passphrase that won't work.
+	if _, err := cosign.LoadPrivateKey(kb, pass); err != nil {
+		return errors.Wrap(err, "checking passphrase")
+	}
+	account, err := keychainAccount(keyPath)
+	if err != nil {
+		return err
+	}
+	if err := keyring.Set(keychainService, account, string(pass)); err != nil {
+		return errors.Wrap(err, "storing passphrase")
+	}
+	log.Infof("Passphrase stored in keychain for %s", account)
+	return nil
+}
+
+// KeychainDeleteCmd removes the passphrase for the key at keyPath from the OS keychain.
+func KeychainDeleteCmd(_ context.Context, keyPath string) error {
+	account, err := keychainAccount(keyPath)
+	if err != nil {
+		return err
+	}
+	if err := keyring.Delete(keychainService, account); err != nil {
+		return errors.Wrap(err, "deleting passphrase")
+	}
+	log.Infof("Passphrase deleted from keychain for %s", account)
+	return nil
+}
+
+// keyPass returns the passphrase for the key at keyPath. COSIGN_PASSWORD takes precedence,
+// then the OS keychain, and finally pf is asked.
+func keyPass(keyPath string, pf cosign.PassFunc) ([]byte, error) {
+	if _, ok := os.LookupEnv("COSIGN_PASSWORD"); !ok {
+		if account, err := keychainAccount(keyPath); err == nil {
+			// Any error here, including no keychain being available, just means we need to ask.
+			if pass, err := keyring.Get(keychainService, account); err == nil {
+				return []byte(pass), nil
+			}
+		}
+	}
+	return pf(false)
+}
+
+// keychainAccount identifies keys in the keychain by their absolute path.
+func keychainAccount(keyPath string) (string, error) {
+	return filepath.Abs(keyPath)
+}
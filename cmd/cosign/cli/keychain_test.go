@@ -0,0 +1,63 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+func TestKeychain(t *testing.T) {
+	keyring.MockInit()
+	ctx := context.Background()
+
+	keys, err := cosign.GenerateKeyPair(pass("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "cosign.key")
+	if err := ioutil.WriteFile(keyPath, keys.PrivateBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	failPass := func(bool) ([]byte, error) {
+		return nil, errors.New("should not be prompted")
+	}
+
+	// The wrong passphrase must not be stored.
+	if err := KeychainStoreCmd(ctx, keyPath, pass("wrong")); err == nil {
+		t.Error("expected error storing the wrong passphrase")
+	}
+	if err := KeychainStoreCmd(ctx, keyPath, pass("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadKey(ctx, keyPath, failPass); err != nil {
+		t.Errorf("loadKey() with stored passphrase = %v", err)
+	}
+
+	if err := KeychainDeleteCmd(ctx, keyPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadKey(ctx, keyPath, failPass); err == nil {
+		t.Error("expected to be prompted after deleting the passphrase")
+	}
+}
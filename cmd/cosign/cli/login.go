@@ -0,0 +1,149 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/types"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// dockerHubAuthKey is the key the docker config stores Docker Hub credentials under.
+const dockerHubAuthKey = "https://index.docker.io/v1/"
+
+func Login() *ffcli.Command {
+	var (
+		flagset       = flag.NewFlagSet("cosign login", flag.ExitOnError)
+		username      = flagset.String("u", "", "username")
+		password      = flagset.String("p", "", "password")
+		passwordStdin = flagset.Bool("password-stdin", false, "read the password from stdin")
+	)
+	return &ffcli.Command{
+		Name:       "login",
+		ShortUsage: "cosign login -u <username> [-p <password>|-password-stdin] <registry>",
+		ShortHelp:  "Log in to a registry",
+		LongHelp: `Log in to a registry, checking the credentials before storing them in the docker config.
+
+Credentials are stored the same way "docker login" stores them, in $DOCKER_CONFIG/config.json
+or the credential helper it configures, so docker doesn't need to be installed.
+
+EXAMPLES
+  # log in to a registry, prompting for the password
+  cosign login -u <USER> ghcr.io
+
+  # log in from CI without putting the password on the command line
+  echo $TOKEN | cosign login -u <USER> -password-stdin ghcr.io`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 || *username == "" {
+				return flag.ErrHelp
+			}
+			pass := *password
+			switch {
+			case *passwordStdin:
+				if pass != "" {
+					return errors.New("-p and -password-stdin are mutually exclusive")
+				}
+				b, err := ioutil.ReadAll(os.Stdin)
+				if err != nil {
+					return err
+				}
+				pass = strings.TrimRight(string(b), "\r\n")
+			case pass == "":
+				fmt.Fprint(os.Stderr, "Password: ")
+				b, err := term.ReadPassword(0)
+				fmt.Fprintln(os.Stderr)
+				if err != nil {
+					return err
+				}
+				pass = string(b)
+			}
+			return LoginCmd(ctx, args[0], *username, pass)
+		},
+	}
+}
+
+// LoginCmd checks the credentials against the registry and stores them in the docker config.
+func LoginCmd(ctx context.Context, registry, username, password string) error {
+	reg, err := name.NewRegistry(registry)
+	if err != nil {
+		return errors.Wrap(err, "parsing registry")
+	}
+	auth := &authn.Basic{Username: username, Password: password}
+	if err := checkLogin(ctx, reg, auth); err != nil {
+		return err
+	}
+
+	cf, err := config.Load(os.Getenv("DOCKER_CONFIG"))
+	if err != nil {
+		return errors.Wrap(err, "loading docker config")
+	}
+	serverAddress := reg.RegistryStr()
+	if serverAddress == name.DefaultRegistry {
+		serverAddress = dockerHubAuthKey
+	}
+	creds := cf.GetCredentialsStore(serverAddress)
+	if err := creds.Store(types.AuthConfig{
+		ServerAddress: serverAddress,
+		Username:      username,
+		Password:      password,
+	}); err != nil {
+		return errors.Wrap(err, "storing credentials")
+	}
+	if err := cf.Save(); err != nil {
+		return errors.Wrap(err, "saving docker config")
+	}
+	log.Infof("Logged in to %s", reg.RegistryStr())
+	return nil
+}
+
+// checkLogin makes an authenticated request to the registry API root, which any registry
+// requiring auth will reject with bad credentials.
+func checkLogin(ctx context.Context, reg name.Registry, auth authn.Authenticator) error {
+	rt, err := transport.New(reg, auth, http.DefaultTransport, []string{reg.Scope(transport.PullScope)})
+	if err != nil {
+		return errors.Wrapf(err, "logging in to %s", reg.RegistryStr())
+	}
+	u := url.URL{Scheme: reg.Scheme(), Host: reg.RegistryStr(), Path: "/v2/"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "logging in to %s", reg.RegistryStr())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("logging in to %s: %s", reg.RegistryStr(), resp.Status)
+	}
+	return nil
+}
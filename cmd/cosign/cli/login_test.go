@@ -0,0 +1,65 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestLoginCmd(t *testing.T) {
+	host, cleanup := newAuthRegistry(t)
+	defer cleanup()
+
+	td, err := ioutil.TempDir("", "cosign-login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	os.Setenv("DOCKER_CONFIG", td)
+	defer os.Unsetenv("DOCKER_CONFIG")
+
+	ctx := context.Background()
+	if err := LoginCmd(ctx, host, "user", "wrong"); err == nil {
+		t.Fatal("LoginCmd() with a bad password expected error")
+	}
+	if _, err := os.Stat(td + "/config.json"); !os.IsNotExist(err) {
+		t.Fatal("bad credentials should not be stored")
+	}
+	if err := LoginCmd(ctx, host, "user", "pass"); err != nil {
+		t.Fatalf("LoginCmd() = %v", err)
+	}
+
+	reg, err := name.NewRegistry(host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := authn.DefaultKeychain.Resolve(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := auth.Authorization()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Username != "user" || cfg.Password != "pass" {
+		t.Errorf("stored credentials = %s:%s, want user:pass", cfg.Username, cfg.Password)
+	}
+}
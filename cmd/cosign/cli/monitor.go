@@ -0,0 +1,274 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+	"github.com/sigstore/rekor/pkg/generated/client"
+	"github.com/sigstore/rekor/pkg/generated/client/entries"
+	"github.com/sigstore/rekor/pkg/generated/client/tlog"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+// MonitorCommand scans the transparency log for entries signed by an identity, so that
+// signatures made with a stolen key or a compromised account are noticed.
+type MonitorCommand struct {
+	// Emails are matched against the email addresses in Fulcio certificates.
+	Emails string
+	// Key is a public key, or KMS reference, to match entries signed by.
+	Key      string
+	Interval time.Duration
+	// Start is the log index to scan from. Negative starts at the end of the log, reporting only new entries.
+	Start   int64
+	Webhook string
+	// RekorURL is the address of the log to monitor, see cosign.TlogServer for the default.
+	RekorURL string
+
+	out io.Writer
+}
+
+// monitorEvent is printed as a JSON line, and posted to the webhook, for every entry matched.
+type monitorEvent struct {
+	Time           time.Time `json:"time"`
+	LogIndex       int64     `json:"logIndex"`
+	UUID           string    `json:"uuid"`
+	IntegratedTime int64     `json:"integratedTime"`
+	// Identity is the matched email address, or "key" when the public key matched.
+	Identity  string `json:"identity"`
+	Signature string `json:"signature,omitempty"`
+	DataHash  string `json:"dataHash,omitempty"`
+	Cert      string `json:"cert,omitempty"`
+}
+
+// identityMatcher decides whether a log entry was signed by one of the monitored identities.
+type identityMatcher struct {
+	emails []string
+	key    []byte // PKIX DER
+}
+
+// Monitor builds and returns an ffcli command
+func Monitor() *ffcli.Command {
+	cmd := MonitorCommand{out: os.Stdout}
+	flagset := flag.NewFlagSet("cosign monitor", flag.ExitOnError)
+	flagset.StringVar(&cmd.Emails, "email", "", "comma separated email addresses to report entries with Fulcio certificates for")
+	flagset.StringVar(&cmd.Key, "key", "", "path to a public key, or KMS reference, to report entries signed by")
+	flagset.DurationVar(&cmd.Interval, "interval", time.Minute, "how often to check the log for new entries")
+	flagset.Int64Var(&cmd.Start, "start", -1, "log index to start scanning from, by default only entries added after starting are scanned")
+	flagset.StringVar(&cmd.Webhook, "webhook", "", "URL to POST each matched entry to as JSON, in addition to printing it")
+	addRekorURLFlag(flagset, &cmd.RekorURL)
+
+	return &ffcli.Command{
+		Name:       "monitor",
+		ShortUsage: "cosign monitor [-email <email>] [-key <key path>|<kms uri>] [-webhook <url>]",
+		ShortHelp:  "Monitor the transparency log for signatures made by your identity",
+		LongHelp: `Monitor the transparency log for signatures made by your identity.
+
+Every entry added to the log is checked, and entries whose Fulcio certificate is for one of the -email
+addresses, or that are signed by -key, are printed as JSON lines. Any you didn't make mean your key or
+account has been used by someone else.
+
+The log is set with -rekor-url or REKOR_SERVER, https://api.rekor.dev by default.
+
+EXAMPLES
+  # alert a webhook whenever a certificate is issued to and used for your email address
+  cosign monitor -email jane@example.com -webhook https://hooks.example.com/cosign
+
+  # look for uses of a key since log index 1000
+  cosign monitor -key cosign.pub -start 1000`,
+		FlagSet: flagset,
+		Exec:    cmd.Exec,
+	}
+}
+
+// Exec runs the monitor command
+func (c *MonitorCommand) Exec(ctx context.Context, args []string) error {
+	if len(args) != 0 || (c.Emails == "" && c.Key == "") {
+		return flag.ErrHelp
+	}
+	if c.Interval <= 0 {
+		return errors.New("-interval must be positive")
+	}
+	if c.out == nil {
+		c.out = os.Stdout
+	}
+
+	m := identityMatcher{}
+	for _, e := range strings.Split(c.Emails, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			m.emails = append(m.emails, e)
+		}
+	}
+	if c.Key != "" {
+		pub, err := cosign.LoadPublicKey(ctx, c.Key)
+		if err != nil {
+			return errors.Wrap(err, "loading public key")
+		}
+		pk, err := pub.PublicKey(ctx)
+		if err != nil {
+			return err
+		}
+		if m.key, err = x509.MarshalPKIXPublicKey(pk); err != nil {
+			return err
+		}
+	}
+
+	rekorURL := c.RekorURL
+	if rekorURL == "" {
+		rekorURL = cosign.TlogServer()
+	}
+	rc, err := cosign.TlogClientFor(rekorURL)
+	if err != nil {
+		return err
+	}
+	next := c.Start
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		if next, err = c.scan(ctx, rc, next, m); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// scan checks the entries from next to the end of the log, returning the index to continue from.
+func (c *MonitorCommand) scan(ctx context.Context, rc *client.Rekor, next int64, m identityMatcher) (int64, error) {
+	info, err := rc.Tlog.GetLogInfo(tlog.NewGetLogInfoParamsWithContext(ctx))
+	if err != nil {
+		return next, errors.Wrap(err, "getting log info")
+	}
+	size := *info.Payload.TreeSize
+	if next < 0 {
+		return size, nil
+	}
+	for ; next < size; next++ {
+		params := entries.NewGetLogEntryByIndexParamsWithContext(ctx)
+		params.SetLogIndex(next)
+		resp, err := rc.Entries.GetLogEntryByIndex(params)
+		if err != nil {
+			return next, errors.Wrapf(err, "getting log entry %d", next)
+		}
+		for uuid, e := range resp.Payload {
+			body, ok := e.Body.(string)
+			if !ok {
+				continue
+			}
+			ev, ok := m.match(body)
+			if !ok {
+				continue
+			}
+			ev.Time = time.Now().UTC()
+			ev.LogIndex = next
+			ev.UUID = uuid
+			ev.IntegratedTime = e.IntegratedTime
+			if err := emitEvent(ctx, c.out, c.Webhook, ev); err != nil {
+				return next, err
+			}
+		}
+	}
+	return next, nil
+}
+
+// rekordBody is the part of a rekord log entry needed to tell who signed it.
+type rekordBody struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+		Signature struct {
+			Content   string `json:"content"`
+			PublicKey struct {
+				Content []byte `json:"content"`
+			} `json:"publicKey"`
+		} `json:"signature"`
+	} `json:"spec"`
+}
+
+// match reports whether the base64 encoded entry body was signed by a monitored identity.
+// Entries of other types, or that can't be parsed, don't match.
+func (m identityMatcher) match(body string) (*monitorEvent, bool) {
+	raw, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, false
+	}
+	rb := rekordBody{}
+	if err := json.Unmarshal(raw, &rb); err != nil || rb.Kind != "rekord" {
+		return nil, false
+	}
+	ev := &monitorEvent{Signature: rb.Spec.Signature.Content}
+	if h := rb.Spec.Data.Hash; h.Value != "" {
+		ev.DataHash = h.Algorithm + ":" + h.Value
+	}
+	p, _ := pem.Decode(rb.Spec.Signature.PublicKey.Content)
+	if p == nil {
+		return nil, false
+	}
+	var pub crypto.PublicKey
+	switch p.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(p.Bytes)
+		if err != nil {
+			return nil, false
+		}
+		ev.Cert = string(pem.EncodeToMemory(p))
+		for _, want := range m.emails {
+			for _, got := range cert.EmailAddresses {
+				if strings.EqualFold(want, got) {
+					ev.Identity = got
+					return ev, true
+				}
+			}
+		}
+		pub = cert.PublicKey
+	case "PUBLIC KEY":
+		if pub, err = x509.ParsePKIXPublicKey(p.Bytes); err != nil {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	if m.key == nil {
+		return nil, false
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil || !bytes.Equal(der, m.key) {
+		return nil, false
+	}
+	ev.Identity = "key"
+	return ev, true
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestIdentityMatcher(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		NotBefore:      time.Now(),
+		NotAfter:       time.Now().Add(time.Hour),
+		EmailAddresses: []string{"Jane@example.com"},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &other.PublicKey, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: keyDER})
+
+	body := func(kind string, pub []byte) string {
+		rb := rekordBody{Kind: kind}
+		rb.Spec.Signature.Content = "c2ln"
+		rb.Spec.Signature.PublicKey.Content = pub
+		rb.Spec.Data.Hash.Algorithm = "sha256"
+		rb.Spec.Data.Hash.Value = "abc"
+		b, err := json.Marshal(rb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.StdEncoding.EncodeToString(b)
+	}
+
+	m := identityMatcher{emails: []string{"jane@example.com"}, key: keyDER}
+	tests := []struct {
+		desc     string
+		m        identityMatcher
+		body     string
+		identity string
+	}{
+		{desc: "certificate email", m: m, body: body("rekord", certPEM), identity: "Jane@example.com"},
+		{desc: "public key", m: m, body: body("rekord", keyPEM), identity: "key"},
+		{desc: "other email", m: identityMatcher{emails: []string{"john@example.com"}}, body: body("rekord", certPEM)},
+		{desc: "other key", m: identityMatcher{key: []byte("nope")}, body: body("rekord", keyPEM)},
+		{desc: "other kind", m: m, body: body("rpm", keyPEM)},
+		{desc: "not base64", m: m, body: "!!!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			ev, ok := tt.m.match(tt.body)
+			if ok != (tt.identity != "") {
+				t.Fatalf("match() = %v, want %v", ok, tt.identity != "")
+			}
+			if !ok {
+				return
+			}
+			if ev.Identity != tt.identity {
+				t.Errorf("Identity = %q, want %q", ev.Identity, tt.identity)
+			}
+			if ev.DataHash != "sha256:abc" || ev.Signature != "c2ln" {
+				t.Errorf("event = %+v", ev)
+			}
+		})
+	}
+}
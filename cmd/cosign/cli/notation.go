@@ -0,0 +1,235 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/fulcio"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+func Notation() *ffcli.Command {
+	return &ffcli.Command{
+		Name:       "notation",
+		ShortUsage: "cosign notation sign|verify",
+		ShortHelp:  "Sign and verify images with Notary v2 (notation) signatures",
+		LongHelp: `Sign and verify images with Notary v2 (notation) signatures.
+
+Notation signatures are JWS envelopes signed by an X.509 certificate, pushed as artifacts whose
+subject is the signed image. They are found with the registry's referrers API, or the
+sha256-<digest> referrers tag on registries without it, like notation does.`,
+		Subcommands: []*ffcli.Command{notationSign(), notationVerify()},
+		Exec: func(context.Context, []string) error {
+			return flag.ErrHelp
+		},
+	}
+}
+
+// NotationSignOpts holds the settings for NotationSignCmd.
+type NotationSignOpts struct {
+	KeyRef string
+	KmsVal string
+	// Cert is the path to the PEM certificate of the key, followed by its chain. Keyless signing
+	// uses the Fulcio certificate instead.
+	Cert     string
+	Registry RegistryOpts
+}
+
+func notationSign() *ffcli.Command {
+	var (
+		flagset  = flag.NewFlagSet("cosign notation sign", flag.ExitOnError)
+		key      = flagset.String("key", "", "path to the private key to sign with")
+		kmsVal   = flagset.String("kms", "", "sign with a private key stored in a KMS")
+		cert     = flagset.String("cert", "", "path to the PEM certificate of the key, followed by its chain")
+		registry = addRegistryFlags(flagset)
+	)
+	return &ffcli.Command{
+		Name:       "sign",
+		ShortUsage: "cosign notation sign -key <key path>|-kms <kms uri> -cert <path> <image uri>",
+		ShortHelp:  "Sign an image with a notation signature",
+		LongHelp: `Sign an image with a notation signature.
+
+Notation signatures must carry the certificate of the signing key, so signing with a key needs
+its certificate. Keyless signing (experimental) uses the certificate issued by Fulcio. Only ECDSA
+keys are supported.
+
+EXAMPLES
+  # sign with a key and its code signing certificate
+  cosign notation sign -key cosign.key -cert chain.pem <IMAGE>
+
+  # sign with Google sign-in (experimental)
+  COSIGN_EXPERIMENTAL=1 cosign notation sign <IMAGE>`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return flag.ErrHelp
+			}
+			if !cosign.Experimental() && *key == "" && *kmsVal == "" {
+				return &KeyParseError{}
+			}
+			no := NotationSignOpts{
+				KeyRef:   *key,
+				KmsVal:   *kmsVal,
+				Cert:     *cert,
+				Registry: *registry,
+			}
+			return NotationSignCmd(ctx, no, args[0], GetPass)
+		},
+	}
+}
+
+// NotationSignCmd pushes a notation signature of the image.
+func NotationSignCmd(ctx context.Context, no NotationSignOpts, imageRef string, pf cosign.PassFunc) error {
+	if no.KeyRef != "" && no.KmsVal != "" {
+		return &KeyParseError{}
+	}
+	if (no.KeyRef != "" || no.KmsVal != "") && no.Cert == "" {
+		return errors.New("signing with a key needs its certificate, set -cert")
+	}
+	ref, err := no.Registry.ParseReference(imageRef)
+	if err != nil {
+		return err
+	}
+	is, err := newImageSigner(ctx, SignOpts{KeyRef: no.KeyRef, KmsVal: no.KmsVal}, pf)
+	if err != nil {
+		return err
+	}
+	chainPEM := is.cert + is.chain
+	if no.Cert != "" {
+		b, err := ioutil.ReadFile(filepath.Clean(no.Cert))
+		if err != nil {
+			return err
+		}
+		chainPEM = string(b)
+	}
+	chain, err := cosign.LoadCerts(chainPEM)
+	if err != nil {
+		return errors.Wrap(err, "loading certificate")
+	}
+
+	opts := no.Registry.ClientOpts(ctx)
+	get, err := remote.Get(ref, opts...)
+	if err != nil {
+		return errors.Wrap(err, "getting remote image")
+	}
+	created, err := cosign.CreationTime()
+	if err != nil {
+		return err
+	}
+	envelope, err := cosign.NotationSignature(ctx, is.signer, chain, get.Descriptor, created)
+	if err != nil {
+		return err
+	}
+	rt, err := no.Registry.Transport(ref.Context())
+	if err != nil {
+		return err
+	}
+	digest, err := cosign.UploadNotationSignature(ctx, ref.Context(), get.Descriptor, envelope, rt, opts...)
+	if err != nil {
+		return err
+	}
+	log.Infof("Pushed notation signature %s of %s", ref.Context().Digest(digest.String()), imageRef)
+	return nil
+}
+
+func notationVerify() *ffcli.Command {
+	var (
+		flagset  = flag.NewFlagSet("cosign notation verify", flag.ExitOnError)
+		caRoots  = flagset.String("ca-roots", "", "path to the PEM certificates of the CAs to trust, instead of the Fulcio roots")
+		registry = addRegistryFlags(flagset)
+	)
+	return &ffcli.Command{
+		Name:       "verify",
+		ShortUsage: "cosign notation verify [-ca-roots <path>] <image uri>",
+		ShortHelp:  "Verify the notation signatures of an image",
+		LongHelp: `Verify the notation signatures of an image.
+
+Signatures verify if their certificate chains up to one of the CA roots, and is for code signing.
+The subject and emails of each signing certificate are printed as JSON.
+
+EXAMPLES
+  # verify signatures made with "notation sign", by certificates of your CA
+  cosign notation verify -ca-roots ca.pem <IMAGE>`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return flag.ErrHelp
+			}
+			return NotationVerifyCmd(ctx, *caRoots, *registry, args[0], os.Stdout)
+		},
+	}
+}
+
+// notationResult describes the certificate of a verified notation signature.
+type notationResult struct {
+	Digest  string   `json:"digest"`
+	Subject string   `json:"subject"`
+	Emails  []string `json:"emails,omitempty"`
+}
+
+// NotationVerifyCmd verifies the notation signatures of the image against the CA roots in the PEM
+// file at caRoots, or Fulcio's if it is empty, and prints who signed it.
+func NotationVerifyCmd(ctx context.Context, caRoots string, ro RegistryOpts, imageRef string, w io.Writer) error {
+	ref, err := ro.ParseReference(imageRef)
+	if err != nil {
+		return err
+	}
+	roots := fulcio.Roots
+	if caRoots != "" {
+		b, err := ioutil.ReadFile(filepath.Clean(caRoots))
+		if err != nil {
+			return err
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(b) {
+			return fmt.Errorf("no certificates found in %s", caRoots)
+		}
+	}
+	rt, err := ro.Transport(ref.Context())
+	if err != nil {
+		return err
+	}
+	subject, envelopes, err := cosign.FetchNotationSignatures(ctx, ref, rt, ro.ClientOpts(ctx)...)
+	if err != nil {
+		return err
+	}
+	results := []notationResult{}
+	for i, env := range envelopes {
+		cert, err := cosign.VerifyNotationSignature(env, subject, roots)
+		if err != nil {
+			log.Debugf("notation signature %d of %s: %v", i+1, imageRef, err)
+			continue
+		}
+		results = append(results, notationResult{Digest: subject.Digest.String(), Subject: cert.Subject.String(), Emails: cert.EmailAddresses})
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("none of the %d notation signatures of %s verified", len(envelopes), imageRef)
+	}
+	log.Infof("Verified %d notation signature(s) of %s", len(results), imageRef)
+	return printJSON(w, results)
+}
@@ -0,0 +1,138 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/zalando/go-keyring"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+func TestNotationSignVerify(t *testing.T) {
+	keyring.MockInit()
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/notation:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	td, err := ioutil.TempDir("", "cosign-notation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	pass := func(bool) ([]byte, error) { return []byte("hunter2"), nil }
+	keys, err := cosign.GenerateKeyPair(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(td, "cosign.key")
+	if err := ioutil.WriteFile(keyPath, keys.PrivateBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	pubKey, err := cosign.ParsePublicKeyPem(keys.PublicBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := pubKey.PublicKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A CA, and a code signing certificate it issued for the cosign key.
+	writeCert := func(path string, tmpl, parent *x509.Certificate, pub, priv interface{}) *x509.Certificate {
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+			t.Fatal(err)
+		}
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caPath, certPath := filepath.Join(td, "ca.pem"), filepath.Join(td, "cert.pem")
+	ca := writeCert(caPath, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	writeCert(certPath, &x509.Certificate{
+		SerialNumber:   big.NewInt(2),
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		EmailAddresses: []string{"release@example.com"},
+	}, ca, pub, caKey)
+
+	out := &bytes.Buffer{}
+	if err := NotationVerifyCmd(ctx, caPath, RegistryOpts{}, ref.String(), out); err == nil {
+		t.Error("expected error verifying an image without notation signatures")
+	}
+	if err := NotationSignCmd(ctx, NotationSignOpts{KeyRef: keyPath}, ref.String(), pass); err == nil {
+		t.Error("expected error signing with a key but no certificate")
+	}
+	if err := NotationSignCmd(ctx, NotationSignOpts{KeyRef: keyPath, Cert: certPath}, ref.String(), pass); err != nil {
+		t.Fatal(err)
+	}
+	if err := NotationVerifyCmd(ctx, caPath, RegistryOpts{}, ref.String(), out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "release@example.com") {
+		t.Errorf("output = %s, want the signer's email", out)
+	}
+}
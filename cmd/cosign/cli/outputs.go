@@ -0,0 +1,178 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+// githubOutputEnv is the file GitHub Actions reads the outputs of a step from.
+const githubOutputEnv = "GITHUB_OUTPUT"
+
+// StepOutputs collects results of a command as key=value pairs, for the steps of a CI workflow
+// that follow. They're appended to OutputFile, and to $GITHUB_OUTPUT when it's set.
+type StepOutputs struct {
+	OutputFile string
+
+	mu      sync.Mutex
+	keys    []string
+	outputs map[string][]string
+}
+
+func addOutputFlags(fs *flag.FlagSet) *StepOutputs {
+	o := &StepOutputs{}
+	fs.StringVar(&o.OutputFile, "output-file", "", "append the results as key=value lines to this file, for the workflow steps that follow; they're also appended to $"+githubOutputEnv+" when it's set")
+	return o
+}
+
+// add records value under key. The values of a key are written one per line, in the order added.
+func (o *StepOutputs) add(key, value string) {
+	if o == nil || value == "" {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.outputs == nil {
+		o.outputs = map[string][]string{}
+	}
+	if _, ok := o.outputs[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.outputs[key] = append(o.outputs[key], value)
+}
+
+// set records value as the only one of key.
+func (o *StepOutputs) set(key, value string) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	if _, ok := o.outputs[key]; ok {
+		o.outputs[key] = nil
+	}
+	o.mu.Unlock()
+	o.add(key, value)
+}
+
+// addSigning records the results of signing an image. sigDigest is the digest of the image the
+// signature was stored in, if it was uploaded.
+func (o *StepOutputs) addSigning(ev *cosign.SigningEvent, sigDigest string) {
+	if o == nil {
+		return
+	}
+	o.add("image", ev.Image.String())
+	o.add("digest", ev.Image.DigestStr())
+	if ev.Signature != nil {
+		o.add("signature", base64.StdEncoding.EncodeToString(ev.Signature))
+	}
+	if ev.SignatureRef != nil {
+		o.add("signature-ref", ev.SignatureRef.String())
+	}
+	o.add("signature-digest", sigDigest)
+	if ev.TlogEntry != nil {
+		o.add("rekor-log-index", strconv.FormatInt(ev.TlogEntry.LogIndex, 10))
+		o.add("rekor-uuid", ev.TlogEntry.UUID)
+	}
+	if certs, err := cosign.LoadCerts(ev.Cert); err == nil && len(certs) > 0 {
+		o.add("certificate-identity", certIdentity(certs[0]))
+	}
+}
+
+// addVerification records the results of verifying the image ref, a digest reference.
+func (o *StepOutputs) addVerification(ref name.Digest, verified []cosign.VerifiedSignature) {
+	if o == nil {
+		return
+	}
+	o.add("image", ref.String())
+	o.add("digest", ref.DigestStr())
+	o.set("verified", "true")
+	for _, vs := range verified {
+		o.add("rekor-uuid", vs.TlogEntryUUID)
+		if vs.Cert != nil {
+			o.add("certificate-identity", certIdentity(vs.Cert))
+		}
+	}
+}
+
+// write appends the outputs to OutputFile and $GITHUB_OUTPUT.
+func (o *StepOutputs) write() error {
+	if o == nil {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	b := o.format()
+	for _, path := range []string{o.OutputFile, os.Getenv(githubOutputEnv)} {
+		if path == "" {
+			continue
+		}
+		f, err := os.OpenFile(filepath.Clean(path), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return errors.Wrap(err, "writing outputs")
+		}
+		_, err = f.Write(b)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return errors.Wrap(err, "writing outputs")
+		}
+	}
+	return nil
+}
+
+// format writes the outputs the way GitHub Actions reads them: key=value, or for values over
+// several lines, between key<<DELIMITER and DELIMITER lines.
+func (o *StepOutputs) format() []byte {
+	var b bytes.Buffer
+	for _, k := range o.keys {
+		v := strings.Join(o.outputs[k], "\n")
+		if !strings.Contains(v, "\n") {
+			fmt.Fprintf(&b, "%s=%s\n", k, v)
+			continue
+		}
+		delim := "COSIGN_OUTPUT"
+		for strings.Contains(v, delim) {
+			delim += "_"
+		}
+		fmt.Fprintf(&b, "%s<<%s\n%s\n%s\n", k, delim, v, delim)
+	}
+	return b.Bytes()
+}
+
+// certIdentity returns the identity a keyless certificate was issued for, its email or URI.
+func certIdentity(cert *x509.Certificate) string {
+	if len(cert.EmailAddresses) > 0 {
+		return cert.EmailAddresses[0]
+	}
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String()
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+func TestStepOutputs(t *testing.T) {
+	td := t.TempDir()
+	outputFile := filepath.Join(td, "results.env")
+	githubOutput := filepath.Join(td, "github_output")
+	if err := ioutil.WriteFile(githubOutput, []byte("previous=step\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv(githubOutputEnv, githubOutput)
+	defer os.Unsetenv(githubOutputEnv)
+
+	// Nothing is recorded without outputs.
+	var none *StepOutputs
+	none.add("digest", "sha256:00")
+	if err := none.write(); err != nil {
+		t.Fatal(err)
+	}
+
+	o := &StepOutputs{OutputFile: outputFile}
+	for _, img := range []string{"gcr.io/acme/app@sha256:0000000000000000000000000000000000000000000000000000000000000001", "gcr.io/acme/web@sha256:0000000000000000000000000000000000000000000000000000000000000002"} {
+		ref, err := name.NewDigest(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o.addVerification(ref, []cosign.VerifiedSignature{{TlogEntryUUID: "abc"}})
+	}
+	if err := o.write(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `image<<COSIGN_OUTPUT
+gcr.io/acme/app@sha256:0000000000000000000000000000000000000000000000000000000000000001
+gcr.io/acme/web@sha256:0000000000000000000000000000000000000000000000000000000000000002
+COSIGN_OUTPUT
+digest<<COSIGN_OUTPUT
+sha256:0000000000000000000000000000000000000000000000000000000000000001
+sha256:0000000000000000000000000000000000000000000000000000000000000002
+COSIGN_OUTPUT
+verified=true
+rekor-uuid<<COSIGN_OUTPUT
+abc
+abc
+COSIGN_OUTPUT
+`
+	for path, prefix := range map[string]string{outputFile: "", githubOutput: "previous=step\n"} {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != prefix+want {
+			t.Errorf("%s = %q, want %q", filepath.Base(path), b, prefix+want)
+		}
+	}
+}
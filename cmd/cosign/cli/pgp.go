@@ -0,0 +1,80 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+const pgpArmorPrefix = "-----BEGIN PGP"
+
+// VerifyBlobPGPCmd verifies a detached OpenPGP signature on the blob against the keys in a keyring,
+// such as one exported with `gpg --export`. Both the keyring and the signature may be armored or binary.
+func VerifyBlobPGPCmd(_ context.Context, keyringRef, sigRef, blobRef string) error {
+	keyring, err := loadKeyring(keyringRef)
+	if err != nil {
+		return errors.Wrap(err, "loading keyring")
+	}
+	sig, err := ioutil.ReadFile(filepath.Clean(sigRef))
+	if err != nil {
+		return err
+	}
+	r, closer, err := blobReader(blobRef)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	var signer *openpgp.Entity
+	if bytes.HasPrefix(bytes.TrimSpace(sig), []byte(pgpArmorPrefix)) {
+		signer, err = openpgp.CheckArmoredDetachedSignature(keyring, r, bytes.NewReader(sig))
+	} else {
+		signer, err = openpgp.CheckDetachedSignature(keyring, r, bytes.NewReader(sig))
+	}
+	if err != nil {
+		return err
+	}
+	// The entity doesn't say which of its keys made the signature, so its primary key is the one checked.
+	if err := cosign.CheckFIPSKey(signer.PrimaryKey.PublicKey); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Verified OK (key %X", signer.PrimaryKey.Fingerprint)
+	for name := range signer.Identities {
+		fmt.Fprintf(os.Stderr, ", %s", name)
+	}
+	fmt.Fprintln(os.Stderr, ")")
+	return nil
+}
+
+func loadKeyring(keyringRef string) (openpgp.EntityList, error) {
+	b, err := ioutil.ReadFile(filepath.Clean(keyringRef))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte(pgpArmorPrefix)) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(b))
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func TestVerifyBlobPGPCmd(t *testing.T) {
+	td := t.TempDir()
+	entity, err := openpgp.NewEntity("Release Signer", "", "release@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keyring bytes.Buffer
+	w, err := armor.Encode(&keyring, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	blob := []byte("release artifact")
+	var armored, binary bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&armored, entity, bytes.NewReader(blob), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := openpgp.DetachSign(&binary, entity, bytes.NewReader(blob), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(name string, b []byte) string {
+		p := filepath.Join(td, name)
+		if err := ioutil.WriteFile(p, b, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	keyringPath := write("keyring.asc", keyring.Bytes())
+	blobPath := write("blob", blob)
+	otherPath := write("other", []byte("something else"))
+
+	ctx := context.Background()
+	for name, sig := range map[string][]byte{"armored": armored.Bytes(), "binary": binary.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			sigPath := write(name+".sig", sig)
+			if err := VerifyBlobPGPCmd(ctx, keyringPath, sigPath, blobPath); err != nil {
+				t.Errorf("VerifyBlobPGPCmd() = %v", err)
+			}
+			if err := VerifyBlobPGPCmd(ctx, keyringPath, sigPath, otherPath); err == nil {
+				t.Error("expected error for wrong blob")
+			}
+		})
+	}
+}
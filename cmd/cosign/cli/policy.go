@@ -0,0 +1,158 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+func Policy() *ffcli.Command {
+	return &ffcli.Command{
+		Name:       "policy",
+		ShortUsage: "cosign policy sign|verify",
+		ShortHelp:  "Sign verification policies and store them in the registry, where verify -policy-key finds them",
+		LongHelp: `Sign verification policies and store them in the registry, where verify -policy-key finds them.
+
+A policy has the keys, CA roots and identities of a trust profile, see "cosign trust". It is
+signed for a repository or a namespace of repositories, and stored in the registry at
+<repository>/` + cosign.PolicyRepository + `:` + cosign.PolicyTag + `. The policy of the most specific repository or namespace
+applies to an image, and is only applied if it is signed with the policy key, so it can't be
+tampered with on its way to the verifiers.`,
+		Subcommands: []*ffcli.Command{policySign(), policyVerify()},
+		Exec: func(context.Context, []string) error {
+			return flag.ErrHelp
+		},
+	}
+}
+
+func policySign() *ffcli.Command {
+	var (
+		flagset  = flag.NewFlagSet("cosign policy sign", flag.ExitOnError)
+		key      = flagset.String("key", "", "path to the private key to sign the policy with")
+		kmsVal   = flagset.String("kms", "", "sign the policy with a private key stored in a KMS")
+		registry = addRegistryFlags(flagset)
+	)
+	return &ffcli.Command{
+		Name:       "sign",
+		ShortUsage: "cosign policy sign -key <key path>|-kms <kms uri> <policy file> <repository>",
+		ShortHelp:  "Sign a policy for the images in a repository or namespace, and push it",
+		LongHelp: `Sign a policy for the images in a repository or namespace, and push it.
+
+The policy file is JSON or YAML with "keys", "roots" and "identities" lists, the format of the
+trust profiles "cosign trust" manages. It replaces the repository's signed policy.
+
+EXAMPLES
+  # every image in gcr.io/acme must be signed by the release bot
+  cosign policy sign -key policy.key acme.yaml gcr.io/acme
+
+  # except gcr.io/acme/experimental, which has its own policy
+  cosign policy sign -kms gcpkms://projects/acme/locations/global/keyRings/policy/cryptoKeys/policy experimental.yaml gcr.io/acme/experimental`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 2 {
+				return flag.ErrHelp
+			}
+			if (*key == "") == (*kmsVal == "") {
+				return &KeyParseError{}
+			}
+			return PolicySignCmd(ctx, SignOpts{KeyRef: *key, KmsVal: *kmsVal, Registry: *registry}, args[0], args[1], GetPass)
+		},
+	}
+}
+
+func policyVerify() *ffcli.Command {
+	var (
+		flagset  = flag.NewFlagSet("cosign policy verify", flag.ExitOnError)
+		key      = flagset.String("key", "", "path to the public key, or KMS reference, the policy must be signed with")
+		registry = addRegistryFlags(flagset)
+	)
+	return &ffcli.Command{
+		Name:       "verify",
+		ShortUsage: "cosign policy verify -key <key path>|<kms uri> <repository>",
+		ShortHelp:  "Print the signed policy that applies to a repository",
+		LongHelp: `Print the signed policy that applies to a repository, after verifying its signature.
+
+EXAMPLES
+  # show the policy verify -policy-key applies to gcr.io/acme/app
+  cosign policy verify -key policy.pub gcr.io/acme/app`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 || *key == "" {
+				return flag.ErrHelp
+			}
+			return PolicyVerifyCmd(ctx, *key, *registry, args[0], os.Stdout)
+		},
+	}
+}
+
+// PolicySignCmd signs the policy in policyPath for the repository or namespace scope, and pushes it.
+func PolicySignCmd(ctx context.Context, so SignOpts, policyPath, scope string, pf cosign.PassFunc) error {
+	b, err := ioutil.ReadFile(filepath.Clean(policyPath))
+	if err != nil {
+		return err
+	}
+	tp := &cosign.TrustProfile{Name: policyPath}
+	if err := yaml.Unmarshal(b, tp); err != nil {
+		return errors.Wrapf(err, "parsing policy %s", policyPath)
+	}
+	// Catch invalid keys and roots before they're signed, rather than when verifying.
+	if err := tp.Apply(&cosign.CheckOpts{}); err != nil {
+		return err
+	}
+	repo, err := so.Registry.ParseRepository(scope)
+	if err != nil {
+		return err
+	}
+	is, err := newImageSigner(ctx, so, pf)
+	if err != nil {
+		return err
+	}
+	dst, err := cosign.SignPolicy(ctx, is.signer, repo, tp, so.Registry.ClientOpts(ctx)...)
+	if err != nil {
+		return err
+	}
+	log.Infof("Pushed the policy for %s to %s", repo, dst)
+	return nil
+}
+
+// PolicyVerifyCmd writes the policy that applies to repo as JSON to w, if it is signed with the key keyRef refers to.
+func PolicyVerifyCmd(ctx context.Context, keyRef string, ro RegistryOpts, repo string, w io.Writer) error {
+	r, err := ro.ParseRepository(repo)
+	if err != nil {
+		return err
+	}
+	pubKey, err := cosign.LoadPublicKey(ctx, keyRef)
+	if err != nil {
+		return errors.Wrap(err, "loading public key")
+	}
+	tp, err := cosign.FetchPolicy(ctx, r, []cosign.PublicKey{pubKey}, ro.ClientOpts(ctx)...)
+	if err != nil {
+		return err
+	}
+	log.Infof("Verified the policy at %s", tp.Name)
+	return printJSON(w, tp)
+}
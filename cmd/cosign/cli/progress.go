@@ -0,0 +1,103 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+// progressInterval is how often the progress of each blob is printed while it is transferred.
+const progressInterval = 2 * time.Second
+
+// addProgressFlag registers -progress on fs.
+func addProgressFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("progress", false, "print the progress of pushing and pulling large blobs to stderr")
+}
+
+// withProgress has the transfers made under ctx print their progress to stderr, if enabled.
+func withProgress(ctx context.Context, enabled bool) context.Context {
+	if !enabled {
+		return ctx
+	}
+	return cosign.WithProgress(ctx, newProgressPrinter(os.Stderr).report)
+}
+
+// progressPrinter prints a status line for each blob every progressInterval, and when it is done.
+type progressPrinter struct {
+	w        io.Writer
+	interval time.Duration
+	now      func() time.Time
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func newProgressPrinter(w io.Writer) *progressPrinter {
+	return &progressPrinter{w: w, interval: progressInterval, now: time.Now, last: map[string]time.Time{}}
+}
+
+func (p *progressPrinter) report(name string, done, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	finished := done >= total
+	if last, ok := p.last[name]; ok && !finished && now.Sub(last) < p.interval {
+		return
+	}
+	if finished {
+		delete(p.last, name)
+	} else {
+		p.last[name] = now
+	}
+	fmt.Fprintf(p.w, "%s %s %s/%s\n", name, progressBar(done, total), formatBytes(done), formatBytes(total))
+}
+
+// progressBar draws a bar of how much of total is done, followed by the percentage.
+func progressBar(done, total int64) string {
+	const width = 30
+	pct := int64(100)
+	if total > 0 {
+		pct = done * 100 / total
+	}
+	filled := int(pct * width / 100)
+	bar := strings.Repeat("=", filled)
+	if filled < width {
+		bar += ">" + strings.Repeat(" ", width-filled-1)
+	}
+	return fmt.Sprintf("[%s] %3d%%", bar, pct)
+}
+
+// formatBytes returns n in the largest binary unit it has at least one of.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
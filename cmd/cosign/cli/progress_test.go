@@ -0,0 +1,59 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressPrinter(t *testing.T) {
+	buf := bytes.Buffer{}
+	p := newProgressPrinter(&buf)
+	now := time.Unix(0, 0)
+	p.now = func() time.Time { return now }
+
+	p.report("sbom.json", 0, 4<<20)
+	p.report("sbom.json", 1<<20, 4<<20) // within the interval, skipped
+	now = now.Add(progressInterval)
+	p.report("sbom.json", 2<<20, 4<<20)
+	p.report("sbom.json", 4<<20, 4<<20) // done, always printed
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("printed %d lines, want 3:\n%s", len(lines), buf.String())
+	}
+	for i, want := range []string{"  0% 0 B/4.0 MiB", " 50% 2.0 MiB/4.0 MiB", "100% 4.0 MiB/4.0 MiB"} {
+		if !strings.HasPrefix(lines[i], "sbom.json [") || !strings.HasSuffix(lines[i], want) {
+			t.Errorf("line %d = %q, want it to end with %q", i, lines[i], want)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1536:            "1.5 KiB",
+		5 << 30:         "5.0 GiB",
+		3<<40 + 512<<30: "3.5 TiB",
+	} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// isCosignTag reports whether tag holds signatures, attestations or other artifacts of an image
+// rather than an image.
+func isCosignTag(tag string) bool {
+	_, _, err := cosign.ParseArtifactTag(tag)
+	return err == nil
+}
+
+// PruneCommand finds, and optionally deletes, signatures and attestations whose image is gone.
+type PruneCommand struct {
+	Registry RegistryOpts
+	// Delete removes the orphaned tags instead of only reporting them.
+	Delete bool
+
+	out io.Writer
+}
+
+// pruneResult is printed as a JSON line for each orphaned signature or attestation tag.
+type pruneResult struct {
+	Tag     string `json:"tag"`
+	Subject string `json:"subject"`
+	Deleted bool   `json:"deleted"`
+}
+
+// Prune builds and returns an ffcli command
+func Prune() *ffcli.Command {
+	cmd := PruneCommand{out: os.Stdout}
+	flagset := flag.NewFlagSet("cosign prune", flag.ExitOnError)
+	flagset.BoolVar(&cmd.Delete, "delete", false, "delete the orphaned signatures and attestations, instead of only listing them")
+	cmd.Registry.addFlags(flagset)
+
+	return &ffcli.Command{
+		Name:       "prune",
+		ShortUsage: "cosign prune [-delete] <repository> [<repository> ...]",
+		ShortHelp:  "Find signatures and attestations for images that no longer exist",
+		LongHelp: `Find signatures and attestations for images that no longer exist.
+
+Every signature, attestation, countersignature and SBOM tag in the repository is checked for the image it belongs to,
+and those whose image has been deleted are printed as JSON lines. With -delete they are removed.
+
+Signatures stored in another repository with COSIGN_REPOSITORY can't be matched to their images,
+run prune on the repository the images are in.
+
+EXAMPLES
+  # list orphaned signatures and attestations
+  cosign prune <REPOSITORY>
+
+  # and delete them
+  cosign prune -delete <REPOSITORY>`,
+		FlagSet: flagset,
+		Exec:    cmd.Exec,
+	}
+}
+
+// Exec runs the prune command
+func (c *PruneCommand) Exec(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return flag.ErrHelp
+	}
+	if c.out == nil {
+		c.out = os.Stdout
+	}
+	for _, repo := range args {
+		if err := c.prune(ctx, repo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *PruneCommand) prune(ctx context.Context, repo string) error {
+	r, err := c.Registry.ParseRepository(repo)
+	if err != nil {
+		return err
+	}
+	opts := c.Registry.ClientOpts(ctx)
+	tags, err := remote.List(r, opts...)
+	if err != nil {
+		return errors.Wrapf(err, "listing tags in %s", repo)
+	}
+	orphans := 0
+	for _, tag := range tags {
+		subject, _, err := cosign.SubjectRef(r.Tag(tag))
+		if err != nil {
+			continue
+		}
+		if _, err := remote.Head(subject, opts...); err == nil {
+			continue
+		} else if !isNotFound(err) {
+			return errors.Wrapf(err, "checking %s", subject)
+		}
+
+		orphans++
+		res := pruneResult{Tag: r.Tag(tag).String(), Subject: subject.String()}
+		if c.Delete {
+			// Registries generally only delete manifests by digest.
+			desc, err := remote.Head(r.Tag(tag), opts...)
+			if err != nil {
+				return errors.Wrapf(err, "resolving %s", res.Tag)
+			}
+			if err := remote.Delete(r.Digest(desc.Digest.String()), opts...); err != nil {
+				return errors.Wrapf(err, "deleting %s", res.Tag)
+			}
+			res.Deleted = true
+		}
+		if err := printJSON(c.out, res); err != nil {
+			return err
+		}
+	}
+	if orphans > 0 && !c.Delete {
+		log.Infof("Nothing was deleted, pass -delete to remove these.")
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	var te *transport.Error
+	return errors.As(err, &te) && te.StatusCode == http.StatusNotFound
+}
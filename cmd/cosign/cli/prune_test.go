@@ -0,0 +1,111 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+func TestIsCosignTag(t *testing.T) {
+	for tag, want := range map[string]bool{
+		"sha256-87ef60f558bad79beea6425a3b28989f01dd417164150ab3baab98dcbf04def8.cosign":      true,
+		"sha256-87ef60f558bad79beea6425a3b28989f01dd417164150ab3baab98dcbf04def8.att":         true,
+		"sha256-87ef60f558bad79beea6425a3b28989f01dd417164150ab3baab98dcbf04def8.countersign": true,
+		"latest":         false,
+		"v1.cosign":      false,
+		"sha256-abc.sig": false,
+	} {
+		if got := isCosignTag(tag); got != want {
+			t.Errorf("isCosignTag(%q) = %v, want %v", tag, got, want)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	repo := strings.TrimPrefix(s.URL, "http://") + "/prune"
+
+	// Both images are signed, then one of them is deleted.
+	sigTags := map[string]name.Reference{}
+	for _, tag := range []string{"kept", "deleted"} {
+		ref, err := name.ParseReference(repo + ":" + tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := random.Image(10, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+		desc, err := remote.Get(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst, err := cosign.DestinationRef(ref, desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cosign.Upload(ctx, []byte("sig"), []byte("payload"), dst, cosign.SignatureMetadata{}); err != nil {
+			t.Fatal(err)
+		}
+		sigTags[tag] = dst
+		if tag == "deleted" {
+			if err := remote.Delete(ref.Context().Digest(desc.Digest.String())); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	c := &PruneCommand{out: buf}
+	if err := c.Exec(ctx, []string{repo}); err != nil {
+		t.Fatal(err)
+	}
+	res := pruneResult{}
+	if err := json.Unmarshal(buf.Bytes(), &res); err != nil {
+		t.Fatalf("expected one orphan, got %q: %v", buf.String(), err)
+	}
+	if res.Tag != sigTags["deleted"].String() || res.Deleted {
+		t.Errorf("prune result = %+v, want %s listed but not deleted", res, sigTags["deleted"])
+	}
+
+	buf.Reset()
+	c.Delete = true
+	if err := c.Exec(ctx, []string{repo}); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(buf.Bytes(), &res); err != nil || !res.Deleted {
+		t.Fatalf("prune -delete result = %q", buf.String())
+	}
+	if _, err := cosign.Descriptors(ctx, sigTags["kept"]); err != nil {
+		t.Errorf("signature of the kept image was removed: %v", err)
+	}
+}
@@ -17,7 +17,6 @@ package cli
 import (
 	"context"
 	"flag"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
@@ -25,6 +24,7 @@ import (
 
 	"github.com/sigstore/cosign/pkg/cosign"
 	"github.com/sigstore/cosign/pkg/cosign/kms"
+	"github.com/sigstore/cosign/pkg/cosign/log"
 
 	"github.com/peterbourgon/ff/v3/ffcli"
 )
@@ -125,7 +125,7 @@ func GetPublicKey(ctx context.Context, reader io.Reader, kmsVal string, writer N
 		return err
 	}
 	if writer.Name != "" {
-		fmt.Fprintln(os.Stderr, "Public key written to ", writer.Name)
+		log.Infof("Public key written to %s", writer.Name)
 	}
 	return nil
 }
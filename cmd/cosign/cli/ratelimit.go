@@ -0,0 +1,57 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"math"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// limiterKey identifies a registry limiter, so commands limited differently don't share one.
+type limiterKey struct {
+	host  string
+	qps   float64
+	burst int
+}
+
+// registryLimiters are kept for the life of the process: the RegistryOpts of a command is
+// copied into every client it makes, and they must all draw from the same limit.
+var registryLimiters = struct {
+	sync.Mutex
+	m map[limiterKey]*rate.Limiter
+}{m: map[limiterKey]*rate.Limiter{}}
+
+// limiter returns the limiter requests to host wait on, or nil if ro doesn't limit them.
+func (ro RegistryOpts) limiter(host string) *rate.Limiter {
+	if ro.QPS <= 0 {
+		return nil
+	}
+	burst := ro.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(ro.QPS))
+	}
+	key := limiterKey{host: host, qps: ro.QPS, burst: burst}
+
+	registryLimiters.Lock()
+	defer registryLimiters.Unlock()
+	l, ok := registryLimiters.m[key]
+	if !ok {
+		l = rate.NewLimiter(rate.Limit(ro.QPS), burst)
+		registryLimiters.m[key] = l
+	}
+	return l
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRegistryQPS(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	get := func(ro RegistryOpts) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, s.URL+"/v2/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := (&registryTransport{ro: ro}).RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	// Copies of the options, as every client of a command gets, share the limit.
+	ro := RegistryOpts{QPS: 20, Burst: 2}
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		get(ro)
+	}
+	// The burst goes through at once, the other 4 requests wait 50ms each.
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("6 requests at 20 QPS with a burst of 2 took %s, want about 200ms", elapsed)
+	}
+
+	start = time.Now()
+	for i := 0; i < 20; i++ {
+		get(RegistryOpts{})
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("unlimited requests took %s", elapsed)
+	}
+}
+
+func TestRegistryLimiterBurst(t *testing.T) {
+	if l := (RegistryOpts{}).limiter("example.com"); l != nil {
+		t.Error("limiter() without -registry-qps, want none")
+	}
+	l := (RegistryOpts{QPS: 2.5}).limiter("example.com")
+	if l.Burst() != 3 {
+		t.Errorf("default burst = %d, want the QPS rounded up to 3", l.Burst())
+	}
+	if l != (RegistryOpts{QPS: 2.5}).limiter("example.com") {
+		t.Error("limiter() isn't shared for the same registry")
+	}
+	if l == (RegistryOpts{QPS: 2.5}).limiter("other.example.com") {
+		t.Error("limiter() is shared across registries")
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/daemon"
+)
+
+// RegistryOpts holds explicit registry credentials. When none are set, credentials come from
+// the docker config file and any credential helpers it configures.
+type RegistryOpts struct {
+	Username string
+	Password string
+	Token    string
+	// AllowInsecure permits plain HTTP and unverified TLS for every registry.
+	// Individual registries can be allowed with $COSIGN_INSECURE_REGISTRIES instead.
+	AllowInsecure bool
+	// QPS, if positive, limits the requests made to each registry to that many per second, with
+	// bursts of up to Burst requests. Every request of the command shares the limit.
+	QPS   float64
+	Burst int
+	// Mirrors are "<registry>=<mirror>" mirrors to look images and signatures up in first, see
+	// cosign.ParseRegistryMirrors. Those in $COSIGN_REGISTRY_MIRRORS are tried after them.
+	Mirrors []string
+}
+
+// addRegistryFlags registers the registry credential flags on fs.
+func addRegistryFlags(fs *flag.FlagSet) *RegistryOpts {
+	ro := &RegistryOpts{}
+	ro.addFlags(fs)
+	return ro
+}
+
+func (ro *RegistryOpts) addFlags(fs *flag.FlagSet) {
+	fs.StringVar(&ro.Username, "registry-username", os.Getenv("COSIGN_REGISTRY_USERNAME"), "username to authenticate to the registry with, instead of the docker config")
+	fs.StringVar(&ro.Password, "registry-password", "", "password to authenticate to the registry with, or set $COSIGN_REGISTRY_PASSWORD")
+	fs.StringVar(&ro.Token, "registry-token", "", "bearer token to authenticate to the registry with, or set $COSIGN_REGISTRY_TOKEN")
+	fs.BoolVar(&ro.AllowInsecure, "allow-insecure-registry", false, "allow plain HTTP and self-signed TLS registries, or list them in $COSIGN_INSECURE_REGISTRIES")
+	fs.Float64Var(&ro.QPS, "registry-qps", 0, "the most requests per second to make to each registry, so audits of many images aren't throttled. 0 for no limit")
+	fs.IntVar(&ro.Burst, "registry-burst", 0, "with -registry-qps, how many requests may be made at once before the limit applies, by default -registry-qps rounded up")
+}
+
+// addMirrorFlag registers the registry mirror flag on fs, for the commands that look signatures up.
+func (ro *RegistryOpts) addMirrorFlag(fs *flag.FlagSet) {
+	fs.Var((*filesFlag)(&ro.Mirrors), "registry-mirror", "look images and signatures up in this mirror first, falling back to the registry, as <registry>=<mirror>, or <mirror> for Docker Hub. May be repeated, or listed in $"+cosign.MirrorsEnv)
+}
+
+// Context returns ctx with the registry mirrors of ro, see cosign.WithRegistryMirrors.
+func (ro RegistryOpts) Context(ctx context.Context) (context.Context, error) {
+	if len(ro.Mirrors) == 0 {
+		return ctx, nil
+	}
+	mirrors, err := cosign.ParseRegistryMirrors(append(ro.Mirrors, strings.Split(os.Getenv(cosign.MirrorsEnv), ",")...))
+	if err != nil {
+		return nil, err
+	}
+	return cosign.WithRegistryMirrors(ctx, mirrors), nil
+}
+
+// ParseReference parses an image reference, allowing plain HTTP for insecure registries.
+func (ro RegistryOpts) ParseReference(s string) (name.Reference, error) {
+	ref, err := name.ParseReference(s)
+	if err != nil || !ro.insecure(ref.Context().RegistryStr()) {
+		return ref, err
+	}
+	return name.ParseReference(s, name.Insecure)
+}
+
+// parseImage parses an image reference like ParseReference. docker:// and containerd:// references are
+// looked up in the local image store, returning the reference in the registry the image will be pushed
+// to, where its signatures are stored, and the descriptor of the image it will be pushed as.
+func (ro RegistryOpts) parseImage(ctx context.Context, s string) (name.Reference, *remote.Descriptor, error) {
+	if !daemon.IsLocal(s) {
+		ref, err := ro.ParseReference(s)
+		return ref, nil, err
+	}
+	img, err := daemon.Resolve(ctx, s)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "resolving local image")
+	}
+	ref := img.Ref
+	if ro.insecure(ref.Context().RegistryStr()) {
+		if ref, err = name.ParseReference(ref.Name(), name.Insecure); err != nil {
+			return nil, nil, err
+		}
+	}
+	return ref, &remote.Descriptor{Descriptor: img.Descriptor}, nil
+}
+
+// ParseRepository parses a repository name, allowing plain HTTP for insecure registries.
+func (ro RegistryOpts) ParseRepository(s string) (name.Repository, error) {
+	repo, err := name.NewRepository(s)
+	if err != nil || !ro.insecure(repo.RegistryStr()) {
+		return repo, err
+	}
+	return name.NewRepository(s, name.Insecure)
+}
+
+// insecure reports whether host may be reached over plain HTTP or unverified TLS.
+func (ro RegistryOpts) insecure(host string) bool {
+	if ro.AllowInsecure {
+		return true
+	}
+	for _, h := range strings.Split(os.Getenv("COSIGN_INSECURE_REGISTRIES"), ",") {
+		if strings.TrimSpace(h) == host {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientOpts returns the options registry operations should use to authenticate, bound to ctx.
+func (ro RegistryOpts) ClientOpts(ctx context.Context) []remote.Option {
+	return ro.Client().Options(ctx)
+}
+
+// Client returns a registry client configured by ro. Retries are left to the default transport,
+// which ConfigureNetwork sets up.
+func (ro RegistryOpts) Client() *cosign.RepositoryClient {
+	return cosign.NewRepositoryClient(cosign.RegistryOptions{
+		Auth:      ro.explicitAuth(),
+		Transport: &registryTransport{ro: ro},
+	})
+}
+
+// Transport returns an authenticated transport for pulling from repo, for the parts of the
+// registry API the client library doesn't cover yet.
+func (ro RegistryOpts) Transport(repo name.Repository) (http.RoundTripper, error) {
+	return ro.transport(repo.Registry, repo.Registry, []string{repo.Scope(transport.PullScope)})
+}
+
+// PushTransport returns an authenticated transport for pushing to repo, see Transport.
+func (ro RegistryOpts) PushTransport(repo name.Repository) (http.RoundTripper, error) {
+	return ro.transport(repo.Registry, repo.Registry, []string{repo.Scope(transport.PushScope)})
+}
+
+// transport returns a transport for server, authenticated with the credentials for authReg.
+func (ro RegistryOpts) transport(server, authReg name.Registry, scopes []string) (http.RoundTripper, error) {
+	auth := ro.explicitAuth()
+	if auth == nil {
+		var err error
+		if auth, err = authn.DefaultKeychain.Resolve(authReg); err != nil {
+			return nil, err
+		}
+	}
+	return transport.New(server, auth, &registryTransport{ro: ro}, scopes)
+}
+
+// explicitAuth returns the credentials set by flags or environment variables, or nil if there are none.
+func (ro RegistryOpts) explicitAuth() authn.Authenticator {
+	password := ro.Password
+	if password == "" {
+		password = os.Getenv("COSIGN_REGISTRY_PASSWORD")
+	}
+	token := ro.Token
+	if token == "" {
+		token = os.Getenv("COSIGN_REGISTRY_TOKEN")
+	}
+	switch {
+	case token != "":
+		return &authn.Bearer{Token: token}
+	case ro.Username != "":
+		return &authn.Basic{Username: ro.Username, Password: password}
+	}
+	return nil
+}
+
+// registryTransport skips TLS verification for insecure registries only, and holds requests
+// back to the QPS of ro.
+type registryTransport struct {
+	ro RegistryOpts
+}
+
+func (t *registryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if l := t.ro.limiter(req.URL.Host); l != nil {
+		if err := l.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+	if t.ro.insecure(req.URL.Host) {
+		insecureOnce.Do(func() {
+			// Cloned lazily so client certificates set by ConfigureTLS are kept.
+			it := baseTransport.Clone()
+			if it.TLSClientConfig == nil {
+				it.TLSClientConfig = &tls.Config{}
+			}
+			it.TLSClientConfig.InsecureSkipVerify = true // nolint: gosec
+			insecureTransport = withRetries(it)
+		})
+		return insecureTransport.RoundTrip(req)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+var (
+	insecureOnce      sync.Once
+	insecureTransport http.RoundTripper
+)
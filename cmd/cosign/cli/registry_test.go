@@ -0,0 +1,122 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// newAuthRegistry starts a registry that requires the credentials user:pass.
+func newAuthRegistry(t *testing.T) (host string, cleanup func()) {
+	t.Helper()
+	reg := registry.New()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != "pass" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	return strings.TrimPrefix(s.URL, "http://"), s.Close
+}
+
+func TestRegistryOptsBasicAuth(t *testing.T) {
+	host, cleanup := newAuthRegistry(t)
+	defer cleanup()
+
+	imgName := host + "/test/image"
+	ref, err := name.ParseReference(imgName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	basic := remote.WithAuth(&authn.Basic{Username: "user", Password: "pass"})
+	if err := remote.Write(ref, img, basic); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	var b bytes.Buffer
+	if err := GenerateCmd(ctx, imgName, nil, &b, RegistryOpts{}); err == nil {
+		t.Error("GenerateCmd() without credentials expected error")
+	}
+	if err := GenerateCmd(ctx, imgName, nil, &b, RegistryOpts{Username: "user", Password: "pass"}); err != nil {
+		t.Errorf("GenerateCmd() with credentials: %v", err)
+	}
+
+	os.Setenv("COSIGN_REGISTRY_PASSWORD", "pass")
+	defer os.Unsetenv("COSIGN_REGISTRY_PASSWORD")
+	if err := GenerateCmd(ctx, imgName, nil, &b, RegistryOpts{Username: "user"}); err != nil {
+		t.Errorf("GenerateCmd() with password from env: %v", err)
+	}
+}
+
+func TestRegistryOptsInsecure(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "https://")
+
+	get := func(ro RegistryOpts) error {
+		resp, err := (&http.Client{Transport: &registryTransport{ro: ro}}).Get(s.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	if err := get(RegistryOpts{}); err == nil {
+		t.Error("self-signed registry expected TLS error")
+	}
+	if err := get(RegistryOpts{AllowInsecure: true}); err != nil {
+		t.Errorf("-allow-insecure-registry: %v", err)
+	}
+
+	os.Setenv("COSIGN_INSECURE_REGISTRIES", "other.example.com, "+host)
+	defer os.Unsetenv("COSIGN_INSECURE_REGISTRIES")
+	if err := get(RegistryOpts{}); err != nil {
+		t.Errorf("$COSIGN_INSECURE_REGISTRIES: %v", err)
+	}
+
+	for _, tc := range []struct {
+		ref    string
+		scheme string
+	}{
+		{"other.example.com/image", "http"},
+		{"registry.example.com/image", "https"},
+	} {
+		ref, err := RegistryOpts{}.ParseReference(tc.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ref.Context().Scheme(); got != tc.scheme {
+			t.Errorf("ParseReference(%s) scheme = %s, want %s", tc.ref, got, tc.scheme)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// Formats of the reports of batch verifications.
+const (
+	reportSARIF = "sarif"
+	reportJUnit = "junit"
+)
+
+// reportFormat returns the format of the report at path: format if it is set, otherwise JUnit
+// for .xml files and SARIF for anything else.
+func reportFormat(path, format string) (string, error) {
+	switch format {
+	case reportSARIF, reportJUnit:
+		return format, nil
+	case "":
+		if strings.EqualFold(filepath.Ext(path), ".xml") {
+			return reportJUnit, nil
+		}
+		return reportSARIF, nil
+	}
+	return "", fmt.Errorf("unsupported report format %q, use %s or %s", format, reportSARIF, reportJUnit)
+}
+
+// writeReport writes the results of a batch verification that took elapsed to path.
+func writeReport(path, format string, results []verifyResult, elapsed time.Duration) error {
+	f, err := os.Create(filepath.Clean(path))
+	if err != nil {
+		return errors.Wrap(err, "writing report")
+	}
+	if format == reportJUnit {
+		err = writeJUnit(f, results, elapsed)
+	} else {
+		err = writeSARIF(f, results)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.Wrap(err, "writing report")
+	}
+	log.Infof("Report written to %s", path)
+	return nil
+}
+
+// sarifRules describe each status an image can fail verification with.
+var sarifRules = []sarifRule{{
+	ID:               statusUnsigned,
+	ShortDescription: sarifMessage{Text: "The image has no signatures"},
+}, {
+	ID:               statusInvalid,
+	ShortDescription: sarifMessage{Text: "None of the image's signatures passed verification"},
+}, {
+	ID:               statusError,
+	ShortDescription: sarifMessage{Text: "The image couldn't be verified"},
+}}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Version        string      `json:"version,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId,omitempty"`
+	Kind      string          `json:"kind"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+	} `json:"physicalLocation"`
+}
+
+// writeSARIF writes the results as a SARIF 2.1.0 log. Images that failed are results of the rule
+// named after their status, the others are passing results.
+func writeSARIF(w io.Writer, results []verifyResult) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "cosign",
+			InformationURI: "https://github.com/sigstore/cosign",
+			Version:        VersionInfo().GitVersion,
+			Rules:          sarifRules,
+		}},
+		Results: []sarifResult{},
+	}
+	for _, res := range results {
+		sr := sarifResult{Kind: "pass", Level: "none", Message: sarifMessage{Text: res.Image + " is signed"}}
+		if res.Status != statusSigned {
+			sr = sarifResult{RuleID: res.Status, Kind: "fail", Level: "error", Message: sarifMessage{Text: res.Image + ": " + res.Error}}
+		}
+		loc := sarifLocation{}
+		loc.PhysicalLocation.ArtifactLocation.URI = res.Image
+		sr.Locations = []sarifLocation{loc}
+		run.Results = append(run.Results, sr)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	})
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+}
+
+type junitFailure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnit writes the results as a JUnit XML report, with a test case per image. Unsigned and
+// invalid images are failures, images that couldn't be verified are errors.
+func writeJUnit(w io.Writer, results []verifyResult, elapsed time.Duration) error {
+	suite := junitTestSuite{
+		Name:  "cosign verify",
+		Tests: len(results),
+		Time:  fmt.Sprintf("%.3f", elapsed.Seconds()),
+	}
+	for _, res := range results {
+		tc := junitTestCase{ClassName: "cosign.verify", Name: res.Image}
+		switch res.Status {
+		case statusSigned:
+		case statusError:
+			tc.Error = &junitFailure{Type: res.Status, Message: res.Error}
+			suite.Errors++
+		default:
+			tc.Failure = &junitFailure{Type: res.Status, Message: res.Error}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var reportResults = []verifyResult{
+	{Image: "gcr.io/acme/app:v1", Verified: true, Status: statusSigned},
+	{Image: "gcr.io/acme/app:v2", Status: statusUnsigned, Error: "no signatures found"},
+	{Image: "gcr.io/acme/app:v3", Status: statusInvalid, Error: "no matching signatures"},
+	{Image: "gcr.io/acme/app:v4", Status: statusError, Error: "UNAUTHORIZED"},
+}
+
+func TestReportFormat(t *testing.T) {
+	for _, tt := range []struct {
+		path, format, want string
+		wantErr            bool
+	}{
+		{path: "audit.sarif", want: reportSARIF},
+		{path: "results.XML", want: reportJUnit},
+		{path: "results.xml", format: reportSARIF, want: reportSARIF},
+		{path: "results.txt", format: "html", wantErr: true},
+	} {
+		got, err := reportFormat(tt.path, tt.format)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("reportFormat(%q, %q) = %q, %v, want %q", tt.path, tt.format, got, err, tt.want)
+		}
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeSARIF(&b, reportResults); err != nil {
+		t.Fatal(err)
+	}
+	got := sarifLog{}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != "2.1.0" || len(got.Runs) != 1 || len(got.Runs[0].Results) != len(reportResults) {
+		t.Fatalf("writeSARIF() = %s", b.String())
+	}
+	rules := []string{}
+	for _, r := range got.Runs[0].Results {
+		rules = append(rules, r.Kind+":"+r.RuleID)
+		if uri := r.Locations[0].PhysicalLocation.ArtifactLocation.URI; uri == "" {
+			t.Errorf("result %+v has no location", r)
+		}
+	}
+	want := []string{"pass:", "fail:unsigned", "fail:invalid", "fail:error"}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("writeSARIF() results = %v, want %v", rules, want)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeJUnit(&b, reportResults, 1500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	got := junitTestSuites{}
+	if err := xml.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Suites) != 1 {
+		t.Fatalf("writeJUnit() = %s", b.String())
+	}
+	suite := got.Suites[0]
+	if suite.Tests != 4 || suite.Failures != 2 || suite.Errors != 1 || suite.Time != "1.500" {
+		t.Errorf("writeJUnit() suite = %+v", suite)
+	}
+	if suite.Cases[0].Failure != nil || suite.Cases[0].Error != nil {
+		t.Errorf("signed image %+v failed", suite.Cases[0])
+	}
+	if f := suite.Cases[1].Failure; f == nil || f.Type != statusUnsigned || f.Message != "no signatures found" {
+		t.Errorf("unsigned image failure = %+v", f)
+	}
+	if suite.Cases[3].Error == nil {
+		t.Errorf("image that couldn't be verified = %+v, want an error", suite.Cases[3])
+	}
+}
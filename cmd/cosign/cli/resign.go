@@ -0,0 +1,184 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"strconv"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/fulcio"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// Annotations resign records in the new signatures, so rotations can be audited with "cosign verify".
+const (
+	keyGenerationAnnotation = "dev.sigstore.cosign/key-generation"
+	previousKeyAnnotation   = "dev.sigstore.cosign/previous-key-id"
+)
+
+// ResignOpts holds the settings for ResignCmd.
+type ResignOpts struct {
+	// OldKey is the public key, or KMS reference, the existing signatures must verify against.
+	OldKey string
+	// KeyRef and KmsVal are the new key to sign with.
+	KeyRef string
+	KmsVal string
+	// Generation is recorded as the key generation of the new signatures. If zero, it is one more
+	// than the generation recorded in the old signature, or 2 if there is none.
+	Generation int
+	Registry   RegistryOpts
+	// RekorURL is the address of the transparency log, see cosign.TlogServer for the default.
+	RekorURL string
+}
+
+func Resign() *ffcli.Command {
+	var (
+		flagset    = flag.NewFlagSet("cosign resign", flag.ExitOnError)
+		oldKey     = flagset.String("old-key", "", "path to the public key, or KMS reference, the existing signatures were made with")
+		key        = flagset.String("key", "", "path to the new private key")
+		kmsVal     = flagset.String("kms", "", "sign with a new private key stored in a KMS")
+		generation = flagset.Int("generation", 0, "key generation to record in the new signatures, one more than the old signature's by default")
+		input      = flagset.String("input", "", "path to a file of image references to re-sign, one per line, or - for stdin")
+		registry   = addRegistryFlags(flagset)
+		rekorURL   string
+	)
+	addRekorURLFlag(flagset, &rekorURL)
+	return &ffcli.Command{
+		Name:       "resign",
+		ShortUsage: "cosign resign -old-key <key path>|<kms uri> -key <key path>|-kms <kms uri> [-generation <n>] [-input <path>|-] <image uri>...",
+		ShortHelp:  "Re-sign images with a new key, after verifying their signatures with the old one",
+		LongHelp: `Re-sign images with a new key, after verifying their signatures with the old one.
+
+Each image must have a valid signature from the old key. It is signed again with the new key,
+keeping the annotations of the old signature, and recording the key generation and the ID of
+the old key in the "` + keyGenerationAnnotation + `" and "` + previousKeyAnnotation + `"
+annotations. The old signatures are left in place, so images stay verifiable with either key
+until the old one is retired.
+
+EXAMPLES
+  # rotate every image listed in images.txt to a new key
+  cosign resign -old-key old.pub -key new.key -input images.txt
+
+  # rotate to a key stored in Google Cloud KMS
+  cosign resign -old-key old.pub -kms gcpkms://projects/<PROJECT>/locations/global/keyRings/<KEYRING>/cryptoKeys/<KEY> <IMAGE>`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if *oldKey == "" || (*key == "" && *kmsVal == "") {
+				return flag.ErrHelp
+			}
+			if *input != "" {
+				refs, err := readImageRefs(*input)
+				if err != nil {
+					return err
+				}
+				args = append(args, refs...)
+			}
+			if len(args) == 0 {
+				return flag.ErrHelp
+			}
+			ro := ResignOpts{
+				OldKey:     *oldKey,
+				KeyRef:     *key,
+				KmsVal:     *kmsVal,
+				Generation: *generation,
+				Registry:   *registry,
+				RekorURL:   rekorURL,
+			}
+			return ResignCmd(ctx, ro, args, GetPass)
+		},
+	}
+}
+
+// ResignCmd verifies each image against the old key, and signs it with the new one.
+func ResignCmd(ctx context.Context, ro ResignOpts, imageRefs []string, pf cosign.PassFunc) error {
+	if ro.KeyRef != "" && ro.KmsVal != "" {
+		return &KeyParseError{}
+	}
+	oldKey, err := cosign.LoadPublicKey(ctx, ro.OldKey)
+	if err != nil {
+		return errors.Wrap(err, "loading old public key")
+	}
+	pub, err := oldKey.PublicKey(ctx)
+	if err != nil {
+		return err
+	}
+	oldKeyID, err := cosign.KeyFingerprint(pub)
+	if err != nil {
+		return err
+	}
+	so := SignOpts{KeyRef: ro.KeyRef, KmsVal: ro.KmsVal, Upload: true, Registry: ro.Registry, RekorURL: ro.RekorURL}
+	is, err := newImageSigner(ctx, so, pf)
+	if err != nil {
+		return err
+	}
+	co := cosign.CheckOpts{
+		Keys:               []cosign.PublicKey{oldKey},
+		ClaimVerification:  true,
+		TLog:               cosign.Experimental(),
+		RekorURL:           ro.RekorURL,
+		Roots:              fulcio.Roots,
+		RegistryClientOpts: ro.Registry.ClientOpts(ctx),
+	}
+
+	for _, img := range imageRefs {
+		ref, err := ro.Registry.ParseReference(img)
+		if err != nil {
+			return err
+		}
+		verified, err := cosign.Verify(ctx, ref, co)
+		if err != nil {
+			return errors.Wrapf(err, "verifying %s with the old key", img)
+		}
+		so.Annotations, err = resignAnnotations(verified[0], oldKeyID, ro.Generation)
+		if err != nil {
+			return errors.Wrapf(err, "reading the old signature of %s", img)
+		}
+		if err := is.sign(ctx, so, img); err != nil {
+			return errors.Wrapf(err, "signing %s", img)
+		}
+		log.Infof("Re-signed %s, key generation %s", img, so.Annotations[keyGenerationAnnotation])
+	}
+	return nil
+}
+
+// resignAnnotations carries over the annotations of the old signature, and records the rotation.
+func resignAnnotations(old cosign.VerifiedSignature, oldKeyID string, generation int) (map[string]string, error) {
+	ss := old.Claims
+	if ss == nil {
+		ss = &cosign.SimpleSigning{}
+		if err := json.Unmarshal(old.Payload, ss); err != nil {
+			return nil, err
+		}
+	}
+	annotations := map[string]string{}
+	for k, v := range ss.Optional {
+		annotations[k] = v
+	}
+	if generation == 0 {
+		generation = 2
+		if prev, err := strconv.Atoi(annotations[keyGenerationAnnotation]); err == nil {
+			generation = prev + 1
+		}
+	}
+	annotations[keyGenerationAnnotation] = strconv.Itoa(generation)
+	annotations[previousKeyAnnotation] = oldKeyID
+	return annotations, nil
+}
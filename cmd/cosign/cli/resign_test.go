@@ -0,0 +1,110 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/zalando/go-keyring"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+func TestResignCmd(t *testing.T) {
+	keyring.MockInit()
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	imgs := []string{}
+	for _, repo := range []string{"signed", "unsigned"} {
+		ref, err := name.ParseReference(host + "/" + repo + ":latest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := random.Image(10, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+		imgs = append(imgs, ref.String())
+	}
+
+	td, err := ioutil.TempDir("", "cosign-resign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	pass := func(bool) ([]byte, error) { return []byte("hunter2"), nil }
+	writeKeys := func(prefix string) (string, string) {
+		keys, err := cosign.GenerateKeyPair(pass)
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv, pub := filepath.Join(td, prefix+".key"), filepath.Join(td, prefix+".pub")
+		if err := ioutil.WriteFile(priv, keys.PrivateBytes, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(pub, keys.PublicBytes, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return priv, pub
+	}
+	oldPriv, oldPub := writeKeys("old")
+	newPriv, newPub := writeKeys("new")
+
+	so := SignOpts{KeyRef: oldPriv, Upload: true, Annotations: map[string]string{"team": "infra"}}
+	if err := SignCmd(ctx, so, imgs[0], pass); err != nil {
+		t.Fatal(err)
+	}
+	if err := ResignCmd(ctx, ResignOpts{OldKey: oldPub, KeyRef: newPriv}, imgs[:1], pass); err != nil {
+		t.Fatal(err)
+	}
+
+	ref, err := name.ParseReference(imgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := cosign.LoadPublicKey(ctx, newPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified, err := cosign.Verify(ctx, ref, cosign.CheckOpts{Keys: []cosign.PublicKey{newKey}, ClaimVerification: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := verified[0].Claims.Optional
+	if got["team"] != "infra" || got[keyGenerationAnnotation] != "2" || got[previousKeyAnnotation] == "" {
+		t.Errorf("re-signed annotations = %v, want the old ones and the rotation", got)
+	}
+
+	// Images without a signature from the old key aren't re-signed.
+	if err := ResignCmd(ctx, ResignOpts{OldKey: oldPub, KeyRef: newPriv}, imgs[1:], pass); err == nil {
+		t.Error("expected error re-signing an image the old key didn't sign")
+	}
+}
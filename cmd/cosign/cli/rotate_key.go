@@ -0,0 +1,86 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/kms"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+func RotateKey() *ffcli.Command {
+	var (
+		flagset = flag.NewFlagSet("cosign rotate-key", flag.ExitOnError)
+		kmsVal  = flagset.String("kms", "", "rotate the key pair in this KMS service")
+		outFile = flagset.String("outfile", "cosign.pub", "file to write the new public key to")
+	)
+
+	return &ffcli.Command{
+		Name:       "rotate-key",
+		ShortUsage: "cosign rotate-key -kms KMSPATH [-outfile <path>]",
+		ShortHelp:  "rotate-key replaces a KMS key with a new one",
+		LongHelp: `rotate-key creates a new key in the KMS, which is signed with from then on,
+and writes its public key to cosign.pub.
+
+Google Cloud KMS keys get a new key version, the previous versions are left enabled.
+TPM keys are replaced at their handle, and the previous key is destroyed.
+Keep the previous public key to verify the signatures made with it.
+
+EXAMPLES:
+  # create a new version of a key in Google Cloud KMS
+  cosign rotate-key -kms gcpkms://projects/[PROJECT]/locations/global/keyRings/[KEYRING]/cryptoKeys/[KEY]
+
+  # replace the key in the local TPM 2.0, keeping the new public key apart from the previous one
+  cosign rotate-key -kms tpm://0x81000100 -outfile cosign-2.pub`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if *kmsVal == "" {
+				return &KeyParseError{}
+			}
+			return RotateKeyCmd(ctx, *kmsVal, *outFile)
+		},
+	}
+}
+
+// RotateKeyCmd rotates the KMS key at kmsVal, writing the new public key to outFile.
+func RotateKeyCmd(ctx context.Context, kmsVal, outFile string) error {
+	k, err := kms.Get(ctx, kmsVal)
+	if err != nil {
+		return err
+	}
+	pubKey, err := k.RotateKey(ctx)
+	if err != nil {
+		return errors.Wrap(err, "rotating key")
+	}
+	pemBytes, err := cosign.KeyToPem(pubKey)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(outFile, pemBytes, 0600); err != nil {
+		return err
+	}
+	if keyID, err := k.KeyID(ctx); err == nil {
+		log.Infof("Signing with %s from now on", keyID)
+	}
+	log.Infof("Public key written to %s", outFile)
+	return nil
+}
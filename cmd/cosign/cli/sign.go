@@ -15,8 +15,13 @@
 package cli
 
 import (
+	"bytes"
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -26,14 +31,20 @@ import (
 
 	"github.com/sigstore/cosign/pkg/cosign/fulcio"
 
-	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/peterbourgon/ff/v3/ffcli"
 	"github.com/pkg/errors"
 
 	"github.com/sigstore/cosign/pkg/cosign"
 	"github.com/sigstore/cosign/pkg/cosign/kms"
+	"github.com/sigstore/cosign/pkg/cosign/kms/gcp"
+	"github.com/sigstore/cosign/pkg/cosign/kms/plugin"
+	"github.com/sigstore/cosign/pkg/cosign/kubernetes"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+	"github.com/sigstore/cosign/pkg/cosign/signservice"
+	"github.com/sigstore/cosign/pkg/cosign/spiffe"
 )
 
 type annotationsMap struct {
@@ -67,14 +78,29 @@ func Sign() *ffcli.Command {
 		key         = flagset.String("key", "", "path to the private key")
 		kmsVal      = flagset.String("kms", "", "sign via a private key stored in a KMS")
 		upload      = flagset.Bool("upload", true, "whether to upload the signature")
-		payloadPath = flagset.String("payload", "", "path to a payload file to use rather than generating one.")
-		force       = flagset.Bool("f", false, "skip warnings and confirmations")
+		payloadPath = flagset.String("payload", "", "path to a simple signing payload file to use rather than generating one, its docker-manifest-digest must be the image's")
+		force       = flagset.Bool("f", false, "skip warnings and confirmations, and push a new signature even if the image already has one of the same payload with the same key")
+		bundle      = flagset.String("bundle", "", "write a self-contained bundle of the signature and its verification material to this path")
+		input       = flagset.String("input", "", "path to a file of image references to sign, one per line, or - for stdin")
+		buildkitMD  = flagset.String("buildkit-metadata", "", "path to the metadata file of a BuildKit build, written by \"docker buildx build --metadata-file\", to sign the images it pushed by digest")
+		outputSig   = flagset.String("output-signature", "", "also write the base64 encoded signature to this path")
+		outputCert  = flagset.String("output-certificate", "", "also write the Fulcio certificate, and its chain, to this path in keyless mode")
+		dryRun      = flagset.Bool("dry-run", false, "sign, but only print what would be uploaded instead of writing to the registry or transparency log")
+		spiffeSVID  = flagset.Bool("spiffe", false, "sign with the workload's X.509-SVID from the SPIFFE Workload API at $"+spiffe.SocketEnv)
+		hwAttest    = flagset.String("hardware-attestation", "", "path to the PEM attestation certificate of the key, followed by its chain, to record that the key was generated on a YubiKey or TPM")
+		compress    = flagset.Bool("compress", false, "store the signed payload zstd compressed, for payloads with large annotations")
+		signSvc     = flagset.String("signing-service", "", "URL of a remote signing service to send payload digests to, authenticated with the token in $"+signservice.TokenEnv+" or the -client-cert")
 		annotations = annotationsMap{}
+		registry    = addRegistryFlags(flagset)
+		digest      = addDigestFlags(flagset)
+		outputs     = addOutputFlags(flagset)
+		rekorURL    string
 	)
 	flagset.Var(&annotations, "a", "extra key=value pairs to sign")
+	addRekorURLFlag(flagset, &rekorURL)
 	return &ffcli.Command{
 		Name:       "sign",
-		ShortUsage: "cosign sign -key <key> [-payload <path>] [-a key=value] [-upload=true|false] [-f] <image uri>",
+		ShortUsage: "cosign sign -key <key> [-payload <path>] [-a key=value] [-upload=true|false] [-bundle <path>] [-output-signature <path>] [-output-certificate <path>] [-input <path>|-] [-buildkit-metadata <path>] [-output-file <path>] [-f] [-dry-run] [-spiffe] [-hardware-attestation <path>] [-compress] [-signing-service <url>] <image uri>...",
 		ShortHelp:  `Sign the supplied container image.`,
 		LongHelp: `Sign the supplied container image.
 
@@ -88,107 +114,421 @@ EXAMPLES
   # sign a container image and add annotations
   cosign sign -key cosign.pub -a key1=value1 -a key2=value2 <IMAGE>
 
+  # sign several images at once, the key is only loaded (or the certificate requested) once
+  cosign sign -key cosign.key <IMAGE> <IMAGE>...
+  cosign sign -key cosign.key -input images.txt
+
+  # record the signature in a staging transparency log instead of the default one (experimental)
+  COSIGN_EXPERIMENTAL=1 cosign sign -key cosign.key -rekor-url https://rekor.staging.example.com <IMAGE>
+
+  # sign a container image and also write the signature to a bundle file
+  cosign sign -key cosign.key -bundle signature.bundle <IMAGE>
+
+  # keep a copy of the signature and certificate outside the registry
+  COSIGN_EXPERIMENTAL=1 cosign sign -output-signature image.sig -output-certificate image.crt <IMAGE>
+
+  # only sign images referenced by digest, as pipelines that pin digests should
+  cosign sign -key cosign.key -require-digest <IMAGE>@sha256:<DIGEST>
+
+  # record the digests the tags resolved to, and were signed
+  cosign sign -key cosign.key -output-digest-file signed.txt <IMAGE>
+
+  # sign a payload written by another tool, e.g. with extra critical fields for containers/image policies
+  cosign sign -key cosign.key -payload payload.json <IMAGE>
+
+  # write the digest, signature and transparency log index as key=value lines for the next steps,
+  # GitHub Actions steps get them as step outputs without it
+  cosign sign -key cosign.key -output-file results.env <IMAGE>
+
+  # check the key, payload and destination without pushing anything
+  cosign sign -key cosign.key -dry-run <IMAGE>
+
+  # sign the images a "docker buildx build --push --metadata-file build.json" pushed, by digest
+  cosign sign -key cosign.key -buildkit-metadata build.json
+
+  # sign an image in the local docker daemon, or containerd, before pushing it to gcr.io/acme/app
+  cosign sign -key cosign.key docker://gcr.io/acme/app:dev
+  cosign sign -key cosign.key containerd://gcr.io/acme/app:dev
+
   # sign a container image with a key pair stored in Google Cloud KMS
-  cosign sign -kms gcpkms://projects/<PROJECT>/locations/global/keyRings/<KEYRING>/cryptoKeys/<KEY> <IMAGE>`,
+  cosign sign -kms gcpkms://projects/<PROJECT>/locations/global/keyRings/<KEYRING>/cryptoKeys/<KEY> <IMAGE>
+
+  # sign with a specific version of a Google Cloud KMS key, instead of the newest enabled one
+  cosign sign -kms gcpkms://projects/<PROJECT>/locations/global/keyRings/<KEYRING>/cryptoKeys/<KEY>/cryptoKeyVersions/<VERSION> <IMAGE>
+
+  # sign a container image with the SPIFFE identity SPIRE issued the workload
+  SPIFFE_ENDPOINT_SOCKET=unix:///tmp/spire-agent/public/api.sock cosign sign -spiffe <IMAGE>
+
+  # sign a container image with a key held in the local TPM, see "cosign generate-key-pair -kms tpm://..."
+  cosign sign -kms tpm://0x81000100 <IMAGE>
+
+  # record the attestation that the TPM generated the key, issued by the TPM's attestation CA
+  cosign sign -kms tpm://0x81000100 -hardware-attestation attestation.pem <IMAGE>
+
+  # sign with an external signer plugin, the cosign-signer-<NAME> binary on the $PATH
+  cosign sign -key plugin://<NAME> <IMAGE>
+
+  # sign with the key of a remote signing service, which returns the signature and its certificate
+  COSIGN_SIGNING_SERVICE_TOKEN=<TOKEN> cosign sign -signing-service https://signer.example.com <IMAGE>`,
 		FlagSet: flagset,
 		Exec: func(ctx context.Context, args []string) error {
 			// A key file (or kms address) is required unless we're in experimental mode!
-			if !cosign.Experimental() {
+			if !cosign.Experimental() && !*spiffeSVID && *signSvc == "" {
 				if *key == "" && *kmsVal == "" {
 					return &KeyParseError{}
 				}
 			}
+			if *input != "" {
+				refs, err := readImageRefs(*input)
+				if err != nil {
+					return err
+				}
+				args = append(args, refs...)
+			}
+			if *buildkitMD != "" {
+				refs, err := buildkitImages(*buildkitMD)
+				if err != nil {
+					return err
+				}
+				args = append(args, refs...)
+			}
 			if len(args) == 0 {
 				return flag.ErrHelp
 			}
-
-			for _, img := range args {
-				if err := SignCmd(ctx, *key, img, *upload, *payloadPath, annotations.annotations, *kmsVal, GetPass, *force); err != nil {
-					return errors.Wrapf(err, "signing %s", img)
+			if *bundle != "" && len(args) > 1 {
+				return errors.New("a bundle can only be written when signing a single image")
+			}
+			if (*outputSig != "" || *outputCert != "") && len(args) > 1 {
+				return errors.New("the signature and certificate can only be written when signing a single image")
+			}
+			if *outputCert != "" && (*key != "" || *kmsVal != "") {
+				return errors.New("-output-certificate is only supported when signing without a key")
+			}
+			if *signSvc != "" && (*key != "" || *kmsVal != "" || *spiffeSVID) {
+				return errors.New("-signing-service can't be used with -key, -kms or -spiffe")
+			}
+			var spiffeSocket string
+			if *spiffeSVID {
+				if *key != "" || *kmsVal != "" {
+					return errors.New("-spiffe can't be used with -key or -kms")
+				}
+				var err error
+				if spiffeSocket, err = spiffe.Socket(); err != nil {
+					return err
 				}
 			}
-			return nil
+
+			so := SignOpts{
+				KeyRef:              *key,
+				KmsVal:              *kmsVal,
+				Upload:              *upload,
+				PayloadPath:         *payloadPath,
+				Annotations:         annotations.annotations,
+				Force:               *force,
+				Bundle:              *bundle,
+				OutputSignature:     *outputSig,
+				OutputCertificate:   *outputCert,
+				DryRun:              *dryRun,
+				Registry:            *registry,
+				Digest:              digest,
+				Outputs:             outputs,
+				SPIFFESocket:        spiffeSocket,
+				RekorURL:            rekorURL,
+				HardwareAttestation: *hwAttest,
+				Compress:            *compress,
+				SigningService:      *signSvc,
+			}
+			return SignImagesCmd(ctx, so, args, GetPass)
 		},
 	}
 }
 
-func SignCmd(ctx context.Context, keyPath string,
-	imageRef string, upload bool, payloadPath string,
-	annotations map[string]string, kmsVal string, pf cosign.PassFunc, force bool) error {
+// SignOpts holds the settings for SignCmd.
+type SignOpts struct {
+	KeyRef      string
+	KmsVal      string
+	Upload      bool
+	PayloadPath string
+	Annotations map[string]string
+	Force       bool
+	// Bundle is a path to write a self-contained bundle of the signature to.
+	Bundle string
+	// OutputSignature is a path to also write the base64 encoded signature to.
+	OutputSignature string
+	// OutputCertificate is a path to also write the signing certificate and chain to, when signing without a key.
+	OutputCertificate string
+	// DryRun signs and prints what would be uploaded, without writing anything.
+	DryRun bool
+	// Registry holds the credentials used to talk to the registry.
+	Registry RegistryOpts
+	// Digest enforces digest references, and records the digests that were signed.
+	Digest *DigestOpts
+	// Outputs records the results for the workflow steps that follow.
+	Outputs *StepOutputs
+	// SPIFFESocket, if set, is the address of the SPIFFE Workload API to sign with the X.509-SVID of.
+	SPIFFESocket string
+	// RekorURL is the address of the transparency log, see cosign.TlogServer for the default.
+	RekorURL string
+	// HardwareAttestation is a path to the PEM attestation certificate of the key, and its chain, to record
+	// with the signatures. See cosign.VerifyHardwareAttestation.
+	HardwareAttestation string
+	// Compress stores the payload zstd compressed, see cosign.SignatureMetadata.
+	Compress bool
+	// SigningService, if set, is the URL of a remote signing service to sign with, see signservice.Client.
+	SigningService string
+}
 
-	if keyPath != "" && kmsVal != "" {
-		return &KeyParseError{}
-	}
+// SignCmd signs the image at imageRef. Hooks set on ctx with cosign.WithHooks are told about
+// every step.
+func SignCmd(ctx context.Context, so SignOpts, imageRef string, pf cosign.PassFunc) error {
+	return SignImagesCmd(ctx, so, []string{imageRef}, pf)
+}
 
-	ref, err := name.ParseReference(imageRef)
-	if err != nil {
-		return errors.Wrap(err, "parsing reference")
+// SignImagesCmd signs each of the images in turn. The key is loaded, or the keyless
+// certificate requested, only once for all of them.
+func SignImagesCmd(ctx context.Context, so SignOpts, imageRefs []string, pf cosign.PassFunc) error {
+	if so.KeyRef != "" && so.KmsVal != "" {
+		return &KeyParseError{}
 	}
-	get, err := remote.Get(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	is, err := newImageSigner(ctx, so, pf)
 	if err != nil {
-		return errors.Wrap(err, "getting remote image")
+		return err
 	}
-	// The payload can be specified via a flag to skip generation.
-	var payload []byte
-	if payloadPath != "" {
-		fmt.Fprintln(os.Stderr, "Using payload from:", payloadPath)
-		payload, err = ioutil.ReadFile(filepath.Clean(payloadPath))
-	} else {
-		payload, err = (&cosign.ImagePayload{Img: get.Descriptor, Annotations: annotations}).MarshalJSON()
+	for _, img := range imageRefs {
+		if err := is.sign(ctx, so, img); err != nil {
+			return errors.Wrapf(err, "signing %s", img)
+		}
 	}
-	if err != nil {
-		return errors.Wrap(err, "payload")
+	if err := so.Digest.write(); err != nil {
+		return err
 	}
+	return so.Outputs.write()
+}
 
-	var signer cosign.Signer
-	var signature []byte
-	var pemBytes []byte
-	var cert, chain string
+// imageSigner holds everything about the signer that can be shared between images.
+type imageSigner struct {
+	signer   cosign.SignerVerifier
+	pemBytes []byte
+	cert     string
+	chain    string
+	keyID    string
+	// sct is the certificate transparency log's timestamp for a keyless certificate.
+	sct *fulcio.SCT
+	// hwAttestation is the PEM attestation chain of the key.
+	hwAttestation string
+	// gcpKeyVersion is the resource name of the GCP KMS key version signing.
+	gcpKeyVersion string
+}
+
+func newImageSigner(ctx context.Context, so SignOpts, pf cosign.PassFunc) (*imageSigner, error) {
+	so.KeyRef, so.KmsVal = pluginKey(so.KeyRef, so.KmsVal)
+	is := &imageSigner{}
 	switch {
-	case kmsVal != "":
-		k, err := kms.Get(ctx, kmsVal)
+	case so.SPIFFESocket != "":
+		svid, err := spiffe.FetchX509SVID(ctx, so.SPIFFESocket)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		signer = k
+		// Signatures with certificates are only verified with ECDSA keys, SPIRE's default.
+		if _, ok := svid.PrivateKey.Public().(*ecdsa.PublicKey); !ok {
+			return nil, fmt.Errorf("the X.509-SVID of %s has an unsupported %T key, it must be ECDSA", svid.ID, svid.PrivateKey.Public())
+		}
+		is.signer, err = cosign.WithCryptoSigner(svid.PrivateKey)
 		if err != nil {
-			return errors.Wrap(err, "getting public key")
+			return nil, errors.Wrap(err, "loading X.509-SVID key")
 		}
-		pemBytes, err = cosign.PublicKeyPem(ctx, k)
+		log.Infof("Signing as %s", svid.ID)
+		// Like Fulcio's, the chain ends in the roots, here the trust domain's bundle.
+		is.cert = pemChain(svid.Certificates[:1])
+		is.chain = pemChain(append(svid.Certificates[1:], svid.Bundle...))
+		is.pemBytes = []byte(is.cert)
+	case so.SigningService != "":
+		s, err := signservice.NewSigner(ctx, &signservice.Client{Server: so.SigningService, Token: os.Getenv(signservice.TokenEnv)})
 		if err != nil {
-			return err
+			return nil, err
 		}
-	case keyPath != "":
-		k, err := loadKey(keyPath, pf)
-		signer = k
+		is.signer = s
+		is.cert, is.chain = s.Cert, s.Chain
+		is.pemBytes = []byte(is.cert)
+	case so.KmsVal != "":
+		k, err := kms.Get(ctx, so.KmsVal)
 		if err != nil {
-			return errors.Wrap(err, "signing payload")
+			return nil, err
 		}
-		pemBytes, err = cosign.PublicKeyPem(ctx, k)
+		is.signer = k
+		is.pemBytes, err = cosign.PublicKeyPem(ctx, k)
 		if err != nil {
-			return err
+			return nil, err
+		}
+	case so.KeyRef != "":
+		k, err := loadKey(ctx, so.KeyRef, pf)
+		if err != nil {
+			return nil, errors.Wrap(err, "signing payload")
+		}
+		is.signer = k
+		is.pemBytes, err = cosign.PublicKeyPem(ctx, k)
+		if err != nil {
+			return nil, err
 		}
 	default: // Keyless!
-		fmt.Fprintln(os.Stderr, "Generating ephemeral keys...")
+		log.Infof("Generating ephemeral keys...")
 		priv, err := cosign.GeneratePrivateKey()
 		if err != nil {
-			return errors.Wrap(err, "generating cert")
+			return nil, errors.Wrap(err, "generating cert")
+		}
+		is.signer = cosign.WithECDSAKey(priv)
+		log.Infof("Retrieving signed certificate...")
+		is.cert, is.chain, err = fulcio.GetCert(ctx, priv) // TODO, use the chain.
+		if err != nil {
+			return nil, errors.Wrap(err, "retrieving cert")
+		}
+		is.pemBytes = []byte(is.cert)
+		is.sct, err = certSCT(ctx, is.cert+is.chain)
+		if err != nil {
+			// Only "cosign verify -verify-ct" needs it, signing can still go ahead.
+			log.Warnf("Could not get the certificate transparency log's SCT for the certificate: %v", err)
+		}
+	}
+	keyID, err := is.signer.KeyID(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting key id")
+	}
+	is.keyID = keyID
+	if strings.HasPrefix(so.KmsVal, gcp.ReferenceScheme) {
+		// GCP KMS key IDs are the resource names of key versions.
+		is.gcpKeyVersion = keyID
+		log.Infof("Signing with GCP KMS key version %s", keyID)
+	}
+	if so.HardwareAttestation != "" {
+		if is.hwAttestation, err = loadHardwareAttestation(ctx, so.HardwareAttestation, is.signer); err != nil {
+			return nil, err
+		}
+	}
+	return is, nil
+}
+
+// loadHardwareAttestation reads the attestation chain at path, checking that it is for the key of signer.
+// The chain is verified against the vendor roots when the signature is.
+func loadHardwareAttestation(ctx context.Context, path string, signer cosign.PublicKey) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	certs, err := cosign.LoadCerts(string(b))
+	if err != nil || len(certs) == 0 {
+		return "", fmt.Errorf("no certificates found in %s", path)
+	}
+	pub, err := signer.PublicKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	if k, ok := pub.(interface{ Equal(crypto.PublicKey) bool }); !ok || !k.Equal(certs[0].PublicKey) {
+		return "", fmt.Errorf("the attestation in %s is for a different key than the one signing", path)
+	}
+	return pemChain(certs), nil
+}
+
+func (is *imageSigner) sign(ctx context.Context, so SignOpts, imageRef string) (err error) {
+	ref, get, err := so.Registry.parseImage(ctx, imageRef)
+	if err != nil {
+		return errors.Wrap(err, "parsing reference")
+	}
+	if err := so.Digest.check(ref); err != nil {
+		return err
+	}
+	// Local images are signed before they're pushed, only their descriptor is known.
+	if get == nil {
+		if get, err = remote.Get(ref, so.Registry.ClientOpts(ctx)...); err != nil {
+			return errors.Wrap(err, "getting remote image")
+		}
+	}
+	so.Digest.record(ref, get.Digest)
+	// The payload can be specified via a flag to skip generation.
+	var payload []byte
+	if so.PayloadPath != "" {
+		log.Infof("Using payload from: %s", so.PayloadPath)
+		payload, err = ioutil.ReadFile(filepath.Clean(so.PayloadPath))
+		if err == nil {
+			err = cosign.ValidatePayload(payload, get.Digest)
+		}
+	} else {
+		var annotations map[string]string
+		annotations, err = imageAnnotations(get, is.annotations(so.Annotations))
+		if err != nil {
+			return err
+		}
+		payload, err = (&cosign.ImagePayload{Img: get.Descriptor, Annotations: annotations, Reference: ref.Context().Name()}).MarshalJSON()
+	}
+	if err != nil {
+		return errors.Wrap(err, "payload")
+	}
+	hooks := cosign.HooksFrom(ctx)
+	ev := &cosign.SigningEvent{
+		Image:     ref.Context().Digest(get.Digest.String()),
+		Payload:   payload,
+		Cert:      is.cert,
+		Chain:     is.chain,
+		KeyID:     is.keyID,
+		Algorithm: is.signer.Algorithm(),
+	}
+	if err := hooks.OnPayloadGenerated(ctx, ev); err != nil {
+		return errors.Wrap(err, "payload hook")
+	}
+	var sigDigest string
+	defer func() {
+		if err == nil {
+			so.Outputs.addSigning(ev, sigDigest)
 		}
-		signer = cosign.WithECDSAKey(priv)
-		fmt.Fprintln(os.Stderr, "Retrieving signed certificate...")
-		cert, chain, err = fulcio.GetCert(ctx, priv) // TODO, use the chain.
+	}()
+
+	// Re-running a pipeline shouldn't keep adding signatures that say the same thing.
+	if so.Upload && so.Bundle == "" && !so.Force && !so.DryRun {
+		signed, err := alreadySigned(ctx, ref, get.Descriptor, payload, is.signer, so.Registry)
 		if err != nil {
-			return errors.Wrap(err, "retrieving cert")
+			return err
+		}
+		if signed {
+			log.Infof("%s already has a signature of this payload with this key, skipping. Use -f to push another.", imageRef)
+			return nil
 		}
-		pemBytes = []byte(cert)
 	}
 
-	signature, err = signer.Sign(ctx, payload)
+	log.Debugf("signing payload sha256:%x for %s", sha256.Sum256(payload), imageRef)
+	signature, err := is.signer.Sign(ctx, payload)
 	if err != nil {
 		return errors.Wrap(err, "signing")
 	}
+	ev.Signature = signature
+	if err := hooks.OnSigned(ctx, ev); err != nil {
+		return errors.Wrap(err, "signed hook")
+	}
+
+	if so.DryRun {
+		return is.printDryRun(ref, get, payload, signature)
+	}
+
+	// Like the bundle, these are written before the upload so they're kept if it fails.
+	if so.OutputSignature != "" {
+		if err := writeOutput(so.OutputSignature, []byte(base64.StdEncoding.EncodeToString(signature)), "Signature"); err != nil {
+			return err
+		}
+	}
+	if so.OutputCertificate != "" && is.cert != "" {
+		if err := writeOutput(so.OutputCertificate, []byte(is.cert+is.chain), "Certificate"); err != nil {
+			return err
+		}
+	}
+
+	var bundle *cosign.Bundle
+	if so.Bundle != "" {
+		bundle = cosign.NewPayloadBundle(signature, payload, is.pemBytes, is.chain)
+		// Write it out now in case the upload fails, we'll add the tlog entry later if there is one.
+		if err := writeBundle(so.Bundle, bundle); err != nil {
+			return err
+		}
+	}
 
-	if !upload {
+	if !so.Upload {
 		fmt.Println(base64.StdEncoding.EncodeToString(signature))
 		return nil
 	}
@@ -199,18 +539,39 @@ func SignCmd(ctx context.Context, keyPath string,
 		return err
 	}
 
-	fmt.Fprintln(os.Stderr, "Pushing signature to:", dstRef.String())
+	log.Infof("Pushing signature to: %s", dstRef.String())
 
-	if err := cosign.Upload(signature, payload, dstRef, string(cert), string(chain)); err != nil {
+	md := cosign.SignatureMetadata{
+		Cert:                is.cert,
+		Chain:               is.chain,
+		KeyID:               is.keyID,
+		Algorithm:           is.signer.Algorithm(),
+		SCT:                 is.sct,
+		HardwareAttestation: is.hwAttestation,
+		Compress:            so.Compress,
+	}
+	if err := cosign.Upload(ctx, signature, payload, dstRef, md, so.Registry.ClientOpts(ctx)...); err != nil {
 		return err
 	}
+	ev.SignatureRef = dstRef
+	if so.Outputs != nil {
+		// The signature image's digest changes with every signature added to it.
+		if desc, err := remote.Head(dstRef, so.Registry.ClientOpts(ctx)...); err == nil {
+			sigDigest = desc.Digest.String()
+		} else {
+			log.Warnf("Could not get the digest of %s: %v", dstRef, err)
+		}
+	}
+	if err := hooks.OnUploaded(ctx, ev); err != nil {
+		return errors.Wrap(err, "uploaded hook")
+	}
 
 	if !cosign.Experimental() {
 		return nil
 	}
 
 	// Check if the image is public (no auth in Get)
-	if !force {
+	if !so.Force {
 		if _, err := remote.Get(ref); err != nil {
 			fmt.Print("warning: uploading to the public transparency log for a private image, please confirm [Y/N]: ")
 			var response string
@@ -223,22 +584,183 @@ func SignCmd(ctx context.Context, keyPath string,
 			}
 		}
 	}
-	index, err := cosign.UploadTLog(signature, payload, pemBytes)
+	entry, err := cosign.UploadTLog(ctx, so.RekorURL, signature, payload, is.pemBytes)
 	if err != nil {
 		return err
 	}
-	fmt.Println("tlog entry created with index: ", index)
+	fmt.Println(entry)
+	if bundle != nil {
+		bundle.VerificationMaterial.TlogEntry = &cosign.TlogInfo{LogIndex: entry.Index(), LogURL: entry.LogURL}
+		if err := writeBundle(so.Bundle, bundle); err != nil {
+			return err
+		}
+	}
+	ev.TlogEntry = entry
+	return errors.Wrap(hooks.OnTlogEntry(ctx, ev), "tlog entry hook")
+}
+
+// imageAnnotations returns the annotations to sign for the image. Helm charts get claims about
+// their name and version, which "cosign verify -type helm" checks.
+// annotations adds what the signer records about its key to the payload annotations.
+func (is *imageSigner) annotations(annotations map[string]string) map[string]string {
+	if is.gcpKeyVersion == "" {
+		return annotations
+	}
+	out := map[string]string{gcp.KeyVersionAnnotation: gcp.ReferenceScheme + is.gcpKeyVersion}
+	for k, v := range annotations {
+		out[k] = v
+	}
+	return out
+}
+
+func imageAnnotations(get *remote.Descriptor, annotations map[string]string) (map[string]string, error) {
+	// The manifest of local images isn't read, they aren't helm charts.
+	if get.Manifest == nil {
+		return annotations, nil
+	}
+	chart, err := cosign.FetchHelmChart(get)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading helm chart")
+	}
+	if chart == nil {
+		return annotations, nil
+	}
+	out := chart.Annotations()
+	for k, v := range annotations {
+		out[k] = v
+	}
+	return out, nil
+}
+
+// dryRunSignature is printed by sign -dry-run in place of uploading the signature.
+type dryRunSignature struct {
+	Image     string          `json:"image"`
+	Tag       string          `json:"tag"`
+	Payload   json.RawMessage `json:"payload"`
+	Signature string          `json:"signature"`
+	Cert      string          `json:"cert,omitempty"`
+	Chain     string          `json:"chain,omitempty"`
+	KeyID     string          `json:"keyid,omitempty"`
+	Algorithm string          `json:"algorithm,omitempty"`
+	SCT       *fulcio.SCT     `json:"sct,omitempty"`
+	// TlogEntry is only set in experimental mode, when sign would upload to the transparency log.
+	TlogEntry interface{} `json:"tlogEntry,omitempty"`
+}
+
+func (is *imageSigner) printDryRun(ref name.Reference, get *remote.Descriptor, payload, signature []byte) error {
+	dstRef, err := cosign.DestinationRef(ref, get)
+	if err != nil {
+		return err
+	}
+	out := dryRunSignature{
+		Image:     ref.Context().Digest(get.Digest.String()).String(),
+		Tag:       dstRef.String(),
+		Payload:   payload,
+		Signature: base64.StdEncoding.EncodeToString(signature),
+		Cert:      is.cert,
+		Chain:     is.chain,
+		KeyID:     is.keyID,
+		Algorithm: is.signer.Algorithm(),
+		SCT:       is.sct,
+	}
+	if cosign.Experimental() {
+		out.TlogEntry = cosign.TlogProposedEntry(signature, payload, is.pemBytes)
+	}
+	log.Infof("Dry run, nothing was uploaded.")
+	return printJSON(os.Stdout, out)
+}
+
+// certSCT submits the Fulcio certificate, and its chain, to Fulcio's certificate transparency log.
+// Fulcio logs the certificates it issues, so this returns the SCT of the existing entry.
+func certSCT(ctx context.Context, certPem string) (*fulcio.SCT, error) {
+	certs, err := cosign.LoadCerts(certPem)
+	if err != nil {
+		return nil, err
+	}
+	return fulcio.AddChain(ctx, fulcio.CTLogServer(), certs)
+}
+
+// alreadySigned reports whether the image with desc has a signature over payload made with key.
+func alreadySigned(ctx context.Context, ref name.Reference, desc v1.Descriptor, payload []byte, key cosign.PublicKey, ro RegistryOpts) (bool, error) {
+	sps, err := cosign.FetchSignaturesFor(ctx, ref, desc, ro.ClientOpts(ctx)...)
+	if err != nil {
+		if errors.Is(err, cosign.ErrNoSignatures) {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, sp := range sps {
+		if bytes.Equal(sp.Payload, payload) && sp.VerifyKey(ctx, key) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// writeOutput writes one of the signing artifacts kept outside the registry.
+func writeOutput(path string, b []byte, what string) error {
+	if err := ioutil.WriteFile(filepath.Clean(path), b, 0644); err != nil {
+		return errors.Wrapf(err, "writing %s", strings.ToLower(what))
+	}
+	log.Infof("%s written to %s", what, path)
 	return nil
 }
 
-func loadKey(keyPath string, pf cosign.PassFunc) (*cosign.ECDSAKey, error) {
+func writeBundle(path string, bundle *cosign.Bundle) error {
+	b, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Clean(path), b, 0644); err != nil {
+		return err
+	}
+	log.Infof("Bundle written to %s", path)
+	return nil
+}
+
+// pluginKey moves a signer plugin reference passed as -key to -kms, which is what loads it.
+func pluginKey(keyRef, kmsVal string) (string, string) {
+	if kmsVal == "" && strings.HasPrefix(keyRef, plugin.ReferenceScheme) {
+		return "", keyRef
+	}
+	return keyRef, kmsVal
+}
+
+func loadKey(ctx context.Context, keyPath string, pf cosign.PassFunc) (*cosign.ECDSAKey, error) {
+	if kubernetes.IsRef(keyPath) {
+		return loadKubernetesKey(ctx, keyPath, pf)
+	}
 	kb, err := ioutil.ReadFile(filepath.Clean(keyPath))
 	if err != nil {
 		return nil, err
 	}
-	pass, err := pf(false)
+	pass, err := keyPass(keyPath, pf)
+	if err != nil {
+		return nil, err
+	}
+	return cosign.LoadPrivateKey(kb, pass)
+}
+
+// loadKubernetesKey loads a private key from a Kubernetes secret. Unless $COSIGN_PASSWORD is set,
+// its password is the secret's cosign.password, if it has one.
+func loadKubernetesKey(ctx context.Context, ref string, pf cosign.PassFunc) (*cosign.ECDSAKey, error) {
+	sr, err := kubernetes.ParseRef(ref)
 	if err != nil {
 		return nil, err
 	}
+	data, err := kubernetes.Secret(ctx, sr)
+	if err != nil {
+		return nil, err
+	}
+	kb, ok := data[sr.KeyOr(kubernetes.PrivateKey)]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s has no key %q", sr.Namespace, sr.Name, sr.KeyOr(kubernetes.PrivateKey))
+	}
+	pass, ok := data[kubernetes.Password]
+	if _, set := os.LookupEnv("COSIGN_PASSWORD"); set || !ok {
+		if pass, err = pf(false); err != nil {
+			return nil, err
+		}
+	}
 	return cosign.LoadPrivateKey(kb, pass)
 }
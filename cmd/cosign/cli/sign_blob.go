@@ -15,31 +15,49 @@
 package cli
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/peterbourgon/ff/v3/ffcli"
 	"github.com/pkg/errors"
 	"github.com/sigstore/cosign/pkg/cosign"
 	"github.com/sigstore/cosign/pkg/cosign/fulcio"
 	"github.com/sigstore/cosign/pkg/cosign/kms"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+	"github.com/sigstore/cosign/pkg/cosign/sshsig"
 )
 
 func SignBlob() *ffcli.Command {
 	var (
-		flagset = flag.NewFlagSet("cosign sign-blob", flag.ExitOnError)
-		key     = flagset.String("key", "", "path to the private key")
-		kmsVal  = flagset.String("kms", "", "sign via a private key stored in a KMS")
-		b64     = flagset.Bool("b64", true, "whether to base64 encode the output")
+		flagset   = flag.NewFlagSet("cosign sign-blob", flag.ExitOnError)
+		key       = flagset.String("key", "", "path to the private key")
+		kmsVal    = flagset.String("kms", "", "sign via a private key stored in a KMS")
+		b64       = flagset.Bool("b64", true, "whether to base64 encode the output")
+		checksums = flagset.String("checksums", "", "write a SHA256SUMS file for the blobs to this path and sign it instead")
+		tree      = flagset.String("tree", "", "write a manifest of the directory to this path and sign it instead")
+		output    = flagset.String("output-format", "", "output format for the signature: one of [dsse, bundle, pem], defaults to the bare signature")
+		pt        = flagset.String("payload-type", cosign.DefaultBlobPayloadType, "payloadType to bind the blob to when using -output-format dsse")
+		sshKey    = flagset.String("ssh-key", "", "sign with an OpenSSH private key, or the ssh-agent key matching an OpenSSH public key")
+		namespace = flagset.String("ssh-namespace", sshsig.DefaultNamespace, "namespace to sign in when using -ssh-key")
+		noTimes   = flagset.Bool("no-timestamps", false, "leave the creation time out of -output-format pem signatures")
+		encOutput = flagset.String("encrypted-output", "", "path to write the blob encrypted with -encrypt-to to, <blob>.age by default")
+		encryptTo = filesFlag{}
+		rekorURL  string
 	)
+	flagset.Var(&encryptTo, "encrypt-to", "encrypt the blob to this age recipient (age1...), or the recipients in this file, and sign the encrypted blob, may be repeated")
+	addRekorURLFlag(flagset, &rekorURL)
 	return &ffcli.Command{
 		Name:       "sign-blob",
-		ShortUsage: "cosign sign-blob -key <key>|-kms <kms> [-sig <sig path>] <blob>",
+		ShortUsage: "cosign sign-blob -key <key>|-kms <kms> [-encrypt-to <recipient>]... [-encrypted-output <path>] <blob>",
 		ShortHelp:  `Sign the supplied blob, outputting the base64-encoded signature to stdout.`,
 		LongHelp: `Sign the supplied blob, outputting the base64-encoded signature to stdout.
 
@@ -51,9 +69,71 @@ EXAMPLES
   cosign sign-blob -key cosign.pub <FILE>
 
   # sign a blob with a key pair stored in Google Cloud KMS
-  cosign sign-blob -kms gcpkms://projects/<PROJECT>/locations/global/keyRings/<KEYRING>/cryptoKeys/<KEY> <FILE>`,
+  cosign sign-blob -kms gcpkms://projects/<PROJECT>/locations/global/keyRings/<KEYRING>/cryptoKeys/<KEY> <FILE>
+
+  # sign a blob with a key held in the local TPM
+  cosign sign-blob -kms tpm://0x81000100 <FILE>
+
+  # wrap a blob in a DSSE envelope bound to its payload type
+  cosign sign-blob -key cosign.key -output-format dsse -payload-type application/vnd.in-toto+json <FILE>
+
+  # write the signature, public key or certificate and tlog entry to a single bundle file
+  cosign sign-blob -key cosign.key -output-format bundle <FILE> > <FILE>.bundle
+
+  # output an armored signature with the key fingerprint and creation time, to paste into release notes
+  cosign sign-blob -key cosign.key -output-format pem <FILE>
+
+  # output a reproducible armored signature, without the creation time
+  cosign sign-blob -key cosign.key -output-format pem -no-timestamps <FILE>
+
+  # sign a blob with an SSH key, producing a signature that "ssh-keygen -Y verify -n file" accepts
+  cosign sign-blob -ssh-key ~/.ssh/id_ed25519 <FILE>
+
+  # sign a blob with the ssh-agent key matching a public key
+  cosign sign-blob -ssh-key ~/.ssh/id_ed25519.pub <FILE>
+
+  # sign a blob with a key on a FIDO2 security key, loaded into ssh-agent with "ssh-add ~/.ssh/id_ecdsa_sk"
+  cosign sign-blob -ssh-key ~/.ssh/id_ecdsa_sk <FILE>
+
+  # write a SHA256SUMS file covering several release artifacts and sign it
+  cosign sign-blob -key cosign.key -checksums SHA256SUMS <FILE> <FILE>...
+
+  # write a manifest of every file in a directory and sign it
+  cosign sign-blob -key cosign.key -tree manifest.txt <DIRECTORY>
+
+  # encrypt a confidential artifact to two age recipients, and sign the encrypted <FILE>.age
+  cosign sign-blob -key cosign.key -encrypt-to age1... -encrypt-to recipients.txt <FILE> > <FILE>.age.sig`,
 		FlagSet: flagset,
 		Exec: func(ctx context.Context, args []string) error {
+			if len(args) == 0 {
+				return flag.ErrHelp
+			}
+			if len(encryptTo) > 0 {
+				if *checksums != "" || *tree != "" {
+					return errors.New("-encrypt-to can't be used with -checksums or -tree")
+				}
+				if *encOutput != "" && len(args) > 1 {
+					return errors.New("-encrypted-output can only be used when signing a single blob")
+				}
+				for i, blob := range args {
+					encrypted, err := EncryptBlobCmd(encryptTo, blob, *encOutput)
+					if err != nil {
+						return errors.Wrapf(err, "encrypting %s", blob)
+					}
+					args[i] = encrypted
+				}
+			} else if *encOutput != "" {
+				return errors.New("-encrypted-output requires -encrypt-to")
+			}
+			if *sshKey != "" {
+				for _, blob := range args {
+					if _, err := SignBlobSSHCmd(ctx, *sshKey, *namespace, blob, GetPass); err != nil {
+						return errors.Wrapf(err, "signing %s", blob)
+					}
+				}
+				return nil
+			}
+
 			// A key file is required unless we're in experimental mode!
 			if !cosign.Experimental() {
 				if *key == "" && *kmsVal == "" {
@@ -61,11 +141,30 @@ EXAMPLES
 				}
 			}
 
-			if len(args) == 0 {
-				return flag.ErrHelp
+			opts := SignBlobOpts{
+				Base64:       *b64,
+				OutputFormat: *output,
+				PayloadType:  *pt,
+				NoTimestamps: *noTimes,
+				RekorURL:     rekorURL,
+			}
+			if *tree != "" {
+				if len(args) != 1 {
+					return flag.ErrHelp
+				}
+				if _, err := SignTreeCmd(ctx, *key, *kmsVal, *tree, args[0], opts, GetPass); err != nil {
+					return errors.Wrapf(err, "signing directory %s", args[0])
+				}
+				return nil
+			}
+			if *checksums != "" {
+				if _, err := SignChecksumsCmd(ctx, *key, *kmsVal, *checksums, args, opts, GetPass); err != nil {
+					return errors.Wrapf(err, "signing checksums %s", *checksums)
+				}
+				return nil
 			}
 			for _, blob := range args {
-				if _, err := SignBlobCmd(ctx, *key, *kmsVal, blob, *b64, GetPass); err != nil {
+				if _, err := SignBlobCmd(ctx, *key, *kmsVal, blob, opts, GetPass); err != nil {
 					return errors.Wrapf(err, "signing %s", blob)
 				}
 			}
@@ -74,73 +173,170 @@ EXAMPLES
 	}
 }
 
-func SignBlobCmd(ctx context.Context, keyPath, kmsVal, payloadPath string, b64 bool, pf cosign.PassFunc) ([]byte, error) {
-	var payload []byte
-	var err error
-	if payloadPath == "-" {
-		payload, err = ioutil.ReadAll(os.Stdin)
-	} else {
-		fmt.Fprintln(os.Stderr, "Using payload from:", payloadPath)
-		payload, err = ioutil.ReadFile(filepath.Clean(payloadPath))
+// SignBlobOpts controls the signature output of SignBlobCmd, and where it is logged.
+type SignBlobOpts struct {
+	// Base64 encodes the bare signature. It's ignored for other output formats.
+	Base64 bool
+	// OutputFormat is empty for the bare signature, or one of "dsse", "bundle" and "pem".
+	OutputFormat string
+	// PayloadType is the payloadType of DSSE envelopes.
+	PayloadType string
+	// NoTimestamps leaves the creation time out of armored signatures, so signing the same blob
+	// with the same key always gives the same output headers.
+	NoTimestamps bool
+	// RekorURL is the address of the transparency log, see cosign.TlogServer for the default.
+	RekorURL string
+}
+
+const (
+	dsseOutput   = "dsse"
+	bundleOutput = "bundle"
+	pemOutput    = "pem"
+)
+
+func (o SignBlobOpts) validate() error {
+	switch o.OutputFormat {
+	case "", dsseOutput, bundleOutput, pemOutput:
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format: %s", o.OutputFormat)
 	}
-	if err != nil {
+}
+
+// blobSigner is satisfied by every key type cosign can sign blobs with.
+type blobSigner interface {
+	cosign.SignerVerifier
+	cosign.DigestSigner
+}
+
+func SignBlobCmd(ctx context.Context, keyPath, kmsVal, payloadPath string, opts SignBlobOpts, pf cosign.PassFunc) ([]byte, error) {
+	if err := opts.validate(); err != nil {
 		return nil, err
 	}
 
-	var signature []byte
+	var signer blobSigner
 	var pemBytes []byte
 
+	keyPath, kmsVal = pluginKey(keyPath, kmsVal)
 	switch {
 	case keyPath != "":
-		k, err := loadKey(keyPath, pf)
+		k, err := loadKey(ctx, keyPath, pf)
 		if err != nil {
 			return nil, errors.Wrap(err, "loading key")
 		}
-		signature, err = k.Sign(ctx, payload)
+		signer = k
+	case kmsVal != "":
+		k, err := kms.Get(ctx, kmsVal)
 		if err != nil {
-			return nil, errors.Wrap(err, "signing blob")
+			return nil, err
 		}
-		pemBytes, err = cosign.PublicKeyPem(ctx, k)
+		signer = k
+	default: // Keyless!
+		log.Infof("Generating ephemeral keys...")
+		priv, err := cosign.GeneratePrivateKey()
+		if err != nil {
+			return nil, errors.Wrap(err, "generating cert")
+		}
+		signer = cosign.WithECDSAKey(priv)
+		log.Infof("Retrieving signed certificate...")
+		cert, _, err := fulcio.GetCert(ctx, priv) // TODO: use the chain
+		if err != nil {
+			return nil, errors.Wrap(err, "retrieving cert")
+		}
+		pemBytes = []byte(cert)
+		log.Infof("Signing with certificate:\n%s", cert)
+	}
+	if pemBytes == nil {
+		var err error
+		pemBytes, err = cosign.PublicKeyPem(ctx, signer)
 		if err != nil {
 			return nil, errors.Wrap(err, "getting public key")
 		}
-	case kmsVal != "":
-		k, err := kms.Get(ctx, kmsVal)
+	}
+
+	if payloadPath != "-" {
+		log.Infof("Using payload from: %s", payloadPath)
+	}
+	r, closer, err := blobReader(payloadPath)
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+
+	if opts.OutputFormat == dsseOutput {
+		return signBlobEnvelope(ctx, signer, r, pemBytes, opts.PayloadType, opts.RekorURL)
+	}
+
+	// The rekord entry embeds the full blob, so we can only stream when not uploading to the tlog.
+	var payload []byte
+	if cosign.Experimental() {
+		payload, err = ioutil.ReadAll(r)
 		if err != nil {
 			return nil, err
 		}
-		signature, err = k.Sign(ctx, payload)
+		r = bytes.NewReader(payload)
+	}
+
+	signature, digest, err := cosign.SignBlob(ctx, signer, r)
+	if err != nil {
+		return nil, errors.Wrap(err, "signing blob")
+	}
+
+	if opts.OutputFormat == bundleOutput {
+		bundle := cosign.NewBlobBundle(signature, digest, pemBytes)
+		if cosign.Experimental() {
+			entry, err := cosign.UploadTLog(ctx, opts.RekorURL, signature, payload, pemBytes)
+			if err != nil {
+				return nil, err
+			}
+			log.Infof("%s", entry)
+			bundle.VerificationMaterial.TlogEntry = &cosign.TlogInfo{LogIndex: entry.Index(), LogURL: entry.LogURL}
+		}
+		b, err := json.Marshal(bundle)
 		if err != nil {
-			return nil, errors.Wrap(err, "signing")
+			return nil, err
+		}
+		fmt.Println(string(b))
+		return b, nil
+	}
+
+	if opts.OutputFormat == pemOutput {
+		if cosign.Experimental() {
+			entry, err := cosign.UploadTLog(ctx, opts.RekorURL, signature, payload, pemBytes)
+			if err != nil {
+				return nil, err
+			}
+			log.Infof("%s", entry)
 		}
-		pemBytes, err = cosign.PublicKeyPem(ctx, k)
+		pub, err := signer.PublicKey(ctx)
 		if err != nil {
 			return nil, errors.Wrap(err, "getting public key")
 		}
-	default: // Keyless!
-		fmt.Fprintln(os.Stderr, "Generating ephemeral keys...")
-		priv, err := cosign.GeneratePrivateKey()
+		fingerprint, err := cosign.KeyFingerprint(pub)
 		if err != nil {
-			return nil, errors.Wrap(err, "generating cert")
+			return nil, err
 		}
-		fmt.Fprintln(os.Stderr, "Retrieving signed certificate...")
-		pemBytes, _, err := fulcio.GetCert(ctx, priv) // TODO: use the chain
-		if err != nil {
-			return nil, errors.Wrap(err, "retrieving cert")
+		var created time.Time
+		if !opts.NoTimestamps {
+			if created, err = cosign.CreationTime(); err != nil {
+				return nil, err
+			}
 		}
-		fmt.Fprintf(os.Stderr, "Signing with certificate:\n%s\n", string(pemBytes))
+		armored := cosign.SignatureToPem(signature, fingerprint, created)
+		fmt.Print(string(armored))
+		return armored, nil
 	}
 
 	if cosign.Experimental() {
-		index, err := cosign.UploadTLog(signature, payload, pemBytes)
+		entry, err := cosign.UploadTLog(ctx, opts.RekorURL, signature, payload, pemBytes)
 		if err != nil {
 			return nil, err
 		}
-		fmt.Println("tlog entry created with index: ", index)
+		fmt.Println(entry)
 		return signature, nil
 	}
 
-	if b64 {
+	if opts.Base64 {
 		signature = []byte(base64.StdEncoding.EncodeToString(signature))
 		fmt.Println(string(signature))
 	} else {
@@ -152,3 +348,50 @@ func SignBlobCmd(ctx context.Context, keyPath, kmsVal, payloadPath string, b64 b
 	}
 	return signature, nil
 }
+
+// signBlobEnvelope signs the blob as a DSSE envelope and writes the envelope to stdout.
+// The envelope embeds the whole payload, so this can't be streamed.
+func signBlobEnvelope(ctx context.Context, signer blobSigner, r io.Reader, pemBytes []byte, payloadType, rekorURL string) ([]byte, error) {
+	payload, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := signer.KeyID(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting key id")
+	}
+	env, err := cosign.SignEnvelope(ctx, signer, keyID, payloadType, payload)
+	if err != nil {
+		return nil, err
+	}
+	if cosign.Experimental() {
+		// What was actually signed is the pre-authentication encoding, so that's what goes in the log.
+		sig, err := base64.StdEncoding.DecodeString(env.Signatures[0].Sig)
+		if err != nil {
+			return nil, err
+		}
+		entry, err := cosign.UploadTLog(ctx, rekorURL, sig, cosign.PAE(payloadType, payload), pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("%s", entry)
+	}
+	b, err := json.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println(string(b))
+	return b, nil
+}
+
+// blobReader opens the blob at path, or stdin for "-". The returned func closes it.
+func blobReader(path string) (io.Reader, func(), error) {
+	if path == "-" {
+		return os.Stdin, func() {}, nil
+	}
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func() { f.Close() }, nil
+}
@@ -16,8 +16,34 @@ package cli
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/zalando/go-keyring"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/kms/gcp"
 )
 
 // TestSignCmdLocalKeyAndKms verifies the SignCmd returns an error
@@ -29,9 +55,361 @@ func TestSignCmdLocalKeyAndKms(t *testing.T) {
 	keyPath := "testLocalPath"
 	kmsVal := "testKmsVal"
 
-	err := SignCmd(ctx, keyPath, "", false, "", map[string]string{}, kmsVal, GetPass, false)
+	err := SignCmd(ctx, SignOpts{KeyRef: keyPath, KmsVal: kmsVal}, "", GetPass)
 
 	if (errors.Is(err, &KeyParseError{}) == false) {
 		t.Fatal("expected KeyParseError")
 	}
 }
+
+func TestSignImagesCmd(t *testing.T) {
+	keyring.MockInit()
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	imgs := []string{}
+	for _, repo := range []string{"one", "two", "three"} {
+		ref, err := name.ParseReference(host + "/" + repo + ":latest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := random.Image(10, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+		imgs = append(imgs, ref.String())
+	}
+
+	td, err := ioutil.TempDir("", "cosign-sign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	pass := func(bool) ([]byte, error) { return []byte("hunter2"), nil }
+	keys, err := cosign.GenerateKeyPair(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(td, "cosign.key")
+	if err := ioutil.WriteFile(keyPath, keys.PrivateBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	asked := 0
+	pf := func(confirm bool) ([]byte, error) {
+		asked++
+		return pass(confirm)
+	}
+	// A dry run signs, but doesn't push anything.
+	if err := SignImagesCmd(ctx, SignOpts{KeyRef: keyPath, Upload: true, DryRun: true}, imgs[:1], pass); err != nil {
+		t.Fatal(err)
+	}
+	dryRef, err := name.ParseReference(imgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := cosign.FetchSignatures(ctx, dryRef); !errors.Is(err, cosign.ErrNoSignatures) {
+		t.Fatalf("FetchSignatures() after a dry run = %v, want ErrNoSignatures", err)
+	}
+
+	if err := SignImagesCmd(ctx, SignOpts{KeyRef: keyPath, Upload: true}, imgs, pf); err != nil {
+		t.Fatal(err)
+	}
+	if asked != 1 {
+		t.Errorf("password asked for %d times, want once for all images", asked)
+	}
+	for _, img := range imgs {
+		ref, err := name.ParseReference(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sigs, _, err := cosign.FetchSignatures(ctx, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sigs) != 1 {
+			t.Errorf("%s has %d signatures, want 1", img, len(sigs))
+		}
+	}
+
+	// Signing again with the same key and payload doesn't add another signature, unless forced.
+	count := func() int {
+		ref, err := name.ParseReference(imgs[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sigs, _, err := cosign.FetchSignatures(ctx, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(sigs)
+	}
+	if err := SignCmd(ctx, SignOpts{KeyRef: keyPath, Upload: true}, imgs[0], pass); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(); got != 1 {
+		t.Errorf("%d signatures after signing again, want 1", got)
+	}
+	if err := SignCmd(ctx, SignOpts{KeyRef: keyPath, Upload: true, Force: true}, imgs[0], pass); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(); got != 2 {
+		t.Errorf("%d signatures after signing again with -f, want 2", got)
+	}
+
+	// The signature can also be written to a file, it matches the one pushed.
+	sigPath := filepath.Join(td, "image.sig")
+	if err := SignCmd(ctx, SignOpts{KeyRef: keyPath, Upload: true, Force: true, OutputSignature: sigPath}, imgs[1], pass); err != nil {
+		t.Fatal(err)
+	}
+	written, err := ioutil.ReadFile(sigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(imgs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigs, _, err := cosign.FetchSignatures(ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sigs[len(sigs)-1].Base64Signature != string(written) {
+		t.Errorf("written signature %q doesn't match the pushed one", written)
+	}
+}
+
+// recordingHooks records the steps of signing, and can refuse to let a signature be uploaded.
+type recordingHooks struct {
+	cosign.NopHooks
+	steps  []string
+	events []cosign.SigningEvent
+	refuse error
+}
+
+func (h *recordingHooks) OnPayloadGenerated(_ context.Context, ev *cosign.SigningEvent) error {
+	h.steps = append(h.steps, "payload")
+	h.events = append(h.events, *ev)
+	return nil
+}
+
+func (h *recordingHooks) OnSigned(_ context.Context, ev *cosign.SigningEvent) error {
+	h.steps = append(h.steps, "signed")
+	h.events = append(h.events, *ev)
+	return h.refuse
+}
+
+func (h *recordingHooks) OnUploaded(_ context.Context, ev *cosign.SigningEvent) error {
+	h.steps = append(h.steps, "uploaded")
+	h.events = append(h.events, *ev)
+	return nil
+}
+
+func TestSignHooks(t *testing.T) {
+	keyring.MockInit()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/hooked:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	td, err := ioutil.TempDir("", "cosign-hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	pass := func(bool) ([]byte, error) { return []byte("hunter2"), nil }
+	keys, err := cosign.GenerateKeyPair(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(td, "cosign.key")
+	if err := ioutil.WriteFile(keyPath, keys.PrivateBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A hook refusing the signature stops it from being uploaded.
+	refusing := &recordingHooks{refuse: errors.New("not approved")}
+	ctx := cosign.WithHooks(context.Background(), refusing)
+	if err := SignCmd(ctx, SignOpts{KeyRef: keyPath, Upload: true}, ref.String(), pass); err == nil || !strings.Contains(err.Error(), "not approved") {
+		t.Fatalf("SignCmd() = %v, want the hook's error", err)
+	}
+	if _, _, err := cosign.FetchSignatures(context.Background(), ref); !errors.Is(err, cosign.ErrNoSignatures) {
+		t.Fatalf("FetchSignatures() after the hook refused = %v, want ErrNoSignatures", err)
+	}
+
+	hooks := &recordingHooks{}
+	ctx = cosign.WithHooks(context.Background(), hooks)
+	if err := SignCmd(ctx, SignOpts{KeyRef: keyPath, Upload: true}, ref.String(), pass); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(hooks.steps, ","); got != "payload,signed,uploaded" {
+		t.Fatalf("hooks called for %s, want payload,signed,uploaded", got)
+	}
+	payload, signed, uploaded := hooks.events[0], hooks.events[1], hooks.events[2]
+	if payload.Image.DigestStr() != digest.String() || len(payload.Payload) == 0 || payload.Signature != nil {
+		t.Errorf("OnPayloadGenerated() got %+v", payload)
+	}
+	if len(signed.Signature) == 0 || signed.SignatureRef != nil {
+		t.Errorf("OnSigned() got %+v", signed)
+	}
+	if uploaded.SignatureRef == nil || uploaded.SignatureRef.Identifier() != cosign.Munge(v1.Descriptor{Digest: digest}) {
+		t.Errorf("OnUploaded() got signature ref %v", uploaded.SignatureRef)
+	}
+}
+
+// spiffeCodec passes Workload API messages through as their protobuf encoding.
+type spiffeCodec struct{}
+
+func (spiffeCodec) Marshal(v interface{}) ([]byte, error) { return v.([]byte), nil }
+func (spiffeCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*[]byte) = data
+	return nil
+}
+func (spiffeCodec) String() string { return "proto" }
+
+// fakeWorkloadAPI serves an X.509-SVID for id, issued by a new trust bundle it writes to bundlePath.
+func fakeWorkloadAPI(t *testing.T, socket, bundlePath, id string) *grpc.Server {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	bundle, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(bundlePath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: bundle}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svid, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		URIs:         []*url.URL{u},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}, caTmpl, &priv.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg []byte
+	for i, v := range [][]byte{[]byte(id), svid, key, bundle} {
+		msg = protowire.AppendTag(msg, protowire.Number(i+1), protowire.BytesType)
+		msg = protowire.AppendBytes(msg, v)
+	}
+	resp := protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), msg)
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer(grpc.CustomCodec(spiffeCodec{}), grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+		var req []byte
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		return stream.SendMsg(resp)
+	}))
+	go s.Serve(l)
+	return s
+}
+
+func TestSignSPIFFE(t *testing.T) {
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/workload:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	td, err := ioutil.TempDir("", "cosign-spiffe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	socket, bundle := filepath.Join(td, "api.sock"), filepath.Join(td, "bundle.pem")
+	const id = "spiffe://example.org/ns/ci/sa/builder"
+	defer fakeWorkloadAPI(t, socket, bundle, id).Stop()
+
+	if err := SignCmd(ctx, SignOpts{Upload: true, SPIFFESocket: "unix://" + socket}, ref.String(), GetPass); err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func(ids ...string) error {
+		cmd := VerifyCommand{CheckClaims: true, Annotations: &map[string]string{}, SPIFFEIDs: ids, SPIFFEBundle: bundle}
+		return cmd.Exec(ctx, []string{ref.String()})
+	}
+	if err := verify(id); err != nil {
+		t.Errorf("verifying the SVID's ID: %v", err)
+	}
+	if err := verify("spiffe://example.org/ns/ci/*"); err != nil {
+		t.Errorf("verifying a prefix of the SVID's ID: %v", err)
+	}
+	if err := verify("spiffe://example.org/ns/prod/*"); err == nil {
+		t.Error("expected error verifying another SPIFFE ID")
+	}
+	if err := verify("ci@example.com"); err == nil {
+		t.Error("expected error verifying an identity that isn't a SPIFFE ID")
+	}
+}
+
+func TestSignerAnnotations(t *testing.T) {
+	user := map[string]string{"team": "infra"}
+	if got := (&imageSigner{}).annotations(user); len(got) != 1 {
+		t.Errorf("annotations() without a GCP KMS key = %v, want only the user's", got)
+	}
+
+	version := "projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/3"
+	got := (&imageSigner{gcpKeyVersion: version}).annotations(user)
+	if got[gcp.KeyVersionAnnotation] != "gcpkms://"+version || got["team"] != "infra" {
+		t.Errorf("annotations() = %v", got)
+	}
+	if len(user) != 1 {
+		t.Errorf("annotations() changed the user's annotations: %v", user)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/sshsig"
+)
+
+// SignBlobSSHCmd signs the blob with an OpenSSH key, writing an armored signature
+// compatible with `ssh-keygen -Y verify` to stdout.
+// If keyPath is a public key, the matching private key is used through ssh-agent.
+// Keys on FIDO2 security keys are always used through ssh-agent, which asks for a touch on each signature.
+func SignBlobSSHCmd(_ context.Context, keyPath, namespace, payloadPath string, pf cosign.PassFunc) ([]byte, error) {
+	if cosign.Experimental() {
+		return nil, errors.New("uploading SSH signatures to the transparency log is not supported")
+	}
+	signer, err := loadSSHSigner(keyPath, pf)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading ssh key")
+	}
+	if err := checkFIPSSSHKey(signer.PublicKey()); err != nil {
+		return nil, err
+	}
+
+	if payloadPath != "-" {
+		fmt.Fprintln(os.Stderr, "Using payload from:", payloadPath)
+	}
+	r, closer, err := blobReader(payloadPath)
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+
+	if sshsig.IsSecurityKey(signer.PublicKey()) {
+		fmt.Fprintln(os.Stderr, "Confirm user presence for key", ssh.FingerprintSHA256(signer.PublicKey()))
+	}
+	sig, err := sshsig.Sign(signer, namespace, r)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Print(string(sig))
+	return sig, nil
+}
+
+// VerifyBlobSSHCmd verifies an `ssh-keygen -Y sign` signature on the blob against an OpenSSH public key.
+func VerifyBlobSSHCmd(_ context.Context, keyRef, namespace, sigRef, blobRef string) error {
+	pub := sshPublicKey(keyRef)
+	if pub == nil {
+		return fmt.Errorf("%s is not an ssh public key", keyRef)
+	}
+	if err := checkFIPSSSHKey(pub); err != nil {
+		return err
+	}
+	sig, err := ioutil.ReadFile(filepath.Clean(sigRef))
+	if err != nil {
+		return err
+	}
+	r, closer, err := blobReader(blobRef)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	if err := sshsig.Verify(pub, namespace, r, sig); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Verified OK")
+	return nil
+}
+
+// checkFIPSSSHKey applies cosign.CheckFIPSKey to an SSH key. Security keys can't be checked
+// and are rejected in FIPS mode.
+func checkFIPSSSHKey(pub ssh.PublicKey) error {
+	if !cosign.FIPSMode() {
+		return nil
+	}
+	cpk, ok := pub.(ssh.CryptoPublicKey)
+	if !ok {
+		return fmt.Errorf("%s keys are not allowed in FIPS mode", pub.Type())
+	}
+	return cosign.CheckFIPSKey(cpk.CryptoPublicKey())
+}
+
+// sshPublicKey returns the OpenSSH public key at keyRef, or nil if it doesn't hold one.
+func sshPublicKey(keyRef string) ssh.PublicKey {
+	if keyRef == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(filepath.Clean(keyRef))
+	if err != nil {
+		return nil
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(b)
+	if err != nil {
+		return nil
+	}
+	return pub
+}
+
+func loadSSHSigner(keyPath string, pf cosign.PassFunc) (ssh.Signer, error) {
+	if pub := sshPublicKey(keyPath); pub != nil {
+		return agentSigner(pub)
+	}
+	kb, err := ioutil.ReadFile(filepath.Clean(keyPath))
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(kb)
+	if err != nil {
+		// Private key files for security keys only hold a handle to the key on the device,
+		// so those have to go through the agent using the public key next to them.
+		if pub := sshPublicKey(keyPath + ".pub"); pub != nil && sshsig.IsSecurityKey(pub) {
+			return agentSigner(pub)
+		}
+	}
+	if _, ok := err.(*ssh.PassphraseMissingError); ok {
+		pass, err := pf(false)
+		if err != nil {
+			return nil, err
+		}
+		return ssh.ParsePrivateKeyWithPassphrase(kb, pass)
+	}
+	return signer, err
+}
+
+// agentSigner finds the signer for pub in the running ssh-agent.
+func agentSigner(pub ssh.PublicKey) (ssh.Signer, error) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, errors.New("a public key was specified but SSH_AUTH_SOCK is not set")
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, errors.Wrap(err, "connecting to ssh-agent")
+	}
+	signers, err := agent.NewClient(conn).Signers()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing ssh-agent keys")
+	}
+	for _, s := range signers {
+		if bytes.Equal(s.PublicKey().Marshal(), pub.Marshal()) {
+			return s, nil
+		}
+	}
+	return nil, errors.New("key not found in ssh-agent")
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"flag"
+
+	"github.com/pkg/errors"
+	"github.com/sigstore/rekor/pkg/generated/client"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+// TlogOpts picks whether verification checks the transparency log. By default it does with
+// COSIGN_EXPERIMENTAL=1, the flags make the choice explicit for a single command.
+// A nil *TlogOpts keeps the default.
+type TlogOpts struct {
+	// Require fails verification unless the signatures are in the transparency log.
+	Require bool
+	// Ignore skips the transparency log, even with COSIGN_EXPERIMENTAL=1.
+	Ignore bool
+}
+
+// addTlogFlags registers the transparency log flags on fs.
+func addTlogFlags(fs *flag.FlagSet) *TlogOpts {
+	o := &TlogOpts{}
+	fs.BoolVar(&o.Require, "require-tlog", false, "require the signatures to be in the transparency log, whether or not $"+cosign.ExperimentalEnv+" is set")
+	fs.BoolVar(&o.Ignore, "insecure-ignore-tlog", false, "don't check the transparency log, even with $"+cosign.ExperimentalEnv+" set")
+	return o
+}
+
+// enabled reports whether the transparency log is checked.
+func (o *TlogOpts) enabled() (bool, error) {
+	switch {
+	case o == nil:
+		return cosign.Experimental(), nil
+	case o.Require && o.Ignore:
+		return false, errors.New("-require-tlog and -insecure-ignore-tlog can't be used together")
+	case o.Require:
+		return true, nil
+	case o.Ignore:
+		return false, nil
+	default:
+		return cosign.Experimental(), nil
+	}
+}
+
+type tlogKey struct{}
+
+// tlogSettings are how the blob verification commands use the transparency log.
+type tlogSettings struct {
+	check    bool
+	rekorURL string
+}
+
+// withTlog records in ctx whether the blob verification commands check the transparency log, and its address.
+func withTlog(ctx context.Context, check bool, rekorURL string) context.Context {
+	return context.WithValue(ctx, tlogKey{}, tlogSettings{check: check, rekorURL: rekorURL})
+}
+
+// checkTlog reports whether to check the transparency log, as recorded by withTlog or with
+// COSIGN_EXPERIMENTAL=1 by default.
+func checkTlog(ctx context.Context) bool {
+	if s, ok := ctx.Value(tlogKey{}).(tlogSettings); ok {
+		return s.check
+	}
+	return cosign.Experimental()
+}
+
+// tlogClient returns a client for the transparency log recorded by withTlog, or cosign.TlogServer.
+func tlogClient(ctx context.Context) (*client.Rekor, error) {
+	if s, ok := ctx.Value(tlogKey{}).(tlogSettings); ok && s.rekorURL != "" {
+		return cosign.TlogClientFor(s.rekorURL)
+	}
+	return cosign.TlogClient()
+}
+
+// addRekorURLFlag registers -rekor-url on fs, storing the address in p.
+func addRekorURLFlag(fs *flag.FlagSet, p *string) {
+	fs.StringVar(p, "rekor-url", "", "address of the transparency log, $"+cosign.ServerEnv+" or https://api.rekor.dev by default")
+}
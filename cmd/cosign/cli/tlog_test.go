@@ -0,0 +1,67 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+func TestTlogOpts(t *testing.T) {
+	if old, ok := os.LookupEnv(cosign.ExperimentalEnv); ok {
+		defer os.Setenv(cosign.ExperimentalEnv, old)
+	} else {
+		defer os.Unsetenv(cosign.ExperimentalEnv)
+	}
+
+	tests := []struct {
+		desc         string
+		opts         *TlogOpts
+		experimental string
+		want         bool
+		wantErr      bool
+	}{
+		{desc: "default", opts: &TlogOpts{}, want: false},
+		{desc: "default experimental", opts: &TlogOpts{}, experimental: "1", want: true},
+		{desc: "nil experimental", opts: nil, experimental: "1", want: true},
+		{desc: "require", opts: &TlogOpts{Require: true}, want: true},
+		{desc: "ignore experimental", opts: &TlogOpts{Ignore: true}, experimental: "1", want: false},
+		{desc: "both", opts: &TlogOpts{Require: true, Ignore: true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			os.Setenv(cosign.ExperimentalEnv, tt.experimental)
+			got, err := tt.opts.enabled()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("enabled() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("enabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	os.Setenv(cosign.ExperimentalEnv, "1")
+	ctx := context.Background()
+	if !checkTlog(ctx) {
+		t.Error("checkTlog() = false, want COSIGN_EXPERIMENTAL by default")
+	}
+	if checkTlog(withTlog(ctx, false, "")) {
+		t.Error("checkTlog() = true after withTlog(false)")
+	}
+}
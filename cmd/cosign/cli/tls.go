@@ -0,0 +1,68 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// ConfigureTLS makes all outbound connections (registries, Rekor and Fulcio) trust the CAs in
+// caBundle in addition to the system roots, and present the client certificate if one is given.
+func ConfigureTLS(caBundle, clientCert, clientKey string) error {
+	if caBundle == "" && clientCert == "" && clientKey == "" {
+		return nil
+	}
+	cfg, err := tlsConfig(caBundle, clientCert, clientKey)
+	if err != nil {
+		return err
+	}
+	baseTransport.TLSClientConfig = cfg
+	return nil
+}
+
+func tlsConfig(caBundle, clientCert, clientKey string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caBundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		b, err := ioutil.ReadFile(filepath.Clean(caBundle))
+		if err != nil {
+			return nil, errors.Wrap(err, "reading CA bundle")
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in %s", caBundle)
+		}
+		cfg.RootCAs = pool
+	}
+	if (clientCert == "") != (clientKey == "") {
+		return nil, errors.New("a client certificate and key must be given together")
+	}
+	if clientCert != "" {
+		cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading client certificate")
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
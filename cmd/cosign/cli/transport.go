@@ -0,0 +1,193 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// baseTransport is the transport all outbound connections are ultimately made with, so
+// connections are pooled across every registry, Rekor and Fulcio request a command makes.
+// It is configured by ConfigureTLS and wrapped by ConfigureNetwork.
+var baseTransport = func() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	// Signing or verifying many images makes lots of concurrent requests to the same registry,
+	// and the default of 2 idle connections per host means most of them redo the TLS handshake.
+	t.MaxIdleConnsPerHost = 32
+	return t
+}()
+
+// retryBackoff is the wait before the first retry, it doubles for each one after that.
+var retryBackoff = time.Second
+
+// maxRetryAfter bounds how long a server asking to be retried later with Retry-After is waited for.
+const maxRetryAfter = time.Minute
+
+var netOpts struct {
+	retries  int
+	deadline time.Time
+}
+
+// ConfigureNetwork makes every outbound request retry connection errors and transient
+// (429 and 5xx) responses up to retries times with exponential backoff, or after the
+// Retry-After the server asked for, and fail once
+// timeout has passed since it was called. A zero timeout means no timeout.
+func ConfigureNetwork(retries int, timeout time.Duration) {
+	netOpts.retries = retries
+	netOpts.deadline = time.Time{}
+	if timeout > 0 {
+		netOpts.deadline = time.Now().Add(timeout)
+	}
+	// The Rekor and Fulcio clients and the OIDC flow all use the default transport.
+	http.DefaultTransport = withRetries(baseTransport)
+}
+
+// withRetries wraps rt with the retry and timeout settings from ConfigureNetwork.
+func withRetries(rt http.RoundTripper) http.RoundTripper {
+	if log.Enabled(log.LevelDebug) {
+		rt = &logTransport{inner: rt}
+	}
+	if netOpts.retries == 0 && netOpts.deadline.IsZero() {
+		return rt
+	}
+	return &retryTransport{inner: rt, retries: netOpts.retries, deadline: netOpts.deadline}
+}
+
+type retryTransport struct {
+	inner    http.RoundTripper
+	retries  int
+	deadline time.Time
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var cancel context.CancelFunc = func() {}
+	if !t.deadline.IsZero() {
+		var ctx context.Context
+		ctx, cancel = context.WithDeadline(req.Context(), t.deadline)
+		req = req.WithContext(ctx)
+	}
+	resp, err := t.roundTrip(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The deadline has to outlive this call, until the body has been read.
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (t *retryTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := t.inner.RoundTrip(req)
+		if attempt >= t.retries || !retryable(resp, err) || req.Context().Err() != nil {
+			return resp, err
+		}
+		// Streamed bodies (like blob uploads) have been consumed and can't be sent again.
+		if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			return resp, err
+		}
+		wait := backoff
+		if resp != nil {
+			wait = retryAfter(resp, backoff)
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+			log.Debugf("%s %s: %s, retrying in %s", req.Method, req.URL.Redacted(), resp.Status, wait)
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryAfter returns how long the server asked to wait before retrying, in seconds or as
+// a date, or backoff if it didn't say.
+func retryAfter(resp *http.Response, backoff time.Duration) time.Duration {
+	ra := resp.Header.Get("Retry-After")
+	if ra == "" {
+		return backoff
+	}
+	wait := backoff
+	if s, err := strconv.Atoi(ra); err == nil && s >= 0 {
+		wait = time.Duration(s) * time.Second
+	} else if t, err := http.ParseTime(ra); err == nil {
+		wait = time.Until(t)
+	}
+	switch {
+	case wait < 0:
+		return 0
+	case wait > maxRetryAfter:
+		return maxRetryAfter
+	}
+	return wait
+}
+
+// retryable reports whether a request failed in a way that might succeed if tried again.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// logTransport logs each round trip, including every retry, at debug level.
+type logTransport struct {
+	inner http.RoundTripper
+}
+
+func (t *logTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil {
+		log.Debugf("%s %s: %v (%s)", req.Method, req.URL.Redacted(), err, time.Since(start))
+		return nil, err
+	}
+	log.Debugf("%s %s: %s (%s)", req.Method, req.URL.Redacted(), resp.Status, time.Since(start))
+	return resp, nil
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
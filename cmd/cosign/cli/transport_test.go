@@ -0,0 +1,110 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	defer func(b time.Duration) { retryBackoff = b }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	calls := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if b, _ := ioutil.ReadAll(r.Body); string(b) != "payload" {
+			t.Errorf("attempt %d got body %q", calls, b)
+		}
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer s.Close()
+
+	tests := []struct {
+		name    string
+		retries int
+		want    int
+	}{
+		{"no retries", 0, http.StatusServiceUnavailable},
+		{"too few retries", 1, http.StatusServiceUnavailable},
+		{"enough retries", 2, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			c := &http.Client{Transport: &retryTransport{inner: http.DefaultTransport, retries: tt.retries}}
+			resp, err := c.Post(s.URL, "text/plain", bytes.NewBufferString("payload"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	backoff := 2 * time.Second
+	tests := []struct {
+		retryAfter string
+		want       time.Duration
+	}{
+		{"", backoff},
+		{"0", 0},
+		{"5", 5 * time.Second},
+		{"3600", maxRetryAfter},
+		{"soon", backoff},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+		if tt.retryAfter != "" {
+			resp.Header.Set("Retry-After", tt.retryAfter)
+		}
+		if got := retryAfter(resp, backoff); got != tt.want {
+			t.Errorf("retryAfter(%q) = %s, want %s", tt.retryAfter, got, tt.want)
+		}
+	}
+}
+
+func TestRetryTransportDeadline(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer s.Close()
+
+	rt := &retryTransport{inner: http.DefaultTransport, deadline: time.Now().Add(50 * time.Millisecond)}
+	start := time.Now()
+	if _, err := (&http.Client{Transport: rt}).Get(s.URL); err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("request took %s, deadline not honored", d)
+	}
+}
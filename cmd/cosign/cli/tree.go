@@ -0,0 +1,61 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// SignTreeCmd writes a canonical manifest of the directory at dir to manifestPath and signs it.
+func SignTreeCmd(ctx context.Context, keyPath, kmsVal, manifestPath, dir string, opts SignBlobOpts, pf cosign.PassFunc) ([]byte, error) {
+	manifest, err := cosign.TreeManifest(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "building manifest")
+	}
+	if err := ioutil.WriteFile(filepath.Clean(manifestPath), manifest, 0644); err != nil {
+		return nil, err
+	}
+	log.Infof("Manifest written to %s", manifestPath)
+	return SignBlobCmd(ctx, keyPath, kmsVal, manifestPath, opts, pf)
+}
+
+// VerifyTreeCmd verifies the signature on a directory manifest, then checks the directory against it.
+func VerifyTreeCmd(ctx context.Context, keyRef, kmsVal, certRef, sigRef, manifestRef, dir string) error {
+	if err := VerifyBlobCmd(ctx, keyRef, kmsVal, certRef, sigRef, manifestRef); err != nil {
+		return err
+	}
+	f, err := os.Open(filepath.Clean(manifestRef))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	manifest, err := cosign.ParseChecksums(f)
+	if err != nil {
+		return errors.Wrap(err, "parsing manifest")
+	}
+	if err := cosign.VerifyTree(dir, manifest); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%s matches the signed manifest\n", dir)
+	return nil
+}
@@ -19,8 +19,6 @@ import (
 	"flag"
 	"fmt"
 
-	"github.com/google/go-containerregistry/pkg/authn"
-	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/peterbourgon/ff/v3/ffcli"
 	"github.com/sigstore/cosign/pkg/cosign"
@@ -28,7 +26,8 @@ import (
 
 func Triangulate() *ffcli.Command {
 	var (
-		flagset = flag.NewFlagSet("cosign triangulate", flag.ExitOnError)
+		flagset  = flag.NewFlagSet("cosign triangulate", flag.ExitOnError)
+		registry = addRegistryFlags(flagset)
 	)
 	return &ffcli.Command{
 		Name:       "triangulate",
@@ -39,19 +38,19 @@ func Triangulate() *ffcli.Command {
 			if len(args) != 1 {
 				return flag.ErrHelp
 			}
-			return MungeCmd(ctx, args[0])
+			return MungeCmd(ctx, args[0], *registry)
 		},
 	}
 }
 
-func MungeCmd(_ context.Context, imageRef string) error {
-	ref, err := name.ParseReference(imageRef)
+func MungeCmd(ctx context.Context, imageRef string, ro RegistryOpts) error {
+	ref, err := ro.ParseReference(imageRef)
 	if err != nil {
 		return err
 	}
 
 	// TODO: just return the descriptor directly if we have a digest reference.
-	desc, err := remote.Get(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	desc, err := remote.Get(ref, ro.ClientOpts(ctx)...)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,279 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+func Trust() *ffcli.Command {
+	return &ffcli.Command{
+		Name:       "trust",
+		ShortUsage: "cosign trust add|list|remove",
+		ShortHelp:  "Manage named trust profiles that verify -trust-profile refers to",
+		LongHelp: `Manage named trust profiles that verify -trust-profile refers to.
+
+A profile holds public keys, CA roots and the certificate identities allowed to sign. Profiles are
+JSON files in $` + cosign.TrustDirEnv + `, or the user config directory by default, so they can be
+distributed by copying them there.`,
+		Subcommands: []*ffcli.Command{trustAdd(), trustList(), trustRemove()},
+		Exec: func(context.Context, []string) error {
+			return flag.ErrHelp
+		},
+	}
+}
+
+func trustAdd() *ffcli.Command {
+	var (
+		flagset    = flag.NewFlagSet("cosign trust add", flag.ExitOnError)
+		keys       = filesFlag{}
+		roots      = filesFlag{}
+		identities = filesFlag{}
+	)
+	flagset.Var(&keys, "key", "path to a PEM public key to trust, may be repeated")
+	flagset.Var(&roots, "root", "path to PEM CA certificates to trust instead of the Fulcio roots, may be repeated")
+	flagset.Var(&identities, "identity", "certificate email or SPIFFE ID allowed to sign, may be repeated")
+	return &ffcli.Command{
+		Name:       "add",
+		ShortUsage: "cosign trust add [-key <path>]... [-root <path>]... [-identity <email>|<spiffe id>]... <profile>",
+		ShortHelp:  "Add keys, roots or identities to a trust profile, creating it if needed",
+		LongHelp: `Add keys, roots or identities to a trust profile, creating it if needed.
+
+EXAMPLES
+  # trust the release key in the prod profile
+  cosign trust add -key release.pub prod
+
+  # trust Fulcio certificates for two identities in the ci profile
+  cosign trust add -identity ci@example.com -identity release@example.com ci
+
+  # trust the X.509-SVIDs of CI workloads in the example.org SPIFFE trust domain
+  cosign trust add -root bundle.pem -identity 'spiffe://example.org/ns/ci/*' ci`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 || len(keys)+len(roots)+len(identities) == 0 {
+				return flag.ErrHelp
+			}
+			ts, err := cosign.NewTrustStore()
+			if err != nil {
+				return err
+			}
+			return TrustAddCmd(ts, args[0], keys, roots, identities)
+		},
+	}
+}
+
+func trustList() *ffcli.Command {
+	flagset := flag.NewFlagSet("cosign trust list", flag.ExitOnError)
+	return &ffcli.Command{
+		Name:       "list",
+		ShortUsage: "cosign trust list [<profile>]",
+		ShortHelp:  "List the trust profiles, or what one of them trusts",
+		FlagSet:    flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) > 1 {
+				return flag.ErrHelp
+			}
+			ts, err := cosign.NewTrustStore()
+			if err != nil {
+				return err
+			}
+			if len(args) == 0 {
+				names, err := ts.List()
+				if err != nil {
+					return err
+				}
+				for _, name := range names {
+					fmt.Println(name)
+				}
+				return nil
+			}
+			return TrustListCmd(ctx, ts, args[0], os.Stdout)
+		},
+	}
+}
+
+func trustRemove() *ffcli.Command {
+	var (
+		flagset    = flag.NewFlagSet("cosign trust remove", flag.ExitOnError)
+		keyIDs     = filesFlag{}
+		identities = filesFlag{}
+	)
+	flagset.Var(&keyIDs, "key-id", "fingerprint of a key to remove, as shown by list, may be repeated")
+	flagset.Var(&identities, "identity", "identity to remove, may be repeated")
+	return &ffcli.Command{
+		Name:       "remove",
+		ShortUsage: "cosign trust remove [-key-id <fingerprint>]... [-identity <email>]... <profile>",
+		ShortHelp:  "Remove keys or identities from a trust profile, or the whole profile",
+		FlagSet:    flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return flag.ErrHelp
+			}
+			ts, err := cosign.NewTrustStore()
+			if err != nil {
+				return err
+			}
+			if len(keyIDs)+len(identities) == 0 {
+				return ts.Remove(args[0])
+			}
+			return TrustRemoveCmd(ctx, ts, args[0], keyIDs, identities)
+		},
+	}
+}
+
+// TrustAddCmd adds the keys and roots at the given paths, and the identities, to the named profile.
+func TrustAddCmd(ts *cosign.TrustStore, name string, keyPaths, rootPaths, identities []string) error {
+	tp, err := ts.Load(name)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		tp = &cosign.TrustProfile{Name: name}
+	case err != nil:
+		return err
+	}
+	for _, p := range keyPaths {
+		b, err := ioutil.ReadFile(filepath.Clean(p))
+		if err != nil {
+			return err
+		}
+		if _, err := cosign.ParsePublicKeyPem(b); err != nil {
+			return errors.Wrapf(err, "loading key %s", p)
+		}
+		tp.Keys = appendUnique(tp.Keys, string(b))
+	}
+	for _, p := range rootPaths {
+		b, err := ioutil.ReadFile(filepath.Clean(p))
+		if err != nil {
+			return err
+		}
+		certs, err := cosign.LoadCerts(string(b))
+		if err != nil || len(certs) == 0 {
+			return fmt.Errorf("no certificates found in %s", p)
+		}
+		tp.Roots = appendUnique(tp.Roots, string(b))
+	}
+	for _, id := range identities {
+		tp.Identities = appendUnique(tp.Identities, id)
+	}
+	return ts.Save(tp)
+}
+
+// trustedKey describes a key of a profile by its fingerprint.
+type trustedKey struct {
+	ID  string `json:"id"`
+	PEM string `json:"pem"`
+}
+
+// listedProfile is printed by "trust list <profile>".
+type listedProfile struct {
+	Name       string       `json:"name"`
+	Keys       []trustedKey `json:"keys,omitempty"`
+	Roots      []string     `json:"roots,omitempty"`
+	Identities []string     `json:"identities,omitempty"`
+}
+
+// TrustListCmd prints what the named profile trusts, identifying keys by their fingerprint and roots by their subject.
+func TrustListCmd(ctx context.Context, ts *cosign.TrustStore, name string, w io.Writer) error {
+	tp, err := ts.Load(name)
+	if err != nil {
+		return err
+	}
+	out := listedProfile{Name: tp.Name, Identities: tp.Identities}
+	for _, k := range tp.Keys {
+		id, err := trustedKeyID(ctx, k)
+		if err != nil {
+			return err
+		}
+		out.Keys = append(out.Keys, trustedKey{ID: id, PEM: k})
+	}
+	for _, r := range tp.Roots {
+		certs, err := cosign.LoadCerts(r)
+		if err != nil {
+			return err
+		}
+		for _, c := range certs {
+			out.Roots = append(out.Roots, c.Subject.String())
+		}
+	}
+	return printJSON(w, out)
+}
+
+// TrustRemoveCmd removes the keys with the given fingerprints, and the identities, from the named profile.
+func TrustRemoveCmd(ctx context.Context, ts *cosign.TrustStore, name string, keyIDs, identities []string) error {
+	tp, err := ts.Load(name)
+	if err != nil {
+		return err
+	}
+	remove := map[string]bool{}
+	for _, id := range append(keyIDs, identities...) {
+		remove[id] = true
+	}
+	keys := []string{}
+	for _, k := range tp.Keys {
+		id, err := trustedKeyID(ctx, k)
+		if err != nil {
+			return err
+		}
+		if remove[id] {
+			delete(remove, id)
+			continue
+		}
+		keys = append(keys, k)
+	}
+	ids := []string{}
+	for _, id := range tp.Identities {
+		if remove[id] {
+			delete(remove, id)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	for missing := range remove {
+		return fmt.Errorf("%s is not in trust profile %s", missing, name)
+	}
+	tp.Keys, tp.Identities = keys, ids
+	return ts.Save(tp)
+}
+
+func trustedKeyID(ctx context.Context, keyPem string) (string, error) {
+	k, err := cosign.ParsePublicKeyPem([]byte(keyPem))
+	if err != nil {
+		return "", err
+	}
+	pub, err := k.PublicKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	return cosign.KeyFingerprint(pub)
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, l := range list {
+		if l == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -19,15 +19,19 @@ import (
 	"encoding/base64"
 	"errors"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
-	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/peterbourgon/ff/v3/ffcli"
 	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/log"
 )
 
 func Upload() *ffcli.Command {
@@ -35,23 +39,163 @@ func Upload() *ffcli.Command {
 		flagset   = flag.NewFlagSet("cosign upload", flag.ExitOnError)
 		signature = flagset.String("signature", "", "the signature, path to the signature, or {-} for stdin")
 		payload   = flagset.String("payload", "", "path to the payload covered by the signature (if using another format)")
+		registry  = addRegistryFlags(flagset)
 	)
 	return &ffcli.Command{
-		Name:       "upload",
-		ShortUsage: "cosign upload <image uri>",
-		ShortHelp:  "upload signatures to the supplied container image",
-		FlagSet:    flagset,
+		Name:        "upload",
+		ShortUsage:  "cosign upload [blob|wasm] <image uri>",
+		ShortHelp:   "upload signatures to the supplied container image, or files to sign as artifacts",
+		FlagSet:     flagset,
+		Subcommands: []*ffcli.Command{uploadBlob(), uploadWasm()},
 		Exec: func(ctx context.Context, args []string) error {
 			if len(args) != 1 {
 				return flag.ErrHelp
 			}
 
-			return UploadCmd(ctx, *signature, *payload, args[0])
+			return UploadCmd(ctx, *signature, *payload, args[0], *registry)
 		},
 	}
 }
 
-func UploadCmd(ctx context.Context, sigRef, payloadRef, imageRef string) error {
+// filesFlag collects the values of a repeated flag.
+type filesFlag []string
+
+func (f *filesFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+func (f *filesFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func uploadBlob() *ffcli.Command {
+	var (
+		flagset     = flag.NewFlagSet("cosign upload blob", flag.ExitOnError)
+		contentType = flagset.String("ct", string(cosign.BlobMediaType), "media type of the files")
+		push        = addPushFlags(flagset)
+		progress    = addProgressFlag(flagset)
+		files       = filesFlag{}
+		registry    = addRegistryFlags(flagset)
+	)
+	flagset.Var(&files, "f", "path to a file to upload, may be repeated")
+	return &ffcli.Command{
+		Name:       "blob",
+		ShortUsage: "cosign upload blob -f <file> [-f <file> ...] [-ct <media type>] [-parallel <n>] [-chunk-size <bytes>] [-progress] <image uri>",
+		ShortHelp:  "Upload one or more files to the supplied container image address as an OCI artifact",
+		LongHelp: `Upload one or more files to the supplied container image address as an OCI artifact.
+
+Each file is a layer of the artifact, annotated with its file name. The digest reference of the
+artifact is printed, and can be signed and verified like any image.
+The files are pushed concurrently, -parallel at a time.
+With -chunk-size, files are pushed in chunks of that many bytes. If the upload is interrupted,
+running the same command again continues where it stopped, while the registry keeps the session.
+
+EXAMPLES
+  # upload a file and sign it
+  cosign sign -key cosign.key $(cosign upload blob -f release.tar.gz <IMAGE>)
+
+  # upload several files with their media type
+  cosign upload blob -f sbom.spdx -f sbom.cdx.json -ct application/json <IMAGE>
+
+  # upload a large tarball in resumable 16MiB chunks, printing the progress
+  cosign upload blob -f rootfs.tar -chunk-size 16777216 -progress <IMAGE>`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 || len(files) == 0 {
+				return flag.ErrHelp
+			}
+			return UploadFilesCmd(withProgress(ctx, *progress), files, types.MediaType(*contentType), cosign.BlobConfigMediaType, args[0], *push, *registry)
+		},
+	}
+}
+
+func uploadWasm() *ffcli.Command {
+	var (
+		flagset  = flag.NewFlagSet("cosign upload wasm", flag.ExitOnError)
+		file     = flagset.String("f", "", "path to the WASM module to upload")
+		push     = addPushFlags(flagset)
+		progress = addProgressFlag(flagset)
+		registry = addRegistryFlags(flagset)
+	)
+	return &ffcli.Command{
+		Name:       "wasm",
+		ShortUsage: "cosign upload wasm -f <module.wasm> [-chunk-size <bytes>] [-progress] <image uri>",
+		ShortHelp:  "Upload a WASM module to the supplied container image address",
+		LongHelp: `Upload a WASM module to the supplied container image address.
+
+The module is stored with the WASM artifact media types, so runtimes that pull WASM from
+registries can use it. The digest reference is printed, and can be signed like any image.
+
+EXAMPLES
+  cosign upload wasm -f module.wasm <IMAGE>`,
+		FlagSet: flagset,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 || *file == "" {
+				return flag.ErrHelp
+			}
+			return UploadFilesCmd(withProgress(ctx, *progress), []string{*file}, cosign.WasmLayerMediaType, cosign.WasmConfigMediaType, args[0], *push, *registry)
+		},
+	}
+}
+
+// uploadSessionTTL is how long an interrupted chunked upload is remembered for resuming.
+const uploadSessionTTL = 24 * time.Hour
+
+// PushOpts holds the settings for pushing blobs to the registry.
+type PushOpts struct {
+	// Parallelism is how many blobs are pushed at once, see cosign.DefaultParallelism.
+	Parallelism int
+	// ChunkSize, if set, pushes blobs in chunks of that many bytes, and resumes interrupted uploads.
+	ChunkSize int64
+}
+
+// addPushFlags registers the flags controlling how blobs are pushed on fs.
+func addPushFlags(fs *flag.FlagSet) *PushOpts {
+	po := &PushOpts{}
+	fs.IntVar(&po.Parallelism, "parallel", cosign.DefaultParallelism, "how many blobs to push at once")
+	fs.Int64Var(&po.ChunkSize, "chunk-size", 0, "push blobs in chunks of this many bytes, resuming an interrupted upload when run again, instead of all at once")
+	return po
+}
+
+// pushOpts returns the options for pushing to repo with the credentials of ro.
+func (po PushOpts) pushOpts(ro RegistryOpts, repo name.Repository) (cosign.PushOpts, error) {
+	opts := cosign.PushOpts{Parallelism: po.Parallelism}
+	if po.ChunkSize <= 0 {
+		return opts, nil
+	}
+	rt, err := ro.PushTransport(repo)
+	if err != nil {
+		return opts, err
+	}
+	sessions, err := cosign.NewCache(uploadSessionTTL)
+	if err != nil {
+		return opts, err
+	}
+	opts.Chunked = &cosign.ChunkedUploader{Transport: rt, ChunkSize: po.ChunkSize, Sessions: sessions}
+	return opts, nil
+}
+
+// UploadFilesCmd pushes the files to imageRef as an OCI artifact, and prints its digest reference.
+func UploadFilesCmd(ctx context.Context, files []string, layerMediaType, configMediaType types.MediaType, imageRef string, po PushOpts, ro RegistryOpts) error {
+	ref, err := ro.ParseReference(imageRef)
+	if err != nil {
+		return err
+	}
+	opts, err := po.pushOpts(ro, ref.Context())
+	if err != nil {
+		return err
+	}
+	log.Infof("Uploading file(s) to: %s", ref.Name())
+	digest, err := cosign.UploadFilesWithOpts(ctx, ref, files, layerMediaType, configMediaType, opts, ro.ClientOpts(ctx)...)
+	if err != nil {
+		return err
+	}
+	fmt.Println(ref.Context().Digest(digest.String()).String())
+	return nil
+}
+
+func UploadCmd(ctx context.Context, sigRef, payloadRef, imageRef string, ro RegistryOpts) error {
 	var b64SigBytes []byte
 
 	b64SigBytes, err := signatureBytes(sigRef)
@@ -61,12 +205,12 @@ func UploadCmd(ctx context.Context, sigRef, payloadRef, imageRef string) error {
 		return errors.New("empty signature")
 	}
 
-	ref, err := name.ParseReference(imageRef)
+	ref, err := ro.ParseReference(imageRef)
 	if err != nil {
 		return err
 	}
 
-	get, err := remote.Get(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	get, err := remote.Get(ref, ro.ClientOpts(ctx)...)
 	if err != nil {
 		return err
 	}
@@ -78,7 +222,7 @@ func UploadCmd(ctx context.Context, sigRef, payloadRef, imageRef string) error {
 
 	var payload []byte
 	if payloadRef == "" {
-		payload, err = (&cosign.ImagePayload{Img: get.Descriptor}).MarshalJSON()
+		payload, err = (&cosign.ImagePayload{Img: get.Descriptor, Reference: ref.Context().Name()}).MarshalJSON()
 	} else {
 		payload, err = ioutil.ReadFile(filepath.Clean(payloadRef))
 	}
@@ -91,7 +235,7 @@ func UploadCmd(ctx context.Context, sigRef, payloadRef, imageRef string) error {
 	if err != nil {
 		return err
 	}
-	return cosign.Upload(sigBytes, payload, dstRef, "", "")
+	return cosign.Upload(ctx, sigBytes, payload, dstRef, cosign.SignatureMetadata{}, ro.ClientOpts(ctx)...)
 }
 
 type SignatureArgType uint8
@@ -15,18 +15,29 @@
 package cli
 
 import (
+	"bufio"
 	"context"
+	"crypto/x509"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/peterbourgon/ff/v3/ffcli"
 	"github.com/pkg/errors"
 
 	"github.com/sigstore/cosign/pkg/cosign"
 	"github.com/sigstore/cosign/pkg/cosign/fulcio"
+	"github.com/sigstore/cosign/pkg/cosign/spiffe"
 )
 
 // VerifyCommand verifies a signature on a supplied container image
@@ -35,7 +46,90 @@ type VerifyCommand struct {
 	KmsVal      string
 	Key         string
 	Output      string
+	Bundle      string
 	Annotations *map[string]string
+	Registry    RegistryOpts
+	// Input is a file listing images to verify, one per line, or "-" for stdin.
+	Input string
+	// Parallelism bounds how many images from Input or Repository are verified at once.
+	Parallelism int
+	// Repository treats the arguments as repositories, and verifies every tagged image in them.
+	Repository bool
+	// Report, if set, is a path to write a report of the results of Input or Repository to, in
+	// ReportFormat: sarif or junit, by default picked from the path's extension.
+	Report       string
+	ReportFormat string
+	// CacheTTL is how long fetched signatures and transparency log entries are reused for.
+	// Zero disables caching.
+	CacheTTL time.Duration
+	// Type is the kind of artifact being verified. For "helm", the claims about the chart's
+	// name and version are checked against the chart.
+	Type string
+	// TrustProfile names a profile from the trust store to verify against, see "cosign trust".
+	TrustProfile string
+	// PolicyKey, if set, verifies each image against the signed policy of its repository, which
+	// must be signed with this public key or KMS reference, see "cosign policy".
+	PolicyKey  string
+	policyKeys []cosign.PublicKey
+	// IdentityBook, if set, is a path to an identity book that IdentityBookKey signed, see
+	// cosign.IdentityBook. Each image must be signed by an identity the book allows to sign it.
+	// IdentityBookSignature is the path to the base64 signature, IdentityBook with a ".sig" suffix by default.
+	IdentityBook          string
+	IdentityBookKey       string
+	IdentityBookSignature string
+	identityBook          *cosign.IdentityBook
+	// CountersignKey and CountersignIdentities, if set, only accept signatures countersigned by the key,
+	// or by a Fulcio certificate for one of the identities, see "cosign countersign".
+	CountersignKey        string
+	CountersignIdentities []string
+	// RequireApprovals, if set, only accepts images approved by that many of ApproverKeys, or
+	// Fulcio certificates for distinct ApproverIdentities, see "cosign approve".
+	RequireApprovals   int
+	ApproverKeys       []string
+	ApproverIdentities []string
+	// SkipDigest and CheckReference pick the claims checked with CheckClaims: by default the
+	// docker-manifest-digest must be the image's digest, and optionally the docker-reference its repository.
+	SkipDigest     bool
+	CheckReference bool
+	// ReferenceEquivalents are "<a>=<b>" equivalences for CheckReference, see cosign.ParseReferenceEquivalent.
+	// Those in $COSIGN_REFERENCE_EQUIVALENTS, separated by commas, are added to them.
+	ReferenceEquivalents []string
+	// Digest enforces digest references, and records the digests that were verified.
+	Digest *DigestOpts
+	// Outputs records the results for the workflow steps that follow.
+	Outputs *StepOutputs
+	// Tlog picks whether the signatures must be in the transparency log, and RekorURL its address.
+	Tlog     *TlogOpts
+	RekorURL string
+	// SPIFFEIDs, if set, only accept signatures by X.509-SVIDs for one of these SPIFFE IDs, which chain up
+	// to the trust bundle in SPIFFEBundle, or the workload's own bundle from the SPIFFE Workload API.
+	SPIFFEIDs    []string
+	SPIFFEBundle string
+	// VerifyCT requires the signing certificates to be in Fulcio's certificate transparency log.
+	VerifyCT bool
+	// HardwareAttestationRoots, if set, is a path to the PEM vendor roots the keys' hardware attestations
+	// must chain up to, see cosign.VerifyHardwareAttestation.
+	HardwareAttestationRoots string
+	// SignaturesFile holds signatures printed by "cosign download signature", or "-" for stdin. SignatureFile,
+	// PayloadFile and CertFile hold a single one, as written by "cosign sign -output-signature". They are verified
+	// instead of the signatures in the registry, which isn't contacted, so the image must be referenced by digest.
+	SignaturesFile string
+	SignatureFile  string
+	PayloadFile    string
+	CertFile       string
+	// MaxDownloadSize bounds the bytes of signatures downloaded for each image, see cosign.WithMaxDownloadSize.
+	MaxDownloadSize int64
+}
+
+// referenceEquivalentsEnv lists equivalences for the docker-reference claim, see VerifyCommand.ReferenceEquivalents.
+const referenceEquivalentsEnv = "COSIGN_REFERENCE_EQUIVALENTS"
+
+// Artifact types verify can check extra claims for.
+const verifyTypeHelm = "helm"
+
+// addMaxDownloadSizeFlag registers the flag bounding what verification may download on fs.
+func addMaxDownloadSizeFlag(fs *flag.FlagSet, p *int64) {
+	fs.Int64Var(p, "max-download-size", cosign.DefaultMaxDownloadSize, "the most bytes of signature or attestation manifests and blobs to download for an image, larger ones fail verification. 0 for no limit")
 }
 
 // Verify builds and returns an ffcli command
@@ -47,7 +141,49 @@ func Verify() *ffcli.Command {
 	flagset.StringVar(&cmd.Key, "key", "", "path to the public key")
 	flagset.StringVar(&cmd.KmsVal, "kms", "", "verify via a public key stored in a KMS")
 	flagset.BoolVar(&cmd.CheckClaims, "check-claims", true, "whether to check the claims found")
-	flagset.StringVar(&cmd.Output, "output", "json", "output the signing image information. Default JSON.")
+	checkDigest := flagset.Bool("check-digest", true, "with -check-claims, check that the docker-manifest-digest claim is the image's digest")
+	flagset.BoolVar(&cmd.CheckReference, "check-reference", false, "with -check-claims, check that the docker-reference claim is the image's repository")
+	referenceEquivalents := filesFlag{}
+	flagset.Var(&referenceEquivalents, "reference-equivalent", "with -check-reference, also accept a docker-reference claim under <a> for an image under <b>, or the other way around, e.g. mirror.example.com=docker.io. May be repeated, or listed in $COSIGN_REFERENCE_EQUIVALENTS")
+	flagset.StringVar(&cmd.Output, "output", envOr("COSIGN_OUTPUT", "json"), "output the signing image information, json or text, or set $COSIGN_OUTPUT")
+	flagset.StringVar(&cmd.Bundle, "bundle", "", "path to a signature bundle to verify instead of the signatures in the registry")
+	flagset.StringVar(&cmd.Input, "input", "", "path to a file of image references to verify, one per line, or - for stdin. Results are printed as one JSON object per line")
+	flagset.IntVar(&cmd.Parallelism, "parallelism", 4, "how many images from -input or -repository to verify at once")
+	flagset.BoolVar(&cmd.Repository, "repository", false, "treat the arguments as repositories and verify every tagged image in them, reporting which are signed, unsigned or invalid")
+	flagset.StringVar(&cmd.Report, "report", "", "with -input or -repository, also write a report of the results to this path, for security dashboards and CI checks")
+	flagset.StringVar(&cmd.ReportFormat, "report-format", "", "format of the -report: sarif, or junit XML. By default junit for .xml paths, sarif otherwise")
+
+	flagset.DurationVar(&cmd.CacheTTL, "cache-ttl", 5*time.Minute, "how long to reuse fetched signatures and transparency log entries for, cached in $"+cosign.CacheDirEnv+" or the user cache directory")
+	flagset.StringVar(&cmd.TrustProfile, "trust-profile", "", "verify against the keys, roots and identities of a profile from \"cosign trust\"")
+	flagset.StringVar(&cmd.PolicyKey, "policy-key", "", "verify each image against the policy \"cosign policy sign\" signed for its repository or namespace with this public key, or KMS reference")
+	flagset.StringVar(&cmd.IdentityBook, "identity-book", "", "path to a signed JSON or YAML document of the identities allowed to sign each image, verified with -identity-book-key")
+	flagset.StringVar(&cmd.IdentityBookKey, "identity-book-key", "", "public key, or KMS reference, the -identity-book must be signed with")
+	flagset.StringVar(&cmd.IdentityBookSignature, "identity-book-signature", "", "path to the base64 signature of the -identity-book, by default the book's path with a .sig suffix")
+	flagset.StringVar(&cmd.Type, "type", "", "the kind of artifact to verify: helm also checks the chart name and version claims against the chart")
+	flagset.StringVar(&cmd.CountersignKey, "countersign-key", "", "only accept signatures countersigned by this public key, or KMS reference")
+	countersignIdentities := filesFlag{}
+	flagset.Var(&countersignIdentities, "countersign-identity", "only accept signatures countersigned with a certificate for this email, may be repeated")
+	flagset.IntVar(&cmd.RequireApprovals, "require-approvals", 0, "only accept images approved by this many distinct approvers from -approver-key or -approver-identity")
+	approverKeys, approverIdentities := filesFlag{}, filesFlag{}
+	flagset.Var(&approverKeys, "approver-key", "public key, or KMS reference, of an approver to count, may be repeated")
+	flagset.Var(&approverIdentities, "approver-identity", "certificate email of an approver to count, may be repeated")
+	noCache := flagset.Bool("no-cache", false, "don't read or write cached verification material")
+	spiffeIDs := filesFlag{}
+	flagset.Var(&spiffeIDs, "spiffe-id", "only accept signatures by an X.509-SVID for this SPIFFE ID, which may end in /* to match a path prefix, may be repeated")
+	flagset.StringVar(&cmd.SPIFFEBundle, "spiffe-bundle", "", "path to the PEM trust bundle -spiffe-id SVIDs must chain up to, by default the bundle from the SPIFFE Workload API at $"+spiffe.SocketEnv)
+	flagset.BoolVar(&cmd.VerifyCT, "verify-ct", false, "require the signing certificates to be in Fulcio's certificate transparency log at $FULCIO_CT_LOG_ADDRESS, checking their inclusion proofs")
+	flagset.StringVar(&cmd.HardwareAttestationRoots, "hardware-attestation-roots", "", "path to PEM vendor roots, e.g. Yubico's PIV attestation CA, that the signing keys must have a hardware attestation from")
+	flagset.StringVar(&cmd.SignaturesFile, "signatures", "", "path to signatures printed by \"cosign download signature\", or - for stdin, to verify offline instead of the signatures in the registry")
+	flagset.StringVar(&cmd.SignatureFile, "signature", "", "path to a base64 encoded signature to verify offline, with -payload")
+	flagset.StringVar(&cmd.PayloadFile, "payload", "", "path to the payload the -signature was made over")
+	flagset.StringVar(&cmd.CertFile, "cert", "", "path to the PEM certificate, and chain, the -signature was made with")
+	cmd.Registry.addFlags(flagset)
+	cmd.Registry.addMirrorFlag(flagset)
+	cmd.Digest = addDigestFlags(flagset)
+	cmd.Outputs = addOutputFlags(flagset)
+	cmd.Tlog = addTlogFlags(flagset)
+	addRekorURLFlag(flagset, &cmd.RekorURL)
+	addMaxDownloadSizeFlag(flagset, &cmd.MaxDownloadSize)
 
 	// parse annotations
 	flagset.Var(&annotations, "a", "extra key=value pairs to sign")
@@ -55,7 +191,7 @@ func Verify() *ffcli.Command {
 
 	return &ffcli.Command{
 		Name:       "verify",
-		ShortUsage: "cosign verify -key <key>|-kms <kms> <image uri>",
+		ShortUsage: "cosign verify -key <key>|-kms <kms> [-input <path>|-] <image uri>",
 		ShortHelp:  "Verify a signature on the supplied container image",
 		LongHelp: `Verify signature and annotations on an image by checking the claims
 against the transparency log.
@@ -70,79 +206,745 @@ EXAMPLES
   # (experimental) additionally, verify with the transparency log
   COSIGN_EXPERIMENTAL=1 cosign verify <IMAGE>
 
+  # require the signatures to be in the transparency log, whatever COSIGN_EXPERIMENTAL is set to
+  cosign verify -key <FILE> -require-tlog <IMAGE>
+
   # verify image with public key
   cosign verify -key <FILE> <IMAGE>
 
+  # also check that the signature was made for the image's repository, not copied from another one
+  cosign verify -key <FILE> -check-reference <IMAGE>
+
+  # also accept signatures made for docker.io images that are verified from a mirror
+  cosign verify -key <FILE> -check-reference -reference-equivalent mirror.example.com=docker.io mirror.example.com/library/nginx
+
+  # in a network where only a mirror of Docker Hub is reachable, look the image and signatures up there
+  cosign verify -key <FILE> -registry-mirror mirror.example.com nginx
+
+  # verify an image that was built but not pushed yet, by the digest it will be pushed with
+  cosign verify -key <FILE> docker://gcr.io/acme/app:dev
+
+  # verify every image listed in images.txt, 8 at a time, printing a JSON result per line
+  cosign verify -key <FILE> -input images.txt -parallelism 8
+
+  # audit a whole repository, reporting which tagged images are signed, unsigned or invalid
+  cosign verify -key <FILE> -repository <REPOSITORY>
+
+  # write the verified digest and signing identities as key=value lines for the next steps,
+  # GitHub Actions steps get them as step outputs without it
+  cosign verify -key cosign.pub -output-file results.env <IMAGE>
+
+  # audit a repository for a security dashboard, or a CI check that reads JUnit reports
+  cosign verify -key <FILE> -repository -report audit.sarif gcr.io/acme/app
+  cosign verify -key <FILE> -input images.txt -report results.xml
+
+  # verify image against a bundle written by "cosign sign -bundle"
+  cosign verify -key <FILE> -bundle <BUNDLE> <IMAGE>
+
+  # verify offline, without contacting the registry, signatures downloaded earlier
+  cosign download signature <IMAGE>@sha256:<DIGEST> > signatures.json
+  cosign verify -key <FILE> -signatures signatures.json <IMAGE>@sha256:<DIGEST>
+
+  # verify offline a keyless signature written by "cosign sign -output-signature -output-certificate"
+  COSIGN_EXPERIMENTAL=1 cosign verify -signature image.sig -payload payload.json -cert image.crt <IMAGE>@sha256:<DIGEST>
+
+  # verify a Helm chart, and that the signature is for its name and version
+  cosign verify -key <FILE> -type helm <CHART>
+
+  # verify image with public key, and that the security team countersigned the signature
+  cosign verify -key <FILE> -countersign-key security.pub <IMAGE>
+
+  # verify image with public key, and that two of the listed approvers approved it
+  cosign verify -key <FILE> -require-approvals 2 -approver-key alice.pub -approver-key bob.pub -approver-key carol.pub <IMAGE>
+
+  # only verify images referenced by digest, recording the digests verified
+  cosign verify -key <FILE> -require-digest -output-digest-file verified.txt <IMAGE>@sha256:<DIGEST>
+
+  # verify image was signed by a CI workload of the example.org SPIFFE trust domain
+  cosign verify -spiffe-id 'spiffe://example.org/ns/ci/*' -spiffe-bundle bundle.pem <IMAGE>
+
+  # also check that the Fulcio certificates are in the certificate transparency log
+  COSIGN_EXPERIMENTAL=1 cosign verify -verify-ct <IMAGE>
+
+  # only accept signatures by keys generated on a YubiKey
+  cosign verify -key <FILE> -hardware-attestation-roots yubico-piv-ca.pem <IMAGE>
+
+  # verify against the keys and identities of the prod trust profile
+  cosign verify -trust-profile prod <IMAGE>
+
+  # verify against the policy signed for the image's repository or namespace
+  cosign verify -policy-key policy.pub <IMAGE>
+
+  # verify each image was signed by an identity the signed identity book allows to sign it
+  cosign sign-blob -key book.key identities.yaml > identities.yaml.sig
+  COSIGN_EXPERIMENTAL=1 cosign verify -identity-book identities.yaml -identity-book-key book.pub <IMAGE>
+
+  # fail rather than download more than 1MiB of signatures from an untrusted repository
+  cosign verify -key <FILE> -max-download-size 1048576 <IMAGE>
+
   # verify image with public key stored in Google Cloud KMS
   cosign verify -kms  gcpkms://projects/<PROJECT>/locations/global/keyRings/<KEYRING>/cryptoKeys/<KEY> <IMAGE>`,
 		FlagSet: flagset,
-		Exec:    cmd.Exec,
+		Exec: func(ctx context.Context, args []string) error {
+			if *noCache {
+				cmd.CacheTTL = 0
+			}
+			cmd.SkipDigest = !*checkDigest
+			cmd.CountersignIdentities = countersignIdentities
+			cmd.ApproverKeys, cmd.ApproverIdentities = approverKeys, approverIdentities
+			cmd.SPIFFEIDs = spiffeIDs
+			cmd.ReferenceEquivalents = referenceEquivalents
+			return cmd.Exec(ctx, args)
+		},
 	}
 }
 
 // Exec runs the verification command
 func (c *VerifyCommand) Exec(ctx context.Context, args []string) error {
-	if len(args) == 0 {
+	if len(args) == 0 && c.Input == "" {
 		return flag.ErrHelp
 	}
 	if c.Key != "" && c.KmsVal != "" {
 		return &KeyParseError{}
 	}
+	ctx = cosign.WithMaxDownloadSize(ctx, c.MaxDownloadSize)
+	ctx, err := c.Registry.Context(ctx)
+	if err != nil {
+		return err
+	}
+	if c.Repository && c.Input != "" {
+		return errors.New("-repository and -input can't be used together")
+	}
+	if c.Report != "" {
+		if !c.Repository && c.Input == "" {
+			return errors.New("-report is only written for -input or -repository")
+		}
+		if c.ReportFormat, err = reportFormat(c.Report, c.ReportFormat); err != nil {
+			return err
+		}
+	}
+	if c.Type != "" && c.Type != verifyTypeHelm {
+		return fmt.Errorf("unsupported type %q, only %s is supported", c.Type, verifyTypeHelm)
+	}
+	if c.Bundle != "" && (len(args) != 1 || c.Input != "" || c.Repository) {
+		return errors.New("a bundle can only be verified against a single image")
+	}
+	if c.detached() {
+		if len(args) != 1 || c.Input != "" || c.Repository || c.Bundle != "" {
+			return errors.New("signatures from files can only be verified against a single image")
+		}
+		if c.Type != "" || c.PolicyKey != "" || c.IdentityBook != "" || c.CountersignKey != "" || len(c.CountersignIdentities) > 0 || c.RequireApprovals > 0 {
+			return errors.New("-type, -policy-key, -identity-book, countersignatures and approvals need the registry, they can't be verified offline")
+		}
+	}
 
+	tlog, err := c.Tlog.enabled()
+	if err != nil {
+		return err
+	}
 	co := cosign.CheckOpts{
-		Annotations: *c.Annotations,
-		Claims:      c.CheckClaims,
-		Tlog:        cosign.Experimental(),
-		Roots:       fulcio.Roots,
+		Annotations:        *c.Annotations,
+		ClaimVerification:  c.CheckClaims,
+		SkipDigestClaim:    c.SkipDigest,
+		TLog:               tlog,
+		RekorURL:           c.RekorURL,
+		Roots:              fulcio.Roots,
+		CTLog:              c.VerifyCT,
+		RegistryClientOpts: c.Registry.ClientOpts(ctx),
+	}
+	if co.ReferenceEquivalents, err = c.referenceEquivalents(); err != nil {
+		return err
+	}
+	if c.HardwareAttestationRoots != "" {
+		b, err := ioutil.ReadFile(filepath.Clean(c.HardwareAttestationRoots))
+		if err != nil {
+			return err
+		}
+		roots, err := cosign.LoadCerts(string(b))
+		if err != nil || len(roots) == 0 {
+			return fmt.Errorf("no certificates found in %s", c.HardwareAttestationRoots)
+		}
+		co.HardwareAttestationRoots = roots
+	}
+	if c.CacheTTL > 0 {
+		cache, err := cosign.NewCache(c.CacheTTL)
+		if err != nil {
+			return errors.Wrap(err, "locating cache directory")
+		}
+		co.Cache = cache
 	}
 	pubKeyDescriptor := c.Key
 	if c.KmsVal != "" {
 		pubKeyDescriptor = c.KmsVal
 	}
+	if c.TrustProfile != "" {
+		if pubKeyDescriptor != "" {
+			return errors.New("-trust-profile can't be used with -key or -kms")
+		}
+		ts, err := cosign.NewTrustStore()
+		if err != nil {
+			return err
+		}
+		tp, err := ts.Load(c.TrustProfile)
+		if err != nil {
+			return err
+		}
+		if err := tp.Apply(&co); err != nil {
+			return err
+		}
+	}
+	if c.PolicyKey != "" {
+		if pubKeyDescriptor != "" || c.TrustProfile != "" {
+			return errors.New("-policy-key can't be used with -key, -kms or -trust-profile")
+		}
+		pubKey, err := cosign.LoadPublicKey(ctx, c.PolicyKey)
+		if err != nil {
+			return errors.Wrap(err, "loading policy public key")
+		}
+		c.policyKeys = []cosign.PublicKey{pubKey}
+	}
+	if c.IdentityBook != "" {
+		if pubKeyDescriptor != "" || c.TrustProfile != "" || c.PolicyKey != "" {
+			return errors.New("-identity-book can't be used with -key, -kms, -trust-profile or -policy-key")
+		}
+		book, err := c.loadIdentityBook(ctx)
+		if err != nil {
+			return err
+		}
+		c.identityBook = book
+	}
+	if len(c.SPIFFEIDs) > 0 || c.SPIFFEBundle != "" {
+		if pubKeyDescriptor != "" || c.TrustProfile != "" || c.PolicyKey != "" || c.IdentityBook != "" {
+			return errors.New("-spiffe-id can't be used with -key, -kms, -trust-profile, -policy-key or -identity-book")
+		}
+		roots, err := c.spiffeRoots(ctx)
+		if err != nil {
+			return err
+		}
+		co.Roots, co.Identities = roots, c.SPIFFEIDs
+	}
 	// Keys are optional!
 	if pubKeyDescriptor != "" {
+		if c.VerifyCT {
+			return errors.New("-verify-ct only applies to signatures verified with certificates, not -key or -kms")
+		}
 		pubKey, err := cosign.LoadPublicKey(ctx, pubKeyDescriptor)
 		if err != nil {
 			return errors.Wrap(err, "loading public key")
 		}
-		co.PubKey = pubKey
+		co.Keys = []cosign.PublicKey{pubKey}
+	}
+	if c.CountersignKey != "" || len(c.CountersignIdentities) > 0 {
+		if c.CountersignKey != "" && len(c.CountersignIdentities) > 0 {
+			return errors.New("-countersign-key and -countersign-identity can't be used together")
+		}
+		cco := &cosign.CheckOpts{TLog: co.TLog, RekorURL: co.RekorURL, Roots: fulcio.Roots, Identities: c.CountersignIdentities}
+		if c.CountersignKey != "" {
+			pubKey, err := cosign.LoadPublicKey(ctx, c.CountersignKey)
+			if err != nil {
+				return errors.Wrap(err, "loading countersigner public key")
+			}
+			cco.Keys = []cosign.PublicKey{pubKey}
+		}
+		co.Countersigners = cco
+	}
+	if c.RequireApprovals > 0 {
+		aco, err := c.approverCheckOpts(ctx)
+		if err != nil {
+			return err
+		}
+		co.RequiredApprovals, co.Approvers = c.RequireApprovals, aco
+	}
+
+	if c.Repository {
+		refs := []string{}
+		for _, repo := range args {
+			images, err := c.repositoryImages(ctx, repo)
+			if err != nil {
+				return err
+			}
+			refs = append(refs, images...)
+		}
+		return c.verifyBatch(ctx, refs, co, os.Stdout)
+	}
+	if c.Input != "" {
+		refs, err := readImageRefs(c.Input)
+		if err != nil {
+			return err
+		}
+		return c.verifyBatch(ctx, append(args, refs...), co, os.Stdout)
 	}
 
 	for _, imageRef := range args {
-		ref, err := name.ParseReference(imageRef)
+		ref, local, err := c.Registry.parseImage(ctx, imageRef)
+		if err != nil {
+			return err
+		}
+		if err := c.Digest.check(ref); err != nil {
+			return err
+		}
+		if _, ok := ref.(name.Digest); c.detached() && !ok {
+			return fmt.Errorf("%s is not a digest reference, use %s@sha256:... to verify signatures from files", ref, ref.Context())
+		}
+		if local != nil {
+			if c.Bundle != "" {
+				return errors.New("-bundle can't be used with docker:// or containerd:// images")
+			}
+			ref = ref.Context().Digest(local.Digest.String())
+		}
+		if ref, err = c.Digest.resolve(ref, co.RegistryClientOpts); err != nil {
+			return err
+		}
+		co, err := c.imageCheckOpts(ctx, ref, co)
 		if err != nil {
 			return err
 		}
+		if c.CheckReference {
+			co.ReferenceClaim = ref.Context().Name()
+		}
 
-		verified, err := cosign.Verify(ctx, ref, co)
+		var verified []cosign.VerifiedSignature
+		if c.detached() {
+			verified, err = c.verifyDetached(ctx, ref, co)
+		} else if c.Bundle != "" {
+			verified, err = verifyBundle(ctx, ref, c.Bundle, co)
+		} else if local != nil {
+			verified, err = cosign.VerifyDescriptor(ctx, ref, local.Descriptor, co)
+		} else {
+			verified, err = cosign.Verify(ctx, ref, co)
+		}
 		if err != nil {
 			return err
 		}
 
 		c.printVerification(imageRef, verified, co)
+		if d, ok := ref.(name.Digest); ok {
+			c.Outputs.addVerification(d, verified)
+		}
+	}
+
+	if err := c.Digest.write(); err != nil {
+		return err
+	}
+	return c.Outputs.write()
+}
+
+// spiffeRoots returns the trust bundle SVIDs are verified against.
+func (c *VerifyCommand) spiffeRoots(ctx context.Context) (*x509.CertPool, error) {
+	if len(c.SPIFFEIDs) == 0 {
+		return nil, errors.New("-spiffe-bundle requires -spiffe-id")
+	}
+	for _, id := range c.SPIFFEIDs {
+		if !strings.HasPrefix(id, "spiffe://") {
+			return nil, fmt.Errorf("invalid SPIFFE ID %q", id)
+		}
+	}
+	roots := x509.NewCertPool()
+	if c.SPIFFEBundle != "" {
+		b, err := ioutil.ReadFile(filepath.Clean(c.SPIFFEBundle))
+		if err != nil {
+			return nil, err
+		}
+		if !roots.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in %s", c.SPIFFEBundle)
+		}
+		return roots, nil
 	}
+	socket, err := spiffe.Socket()
+	if err != nil {
+		return nil, errors.Wrap(err, "-spiffe-bundle isn't set")
+	}
+	svid, err := spiffe.FetchX509SVID(ctx, socket)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range svid.Bundle {
+		roots.AddCert(c)
+	}
+	return roots, nil
+}
+
+// approverCheckOpts returns the checks approvals must pass to be counted.
+func (c *VerifyCommand) approverCheckOpts(ctx context.Context) (*cosign.CheckOpts, error) {
+	switch {
+	case len(c.ApproverKeys) > 0 && len(c.ApproverIdentities) > 0:
+		return nil, errors.New("-approver-key and -approver-identity can't be used together")
+	case len(c.ApproverKeys) > 0:
+		aco := &cosign.CheckOpts{}
+		for _, k := range c.ApproverKeys {
+			pubKey, err := cosign.LoadPublicKey(ctx, k)
+			if err != nil {
+				return nil, errors.Wrapf(err, "loading approver key %s", k)
+			}
+			aco.Keys = append(aco.Keys, pubKey)
+		}
+		return aco, nil
+	case len(c.ApproverIdentities) > 0:
+		return &cosign.CheckOpts{Roots: fulcio.Roots, Identities: c.ApproverIdentities}, nil
+	default:
+		return nil, errors.New("-require-approvals needs -approver-key or -approver-identity")
+	}
+}
+
+// referenceEquivalents parses the equivalences from $COSIGN_REFERENCE_EQUIVALENTS and -reference-equivalent.
+func (c *VerifyCommand) referenceEquivalents() ([]cosign.ReferenceEquivalent, error) {
+	var eqs []cosign.ReferenceEquivalent
+	for _, s := range append(strings.Split(os.Getenv(referenceEquivalentsEnv), ","), c.ReferenceEquivalents...) {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		eq, err := cosign.ParseReferenceEquivalent(s)
+		if err != nil {
+			return nil, err
+		}
+		eqs = append(eqs, eq)
+	}
+	return eqs, nil
+}
+
+// imageCheckOpts adds what co checks that depends on the image at ref.
+func (c *VerifyCommand) imageCheckOpts(ctx context.Context, ref name.Reference, co cosign.CheckOpts) (cosign.CheckOpts, error) {
+	co, err := c.typeCheckOpts(ctx, ref, co)
+	if err != nil {
+		return co, err
+	}
+	if co, err = c.policyCheckOpts(ctx, ref, co); err != nil {
+		return co, err
+	}
+	if c.identityBook != nil {
+		co.Identities = c.identityBook.Identities(ref.Context())
+		if len(co.Identities) == 0 {
+			return co, fmt.Errorf("the identity book doesn't allow any identity to sign %s", ref.Context())
+		}
+	}
+	return co, nil
+}
+
+// typeCheckOpts adds the claims checked for c.Type about the artifact at ref to co.
+func (c *VerifyCommand) typeCheckOpts(ctx context.Context, ref name.Reference, co cosign.CheckOpts) (cosign.CheckOpts, error) {
+	if c.Type != verifyTypeHelm {
+		return co, nil
+	}
+	get, err := remote.Get(ref, co.RegistryClientOpts...)
+	if err != nil {
+		return co, errors.Wrap(err, "getting remote image")
+	}
+	chart, err := cosign.FetchHelmChart(get)
+	if err != nil {
+		return co, errors.Wrap(err, "reading helm chart")
+	}
+	if chart == nil {
+		return co, fmt.Errorf("%s is not a helm chart", ref)
+	}
+	annotations := chart.Annotations()
+	for k, v := range co.Annotations {
+		annotations[k] = v
+	}
+	co.Annotations = annotations
+	co.ClaimVerification = true
+	return co, nil
+}
 
+// policyCheckOpts applies the signed policy of ref's repository to co, with -policy-key.
+func (c *VerifyCommand) policyCheckOpts(ctx context.Context, ref name.Reference, co cosign.CheckOpts) (cosign.CheckOpts, error) {
+	if len(c.policyKeys) == 0 {
+		return co, nil
+	}
+	tp, err := cosign.FetchPolicy(ctx, ref.Context(), c.policyKeys, co.RegistryClientOpts...)
+	if err != nil {
+		return co, err
+	}
+	return co, tp.Apply(&co)
+}
+
+// loadIdentityBook returns the -identity-book, once its signature is verified with -identity-book-key.
+func (c *VerifyCommand) loadIdentityBook(ctx context.Context) (*cosign.IdentityBook, error) {
+	if c.IdentityBookKey == "" {
+		return nil, errors.New("-identity-book needs the -identity-book-key it is signed with")
+	}
+	pubKey, err := cosign.LoadPublicKey(ctx, c.IdentityBookKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading identity book public key")
+	}
+	b, err := ioutil.ReadFile(filepath.Clean(c.IdentityBook))
+	if err != nil {
+		return nil, err
+	}
+	sigPath := c.IdentityBookSignature
+	if sigPath == "" {
+		sigPath = c.IdentityBook + ".sig"
+	}
+	sig, err := ioutil.ReadFile(filepath.Clean(sigPath))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading identity book signature")
+	}
+	return cosign.LoadIdentityBook(ctx, b, string(sig), []cosign.PublicKey{pubKey})
+}
+
+// verifyBundle checks the signature in the bundle at bundlePath against the image ref.
+func verifyBundle(ctx context.Context, ref name.Reference, bundlePath string, co cosign.CheckOpts) ([]cosign.VerifiedSignature, error) {
+	b, err := ioutil.ReadFile(filepath.Clean(bundlePath))
+	if err != nil {
+		return nil, err
+	}
+	bundle, err := cosign.ParseBundle(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing bundle")
+	}
+	return cosign.VerifyBundle(ctx, ref, bundle, co)
+}
+
+// Statuses reported for each image verified from -input or -repository.
+const (
+	statusSigned   = "signed"
+	statusUnsigned = "unsigned"
+	statusInvalid  = "invalid"
+	statusError    = "error"
+)
+
+// verifyResult is the line printed for each image verified from -input or -repository.
+type verifyResult struct {
+	Image      string                 `json:"image"`
+	Verified   bool                   `json:"verified"`
+	Status     string                 `json:"status"`
+	Error      string                 `json:"error,omitempty"`
+	Signatures []cosign.SimpleSigning `json:"signatures,omitempty"`
+	// FailedClaims are the claim checks that signatures failed, when the image is invalid.
+	FailedClaims []cosign.ClaimError `json:"failedClaims,omitempty"`
+}
+
+// verifyBatch verifies the images concurrently, writing one JSON result per line to w as each finishes,
+// and a summary of the results to stderr.
+func (c *VerifyCommand) verifyBatch(ctx context.Context, imageRefs []string, co cosign.CheckOpts, w io.Writer) error {
+	parallelism := c.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	var (
+		mu     sync.Mutex
+		counts = map[string]int{}
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, parallelism)
+	)
+	// The results are only reused for this run, and not at all with -no-cache.
+	var results *cosign.ResultCache
+	if c.CacheTTL > 0 {
+		results = cosign.NewResultCache(c.CacheTTL, 0)
+	}
+	enc := json.NewEncoder(w)
+	start := time.Now()
+	// The report lists the images in the order given, whatever order they finish in.
+	report := make([]verifyResult, len(imageRefs))
+	for i, imageRef := range imageRefs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, imageRef string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			res := c.verifyImage(ctx, imageRef, co, results)
+
+			mu.Lock()
+			defer mu.Unlock()
+			counts[res.Status]++
+			report[i] = res
+			if err := enc.Encode(res); err != nil {
+				fmt.Fprintln(os.Stderr, "error writing result:", err)
+			}
+		}(i, imageRef)
+	}
+	wg.Wait()
+	if err := c.Digest.write(); err != nil {
+		return err
+	}
+	if c.Report != "" {
+		if err := writeReport(c.Report, c.ReportFormat, report, time.Since(start)); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d images: %d signed, %d unsigned, %d invalid, %d errors\n", len(imageRefs),
+		counts[statusSigned], counts[statusUnsigned], counts[statusInvalid], counts[statusError])
+	if failed := len(imageRefs) - counts[statusSigned]; failed > 0 {
+		return fmt.Errorf("%d of %d images failed verification", failed, len(imageRefs))
+	}
 	return nil
 }
 
+// verifyImage verifies a single image, telling apart images without any signatures from those
+// whose signatures don't pass. Successful results are reused from results.
+func (c *VerifyCommand) verifyImage(ctx context.Context, imageRef string, co cosign.CheckOpts, results *cosign.ResultCache) verifyResult {
+	res := verifyResult{Image: imageRef}
+	fail := func(status string, err error) verifyResult {
+		res.Status = status
+		res.Error = err.Error()
+		return res
+	}
+	ref, local, err := c.Registry.parseImage(ctx, imageRef)
+	if err != nil {
+		return fail(statusError, err)
+	}
+	if err := c.Digest.check(ref); err != nil {
+		return fail(statusInvalid, err)
+	}
+	if local != nil {
+		ref = ref.Context().Digest(local.Digest.String())
+	}
+	if ref, err = c.Digest.resolve(ref, co.RegistryClientOpts); err != nil {
+		return fail(statusError, err)
+	}
+	co, err = c.imageCheckOpts(ctx, ref, co)
+	if err != nil {
+		return fail(statusInvalid, err)
+	}
+	if c.CheckReference {
+		co.ReferenceClaim = ref.Context().Name()
+	}
+	var sps []cosign.SignedPayload
+	var desc *v1.Descriptor
+	if local != nil {
+		desc = &local.Descriptor
+		sps, err = cosign.FetchSignaturesFor(ctx, ref, *desc, co.RegistryClientOpts...)
+	} else {
+		sps, desc, err = co.Cache.FetchSignatures(ctx, ref, co.RegistryClientOpts...)
+	}
+	if err != nil {
+		if errors.Is(err, cosign.ErrNoSignatures) {
+			return fail(statusUnsigned, err)
+		}
+		return fail(statusError, err)
+	}
+	if len(sps) == 0 {
+		return fail(statusUnsigned, cosign.ErrNoSignatures)
+	}
+	// Tags often share digests, especially with -repository. Each digest is only verified once.
+	verified, err := results.Verify(ctx, desc.Digest, co, "", func() ([]cosign.VerifiedSignature, error) {
+		verified, err := cosign.VerifyPayloads(ctx, desc, sps, co)
+		if err != nil {
+			return nil, err
+		}
+		return cosign.VerifyEndorsements(ctx, ref, desc, verified, co)
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return fail(statusError, err)
+		}
+		res.FailedClaims = cosign.ClaimErrors(err)
+		return fail(statusInvalid, err)
+	}
+	res.Signatures, err = simpleSignings(verified)
+	if err != nil {
+		return fail(statusInvalid, err)
+	}
+	res.Verified = true
+	res.Status = statusSigned
+	return res
+}
+
+// repositoryImages lists the images tagged in repo, skipping the tags cosign stores signatures and attestations under.
+func (c *VerifyCommand) repositoryImages(ctx context.Context, repo string) ([]string, error) {
+	r, err := c.Registry.ParseRepository(repo)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := remote.List(r, c.Registry.ClientOpts(ctx)...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing tags in %s", repo)
+	}
+	refs := []string{}
+	for _, tag := range tags {
+		if isCosignTag(tag) {
+			continue
+		}
+		refs = append(refs, r.Tag(tag).String())
+	}
+	return refs, nil
+}
+
+// readImageRefs reads image references from path, or stdin for "-", one per line.
+// Blank lines and lines starting with # are skipped.
+func readImageRefs(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(filepath.Clean(path))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	refs := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		refs = append(refs, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// simpleSignings decodes the verified payloads, adding the certificate common name if there is one.
+func simpleSignings(verified []cosign.VerifiedSignature) ([]cosign.SimpleSigning, error) {
+	var out []cosign.SimpleSigning
+	for _, vp := range verified {
+		ss := cosign.SimpleSigning{}
+		if err := json.Unmarshal(vp.Payload, &ss); err != nil {
+			return nil, errors.Wrap(err, "decoding the payload")
+		}
+		if vp.Cert != nil {
+			if ss.Optional == nil {
+				ss.Optional = make(map[string]string)
+			}
+			ss.Optional["CommonName"] = vp.Cert.Subject.CommonName
+		}
+		out = append(out, ss)
+	}
+	return out, nil
+}
+
 // printVerification logs details about the verification to stdout
-func (c *VerifyCommand) printVerification(imgRef string, verified []cosign.SignedPayload, co cosign.CheckOpts) {
+func (c *VerifyCommand) printVerification(imgRef string, verified []cosign.VerifiedSignature, co cosign.CheckOpts) {
 	fmt.Fprintf(os.Stderr, "\nVerification for %s --\n", imgRef)
 	fmt.Fprintln(os.Stderr, "The following checks were performed on each of these signatures:")
-	if co.Claims {
+	if co.ClaimVerification {
 		if co.Annotations != nil {
 			fmt.Fprintln(os.Stderr, "  - The specified annotations were verified.")
 		}
 		fmt.Fprintln(os.Stderr, "  - The cosign claims were validated")
+		if !co.SkipDigestClaim {
+			fmt.Fprintln(os.Stderr, "  - The docker-manifest-digest claim matched the image")
+		}
+		if co.ReferenceClaim != "" {
+			if len(co.ReferenceEquivalents) > 0 {
+				fmt.Fprintln(os.Stderr, "  - The docker-reference claim matched the image's repository, or an equivalent repository")
+			} else {
+				fmt.Fprintln(os.Stderr, "  - The docker-reference claim matched the image's repository")
+			}
+		}
 	}
-	if co.Tlog {
+	if co.TLog {
 		fmt.Fprintln(os.Stderr, "  - The claims were present in the transparency log")
 		fmt.Fprintln(os.Stderr, "  - The signatures were integrated into the transparency log when the certificate was valid")
 	}
-	if co.PubKey != nil {
+	if len(co.Keys) > 0 {
 		fmt.Fprintln(os.Stderr, "  - The signatures were verified against the specified public key")
 	}
 	fmt.Fprintln(os.Stderr, "  - Any certificates were verified against the Fulcio roots.")
+	if co.CTLog {
+		fmt.Fprintln(os.Stderr, "  - The certificates were present in the certificate transparency log")
+	}
+	if len(co.HardwareAttestationRoots) > 0 {
+		fmt.Fprintln(os.Stderr, "  - The signing keys were attested to be generated on hardware by a trusted vendor")
+	}
+	if co.Countersigners != nil {
+		fmt.Fprintln(os.Stderr, "  - The signatures were countersigned by a trusted countersigner")
+	}
+	if co.RequiredApprovals > 0 {
+		fmt.Fprintf(os.Stderr, "  - The image was approved by at least %d trusted approvers\n", co.RequiredApprovals)
+	}
 
 	switch c.Output {
 	case "text":
@@ -150,27 +952,17 @@ func (c *VerifyCommand) printVerification(imgRef string, verified []cosign.Signe
 			if vp.Cert != nil {
 				fmt.Println("Certificate common name: ", vp.Cert.Subject.CommonName)
 			}
+			if vp.Hardware != nil {
+				fmt.Println("Hardware attestation: ", vp.Hardware.Issuer, vp.Hardware.Serial)
+			}
 
 			fmt.Println(string(vp.Payload))
 		}
 	default:
-		var outputKeys []cosign.SimpleSigning
-		for _, vp := range verified {
-			ss := cosign.SimpleSigning{}
-			err := json.Unmarshal(vp.Payload, &ss)
-			if err != nil {
-				fmt.Println("error decoding the payload:", err.Error())
-				return
-			}
-
-			if vp.Cert != nil {
-				if ss.Optional == nil {
-					ss.Optional = make(map[string]string)
-				}
-				ss.Optional["CommonName"] = vp.Cert.Subject.CommonName
-			}
-
-			outputKeys = append(outputKeys, ss)
+		outputKeys, err := simpleSignings(verified)
+		if err != nil {
+			fmt.Println("error", err.Error())
+			return
 		}
 
 		b, err := json.Marshal(outputKeys)
@@ -182,3 +974,11 @@ func (c *VerifyCommand) printVerification(imgRef string, verified []cosign.Signe
 		fmt.Printf("\n%s\n", string(b))
 	}
 }
+
+// envOr returns the environment variable key, or def if it is unset.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,385 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/fulcio"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// VerifyAttestationCommand verifies the attestations on a supplied container image
+type VerifyAttestationCommand struct {
+	KmsVal string
+	Key    string
+	// PredicateType, if set, only verifies attestations with this predicate type.
+	PredicateType string
+	// MinSLSALevel, if set, requires a verified SLSA provenance attestation of at least this level.
+	MinSLSALevel int
+	// TrustedBuilders is a path to a list of the builder IDs provenance may come from, one per line.
+	TrustedBuilders string
+	// MaxAttestationAge, if set, requires a verified vulnerability scan attestation that ran at most this long ago.
+	MaxAttestationAge time.Duration
+	// Layout is a path to a layout the attestations must satisfy, instead of checking them against a key.
+	Layout   string
+	Registry RegistryOpts
+	// Tlog picks whether the attestations must be in the transparency log, and RekorURL its address.
+	Tlog     *TlogOpts
+	RekorURL string
+	// MaxDownloadSize bounds the bytes of attestations downloaded for each image, see cosign.WithMaxDownloadSize.
+	MaxDownloadSize int64
+}
+
+// VerifyAttestation builds and returns an ffcli command
+func VerifyAttestation() *ffcli.Command {
+	cmd := VerifyAttestationCommand{}
+	flagset := flag.NewFlagSet("cosign verify-attestation", flag.ExitOnError)
+
+	flagset.StringVar(&cmd.Key, "key", "", "path to the public key")
+	flagset.StringVar(&cmd.KmsVal, "kms", "", "verify via a public key stored in a KMS")
+	flagset.StringVar(&cmd.PredicateType, "predicate-type", "", "only verify attestations with this predicate type, a URI or one of "+predicateTypeNames())
+	flagset.IntVar(&cmd.MinSLSALevel, "min-slsa-level", 0, "require SLSA provenance that achieves at least this SLSA level, 1 to 4")
+	flagset.StringVar(&cmd.TrustedBuilders, "trusted-builders", "", "path to a file of trusted builder IDs, one per line, that SLSA provenance must come from. IDs ending in * match by prefix")
+	flagset.Var((*ageFlag)(&cmd.MaxAttestationAge), "max-attestation-age", "require a vuln attestation whose scan ran at most this long ago, e.g. 7d or 36h")
+	flagset.StringVar(&cmd.Layout, "layout", "", "path to a layout of the steps, functionaries and thresholds the attestations must satisfy")
+	cmd.Registry.addFlags(flagset)
+	cmd.Registry.addMirrorFlag(flagset)
+	cmd.Tlog = addTlogFlags(flagset)
+	addRekorURLFlag(flagset, &cmd.RekorURL)
+	addMaxDownloadSizeFlag(flagset, &cmd.MaxDownloadSize)
+
+	return &ffcli.Command{
+		Name:       "verify-attestation",
+		ShortUsage: "cosign verify-attestation -key <key path>|<kms uri> [-predicate-type <type>] [-max-attestation-age <age>]|-layout <layout> <image uri> [<image uri> ...]",
+		ShortHelp:  "Verify the attestations on the supplied container image",
+		LongHelp: `Verify the attestations on the supplied container image.
+
+Each attestation must be signed by the key, or without one by a Fulcio certificate, and its
+in-toto statement must have the image as a subject. The statements that pass are printed
+as JSON, one per line.
+
+With -predicate-type, attestations with other predicate types are ignored rather than
+checked, so only (for example) provenance needs to be present and valid.
+
+The SLSA level of each verified SLSA provenance attestation is evaluated from what it records
+(builder, source, completeness, parameterless and hermetic builds) and reported. With
+-min-slsa-level, verification fails unless one of them achieves at least that level.
+
+Anyone can sign provenance claiming any builder. With -trusted-builders, every provenance
+attestation must name one of the builders listed in the file, one ID per line. An ID ending
+in * matches any builder with that prefix, such as every ref of a reusable workflow:
+
+  https://github.com/my-org/workflows/.github/workflows/build.yml@*
+  https://tekton.example.com/chains/sa/build-bot
+
+Vulnerability scans go stale as new vulnerabilities are found. With -max-attestation-age,
+verification fails unless a vuln attestation records a scan that finished (or, if it doesn't
+record the end, started) at most that long ago, in its metadata.scanFinishedOn or
+metadata.scanStartedOn. Only the fresh scans are printed.
+
+With -layout, the attestations are checked against a layout instead: a JSON policy listing
+the steps the image must have been through, the predicate type of each step's attestations,
+the keys or Fulcio identities of the functionaries allowed to perform it, and how many of
+them must attest. For each satisfied step, the functionaries who attested are printed.
+
+  {
+    "_type": "layout",
+    "expires": "2022-01-01T00:00:00Z",
+    "keys": {"builder": "-----BEGIN PUBLIC KEY-----\n..."},
+    "steps": [
+      {"name": "build", "predicateType": "slsaprovenance", "pubkeys": ["builder"]},
+      {"name": "review", "predicateType": "link", "identities": ["a@example.com", "b@example.com"], "threshold": 2}
+    ]
+  }
+
+EXAMPLES
+  # verify the SLSA provenance attested for an image
+  cosign verify-attestation -key cosign.pub -predicate-type slsaprovenance <IMAGE>
+
+  # require provenance of SLSA level 3 or higher
+  cosign verify-attestation -key cosign.pub -min-slsa-level 3 <IMAGE>
+
+  # only accept provenance from the builders in trusted-builders.txt
+  cosign verify-attestation -key cosign.pub -trusted-builders trusted-builders.txt <IMAGE>
+
+  # require a vulnerability scan from the last week
+  cosign verify-attestation -key cosign.pub -max-attestation-age 7d <IMAGE>
+
+  # verify the image went through every step of the supply chain
+  cosign verify-attestation -layout layout.json <IMAGE>
+
+  # verify attestations made with a Fulcio certificate
+  COSIGN_EXPERIMENTAL=1 cosign verify-attestation <IMAGE>`,
+		FlagSet: flagset,
+		Exec:    cmd.Exec,
+	}
+}
+
+// Exec runs the verification command
+func (c *VerifyAttestationCommand) Exec(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return flag.ErrHelp
+	}
+	if c.Key != "" && c.KmsVal != "" {
+		return &KeyParseError{}
+	}
+	ctx = cosign.WithMaxDownloadSize(ctx, c.MaxDownloadSize)
+	ctx, err := c.Registry.Context(ctx)
+	if err != nil {
+		return err
+	}
+	if c.MinSLSALevel < 0 || c.MinSLSALevel > cosign.MaxSLSALevel {
+		return fmt.Errorf("-min-slsa-level must be between 1 and %d", cosign.MaxSLSALevel)
+	}
+	var trusted []string
+	if c.TrustedBuilders != "" {
+		var err error
+		trusted, err = readImageRefs(c.TrustedBuilders)
+		if err != nil {
+			return errors.Wrap(err, "reading trusted builders")
+		}
+		if len(trusted) == 0 {
+			return fmt.Errorf("no builders listed in %s", c.TrustedBuilders)
+		}
+	}
+	if c.MinSLSALevel > 0 || trusted != nil {
+		if c.PredicateType != "" && cosign.PredicateTypeURI(c.PredicateType) != cosign.PredicateTypes["slsaprovenance"] {
+			return errors.New("-min-slsa-level and -trusted-builders only apply to slsaprovenance attestations")
+		}
+		c.PredicateType = "slsaprovenance"
+	}
+	if c.MaxAttestationAge < 0 {
+		return errors.New("-max-attestation-age must be positive")
+	}
+	if c.MaxAttestationAge > 0 {
+		if c.MinSLSALevel > 0 || trusted != nil || (c.PredicateType != "" && cosign.PredicateTypeURI(c.PredicateType) != cosign.PredicateTypes["vuln"]) {
+			return errors.New("-max-attestation-age only applies to vuln attestations")
+		}
+		c.PredicateType = "vuln"
+	}
+	tlog, err := c.Tlog.enabled()
+	if err != nil {
+		return err
+	}
+	if c.Layout != "" {
+		if c.Key != "" || c.KmsVal != "" || c.PredicateType != "" {
+			return errors.New("-layout can't be used with -key, -kms or -predicate-type, the layout names the keys and predicate types")
+		}
+		if c.Tlog != nil && c.Tlog.Require {
+			return errors.New("-require-tlog can't be used with -layout")
+		}
+		return c.verifyLayout(ctx, args, os.Stdout)
+	}
+	co := cosign.CheckOpts{
+		TLog:               tlog,
+		RekorURL:           c.RekorURL,
+		Roots:              fulcio.Roots,
+		RegistryClientOpts: c.Registry.ClientOpts(ctx),
+	}
+	pubKeyDescriptor := c.Key
+	if c.KmsVal != "" {
+		pubKeyDescriptor = c.KmsVal
+	}
+	if pubKeyDescriptor != "" {
+		pubKey, err := cosign.LoadPublicKey(ctx, pubKeyDescriptor)
+		if err != nil {
+			return errors.Wrap(err, "loading public key")
+		}
+		co.Keys = []cosign.PublicKey{pubKey}
+	}
+
+	for _, imageRef := range args {
+		if err := c.verify(ctx, imageRef, co, trusted, os.Stdout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *VerifyAttestationCommand) verify(ctx context.Context, imageRef string, co cosign.CheckOpts, trusted []string, w io.Writer) error {
+	ref, err := c.Registry.ParseReference(imageRef)
+	if err != nil {
+		return err
+	}
+	atts, desc, err := cosign.FetchAttestations(ctx, ref, co.RegistryClientOpts...)
+	if err != nil {
+		return errors.Wrap(err, "fetching attestations")
+	}
+	atts = cosign.FilterAttestations(atts, c.PredicateType)
+	if len(atts) == 0 {
+		return fmt.Errorf("no attestations with predicate type %s found for %s", cosign.PredicateTypeURI(c.PredicateType), imageRef)
+	}
+	verified, err := cosign.VerifyAttestations(ctx, desc, atts, co)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "\nVerification for %s --\n", imageRef)
+	fmt.Fprintln(os.Stderr, "The following checks were performed on each of these attestations:")
+	fmt.Fprintln(os.Stderr, "  - The statement subject matched the image digest")
+	if len(co.Keys) > 0 {
+		fmt.Fprintln(os.Stderr, "  - The attestations were verified against the specified public key")
+	} else {
+		fmt.Fprintln(os.Stderr, "  - The certificates were verified against the Fulcio roots.")
+	}
+	if co.TLog {
+		fmt.Fprintln(os.Stderr, "  - The envelopes were present in the transparency log as intoto entries")
+	}
+	if err := c.checkProvenance(verified, trusted); err != nil {
+		return err
+	}
+	if c.MaxAttestationAge > 0 {
+		if verified, err = c.freshScans(verified, time.Now()); err != nil {
+			return err
+		}
+	}
+	for _, va := range verified {
+		if err := printJSON(w, va.Statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkProvenance reports the SLSA level of each provenance attestation, and enforces c.MinSLSALevel
+// and the trusted builders, if any.
+func (c *VerifyAttestationCommand) checkProvenance(verified []cosign.VerifiedAttestation, trusted []string) error {
+	best := 0
+	for _, va := range verified {
+		if va.Statement.PredicateType != cosign.PredicateTypes["slsaprovenance"] {
+			continue
+		}
+		p, err := cosign.ParseSLSAProvenance(va.Statement)
+		if err != nil {
+			if trusted != nil {
+				return err
+			}
+			log.Warnf("%v", err)
+			continue
+		}
+		if trusted != nil && !p.TrustedBuilder(trusted) {
+			return fmt.Errorf("provenance is from untrusted builder %q", p.Builder.ID)
+		}
+		level := p.Level()
+		fmt.Fprintf(os.Stderr, "  - SLSA level %d provenance from builder %q\n", level.Level, p.Builder.ID)
+		for _, m := range level.Missing {
+			fmt.Fprintf(os.Stderr, "      level %d requires %s\n", level.Level+1, m)
+		}
+		if level.Level > best {
+			best = level.Level
+		}
+	}
+	if best < c.MinSLSALevel {
+		return fmt.Errorf("no provenance achieves SLSA level %d, the highest is level %d", c.MinSLSALevel, best)
+	}
+	return nil
+}
+
+// freshScans returns the vulnerability scans that ran at most c.MaxAttestationAge before now,
+// failing if there are none.
+func (c *VerifyAttestationCommand) freshScans(verified []cosign.VerifiedAttestation, now time.Time) ([]cosign.VerifiedAttestation, error) {
+	fresh := []cosign.VerifiedAttestation{}
+	var lastErr error
+	for _, va := range verified {
+		v, err := cosign.ParseVulnScan(va.Statement)
+		if err == nil {
+			err = v.CheckAge(c.MaxAttestationAge, now)
+		}
+		if err != nil {
+			log.Warnf("%v", err)
+			lastErr = err
+			continue
+		}
+		fresh = append(fresh, va)
+	}
+	if len(fresh) == 0 {
+		return nil, fmt.Errorf("no vulnerability scan from the last %s: %v", c.MaxAttestationAge, lastErr)
+	}
+	fmt.Fprintf(os.Stderr, "  - %d of the vulnerability scans ran in the last %s\n", len(fresh), c.MaxAttestationAge)
+	return fresh, nil
+}
+
+// ageFlag is a duration flag that also takes a number of days, such as 7d.
+type ageFlag time.Duration
+
+func (a *ageFlag) String() string {
+	return time.Duration(*a).String()
+}
+
+func (a *ageFlag) Set(s string) error {
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return fmt.Errorf("invalid number of days %q", s)
+		}
+		*a = ageFlag(time.Duration(n) * 24 * time.Hour)
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*a = ageFlag(d)
+	return nil
+}
+
+// verifyLayout checks the attestations on each image against the layout, printing the functionaries of each step.
+func (c *VerifyAttestationCommand) verifyLayout(ctx context.Context, imageRefs []string, w io.Writer) error {
+	b, err := ioutil.ReadFile(filepath.Clean(c.Layout))
+	if err != nil {
+		return err
+	}
+	layout, err := cosign.ParseLayout(b)
+	if err != nil {
+		return err
+	}
+	co := cosign.CheckOpts{Roots: fulcio.Roots}
+	for _, imageRef := range imageRefs {
+		ref, err := c.Registry.ParseReference(imageRef)
+		if err != nil {
+			return err
+		}
+		atts, desc, err := cosign.FetchAttestations(ctx, ref, c.Registry.ClientOpts(ctx)...)
+		if err != nil {
+			return errors.Wrap(err, "fetching attestations")
+		}
+		results, err := layout.Verify(ctx, desc, atts, co, time.Now())
+		if err != nil {
+			return errors.Wrapf(err, "verifying %s", imageRef)
+		}
+		fmt.Fprintf(os.Stderr, "\nVerification for %s --\n", imageRef)
+		fmt.Fprintf(os.Stderr, "All %d steps of the layout were attested by enough functionaries\n", len(results))
+		for _, res := range results {
+			if err := printJSON(w, res); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -15,12 +15,15 @@
 package cli
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -30,7 +33,8 @@ import (
 	"github.com/sigstore/cosign/pkg/cosign"
 	"github.com/sigstore/cosign/pkg/cosign/fulcio"
 	"github.com/sigstore/cosign/pkg/cosign/kms"
-	"github.com/sigstore/rekor/cmd/cli/app"
+	"github.com/sigstore/cosign/pkg/cosign/minisign"
+	"github.com/sigstore/cosign/pkg/cosign/sshsig"
 )
 
 func VerifyBlob() *ffcli.Command {
@@ -40,17 +44,36 @@ func VerifyBlob() *ffcli.Command {
 		kmsVal    = flagset.String("kms", "", "verify via a public key stored in a KMS")
 		cert      = flagset.String("cert", "", "path to the public certificate")
 		signature = flagset.String("signature", "", "path to the signature")
+		digest    = flagset.String("digest", "", "verify against a precomputed digest (sha256:<hex>) instead of a blob")
+		checksums = flagset.String("checksums", "", "path to a signed SHA256SUMS file to verify the blobs against")
+		tree      = flagset.String("tree", "", "path to a signed directory manifest to verify the directory against")
+		keyring   = flagset.String("keyring", "", "path to an OpenPGP keyring to verify a detached PGP signature against")
+		namespace = flagset.String("ssh-namespace", sshsig.DefaultNamespace, "namespace the signature was made in when verifying with an SSH key")
+		identity  = flagset.String("decrypt-identity", "", "path to an age identity file to decrypt the blob with once its signature is verified, see \"sign-blob -encrypt-to\"")
+		decOutput = flagset.String("decrypt-output", "", "path to write the blob decrypted with -decrypt-identity to, stdout by default")
+		tlog      = addTlogFlags(flagset)
+		rekorURL  string
 	)
+	addRekorURLFlag(flagset, &rekorURL)
 	return &ffcli.Command{
 		Name:       "verify-blob",
-		ShortUsage: "cosign verify-blob -key <key>|-cert <cert>|-kms <kms> -signature <sig> <blob>",
+		ShortUsage: "cosign verify-blob -key <key>|-cert <cert>|-kms <kms>|-keyring <keyring> -signature <sig> <blob>|-digest <digest>|-checksums <sums> <blob>...|-tree <manifest> <dir>",
 		ShortHelp:  "Verify a signature on the supplied blob",
 		LongHelp: `Verify a signature on the supplied blob input using the specified key reference.
 You may specify either a key, a certificate or a kms reference to verify against.
 	If you use a key or a certificate, you must specify the path to them on disk.
 
 The signature may be specified as a path to a file or a base64 encoded string.
+A file holding a DSSE envelope, bundle or PEM signature from "sign-blob -output-format" is also accepted.
+The certificate in a bundle is used if no key or certificate is specified.
+Public keys and signatures created by minisign or OpenBSD signify are also supported,
+as are OpenSSH public keys with signatures from "ssh-keygen -Y sign" or "sign-blob -ssh-key".
+Detached OpenPGP signatures, e.g. from "gpg --detach-sign", are verified against -keyring.
 The blob may be specified as a path to a file or - for stdin.
+If only the digest of the blob is available, pass it with -digest instead of the blob.
+With -checksums, the signature covers a SHA256SUMS file and each blob is checked against it.
+With -tree, the signature covers a directory manifest and the directory must match it exactly.
+The transparency log is checked with COSIGN_EXPERIMENTAL=1, or -require-tlog, and not with -insecure-ignore-tlog.
 
 EXAMPLES
 	# Verify a simple blob and message
@@ -62,16 +85,100 @@ EXAMPLES
 	# Verify a signature against a payload from another process using process redirection
 	cosign verify-blob -key cosign.pub -signature $sig <(git rev-parse HEAD)
 
+	# Verify a release signed with minisign or signify
+	cosign verify-blob -key minisign.pub -signature <FILE>.minisig <FILE>
+
+	# Verify a signature made with an SSH key
+	cosign verify-blob -key id_ed25519.pub -signature <FILE>.sig <FILE>
+
+	# Verify a GPG signed release against an exported keyring
+	cosign verify-blob -keyring release-keys.asc -signature <FILE>.asc <FILE>
+
+	# Verify a bundle from keyless signing, using the certificate inside it
+	cosign verify-blob -signature msg.bundle msg
+
+	# Verify a signature against a precomputed digest of the blob
+	cosign verify-blob -key cosign.pub -signature $sig -digest sha256:$(sha256sum msg | cut -d' ' -f1)
+
+	# Verify a signed SHA256SUMS file, then check release artifacts against it
+	cosign verify-blob -key cosign.pub -signature SHA256SUMS.sig -checksums SHA256SUMS <FILE> <FILE>...
+
+	# Verify a directory against a signed manifest, detecting added, removed or modified files
+	cosign verify-blob -key cosign.pub -signature manifest.sig -tree manifest.txt <DIRECTORY>
+
+	# Verify an encrypted blob from "sign-blob -encrypt-to", and only then decrypt it
+	cosign verify-blob -key cosign.pub -signature <FILE>.age.sig -decrypt-identity key.txt -decrypt-output <FILE> <FILE>.age
+
+	# Require the signature to be in the transparency log
+	cosign verify-blob -key cosign.pub -signature $sig -require-tlog msg
+
 	# Verify a signature against a KMS reference
 	cosign verify-blob -kms gcpkms://projects/<PROJECT ID>/locations/<LOCATION>/keyRings/<KEYRING>/cryptoKeys/<KEY> -signature $sig <blob>`,
 		FlagSet: flagset,
 		Exec: func(ctx context.Context, args []string) error {
+			check, err := tlog.enabled()
+			if err != nil {
+				return err
+			}
+			ctx = withTlog(ctx, check, rekorURL)
+			if tlog.Require && (*keyring != "" || sshPublicKey(*key) != nil || minisignKey(*key) != nil) {
+				return errors.New("OpenPGP, SSH and minisign signatures aren't in the transparency log, -require-tlog can't be used")
+			}
+			if *identity != "" && (*digest != "" || *tree != "" || *checksums != "" || *keyring != "") {
+				return errors.New("-decrypt-identity can't be used with -digest, -tree, -checksums or -keyring")
+			}
+			if *decOutput != "" && *identity == "" {
+				return errors.New("-decrypt-output requires -decrypt-identity")
+			}
+			if *digest != "" {
+				if len(args) != 0 {
+					return flag.ErrHelp
+				}
+				if err := VerifyBlobDigestCmd(ctx, *key, *kmsVal, *cert, *signature, *digest); err != nil {
+					return errors.Wrapf(err, "verifying digest %s", *digest)
+				}
+				return nil
+			}
+			if *tree != "" {
+				if len(args) != 1 {
+					return flag.ErrHelp
+				}
+				if err := VerifyTreeCmd(ctx, *key, *kmsVal, *cert, *signature, *tree, args[0]); err != nil {
+					return errors.Wrapf(err, "verifying directory %s", args[0])
+				}
+				return nil
+			}
+			if *checksums != "" {
+				if len(args) == 0 {
+					return flag.ErrHelp
+				}
+				if err := VerifyChecksumsCmd(ctx, *key, *kmsVal, *cert, *signature, *checksums, args); err != nil {
+					return errors.Wrapf(err, "verifying checksums %s", *checksums)
+				}
+				return nil
+			}
 			if len(args) != 1 {
 				return flag.ErrHelp
 			}
-			if err := VerifyBlobCmd(ctx, *key, *kmsVal, *cert, *signature, args[0]); err != nil {
+			if *keyring != "" {
+				if err := VerifyBlobPGPCmd(ctx, *keyring, *signature, args[0]); err != nil {
+					return errors.Wrapf(err, "verifying blob %s", args)
+				}
+				return nil
+			}
+			if *identity != "" && args[0] == "-" {
+				return errors.New("-decrypt-identity needs the blob in a file, it is read twice")
+			}
+			if sshPublicKey(*key) != nil {
+				if err := VerifyBlobSSHCmd(ctx, *key, *namespace, *signature, args[0]); err != nil {
+					return errors.Wrapf(err, "verifying blob %s", args)
+				}
+			} else if err := VerifyBlobCmd(ctx, *key, *kmsVal, *cert, *signature, args[0]); err != nil {
 				return errors.Wrapf(err, "verifying blob %s", args)
 			}
+			if *identity != "" {
+				return DecryptBlobCmd(*identity, args[0], *decOutput)
+			}
 			return nil
 		},
 	}
@@ -83,112 +190,382 @@ func isb64(data []byte) bool {
 }
 
 func VerifyBlobCmd(ctx context.Context, keyRef, kmsVal, certRef, sigRef, blobRef string) error {
-	var pubKey cosign.PublicKey
-	var err error
-	var cert *x509.Certificate
-	switch {
-	case keyRef != "":
-		pubKey, err = cosign.LoadPublicKey(ctx, keyRef)
+	if pk := minisignKey(keyRef); pk != nil {
+		return verifyBlobMinisign(pk, sigRef, blobRef)
+	}
+	if bundle := blobBundle(sigRef); bundle != nil {
+		return verifyBlobBundle(ctx, keyRef, kmsVal, certRef, bundle, blobRef)
+	}
+	pubKey, cert, err := blobVerifier(ctx, keyRef, kmsVal, certRef)
+	if err != nil {
+		return err
+	}
+	if env := blobEnvelope(sigRef); env != nil {
+		return verifyBlobEnvelope(ctx, pubKey, cert, env, blobRef)
+	}
+	b64sig, err := blobSignature(sigRef)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(b64sig)
+	if err != nil {
+		return err
+	}
+
+	r, closer, err := blobReader(blobRef)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	// The rekord entry embeds the full blob, so we can only stream when not checking the tlog.
+	var blobBytes []byte
+	if checkTlog(ctx) {
+		blobBytes, err = ioutil.ReadAll(r)
 		if err != nil {
 			return err
 		}
-	case kmsVal != "":
-		pubKey, err = kms.Get(ctx, kmsVal)
-		if err != nil {
-			return errors.Wrap(err, "getting kms")
-		}
-	case certRef != "": // KEYLESS MODE!
-		pems, err := ioutil.ReadFile(certRef)
-		if err != nil {
-			return err
+		r = bytes.NewReader(blobBytes)
+	}
+
+	if err := cosign.VerifyBlob(ctx, pubKey, r, sig); err != nil {
+		return err
+	}
+	if err := verifyBlobCert(cert); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Verified OK")
+
+	if checkTlog(ctx) {
+		return verifyBlobTlog(ctx, pubKey, cert, b64sig, blobBytes)
+	}
+	return nil
+}
+
+// verifyBlobEnvelope verifies a DSSE envelope and checks that it wraps the blob at blobRef.
+func verifyBlobEnvelope(ctx context.Context, pubKey cosign.PublicKey, cert *x509.Certificate, env *cosign.Envelope, blobRef string) error {
+	payload, err := cosign.VerifyEnvelope(ctx, pubKey, env)
+	if err != nil {
+		return err
+	}
+	r, closer, err := blobReader(blobRef)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	want, err := cosign.HashReader(r)
+	if err != nil {
+		return err
+	}
+	if got := sha256.Sum256(payload); !bytes.Equal(got[:], want) {
+		return errors.New("envelope payload does not match the blob")
+	}
+	if err := verifyBlobCert(cert); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Verified OK (payloadType: %s)\n", env.PayloadType)
+
+	if checkTlog(ctx) {
+		// We don't know which of the signatures was ours, so try each of them.
+		pae := cosign.PAE(env.PayloadType, payload)
+		for _, sig := range env.Signatures {
+			if err = verifyBlobTlog(ctx, pubKey, cert, sig.Sig, pae); err == nil {
+				return nil
+			}
 		}
+		return err
+	}
+	return nil
+}
 
-		certs, err := cosign.LoadCerts(string(pems))
+// verifyBlobBundle verifies a bundle produced by "sign-blob -output-format bundle" against the blob.
+func verifyBlobBundle(ctx context.Context, keyRef, kmsVal, certRef string, bundle *cosign.Bundle, blobRef string) error {
+	pubKey, cert, err := bundleVerifier(ctx, keyRef, kmsVal, certRef, bundle)
+	if err != nil {
+		return err
+	}
+	if bundle.DSSEEnvelope != nil {
+		return verifyBlobEnvelope(ctx, pubKey, cert, bundle.DSSEEnvelope, blobRef)
+	}
+	r, closer, err := blobReader(blobRef)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	return verifyBundleMessage(ctx, pubKey, cert, bundle.MessageSignature, r)
+}
+
+// bundleVerifier returns the key to verify a bundle with. The certificate in a bundle can be used
+// directly since it must chain up to the Fulcio roots, but a bare public key is only a hint and
+// has to be passed explicitly.
+func bundleVerifier(ctx context.Context, keyRef, kmsVal, certRef string, bundle *cosign.Bundle) (cosign.PublicKey, *x509.Certificate, error) {
+	switch {
+	case keyRef != "" || kmsVal != "" || certRef != "":
+		return blobVerifier(ctx, keyRef, kmsVal, certRef)
+	case bundle.VerificationMaterial.Certificate != "":
+		certs, err := cosign.LoadCerts(bundle.VerificationMaterial.Certificate)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 		if len(certs) == 0 {
-			return errors.New("no certs found in pem file")
+			return nil, nil, errors.New("no certs found in bundle")
 		}
-		cert = certs[0]
-		pubKey = &cosign.ECDSAPublicKey{
+		cert := certs[0]
+		pubKey := &cosign.ECDSAPublicKey{
 			Key: cert.PublicKey.(*ecdsa.PublicKey),
 		}
+		return pubKey, cert, nil
 	default:
-		return errors.New("one of -key and -cert required")
+		return nil, nil, errors.New("bundle does not contain a certificate, one of -key, -kms and -cert required")
 	}
+}
 
-	var b64sig string
-	// This can be the base64-encoded bytes or a path to the signature
-	if _, err = os.Stat(sigRef); err != nil {
-		if os.IsNotExist(err) {
-			b64sig = sigRef
-		} else {
+// verifyBundleMessage verifies the message signature of a bundle against the content read from r.
+func verifyBundleMessage(ctx context.Context, pubKey cosign.PublicKey, cert *x509.Certificate, ms *cosign.MessageSignature, r io.Reader) error {
+	if ms == nil {
+		return errors.New("bundle does not contain a signature")
+	}
+	wantDigest, err := base64.StdEncoding.DecodeString(ms.MessageDigest.Digest)
+	if err != nil {
+		return errors.Wrap(err, "decoding digest")
+	}
+	sig, err := base64.StdEncoding.DecodeString(ms.Signature)
+	if err != nil {
+		return errors.Wrap(err, "decoding signature")
+	}
+
+	var blobBytes []byte
+	if checkTlog(ctx) {
+		blobBytes, err = ioutil.ReadAll(r)
+		if err != nil {
 			return err
 		}
+		r = bytes.NewReader(blobBytes)
+	}
+	h, err := cosign.BundleDigestHash(ms.MessageDigest.Algorithm)
+	if err != nil {
+		return err
+	}
+	digest, err := cosign.HashReaderWith(r, h)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(digest, wantDigest) {
+		return errors.New("bundle digest does not match the blob")
+	}
+	if err := pubKey.VerifyDigest(ctx, digest, sig); err != nil {
+		return err
+	}
+	if err := verifyBlobCert(cert); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Verified OK")
+
+	if checkTlog(ctx) {
+		return verifyBlobTlog(ctx, pubKey, cert, ms.Signature, blobBytes)
+	}
+	return nil
+}
+
+func verifyBlobTlog(ctx context.Context, pubKey cosign.PublicKey, cert *x509.Certificate, b64sig string, payload []byte) error {
+	rekorClient, err := tlogClient(ctx)
+	if err != nil {
+		return err
+	}
+	var pubBytes []byte
+	if cert != nil {
+		pubBytes = cosign.CertToPem(cert)
 	} else {
-		b, err := ioutil.ReadFile(filepath.Clean(sigRef))
+		pubBytes, err = cosign.PublicKeyPem(ctx, pubKey)
 		if err != nil {
 			return err
 		}
-		// If in a file, it could be raw or base64-encoded.
-		// We want them to be encoded eventually, but not double encoded!
-		if isb64(b) {
-			b64sig = string(b)
-		} else {
-			b64sig = base64.StdEncoding.EncodeToString(b)
-		}
 	}
+	index, err := cosign.FindTlogEntry(ctx, rekorClient, b64sig, payload, pubBytes)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "tlog entry verified with index: ", index)
+	return nil
+}
 
-	var blobBytes []byte
-	if blobRef == "-" {
-		blobBytes, err = ioutil.ReadAll(os.Stdin)
-	} else {
-		blobBytes, err = ioutil.ReadFile(filepath.Clean(blobRef))
+// VerifyBlobDigestCmd verifies a blob signature given only the digest of the blob.
+func VerifyBlobDigestCmd(ctx context.Context, keyRef, kmsVal, certRef, sigRef, digestRef string) error {
+	if checkTlog(ctx) {
+		// rekord entries are searched by their full content, which we don't have.
+		return errors.New("transparency log verification requires the blob, not just its digest")
 	}
+	digest, err := cosign.ParseDigest(digestRef)
 	if err != nil {
 		return err
 	}
-
-	sig, err := base64.StdEncoding.DecodeString(b64sig)
+	pubKey, cert, err := blobVerifier(ctx, keyRef, kmsVal, certRef)
 	if err != nil {
 		return err
 	}
-	if err := pubKey.Verify(ctx, blobBytes, sig); err != nil {
+	b64sig, err := blobSignature(sigRef)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(b64sig)
+	if err != nil {
 		return err
 	}
 
-	if cert != nil { // cert
-		if err := cosign.TrustedCert(cert, fulcio.Roots); err != nil {
-			return err
-		}
-		fmt.Fprintln(os.Stderr, "Certificate is trusted by Fulcio Root CA")
-		fmt.Fprintln(os.Stderr, "Email:", cert.Subject.CommonName)
+	if err := pubKey.VerifyDigest(ctx, digest, sig); err != nil {
+		return err
+	}
+	if err := verifyBlobCert(cert); err != nil {
+		return err
 	}
 	fmt.Fprintln(os.Stderr, "Verified OK")
+	return nil
+}
 
-	if cosign.Experimental() {
-		rekorClient, err := app.GetRekorClient(cosign.TlogServer())
+// blobVerifier loads the public key to verify blobs against from a key, KMS or certificate reference.
+// The certificate is only returned in keyless mode.
+func blobVerifier(ctx context.Context, keyRef, kmsVal, certRef string) (cosign.PublicKey, *x509.Certificate, error) {
+	switch {
+	case keyRef != "":
+		pubKey, err := cosign.LoadPublicKey(ctx, keyRef)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
-		var pubBytes []byte
-		if pubKey != nil {
-			pubBytes, err = cosign.PublicKeyPem(ctx, pubKey)
-			if err != nil {
-				return err
-			}
+		return pubKey, nil, nil
+	case kmsVal != "":
+		pubKey, err := kms.Get(ctx, kmsVal)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "getting kms")
 		}
-		if cert != nil {
-			pubBytes = cosign.CertToPem(cert)
+		return pubKey, nil, nil
+	case certRef != "": // KEYLESS MODE!
+		pems, err := ioutil.ReadFile(certRef)
+		if err != nil {
+			return nil, nil, err
 		}
-		index, err := cosign.FindTlogEntry(rekorClient, b64sig, blobBytes, pubBytes)
+
+		certs, err := cosign.LoadCerts(string(pems))
 		if err != nil {
-			return err
+			return nil, nil, err
+		}
+		if len(certs) == 0 {
+			return nil, nil, errors.New("no certs found in pem file")
+		}
+		cert := certs[0]
+		pubKey := &cosign.ECDSAPublicKey{
+			Key: cert.PublicKey.(*ecdsa.PublicKey),
 		}
-		fmt.Fprintln(os.Stderr, "tlog entry verified with index: ", index)
+		return pubKey, cert, nil
+	default:
+		return nil, nil, errors.New("one of -key and -cert required")
+	}
+}
+
+// blobEnvelope returns the DSSE envelope stored at sigRef, or nil if it doesn't hold one.
+func blobEnvelope(sigRef string) *cosign.Envelope {
+	b, err := ioutil.ReadFile(filepath.Clean(sigRef))
+	if err != nil {
+		return nil
+	}
+	env, err := cosign.ParseEnvelope(b)
+	if err != nil {
+		return nil
+	}
+	return env
+}
+
+// minisignKey returns the minisign or signify public key at keyRef, or nil if it doesn't hold one.
+func minisignKey(keyRef string) *minisign.PublicKey {
+	if keyRef == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(filepath.Clean(keyRef))
+	if err != nil {
+		return nil
+	}
+	pk, err := minisign.ParsePublicKey(b)
+	if err != nil {
 		return nil
 	}
+	return pk
+}
 
+// verifyBlobMinisign verifies a minisign or signify signature file against the blob.
+func verifyBlobMinisign(pk *minisign.PublicKey, sigRef, blobRef string) error {
+	if cosign.FIPSMode() {
+		return errors.New("minisign signatures use Ed25519, which is not allowed in FIPS mode")
+	}
+	b, err := ioutil.ReadFile(filepath.Clean(sigRef))
+	if err != nil {
+		return err
+	}
+	sig, err := minisign.ParseSignature(b)
+	if err != nil {
+		return err
+	}
+	r, closer, err := blobReader(blobRef)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	if err := pk.Verify(r, sig); err != nil {
+		return err
+	}
+	if sig.TrustedComment != "" {
+		fmt.Fprintln(os.Stderr, "Trusted comment:", sig.TrustedComment)
+	}
+	fmt.Fprintln(os.Stderr, "Verified OK")
+	return nil
+}
+
+// blobBundle returns the bundle stored at sigRef, or nil if it doesn't hold one.
+func blobBundle(sigRef string) *cosign.Bundle {
+	b, err := ioutil.ReadFile(filepath.Clean(sigRef))
+	if err != nil {
+		return nil
+	}
+	bundle, err := cosign.ParseBundle(b)
+	if err != nil {
+		return nil
+	}
+	return bundle
+}
+
+// blobSignature returns the base64-encoded signature found at sigRef.
+func blobSignature(sigRef string) (string, error) {
+	// This can be the base64-encoded bytes or a path to the signature
+	if _, err := os.Stat(sigRef); err != nil {
+		if os.IsNotExist(err) {
+			return sigRef, nil
+		}
+		return "", err
+	}
+	b, err := ioutil.ReadFile(filepath.Clean(sigRef))
+	if err != nil {
+		return "", err
+	}
+	if sig, headers, err := cosign.SignatureFromPem(b); err == nil {
+		if fp, ok := headers[cosign.FingerprintHeader]; ok {
+			fmt.Fprintln(os.Stderr, "Signature claims to be made by key with fingerprint:", fp)
+		}
+		return base64.StdEncoding.EncodeToString(sig), nil
+	}
+	// If in a file, it could be raw or base64-encoded.
+	// We want them to be encoded eventually, but not double encoded!
+	if isb64(b) {
+		return string(b), nil
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+func verifyBlobCert(cert *x509.Certificate) error {
+	if cert == nil {
+		return nil
+	}
+	if err := cosign.TrustedCert(cert, fulcio.Roots); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Certificate is trusted by Fulcio Root CA")
+	fmt.Fprintln(os.Stderr, "Email:", cert.Subject.CommonName)
 	return nil
 }
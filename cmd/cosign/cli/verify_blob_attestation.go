@@ -0,0 +1,192 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/fulcio"
+)
+
+// VerifyBlobAttestationCommand verifies an attestation about a blob, from "cosign attest-blob".
+type VerifyBlobAttestationCommand struct {
+	KmsVal string
+	Key    string
+	// Cert is a path to the certificate the attestation was signed with, followed by its chain.
+	Cert string
+	// Attestation is a path to the DSSE envelope of the attestation.
+	Attestation string
+	// PredicateType, if set, requires the attestation to have this predicate type.
+	PredicateType string
+	// Digest is the digest of the blob, sha256:<hex>, to verify against instead of the blob itself.
+	Digest string
+	// Tlog picks whether the attestation must be in the transparency log, and RekorURL its address.
+	Tlog     *TlogOpts
+	RekorURL string
+}
+
+// VerifyBlobAttestation builds and returns an ffcli command
+func VerifyBlobAttestation() *ffcli.Command {
+	cmd := VerifyBlobAttestationCommand{}
+	flagset := flag.NewFlagSet("cosign verify-blob-attestation", flag.ExitOnError)
+
+	flagset.StringVar(&cmd.Key, "key", "", "path to the public key")
+	flagset.StringVar(&cmd.KmsVal, "kms", "", "verify via a public key stored in a KMS")
+	flagset.StringVar(&cmd.Cert, "cert", "", "path to the certificate the attestation was signed with, and its chain")
+	flagset.StringVar(&cmd.Attestation, "attestation", "", "path to the attestation")
+	flagset.StringVar(&cmd.PredicateType, "predicate-type", "", "require the attestation to have this predicate type, a URI or one of "+predicateTypeNames())
+	flagset.StringVar(&cmd.Digest, "digest", "", "verify against a precomputed digest (sha256:<hex>) instead of a blob")
+	cmd.Tlog = addTlogFlags(flagset)
+	addRekorURLFlag(flagset, &cmd.RekorURL)
+
+	return &ffcli.Command{
+		Name:       "verify-blob-attestation",
+		ShortUsage: "cosign verify-blob-attestation -key <key path>|<kms uri>|-cert <cert> -attestation <path> [-predicate-type <type>] <blob>|-digest <digest>",
+		ShortHelp:  "Verify an attestation about the supplied blob",
+		LongHelp: `Verify an attestation about the supplied blob, from "cosign attest-blob".
+
+The attestation must be signed by the key, or by the Fulcio certificate in -cert, and its
+in-toto statement must have the SHA256 digest of the blob as a subject. Only the digest is
+compared, so the blob may have been renamed since it was attested. The statement is printed
+as JSON when it passes.
+If only the digest of the blob is available, pass it with -digest instead of the blob.
+The transparency log is checked with COSIGN_EXPERIMENTAL=1, or -require-tlog, and not with -insecure-ignore-tlog.
+
+EXAMPLES
+  # verify the SLSA provenance of a release tarball
+  cosign verify-blob-attestation -key cosign.pub -attestation <FILE>.intoto.json -predicate-type slsaprovenance <FILE>
+
+  # verify against a precomputed digest of the blob
+  cosign verify-blob-attestation -key cosign.pub -attestation <FILE>.intoto.json -digest sha256:$(sha256sum <FILE> | cut -d' ' -f1)
+
+  # verify an attestation made with Google sign-in, and find it in the transparency log
+  COSIGN_EXPERIMENTAL=1 cosign verify-blob-attestation -cert <FILE>.pem -attestation <FILE>.intoto.json <FILE>`,
+		FlagSet: flagset,
+		Exec:    cmd.Exec,
+	}
+}
+
+// Exec runs the verification command
+func (c *VerifyBlobAttestationCommand) Exec(ctx context.Context, args []string) error {
+	if c.Attestation == "" {
+		return flag.ErrHelp
+	}
+	blob := c.Digest
+	switch {
+	case c.Digest != "" && len(args) == 0:
+	case c.Digest == "" && len(args) == 1:
+		blob = args[0]
+	default:
+		return flag.ErrHelp
+	}
+	_, err := c.verify(ctx, blob, os.Stdout)
+	return err
+}
+
+// verify checks the attestation is about blob, or c.Digest, and writes its statement to w.
+func (c *VerifyBlobAttestationCommand) verify(ctx context.Context, blob string, w io.Writer) (*cosign.VerifiedAttestation, error) {
+	set := 0
+	for _, v := range []string{c.Key, c.KmsVal, c.Cert} {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, &KeyParseError{}
+	}
+	tlog, err := c.Tlog.enabled()
+	if err != nil {
+		return nil, err
+	}
+	var digest v1.Hash
+	if c.Digest != "" {
+		if _, err := cosign.ParseDigest(c.Digest); err != nil {
+			return nil, err
+		}
+		if digest, err = v1.NewHash(c.Digest); err != nil {
+			return nil, err
+		}
+	} else if digest, err = blobDigest(blob); err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadFile(filepath.Clean(c.Attestation))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading attestation")
+	}
+	env, err := cosign.ParseEnvelope(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing attestation %s", c.Attestation)
+	}
+	att := cosign.Attestation{Envelope: *env}
+	co := cosign.CheckOpts{TLog: tlog, RekorURL: c.RekorURL}
+	if c.Cert != "" {
+		pems, err := ioutil.ReadFile(filepath.Clean(c.Cert))
+		if err != nil {
+			return nil, err
+		}
+		certs, err := cosign.LoadCerts(string(pems))
+		if err != nil {
+			return nil, err
+		}
+		if len(certs) == 0 {
+			return nil, errors.New("no certs found in pem file")
+		}
+		att.Cert, att.Chain = certs[0], certs[1:]
+		co.Roots = fulcio.Roots
+	} else {
+		pubKey, _, err := blobVerifier(ctx, c.Key, c.KmsVal, "")
+		if err != nil {
+			return nil, err
+		}
+		co.Keys = []cosign.PublicKey{pubKey}
+	}
+
+	atts := cosign.FilterAttestations([]cosign.Attestation{att}, c.PredicateType)
+	if len(atts) == 0 {
+		return nil, fmt.Errorf("the attestation doesn't have predicate type %s", cosign.PredicateTypeURI(c.PredicateType))
+	}
+	verified, err := cosign.VerifyAttestations(ctx, &v1.Descriptor{Digest: digest}, atts, co)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(os.Stderr, "\nVerification for %s --\n", blob)
+	fmt.Fprintln(os.Stderr, "The following checks were performed on the attestation:")
+	fmt.Fprintln(os.Stderr, "  - The statement subject matched the blob digest")
+	if len(co.Keys) > 0 {
+		fmt.Fprintln(os.Stderr, "  - The attestation was verified against the specified public key")
+	} else {
+		fmt.Fprintln(os.Stderr, "  - The certificate was verified against the Fulcio roots.")
+	}
+	if co.TLog {
+		fmt.Fprintln(os.Stderr, "  - The envelope was present in the transparency log as an intoto entry")
+	}
+	if err := printJSON(w, verified[0].Statement); err != nil {
+		return nil, err
+	}
+	return &verified[0], nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+// detached reports whether c verifies signatures from files instead of those in the registry.
+func (c *VerifyCommand) detached() bool {
+	return c.SignaturesFile != "" || c.SignatureFile != "" || c.PayloadFile != "" || c.CertFile != ""
+}
+
+// detachedSignatures loads the signatures from -signatures, or the one from -signature and -payload.
+func (c *VerifyCommand) detachedSignatures() ([]cosign.SignedPayload, error) {
+	if c.SignaturesFile != "" {
+		if c.SignatureFile != "" || c.PayloadFile != "" || c.CertFile != "" {
+			return nil, errors.New("-signatures can't be used with -signature, -payload or -cert")
+		}
+		var r io.Reader = os.Stdin
+		if c.SignaturesFile != "-" {
+			f, err := os.Open(filepath.Clean(c.SignaturesFile))
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			r = f
+		}
+		return readDownloadedSignatures(r)
+	}
+	if c.SignatureFile == "" || c.PayloadFile == "" {
+		return nil, errors.New("-signature and -payload must be used together")
+	}
+	sig, err := ioutil.ReadFile(filepath.Clean(c.SignatureFile))
+	if err != nil {
+		return nil, err
+	}
+	payload, err := ioutil.ReadFile(filepath.Clean(c.PayloadFile))
+	if err != nil {
+		return nil, err
+	}
+	ds := downloadedSignature{Base64Signature: strings.TrimSpace(string(sig)), Payload: payload}
+	if c.CertFile != "" {
+		cert, err := ioutil.ReadFile(filepath.Clean(c.CertFile))
+		if err != nil {
+			return nil, err
+		}
+		ds.Cert = string(cert)
+	}
+	sp, err := ds.signedPayload()
+	if err != nil {
+		return nil, err
+	}
+	return []cosign.SignedPayload{sp}, nil
+}
+
+// readDownloadedSignatures reads signatures as "cosign download signature" prints them.
+func readDownloadedSignatures(r io.Reader) ([]cosign.SignedPayload, error) {
+	sps := []cosign.SignedPayload{}
+	dec := json.NewDecoder(r)
+	for {
+		var ds downloadedSignature
+		if err := dec.Decode(&ds); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		sp, err := ds.signedPayload()
+		if err != nil {
+			return nil, err
+		}
+		sps = append(sps, sp)
+	}
+	if len(sps) == 0 {
+		return nil, errors.New("no signatures found")
+	}
+	return sps, nil
+}
+
+// signedPayload parses the certificates of ds. The first certificate of Cert is the signing one,
+// any others are added to its chain.
+func (ds *downloadedSignature) signedPayload() (cosign.SignedPayload, error) {
+	sp := cosign.SignedPayload{
+		Base64Signature: ds.Base64Signature,
+		Payload:         ds.Payload,
+		KeyID:           ds.KeyID,
+		Algorithm:       ds.Algorithm,
+		SCT:             ds.SCT,
+	}
+	if ds.HardwareAttestation != "" {
+		certs, err := cosign.LoadCerts(ds.HardwareAttestation)
+		if err != nil {
+			return sp, err
+		}
+		sp.HardwareAttestation = certs
+	}
+	if ds.Cert == "" {
+		return sp, nil
+	}
+	certs, err := cosign.LoadCerts(ds.Cert + ds.Chain)
+	if err != nil {
+		return sp, err
+	}
+	if len(certs) == 0 {
+		return sp, errors.New("no certificates found")
+	}
+	sp.Cert, sp.Chain = certs[0], certs[1:]
+	return sp, nil
+}
+
+// verifyDetached verifies the signatures from files against the image at ref, which must be a digest reference.
+func (c *VerifyCommand) verifyDetached(ctx context.Context, ref name.Reference, co cosign.CheckOpts) ([]cosign.VerifiedSignature, error) {
+	dr, ok := ref.(name.Digest)
+	if !ok {
+		return nil, errors.Errorf("%s is not a digest reference", ref)
+	}
+	sps, err := c.detachedSignatures()
+	if err != nil {
+		return nil, errors.Wrap(err, "loading signatures")
+	}
+	return cosign.VerifyDetached(ctx, dr, sps, co)
+}
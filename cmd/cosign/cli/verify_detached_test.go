@@ -0,0 +1,131 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/zalando/go-keyring"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+func TestVerifyDetached(t *testing.T) {
+	keyring.MockInit()
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/detached:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digestRef := ref.Context().Digest(digest.String()).String()
+
+	td, err := ioutil.TempDir("", "cosign-verify-detached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	pass := func(bool) ([]byte, error) { return []byte("hunter2"), nil }
+	keys, err := cosign.GenerateKeyPair(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, pub := filepath.Join(td, "cosign.key"), filepath.Join(td, "cosign.pub")
+	if err := ioutil.WriteFile(priv, keys.PrivateBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(pub, keys.PublicBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := SignCmd(ctx, SignOpts{KeyRef: priv, Upload: true}, digestRef, pass); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := downloadSignatures(ctx, digestRef, RegistryOpts{}, buf); err != nil {
+		t.Fatal(err)
+	}
+	sigs := filepath.Join(td, "signatures.json")
+	if err := ioutil.WriteFile(sigs, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The registry is gone, the signatures are only in the file.
+	s.Close()
+	verify := func(path, imageRef string) error {
+		c := &VerifyCommand{Key: pub, CheckClaims: true, Annotations: &map[string]string{}, Output: "json", SignaturesFile: path}
+		return c.Exec(ctx, []string{imageRef})
+	}
+	if err := verify(sigs, digestRef); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify(sigs, ref.String()); err == nil {
+		t.Error("expected error verifying a tag offline")
+	}
+
+	otherKeys, err := cosign.GenerateKeyPair(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub := filepath.Join(td, "other.pub")
+	if err := ioutil.WriteFile(otherPub, otherKeys.PublicBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	c := &VerifyCommand{Key: otherPub, CheckClaims: true, Annotations: &map[string]string{}, Output: "json", SignaturesFile: sigs}
+	if err := c.Exec(ctx, []string{digestRef}); err == nil {
+		t.Error("expected error verifying against a key that didn't sign")
+	}
+}
+
+func TestReadDownloadedSignatures(t *testing.T) {
+	in := `{"Base64Signature":"c2ln","Payload":"e30="}
+{"Base64Signature":"c2lnMg==","Payload":"e30=","KeyID":"key"}
+`
+	sps, err := readDownloadedSignatures(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sps) != 2 || sps[0].Base64Signature != "c2ln" || string(sps[0].Payload) != "{}" || sps[1].KeyID != "key" {
+		t.Errorf("readDownloadedSignatures() = %+v", sps)
+	}
+	if _, err := readDownloadedSignatures(strings.NewReader("")); err == nil {
+		t.Error("expected error reading no signatures")
+	}
+	if _, err := readDownloadedSignatures(strings.NewReader(`{"Base64Signature":"c2ln","Cert":"not a cert"}`)); err == nil {
+		t.Error("expected error reading a signature with an invalid certificate")
+	}
+}
@@ -15,9 +15,25 @@
 package cli
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/zalando/go-keyring"
+
+	"github.com/sigstore/cosign/pkg/cosign"
 )
 
 // TestVerifyCmdLocalKeyAndKms verifies the Verify command returns an error
@@ -39,3 +55,132 @@ func TestVerifyCmdLocalKeyAndKms(t *testing.T) {
 		t.Fatal("expected KeyParseError")
 	}
 }
+
+func TestReadImageRefs(t *testing.T) {
+	td, err := ioutil.TempDir("", "cosign-verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	path := filepath.Join(td, "images.txt")
+	in := "gcr.io/a/b:latest\n\n# a comment\n  gcr.io/c/d@sha256:abc  \n"
+	if err := ioutil.WriteFile(path, []byte(in), 0600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readImageRefs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"gcr.io/a/b:latest", "gcr.io/c/d@sha256:abc"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readImageRefs() = %v, want %v", got, want)
+	}
+}
+
+func TestVerifyBatch(t *testing.T) {
+	cmd := VerifyCommand{Parallelism: 2}
+	refs := []string{"INVALID/ref:!", "also invalid", "bad::ref"}
+	var out bytes.Buffer
+	err := cmd.verifyBatch(context.Background(), refs, cosign.CheckOpts{}, &out)
+	if err == nil {
+		t.Fatal("expected error when images fail verification")
+	}
+
+	dec := json.NewDecoder(&out)
+	seen := map[string]bool{}
+	for dec.More() {
+		var res verifyResult
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Verified || res.Error == "" {
+			t.Errorf("unexpected result for %s: %+v", res.Image, res)
+		}
+		seen[res.Image] = true
+	}
+	if len(seen) != len(refs) {
+		t.Errorf("got results for %v, want one per image in %v", seen, refs)
+	}
+}
+
+func TestVerifyRepository(t *testing.T) {
+	keyring.MockInit()
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	repo := strings.TrimPrefix(s.URL, "http://") + "/audit"
+
+	for _, tag := range []string{"good", "wrong-key", "unsigned"} {
+		ref, err := name.ParseReference(repo + ":" + tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := random.Image(10, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	td, err := ioutil.TempDir("", "cosign-verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	pf := func(bool) ([]byte, error) { return []byte("hunter2"), nil }
+	writeKeys := func(n string) (string, string) {
+		keys, err := cosign.GenerateKeyPair(pf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv, pub := filepath.Join(td, n+".key"), filepath.Join(td, n+".pub")
+		if err := ioutil.WriteFile(priv, keys.PrivateBytes, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(pub, keys.PublicBytes, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return priv, pub
+	}
+	priv, pub := writeKeys("trusted")
+	otherPriv, _ := writeKeys("other")
+	if err := SignCmd(ctx, SignOpts{KeyRef: priv, Upload: true}, repo+":good", pf); err != nil {
+		t.Fatal(err)
+	}
+	if err := SignCmd(ctx, SignOpts{KeyRef: otherPriv, Upload: true}, repo+":wrong-key", pf); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := VerifyCommand{Parallelism: 2}
+	refs, err := cmd.repositoryImages(ctx, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 3 {
+		t.Fatalf("repositoryImages() = %v, want the 3 image tags", refs)
+	}
+	pubKey, err := cosign.LoadPublicKey(ctx, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := cmd.verifyBatch(ctx, refs, cosign.CheckOpts{Keys: []cosign.PublicKey{pubKey}, ClaimVerification: true}, &out); err == nil {
+		t.Error("expected error when some images aren't signed")
+	}
+
+	got := map[string]string{}
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var res verifyResult
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		got[res.Image[strings.LastIndex(res.Image, ":")+1:]] = res.Status
+	}
+	want := map[string]string{"good": statusSigned, "wrong-key": statusInvalid, "unsigned": statusUnsigned}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+const (
+	eventAdded        = "added"
+	eventRemoved      = "removed"
+	eventImageChanged = "image-changed"
+	eventError        = "error"
+)
+
+// WatchCommand polls images and reports signatures being added to or removed from them.
+type WatchCommand struct {
+	Registry RegistryOpts
+	Interval time.Duration
+	// Webhook, if set, is sent each event as a JSON POST.
+	Webhook string
+	// Input is a file listing images to watch, one per line, or "-" for stdin.
+	Input string
+
+	out io.Writer
+}
+
+// watchEvent is printed as a JSON line, and posted to the webhook, for every change seen.
+type watchEvent struct {
+	Time      time.Time `json:"time"`
+	Event     string    `json:"event"`
+	Image     string    `json:"image"`
+	Digest    string    `json:"digest,omitempty"`
+	Signature string    `json:"signature,omitempty"`
+	Payload   string    `json:"payload,omitempty"`
+	Cert      string    `json:"cert,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// watchState is what was seen on an image in the last poll.
+type watchState struct {
+	digest     string
+	signatures map[string]cosign.SignedPayload
+}
+
+// Watch builds and returns an ffcli command
+func Watch() *ffcli.Command {
+	cmd := WatchCommand{out: os.Stdout}
+	flagset := flag.NewFlagSet("cosign watch", flag.ExitOnError)
+	flagset.DurationVar(&cmd.Interval, "interval", time.Minute, "how often to check the images for signature changes")
+	flagset.StringVar(&cmd.Webhook, "webhook", "", "URL to POST each event to as JSON, in addition to printing it")
+	flagset.StringVar(&cmd.Input, "input", "", "path to a file of image references to watch, one per line, or - for stdin")
+	cmd.Registry.addFlags(flagset)
+
+	return &ffcli.Command{
+		Name:       "watch",
+		ShortUsage: "cosign watch [-interval <duration>] [-webhook <url>] <image uri> [<image uri> ...]",
+		ShortHelp:  "Watch images for signatures being added or removed",
+		LongHelp: `Watch images for signatures being added or removed.
+
+The images are checked every -interval, until interrupted. Each change is printed as a JSON line:
+"added" and "removed" for signatures, "image-changed" when a tag points at a new digest, and "error" when
+an image couldn't be checked. The signatures present when watching starts are not reported.
+
+EXAMPLES
+  # watch an image, alerting a webhook on changes
+  cosign watch -webhook https://hooks.example.com/cosign <IMAGE>
+
+  # check every image in images.txt every 5 minutes
+  cosign watch -interval 5m -input images.txt`,
+		FlagSet: flagset,
+		Exec:    cmd.Exec,
+	}
+}
+
+// Exec runs the watch command
+func (c *WatchCommand) Exec(ctx context.Context, args []string) error {
+	if c.Input != "" {
+		refs, err := readImageRefs(c.Input)
+		if err != nil {
+			return err
+		}
+		args = append(args, refs...)
+	}
+	if len(args) == 0 {
+		return flag.ErrHelp
+	}
+	if c.Interval <= 0 {
+		return errors.New("-interval must be positive")
+	}
+	if c.out == nil {
+		c.out = os.Stdout
+	}
+
+	state := map[string]*watchState{}
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		for _, ev := range c.poll(ctx, args, state) {
+			if err := emitEvent(ctx, c.out, c.Webhook, ev); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll checks every image once, returning the changes since the previous poll.
+// Images seen for the first time only record their state.
+func (c *WatchCommand) poll(ctx context.Context, images []string, state map[string]*watchState) []watchEvent {
+	events := []watchEvent{}
+	now := time.Now().UTC()
+	for _, image := range images {
+		cur, err := c.check(ctx, image)
+		if err != nil {
+			if ctx.Err() != nil {
+				return events
+			}
+			events = append(events, watchEvent{Time: now, Event: eventError, Image: image, Error: err.Error()})
+			continue
+		}
+		prev, seen := state[image]
+		state[image] = cur
+		if !seen {
+			continue
+		}
+		if cur.digest != prev.digest {
+			events = append(events, watchEvent{Time: now, Event: eventImageChanged, Image: image, Digest: cur.digest})
+		}
+		for id, sp := range cur.signatures {
+			if _, ok := prev.signatures[id]; !ok {
+				events = append(events, signatureEvent(now, eventAdded, image, cur.digest, sp))
+			}
+		}
+		for id, sp := range prev.signatures {
+			if _, ok := cur.signatures[id]; !ok {
+				events = append(events, signatureEvent(now, eventRemoved, image, prev.digest, sp))
+			}
+		}
+	}
+	return events
+}
+
+func (c *WatchCommand) check(ctx context.Context, image string) (*watchState, error) {
+	ref, err := c.Registry.ParseReference(image)
+	if err != nil {
+		return nil, err
+	}
+	sps, desc, err := cosign.FetchSignatures(ctx, ref, c.Registry.ClientOpts(ctx)...)
+	if err != nil && !errors.Is(err, cosign.ErrNoSignatures) {
+		return nil, err
+	}
+	st := &watchState{signatures: map[string]cosign.SignedPayload{}}
+	if desc != nil {
+		st.digest = desc.Digest.String()
+	}
+	for _, sp := range sps {
+		st.signatures[sp.Base64Signature] = sp
+	}
+	return st, nil
+}
+
+func signatureEvent(now time.Time, event, image, digest string, sp cosign.SignedPayload) watchEvent {
+	ev := watchEvent{
+		Time:      now,
+		Event:     event,
+		Image:     image,
+		Digest:    digest,
+		Signature: sp.Base64Signature,
+		Payload:   string(sp.Payload),
+	}
+	if sp.Cert != nil {
+		ev.Cert = string(cosign.CertToPem(sp.Cert))
+	}
+	return ev
+}
+
+// emitEvent prints ev as a JSON line to out and, if webhook is set, posts it there. A webhook
+// that can't be reached is reported but doesn't stop monitoring.
+func emitEvent(ctx context.Context, out io.Writer, webhook string, ev interface{}) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(out, string(b)); err != nil {
+		return err
+	}
+	if webhook == "" {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "creating webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Warnf("error posting event to webhook: %v", err)
+		return nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		log.Warnf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+func TestWatchPoll(t *testing.T) {
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	ref, err := name.ParseReference(host + "/watched:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	push := func() {
+		img, err := random.Image(10, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sign := func(sig string) {
+		desc, err := remote.Get(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst, err := cosign.DestinationRef(ref, desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cosign.Upload(ctx, []byte(sig), []byte(`{"sig":"`+sig+`"}`), dst, cosign.SignatureMetadata{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events := func(evs []watchEvent) []string {
+		got := []string{}
+		for _, ev := range evs {
+			got = append(got, ev.Event)
+		}
+		return got
+	}
+
+	c := &WatchCommand{}
+	images := []string{ref.String(), host + "/missing:latest"}
+	state := map[string]*watchState{}
+
+	push()
+	// The first poll only records what is there, but the missing image is an error every time.
+	if got := events(c.poll(ctx, images, state)); strings.Join(got, ",") != eventError {
+		t.Fatalf("first poll events = %v, want [error]", got)
+	}
+	images = images[:1]
+
+	sign("one")
+	evs := c.poll(ctx, images, state)
+	if got := events(evs); strings.Join(got, ",") != eventAdded {
+		t.Fatalf("events after signing = %v, want [added]", got)
+	}
+	if evs[0].Payload != `{"sig":"one"}` || evs[0].Digest == "" {
+		t.Errorf("added event = %+v", evs[0])
+	}
+	if got := events(c.poll(ctx, images, state)); len(got) != 0 {
+		t.Errorf("events without changes = %v, want none", got)
+	}
+
+	// A new, unsigned image is pushed over the tag.
+	push()
+	got := events(c.poll(ctx, images, state))
+	if strings.Join(got, ",") != eventImageChanged+","+eventRemoved {
+		t.Errorf("events after pushing = %v, want [image-changed removed]", got)
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build boringcrypto
+
+package main
+
+import (
+	// Restricts TLS to FIPS approved versions, cipher suites and curves.
+	_ "crypto/tls/fipsonly"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+// Builds with the BoringCrypto toolchain are meant for FIPS environments, so FIPS mode is always on.
+func init() {
+	cosign.SetFIPSMode(true)
+}
@@ -23,41 +23,97 @@ import (
 	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/peterbourgon/ff/v3/ffcli"
 	"github.com/sigstore/cosign/cmd/cosign/cli"
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/log"
 )
 
 var (
 	rootFlagSet = flag.NewFlagSet("cosign", flag.ExitOnError)
-	debug       = rootFlagSet.Bool("d", false, "log debug output to stderr")
-	verbose     = rootFlagSet.Bool("v", false, "increase log verbosity")
+	debug       = rootFlagSet.Bool("d", false, "log debug output to stderr, including the HTTP requests and responses of registry operations")
+	verbose     = rootFlagSet.Bool("v", false, "log debug output from cosign, like network round trips and payload digests")
+	quiet       = rootFlagSet.Bool("q", false, "only log warnings and errors")
+	logFormat   = rootFlagSet.String("log-format", "text", "format of log messages, text or json")
+	caBundle    = rootFlagSet.String("ca-bundle", os.Getenv("COSIGN_CA_BUNDLE"), "path to PEM encoded CA certificates to trust for all connections, in addition to the system roots")
+	clientCert  = rootFlagSet.String("client-cert", os.Getenv("COSIGN_CLIENT_CERT"), "path to a PEM encoded client certificate for mutual TLS")
+	clientKey   = rootFlagSet.String("client-key", os.Getenv("COSIGN_CLIENT_KEY"), "path to the PEM encoded private key for -client-cert")
+	fips        = rootFlagSet.Bool("fips", false, "only sign and verify with keys of FIPS 186-4 approved algorithms, always on in boringcrypto builds")
+	retries     = rootFlagSet.Int("retries", 3, "number of times to retry network requests that fail with connection errors or 429/5xx responses")
+	helpJSON    = rootFlagSet.Bool("help-json", false, "print the commands and flags as JSON, for generating UIs against the CLI")
+	timeout     = rootFlagSet.Duration("timeout", 0, "give up on network operations after this long, e.g. 5m (default no timeout)")
 )
 
 func main() {
+	// The config file only fills in environment variables that aren't set, so it has to be
+	// applied before the subcommands read them for their flag defaults.
+	cfg, err := cosign.LoadConfig()
+	if err == nil {
+		err = cfg.Apply()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: loading config: %v\n", err)
+		os.Exit(1)
+	}
+
 	root := &ffcli.Command{
 		ShortUsage: "cosign [flags] <subcommand>",
 		FlagSet:    rootFlagSet,
 		Subcommands: []*ffcli.Command{
-			cli.Verify(), cli.Sign(), cli.Upload(), cli.Generate(), cli.Download(), cli.GenerateKeyPair(), cli.SignBlob(), cli.VerifyBlob(), cli.Triangulate(), cli.Version(), cli.PublicKey()},
+			cli.Verify(), cli.Sign(), cli.Build(), cli.Upload(), cli.Generate(), cli.Download(), cli.GenerateKeyPair(), cli.RotateKey(), cli.SignBlob(), cli.VerifyBlob(), cli.Triangulate(), cli.Version(), cli.PublicKey(), cli.Keychain(), cli.Login(), cli.Watch(), cli.Monitor(), cli.Attest(), cli.VerifyAttestation(), cli.AttestBlob(), cli.VerifyBlobAttestation(), cli.Prune(), cli.SignGit(), cli.VerifyGit(), cli.Resign(), cli.Countersign(), cli.Approve(), cli.Atomic(), cli.Notation(), cli.MigrateDCT(), cli.Trust(), cli.Policy(), cli.Env()},
 		Exec: func(context.Context, []string) error {
 			return flag.ErrHelp
 		},
 	}
 
+	root.Subcommands = append(root.Subcommands, cli.Completion(root))
+
 	if err := root.Parse(os.Args[1:]); err != nil {
-		if *verbose {
-			fmt.Print("verbose!")
-		}
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
 
-	if *debug {
+	if *helpJSON {
+		if err := cli.HelpJSONCmd(os.Stdout, root); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	switch {
+	case *debug:
 		logs.Debug.SetOutput(os.Stderr)
+		log.SetLevel(log.LevelDebug)
+	case *verbose:
+		log.SetLevel(log.LevelDebug)
+	case *quiet:
+		log.SetLevel(log.LevelWarn)
+	}
+	switch *logFormat {
+	case "text":
+	case "json":
+		log.SetJSON(true)
+	default:
+		fmt.Fprintf(os.Stderr, "error: invalid -log-format %q, must be text or json\n", *logFormat)
+		os.Exit(1)
 	}
 
-	if err := root.Run(context.Background()); err != nil {
-		if *verbose {
-			fmt.Print("verbose!")
-		}
+	if *fips {
+		cosign.SetFIPSMode(true)
+	}
+
+	if err := cli.ConfigureTLS(*caBundle, *clientCert, *clientKey); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	cli.ConfigureNetwork(*retries, *timeout)
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	if err := root.Run(ctx); err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
@@ -175,9 +175,9 @@ func main() {
 				return nil, err
 			}
 			co := cosign.CheckOpts{
-				PubKey: pubKey,
-				Claims: true,
-				Roots:  fulcio.Roots,
+				Keys:              []cosign.PublicKey{pubKey},
+				ClaimVerification: true,
+				Roots:             fulcio.Roots,
 			}
 			sps, err := cosign.Verify(context.Background(), ref, co)
 			if err != nil {
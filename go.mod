@@ -4,12 +4,17 @@ go 1.16
 
 require (
 	cloud.google.com/go v0.81.0
+	filippo.io/age v1.0.0
+	github.com/docker/cli v0.0.0-20191017083524-a8ff7f821017
 	github.com/go-openapi/runtime v0.19.27
 	github.com/go-openapi/strfmt v0.20.1
 	github.com/go-openapi/swag v0.19.15
+	github.com/ghodss/yaml v1.0.0
 	github.com/google/go-cmp v0.5.5
 	github.com/google/go-containerregistry v0.4.1-0.20210206001656-4d068fbcb51f
+	github.com/google/go-tpm v0.3.3
 	github.com/google/trillian v1.3.13
+	github.com/klauspost/compress v1.13.0
 	github.com/open-policy-agent/opa v0.27.1
 	github.com/peterbourgon/ff/v3 v3.0.0
 	github.com/pkg/errors v0.9.1
@@ -18,8 +23,15 @@ require (
 	github.com/sigstore/sigstore v0.0.0-20210329185113-57367f943f99
 	github.com/stretchr/testify v1.7.0
 	github.com/theupdateframework/go-tuf v0.0.0-20201230183259-aee6270feb55
+	github.com/zalando/go-keyring v0.2.1
+	go.opentelemetry.io/otel v0.20.0
+	go.opentelemetry.io/otel/metric v0.20.0
+	go.opentelemetry.io/otel/trace v0.20.0
+	golang.org/x/crypto v0.0.0-20201221181555-eec23a3978ad
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
 	golang.org/x/term v0.0.0-20201210144234-2321bbc49cbf
+	golang.org/x/time v0.0.0-20210220033141-f8bda1e9f3ba
 	google.golang.org/genproto v0.0.0-20210402141018-6c239bbf2bb1
+	google.golang.org/grpc v1.36.1
 	google.golang.org/protobuf v1.26.0
 )
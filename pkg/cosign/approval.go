@@ -0,0 +1,81 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pkg/errors"
+)
+
+// ApprovalPredicate is the predicate of approval attestations, made by "cosign approve".
+// Who approved is whoever signed the attestation.
+type ApprovalPredicate struct {
+	Comment string `json:"comment,omitempty"`
+}
+
+// ApproverIdentity returns who made a verified approval: the email of its certificate, or the
+// fingerprint of the key that verified it.
+func ApproverIdentity(ctx context.Context, va VerifiedAttestation) (string, error) {
+	if va.Key != nil {
+		pub, err := va.Key.PublicKey(ctx)
+		if err != nil {
+			return "", err
+		}
+		fp, err := KeyFingerprint(pub)
+		if err != nil {
+			return "", err
+		}
+		return "key:" + fp, nil
+	}
+	if va.Cert == nil || len(va.Cert.EmailAddresses) == 0 {
+		return "", errors.New("approval certificate has no identity")
+	}
+	return va.Cert.EmailAddresses[0], nil
+}
+
+// VerifyApprovals checks that the image at ref, with desc, has approvals from at least
+// co.RequiredApprovals distinct identities passing the checks in co.Approvers. It returns one
+// approval for each identity.
+func VerifyApprovals(ctx context.Context, ref name.Reference, desc *v1.Descriptor, co CheckOpts) ([]VerifiedAttestation, error) {
+	if co.Approvers == nil {
+		return nil, errors.New("approvers are required to verify approvals")
+	}
+	atts, _, err := FetchAttestations(ctx, ref, co.RegistryClientOpts...)
+	if err != nil && !errors.Is(err, ErrNoAttestations) {
+		return nil, errors.Wrap(err, "fetching approvals")
+	}
+	approvals := []VerifiedAttestation{}
+	seen := map[string]bool{}
+	if atts = FilterAttestations(atts, "approval"); len(atts) > 0 {
+		// An error only means none of them verified.
+		verified, _ := VerifyAttestations(ctx, desc, atts, *co.Approvers)
+		for _, va := range verified {
+			id, err := ApproverIdentity(ctx, va)
+			if err != nil || seen[id] {
+				continue
+			}
+			seen[id] = true
+			approvals = append(approvals, va)
+		}
+	}
+	if len(approvals) < co.RequiredApprovals {
+		return nil, fmt.Errorf("%d of %d required approvals from distinct trusted approvers", len(approvals), co.RequiredApprovals)
+	}
+	return approvals, nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"encoding/pem"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	SignaturePemType = "COSIGN SIGNATURE"
+
+	// Headers of armored signatures. These are informational only and aren't covered by the signature.
+	FingerprintHeader = "Key-Fingerprint"
+	CreatedHeader     = "Created"
+)
+
+// SignatureToPem armors a raw signature in a PEM block, with headers describing how it was made.
+// The Created header is left out if created is the zero time.
+func SignatureToPem(signature []byte, fingerprint string, created time.Time) []byte {
+	headers := map[string]string{}
+	if !created.IsZero() {
+		headers[CreatedHeader] = created.UTC().Format(time.RFC3339)
+	}
+	if fingerprint != "" {
+		headers[FingerprintHeader] = fingerprint
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:    SignaturePemType,
+		Headers: headers,
+		Bytes:   signature,
+	})
+}
+
+// SignatureFromPem returns the raw signature and headers of an armored signature.
+func SignatureFromPem(b []byte) ([]byte, map[string]string, error) {
+	p, _ := pem.Decode(b)
+	if p == nil {
+		return nil, nil, errors.New("invalid pem block")
+	}
+	if p.Type != SignaturePemType {
+		return nil, nil, errors.Errorf("unsupported pem type: %s", p.Type)
+	}
+	return p.Bytes, p.Headers, nil
+}
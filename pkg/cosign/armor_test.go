@@ -0,0 +1,59 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSignaturePem(t *testing.T) {
+	sig := []byte("not really a signature")
+	created := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+	armored := SignatureToPem(sig, "abcd", created)
+
+	got, headers, err := SignatureFromPem(armored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, sig) {
+		t.Errorf("SignatureFromPem() = %q, want %q", got, sig)
+	}
+	if headers[FingerprintHeader] != "abcd" {
+		t.Errorf("fingerprint header = %q, want %q", headers[FingerprintHeader], "abcd")
+	}
+	if headers[CreatedHeader] != "2021-04-01T12:00:00Z" {
+		t.Errorf("created header = %q", headers[CreatedHeader])
+	}
+
+	if _, headers, err := SignatureFromPem(SignatureToPem(sig, "abcd", time.Time{})); err != nil {
+		t.Fatal(err)
+	} else if _, ok := headers[CreatedHeader]; ok {
+		t.Error("expected no created header for the zero time")
+	}
+
+	priv, err := GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := KeyToPem(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := SignatureFromPem(pub); err == nil {
+		t.Error("expected error parsing a public key as a signature")
+	}
+}
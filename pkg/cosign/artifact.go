@@ -0,0 +1,132 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// BlobMediaType is the default media type of the files pushed by UploadFiles.
+	BlobMediaType types.MediaType = "application/octet-stream"
+	// BlobConfigMediaType is the config media type of artifacts holding arbitrary files.
+	BlobConfigMediaType types.MediaType = "application/vnd.cosign.artifact.config.v1+json"
+	// WasmLayerMediaType and WasmConfigMediaType are the media types of WASM modules stored as OCI artifacts.
+	WasmLayerMediaType  types.MediaType = "application/vnd.wasm.content.layer.v1+wasm"
+	WasmConfigMediaType types.MediaType = "application/vnd.wasm.config.v1+json"
+
+	// titleAnnotation records the file name of each layer, as in the OCI image spec.
+	titleAnnotation = "org.opencontainers.image.title"
+)
+
+// UploadFiles pushes the files to ref as the layers of an OCI artifact, so they can be
+// signed and verified like an image. It returns the digest of the artifact.
+func UploadFiles(ctx context.Context, ref name.Reference, paths []string, layerMediaType, configMediaType types.MediaType, opts ...remote.Option) (v1.Hash, error) {
+	return UploadFilesWithOpts(ctx, ref, paths, layerMediaType, configMediaType, PushOpts{}, opts...)
+}
+
+// UploadFilesWithOpts is UploadFiles, pushing the files as po says: several at once, and in chunks
+// so an interrupted upload can be resumed if po.Chunked is set.
+func UploadFilesWithOpts(ctx context.Context, ref name.Reference, paths []string, layerMediaType, configMediaType types.MediaType, po PushOpts, opts ...remote.Option) (v1.Hash, error) {
+	if len(paths) == 0 {
+		return v1.Hash{}, errors.New("no files to upload")
+	}
+	img := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+	layers := make([]v1.Layer, 0, len(paths))
+	for _, path := range paths {
+		b, err := ioutil.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return v1.Hash{}, err
+		}
+		l := withProgress(ctx, &staticLayer{b: b, mt: layerMediaType}, filepath.Base(path))
+		layers = append(layers, l)
+		img, err = mutate.Append(img, mutate.Addendum{
+			Layer:       l,
+			Annotations: map[string]string{titleAnnotation: filepath.Base(path)},
+		})
+		if err != nil {
+			return v1.Hash{}, err
+		}
+	}
+	opts = registryOpts(ctx, opts)
+	if err := pushLayers(ctx, ref.Context(), layers, po, opts); err != nil {
+		return v1.Hash{}, errors.Wrapf(err, "pushing %s", ref)
+	}
+	// The files are in the registry now, only the config and manifest are left.
+	art := &artifact{Image: img, configMediaType: configMediaType}
+	if err := remote.Write(ref, art, opts...); err != nil {
+		return v1.Hash{}, errors.Wrapf(err, "pushing %s", ref)
+	}
+	return art.Digest()
+}
+
+// artifact overrides the config media type of an image, which mutate can't set yet,
+// and optionally its config.
+type artifact struct {
+	v1.Image
+	configMediaType types.MediaType
+	config          []byte
+}
+
+func (a *artifact) Manifest() (*v1.Manifest, error) {
+	m, err := a.Image.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	m = m.DeepCopy()
+	m.Config.MediaType = a.configMediaType
+	if a.config != nil {
+		if m.Config.Digest, err = a.ConfigName(); err != nil {
+			return nil, err
+		}
+		m.Config.Size = int64(len(a.config))
+	}
+	return m, nil
+}
+
+func (a *artifact) RawConfigFile() ([]byte, error) {
+	if a.config == nil {
+		return a.Image.RawConfigFile()
+	}
+	return a.config, nil
+}
+
+func (a *artifact) ConfigName() (v1.Hash, error) {
+	if a.config == nil {
+		return a.Image.ConfigName()
+	}
+	h, _, err := v1.SHA256(bytes.NewReader(a.config))
+	return h, err
+}
+
+func (a *artifact) RawManifest() ([]byte, error) {
+	return partial.RawManifest(a)
+}
+
+func (a *artifact) Digest() (v1.Hash, error) {
+	return partial.Digest(a)
+}
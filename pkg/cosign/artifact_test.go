@@ -0,0 +1,75 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestUploadFiles(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/module:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	td, err := ioutil.TempDir("", "cosign-artifact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	path := filepath.Join(td, "module.wasm")
+	if err := ioutil.WriteFile(path, []byte("\x00asm\x01\x00\x00\x00"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := UploadFiles(context.Background(), ref, []string{path}, WasmLayerMediaType, WasmConfigMediaType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := remote.Image(ref.Context().Digest(digest.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MediaType != types.OCIManifestSchema1 {
+		t.Errorf("manifest media type = %s, want %s", m.MediaType, types.OCIManifestSchema1)
+	}
+	if m.Config.MediaType != WasmConfigMediaType {
+		t.Errorf("config media type = %s, want %s", m.Config.MediaType, WasmConfigMediaType)
+	}
+	if len(m.Layers) != 1 || m.Layers[0].MediaType != WasmLayerMediaType || m.Layers[0].Annotations[titleAnnotation] != "module.wasm" {
+		t.Errorf("layers = %+v, want the module", m.Layers)
+	}
+
+	if _, err := UploadFiles(context.Background(), ref, nil, BlobMediaType, BlobConfigMediaType); err == nil {
+		t.Error("expected error uploading no files")
+	}
+}
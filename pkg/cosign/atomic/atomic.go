@@ -0,0 +1,203 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package atomic reads and writes "atomic container signature"s, the OpenPGP signed simple signing
+// payloads that podman, CRI-O and skopeo check against the signedBy requirements of policy.json,
+// in the lookaside directory layout containers/image reads them from.
+package atomic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/openpgp"
+)
+
+// Type is the critical type of the payloads containers/image accepts.
+const Type = "atomic container signature"
+
+// Payload is the simple signing payload of an atomic signature. Unlike cosign's payloads, its field
+// names are all lower case, and containers/image rejects any it doesn't know.
+type Payload struct {
+	Critical Critical `json:"critical"`
+	Optional Optional `json:"optional"`
+}
+
+type Critical struct {
+	Identity struct {
+		DockerReference string `json:"docker-reference"`
+	} `json:"identity"`
+	Image struct {
+		DockerManifestDigest string `json:"docker-manifest-digest"`
+	} `json:"image"`
+	Type string `json:"type"`
+}
+
+type Optional struct {
+	Creator   string `json:"creator,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+}
+
+// NewPayload returns the payload claiming that the image with the digest is ref.
+func NewPayload(ref name.Reference, digest v1.Hash, creator string, created time.Time) *Payload {
+	p := &Payload{}
+	p.Critical.Identity.DockerReference = DockerReference(ref)
+	p.Critical.Image.DockerManifestDigest = digest.String()
+	p.Critical.Type = Type
+	p.Optional.Creator = creator
+	if !created.IsZero() {
+		p.Optional.Timestamp = created.Unix()
+	}
+	return p
+}
+
+// DockerReference returns ref as containers/image writes it, with Docker Hub images under docker.io
+// rather than index.docker.io.
+func DockerReference(ref name.Reference) string {
+	s := dockerRepository(ref.Context())
+	switch r := ref.(type) {
+	case name.Tag:
+		s += ":" + r.TagStr()
+	case name.Digest:
+		s += "@" + r.DigestStr()
+	}
+	return s
+}
+
+func dockerRepository(repo name.Repository) string {
+	registry := repo.RegistryStr()
+	if registry == name.DefaultRegistry {
+		registry = "docker.io"
+	}
+	return registry + "/" + repo.RepositoryStr()
+}
+
+// Sign returns the payload signed by the entity, as the OpenPGP message containers/image reads.
+// The entity's private key must already be decrypted.
+func Sign(p *Payload, signer *openpgp.Entity) ([]byte, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	w, err := openpgp.Sign(buf, signer, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Verify checks that sig is signed by a key in the keyring, and returns its payload and signer.
+func Verify(sig []byte, keyring openpgp.KeyRing) (*Payload, *openpgp.Entity, error) {
+	md, err := openpgp.ReadMessage(bytes.NewReader(sig), keyring, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !md.IsSigned {
+		return nil, nil, errors.New("not a signed message")
+	}
+	// The signature is only checked once the whole body has been read.
+	b, err := ioutil.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return nil, nil, err
+	}
+	if md.SignatureError != nil {
+		return nil, nil, md.SignatureError
+	}
+	if md.SignedBy == nil {
+		return nil, nil, fmt.Errorf("signed by unknown key %X", md.SignedByKeyId)
+	}
+	p := &Payload{}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, nil, errors.Wrap(err, "parsing payload")
+	}
+	if p.Critical.Type != Type {
+		return nil, nil, fmt.Errorf("payload type is %q, not %q", p.Critical.Type, Type)
+	}
+	return p, md.SignedBy.Entity, nil
+}
+
+// Dir returns the lookaside directory the signatures of the image with the digest are in, below
+// base. It is named after the repository without its registry, so base is usually per registry.
+func Dir(base string, ref name.Reference, digest v1.Hash) string {
+	return filepath.Join(base, filepath.FromSlash(ref.Context().RepositoryStr())+"@"+digest.Algorithm+"="+digest.Hex)
+}
+
+// Read returns the signatures of the image in the lookaside directory base, in order.
+func Read(base string, ref name.Reference, digest v1.Hash) ([][]byte, error) {
+	dir := Dir(base, ref, digest)
+	sigs := [][]byte{}
+	for i := 1; ; i++ {
+		b, err := ioutil.ReadFile(filepath.Join(dir, fmt.Sprintf("signature-%d", i)))
+		if os.IsNotExist(err) {
+			return sigs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, b)
+	}
+}
+
+// Write adds sig to the signatures of the image in the lookaside directory base, unless the same
+// signature is already there, and returns the path of its file.
+func Write(base string, ref name.Reference, digest v1.Hash, sig []byte) (string, error) {
+	dir := Dir(base, ref, digest)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	for i := 1; ; i++ {
+		p := filepath.Join(dir, fmt.Sprintf("signature-%d", i))
+		b, err := ioutil.ReadFile(p)
+		switch {
+		case os.IsNotExist(err):
+			return p, ioutil.WriteFile(p, sig, 0644)
+		case err != nil:
+			return "", err
+		case bytes.Equal(b, sig):
+			return p, nil
+		}
+	}
+}
+
+// PolicyTransports returns the transports section of a policy.json that requires images in the
+// repository of ref to be signed by the OpenPGP keys in the keyring at keyPath.
+func PolicyTransports(ref name.Reference, keyPath string) map[string]interface{} {
+	return map[string]interface{}{
+		"docker": map[string]interface{}{
+			dockerRepository(ref.Context()): []map[string]interface{}{{
+				"type":    "signedBy",
+				"keyType": "GPGKeys",
+				"keyPath": keyPath,
+				"signedIdentity": map[string]string{
+					"type": "matchRepository",
+				},
+			}},
+		},
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package atomic
+
+import (
+	"crypto"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+func TestDockerReference(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"busybox", "docker.io/library/busybox:latest"},
+		{"index.docker.io/dlorenc/demo:v1", "docker.io/dlorenc/demo:v1"},
+		{"gcr.io/project/app@sha256:4bb2c5d1e2dd7ee0b84ff1c4ea2d5b2fd69cb8e4d9ba2f12e9d2d3e5ba1f0a60", "gcr.io/project/app@sha256:4bb2c5d1e2dd7ee0b84ff1c4ea2d5b2fd69cb8e4d9ba2f12e9d2d3e5ba1f0a60"},
+	}
+	for _, tt := range tests {
+		ref, err := name.ParseReference(tt.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := DockerReference(ref); got != tt.want {
+			t.Errorf("DockerReference(%s) = %s, want %s", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	// Like gpg, prefer SHA-256; without a preference openpgp signs with RIPEMD-160.
+	config := &packet.Config{DefaultHash: crypto.SHA256}
+	signer, err := openpgp.NewEntity("release", "", "release@example.com", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := openpgp.NewEntity("other", "", "other@example.com", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference("gcr.io/project/app:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := v1.NewHash("sha256:4bb2c5d1e2dd7ee0b84ff1c4ea2d5b2fd69cb8e4d9ba2f12e9d2d3e5ba1f0a60")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := Sign(NewPayload(ref, digest, "cosign", time.Unix(1600000000, 0)), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, e, err := Verify(sig, openpgp.EntityList{other, signer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e != signer {
+		t.Errorf("signer = %v, want the release key", e.Identities)
+	}
+	if p.Critical.Image.DockerManifestDigest != digest.String() || p.Critical.Identity.DockerReference != "gcr.io/project/app:v1" || p.Optional.Timestamp != 1600000000 {
+		t.Errorf("payload = %+v", p)
+	}
+	if _, _, err := Verify(sig, openpgp.EntityList{other}); err == nil {
+		t.Error("expected error verifying against a keyring without the signer")
+	}
+
+	// The payload uses the field names containers/image requires.
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"critical":{"identity":{"docker-reference":"gcr.io/project/app:v1"},"image":{"docker-manifest-digest":"sha256:4bb2c5d1e2dd7ee0b84ff1c4ea2d5b2fd69cb8e4d9ba2f12e9d2d3e5ba1f0a60"},"type":"atomic container signature"},"optional":{"creator":"cosign","timestamp":1600000000}}`
+	if string(b) != want {
+		t.Errorf("payload = %s, want %s", b, want)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	td, err := ioutil.TempDir("", "cosign-atomic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	ref, err := name.ParseReference("gcr.io/project/app:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := v1.NewHash("sha256:4bb2c5d1e2dd7ee0b84ff1c4ea2d5b2fd69cb8e4d9ba2f12e9d2d3e5ba1f0a60")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sig := range []string{"one", "two", "one"} {
+		if _, err := Write(td, ref, digest, []byte(sig)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sigs, err := Read(td, ref, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sigs) != 2 || string(sigs[0]) != "one" || string(sigs[1]) != "two" {
+		t.Errorf("Read() = %q, want one and two once each", sigs)
+	}
+	if _, err := os.Stat(td + "/project/app@sha256=4bb2c5d1e2dd7ee0b84ff1c4ea2d5b2fd69cb8e4d9ba2f12e9d2d3e5ba1f0a60/signature-2"); err != nil {
+		t.Errorf("signature not in the lookaside layout: %v", err)
+	}
+}
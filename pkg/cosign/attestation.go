@@ -0,0 +1,484 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+	"github.com/sigstore/rekor/pkg/generated/client"
+
+	"github.com/sigstore/cosign/pkg/cosign/telemetry"
+)
+
+const (
+	// AttestationMediaType is the media type of the DSSE envelope layers attestations are stored in.
+	AttestationMediaType = "application/vnd.dsse.envelope.v1+json"
+	// InTotoPayloadType is the payloadType of envelopes carrying an in-toto statement.
+	InTotoPayloadType = "application/vnd.in-toto+json"
+)
+
+// PredicateTypes are short names for commonly used predicate types.
+var PredicateTypes = map[string]string{
+	"slsaprovenance": "https://slsa.dev/provenance/v0.1",
+	"link":           "https://in-toto.io/Link/v1",
+	"spdx":           "https://spdx.dev/Document",
+	"vuln":           "https://cosign.sigstore.dev/attestation/vuln/v1",
+	"custom":         "https://cosign.sigstore.dev/attestation/v1",
+	"approval":       "https://cosign.sigstore.dev/attestation/approval/v1",
+}
+
+// PredicateTypeURI returns the predicate type s is a short name for, or s itself.
+func PredicateTypeURI(s string) string {
+	if uri, ok := PredicateTypes[s]; ok {
+		return uri
+	}
+	return s
+}
+
+// ErrNoAttestations is returned by FetchAttestations when no attestations are stored for an image.
+var ErrNoAttestations = errors.New("no attestations found")
+
+// Attestation is a DSSE envelope attached to an image, along with the certificate it was signed with, if any.
+type Attestation struct {
+	Envelope Envelope
+	Cert     *x509.Certificate
+	Chain    []*x509.Certificate
+}
+
+// AttestationTag is the tag attestations for the image with desc are stored under,
+// next to its signatures.
+func AttestationTag(desc v1.Descriptor) string {
+	return ArtifactTag(desc.Digest, AttestationKind)
+}
+
+// AttestationRef returns where attestations for ref are stored. Like signatures, they can be
+// kept in another repository by setting COSIGN_REPOSITORY.
+func AttestationRef(ref name.Reference, img *remote.Descriptor) (name.Reference, error) {
+	return attestationRef(ref, img, os.Getenv(repoEnv))
+}
+
+func attestationRef(ref name.Reference, img *remote.Descriptor, repo string) (name.Reference, error) {
+	dst, err := destinationRef(ref, img, repo)
+	if err != nil {
+		return nil, err
+	}
+	return dst.Context().Tag(AttestationTag(img.Descriptor)), nil
+}
+
+// UploadAttestation adds env to the attestations stored at dstTag.
+func UploadAttestation(ctx context.Context, env *Envelope, dstTag name.Reference, md SignatureMetadata, opts ...remote.Option) error {
+	return UploadAttestations(ctx, []*Envelope{env}, dstTag, md, PushOpts{}, opts...)
+}
+
+// ReplaceAttestation stores env at dstTag in place of any attestations with the same predicate
+// type, so attesting an image again doesn't leave the stale attestations next to the new one.
+// Attestations of other types are kept.
+func ReplaceAttestation(ctx context.Context, env *Envelope, dstTag name.Reference, md SignatureMetadata, opts ...remote.Option) error {
+	return ReplaceAttestations(ctx, []*Envelope{env}, dstTag, md, PushOpts{}, opts...)
+}
+
+// UploadAttestations adds the envelopes to the attestations stored at dstTag, pushing their blobs
+// concurrently and writing the manifest once. Envelopes already stored with the same metadata are skipped.
+func UploadAttestations(ctx context.Context, envs []*Envelope, dstTag name.Reference, md SignatureMetadata, po PushOpts, opts ...remote.Option) error {
+	return writeAttestations(ctx, envs, dstTag, md, false, po, opts)
+}
+
+// ReplaceAttestations is ReplaceAttestation for several envelopes, removing the stored attestations
+// of any of their predicate types.
+func ReplaceAttestations(ctx context.Context, envs []*Envelope, dstTag name.Reference, md SignatureMetadata, po PushOpts, opts ...remote.Option) error {
+	return writeAttestations(ctx, envs, dstTag, md, true, po, opts)
+}
+
+func writeAttestations(ctx context.Context, envs []*Envelope, dstTag name.Reference, md SignatureMetadata, replace bool, po PushOpts, opts []remote.Option) (err error) {
+	ctx, end := telemetry.Start(ctx, telemetry.OpUploadAttestation)
+	defer func() { end(err) }()
+	if len(envs) == 0 {
+		return errors.New("no attestations to upload")
+	}
+	opts = registryOpts(ctx, opts)
+	predicateTypes := map[string]bool{}
+	adds := make([]mutate.Addendum, 0, len(envs))
+	for _, env := range envs {
+		st, err := (&Attestation{Envelope: *env}).Statement()
+		if err != nil {
+			if replace {
+				return err
+			}
+		} else {
+			predicateTypes[st.PredicateType] = true
+		}
+		l, annotations, err := attestationLayer(env, md)
+		if err != nil {
+			return err
+		}
+		what := "attestation"
+		if st != nil {
+			what += " " + st.PredicateType
+		}
+		adds = append(adds, mutate.Addendum{Layer: withProgress(ctx, l, what), Annotations: annotations})
+	}
+
+	base, err := remote.Image(dstTag, opts...)
+	if err != nil {
+		te, ok := err.(*transport.Error)
+		if !ok || te.StatusCode != http.StatusNotFound {
+			return err
+		}
+		base = empty.Image
+	}
+
+	img := base
+	if replace {
+		m, err := base.Manifest()
+		if err != nil {
+			return errors.Wrap(err, "manifest")
+		}
+		img = empty.Image
+		for _, desc := range m.Layers {
+			old, err := base.LayerByDigest(desc.Digest)
+			if err != nil {
+				return err
+			}
+			if isMediaType(desc.MediaType, AttestationMediaType) {
+				b, err := readLayer(old, desc.MediaType)
+				if err != nil {
+					return err
+				}
+				att := Attestation{}
+				if json.Unmarshal(b, &att.Envelope) == nil {
+					if oldSt, err := att.Statement(); err == nil && predicateTypes[oldSt.PredicateType] {
+						continue
+					}
+				}
+			}
+			if img, err = mutate.Append(img, mutate.Addendum{Layer: old, Annotations: desc.Annotations}); err != nil {
+				return err
+			}
+		}
+	} else {
+		// Attesting again with the same envelope doesn't keep growing the image.
+		kept := adds[:0]
+		for _, add := range adds {
+			exists, err := hasLayer(base, add.Layer, add.Annotations)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				kept = append(kept, add)
+			}
+		}
+		if len(kept) == 0 {
+			return nil
+		}
+		adds = kept
+	}
+
+	layers := make([]v1.Layer, 0, len(adds))
+	for _, add := range adds {
+		layers = append(layers, add.Layer)
+	}
+	if err := pushLayers(ctx, dstTag.Context(), layers, po, opts); err != nil {
+		return err
+	}
+	if img, err = mutate.Append(img, adds...); err != nil {
+		return err
+	}
+	return remote.Write(dstTag, img, opts...)
+}
+
+func attestationLayer(env *Envelope, md SignatureMetadata) (v1.Layer, map[string]string, error) {
+	b, err := json.Marshal(env)
+	if err != nil {
+		return nil, nil, err
+	}
+	annotations := map[string]string{}
+	if md.Cert != "" {
+		annotations[certkey] = md.Cert
+		annotations[chainkey] = md.Chain
+	}
+	l, err := payloadLayer(b, AttestationMediaType, md.Compress)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, annotations, nil
+}
+
+// readLayer returns the contents of l, with media type mt, as stored in the registry.
+// Payloads stored compressed are decompressed.
+func readLayer(l v1.Layer, mt types.MediaType) ([]byte, error) {
+	r, err := l.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return decompressPayload(r, mt)
+}
+
+// FetchAttestations returns the attestations stored for ref along with its descriptor.
+// If there are none, the descriptor is returned with an error wrapping ErrNoAttestations.
+func FetchAttestations(ctx context.Context, ref name.Reference, opts ...remote.Option) (_ []Attestation, _ *v1.Descriptor, err error) {
+	ctx, end := telemetry.Start(ctx, telemetry.OpFetchAttestations)
+	defer func() { end(err) }()
+	opts = registryOpts(ctx, opts)
+	targetDesc, err := mirroredDescriptor(ctx, ref, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	dstRef, err := attestationRef(ref, targetDesc, signatureRepository(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	attDesc, err := headDescriptor(dstRef, opts)
+	if err != nil {
+		if te, ok := err.(*transport.Error); ok && te.StatusCode == http.StatusNotFound {
+			return nil, &targetDesc.Descriptor, fmt.Errorf("%s: %w", dstRef, ErrNoAttestations)
+		}
+		return nil, nil, errors.Wrap(err, "remote image")
+	}
+	if err := checkDownloadSize(ctx, dstRef.String(), attDesc.Size); err != nil {
+		return nil, nil, err
+	}
+	attImg, err := remote.Image(dstRef, opts...)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "remote image")
+	}
+	m, err := attImg.Manifest()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "manifest")
+	}
+	layers, err := manifestLayers(ctx, dstRef, attDesc.Size, m, func(desc v1.Descriptor) bool {
+		return isMediaType(desc.MediaType, AttestationMediaType)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	atts := []Attestation{}
+	for _, desc := range layers {
+		l, err := attImg.LayerByDigest(desc.Digest)
+		if err != nil {
+			return nil, nil, err
+		}
+		l = withProgress(ctx, &limitedLayer{Layer: l, size: desc.Size}, "attestation "+desc.Digest.String())
+		b, err := readLayer(l, desc.MediaType)
+		if err != nil {
+			return nil, nil, err
+		}
+		att := Attestation{}
+		if err := json.Unmarshal(b, &att.Envelope); err != nil {
+			return nil, nil, errors.Wrapf(err, "parsing attestation %s", desc.Digest)
+		}
+		if certPem := desc.Annotations[certkey]; certPem != "" {
+			certs, err := LoadCerts(certPem)
+			if err != nil {
+				return nil, nil, err
+			}
+			att.Cert = certs[0]
+		}
+		if chainPem := desc.Annotations[chainkey]; chainPem != "" {
+			if att.Chain, err = LoadCerts(chainPem); err != nil {
+				return nil, nil, err
+			}
+		}
+		atts = append(atts, att)
+	}
+	return atts, &targetDesc.Descriptor, nil
+}
+
+// Statement is an in-toto statement, which is what attestations carry.
+type Statement struct {
+	Type          string          `json:"_type"`
+	PredicateType string          `json:"predicateType"`
+	Subject       []Subject       `json:"subject"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+// Subject is an artifact an in-toto statement is about.
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Statement decodes the in-toto statement in the envelope. It is not verified.
+func (a *Attestation) Statement() (*Statement, error) {
+	if a.Envelope.PayloadType != InTotoPayloadType {
+		return nil, fmt.Errorf("unexpected payloadType %q", a.Envelope.PayloadType)
+	}
+	b, err := base64.StdEncoding.DecodeString(a.Envelope.Payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding payload")
+	}
+	st := &Statement{}
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, errors.Wrap(err, "parsing statement")
+	}
+	return st, nil
+}
+
+// FilterAttestations returns the attestations whose statement has predicateType, which may be
+// one of the short names in PredicateTypes. An empty predicateType keeps every attestation.
+func FilterAttestations(atts []Attestation, predicateType string) []Attestation {
+	if predicateType == "" {
+		return atts
+	}
+	want := PredicateTypeURI(predicateType)
+	filtered := []Attestation{}
+	for _, a := range atts {
+		if st, err := a.Statement(); err == nil && st.PredicateType == want {
+			filtered = append(filtered, a)
+		}
+	}
+	return filtered
+}
+
+// VerifiedAttestation is an attestation that passed the checks in VerifyAttestations.
+type VerifiedAttestation struct {
+	Attestation
+	Statement *Statement
+	// Key is the one from CheckOpts.Keys that verified the envelope, or nil if it was verified by its certificate.
+	Key PublicKey `json:"-"`
+	// TlogEntryUUID is the intoto entry of the envelope in the transparency log, when co.TLog is checked.
+	TlogEntryUUID string `json:"-"`
+}
+
+// VerifyAttestations returns the attestations that are signed by one of co.Keys or, without keys,
+// by a certificate that chains up to co.Roots, and whose statement has desc as a subject.
+// With co.TLog, the envelopes must also be in the transparency log as intoto entries, see
+// UploadAttestationTLog, and certificates must have been valid when they were added.
+func VerifyAttestations(ctx context.Context, desc *v1.Descriptor, atts []Attestation, co CheckOpts) ([]VerifiedAttestation, error) {
+	if co.Roots == nil && len(co.Keys) == 0 {
+		return nil, errors.New("one of public key or cert roots is required")
+	}
+	var rekorClient *client.Rekor
+	if co.TLog {
+		var err error
+		if rekorClient, err = TlogClientFor(co.rekorURL()); err != nil {
+			return nil, err
+		}
+	}
+	verified := []VerifiedAttestation{}
+	validationErrs := []string{}
+	for _, a := range atts {
+		va, err := verifyAttestation(ctx, desc, a, co)
+		if err == nil && co.TLog {
+			err = va.verifyTlog(ctx, rekorClient)
+		}
+		if err != nil {
+			validationErrs = append(validationErrs, err.Error())
+			continue
+		}
+		verified = append(verified, *va)
+	}
+	if len(verified) == 0 {
+		return nil, fmt.Errorf("no matching attestations:\n%s", strings.Join(validationErrs, "\n "))
+	}
+	return verified, nil
+}
+
+func verifyAttestation(ctx context.Context, desc *v1.Descriptor, a Attestation, co CheckOpts) (*VerifiedAttestation, error) {
+	va := &VerifiedAttestation{Attestation: a}
+	if len(co.Keys) > 0 {
+		var err error
+		for _, k := range co.Keys {
+			if _, err = VerifyEnvelope(ctx, k, &a.Envelope); err == nil {
+				va.Key = k
+				break
+			}
+		}
+		if va.Key == nil {
+			return nil, err
+		}
+	} else {
+		if a.Cert == nil {
+			return nil, errors.New("no certificate found on attestation")
+		}
+		pub, ok := a.Cert.PublicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, errors.New("unsupported certificate key type")
+		}
+		if _, err := VerifyEnvelope(ctx, &ECDSAPublicKey{pub}, &a.Envelope); err != nil {
+			return nil, err
+		}
+		if err := TrustedCert(a.Cert, co.Roots, a.Chain...); err != nil {
+			return nil, err
+		}
+		if err := checkIdentity(a.Cert, co.Identities); err != nil {
+			return nil, err
+		}
+	}
+
+	st, err := a.Statement()
+	if err != nil {
+		return nil, err
+	}
+	if !st.HasSubject(desc.Digest) {
+		return nil, fmt.Errorf("attestation is not about %s", desc.Digest)
+	}
+	va.Statement = st
+	return va, nil
+}
+
+// verifyTlog finds the intoto entry of the envelope, checking the certificate was valid when it was added.
+func (va *VerifiedAttestation) verifyTlog(ctx context.Context, rekorClient *client.Rekor) error {
+	pemBytes := CertToPem(va.Cert)
+	if va.Key != nil {
+		var err error
+		if pemBytes, err = PublicKeyPem(ctx, va.Key); err != nil {
+			return err
+		}
+	}
+	uuid, err := FindAttestationTlogEntry(ctx, rekorClient, &va.Envelope, pemBytes)
+	if err != nil {
+		return errors.Wrap(err, "finding the attestation in the transparency log")
+	}
+	if va.Key == nil {
+		e, err := getTlogEntry(ctx, rekorClient, uuid)
+		if err != nil {
+			return err
+		}
+		if err := checkExpiry(va.Cert, time.Unix(e.IntegratedTime, 0)); err != nil {
+			return err
+		}
+	}
+	va.TlogEntryUUID = uuid
+	return nil
+}
+
+// HasSubject reports whether digest is one of the statement's subjects.
+func (st *Statement) HasSubject(digest v1.Hash) bool {
+	for _, s := range st.Subject {
+		if s.Digest[digest.Algorithm] == digest.Hex {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// testAttestation signs a statement about digest with the given predicate type.
+func testAttestation(t *testing.T, k *ECDSAKey, digest v1.Hash, predicateType string) Attestation {
+	t.Helper()
+	st := Statement{
+		Type:          "https://in-toto.io/Statement/v0.1",
+		PredicateType: predicateType,
+		Subject:       []Subject{{Name: "image", Digest: map[string]string{digest.Algorithm: digest.Hex}}},
+		Predicate:     json.RawMessage(`{}`),
+	}
+	b, err := json.Marshal(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := SignEnvelope(context.Background(), k, "", InTotoPayloadType, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Attestation{Envelope: *env}
+}
+
+func TestFilterAttestations(t *testing.T) {
+	priv, err := GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := WithECDSAKey(priv)
+	digest := v1.Hash{Algorithm: "sha256", Hex: "abc"}
+	atts := []Attestation{
+		testAttestation(t, k, digest, PredicateTypes["slsaprovenance"]),
+		testAttestation(t, k, digest, PredicateTypes["spdx"]),
+		{Envelope: Envelope{PayloadType: DefaultBlobPayloadType, Payload: "e30="}},
+	}
+
+	if got := FilterAttestations(atts, ""); len(got) != 3 {
+		t.Errorf("FilterAttestations() with no predicate type kept %d, want 3", len(got))
+	}
+	for _, pt := range []string{"slsaprovenance", PredicateTypes["slsaprovenance"]} {
+		got := FilterAttestations(atts, pt)
+		if len(got) != 1 {
+			t.Fatalf("FilterAttestations(%q) kept %d, want 1", pt, len(got))
+		}
+		st, err := got[0].Statement()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.PredicateType != PredicateTypes["slsaprovenance"] {
+			t.Errorf("FilterAttestations(%q) kept %s", pt, st.PredicateType)
+		}
+	}
+	if got := FilterAttestations(atts, "https://example.com/other"); len(got) != 0 {
+		t.Errorf("FilterAttestations() with an unknown predicate type kept %d, want 0", len(got))
+	}
+}
+
+func TestVerifyAttestations(t *testing.T) {
+	ctx := context.Background()
+	priv, err := GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := WithECDSAKey(priv)
+	otherPriv, err := GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := WithECDSAKey(otherPriv)
+	desc := &v1.Descriptor{Digest: v1.Hash{Algorithm: "sha256", Hex: "abc"}}
+	co := CheckOpts{Keys: []PublicKey{k}}
+
+	good := testAttestation(t, k, desc.Digest, PredicateTypes["custom"])
+	wrongKey := testAttestation(t, other, desc.Digest, PredicateTypes["custom"])
+	wrongSubject := testAttestation(t, k, v1.Hash{Algorithm: "sha256", Hex: "def"}, PredicateTypes["custom"])
+
+	verified, err := VerifyAttestations(ctx, desc, []Attestation{good, wrongKey, wrongSubject}, co)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(verified) != 1 || verified[0].Key != k || verified[0].Statement.PredicateType != PredicateTypes["custom"] {
+		t.Errorf("VerifyAttestations() = %+v, want only the good attestation", verified)
+	}
+	if _, err := VerifyAttestations(ctx, desc, []Attestation{wrongKey, wrongSubject}, co); err == nil {
+		t.Error("expected error when no attestation verifies")
+	}
+}
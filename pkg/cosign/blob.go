@@ -0,0 +1,133 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	_ "crypto/sha256" // Registers the hashes HashFunc returns.
+	_ "crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pkg/errors"
+)
+
+// DigestSigner signs a precomputed digest rather than the payload itself. The digest must be
+// computed with the HashFunc of the signer's key.
+// Signatures produced this way are interchangeable with Signer.Sign over the full payload.
+type DigestSigner interface {
+	SignDigest(ctx context.Context, digest []byte) (signature []byte, err error)
+}
+
+// DigestVerifier verifies a signature against a precomputed digest, see DigestSigner.
+type DigestVerifier interface {
+	VerifyDigest(ctx context.Context, digest, signature []byte) error
+}
+
+// HashReader computes the SHA-256 digest of everything read from r, using constant memory.
+func HashReader(r io.Reader) ([]byte, error) {
+	return HashReaderWith(r, crypto.SHA256)
+}
+
+// HashReaderWith computes the digest of everything read from r with h, using constant memory.
+func HashReaderWith(r io.Reader, h crypto.Hash) ([]byte, error) {
+	hasher := h.New()
+	if _, err := io.Copy(hasher, r); err != nil {
+		return nil, errors.Wrap(err, "hashing blob")
+	}
+	return hasher.Sum(nil), nil
+}
+
+// HashFunc returns the hash cosign signs with for pub: SHA-384 for ECDSA P-384 keys,
+// SHA-512 for P-521 keys, and SHA-256 for everything else.
+func HashFunc(pub crypto.PublicKey) crypto.Hash {
+	if pub, ok := pub.(*ecdsa.PublicKey); ok {
+		switch pub.Curve {
+		case elliptic.P384():
+			return crypto.SHA384
+		case elliptic.P521():
+			return crypto.SHA512
+		}
+	}
+	return crypto.SHA256
+}
+
+// keyHashFunc returns the HashFunc of k's key, or SHA-256 if k can't tell what its key is.
+func keyHashFunc(ctx context.Context, k interface{}) (crypto.Hash, error) {
+	p, ok := k.(PublicKeyProvider)
+	if !ok {
+		return crypto.SHA256, nil
+	}
+	pub, err := p.PublicKey(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return HashFunc(pub), nil
+}
+
+func hashBytes(h crypto.Hash, b []byte) []byte {
+	hasher := h.New()
+	hasher.Write(b)
+	return hasher.Sum(nil)
+}
+
+// SignBlob streams r through the hash of the signer's key and signs the resulting digest.
+// It returns both the signature and the digest so callers can record or upload it.
+func SignBlob(ctx context.Context, signer DigestSigner, r io.Reader) (signature, digest []byte, err error) {
+	h, err := keyHashFunc(ctx, signer)
+	if err != nil {
+		return nil, nil, err
+	}
+	digest, err = HashReaderWith(r, h)
+	if err != nil {
+		return nil, nil, err
+	}
+	signature, err = signer.SignDigest(ctx, digest)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "signing digest")
+	}
+	return signature, digest, nil
+}
+
+// VerifyBlob streams r through the hash of the verifier's key and checks the signature over
+// the resulting digest.
+func VerifyBlob(ctx context.Context, verifier DigestVerifier, r io.Reader, signature []byte) error {
+	h, err := keyHashFunc(ctx, verifier)
+	if err != nil {
+		return err
+	}
+	digest, err := HashReaderWith(r, h)
+	if err != nil {
+		return err
+	}
+	return verifier.VerifyDigest(ctx, digest, signature)
+}
+
+// ParseDigest parses a digest in the "sha256:<hex>" form and returns the raw digest bytes.
+func ParseDigest(s string) ([]byte, error) {
+	h, err := v1.NewHash(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing digest")
+	}
+	if h.Algorithm != "sha256" {
+		return nil, fmt.Errorf("unsupported digest algorithm: %s", h.Algorithm)
+	}
+	return hex.DecodeString(h.Hex)
+}
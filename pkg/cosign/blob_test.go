@@ -0,0 +1,84 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestSignVerifyBlob(t *testing.T) {
+	for _, alg := range KeyAlgorithms {
+		t.Run(alg, func(t *testing.T) {
+			testSignVerifyBlob(t, alg)
+		})
+	}
+}
+
+func testSignVerifyBlob(t *testing.T, alg string) {
+	ctx := context.Background()
+	priv, err := GeneratePrivateKeyWithAlgorithm(alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := WithECDSAKey(priv)
+
+	blob := bytes.Repeat([]byte("cosign"), 1<<16)
+	sig, digest, err := SignBlob(ctx, k, bytes.NewReader(blob))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(digest) != HashFunc(priv.Public()).Size() {
+		t.Errorf("SignBlob() digest is %d bytes, want the %s hash", len(digest), alg)
+	}
+
+	// A streamed signature must verify against the whole payload, and vice versa.
+	if err := k.Verify(ctx, blob, sig); err != nil {
+		t.Errorf("Verify() over streamed signature: %v", err)
+	}
+	fullSig, err := k.Sign(ctx, blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyBlob(ctx, k, bytes.NewReader(blob), fullSig); err != nil {
+		t.Errorf("VerifyBlob() over payload signature: %v", err)
+	}
+
+	if err := VerifyBlob(ctx, k, bytes.NewReader(blob[1:]), sig); err == nil {
+		t.Error("expected error verifying modified blob")
+	}
+}
+
+func TestParseDigest(t *testing.T) {
+	blob := []byte("hello")
+	want, err := HashReader(bytes.NewReader(blob))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseDigest("sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("ParseDigest() = %x, want %x", got, want)
+	}
+
+	for _, bad := range []string{"", "2cf24dba", "sha256:nothex", "sha512:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"} {
+		if _, err := ParseDigest(bad); err == nil {
+			t.Errorf("ParseDigest(%q) expected error", bad)
+		}
+	}
+}
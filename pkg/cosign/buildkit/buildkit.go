@@ -0,0 +1,158 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package buildkit finds the images BuildKit pushed by the digest its image exporter returned, so
+// they can be signed right away. Resolving the tags after the build instead would sign whatever
+// they were re-pushed to in the meantime.
+package buildkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DigestKey is the exporter metadata key of the digest of the pushed image.
+	DigestKey = "containerimage.digest"
+	// NameKey is the exporter metadata key of the names the image was pushed as, separated by commas.
+	NameKey = "image.name"
+)
+
+// Images returns the images a build pushed, by digest, from the exporter metadata b written by
+// "docker buildx build --metadata-file", "docker buildx bake --metadata-file" or
+// "buildctl build --metadata-file". opts apply to parsing the image names.
+func Images(b []byte, opts ...name.Option) ([]name.Digest, error) {
+	md := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &md); err != nil {
+		return nil, errors.Wrap(err, "parsing build metadata")
+	}
+	if _, ok := md[DigestKey]; ok {
+		return exported(md, opts)
+	}
+	// bake writes the metadata of each target under its name.
+	targets := make([]string, 0, len(md))
+	for t := range md {
+		targets = append(targets, t)
+	}
+	sort.Strings(targets)
+	images := []name.Digest{}
+	for _, t := range targets {
+		// Entries other than targets aren't objects.
+		target := map[string]json.RawMessage{}
+		if err := json.Unmarshal(md[t], &target); err != nil {
+			continue
+		}
+		if _, ok := target[DigestKey]; !ok {
+			continue
+		}
+		digests, err := exported(target, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "target %s", t)
+		}
+		images = append(images, digests...)
+	}
+	if len(images) == 0 {
+		return nil, errors.New("the build didn't push any image")
+	}
+	return images, nil
+}
+
+// exported returns the images the exporter metadata md of a single build says were pushed.
+func exported(md map[string]json.RawMessage, opts []name.Option) ([]name.Digest, error) {
+	var digest, names string
+	if err := json.Unmarshal(md[DigestKey], &digest); err != nil {
+		return nil, errors.Wrap(err, "parsing image digest")
+	}
+	if n, ok := md[NameKey]; ok {
+		if err := json.Unmarshal(n, &names); err != nil {
+			return nil, errors.Wrap(err, "parsing image names")
+		}
+	}
+	images := []name.Digest{}
+	seen := map[string]bool{}
+	for _, n := range strings.Split(names, ",") {
+		if n = strings.TrimSpace(n); n == "" {
+			continue
+		}
+		ref, err := name.ParseReference(n, opts...)
+		if err != nil {
+			return nil, err
+		}
+		d, err := name.NewDigest(ref.Context().Name()+"@"+digest, opts...)
+		if err != nil {
+			return nil, err
+		}
+		// Several tags of the same repository are the same image.
+		if !seen[d.String()] {
+			seen[d.String()] = true
+			images = append(images, d)
+		}
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("image %s was pushed without a name", digest)
+	}
+	return images, nil
+}
+
+// Build runs "docker buildx build" with args, pushing the image unless args set an --output, and
+// returns the images it pushed. The build's output goes to stdout and stderr.
+func Build(ctx context.Context, args []string, stdout, stderr io.Writer, opts ...name.Option) ([]name.Digest, error) {
+	f, err := ioutil.TempFile("", "cosign-build-metadata-*.json")
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	buildArgs := []string{"buildx", "build", "--metadata-file", f.Name()}
+	if !hasOutput(args) {
+		buildArgs = append(buildArgs, "--push")
+	}
+	cmd := exec.CommandContext(ctx, "docker", append(buildArgs, args...)...) // nolint: gosec
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrap(err, "docker buildx build")
+	}
+	b, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil, errors.New("docker buildx didn't write the build metadata, it needs to be v0.6.0 or later")
+	}
+	return Images(b, opts...)
+}
+
+// hasOutput reports whether the buildx args choose where the image is exported to.
+func hasOutput(args []string) bool {
+	for _, a := range args {
+		switch {
+		case a == "--push", a == "--load", a == "-o", a == "--output", strings.HasPrefix(a, "--output="), strings.HasPrefix(a, "-o="):
+			return true
+		}
+	}
+	return false
+}
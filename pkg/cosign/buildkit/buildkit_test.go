@@ -0,0 +1,120 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildkit
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+const digest = "sha256:1d43f5bc5a1f2c8e0a2a8e8d0c0b6a1f1c1fd8a0b3d2c4e5f60718293a4b5c6d"
+
+func names(digests []name.Digest) []string {
+	out := []string{}
+	for _, d := range digests {
+		out = append(out, d.String())
+	}
+	return out
+}
+
+func TestImages(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata string
+		want     []string
+		wantErr  bool
+	}{{
+		name:     "build",
+		metadata: `{"containerimage.digest": "` + digest + `", "image.name": "gcr.io/acme/app:v1,gcr.io/acme/app:latest,ghcr.io/acme/app:v1", "containerimage.config.digest": "sha256:00"}`,
+		want:     []string{"gcr.io/acme/app@" + digest, "ghcr.io/acme/app@" + digest},
+	}, {
+		name:     "bake",
+		metadata: `{"web": {"containerimage.digest": "` + digest + `", "image.name": "gcr.io/acme/web:v1"}, "api": {"containerimage.digest": "` + digest + `", "image.name": "gcr.io/acme/api:v1"}, "test": {}, "buildx.build.warnings": []}`,
+		want:     []string{"gcr.io/acme/api@" + digest, "gcr.io/acme/web@" + digest},
+	}, {
+		name:     "no name",
+		metadata: `{"containerimage.digest": "` + digest + `"}`,
+		wantErr:  true,
+	}, {
+		name:     "nothing pushed",
+		metadata: `{"test": {"buildx.build.ref": "default/default/abc"}}`,
+		wantErr:  true,
+	}, {
+		name:     "bad digest",
+		metadata: `{"containerimage.digest": "sha256:00", "image.name": "gcr.io/acme/app:v1"}`,
+		wantErr:  true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Images([]byte(tt.metadata))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Images() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(names(got), tt.want) {
+				t.Errorf("Images() = %v, want %v", names(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	// A fake docker that records its arguments and writes the metadata buildx would.
+	docker := "#!/bin/sh\n" +
+		"echo \"$@\" > " + argsFile + "\n" +
+		"while [ $# -gt 0 ]; do\n" +
+		"  if [ \"$1\" = --metadata-file ]; then\n" +
+		"    echo '{\"containerimage.digest\": \"" + digest + "\", \"image.name\": \"gcr.io/acme/app:v1\"}' > \"$2\"\n" +
+		"  fi\n" +
+		"  shift\n" +
+		"done\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "docker"), []byte(docker), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	defer os.Setenv("PATH", path)
+
+	for _, tt := range []struct {
+		args     []string
+		wantPush bool
+	}{
+		{[]string{"-t", "gcr.io/acme/app:v1", "."}, true},
+		{[]string{"-t", "gcr.io/acme/app:v1", "--output=type=image,push=true", "."}, false},
+	} {
+		got, err := Build(context.Background(), tt.args, ioutil.Discard, ioutil.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"gcr.io/acme/app@" + digest}; !reflect.DeepEqual(names(got), want) {
+			t.Errorf("Build() = %v, want %v", names(got), want)
+		}
+		b, err := ioutil.ReadFile(argsFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pushed := strings.Contains(string(b), " --push "); pushed != tt.wantPush {
+			t.Errorf("docker %s, want --push %v", b, tt.wantPush)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// BundleMediaType identifies cosign bundle files.
+const BundleMediaType = "application/vnd.dev.sigstore.cosign.bundle.v1+json"
+
+// Bundle carries a signature together with everything needed to verify it,
+// so an artifact and its verification material can travel as a single file.
+// Exactly one of MessageSignature and DSSEEnvelope is set.
+type Bundle struct {
+	MediaType            string               `json:"mediaType"`
+	VerificationMaterial VerificationMaterial `json:"verificationMaterial"`
+	MessageSignature     *MessageSignature    `json:"messageSignature,omitempty"`
+	DSSEEnvelope         *Envelope            `json:"dsseEnvelope,omitempty"`
+	// Payload holds the signed payload for image signatures, which is not the artifact itself.
+	Payload string `json:"payload,omitempty"`
+}
+
+// VerificationMaterial is the key or certificate that produced a bundle's signature,
+// and the transparency log entry recording it, if any.
+type VerificationMaterial struct {
+	PublicKey   string    `json:"publicKey,omitempty"`
+	Certificate string    `json:"certificate,omitempty"`
+	Chain       string    `json:"chain,omitempty"`
+	TlogEntry   *TlogInfo `json:"tlogEntry,omitempty"`
+}
+
+type TlogInfo struct {
+	LogIndex string `json:"logIndex"`
+	LogURL   string `json:"logURL"`
+}
+
+type MessageSignature struct {
+	MessageDigest MessageDigest `json:"messageDigest"`
+	Signature     string        `json:"signature"`
+}
+
+type MessageDigest struct {
+	Algorithm string `json:"algorithm"`
+	Digest    string `json:"digest"`
+}
+
+// bundleDigestHashes are the digest algorithms a MessageDigest can use.
+var bundleDigestHashes = map[string]crypto.Hash{
+	"SHA2_256": crypto.SHA256,
+	"SHA2_384": crypto.SHA384,
+	"SHA2_512": crypto.SHA512,
+}
+
+// BundleDigestHash returns the hash a MessageDigest algorithm stands for.
+func BundleDigestHash(algorithm string) (crypto.Hash, error) {
+	h, ok := bundleDigestHashes[algorithm]
+	if !ok {
+		return 0, fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+	return h, nil
+}
+
+// bundleDigestAlgorithm names the algorithm of a digest by its size.
+func bundleDigestAlgorithm(digest []byte) string {
+	for name, h := range bundleDigestHashes {
+		if h.Size() == len(digest) {
+			return name
+		}
+	}
+	return ""
+}
+
+// NewBlobBundle creates a bundle for a signature over the given SHA-256, SHA-384 or SHA-512 digest.
+// pemBytes is either a PEM encoded public key or certificate.
+func NewBlobBundle(signature, digest, pemBytes []byte) *Bundle {
+	return &Bundle{
+		MediaType:            BundleMediaType,
+		VerificationMaterial: verificationMaterial(pemBytes),
+		MessageSignature: &MessageSignature{
+			MessageDigest: MessageDigest{
+				Algorithm: bundleDigestAlgorithm(digest),
+				Digest:    base64.StdEncoding.EncodeToString(digest),
+			},
+			Signature: base64.StdEncoding.EncodeToString(signature),
+		},
+	}
+}
+
+// NewPayloadBundle creates a bundle for a signature over an image payload.
+func NewPayloadBundle(signature, payload, pemBytes []byte, chain string) *Bundle {
+	h := sha256.Sum256(payload)
+	b := NewBlobBundle(signature, h[:], pemBytes)
+	b.Payload = base64.StdEncoding.EncodeToString(payload)
+	b.VerificationMaterial.Chain = chain
+	return b
+}
+
+func verificationMaterial(pemBytes []byte) VerificationMaterial {
+	if certs, err := LoadCerts(string(pemBytes)); err == nil && len(certs) > 0 {
+		return VerificationMaterial{Certificate: string(pemBytes)}
+	}
+	return VerificationMaterial{PublicKey: string(pemBytes)}
+}
+
+// ParseBundle returns the bundle encoded in b, or an error if b isn't one.
+func ParseBundle(b []byte) (*Bundle, error) {
+	bundle := &Bundle{}
+	if err := json.Unmarshal(b, bundle); err != nil {
+		return nil, err
+	}
+	if bundle.MediaType != BundleMediaType {
+		return nil, errors.New("not a cosign bundle")
+	}
+	if (bundle.MessageSignature == nil) == (bundle.DSSEEnvelope == nil) {
+		return nil, errors.New("bundle must contain exactly one of messageSignature and dsseEnvelope")
+	}
+	return bundle, nil
+}
+
+// SignedPayload converts an image bundle into the SignedPayload form used for verification.
+func (b *Bundle) SignedPayload() (SignedPayload, error) {
+	if b.MessageSignature == nil || b.Payload == "" {
+		return SignedPayload{}, errors.New("bundle does not contain an image signature")
+	}
+	payload, err := base64.StdEncoding.DecodeString(b.Payload)
+	if err != nil {
+		return SignedPayload{}, errors.Wrap(err, "decoding payload")
+	}
+	sp := SignedPayload{
+		Base64Signature: b.MessageSignature.Signature,
+		Payload:         payload,
+	}
+	if b.VerificationMaterial.Certificate != "" {
+		certs, err := LoadCerts(b.VerificationMaterial.Certificate)
+		if err != nil {
+			return SignedPayload{}, err
+		}
+		if len(certs) == 0 {
+			return SignedPayload{}, errors.New("no certificate found in bundle")
+		}
+		sp.Cert = certs[0]
+	}
+	if b.VerificationMaterial.Chain != "" {
+		certs, err := LoadCerts(b.VerificationMaterial.Chain)
+		if err != nil {
+			return SignedPayload{}, err
+		}
+		sp.Chain = certs
+	}
+	return sp, nil
+}
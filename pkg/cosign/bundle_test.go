@@ -0,0 +1,73 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestPayloadBundle(t *testing.T) {
+	ctx := context.Background()
+	priv, err := GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := WithECDSAKey(priv)
+	pemBytes, err := PublicKeyPem(ctx, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte(`{"critical":{}}`)
+	sig, err := k.Sign(ctx, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(NewPayloadBundle(sig, payload, pemBytes, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := ParseBundle(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundle.VerificationMaterial.PublicKey != string(pemBytes) || bundle.VerificationMaterial.Certificate != "" {
+		t.Errorf("unexpected verification material: %+v", bundle.VerificationMaterial)
+	}
+	sp, err := bundle.SignedPayload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(sp.Payload) != string(payload) {
+		t.Errorf("SignedPayload().Payload = %s, want %s", sp.Payload, payload)
+	}
+	if err := sp.VerifyKey(ctx, k); err != nil {
+		t.Errorf("VerifyKey() = %v", err)
+	}
+}
+
+func TestParseBundle(t *testing.T) {
+	for _, bad := range []string{
+		`{}`,
+		`{"mediaType":"application/vnd.dev.sigstore.cosign.bundle.v1+json"}`,
+		`{"payloadType":"application/octet-stream","signatures":[{"sig":"MEUCIQ=="}]}`,
+	} {
+		if _, err := ParseBundle([]byte(bad)); err == nil {
+			t.Errorf("ParseBundle(%s) expected error", bad)
+		}
+	}
+}
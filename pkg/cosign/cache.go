@@ -0,0 +1,247 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sigstore/rekor/pkg/generated/client"
+
+	"github.com/sigstore/cosign/pkg/cosign/fulcio"
+)
+
+// CacheDirEnv overrides the directory verification material is cached in.
+const CacheDirEnv = "COSIGN_CACHE_DIR"
+
+// Cache keeps verification material fetched from registries and the transparency log on disk,
+// so repeated verifications of the same image don't fetch it again.
+// A nil *Cache caches nothing.
+type Cache struct {
+	Dir string
+	// TTL is how long entries are used for before being fetched again.
+	TTL time.Duration
+}
+
+// NewCache returns a cache in $COSIGN_CACHE_DIR, or the user's cache directory by default.
+func NewCache(ttl time.Duration) (*Cache, error) {
+	dir := os.Getenv(CacheDirEnv)
+	if dir == "" {
+		ucd, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(ucd, "cosign")
+	}
+	return &Cache{Dir: dir, TTL: ttl}, nil
+}
+
+func (c *Cache) path(kind, key string) string {
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, kind, hex.EncodeToString(h[:]))
+}
+
+// get decodes the entry for key into v, reporting whether there was a fresh one.
+func (c *Cache) get(kind, key string, v interface{}) bool {
+	if c == nil {
+		return false
+	}
+	p := c.path(kind, key)
+	fi, err := os.Stat(p)
+	if err != nil || time.Since(fi.ModTime()) > c.TTL {
+		return false
+	}
+	b, err := ioutil.ReadFile(filepath.Clean(p))
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(b, v) == nil
+}
+
+// put stores v for key. Failing to cache isn't worth failing a verification over, so errors are dropped.
+func (c *Cache) put(kind, key string, v interface{}) {
+	if c == nil {
+		return
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	p := c.path(kind, key)
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return
+	}
+	// Write then rename, so concurrent verifications never read a partial entry.
+	f, err := ioutil.TempFile(filepath.Dir(p), ".tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+// remove drops the entry for key, if there is one.
+func (c *Cache) remove(kind, key string) {
+	if c == nil {
+		return
+	}
+	os.Remove(c.path(kind, key))
+}
+
+// cachedSignature is the on-disk form of a SignedPayload, certificates don't round trip through JSON.
+type cachedSignature struct {
+	Base64Signature     string
+	Payload             []byte
+	Cert                string      `json:",omitempty"`
+	Chain               string      `json:",omitempty"`
+	KeyID               string      `json:",omitempty"`
+	Algorithm           string      `json:",omitempty"`
+	SCT                 *fulcio.SCT `json:",omitempty"`
+	HardwareAttestation string      `json:",omitempty"`
+}
+
+// FetchSignatures is cosign.FetchSignatures, using the signatures cached for the image's digest when
+// there are any. The image itself is always looked up, since tags can move.
+func (c *Cache) FetchSignatures(ctx context.Context, ref name.Reference, opts ...remote.Option) ([]SignedPayload, *v1.Descriptor, error) {
+	return fetchSignatures(ctx, ref, c, opts)
+}
+
+func (c *Cache) signatures(key string) ([]SignedPayload, bool) {
+	var cached []cachedSignature
+	if !c.get("signatures", key, &cached) {
+		return nil, false
+	}
+	sps := make([]SignedPayload, 0, len(cached))
+	for _, cs := range cached {
+		sp := SignedPayload{
+			Base64Signature: cs.Base64Signature,
+			Payload:         cs.Payload,
+			KeyID:           cs.KeyID,
+			Algorithm:       cs.Algorithm,
+			SCT:             cs.SCT,
+		}
+		if cs.Cert != "" {
+			certs, err := LoadCerts(cs.Cert)
+			if err != nil || len(certs) == 0 {
+				return nil, false
+			}
+			sp.Cert = certs[0]
+		}
+		if cs.Chain != "" {
+			certs, err := LoadCerts(cs.Chain)
+			if err != nil {
+				return nil, false
+			}
+			sp.Chain = certs
+		}
+		if cs.HardwareAttestation != "" {
+			certs, err := LoadCerts(cs.HardwareAttestation)
+			if err != nil {
+				return nil, false
+			}
+			sp.HardwareAttestation = certs
+		}
+		sps = append(sps, sp)
+	}
+	return sps, true
+}
+
+func (c *Cache) putSignatures(key string, sps []SignedPayload) {
+	if c == nil {
+		return
+	}
+	cached := make([]cachedSignature, 0, len(sps))
+	for _, sp := range sps {
+		cs := cachedSignature{
+			Base64Signature: sp.Base64Signature,
+			Payload:         sp.Payload,
+			KeyID:           sp.KeyID,
+			Algorithm:       sp.Algorithm,
+			SCT:             sp.SCT,
+		}
+		if sp.Cert != nil {
+			cs.Cert = string(CertToPem(sp.Cert))
+		}
+		cs.Chain = certsToPem(sp.Chain)
+		cs.HardwareAttestation = certsToPem(sp.HardwareAttestation)
+		cached = append(cached, cs)
+	}
+	c.put("signatures", key, cached)
+}
+
+// certsToPem concatenates the PEM encodings of certs.
+func certsToPem(certs []*x509.Certificate) string {
+	pems := []string{}
+	for _, cert := range certs {
+		pems = append(pems, string(CertToPem(cert)))
+	}
+	return strings.Join(pems, "")
+}
+
+// tlogEntryUUID finds and verifies the inclusion of the signature in the log, or returns the
+// entry found by an earlier verification. Entries never change once they're in the log.
+func (c *Cache) tlogEntryUUID(ctx context.Context, rc *client.Rekor, server string, sp SignedPayload, pemBytes []byte) (string, error) {
+	h := sha256.New()
+	for _, b := range [][]byte{[]byte(sp.Base64Signature), sp.Payload, pemBytes} {
+		h.Write([]byte(base64.StdEncoding.EncodeToString(b) + "\n"))
+	}
+	key := server + "\n" + hex.EncodeToString(h.Sum(nil))
+	var uuid string
+	if c.get("tlog", key, &uuid) {
+		return uuid, nil
+	}
+	uuid, err := sp.VerifyTlog(ctx, rc, pemBytes)
+	if err != nil {
+		return "", err
+	}
+	c.put("tlog", key, uuid)
+	return uuid, nil
+}
+
+// tlogIntegratedTime returns when the entry was added to the log.
+func (c *Cache) tlogIntegratedTime(ctx context.Context, rc *client.Rekor, server, uuid string) (int64, error) {
+	key := server + "\n" + uuid
+	var it int64
+	if c.get("tlog-time", key, &it) {
+		return it, nil
+	}
+	e, err := getTlogEntry(ctx, rc, uuid)
+	if err != nil {
+		return 0, err
+	}
+	c.put("tlog-time", key, e.IntegratedTime)
+	return e.IntegratedTime, nil
+}
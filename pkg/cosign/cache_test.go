@@ -0,0 +1,116 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	td, err := ioutil.TempDir("", "cosign-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	c := &Cache{Dir: td, TTL: time.Minute}
+
+	var got string
+	if c.get("test", "key", &got) {
+		t.Fatal("get() found an entry in an empty cache")
+	}
+	c.put("test", "key", "value")
+	if !c.get("test", "key", &got) || got != "value" {
+		t.Fatalf("get() = %q, want value", got)
+	}
+
+	old := time.Now().Add(-2 * time.Minute)
+	if err := os.Chtimes(c.path("test", "key"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if c.get("test", "key", &got) {
+		t.Error("get() returned an expired entry")
+	}
+
+	var nilCache *Cache
+	nilCache.put("test", "key", "value")
+	if nilCache.get("test", "key", &got) {
+		t.Error("nil cache returned an entry")
+	}
+}
+
+func TestCacheSignatures(t *testing.T) {
+	td, err := ioutil.TempDir("", "cosign-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	c := &Cache{Dir: td, TTL: time.Minute}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sps := []SignedPayload{
+		{Base64Signature: "c2lnMQ==", Payload: []byte(`{"a":1}`), KeyID: "key"},
+		{Base64Signature: "c2lnMg==", Payload: []byte(`{"b":2}`), Cert: cert, Chain: []*x509.Certificate{cert, cert}},
+	}
+	c.putSignatures("repo:sha256-abc.cosign", sps)
+	got, ok := c.signatures("repo:sha256-abc.cosign")
+	if !ok {
+		t.Fatal("signatures() found nothing")
+	}
+	if len(got) != len(sps) {
+		t.Fatalf("signatures() returned %d signatures, want %d", len(got), len(sps))
+	}
+	for i := range sps {
+		if got[i].Base64Signature != sps[i].Base64Signature || string(got[i].Payload) != string(sps[i].Payload) || got[i].KeyID != sps[i].KeyID {
+			t.Errorf("signature %d = %+v, want %+v", i, got[i], sps[i])
+		}
+	}
+	if got[0].Cert != nil {
+		t.Error("signature 0 has a certificate")
+	}
+	if got[1].Cert == nil || !got[1].Cert.Equal(cert) {
+		t.Error("signature 1 certificate didn't round trip")
+	}
+	if len(got[1].Chain) != 2 {
+		t.Errorf("signature 1 chain has %d certificates, want 2", len(got[1].Chain))
+	}
+	if _, ok := c.signatures("repo:sha256-def.cosign"); ok {
+		t.Error("signatures() found signatures for another digest")
+	}
+}
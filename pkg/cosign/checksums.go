@@ -0,0 +1,103 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Checksums maps file names to their SHA-256 digests, as found in a SHA256SUMS file.
+type Checksums map[string][]byte
+
+// WriteChecksums hashes each of the files and writes them to w in the format used by sha256sum.
+func WriteChecksums(w io.Writer, paths []string) error {
+	for _, p := range paths {
+		f, err := os.Open(filepath.Clean(p))
+		if err != nil {
+			return err
+		}
+		digest, err := HashReader(f)
+		f.Close()
+		if err != nil {
+			return errors.Wrapf(err, "hashing %s", p)
+		}
+		if _, err := fmt.Fprintf(w, "%x  %s\n", digest, filepath.ToSlash(p)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseChecksums reads a checksum file as produced by sha256sum, in either text or binary mode.
+func ParseChecksums(r io.Reader) (Checksums, error) {
+	sums := Checksums{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid checksum line %d", n)
+		}
+		digest, err := hex.DecodeString(fields[0])
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("invalid sha256 digest on line %d", n)
+		}
+		// A leading "*" marks binary mode, otherwise the separator is two spaces.
+		name := strings.TrimPrefix(strings.TrimPrefix(fields[1], " "), "*")
+		sums[filepath.ToSlash(filepath.Clean(name))] = digest
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
+
+// Verify checks that the file at path hashes to the digest recorded for it.
+// Files are looked up by their path, falling back to their base name.
+func (c Checksums) Verify(path string) error {
+	want, ok := c[filepath.ToSlash(filepath.Clean(path))]
+	if !ok {
+		want, ok = c[filepath.Base(path)]
+	}
+	if !ok {
+		return fmt.Errorf("%s not found in checksums", path)
+	}
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	got, err := HashReader(f)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("checksum mismatch for %s: got %x, want %x", path, got, want)
+	}
+	return nil
+}
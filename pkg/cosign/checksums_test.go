@@ -0,0 +1,79 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChecksums(t *testing.T) {
+	td := t.TempDir()
+	a := filepath.Join(td, "a.tar.gz")
+	b := filepath.Join(td, "b.zip")
+	if err := ioutil.WriteFile(a, []byte("a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(b, []byte("b"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteChecksums(&buf, []string{a, b}); err != nil {
+		t.Fatal(err)
+	}
+	sums, err := ParseChecksums(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sums.Verify(a); err != nil {
+		t.Errorf("Verify(%s): %v", a, err)
+	}
+
+	// Tamper with one of the files.
+	if err := ioutil.WriteFile(b, []byte("evil"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := sums.Verify(b); err == nil {
+		t.Error("expected error verifying modified file")
+	}
+
+	// Files that aren't listed fail.
+	if err := sums.Verify(filepath.Join(td, "c")); err == nil {
+		t.Error("expected error verifying unlisted file")
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	sums, err := ParseChecksums(strings.NewReader(`
+ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb  a.txt
+3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d *dir/b.bin
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sums) != 2 || sums["a.txt"] == nil || sums["dir/b.bin"] == nil {
+		t.Errorf("unexpected checksums: %v", sums)
+	}
+
+	for _, bad := range []string{"nothex  a.txt", "abcd", "abcd  short.txt"} {
+		if _, err := ParseChecksums(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseChecksums(%q) expected error", bad)
+		}
+	}
+}
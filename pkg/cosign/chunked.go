@@ -0,0 +1,229 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+const (
+	// DefaultChunkSize is how much of a blob ChunkedUploader sends per request, unless told otherwise.
+	DefaultChunkSize = 8 << 20
+
+	uploadSessionKind = "uploads"
+)
+
+// ChunkedUploader pushes blobs in chunks through a registry upload session. Sessions are recorded
+// as chunks are accepted, so running an interrupted upload again continues where it stopped,
+// as long as the registry still has the session.
+type ChunkedUploader struct {
+	// Transport is authenticated to push to the repositories blobs are uploaded to.
+	Transport http.RoundTripper
+	// ChunkSize is the size of each request, DefaultChunkSize if it isn't set.
+	ChunkSize int64
+	// Sessions records unfinished uploads. If it is nil, uploads always start over.
+	Sessions *Cache
+}
+
+// uploadSession is what is recorded of an unfinished upload.
+type uploadSession struct {
+	Location string
+}
+
+// Upload pushes the contents of l to repo, unless the registry already has them.
+func (u *ChunkedUploader) Upload(ctx context.Context, repo name.Repository, l v1.Layer) error {
+	digest, err := l.Digest()
+	if err != nil {
+		return err
+	}
+	size, err := l.Size()
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Transport: u.Transport}
+	base := &url.URL{Scheme: repo.Registry.Scheme(), Host: repo.RegistryStr()}
+
+	exists, err := blobExists(ctx, client, base, repo, digest)
+	if err != nil || exists {
+		return err
+	}
+
+	key := repo.Name() + "@" + digest.String()
+	sess := uploadSession{}
+	offset := int64(-1)
+	if u.Sessions.get(uploadSessionKind, key, &sess) {
+		if offset, err = uploadedBytes(ctx, client, sess.Location); err != nil {
+			log.Debugf("starting the upload of %s over: %v", digest, err)
+			offset = -1
+		} else if offset > 0 {
+			log.Infof("Resuming the upload of %s at %d of %d bytes", digest, offset, size)
+		}
+	}
+	if offset < 0 {
+		if sess.Location, err = startUpload(ctx, client, base, repo); err != nil {
+			return err
+		}
+		offset = 0
+		u.Sessions.put(uploadSessionKind, key, sess)
+	}
+
+	rc, err := l.Compressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if _, err := io.CopyN(ioutil.Discard, rc, offset); err != nil {
+		return errors.Wrap(err, "skipping the uploaded bytes")
+	}
+	chunkSize := u.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	buf := make([]byte, chunkSize)
+	for offset < size {
+		n, err := io.ReadFull(rc, buf)
+		if n == 0 {
+			return fmt.Errorf("%s ended after %d of %d bytes: %v", digest, offset, size, err)
+		}
+		if sess.Location, err = uploadChunk(ctx, client, sess.Location, buf[:n], offset); err != nil {
+			return err
+		}
+		offset += int64(n)
+		u.Sessions.put(uploadSessionKind, key, sess)
+	}
+	if err := finishUpload(ctx, client, sess.Location, digest); err != nil {
+		return err
+	}
+	u.Sessions.remove(uploadSessionKind, key)
+	return nil
+}
+
+func blobExists(ctx context.Context, client *http.Client, base *url.URL, repo name.Repository, digest v1.Hash) (bool, error) {
+	u := base.ResolveReference(&url.URL{Path: fmt.Sprintf("/v2/%s/blobs/%s", repo.RepositoryStr(), digest)})
+	resp, err := uploadRequest(ctx, client, http.MethodHead, u.String(), nil, nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+// startUpload opens an upload session, and returns its location.
+func startUpload(ctx context.Context, client *http.Client, base *url.URL, repo name.Repository) (string, error) {
+	u := base.ResolveReference(&url.URL{Path: fmt.Sprintf("/v2/%s/blobs/uploads/", repo.RepositoryStr())})
+	resp, err := uploadRequest(ctx, client, http.MethodPost, u.String(), nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := transport.CheckError(resp, http.StatusAccepted); err != nil {
+		return "", err
+	}
+	return uploadLocation(resp)
+}
+
+// uploadedBytes asks the registry how much of the session at loc it has, so the upload can resume after it.
+func uploadedBytes(ctx context.Context, client *http.Client, loc string) (int64, error) {
+	resp, err := uploadRequest(ctx, client, http.MethodGet, loc, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := transport.CheckError(resp, http.StatusNoContent); err != nil {
+		return 0, err
+	}
+	// The range is inclusive, "0-0" when nothing has been uploaded yet.
+	r := resp.Header.Get("Range")
+	if r == "" || r == "0-0" {
+		return 0, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r, "bytes="), "-", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid upload range %q", r)
+	}
+	end, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid upload range %q", r)
+	}
+	return end + 1, nil
+}
+
+// uploadChunk sends b, starting at offset of the blob, and returns where the session continues.
+func uploadChunk(ctx context.Context, client *http.Client, loc string, b []byte, offset int64) (string, error) {
+	hdr := http.Header{}
+	hdr.Set("Content-Type", "application/octet-stream")
+	hdr.Set("Content-Range", fmt.Sprintf("%d-%d", offset, offset+int64(len(b))-1))
+	resp, err := uploadRequest(ctx, client, http.MethodPatch, loc, hdr, b)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := transport.CheckError(resp, http.StatusAccepted, http.StatusNoContent, http.StatusCreated); err != nil {
+		return "", err
+	}
+	return uploadLocation(resp)
+}
+
+// finishUpload closes the session at loc, committing the blob as digest.
+func finishUpload(ctx context.Context, client *http.Client, loc string, digest v1.Hash) error {
+	u, err := url.Parse(loc)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("digest", digest.String())
+	u.RawQuery = q.Encode()
+	resp, err := uploadRequest(ctx, client, http.MethodPut, u.String(), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return transport.CheckError(resp, http.StatusCreated)
+}
+
+func uploadRequest(ctx context.Context, client *http.Client, method, u string, hdr http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range hdr {
+		req.Header[k] = v
+	}
+	return client.Do(req)
+}
+
+// uploadLocation returns the absolute address of the session a response points to.
+func uploadLocation(resp *http.Response) (string, error) {
+	loc, err := resp.Location()
+	if err != nil {
+		return "", errors.Wrap(err, "upload session")
+	}
+	return loc.String(), nil
+}
@@ -0,0 +1,201 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// chunkRegistry implements the blob upload API, failing PATCH requests while failPatch is set.
+type chunkRegistry struct {
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	sessions  map[string][]byte
+	next      int
+	failPatch bool
+	received  int
+	posts     int
+}
+
+func (r *chunkRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	const uploads = "/v2/repo/blobs/uploads/"
+	switch {
+	case req.Method == http.MethodHead && strings.HasPrefix(req.URL.Path, "/v2/repo/blobs/sha256:"):
+		if _, ok := r.blobs[strings.TrimPrefix(req.URL.Path, "/v2/repo/blobs/")]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case req.Method == http.MethodPost && req.URL.Path == uploads:
+		r.posts++
+		r.next++
+		id := strconv.Itoa(r.next)
+		r.sessions[id] = nil
+		w.Header().Set("Location", uploads+id)
+		w.WriteHeader(http.StatusAccepted)
+	case strings.HasPrefix(req.URL.Path, uploads):
+		id := strings.TrimPrefix(req.URL.Path, uploads)
+		b, ok := r.sessions[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch req.Method {
+		case http.MethodGet:
+			if len(b) > 0 {
+				w.Header().Set("Range", fmt.Sprintf("0-%d", len(b)-1))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodPatch:
+			if r.failPatch {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if want := fmt.Sprintf("%d-", len(b)); !strings.HasPrefix(req.Header.Get("Content-Range"), want) {
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			chunk, _ := ioutil.ReadAll(req.Body)
+			r.received += len(chunk)
+			r.sessions[id] = append(b, chunk...)
+			w.Header().Set("Location", uploads+id)
+			w.WriteHeader(http.StatusAccepted)
+		case http.MethodPut:
+			h := sha256.Sum256(b)
+			digest := "sha256:" + hex.EncodeToString(h[:])
+			if req.URL.Query().Get("digest") != digest {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			r.blobs[digest] = b
+			delete(r.sessions, id)
+			w.WriteHeader(http.StatusCreated)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestChunkedUploaderResume(t *testing.T) {
+	reg := &chunkRegistry{blobs: map[string][]byte{}, sessions: map[string][]byte{}}
+	s := httptest.NewServer(reg)
+	defer s.Close()
+	repo, err := name.NewRepository(strings.TrimPrefix(s.URL, "http://") + "/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	td, err := ioutil.TempDir("", "cosign-uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	blob := bytes.Repeat([]byte("0123456789"), 10)
+	l := &staticLayer{b: blob, mt: BlobMediaType}
+	u := &ChunkedUploader{Transport: http.DefaultTransport, ChunkSize: 30, Sessions: &Cache{Dir: td, TTL: time.Hour}}
+
+	// Let the first two chunks through, then fail, as if the connection dropped.
+	var sent int
+	u.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodPatch {
+			sent++
+			reg.mu.Lock()
+			reg.failPatch = sent > 2
+			reg.mu.Unlock()
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	if err := u.Upload(context.Background(), repo, l); err == nil {
+		t.Fatal("expected the interrupted upload to fail")
+	}
+	if reg.received != 60 {
+		t.Fatalf("registry received %d bytes before the failure, want 60", reg.received)
+	}
+
+	reg.failPatch = false
+	u.Transport = http.DefaultTransport
+	if err := u.Upload(context.Background(), repo, l); err != nil {
+		t.Fatal(err)
+	}
+	if reg.posts != 1 {
+		t.Errorf("%d upload sessions were started, want the first one resumed", reg.posts)
+	}
+	if reg.received != len(blob) {
+		t.Errorf("registry received %d bytes in total, want %d", reg.received, len(blob))
+	}
+	digest, _ := l.Digest()
+	if !bytes.Equal(reg.blobs[digest.String()], blob) {
+		t.Error("registry has the wrong blob")
+	}
+	if u.Sessions.get(uploadSessionKind, repo.Name()+"@"+digest.String(), &uploadSession{}) {
+		t.Error("finished session is still recorded")
+	}
+
+	// Blobs the registry has aren't uploaded again.
+	if err := u.Upload(context.Background(), repo, l); err != nil {
+		t.Fatal(err)
+	}
+	if reg.posts != 1 {
+		t.Error("existing blob was uploaded again")
+	}
+}
+
+func TestChunkedUploaderExpiredSession(t *testing.T) {
+	reg := &chunkRegistry{blobs: map[string][]byte{}, sessions: map[string][]byte{}}
+	s := httptest.NewServer(reg)
+	defer s.Close()
+	repo, err := name.NewRepository(strings.TrimPrefix(s.URL, "http://") + "/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	td, err := ioutil.TempDir("", "cosign-uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	l := &staticLayer{b: []byte("expired"), mt: BlobMediaType}
+	digest, _ := l.Digest()
+	u := &ChunkedUploader{Transport: http.DefaultTransport, Sessions: &Cache{Dir: td, TTL: time.Hour}}
+	// A session the registry has forgotten about is started over.
+	u.Sessions.put(uploadSessionKind, repo.Name()+"@"+digest.String(), uploadSession{Location: s.URL + "/v2/repo/blobs/uploads/gone"})
+	if err := u.Upload(context.Background(), repo, l); err != nil {
+		t.Fatal(err)
+	}
+	if string(reg.blobs[digest.String()]) != "expired" {
+		t.Error("blob wasn't uploaded")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,188 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+type repositoryKey struct{}
+
+// WithRepository returns a context under which signatures, attestations and countersignatures are
+// looked up in repo instead of next to the image, overriding $COSIGN_REPOSITORY.
+func WithRepository(ctx context.Context, repo string) context.Context {
+	return context.WithValue(ctx, repositoryKey{}, repo)
+}
+
+// signatureRepository returns the repository signatures are kept in under ctx, or "" if they
+// are kept next to the image.
+func signatureRepository(ctx context.Context) string {
+	if repo, ok := ctx.Value(repositoryKey{}).(string); ok {
+		return repo
+	}
+	return os.Getenv(repoEnv)
+}
+
+// RegistryOptions configure a RepositoryClient.
+type RegistryOptions struct {
+	// Auth, if set, authenticates every request. Otherwise credentials come from Keychain,
+	// or the docker config and credential helpers if that isn't set either.
+	Auth     authn.Authenticator
+	Keychain authn.Keychain
+	// Transport makes the requests, http.DefaultTransport if it isn't set.
+	Transport http.RoundTripper
+	// Retries is how many times requests failing with a connection error, 429 or 5xx are retried,
+	// with exponential backoff.
+	Retries int
+	// Repository, if set, is where signatures are stored and looked up instead of next to the
+	// image, like $COSIGN_REPOSITORY.
+	Repository string
+	// Mirrors, if set, are the mirrors of each registry to look images and signatures up in first,
+	// see WithRegistryMirrors. $COSIGN_REGISTRY_MIRRORS is used otherwise.
+	Mirrors map[string][]string
+}
+
+// RepositoryClient is a configured registry client. It can be shared across any number of
+// concurrent sign and verify calls, so their connections and credentials are reused.
+type RepositoryClient struct {
+	auth       authn.Authenticator
+	keychain   authn.Keychain
+	transport  http.RoundTripper
+	repository string
+	mirrors    map[string][]string
+}
+
+// NewRepositoryClient returns a client configured by o.
+func NewRepositoryClient(o RegistryOptions) *RepositoryClient {
+	c := &RepositoryClient{auth: o.Auth, keychain: o.Keychain, transport: o.Transport, repository: o.Repository, mirrors: o.Mirrors}
+	if c.keychain == nil {
+		c.keychain = authn.DefaultKeychain
+	}
+	if c.transport == nil {
+		c.transport = http.DefaultTransport
+	}
+	if o.Retries > 0 {
+		c.transport = &retryTransport{inner: c.transport, retries: o.Retries, backoff: time.Second}
+	}
+	if c.repository == "" {
+		c.repository = os.Getenv(repoEnv)
+	}
+	return c
+}
+
+// Options returns the registry options for calls made under ctx, for the helpers of this package
+// that take them, like Upload and CheckOpts.RegistryClientOpts.
+func (c *RepositoryClient) Options(ctx context.Context) []remote.Option {
+	opts := []remote.Option{remote.WithContext(ctx), remote.WithTransport(c.transport)}
+	// A keychain takes precedence over explicit auth, so only one of them is ever passed.
+	if c.auth != nil {
+		return append(opts, remote.WithAuth(c.auth))
+	}
+	return append(opts, remote.WithAuthFromKeychain(c.keychain))
+}
+
+// Context returns ctx with the repository signatures are kept in, and the registry mirrors, for
+// the helpers of this package that look them up.
+func (c *RepositoryClient) Context(ctx context.Context) context.Context {
+	if c.mirrors != nil {
+		ctx = WithRegistryMirrors(ctx, c.mirrors)
+	}
+	return WithRepository(ctx, c.repository)
+}
+
+// DestinationRef returns where signatures for the image at ref with descriptor img are stored.
+func (c *RepositoryClient) DestinationRef(ref name.Reference, img *remote.Descriptor) (name.Reference, error) {
+	return destinationRef(ref, img, c.repository)
+}
+
+// AttestationRef returns where attestations for the image at ref with descriptor img are stored.
+func (c *RepositoryClient) AttestationRef(ref name.Reference, img *remote.Descriptor) (name.Reference, error) {
+	return attestationRef(ref, img, c.repository)
+}
+
+// Verify is Verify, with the registry access of the client.
+func (c *RepositoryClient) Verify(ctx context.Context, ref name.Reference, co CheckOpts) ([]VerifiedSignature, error) {
+	co.RegistryClientOpts = c.Options(ctx)
+	return Verify(c.Context(ctx), ref, co)
+}
+
+// FetchSignatures is FetchSignatures, with the registry access of the client.
+func (c *RepositoryClient) FetchSignatures(ctx context.Context, ref name.Reference) ([]SignedPayload, *v1.Descriptor, error) {
+	return FetchSignatures(c.Context(ctx), ref, c.Options(ctx)...)
+}
+
+// FetchAttestations is FetchAttestations, with the registry access of the client.
+func (c *RepositoryClient) FetchAttestations(ctx context.Context, ref name.Reference) ([]Attestation, *v1.Descriptor, error) {
+	return FetchAttestations(c.Context(ctx), ref, c.Options(ctx)...)
+}
+
+// retryTransport retries requests that failed in a way that might succeed if tried again.
+type retryTransport struct {
+	inner   http.RoundTripper
+	retries int
+	backoff time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := t.backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := t.inner.RoundTrip(req)
+		if attempt >= t.retries || !retryable(resp, err) || req.Context().Err() != nil {
+			return resp, err
+		}
+		// Streamed bodies (like blob uploads) have been consumed and can't be sent again.
+		if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryable reports whether a request failed with a connection error or a transient status.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestRepositoryClientRepository(t *testing.T) {
+	os.Setenv(repoEnv, "gcr.io/env")
+	defer os.Unsetenv(repoEnv)
+
+	ref := name.MustParseReference("gcr.io/test/image")
+	img := &remote.Descriptor{Descriptor: v1.Descriptor{Digest: v1.Hash{Algorithm: "sha256", Hex: "digest"}}}
+	for _, tt := range []struct {
+		repo, want string
+	}{
+		{repo: "gcr.io/new", want: "gcr.io/new/image:sha256-digest.cosign"},
+		{want: "gcr.io/env/image:sha256-digest.cosign"},
+	} {
+		c := NewRepositoryClient(RegistryOptions{Repository: tt.repo})
+		got, err := c.DestinationRef(ref, img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name() != tt.want {
+			t.Errorf("DestinationRef() = %s, want %s", got.Name(), tt.want)
+		}
+		// The helpers the client calls look signatures up in the same place.
+		ctx := c.Context(context.Background())
+		if got, err := destinationRef(ref, img, signatureRepository(ctx)); err != nil || got.Name() != tt.want {
+			t.Errorf("destinationRef() under the client's context = %v, %v, want %s", got, err, tt.want)
+		}
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+	for _, tt := range []struct {
+		retries  int
+		want     int
+		attempts int
+	}{
+		{retries: 0, want: http.StatusServiceUnavailable, attempts: 1},
+		{retries: 1, want: http.StatusServiceUnavailable, attempts: 2},
+		{retries: 2, want: http.StatusOK, attempts: 3},
+	} {
+		attempts := 0
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			if attempts < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		c := &http.Client{Transport: &retryTransport{inner: http.DefaultTransport, retries: tt.retries, backoff: time.Millisecond}}
+		resp, err := c.Get(s.URL)
+		s.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want || attempts != tt.attempts {
+			t.Errorf("retries=%d: got %d after %d attempts, want %d after %d", tt.retries, resp.StatusCode, attempts, tt.want, tt.attempts)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// zstdSuffix is appended to the media type of payloads stored zstd compressed, following the
+// convention of OCI layers.
+const zstdSuffix = "+zstd"
+
+// maxPayloadSize bounds how large a compressed payload may get when decompressed, so a small
+// layer can't exhaust memory on verify.
+const maxPayloadSize = 256 << 20
+
+// compressedLayer returns a layer holding b zstd compressed, with media type mt+zstd.
+// The encoding is deterministic, so uploading the same payload twice is still deduplicated.
+func compressedLayer(b []byte, mt types.MediaType) (*staticLayer, error) {
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Close()
+	return &staticLayer{b: enc.EncodeAll(b, nil), mt: mt + zstdSuffix}, nil
+}
+
+// payloadLayer returns a layer holding b with media type mt, compressed if compress is set.
+func payloadLayer(b []byte, mt types.MediaType, compress bool) (*staticLayer, error) {
+	if compress {
+		return compressedLayer(b, mt)
+	}
+	return &staticLayer{b: b, mt: mt}, nil
+}
+
+// isMediaType reports whether got is mt, either as is or compressed.
+func isMediaType(got, mt types.MediaType) bool {
+	return got == mt || got == mt+zstdSuffix
+}
+
+// decompressPayload returns the contents of r, a layer with media type mt, decompressing it if it
+// was stored compressed.
+func decompressPayload(r io.Reader, mt types.MediaType) ([]byte, error) {
+	if !strings.HasSuffix(string(mt), zstdSuffix) {
+		return ioutil.ReadAll(r)
+	}
+	dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxPayloadSize))
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(dec, maxPayloadSize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "decompressing payload")
+	}
+	if n > maxPayloadSize {
+		return nil, errors.Errorf("decompressed payload is larger than %d bytes", maxPayloadSize)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPayloadLayer(t *testing.T) {
+	payload := []byte(`{"predicate":"` + strings.Repeat("sbom ", 1<<16) + `"}`)
+	for _, compress := range []bool{false, true} {
+		l, err := payloadLayer(payload, AttestationMediaType, compress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mt, err := l.MediaType()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isMediaType(mt, AttestationMediaType) {
+			t.Errorf("compress=%v: media type %s isn't an attestation", compress, mt)
+		}
+		if compress && (mt != AttestationMediaType+zstdSuffix || len(l.b) >= len(payload)) {
+			t.Errorf("payload wasn't compressed: %s, %d bytes", mt, len(l.b))
+		}
+		got, err := readLayer(l, mt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, payload) {
+			t.Errorf("compress=%v: payload changed on the way through the layer", compress)
+		}
+	}
+
+	// The same payload must compress to the same layer, or re-uploads aren't deduplicated.
+	a, err := compressedLayer(payload, simpleSigningMediaType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := compressedLayer(payload, simpleSigningMediaType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a.b, b.b) {
+		t.Error("compression isn't deterministic")
+	}
+}
+
+func TestReadLayerCorrupt(t *testing.T) {
+	l := &staticLayer{b: []byte("not zstd"), mt: AttestationMediaType + zstdSuffix}
+	if _, err := readLayer(l, l.mt); err == nil {
+		t.Error("expected an error reading a corrupt compressed layer")
+	}
+	// Uncompressed layers are returned as is, even when they look compressed.
+	l.mt = AttestationMediaType
+	if got, err := readLayer(l, l.mt); err != nil || string(got) != "not zstd" {
+		t.Errorf("readLayer() = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// ConfigEnv overrides the path of the config file, ~/.cosign/config.yaml by default.
+const ConfigEnv = "COSIGN_CONFIG"
+
+// Config holds defaults for settings that are otherwise taken from the environment. The
+// environment, and flags, take precedence over it.
+type Config struct {
+	RekorURL   string `json:"rekor-url,omitempty"`
+	FulcioURL  string `json:"fulcio-url,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	// Output is the default output format of verify, "json" or "text".
+	Output       string         `json:"output,omitempty"`
+	Experimental bool           `json:"experimental,omitempty"`
+	Registry     RegistryConfig `json:"registry,omitempty"`
+	// ReferenceEquivalents are the "<a>=<b>" equivalences verify -check-reference accepts, e.g. a mirror of docker.io.
+	ReferenceEquivalents []string `json:"reference-equivalents,omitempty"`
+}
+
+// RegistryConfig holds defaults for the registry flags. Secrets are deliberately left out,
+// they belong in the docker config or a credential helper.
+type RegistryConfig struct {
+	Username string `json:"username,omitempty"`
+	// Insecure lists the registries to allow plain HTTP and self-signed TLS for.
+	Insecure []string `json:"insecure,omitempty"`
+	// Mirrors lists the registry mirrors to look images and signatures up in first, see ParseRegistryMirrors.
+	Mirrors []string `json:"mirrors,omitempty"`
+}
+
+// ConfigPath returns $COSIGN_CONFIG, or ~/.cosign/config.yaml by default.
+func ConfigPath() (string, error) {
+	if p := os.Getenv(ConfigEnv); p != "" {
+		return p, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cosign", "config.yaml"), nil
+}
+
+// LoadConfig reads the config file at ConfigPath. A missing default file is an empty config,
+// but a missing $COSIGN_CONFIG is an error, since it was asked for explicitly.
+func LoadConfig() (*Config, error) {
+	p, err := ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(filepath.Clean(p))
+	if err != nil {
+		if os.IsNotExist(err) && os.Getenv(ConfigEnv) == "" {
+			return &Config{}, nil
+		}
+		return nil, err
+	}
+	c := &Config{}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, errors.Wrapf(err, "parsing config file %s", p)
+	}
+	return c, nil
+}
+
+// Env returns the environment variables the settings in the config stand for.
+func (c *Config) Env() map[string]string {
+	env := map[string]string{
+		ServerEnv:                      c.RekorURL,
+		"FULCIO_ADDRESS":               c.FulcioURL,
+		repoEnv:                        c.Repository,
+		"COSIGN_OUTPUT":                c.Output,
+		"COSIGN_REGISTRY_USERNAME":     c.Registry.Username,
+		"COSIGN_INSECURE_REGISTRIES":   strings.Join(c.Registry.Insecure, ","),
+		"COSIGN_REFERENCE_EQUIVALENTS": strings.Join(c.ReferenceEquivalents, ","),
+		MirrorsEnv:                     strings.Join(c.Registry.Mirrors, ","),
+	}
+	if c.Experimental {
+		env[ExperimentalEnv] = "1"
+	}
+	return env
+}
+
+// Apply sets the environment variables for the settings in the config, unless they are already set.
+func (c *Config) Apply() error {
+	for k, v := range c.Env() {
+		if _, ok := os.LookupEnv(k); ok || v == "" {
+			continue
+		}
+		if err := os.Setenv(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	td, err := ioutil.TempDir("", "cosign-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	p := filepath.Join(td, "config.yaml")
+	if err := ioutil.WriteFile(p, []byte(`
+rekor-url: https://rekor.example.com
+fulcio-url: https://fulcio.example.com
+registry:
+  insecure:
+  - localhost:5000
+  - registry.local
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	vars := []string{ConfigEnv, ServerEnv, "FULCIO_ADDRESS", "COSIGN_INSECURE_REGISTRIES"}
+	for _, k := range vars {
+		if v, ok := os.LookupEnv(k); ok {
+			defer os.Setenv(k, v)
+		} else {
+			defer os.Unsetenv(k)
+		}
+		os.Unsetenv(k)
+	}
+	os.Setenv(ConfigEnv, p)
+	// The environment takes precedence over the config file.
+	os.Setenv("FULCIO_ADDRESS", "https://fulcio.internal")
+
+	c, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{
+		ServerEnv:                    "https://rekor.example.com",
+		"FULCIO_ADDRESS":             "https://fulcio.internal",
+		"COSIGN_INSECURE_REGISTRIES": "localhost:5000,registry.local",
+	} {
+		if got := os.Getenv(k); got != want {
+			t.Errorf("$%s = %q, want %q", k, got, want)
+		}
+	}
+
+	os.Setenv(ConfigEnv, filepath.Join(td, "missing.yaml"))
+	if _, err := LoadConfig(); err == nil {
+		t.Error("expected error loading a missing $COSIGN_CONFIG")
+	}
+}
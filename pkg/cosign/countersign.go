@@ -0,0 +1,148 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+)
+
+// CountersignatureType is the critical type of countersignature payloads.
+const CountersignatureType = "cosign countersignature"
+
+// CountersignaturePayload endorses one signature of an image. Like a simple signing payload it
+// names the image, so it is checked the same way, and it also identifies the signature endorsed.
+type CountersignaturePayload struct {
+	Critical CountersignatureCritical
+	Optional map[string]string
+}
+
+type CountersignatureCritical struct {
+	Image     Image
+	Type      string
+	Signature CountersignedSignature
+}
+
+// CountersignedSignature identifies a signature by its base64 encoded signature and the digest
+// of the payload it signs.
+type CountersignedSignature struct {
+	PayloadDigest string `json:"payload-digest"`
+	Signature     string `json:"signature"`
+}
+
+// CountersignedSignatureOf identifies sp in a countersignature payload.
+func CountersignedSignatureOf(sp SignedPayload) CountersignedSignature {
+	return CountersignedSignature{
+		PayloadDigest: fmt.Sprintf("sha256:%x", sha256.Sum256(sp.Payload)),
+		Signature:     sp.Base64Signature,
+	}
+}
+
+// NewCountersignaturePayload returns the payload endorsing sp, a signature of the image with desc.
+func NewCountersignaturePayload(desc v1.Descriptor, sp SignedPayload, annotations map[string]string) ([]byte, error) {
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	return json.Marshal(CountersignaturePayload{
+		Critical: CountersignatureCritical{
+			Image:     Image{DockerManifestDigest: desc.Digest.String()},
+			Type:      CountersignatureType,
+			Signature: CountersignedSignatureOf(sp),
+		},
+		Optional: annotations,
+	})
+}
+
+// CountersignatureTag is the tag countersignatures for the image with desc are stored under,
+// next to its signatures.
+func CountersignatureTag(desc v1.Descriptor) string {
+	return ArtifactTag(desc.Digest, CountersignatureKind)
+}
+
+// CountersignatureRef returns where countersignatures for ref are stored. Like signatures, they
+// can be kept in another repository by setting COSIGN_REPOSITORY.
+func CountersignatureRef(ref name.Reference, img *remote.Descriptor) (name.Reference, error) {
+	return countersignatureRef(ref, img, os.Getenv(repoEnv))
+}
+
+func countersignatureRef(ref name.Reference, img *remote.Descriptor, repo string) (name.Reference, error) {
+	dst, err := destinationRef(ref, img, repo)
+	if err != nil {
+		return nil, err
+	}
+	return dst.Context().Tag(CountersignatureTag(img.Descriptor)), nil
+}
+
+// FetchCountersignatures returns the countersignatures stored for ref. If there are none, the
+// error wraps ErrNoSignatures.
+func FetchCountersignatures(ctx context.Context, ref name.Reference, opts ...remote.Option) ([]SignedPayload, error) {
+	opts = registryOpts(ctx, opts)
+	targetDesc, err := headDescriptor(ref, opts)
+	if err != nil {
+		return nil, err
+	}
+	dstRef, err := countersignatureRef(ref, targetDesc, signatureRepository(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return fetchSignedPayloads(ctx, dstRef, opts)
+}
+
+// verifyCountersigned keeps the signatures of ref that have a countersignature passing the checks
+// in co.Countersigners, and records the countersignatures on them.
+func verifyCountersigned(ctx context.Context, ref name.Reference, desc *v1.Descriptor, verified []VerifiedSignature, co CheckOpts) ([]VerifiedSignature, error) {
+	countersigs, err := FetchCountersignatures(ctx, ref, co.RegistryClientOpts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching countersignatures")
+	}
+	cco := *co.Countersigners
+	cco.ClaimVerification = true
+	cco.Threshold = 0
+	cco.Cache = co.Cache
+	cco.RekorURL = co.RekorURL
+	cco.RegistryClientOpts = co.RegistryClientOpts
+	endorsements, err := VerifyPayloads(ctx, desc, countersigs, cco)
+	if err != nil {
+		return nil, errors.Wrap(err, "verifying countersignatures")
+	}
+
+	bySignature := map[CountersignedSignature][]VerifiedSignature{}
+	for _, e := range endorsements {
+		p := CountersignaturePayload{}
+		if err := json.Unmarshal(e.Payload, &p); err != nil || p.Critical.Type != CountersignatureType {
+			continue
+		}
+		bySignature[p.Critical.Signature] = append(bySignature[p.Critical.Signature], e)
+	}
+	out := []VerifiedSignature{}
+	for _, vs := range verified {
+		if cs := bySignature[CountersignedSignatureOf(vs.SignedPayload)]; len(cs) > 0 {
+			vs.Countersignatures = cs
+			out = append(out, vs)
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no signatures are countersigned by a trusted countersigner")
+	}
+	return out, nil
+}
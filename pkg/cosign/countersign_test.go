@@ -0,0 +1,63 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"encoding/json"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestCountersignaturePayload(t *testing.T) {
+	digest, err := v1.NewHash("sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824")
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := v1.Descriptor{Digest: digest}
+	if got, want := CountersignatureTag(desc), "sha256-2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824.countersign"; got != want {
+		t.Errorf("CountersignatureTag() = %s, want %s", got, want)
+	}
+
+	sp := SignedPayload{Payload: []byte("hello"), Base64Signature: "c2lnbmF0dXJl"}
+	b, err := NewCountersignaturePayload(desc, sp, map[string]string{"approved-by": "security"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Countersignatures pass the same claim checks as signatures.
+	ss := &SimpleSigning{}
+	if err := json.Unmarshal(b, ss); err != nil {
+		t.Fatal(err)
+	}
+	if err := sp.VerifyClaims(&desc, ss); err != nil {
+		t.Errorf("VerifyClaims() = %v", err)
+	}
+	if ss.Optional["approved-by"] != "security" {
+		t.Errorf("annotations = %v", ss.Optional)
+	}
+
+	p := CountersignaturePayload{}
+	if err := json.Unmarshal(b, &p); err != nil {
+		t.Fatal(err)
+	}
+	want := CountersignedSignature{
+		PayloadDigest: "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		Signature:     "c2lnbmF0dXJl",
+	}
+	if p.Critical.Type != CountersignatureType || p.Critical.Signature != want {
+		t.Errorf("countersignature payload = %+v, want it to endorse %+v", p, want)
+	}
+}
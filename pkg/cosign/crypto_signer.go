@@ -0,0 +1,102 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// CryptoSigner adapts any crypto.Signer, such as a key held in a TPM or HSM, to the cosign signing interfaces.
+// ECDSA and RSA keys sign SHA-256 digests (RSA with PKCS #1 v1.5), while Ed25519 keys sign the payload itself
+// and so can't be used as a DigestSigner.
+type CryptoSigner struct {
+	signer crypto.Signer
+}
+
+// WithCryptoSigner wraps signer, returning an error if its key type isn't supported.
+func WithCryptoSigner(signer crypto.Signer) (*CryptoSigner, error) {
+	if err := CheckFIPSKey(signer.Public()); err != nil {
+		return nil, err
+	}
+	switch pub := signer.Public().(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return &CryptoSigner{signer: signer}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %T", pub)
+	}
+}
+
+func (s *CryptoSigner) Sign(ctx context.Context, payload []byte) (signature []byte, err error) {
+	if _, ok := s.signer.Public().(ed25519.PublicKey); ok {
+		return s.signer.Sign(rand.Reader, payload, crypto.Hash(0))
+	}
+	return s.SignDigest(ctx, hashBytes(HashFunc(s.signer.Public()), payload))
+}
+
+func (s *CryptoSigner) SignDigest(_ context.Context, digest []byte) (signature []byte, err error) {
+	if _, ok := s.signer.Public().(ed25519.PublicKey); ok {
+		return nil, errors.New("ed25519 keys can't sign a precomputed digest")
+	}
+	return s.signer.Sign(rand.Reader, digest, HashFunc(s.signer.Public()))
+}
+
+func (s *CryptoSigner) Verify(_ context.Context, payload, signature []byte) error {
+	if pub, ok := s.signer.Public().(ed25519.PublicKey); ok {
+		if !ed25519.Verify(pub, payload, signature) {
+			return errors.New("unable to verify signature")
+		}
+		return nil
+	}
+	return verifyDigest(s.signer.Public(), hashBytes(HashFunc(s.signer.Public()), payload), signature)
+}
+
+func (s *CryptoSigner) VerifyDigest(_ context.Context, digest, signature []byte) error {
+	return verifyDigest(s.signer.Public(), digest, signature)
+}
+
+func (s *CryptoSigner) PublicKey(_ context.Context) (crypto.PublicKey, error) {
+	return s.signer.Public(), nil
+}
+
+// KeyID returns the fingerprint of the key.
+func (s *CryptoSigner) KeyID(_ context.Context) (string, error) {
+	return KeyFingerprint(s.signer.Public())
+}
+
+func (s *CryptoSigner) Algorithm() string {
+	return KeyAlgorithm(s.signer.Public())
+}
+
+func verifyDigest(pub crypto.PublicKey, digest, signature []byte) error {
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest, signature) {
+			return errors.New("unable to verify signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, signature)
+	default:
+		return fmt.Errorf("unsupported key type for digest verification: %T", pub)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestCryptoSigner(t *testing.T) {
+	ctx := context.Background()
+	ecKey, err := GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte("payload")
+	algorithms := map[string]string{"ecdsa": AlgorithmECDSAP256SHA256, "rsa": AlgorithmRSASHA256, "ed25519": AlgorithmEd25519}
+	for name, key := range map[string]crypto.Signer{"ecdsa": ecKey, "rsa": rsaKey, "ed25519": edKey} {
+		t.Run(name, func(t *testing.T) {
+			s, err := WithCryptoSigner(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sv SignerVerifier = s
+			if got := sv.Algorithm(); got != algorithms[name] {
+				t.Errorf("Algorithm() = %s, want %s", got, algorithms[name])
+			}
+			keyID, err := sv.KeyID(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want, _ := KeyFingerprint(key.Public()); keyID != want {
+				t.Errorf("KeyID() = %s, want %s", keyID, want)
+			}
+			sig, err := s.Sign(ctx, payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Verify(ctx, payload, sig); err != nil {
+				t.Errorf("Verify() = %v", err)
+			}
+			if err := s.Verify(ctx, []byte("other"), sig); err == nil {
+				t.Error("expected error for modified payload")
+			}
+			if name == "ed25519" {
+				if _, _, err := SignBlob(ctx, s, bytes.NewReader(payload)); err == nil {
+					t.Error("expected error signing a digest with ed25519")
+				}
+				return
+			}
+			// Digest and payload signatures must be interchangeable.
+			sig, _, err = SignBlob(ctx, s, bytes.NewReader(payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Verify(ctx, payload, sig); err != nil {
+				t.Errorf("Verify() of digest signature = %v", err)
+			}
+		})
+	}
+}
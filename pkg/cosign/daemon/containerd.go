@@ -0,0 +1,66 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+)
+
+// containerdName returns ref's name in containerd, which calls Docker Hub docker.io.
+func containerdName(ref name.Reference) string {
+	n := ref.Name()
+	if ref.Context().RegistryStr() == name.DefaultRegistry {
+		n = "docker.io" + strings.TrimPrefix(n, name.DefaultRegistry)
+	}
+	return n
+}
+
+// containerdDescriptor returns the descriptor of the image ref in containerd, listed with ctr, which
+// takes the containerd address and namespace from $CONTAINERD_ADDRESS and $CONTAINERD_NAMESPACE.
+// Containerd records the digest images are pushed with.
+func containerdDescriptor(ctx context.Context, ref name.Reference) (*v1.Descriptor, error) {
+	n := containerdName(ref)
+	cmd := exec.CommandContext(ctx, "ctr", "images", "ls", "name=="+n)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "ctr images ls: %s", strings.TrimSpace(stderr.String()))
+	}
+	// The columns are REF, TYPE, DIGEST, SIZE, PLATFORMS and LABELS, the size has a space in it.
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 3 || fields[0] != n {
+			continue
+		}
+		h, err := v1.NewHash(fields[2])
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing digest of %s in containerd", n)
+		}
+		return &v1.Descriptor{MediaType: types.MediaType(fields[1]), Digest: h}, nil
+	}
+	return nil, fmt.Errorf("image %s not found in containerd", n)
+}
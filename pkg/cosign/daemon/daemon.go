@@ -0,0 +1,76 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package daemon finds images in the docker daemon or containerd image store, so they can be
+// signed and verified at build time, before they are pushed.
+package daemon
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+const (
+	// DockerScheme prefixes references to images in the docker daemon, e.g. docker://gcr.io/acme/app:dev.
+	DockerScheme = "docker://"
+	// ContainerdScheme prefixes references to images in containerd, e.g. containerd://gcr.io/acme/app:dev.
+	ContainerdScheme = "containerd://"
+)
+
+// Image is an image in a local image store.
+type Image struct {
+	// Ref is the image's name in the registry, where its signatures are stored.
+	Ref name.Reference
+	// Descriptor has the digest of the image's manifest in the registry, once it is pushed.
+	Descriptor v1.Descriptor
+}
+
+// IsLocal reports whether ref refers to an image in a local image store.
+func IsLocal(ref string) bool {
+	return strings.HasPrefix(ref, DockerScheme) || strings.HasPrefix(ref, ContainerdScheme)
+}
+
+// Resolve finds the image ref refers to in its local image store. opts apply to parsing the
+// image's name in the registry.
+func Resolve(ctx context.Context, ref string, opts ...name.Option) (*Image, error) {
+	store, s := ContainerdScheme, strings.TrimPrefix(ref, ContainerdScheme)
+	if strings.HasPrefix(ref, DockerScheme) {
+		store, s = DockerScheme, strings.TrimPrefix(ref, DockerScheme)
+	}
+	r, err := name.ParseReference(s, opts...)
+	if err != nil {
+		return nil, err
+	}
+	// A digest identifies the image whichever store it's in.
+	if d, ok := r.(name.Digest); ok {
+		h, err := v1.NewHash(d.DigestStr())
+		if err != nil {
+			return nil, err
+		}
+		return &Image{Ref: r, Descriptor: v1.Descriptor{Digest: h}}, nil
+	}
+	var desc *v1.Descriptor
+	if store == DockerScheme {
+		desc, err = dockerDescriptor(ctx, r)
+	} else {
+		desc, err = containerdDescriptor(ctx, r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Image{Ref: r, Descriptor: *desc}, nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+const pushedDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000001"
+
+func TestResolveDocker(t *testing.T) {
+	img, err := random.Image(100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag("gcr.io/acme/built:dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/images/gcr.io/acme/app:dev/json":
+			json.NewEncoder(w).Encode(dockerInspect{ID: "sha256:app", RepoDigests: []string{"gcr.io/acme/other@" + pushedDigest, "gcr.io/acme/app@" + pushedDigest}})
+		case "/images/gcr.io/acme/built:dev/json":
+			json.NewEncoder(w).Encode(dockerInspect{ID: "sha256:built"})
+		case "/images/sha256:built/get":
+			tarball.Write(tag, img, w)
+		default:
+			http.Error(w, "No such image", http.StatusNotFound)
+		}
+	}))
+	defer daemon.Close()
+	os.Setenv(DockerHostEnv, "tcp://"+strings.TrimPrefix(daemon.URL, "http://"))
+	defer os.Unsetenv(DockerHostEnv)
+
+	// Pushed, the daemon knows the digest.
+	got, err := Resolve(context.Background(), "docker://gcr.io/acme/app:dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Ref.Name() != "gcr.io/acme/app:dev" || got.Descriptor.Digest.String() != pushedDigest {
+		t.Errorf("Resolve() = %s %s, want the pushed digest", got.Ref, got.Descriptor.Digest)
+	}
+
+	// Never pushed, the digest is computed.
+	got, err = Resolve(context.Background(), "docker://gcr.io/acme/built:dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Descriptor.Digest != want {
+		t.Errorf("Resolve() digest = %s, want %s", got.Descriptor.Digest, want)
+	}
+
+	if _, err := Resolve(context.Background(), "docker://gcr.io/acme/missing:dev"); err == nil {
+		t.Error("Resolve() of a missing image, expected error")
+	}
+}
+
+func TestResolveContainerd(t *testing.T) {
+	dir := t.TempDir()
+	ctr := "#!/bin/sh\n" +
+		"echo 'REF TYPE DIGEST SIZE PLATFORMS LABELS'\n" +
+		"echo 'docker.io/library/app:dev application/vnd.oci.image.index.v1+json " + pushedDigest + " 2.5 MiB linux/amd64 -'\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "ctr"), []byte(ctr), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	defer os.Setenv("PATH", path)
+
+	got, err := Resolve(context.Background(), "containerd://app:dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Ref.Name() != "index.docker.io/library/app:dev" || got.Descriptor.Digest.String() != pushedDigest {
+		t.Errorf("Resolve() = %s %s, want %s", got.Ref, got.Descriptor.Digest, pushedDigest)
+	}
+	if got.Descriptor.MediaType != "application/vnd.oci.image.index.v1+json" {
+		t.Errorf("Resolve() media type = %s", got.Descriptor.MediaType)
+	}
+	if _, err := Resolve(context.Background(), "containerd://app:other"); err == nil {
+		t.Error("Resolve() of a missing image, expected error")
+	}
+}
+
+func TestIsLocal(t *testing.T) {
+	for ref, want := range map[string]bool{
+		"docker://app:dev":     true,
+		"containerd://app:dev": true,
+		"gcr.io/acme/app:dev":  false,
+	} {
+		if got := IsLocal(ref); got != want {
+			t.Errorf("IsLocal(%q) = %v, want %v", ref, got, want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// DockerHostEnv is the address of the docker daemon, as for the docker CLI: unix:///var/run/docker.sock by default.
+const DockerHostEnv = "DOCKER_HOST"
+
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+// dockerInspect is what of the docker daemon's image inspection is used.
+type dockerInspect struct {
+	ID          string   `json:"Id"`
+	RepoDigests []string `json:"RepoDigests"`
+}
+
+// dockerClient returns a client for the docker daemon at $DOCKER_HOST, and the base URL of its API.
+func dockerClient() (*http.Client, string, error) {
+	host := os.Getenv(DockerHostEnv)
+	if host == "" {
+		host = defaultDockerHost
+	}
+	switch {
+	case strings.HasPrefix(host, "unix://"):
+		socket := strings.TrimPrefix(host, "unix://")
+		return &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		}}, "http://docker", nil
+	case strings.HasPrefix(host, "tcp://"):
+		if os.Getenv("DOCKER_TLS_VERIFY") != "" {
+			return nil, "", errors.New("docker daemons that require TLS aren't supported, use a unix socket")
+		}
+		return http.DefaultClient, "http://" + strings.TrimPrefix(host, "tcp://"), nil
+	}
+	return nil, "", fmt.Errorf("unsupported $%s %q", DockerHostEnv, host)
+}
+
+// dockerGet calls the docker daemon's API at path.
+func dockerGet(ctx context.Context, path string) (*http.Response, error) {
+	client, base, err := dockerClient()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "contacting the docker daemon")
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("docker daemon: %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	return resp, nil
+}
+
+// dockerDescriptor returns the descriptor of the image ref in the docker daemon. The daemon only
+// knows the digest of images it pushed or pulled. For the others, the digest is computed from the
+// image exported by the daemon, and is the one go-containerregistry based tools like crane or ko
+// push it with, "docker push" may compress its layers differently.
+func dockerDescriptor(ctx context.Context, ref name.Reference) (*v1.Descriptor, error) {
+	resp, err := dockerGet(ctx, "/images/"+ref.Name()+"/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	inspect := dockerInspect{}
+	if err := json.NewDecoder(resp.Body).Decode(&inspect); err != nil {
+		return nil, errors.Wrap(err, "parsing docker image inspection")
+	}
+	for _, rd := range inspect.RepoDigests {
+		d, err := name.NewDigest(rd)
+		if err != nil || d.Context().Name() != ref.Context().Name() {
+			continue
+		}
+		h, err := v1.NewHash(d.DigestStr())
+		if err != nil {
+			return nil, err
+		}
+		return &v1.Descriptor{Digest: h}, nil
+	}
+	log.Warnf("%s hasn't been pushed from this docker daemon, using the digest go-containerregistry tools like crane push it with", ref)
+	return exportedDescriptor(ctx, inspect.ID)
+}
+
+// exportedDescriptor returns the descriptor of the image with id, exported from the docker daemon.
+func exportedDescriptor(ctx context.Context, id string) (*v1.Descriptor, error) {
+	resp, err := dockerGet(ctx, "/images/"+id+"/get")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// The export is read several times, it's kept in a temporary file.
+	f, err := ioutil.TempFile("", "cosign-docker-image-*.tar")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return nil, errors.Wrap(err, "exporting image from the docker daemon")
+	}
+	img, err := tarball.ImageFromPath(f.Name(), nil)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, err
+	}
+	mt, err := img.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	size, err := img.Size()
+	if err != nil {
+		return nil, err
+	}
+	return &v1.Descriptor{MediaType: mt, Size: size, Digest: digest}, nil
+}
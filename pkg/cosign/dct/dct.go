@@ -0,0 +1,341 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dct reads the Docker Content Trust (Notary v1) trust data of a repository: the TUF
+// targets metadata that maps its signed tags to digests.
+package dct
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// DefaultServer is Docker Hub's notary server.
+const DefaultServer = "https://notary.docker.io"
+
+// Target is a tag signed in the trust data.
+type Target struct {
+	Tag    string  `json:"tag"`
+	Digest v1.Hash `json:"digest"`
+	Size   int64   `json:"size"`
+	// Role is the TUF role that signed the tag, "targets" or a delegation like "targets/releases".
+	Role string `json:"role"`
+}
+
+type signedFile struct {
+	Signed     json.RawMessage `json:"signed"`
+	Signatures []struct {
+		KeyID  string `json:"keyid"`
+		Method string `json:"method"`
+		Sig    string `json:"sig"`
+	} `json:"signatures"`
+}
+
+type tufKey struct {
+	KeyType string `json:"keytype"`
+	KeyVal  struct {
+		Public string `json:"public"`
+	} `json:"keyval"`
+}
+
+type tufRole struct {
+	Name      string   `json:"name"`
+	KeyIDs    []string `json:"keyids"`
+	Threshold int      `json:"threshold"`
+}
+
+type rootMeta struct {
+	Expires time.Time          `json:"expires"`
+	Keys    map[string]tufKey  `json:"keys"`
+	Roles   map[string]tufRole `json:"roles"`
+}
+
+type targetsMeta struct {
+	Expires time.Time `json:"expires"`
+	Targets map[string]struct {
+		Hashes map[string]string `json:"hashes"`
+		Length int64             `json:"length"`
+	} `json:"targets"`
+	Delegations struct {
+		Keys  map[string]tufKey `json:"keys"`
+		Roles []tufRole         `json:"roles"`
+	} `json:"delegations"`
+}
+
+// Client fetches trust data from a notary server.
+type Client struct {
+	// Server is the base URL of the notary server, DefaultServer if empty.
+	Server string
+	// Transport authenticates to the server, http.DefaultTransport if nil.
+	Transport http.RoundTripper
+}
+
+// GUN returns the globally unique name notary knows a repository by, like docker.io/library/alpine.
+func GUN(registry, repository string) string {
+	if registry == "index.docker.io" {
+		registry = "docker.io"
+	}
+	return registry + "/" + repository
+}
+
+// Targets returns the tags signed in the trust data of gun, by the targets role or the delegations
+// it lists, sorted by tag and role. Each file must be signed by the threshold of keys its role has
+// in the metadata that delegates to it. The root of trust is the root metadata on the server, as
+// the docker CLI would trust it on first use; its own signatures are checked as well.
+func (c *Client) Targets(ctx context.Context, gun string) ([]Target, error) {
+	sf, err := c.get(ctx, gun, "root")
+	if err != nil {
+		return nil, err
+	}
+	root := rootMeta{}
+	if err := json.Unmarshal(sf.Signed, &root); err != nil {
+		return nil, errors.Wrap(err, "parsing root metadata")
+	}
+	if err := verifyThreshold(sf, keyRole{root.Keys, root.Roles["root"]}); err != nil {
+		return nil, errors.Wrap(err, "verifying root metadata")
+	}
+	targets := targetsMeta{}
+	if err := c.fetch(ctx, gun, "targets", keyRole{root.Keys, root.Roles["targets"]}, &targets); err != nil {
+		return nil, err
+	}
+	warnExpired("targets", targets.Expires)
+	out, err := targetsOf("targets", targets)
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range targets.Delegations.Roles {
+		delegated := targetsMeta{}
+		if err := c.fetch(ctx, gun, role.Name, keyRole{targets.Delegations.Keys, role}, &delegated); err != nil {
+			var nf *notFoundError
+			if errors.As(err, &nf) {
+				// Delegations nobody has signed with yet have no metadata.
+				continue
+			}
+			return nil, err
+		}
+		warnExpired(role.Name, delegated.Expires)
+		ts, err := targetsOf(role.Name, delegated)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ts...)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Tag != out[j].Tag {
+			return out[i].Tag < out[j].Tag
+		}
+		return out[i].Role < out[j].Role
+	})
+	return out, nil
+}
+
+func warnExpired(role string, expires time.Time) {
+	if !expires.IsZero() && time.Now().After(expires) {
+		// Expired trust data is still a record of what was signed, which is what migrating keeps.
+		log.Warnf("the %s metadata expired at %s", role, expires.Format(time.RFC3339))
+	}
+}
+
+func targetsOf(role string, m targetsMeta) ([]Target, error) {
+	out := []Target{}
+	for tag, t := range m.Targets {
+		sum, err := base64.StdEncoding.DecodeString(t.Hashes["sha256"])
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("%s: tag %s has no valid sha256 hash", role, tag)
+		}
+		out = append(out, Target{
+			Tag:    tag,
+			Digest: v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(sum)},
+			Size:   t.Length,
+			Role:   role,
+		})
+	}
+	return out, nil
+}
+
+// keyRole is the keys a role's metadata must be signed with.
+type keyRole struct {
+	keys map[string]tufKey
+	role tufRole
+}
+
+type notFoundError struct {
+	url string
+}
+
+func (e *notFoundError) Error() string {
+	return fmt.Sprintf("%s not found", e.url)
+}
+
+// fetch gets the metadata of the role, checks that it is signed by the threshold of kr,
+// and decodes its signed part into v.
+func (c *Client) fetch(ctx context.Context, gun, role string, kr keyRole, v interface{}) error {
+	sf, err := c.get(ctx, gun, role)
+	if err != nil {
+		return err
+	}
+	if err := verifyThreshold(sf, kr); err != nil {
+		return errors.Wrapf(err, "verifying %s metadata", role)
+	}
+	return errors.Wrapf(json.Unmarshal(sf.Signed, v), "parsing %s metadata", role)
+}
+
+func (c *Client) get(ctx context.Context, gun, role string) (signedFile, error) {
+	sf := signedFile{}
+	server, rt := c.Server, c.Transport
+	if server == "" {
+		server = DefaultServer
+	}
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	u := fmt.Sprintf("%s/v2/%s/_trust/tuf/%s.json", strings.TrimSuffix(server, "/"), gun, role)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return sf, err
+	}
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		return sf, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return sf, &notFoundError{url: u}
+	default:
+		return sf, fmt.Errorf("fetching %s: %s", u, resp.Status)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return sf, err
+	}
+	return sf, errors.Wrapf(json.Unmarshal(b, &sf), "parsing %s metadata", role)
+}
+
+// verifyThreshold checks that the signed part of sf is signed by at least the threshold of the role's keys.
+func verifyThreshold(sf signedFile, kr keyRole) error {
+	if kr.role.Threshold < 1 {
+		return errors.New("role has no signing threshold")
+	}
+	canonical, err := cosign.CanonicalJSON(sf.Signed)
+	if err != nil {
+		return err
+	}
+	allowed := map[string]bool{}
+	for _, id := range kr.role.KeyIDs {
+		allowed[id] = true
+	}
+	valid := map[string]bool{}
+	for _, s := range sf.Signatures {
+		key, ok := kr.keys[s.KeyID]
+		if !allowed[s.KeyID] || !ok {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			continue
+		}
+		pub, err := publicKey(key)
+		if err != nil {
+			return errors.Wrapf(err, "key %s", s.KeyID)
+		}
+		if verifySignature(pub, s.Method, canonical, sig) == nil {
+			valid[s.KeyID] = true
+		}
+	}
+	if len(valid) < kr.role.Threshold {
+		return fmt.Errorf("%d valid signature(s), need %d", len(valid), kr.role.Threshold)
+	}
+	return nil
+}
+
+// publicKey decodes a TUF key the way notary writes them: certificates for the x509 key
+// types, and DER public keys otherwise.
+func publicKey(k tufKey) (crypto.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(k.KeyVal.Public)
+	if err != nil {
+		return nil, err
+	}
+	switch k.KeyType {
+	case "ecdsa-x509", "rsa-x509":
+		p, _ := pem.Decode(b)
+		if p == nil {
+			return nil, errors.New("no certificate")
+		}
+		cert, err := x509.ParseCertificate(p.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	case "ecdsa", "rsa":
+		return x509.ParsePKIXPublicKey(b)
+	case "ed25519":
+		if len(b) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key")
+		}
+		return ed25519.PublicKey(b), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+}
+
+func verifySignature(pub crypto.PublicKey, method string, msg, sig []byte) error {
+	digest := sha256.Sum256(msg)
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		// notary's ECDSA signatures are r and s concatenated, not ASN.1.
+		sz := (pub.Curve.Params().BitSize + 7) / 8
+		if method != "ecdsa" || len(sig) != 2*sz {
+			return errors.New("invalid ECDSA signature")
+		}
+		r, s := new(big.Int).SetBytes(sig[:sz]), new(big.Int).SetBytes(sig[sz:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		if method != "rsapss" {
+			return fmt.Errorf("unsupported RSA signature method %q", method)
+		}
+		return rsa.VerifyPSS(pub, crypto.SHA256, digest[:], sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	case ed25519.PublicKey:
+		if method != "eddsa" || !ed25519.Verify(pub, msg, sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported key type %T", pub)
+}
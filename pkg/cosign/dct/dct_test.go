@@ -0,0 +1,162 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dct
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+// tufSigner makes notary style TUF keys and signatures for tests.
+type tufSigner struct {
+	t    *testing.T
+	id   string
+	priv *ecdsa.PrivateKey
+}
+
+func newTUFSigner(t *testing.T, id string) *tufSigner {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tufSigner{t: t, id: id, priv: priv}
+}
+
+func (s *tufSigner) key() map[string]interface{} {
+	der, err := x509.MarshalPKIXPublicKey(&s.priv.PublicKey)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return map[string]interface{}{"keytype": "ecdsa", "keyval": map[string]interface{}{"public": base64.StdEncoding.EncodeToString(der), "private": nil}}
+}
+
+// sign returns the metadata file with signed signed by the signers.
+func sign(t *testing.T, signed interface{}, signers ...*tufSigner) []byte {
+	b, err := json.Marshal(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical, err := cosign.CanonicalJSON(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(canonical)
+	sigs := []map[string]string{}
+	for _, s := range signers {
+		r, ss, err := ecdsa.Sign(rand.Reader, s.priv, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw := make([]byte, 64)
+		r.FillBytes(raw[:32])
+		ss.FillBytes(raw[32:])
+		sigs = append(sigs, map[string]string{"keyid": s.id, "method": "ecdsa", "sig": base64.StdEncoding.EncodeToString(raw)})
+	}
+	out, err := json.Marshal(map[string]interface{}{"signed": json.RawMessage(b), "signatures": sigs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func targetsJSON(hashes map[string]string) map[string]interface{} {
+	targets := map[string]interface{}{}
+	for tag, h := range hashes {
+		sum := sha256.Sum256([]byte(h))
+		targets[tag] = map[string]interface{}{"hashes": map[string]string{"sha256": base64.StdEncoding.EncodeToString(sum[:])}, "length": 100}
+	}
+	return targets
+}
+
+func TestTargets(t *testing.T) {
+	rootKey, targetsKey, releasesKey, other := newTUFSigner(t, "root"), newTUFSigner(t, "targets"), newTUFSigner(t, "releases"), newTUFSigner(t, "other")
+	expires := time.Now().Add(time.Hour)
+	files := map[string][]byte{
+		"root": sign(t, map[string]interface{}{
+			"_type":   "Root",
+			"expires": expires,
+			"keys":    map[string]interface{}{"root": rootKey.key(), "targets": targetsKey.key()},
+			"roles": map[string]interface{}{
+				"root":    map[string]interface{}{"keyids": []string{"root"}, "threshold": 1},
+				"targets": map[string]interface{}{"keyids": []string{"targets"}, "threshold": 1},
+			},
+		}, rootKey),
+		"targets": sign(t, map[string]interface{}{
+			"_type":   "Targets",
+			"expires": expires,
+			"targets": targetsJSON(map[string]string{"v1": "one"}),
+			"delegations": map[string]interface{}{
+				"keys": map[string]interface{}{"releases": releasesKey.key()},
+				"roles": []map[string]interface{}{
+					{"name": "targets/releases", "keyids": []string{"releases"}, "threshold": 1, "paths": []string{""}},
+					{"name": "targets/unused", "keyids": []string{"releases"}, "threshold": 1, "paths": []string{""}},
+				},
+			},
+		}, targetsKey),
+		"targets/releases": sign(t, map[string]interface{}{
+			"_type":   "Targets",
+			"expires": expires,
+			"targets": targetsJSON(map[string]string{"v2": "two", "latest": "two"}),
+		}, releasesKey),
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v2/docker.io/library/app/_trust/tuf/"), ".json")
+		b, ok := files[role]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(b)
+	}))
+	defer s.Close()
+	c := &Client{Server: s.URL}
+
+	got, err := c.Targets(context.Background(), GUN("index.docker.io", "library/app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"latest targets/releases", "v1 targets", "v2 targets/releases"}
+	if len(got) != len(want) {
+		t.Fatalf("Targets() = %v, want %v", got, want)
+	}
+	for i, tg := range got {
+		if tg.Tag+" "+tg.Role != want[i] {
+			t.Errorf("target %d = %s %s, want %s", i, tg.Tag, tg.Role, want[i])
+		}
+	}
+	if sum := sha256.Sum256([]byte("two")); got[0].Digest.Hex != hex.EncodeToString(sum[:]) {
+		t.Errorf("digest of latest = %s", got[0].Digest)
+	}
+
+	// A delegation signed by a key it wasn't delegated to is rejected.
+	files["targets/releases"] = sign(t, map[string]interface{}{"_type": "Targets", "expires": expires, "targets": targetsJSON(map[string]string{"v2": "evil"})}, other)
+	if _, err := c.Targets(context.Background(), "docker.io/library/app"); err == nil {
+		t.Error("expected error reading a delegation signed by the wrong key")
+	}
+}
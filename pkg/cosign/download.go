@@ -0,0 +1,134 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxDownloadSize bounds how many bytes of manifests and blobs are downloaded to read the
+// signatures or attestations of an image, unless the context sets another limit.
+const DefaultMaxDownloadSize int64 = 256 << 20
+
+// ErrDownloadTooLarge is returned when reading signatures or attestations would download more than
+// the limit set with WithMaxDownloadSize.
+var ErrDownloadTooLarge = errors.New("download exceeds the maximum size")
+
+type maxDownloadKey struct{}
+
+// WithMaxDownloadSize returns a context under which reading the signatures or attestations of an
+// image downloads at most n bytes, failing with ErrDownloadTooLarge rather than downloading more.
+// n <= 0 lifts the limit.
+func WithMaxDownloadSize(ctx context.Context, n int64) context.Context {
+	return context.WithValue(ctx, maxDownloadKey{}, n)
+}
+
+// maxDownloadSize returns the download limit of ctx, or 0 if there is none.
+func maxDownloadSize(ctx context.Context) int64 {
+	n, ok := ctx.Value(maxDownloadKey{}).(int64)
+	if !ok {
+		return DefaultMaxDownloadSize
+	}
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// checkDownloadSize fails if downloading size bytes for what would exceed the limit of ctx.
+// Sizes come from HEAD responses and manifests, so nothing is downloaded to find out.
+func checkDownloadSize(ctx context.Context, what string, size int64) error {
+	if max := maxDownloadSize(ctx); max > 0 && size > max {
+		return errors.Wrapf(ErrDownloadTooLarge, "%s would download %d bytes, the limit is %d", what, size, max)
+	}
+	return nil
+}
+
+// headDescriptor returns the descriptor of ref from a HEAD request, without downloading its
+// manifest, let alone its layers. Registries that don't answer HEAD with the digest get a GET.
+func headDescriptor(ref name.Reference, opts []remote.Option) (*remote.Descriptor, error) {
+	desc, err := remote.Head(ref, opts...)
+	if err == nil {
+		return &remote.Descriptor{Descriptor: *desc}, nil
+	}
+	if te, ok := err.(*transport.Error); ok && te.StatusCode == http.StatusNotFound {
+		return nil, err
+	}
+	return remote.Get(ref, opts...)
+}
+
+// manifestLayers returns the layers of m the filter keeps, failing if the manifest of size
+// manifestSize and those layers together exceed the download limit of ctx.
+func manifestLayers(ctx context.Context, ref name.Reference, manifestSize int64, m *v1.Manifest, keep func(v1.Descriptor) bool) ([]v1.Descriptor, error) {
+	total := manifestSize
+	var layers []v1.Descriptor
+	for _, desc := range m.Layers {
+		if !keep(desc) {
+			continue
+		}
+		layers = append(layers, desc)
+		total += desc.Size
+	}
+	if err := checkDownloadSize(ctx, ref.String(), total); err != nil {
+		return nil, err
+	}
+	return layers, nil
+}
+
+// limitedLayer is a layer that fails to read past the size its manifest declared, so a registry
+// can't stream more than the size checked against the download limit.
+type limitedLayer struct {
+	v1.Layer
+	size int64
+}
+
+// Compressed returns the contents of the layer, as stored in the registry.
+func (l *limitedLayer) Compressed() (io.ReadCloser, error) {
+	rc, err := l.Layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	return &limitedReader{ReadCloser: rc, left: l.size}, nil
+}
+
+type limitedReader struct {
+	io.ReadCloser
+	left int64
+}
+
+// Read reads at most one byte past the declared size, which is enough to tell the layer is larger.
+// Up to the declared size reads pass through, so the registry client still checks the digest at EOF.
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if r.left < 0 {
+		return 0, ErrDownloadTooLarge
+	}
+	if int64(len(p)) > r.left+1 {
+		p = p[:r.left+1]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.left -= int64(n)
+	if r.left < 0 {
+		return n, errors.Wrap(ErrDownloadTooLarge, "layer is larger than its descriptor")
+	}
+	return n, err
+}
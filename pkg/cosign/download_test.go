@@ -0,0 +1,74 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestManifestLayers(t *testing.T) {
+	ref := name.MustParseReference("example.com/app:sha256-abc.cosign")
+	m := &v1.Manifest{Layers: []v1.Descriptor{
+		{Size: 100, Annotations: map[string]string{sigkey: "sig"}},
+		// Only the layers that are read count against the limit.
+		{Size: 1 << 40},
+		{Size: 200, Annotations: map[string]string{sigkey: "sig"}},
+	}}
+	signed := func(desc v1.Descriptor) bool {
+		_, ok := desc.Annotations[sigkey]
+		return ok
+	}
+
+	layers, err := manifestLayers(WithMaxDownloadSize(context.Background(), 350), ref, 50, m, signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 2 || layers[0].Size != 100 || layers[1].Size != 200 {
+		t.Errorf("got layers %v, want the two signatures", layers)
+	}
+
+	if _, err := manifestLayers(WithMaxDownloadSize(context.Background(), 349), ref, 50, m, signed); !errors.Is(err, ErrDownloadTooLarge) {
+		t.Errorf("manifestLayers() = %v, want ErrDownloadTooLarge", err)
+	}
+	if _, err := manifestLayers(WithMaxDownloadSize(context.Background(), 0), ref, 50, m, func(v1.Descriptor) bool { return true }); err != nil {
+		t.Errorf("no limit: %v", err)
+	}
+	if got := maxDownloadSize(context.Background()); got != DefaultMaxDownloadSize {
+		t.Errorf("maxDownloadSize() = %d, want the default %d", got, DefaultMaxDownloadSize)
+	}
+}
+
+func TestLimitedLayer(t *testing.T) {
+	payload := []byte(`{"critical":{}}`)
+	l := &staticLayer{b: payload, mt: simpleSigningMediaType}
+
+	got, err := readLayer(&limitedLayer{Layer: l, size: int64(len(payload))}, simpleSigningMediaType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(payload) {
+		t.Errorf("readLayer() = %q, want %q", got, payload)
+	}
+
+	// A registry serving more than the manifest declared is cut off.
+	if _, err := readLayer(&limitedLayer{Layer: l, size: 4}, simpleSigningMediaType); !errors.Is(err, ErrDownloadTooLarge) {
+		t.Errorf("readLayer() = %v, want ErrDownloadTooLarge", err)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultBlobPayloadType is the DSSE payloadType used for blobs when none is specified.
+const DefaultBlobPayloadType = "application/octet-stream"
+
+// Envelope is a DSSE (Dead Simple Signing Envelope), see
+// https://github.com/secure-systems-lab/dsse/blob/master/envelope.md
+type Envelope struct {
+	PayloadType string              `json:"payloadType"`
+	Payload     string              `json:"payload"`
+	Signatures  []EnvelopeSignature `json:"signatures"`
+}
+
+type EnvelopeSignature struct {
+	KeyID string `json:"keyid,omitempty"`
+	Sig   string `json:"sig"`
+}
+
+// PAE implements the DSSE Pre-Authentication Encoding, which is what actually gets signed.
+// This binds the payload to its type so one can't be swapped without the other.
+func PAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// SignEnvelope wraps the payload in a DSSE envelope signed by signer.
+func SignEnvelope(ctx context.Context, signer Signer, keyID, payloadType string, payload []byte) (*Envelope, error) {
+	sig, err := signer.Sign(ctx, PAE(payloadType, payload))
+	if err != nil {
+		return nil, errors.Wrap(err, "signing envelope")
+	}
+	return &Envelope{
+		PayloadType: payloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures: []EnvelopeSignature{{
+			KeyID: keyID,
+			Sig:   base64.StdEncoding.EncodeToString(sig),
+		}},
+	}, nil
+}
+
+// VerifyEnvelope checks that at least one of the envelope signatures was made by verifier,
+// and returns the decoded payload.
+func VerifyEnvelope(ctx context.Context, verifier Verifier, env *Envelope) ([]byte, error) {
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding payload")
+	}
+	if len(env.Signatures) == 0 {
+		return nil, errors.New("envelope has no signatures")
+	}
+	pae := PAE(env.PayloadType, payload)
+	validationErrs := []string{}
+	for _, s := range env.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			validationErrs = append(validationErrs, err.Error())
+			continue
+		}
+		if err := verifier.Verify(ctx, pae, sig); err != nil {
+			validationErrs = append(validationErrs, err.Error())
+			continue
+		}
+		return payload, nil
+	}
+	return nil, fmt.Errorf("no matching envelope signatures:\n%s", strings.Join(validationErrs, "\n "))
+}
+
+// ParseEnvelope returns the DSSE envelope encoded in b, or an error if b isn't one.
+func ParseEnvelope(b []byte) (*Envelope, error) {
+	env := &Envelope{}
+	if err := json.Unmarshal(b, env); err != nil {
+		return nil, err
+	}
+	if env.PayloadType == "" || len(env.Signatures) == 0 {
+		return nil, errors.New("not a DSSE envelope")
+	}
+	return env, nil
+}
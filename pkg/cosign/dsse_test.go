@@ -0,0 +1,69 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestPAE(t *testing.T) {
+	// Test vector from the DSSE spec.
+	got := string(PAE("http://example.com/HelloWorld", []byte("hello world")))
+	want := "DSSEv1 29 http://example.com/HelloWorld 11 hello world"
+	if got != want {
+		t.Errorf("PAE() = %q, want %q", got, want)
+	}
+}
+
+func TestSignVerifyEnvelope(t *testing.T) {
+	ctx := context.Background()
+	priv, err := GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := WithECDSAKey(priv)
+
+	env, err := SignEnvelope(ctx, k, "", DefaultBlobPayloadType, []byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseEnvelope(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := VerifyEnvelope(ctx, k, parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payload) != "payload" {
+		t.Errorf("VerifyEnvelope() = %q, want %q", payload, "payload")
+	}
+
+	// Changing the payload type must break the signature.
+	parsed.PayloadType = "application/json"
+	if _, err := VerifyEnvelope(ctx, k, parsed); err == nil {
+		t.Error("expected error verifying envelope with a different payload type")
+	}
+
+	if _, err := ParseEnvelope([]byte("MEUCIQ==")); err == nil {
+		t.Error("expected error parsing a bare signature as an envelope")
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/pkg/errors"
+)
+
+// ParseAgeRecipients returns the age recipients to encrypt to. Each of recipients is an X25519 public key
+// (age1...), or the path to a recipients file with one per line, as taken by "age -R".
+func ParseAgeRecipients(recipients []string) ([]age.Recipient, error) {
+	out := []age.Recipient{}
+	for _, r := range recipients {
+		if strings.HasPrefix(r, "age1") {
+			x, err := age.ParseX25519Recipient(r)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, x)
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Clean(r))
+		if err != nil {
+			return nil, errors.Wrap(err, "reading recipients file")
+		}
+		rs, err := age.ParseRecipients(bytes.NewReader(b))
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing recipients file %s", r)
+		}
+		out = append(out, rs...)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no recipients to encrypt to")
+	}
+	return out, nil
+}
+
+// LoadAgeIdentities reads the X25519 identities in the age identity file at path, as written by age-keygen.
+func LoadAgeIdentities(path string) ([]age.Identity, error) {
+	b, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	ids, err := age.ParseIdentities(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing identity file %s", path)
+	}
+	return ids, nil
+}
+
+// EncryptBlob encrypts r to the recipients as a binary age file, written to w. Any of them can decrypt
+// it with "age -d", or DecryptBlob.
+func EncryptBlob(w io.Writer, r io.Reader, recipients []age.Recipient) error {
+	ew, err := age.Encrypt(w, recipients...)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(ew, r); err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+// DecryptBlob decrypts the age file in r, binary or armored, with one of the identities, writing the plaintext to w.
+func DecryptBlob(w io.Writer, r io.Reader, identities []age.Identity) error {
+	br := bufio.NewReader(r)
+	if start, _ := br.Peek(len(armor.Header)); string(start) == armor.Header {
+		r = armor.NewReader(br)
+	} else {
+		r = br
+	}
+	dr, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return errors.Wrap(err, "decrypting")
+	}
+	_, err = io.Copy(w, dr)
+	return err
+}
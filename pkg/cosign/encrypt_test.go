@@ -0,0 +1,82 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestEncryptBlob(t *testing.T) {
+	alice, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eve, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	td, err := ioutil.TempDir("", "cosign-encrypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	recipientsFile := filepath.Join(td, "recipients.txt")
+	if err := ioutil.WriteFile(recipientsFile, []byte("# release team\n"+bob.Recipient().String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	recipients, err := ParseAgeRecipients([]string{alice.Recipient().String(), recipientsFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := []byte("confidential release artifact")
+	encrypted := &bytes.Buffer{}
+	if err := EncryptBlob(encrypted, bytes.NewReader(blob), recipients); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(encrypted.Bytes(), blob) {
+		t.Fatal("encrypted blob contains the plaintext")
+	}
+
+	for _, id := range []*age.X25519Identity{alice, bob} {
+		decrypted := &bytes.Buffer{}
+		if err := DecryptBlob(decrypted, bytes.NewReader(encrypted.Bytes()), []age.Identity{id}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decrypted.Bytes(), blob) {
+			t.Errorf("DecryptBlob() = %q, want %q", decrypted, blob)
+		}
+	}
+	if err := DecryptBlob(ioutil.Discard, bytes.NewReader(encrypted.Bytes()), []age.Identity{eve}); err == nil {
+		t.Error("expected error decrypting with an identity that isn't a recipient")
+	}
+
+	if _, err := ParseAgeRecipients(nil); err == nil {
+		t.Error("expected error without recipients")
+	}
+	if _, err := ParseAgeRecipients([]string{"age1notakey"}); err == nil {
+		t.Error("expected error parsing an invalid recipient")
+	}
+}
@@ -17,25 +17,39 @@ package cosign
 import (
 	"context"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
-	"io/ioutil"
+	"fmt"
+	"net/http"
 	"runtime"
-	"strings"
 
-	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/semaphore"
+
+	"github.com/sigstore/cosign/pkg/cosign/fulcio"
+	"github.com/sigstore/cosign/pkg/cosign/telemetry"
 )
 
+// ErrNoSignatures is returned by FetchSignatures when no signatures are stored for an image.
+var ErrNoSignatures = errors.New("no signatures found")
+
 type SignedPayload struct {
 	Base64Signature string
 	Payload         []byte
 	Cert            *x509.Certificate
 	Chain           []*x509.Certificate
+	// KeyID and Algorithm are recorded by the signer, they are informational and not verified.
+	KeyID     string
+	Algorithm string
+	// SCT is the certificate transparency log's timestamp for Cert, if the signer recorded it.
+	SCT *fulcio.SCT `json:",omitempty"`
+	// HardwareAttestation attests the signing key was generated on a hardware device, if the signer recorded it.
+	HardwareAttestation []*x509.Certificate `json:",omitempty"`
 }
 
 // TODO: marshal the cert correctly.
@@ -47,66 +61,126 @@ type SignedPayload struct {
 // 	})
 // }
 
+// Munge returns the tag the signatures of the image with desc are stored under, see ArtifactTag.
 func Munge(desc v1.Descriptor) string {
-	// sha256:... -> sha256-...
-	munged := strings.ReplaceAll(desc.Digest.String(), ":", "-")
-	munged += ".cosign"
-	return munged
+	return ArtifactTag(desc.Digest, SignatureKind)
 }
 
-func FetchSignatures(ctx context.Context, ref name.Reference) ([]SignedPayload, *v1.Descriptor, error) {
-	targetDesc, err := remote.Get(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+// FetchSignatures returns the signatures stored for ref along with its descriptor.
+// If ref has never been signed, the descriptor is returned with an error wrapping ErrNoSignatures.
+// opts configure the registry client, by default credentials come from the docker config.
+// The mirrors of the registry are tried first, see WithRegistryMirrors.
+func FetchSignatures(ctx context.Context, ref name.Reference, opts ...remote.Option) ([]SignedPayload, *v1.Descriptor, error) {
+	return fetchSignatures(ctx, ref, nil, opts)
+}
+
+// fetchSignatures is FetchSignatures, using signatures from cache when it has them.
+func fetchSignatures(ctx context.Context, ref name.Reference, cache *Cache, opts []remote.Option) (_ []SignedPayload, _ *v1.Descriptor, err error) {
+	ctx, end := telemetry.Start(ctx, telemetry.OpFetchSignatures)
+	defer func() { end(err) }()
+	opts = registryOpts(ctx, opts)
+	targetDesc, err := mirroredDescriptor(ctx, ref, opts)
 	if err != nil {
 		return nil, nil, err
 	}
+	signatures, err := signaturesOf(ctx, ref, targetDesc, cache, opts)
+	if err != nil {
+		if errors.Is(err, ErrNoSignatures) {
+			return nil, &targetDesc.Descriptor, err
+		}
+		return nil, nil, err
+	}
+	return signatures, &targetDesc.Descriptor, nil
+}
+
+// FetchSignaturesFor returns the signatures stored in ref's repository for the image with desc, which
+// doesn't have to be in the registry, for example an image in the local docker daemon that hasn't been
+// pushed yet. If it has never been signed, the error wraps ErrNoSignatures.
+func FetchSignaturesFor(ctx context.Context, ref name.Reference, desc v1.Descriptor, opts ...remote.Option) ([]SignedPayload, error) {
+	return signaturesOf(ctx, ref, &remote.Descriptor{Descriptor: desc}, nil, registryOpts(ctx, opts))
+}
 
+// signaturesOf returns the signatures stored for the image with targetDesc in ref's repository, or
+// the alternate one of ctx.
+func signaturesOf(ctx context.Context, ref name.Reference, targetDesc *remote.Descriptor, cache *Cache, opts []remote.Option) ([]SignedPayload, error) {
 	// first, see if signatures exist in an alternate location
-	dstRef, err := DestinationRef(ref, targetDesc)
+	dstRef, err := destinationRef(ref, targetDesc, signatureRepository(ctx))
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	// The signature tag is named after the image digest, so it identifies the signatures.
+	if sps, ok := cache.signatures(dstRef.String()); ok {
+		return sps, nil
 	}
-	sigImg, err := remote.Image(dstRef, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	var signatures []SignedPayload
+	err = withMirrors(ctx, dstRef, func(r name.Reference) (err error) {
+		signatures, err = fetchSignedPayloads(ctx, r, opts)
+		return err
+	})
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "remote image")
+		return nil, err
+	}
+	cache.putSignatures(dstRef.String(), signatures)
+	return signatures, nil
+}
+
+// fetchSignedPayloads reads the signatures stored in the image at sigRef, returning an error
+// wrapping ErrNoSignatures if there is no such image. Only the manifest and the signature payloads
+// are downloaded, within the download limit of ctx.
+func fetchSignedPayloads(ctx context.Context, sigRef name.Reference, opts []remote.Option) ([]SignedPayload, error) {
+	sigDesc, err := headDescriptor(sigRef, opts)
+	if err != nil {
+		if te, ok := err.(*transport.Error); ok && te.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%s: %w", sigRef, ErrNoSignatures)
+		}
+		return nil, errors.Wrap(err, "remote image")
+	}
+	if err := checkDownloadSize(ctx, sigRef.String(), sigDesc.Size); err != nil {
+		return nil, err
+	}
+	sigImg, err := remote.Image(sigRef, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "remote image")
 	}
 
 	m, err := sigImg.Manifest()
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "manifest")
+		return nil, errors.Wrap(err, "manifest")
+	}
+	layers, err := manifestLayers(ctx, sigRef, sigDesc.Size, m, func(desc v1.Descriptor) bool {
+		_, ok := desc.Annotations[sigkey]
+		return ok
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	g, ctx := errgroup.WithContext(ctx)
-	signatures := make([]SignedPayload, len(m.Layers))
+	signatures := make([]SignedPayload, len(layers))
 	sem := semaphore.NewWeighted(int64(runtime.NumCPU()))
-	for i, desc := range m.Layers {
+	for i, desc := range layers {
 		i, desc := i, desc
 		g.Go(func() error {
 			if err := sem.Acquire(ctx, 1); err != nil {
 				return err
 			}
 			defer sem.Release(1)
-			base64sig, ok := desc.Annotations[sigkey]
-			if !ok {
-				return nil
-			}
+			base64sig := desc.Annotations[sigkey]
 			l, err := sigImg.LayerByDigest(desc.Digest)
 			if err != nil {
 				return err
 			}
 
-			// Compressed is a misnomer here, we just want the raw bytes from the registry.
-			r, err := l.Compressed()
-			if err != nil {
-				return err
-
-			}
-			payload, err := ioutil.ReadAll(r)
+			// Payloads stored zstd compressed are decompressed here, the signature is over the original.
+			payload, err := readLayer(&limitedLayer{Layer: l, size: desc.Size}, desc.MediaType)
 			if err != nil {
 				return err
 			}
 			sp := SignedPayload{
 				Payload:         payload,
 				Base64Signature: base64sig,
+				KeyID:           desc.Annotations[keyidkey],
+				Algorithm:       desc.Annotations[algkey],
 			}
 			// We may have a certificate and chain
 			certPem := desc.Annotations[certkey]
@@ -125,15 +199,26 @@ func FetchSignatures(ctx context.Context, ref name.Reference) ([]SignedPayload,
 				}
 				sp.Chain = certs
 			}
+			if sct := desc.Annotations[sctkey]; sct != "" {
+				sp.SCT = &fulcio.SCT{}
+				if err := json.Unmarshal([]byte(sct), sp.SCT); err != nil {
+					return errors.Wrap(err, "parsing SCT")
+				}
+			}
+			if hwatt := desc.Annotations[hwattkey]; hwatt != "" {
+				if sp.HardwareAttestation, err = LoadCerts(hwatt); err != nil {
+					return errors.Wrap(err, "parsing hardware attestation")
+				}
+			}
 
 			signatures[i] = sp
 			return nil
 		})
 	}
 	if err := g.Wait(); err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	return signatures, &targetDesc.Descriptor, nil
+	return signatures, nil
 }
 
 func LoadCerts(pemStr string) ([]*x509.Certificate, error) {
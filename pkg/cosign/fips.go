@@ -0,0 +1,68 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"fmt"
+	"sync/atomic"
+)
+
+// minFIPSRSABits is the smallest RSA modulus FIPS 186-4 allows for signatures.
+const minFIPSRSABits = 2048
+
+var fipsMode int32
+
+// SetFIPSMode restricts signing and verification to keys of FIPS 186-4 approved algorithms:
+// ECDSA on the NIST P-256, P-384 and P-521 curves, and RSA of at least 2048 bits. Ed25519,
+// and with it SSH ed25519 keys and minisign signatures, is rejected. It is on by default in
+// builds with the boringcrypto tag.
+func SetFIPSMode(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&fipsMode, v)
+}
+
+// FIPSMode reports whether SetFIPSMode is on.
+func FIPSMode() bool {
+	return atomic.LoadInt32(&fipsMode) == 1
+}
+
+// CheckFIPSKey returns an error if FIPS mode is on and pub isn't a key of an approved algorithm.
+func CheckFIPSKey(pub crypto.PublicKey) error {
+	if !FIPSMode() {
+		return nil
+	}
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256(), elliptic.P384(), elliptic.P521():
+			return nil
+		}
+		return fmt.Errorf("ECDSA curve %s is not allowed in FIPS mode", pub.Curve.Params().Name)
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minFIPSRSABits {
+			return fmt.Errorf("%d bit RSA keys are not allowed in FIPS mode, the minimum is %d", pub.N.BitLen(), minFIPSRSABits)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%T keys are not allowed in FIPS mode", pub)
+	}
+}
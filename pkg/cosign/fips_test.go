@@ -0,0 +1,79 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestCheckFIPSKey(t *testing.T) {
+	ecKey := func(c elliptic.Curve) crypto.PublicKey {
+		k, err := ecdsa.GenerateKey(c, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &k.PublicKey
+	}
+	rsaKey := func(bits int) crypto.PublicKey {
+		k, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &k.PublicKey
+	}
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc string
+		key  crypto.PublicKey
+		ok   bool
+	}{
+		{"P-256", ecKey(elliptic.P256()), true},
+		{"P-384", ecKey(elliptic.P384()), true},
+		{"P-224", ecKey(elliptic.P224()), false},
+		{"RSA 2048", rsaKey(2048), true},
+		{"RSA 1024", rsaKey(1024), false},
+		{"Ed25519", edKey, false},
+	}
+
+	SetFIPSMode(true)
+	defer SetFIPSMode(false)
+	for _, tt := range tests {
+		if err := CheckFIPSKey(tt.key); (err == nil) != tt.ok {
+			t.Errorf("CheckFIPSKey(%s) = %v, want ok %v", tt.desc, err, tt.ok)
+		}
+	}
+
+	SetFIPSMode(false)
+	if err := CheckFIPSKey(edKey); err != nil {
+		t.Errorf("CheckFIPSKey() outside FIPS mode = %v", err)
+	}
+	if _, err := WithCryptoSigner(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))); err != nil {
+		t.Errorf("WithCryptoSigner(ed25519) outside FIPS mode = %v", err)
+	}
+	SetFIPSMode(true)
+	if _, err := WithCryptoSigner(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))); err == nil {
+		t.Error("expected error wrapping an ed25519 signer in FIPS mode")
+	}
+}
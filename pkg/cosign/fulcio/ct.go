@@ -0,0 +1,160 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fulcio
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/trillian/merkle/logverifier"
+	"github.com/google/trillian/merkle/rfc6962/hasher"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign/telemetry"
+)
+
+const defaultCTLogAddress = "https://ctfe.sigstore.dev/test"
+
+// CTLogServer returns the address of the certificate transparency log Fulcio logs the certificates
+// it issues to, which can be overridden with $FULCIO_CT_LOG_ADDRESS.
+func CTLogServer() string {
+	addr := os.Getenv("FULCIO_CT_LOG_ADDRESS")
+	if addr != "" {
+		return addr
+	}
+	return defaultCTLogAddress
+}
+
+// SCT is a signed certificate timestamp, the CT log's promise to include a certificate, in the JSON
+// form of RFC 6962 add-chain responses. The timestamp is needed to look the certificate up in the log.
+type SCT struct {
+	Version    int    `json:"sct_version"`
+	ID         []byte `json:"id"`
+	Timestamp  uint64 `json:"timestamp"`
+	Extensions []byte `json:"extensions"`
+	Signature  []byte `json:"signature"`
+}
+
+// AddChain submits the certificate and its chain to the CT log, and returns its SCT. The log
+// deduplicates submissions, so for a certificate Fulcio already logged the existing SCT is returned.
+func AddChain(ctx context.Context, ctURL string, certs []*x509.Certificate) (_ *SCT, err error) {
+	ctx, end := telemetry.Start(ctx, telemetry.OpCTLogAddChain)
+	defer func() { end(err) }()
+	req := struct {
+		Chain [][]byte `json:"chain"`
+	}{}
+	for _, c := range certs {
+		req.Chain = append(req.Chain, c.Raw)
+	}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	sct := &SCT{}
+	if err := ctRequest(ctx, http.MethodPost, ctURL, "add-chain", nil, b, sct); err != nil {
+		return nil, err
+	}
+	return sct, nil
+}
+
+// VerifyCTInclusion checks that cert is in the CT log the sct is from: it fetches the log's
+// current tree head and the inclusion proof of the certificate's entry, and verifies the proof.
+func VerifyCTInclusion(ctx context.Context, ctURL string, cert *x509.Certificate, sct *SCT) (err error) {
+	ctx, end := telemetry.Start(ctx, telemetry.OpCTLogGetProof)
+	defer func() { end(err) }()
+	if sct.Version != 0 {
+		return fmt.Errorf("unsupported SCT version %d", sct.Version)
+	}
+	leafHash, err := ctLeafHash(cert, sct)
+	if err != nil {
+		return err
+	}
+	sth := struct {
+		TreeSize int64  `json:"tree_size"`
+		RootHash []byte `json:"sha256_root_hash"`
+	}{}
+	if err := ctRequest(ctx, http.MethodGet, ctURL, "get-sth", nil, nil, &sth); err != nil {
+		return err
+	}
+	proof := struct {
+		LeafIndex int64    `json:"leaf_index"`
+		AuditPath [][]byte `json:"audit_path"`
+	}{}
+	q := url.Values{
+		"hash":      []string{base64.StdEncoding.EncodeToString(leafHash)},
+		"tree_size": []string{strconv.FormatInt(sth.TreeSize, 10)},
+	}
+	if err := ctRequest(ctx, http.MethodGet, ctURL, "get-proof-by-hash", q, nil, &proof); err != nil {
+		return errors.Wrap(err, "certificate not found in the CT log")
+	}
+	v := logverifier.New(hasher.DefaultHasher)
+	if err := v.VerifyInclusionProof(proof.LeafIndex, sth.TreeSize, proof.AuditPath, sth.RootHash, leafHash); err != nil {
+		return errors.Wrap(err, "verifying CT inclusion proof")
+	}
+	return nil
+}
+
+// ctLeafHash returns the RFC 6962 Merkle tree hash of the log entry for cert: a timestamped
+// X.509 entry, with the timestamp and extensions of the SCT.
+func ctLeafHash(cert *x509.Certificate, sct *SCT) ([]byte, error) {
+	if len(cert.Raw) >= 1<<24 || len(sct.Extensions) >= 1<<16 {
+		return nil, errors.New("certificate or SCT extensions too large for a CT log entry")
+	}
+	leaf := &bytes.Buffer{}
+	leaf.Write([]byte{0, 0}) // v1, timestamped_entry
+	_ = binary.Write(leaf, binary.BigEndian, sct.Timestamp)
+	leaf.Write([]byte{0, 0}) // x509_entry
+	leaf.Write([]byte{byte(len(cert.Raw) >> 16), byte(len(cert.Raw) >> 8), byte(len(cert.Raw))})
+	leaf.Write(cert.Raw)
+	_ = binary.Write(leaf, binary.BigEndian, uint16(len(sct.Extensions)))
+	leaf.Write(sct.Extensions)
+	h := sha256.Sum256(append([]byte{0}, leaf.Bytes()...))
+	return h[:], nil
+}
+
+// ctRequest calls one of the RFC 6962 endpoints of the log at ctURL, decoding the response into out.
+func ctRequest(ctx context.Context, method, ctURL, endpoint string, q url.Values, body []byte, out interface{}) error {
+	u := strings.TrimSuffix(ctURL, "/") + "/ct/v1/" + endpoint
+	if q != nil {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	return errors.Wrapf(json.NewDecoder(resp.Body).Decode(out), "decoding %s response", endpoint)
+}
@@ -0,0 +1,136 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fulcio
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testCTLog serves an RFC 6962 log of three entries, the first of which is the certificate it was given.
+type testCTLog struct {
+	sct    *SCT
+	leaves [][]byte
+	// forked serves a tree head for a different tree than the proofs.
+	forked bool
+}
+
+func newTestCTLog(t *testing.T, cert *x509.Certificate) *testCTLog {
+	l := &testCTLog{sct: &SCT{Timestamp: 1617000000000, ID: []byte("log")}}
+	h, err := ctLeafHash(cert, l.sct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other1, other2 := sha256.Sum256([]byte("other1")), sha256.Sum256([]byte("other2"))
+	l.leaves = [][]byte{h, other1[:], other2[:]}
+	return l
+}
+
+func nodeHash(l, r []byte) []byte {
+	h := sha256.Sum256(append(append([]byte{1}, l...), r...))
+	return h[:]
+}
+
+func (l *testCTLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/ct/v1/add-chain":
+		_ = json.NewEncoder(w).Encode(l.sct)
+	case "/ct/v1/get-sth":
+		root := nodeHash(nodeHash(l.leaves[0], l.leaves[1]), l.leaves[2])
+		if l.forked {
+			root = nodeHash(nodeHash(l.leaves[0], l.leaves[2]), l.leaves[1])
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"tree_size":        3,
+			"sha256_root_hash": root,
+		})
+	case "/ct/v1/get-proof-by-hash":
+		if r.URL.Query().Get("hash") != base64.StdEncoding.EncodeToString(l.leaves[0]) {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"leaf_index": 0,
+			"audit_path": [][]byte{l.leaves[1], l.leaves[2]},
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func testCert(t *testing.T) *x509.Certificate {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		Subject:        pkix.Name{CommonName: "test"},
+		NotBefore:      time.Now(),
+		NotAfter:       time.Now().Add(time.Hour),
+		EmailAddresses: []string{"foo@example.com"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestVerifyCTInclusion(t *testing.T) {
+	ctx := context.Background()
+	cert := testCert(t)
+	ctLog := newTestCTLog(t, cert)
+	s := httptest.NewServer(ctLog)
+	defer s.Close()
+
+	sct, err := AddChain(ctx, s.URL, []*x509.Certificate{cert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyCTInclusion(ctx, s.URL, cert, sct); err != nil {
+		t.Errorf("VerifyCTInclusion() = %v", err)
+	}
+
+	// A certificate that isn't in the log.
+	if err := VerifyCTInclusion(ctx, s.URL, testCert(t), sct); err == nil {
+		t.Error("expected error verifying a certificate that isn't in the log")
+	}
+	// The SCT's timestamp is part of the entry, so a different one doesn't find it.
+	if err := VerifyCTInclusion(ctx, s.URL, cert, &SCT{Timestamp: sct.Timestamp + 1}); err == nil {
+		t.Error("expected error verifying with the wrong SCT")
+	}
+	// The inclusion proof must lead to the log's tree head.
+	ctLog.forked = true
+	if err := VerifyCTInclusion(ctx, s.URL, cert, sct); err == nil {
+		t.Error("expected error verifying against a forked tree head")
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"encoding/pem"
 	"os"
 
+	"github.com/pkg/errors"
 	"github.com/sigstore/sigstore/pkg/oauthflow"
 
 	"github.com/sigstore/fulcio/cmd/client/app"
@@ -33,6 +34,8 @@ import (
 	"github.com/go-openapi/swag"
 	"github.com/sigstore/fulcio/pkg/generated/client/operations"
 	"github.com/sigstore/fulcio/pkg/generated/models"
+
+	"github.com/sigstore/cosign/pkg/cosign/telemetry"
 )
 
 const defaultFulcioAddress = "https://fulcio-dev.sigstore.dev"
@@ -41,7 +44,8 @@ const defaultFulcioAddress = "https://fulcio-dev.sigstore.dev"
 //go:embed fulcio.pem
 var rootPem string
 
-func fulcioServer() string {
+// Server returns the address of Fulcio, which can be overridden with $FULCIO_ADDRESS.
+func Server() string {
 	addr := os.Getenv("FULCIO_ADDRESS")
 	if addr != "" {
 		return addr
@@ -63,7 +67,7 @@ type signingCertProvider interface {
 	SigningCert(params *operations.SigningCertParams, authInfo runtime.ClientAuthInfoWriter) (*operations.SigningCertCreated, error)
 }
 
-func getCertForOauthID(priv *ecdsa.PrivateKey, scp signingCertProvider, flow oidcFlow) (string, string, error) {
+func getCertForOauthID(ctx context.Context, priv *ecdsa.PrivateKey, scp signingCertProvider, flow oidcFlow) (string, string, error) {
 	pubBytes, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
 	if err != nil {
 		return "", "", err
@@ -85,7 +89,7 @@ func getCertForOauthID(priv *ecdsa.PrivateKey, scp signingCertProvider, flow oid
 
 	content := strfmt.Base64(pubBytes)
 	signedEmail := strfmt.Base64(proof)
-	params := operations.NewSigningCertParams()
+	params := operations.NewSigningCertParamsWithContext(ctx)
 	params.SetCertificateRequest(
 		&models.CertificateRequest{
 			PublicKey: &models.CertificateRequestPublicKey{
@@ -108,18 +112,23 @@ func getCertForOauthID(priv *ecdsa.PrivateKey, scp signingCertProvider, flow oid
 }
 
 // GetCert returns the PEM-encoded signature of the OIDC identity returned as part of an interactive oauth2 flow plus the PEM-encoded cert chain.
-func GetCert(ctx context.Context, priv *ecdsa.PrivateKey) (string, string, error) {
-	fcli, err := app.GetFulcioClient(fulcioServer())
+func GetCert(ctx context.Context, priv *ecdsa.PrivateKey) (_ string, _ string, err error) {
+	ctx, end := telemetry.Start(ctx, telemetry.OpFulcioGetCert)
+	defer func() { end(err) }()
+	fcli, err := app.GetFulcioClient(Server())
 	if err != nil {
 		return "", "", err
 	}
 
 	flow := &defaultFlow{}
 
-	return getCertForOauthID(priv, fcli.Operations, flow)
+	return getCertForOauthID(ctx, priv, fcli.Operations, flow)
 }
 
-var Roots *x509.CertPool
+var (
+	Roots     *x509.CertPool
+	rootCerts []*x509.Certificate
+)
 
 func init() {
 	cp := x509.NewCertPool()
@@ -127,4 +136,37 @@ func init() {
 		panic("error creating root cert pool")
 	}
 	Roots = cp
+	for rest := []byte(rootPem); ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			panic("error parsing root cert")
+		}
+		rootCerts = append(rootCerts, c)
+	}
+}
+
+// IsRoot reports whether c is one of the Fulcio roots.
+func IsRoot(c *x509.Certificate) bool {
+	for _, r := range rootCerts {
+		if r.Equal(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckCert checks that cert has the extensions Fulcio puts in the certificates it issues: it is
+// not a CA, and names the identity it was issued to as an email address or URI.
+func CheckCert(cert *x509.Certificate) error {
+	if cert.BasicConstraintsValid && cert.IsCA {
+		return errors.New("certificate issued by Fulcio is a CA certificate, not a signing certificate")
+	}
+	if len(cert.EmailAddresses) == 0 && len(cert.URIs) == 0 {
+		return errors.New("certificate issued by Fulcio has no email address or URI identity")
+	}
+	return nil
 }
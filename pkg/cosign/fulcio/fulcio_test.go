@@ -16,11 +16,14 @@ limitations under the License.
 package fulcio
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/x509"
 	"encoding/pem"
 	"errors"
+	"net/url"
 	"testing"
 
 	"github.com/go-openapi/runtime"
@@ -112,7 +115,7 @@ func TestGetCertForOauthID(t *testing.T) {
 				err: tc.tokenGetterErr,
 			}
 
-			cert, chain, err := getCertForOauthID(testKey, tscp, &tf)
+			cert, chain, err := getCertForOauthID(context.Background(), testKey, tscp, &tf)
 
 			if err != nil {
 				if !tc.expectErr {
@@ -135,3 +138,41 @@ func TestGetCertForOauthID(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckCert(t *testing.T) {
+	if len(rootCerts) == 0 || !IsRoot(rootCerts[0]) {
+		t.Fatal("Fulcio root not recognized by IsRoot")
+	}
+	if IsRoot(&x509.Certificate{Raw: []byte("not a root")}) {
+		t.Error("IsRoot() = true for a certificate that isn't a Fulcio root")
+	}
+
+	uri, err := url.Parse("https://github.com/my-org/repo/.github/workflows/release.yml@refs/heads/main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		desc    string
+		cert    *x509.Certificate
+		wantErr bool
+	}{{
+		desc: "email",
+		cert: &x509.Certificate{EmailAddresses: []string{"foo@example.com"}},
+	}, {
+		desc: "URI",
+		cert: &x509.Certificate{URIs: []*url.URL{uri}},
+	}, {
+		desc:    "CA",
+		cert:    &x509.Certificate{EmailAddresses: []string{"foo@example.com"}, IsCA: true, BasicConstraintsValid: true},
+		wantErr: true,
+	}, {
+		desc:    "no identity",
+		cert:    &x509.Certificate{},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		if err := CheckCert(tt.cert); (err != nil) != tt.wantErr {
+			t.Errorf("CheckCert() for %s = %v, wantErr %v", tt.desc, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Extensions YubiKeys add to the attestation certificates of PIV keys, see
+// https://developers.yubico.com/PIV/Introduction/PIV_attestation.html.
+var (
+	yubicoFirmwareOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 41482, 3, 3}
+	yubicoSerialOID   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 41482, 3, 7}
+	yubicoPolicyOID   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 41482, 3, 8}
+)
+
+var (
+	yubicoPINPolicies   = map[byte]string{1: "never", 2: "once", 3: "always"}
+	yubicoTouchPolicies = map[byte]string{1: "never", 2: "always", 3: "cached"}
+)
+
+// HardwareAttestation describes the device a signing key was generated on, as vouched for by its vendor.
+type HardwareAttestation struct {
+	// Issuer is the subject of the vendor root the attestation chains up to.
+	Issuer string `json:"issuer"`
+	// Serial, Firmware, PINPolicy and TouchPolicy are set for YubiKeys.
+	Serial      string `json:"serial,omitempty"`
+	Firmware    string `json:"firmware,omitempty"`
+	PINPolicy   string `json:"pinPolicy,omitempty"`
+	TouchPolicy string `json:"touchPolicy,omitempty"`
+}
+
+// VerifyHardwareAttestation checks that chain attests that pub was generated on a hardware device: the first
+// certificate must be for pub, and each must be signed by the next, the last by one of the vendor roots.
+// Device attestation certificates aren't always valid CA certificates, YubiKeys' older intermediates aren't,
+// so only the signatures along the chain are checked, not the constraints of a TLS chain.
+func VerifyHardwareAttestation(pub crypto.PublicKey, chain []*x509.Certificate, roots []*x509.Certificate) (*HardwareAttestation, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("signature has no hardware attestation")
+	}
+	k, ok := pub.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !k.Equal(chain[0].PublicKey) {
+		return nil, errors.New("hardware attestation is for a different key than the signature's")
+	}
+	for i, c := range chain[:len(chain)-1] {
+		if err := checkSignedBy(c, chain[i+1]); err != nil {
+			return nil, errors.Wrap(err, "verifying hardware attestation chain")
+		}
+	}
+	last := chain[len(chain)-1]
+	for _, r := range roots {
+		if r.Equal(last) || checkSignedBy(last, r) == nil {
+			ha := &HardwareAttestation{Issuer: r.Subject.String()}
+			if err := ha.parseYubicoExtensions(chain[0]); err != nil {
+				return nil, err
+			}
+			return ha, nil
+		}
+	}
+	return nil, errors.New("hardware attestation doesn't chain up to a trusted vendor root")
+}
+
+func checkSignedBy(c, parent *x509.Certificate) error {
+	if !bytes.Equal(c.RawIssuer, parent.RawSubject) {
+		return fmt.Errorf("%q isn't issued by %q", c.Subject, parent.Subject)
+	}
+	return parent.CheckSignature(c.SignatureAlgorithm, c.RawTBSCertificate, c.Signature)
+}
+
+// parseYubicoExtensions records what a YubiKey attestation certificate says about the device and key.
+func (ha *HardwareAttestation) parseYubicoExtensions(c *x509.Certificate) error {
+	for _, ext := range c.Extensions {
+		switch {
+		case ext.Id.Equal(yubicoFirmwareOID):
+			if len(ext.Value) != 3 {
+				return errors.New("invalid YubiKey firmware version extension")
+			}
+			ha.Firmware = fmt.Sprintf("%d.%d.%d", ext.Value[0], ext.Value[1], ext.Value[2])
+		case ext.Id.Equal(yubicoSerialOID):
+			var serial int64
+			if _, err := asn1.Unmarshal(ext.Value, &serial); err != nil {
+				return errors.Wrap(err, "invalid YubiKey serial number extension")
+			}
+			ha.Serial = strconv.FormatInt(serial, 10)
+		case ext.Id.Equal(yubicoPolicyOID):
+			if len(ext.Value) != 2 {
+				return errors.New("invalid YubiKey PIN and touch policy extension")
+			}
+			ha.PINPolicy, ha.TouchPolicy = yubicoPINPolicies[ext.Value[0]], yubicoTouchPolicies[ext.Value[1]]
+		}
+	}
+	return nil
+}
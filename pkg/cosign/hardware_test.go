@@ -0,0 +1,106 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestVerifyHardwareAttestation(t *testing.T) {
+	newKey := func() *ecdsa.PrivateKey {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	newCert := func(tmpl, parent *x509.Certificate, pub crypto.PublicKey, priv crypto.Signer) *x509.Certificate {
+		tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+		tmpl.NotBefore, tmpl.NotAfter = time.Now(), time.Now().Add(time.Hour)
+		if parent == nil {
+			parent = tmpl
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	rootKey, deviceKey, signingKey := newKey(), newKey(), newKey()
+	root := newCert(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test PIV Root CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}, nil, &rootKey.PublicKey, rootKey)
+	// Like older YubiKeys' slot f9 certificates, the device intermediate isn't marked as a CA.
+	device := newCert(&x509.Certificate{Subject: pkix.Name{CommonName: "Test PIV Attestation"}}, root, &deviceKey.PublicKey, rootKey)
+	serial, err := asn1.Marshal(12345678)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestation := newCert(&x509.Certificate{
+		Subject: pkix.Name{CommonName: "Test PIV Attestation 9c"},
+		ExtraExtensions: []pkix.Extension{
+			{Id: yubicoFirmwareOID, Value: []byte{5, 2, 7}},
+			{Id: yubicoSerialOID, Value: serial},
+			{Id: yubicoPolicyOID, Value: []byte{2, 3}},
+		},
+	}, device, &signingKey.PublicKey, deviceKey)
+
+	ha, err := VerifyHardwareAttestation(&signingKey.PublicKey, []*x509.Certificate{attestation, device}, []*x509.Certificate{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := HardwareAttestation{Issuer: "CN=Test PIV Root CA", Serial: "12345678", Firmware: "5.2.7", PINPolicy: "once", TouchPolicy: "cached"}
+	if *ha != want {
+		t.Errorf("VerifyHardwareAttestation() = %+v, want %+v", *ha, want)
+	}
+
+	otherKey := newKey()
+	otherRoot := newCert(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test PIV Root CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}, nil, &otherKey.PublicKey, otherKey)
+	for _, tt := range []struct {
+		desc  string
+		pub   crypto.PublicKey
+		chain []*x509.Certificate
+		roots []*x509.Certificate
+	}{
+		{"no attestation", &signingKey.PublicKey, nil, []*x509.Certificate{root}},
+		{"attestation of another key", &otherKey.PublicKey, []*x509.Certificate{attestation, device}, []*x509.Certificate{root}},
+		{"missing intermediate", &signingKey.PublicKey, []*x509.Certificate{attestation}, []*x509.Certificate{root}},
+		{"untrusted vendor", &signingKey.PublicKey, []*x509.Certificate{attestation, device}, []*x509.Certificate{otherRoot}},
+	} {
+		if _, err := VerifyHardwareAttestation(tt.pub, tt.chain, tt.roots); err == nil {
+			t.Errorf("VerifyHardwareAttestation() with %s: expected error", tt.desc)
+		}
+	}
+}
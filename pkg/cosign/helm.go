@@ -0,0 +1,84 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"encoding/json"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// HelmChartConfigMediaType is the config media type of Helm charts stored in a registry.
+	// The config holds the chart's Chart.yaml as JSON.
+	HelmChartConfigMediaType types.MediaType = "application/vnd.cncf.helm.config.v1+json"
+	// HelmChartContentMediaType is the media type of the chart archive layer.
+	HelmChartContentMediaType types.MediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+
+	// HelmChartNameAnnotation and HelmChartVersionAnnotation are the payload annotations that
+	// claim the name and version of a signed chart.
+	HelmChartNameAnnotation    = "helm.sh/chart-name"
+	HelmChartVersionAnnotation = "helm.sh/chart-version"
+)
+
+// HelmChart is the part of a chart's Chart.yaml that signatures make claims about.
+type HelmChart struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// IsHelmChart reports whether the manifest is for a Helm chart.
+func IsHelmChart(m *v1.Manifest) bool {
+	return m.Config.MediaType == HelmChartConfigMediaType
+}
+
+// FetchHelmChart returns the Chart.yaml metadata of the chart at desc, or nil if it isn't a Helm chart.
+func FetchHelmChart(desc *remote.Descriptor) (*HelmChart, error) {
+	m, err := v1.ParseManifest(bytes.NewReader(desc.Manifest))
+	if err != nil {
+		return nil, err
+	}
+	if !IsHelmChart(m) {
+		return nil, nil
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return nil, err
+	}
+	b, err := img.RawConfigFile()
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching chart config")
+	}
+	chart := &HelmChart{}
+	if err := json.Unmarshal(b, chart); err != nil {
+		return nil, errors.Wrap(err, "parsing chart config")
+	}
+	if chart.Name == "" || chart.Version == "" {
+		return nil, errors.New("chart config is missing the name or version")
+	}
+	return chart, nil
+}
+
+// Annotations returns the payload annotations claiming the chart's name and version.
+func (c *HelmChart) Annotations() map[string]string {
+	return map[string]string{
+		HelmChartNameAnnotation:    c.Name,
+		HelmChartVersionAnnotation: c.Version,
+	}
+}
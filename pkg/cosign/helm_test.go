@@ -0,0 +1,84 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestFetchHelmChart(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	chartRef, err := name.ParseReference(host + "/charts/demo:1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.Append(mutate.MediaType(empty.Image, types.OCIManifestSchema1), mutate.Addendum{
+		Layer: &staticLayer{b: []byte("chart archive"), mt: HelmChartContentMediaType},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chart := &artifact{Image: img, configMediaType: HelmChartConfigMediaType, config: []byte(`{"name":"demo","version":"1.2.3","apiVersion":"v2"}`)}
+	if err := remote.Write(chartRef, chart); err != nil {
+		t.Fatal(err)
+	}
+	get, err := remote.Get(chartRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := FetchHelmChart(get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Name != "demo" || got.Version != "1.2.3" {
+		t.Errorf("FetchHelmChart() = %+v, want demo 1.2.3", got)
+	}
+	if a := got.Annotations(); a[HelmChartNameAnnotation] != "demo" || a[HelmChartVersionAnnotation] != "1.2.3" {
+		t.Errorf("Annotations() = %v", a)
+	}
+
+	// Images aren't charts.
+	imgRef, err := name.ParseReference(host + "/image:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(imgRef, rnd); err != nil {
+		t.Fatal(err)
+	}
+	get, err = remote.Get(imgRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := FetchHelmChart(get); err != nil || got != nil {
+		t.Errorf("FetchHelmChart() for an image = %v, %v, want nil", got, err)
+	}
+}
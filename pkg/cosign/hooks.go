@@ -0,0 +1,86 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// SigningEvent describes an image signature as it is made. Each hook sees the fields of the steps
+// before it filled in.
+type SigningEvent struct {
+	// Image is the image being signed.
+	Image name.Digest
+	// Payload is what is signed. Hooks must not modify it.
+	Payload []byte
+	// Signature is set from OnSigned on.
+	Signature []byte
+	// Cert and Chain are the PEM certificate and chain of the signing key, if it has them.
+	Cert  string
+	Chain string
+	// KeyID and Algorithm are recorded next to the signature.
+	KeyID     string
+	Algorithm string
+	// SignatureRef is where the signature was uploaded to, set from OnUploaded on.
+	SignatureRef name.Reference
+	// TlogEntry is the transparency log entry of the signature, set for OnTlogEntry.
+	TlogEntry *TlogEntry
+}
+
+// Hooks are told about every step of signing an image, for example to record them in an audit log.
+// An error from a hook stops signing before the next step, so OnSigned can hold back the upload of
+// a signature until it is approved. Embed NopHooks to only implement some of them.
+type Hooks interface {
+	// OnPayloadGenerated is called with the payload, before it is signed.
+	OnPayloadGenerated(ctx context.Context, ev *SigningEvent) error
+	// OnSigned is called with the signature, before it is uploaded.
+	OnSigned(ctx context.Context, ev *SigningEvent) error
+	// OnUploaded is called once the signature is stored in the registry.
+	OnUploaded(ctx context.Context, ev *SigningEvent) error
+	// OnTlogEntry is called once the signature is in the transparency log.
+	OnTlogEntry(ctx context.Context, ev *SigningEvent) error
+}
+
+// NopHooks does nothing at every step.
+type NopHooks struct{}
+
+// OnPayloadGenerated does nothing.
+func (NopHooks) OnPayloadGenerated(context.Context, *SigningEvent) error { return nil }
+
+// OnSigned does nothing.
+func (NopHooks) OnSigned(context.Context, *SigningEvent) error { return nil }
+
+// OnUploaded does nothing.
+func (NopHooks) OnUploaded(context.Context, *SigningEvent) error { return nil }
+
+// OnTlogEntry does nothing.
+func (NopHooks) OnTlogEntry(context.Context, *SigningEvent) error { return nil }
+
+type hooksKey struct{}
+
+// WithHooks returns a context under which signing images calls h at every step.
+func WithHooks(ctx context.Context, h Hooks) context.Context {
+	return context.WithValue(ctx, hooksKey{}, h)
+}
+
+// HooksFrom returns the hooks of ctx, or NopHooks if it has none.
+func HooksFrom(ctx context.Context) Hooks {
+	if h, ok := ctx.Value(hooksKey{}).(Hooks); ok && h != nil {
+		return h
+	}
+	return NopHooks{}
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+)
+
+// IdentityBook declares which keyless identities may sign which images, so a fleet can be
+// verified against one signed document rather than passing the identities for each image.
+type IdentityBook struct {
+	Entries []IdentityBookEntry `json:"entries"`
+}
+
+// IdentityBookEntry allows Identities to sign the images in the repositories matching Images.
+type IdentityBookEntry struct {
+	// Identities are certificate emails or SPIFFE IDs, as in CheckOpts.Identities.
+	Identities []string `json:"identities"`
+	// Images are patterns of full repository names, e.g. gcr.io/acme/app. A * matches within a
+	// path component, as in path.Match, and a trailing /** matches every repository under a namespace.
+	Images []string `json:"images"`
+}
+
+// LoadIdentityBook parses the JSON or YAML identity book in b, once b64sig is verified as its
+// signature by one of keys, e.g. a signature made with "cosign sign-blob".
+func LoadIdentityBook(ctx context.Context, b []byte, b64sig string, keys []PublicKey) (*IdentityBook, error) {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b64sig))
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding identity book signature")
+	}
+	verified := false
+	for _, k := range keys {
+		if k.Verify(ctx, b, sig) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("identity book is not signed by a trusted key")
+	}
+	book := &IdentityBook{}
+	if err := yaml.Unmarshal(b, book); err != nil {
+		return nil, errors.Wrap(err, "parsing identity book")
+	}
+	for _, e := range book.Entries {
+		for _, p := range e.Images {
+			if _, err := path.Match(strings.TrimSuffix(p, "/**"), ""); err != nil {
+				return nil, fmt.Errorf("invalid image pattern %q: %v", p, err)
+			}
+		}
+	}
+	return book, nil
+}
+
+// Identities returns the identities allowed to sign the images in repo, none if the book doesn't mention it.
+func (b *IdentityBook) Identities(repo name.Repository) []string {
+	var ids []string
+	seen := map[string]bool{}
+	for _, e := range b.Entries {
+		if !matchesAny(e.Images, repo.Name()) {
+			continue
+		}
+		for _, id := range e.Identities {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+func matchesAny(patterns []string, repo string) bool {
+	for _, p := range patterns {
+		if ns := strings.TrimSuffix(p, "/**"); ns != p {
+			// Match the namespace against each parent of repo.
+			for i := strings.LastIndex(repo, "/"); i > 0; i = strings.LastIndex(repo[:i], "/") {
+				if ok, _ := path.Match(ns, repo[:i]); ok {
+					return true
+				}
+			}
+			continue
+		}
+		if ok, _ := path.Match(p, repo); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+const testIdentityBook = `entries:
+- identities: [release@acme.example]
+  images: [gcr.io/acme/app, gcr.io/acme/tools/*]
+- identities: [ci@acme.example, release@acme.example]
+  images: [gcr.io/acme/**]
+`
+
+func TestIdentityBook(t *testing.T) {
+	ctx := context.Background()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := WithECDSAKey(priv).Sign(ctx, []byte(testIdentityBook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64sig := base64.StdEncoding.EncodeToString(sig)
+	keys := []PublicKey{&ECDSAPublicKey{&priv.PublicKey}}
+
+	if _, err := LoadIdentityBook(ctx, []byte(testIdentityBook+"- identities: [mallory@evil.example]\n  images: ['**']\n"), b64sig, keys); err == nil {
+		t.Error("LoadIdentityBook() with a modified book, expected error")
+	}
+	book, err := LoadIdentityBook(ctx, []byte(testIdentityBook), b64sig, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		repo string
+		want []string
+	}{
+		{"gcr.io/acme/app", []string{"release@acme.example", "ci@acme.example"}},
+		{"gcr.io/acme/tools/lint", []string{"release@acme.example", "ci@acme.example"}},
+		{"gcr.io/acme/team/service", []string{"ci@acme.example", "release@acme.example"}},
+		{"gcr.io/acme", nil},
+		{"gcr.io/other/app", nil},
+	}
+	for _, tt := range tests {
+		repo, err := name.NewRepository(tt.repo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := book.Identities(repo); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Identities(%s) = %v, want %v", tt.repo, got, tt.want)
+		}
+	}
+}
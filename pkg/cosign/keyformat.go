@@ -0,0 +1,113 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// Private key encryption formats for GenerateEncryptedKeyPair.
+// LoadPrivateKey detects the format by itself, and additionally reads OpenSSH private keys.
+const (
+	// KeyFormatCosign is the ENCRYPTED COSIGN PRIVATE KEY PEM format.
+	KeyFormatCosign = "cosign"
+	// KeyFormatAge is a PKCS #8 key in an armored age file encrypted with a passphrase,
+	// which can be decrypted with `age -d`.
+	KeyFormatAge = "age"
+
+	ageBinaryHeader = "age-encryption.org/v1"
+	openSSHPemType  = "OPENSSH PRIVATE KEY"
+)
+
+func encryptPrivateKey(x509Encoded, password []byte, format string) ([]byte, error) {
+	switch format {
+	case KeyFormatCosign, "":
+		return encryptCosignKey(x509Encoded, password)
+	case KeyFormatAge:
+		return encryptAgeKey(x509Encoded, password)
+	default:
+		return nil, fmt.Errorf("unsupported key format: %s", format)
+	}
+}
+
+func encryptAgeKey(x509Encoded, password []byte) ([]byte, error) {
+	r, err := age.NewScryptRecipient(string(password))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	aw := armor.NewWriter(&buf)
+	w, err := age.Encrypt(aw, r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(x509Encoded); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if err := aw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func isAgeEncrypted(key []byte) bool {
+	key = bytes.TrimSpace(key)
+	return bytes.HasPrefix(key, []byte(armor.Header)) || bytes.HasPrefix(key, []byte(ageBinaryHeader))
+}
+
+func decryptAgeKey(key, password []byte) ([]byte, error) {
+	id, err := age.NewScryptIdentity(string(password))
+	if err != nil {
+		return nil, err
+	}
+	var r io.Reader = bytes.NewReader(key)
+	if bytes.HasPrefix(bytes.TrimSpace(key), []byte(armor.Header)) {
+		r = armor.NewReader(r)
+	}
+	dr, err := age.Decrypt(r, id)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(dr)
+}
+
+func isOpenSSHKey(key []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(key), []byte("-----BEGIN "+openSSHPemType+"-----"))
+}
+
+// parseOpenSSHKey parses an OpenSSH private key, as written by `ssh-keygen -t ecdsa -b 256`.
+// The passphrase is only used if the key is encrypted.
+func parseOpenSSHKey(key, password []byte) (crypto.PrivateKey, error) {
+	pk, err := ssh.ParseRawPrivateKey(key)
+	if _, ok := err.(*ssh.PassphraseMissingError); ok {
+		pk, err = ssh.ParseRawPrivateKeyWithPassphrase(key, password)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing openssh private key")
+	}
+	return pk, nil
+}
@@ -22,7 +22,9 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
+	"fmt"
 
 	"github.com/pkg/errors"
 	"github.com/theupdateframework/go-tuf/encrypted"
@@ -35,12 +37,40 @@ type Keys struct {
 	PublicBytes  []byte
 }
 
+// KeyAlgorithms are the algorithms GenerateKeyPairWithAlgorithm can create keys for.
+var KeyAlgorithms = []string{AlgorithmECDSAP256SHA256, AlgorithmECDSAP384SHA384, AlgorithmECDSAP521SHA512}
+
 func GeneratePrivateKey() (*ecdsa.PrivateKey, error) {
 	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 }
 
+// GeneratePrivateKeyWithAlgorithm generates an ECDSA key for one of KeyAlgorithms.
+func GeneratePrivateKeyWithAlgorithm(algorithm string) (*ecdsa.PrivateKey, error) {
+	switch algorithm {
+	case AlgorithmECDSAP256SHA256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmECDSAP384SHA384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case AlgorithmECDSAP521SHA512:
+		return ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm %q, must be one of %v", algorithm, KeyAlgorithms)
+	}
+}
+
 func GenerateKeyPair(pf PassFunc) (*Keys, error) {
-	priv, err := GeneratePrivateKey()
+	return GenerateEncryptedKeyPair(pf, KeyFormatCosign)
+}
+
+// GenerateEncryptedKeyPair generates a P-256 key pair, encrypting the private key in the given format.
+func GenerateEncryptedKeyPair(pf PassFunc, format string) (*Keys, error) {
+	return GenerateKeyPairWithAlgorithm(pf, format, AlgorithmECDSAP256SHA256)
+}
+
+// GenerateKeyPairWithAlgorithm generates a key pair for one of KeyAlgorithms, encrypting the
+// private key in the given format.
+func GenerateKeyPairWithAlgorithm(pf PassFunc, format, algorithm string) (*Keys, error) {
+	priv, err := GeneratePrivateKeyWithAlgorithm(algorithm)
 	if err != nil {
 		return nil, err
 	}
@@ -54,16 +84,10 @@ func GenerateKeyPair(pf PassFunc) (*Keys, error) {
 	if err != nil {
 		return nil, err
 	}
-	encBytes, err := encrypted.Encrypt(x509Encoded, password)
+	privBytes, err := encryptPrivateKey(x509Encoded, password, format)
 	if err != nil {
 		return nil, err
 	}
-	// store in PEM format
-
-	privBytes := pem.EncodeToMemory(&pem.Block{
-		Bytes: encBytes,
-		Type:  "ENCRYPTED COSIGN PRIVATE KEY",
-	})
 
 	// Now do the public key
 	pubBytes, err := KeyToPem(&priv.PublicKey)
@@ -77,6 +101,18 @@ func GenerateKeyPair(pf PassFunc) (*Keys, error) {
 	}, nil
 }
 
+func encryptCosignKey(x509Encoded, password []byte) ([]byte, error) {
+	encBytes, err := encrypted.Encrypt(x509Encoded, password)
+	if err != nil {
+		return nil, err
+	}
+	// store in PEM format
+	return pem.EncodeToMemory(&pem.Block{
+		Bytes: encBytes,
+		Type:  pemType,
+	}), nil
+}
+
 type PublicKeyProvider interface {
 	PublicKey(context.Context) (crypto.PublicKey, error)
 }
@@ -116,15 +152,22 @@ type ECDSAKey struct {
 	Key *ecdsa.PrivateKey
 }
 
-// Sign returns an ASN.1-encoded signature of the SHA-256 hash of the given payload.
-func (k *ECDSAKey) Sign(_ context.Context, payload []byte) (signature []byte, err error) {
-	h := sha256.Sum256(payload)
-	return ecdsa.SignASN1(rand.Reader, k.Key, h[:])
+// Sign returns an ASN.1-encoded signature of the hash of the given payload, see HashFunc.
+func (k *ECDSAKey) Sign(ctx context.Context, payload []byte) (signature []byte, err error) {
+	return k.SignDigest(ctx, hashBytes(HashFunc(k.ECDSAPublicKey.Key), payload))
+}
+
+// SignDigest returns an ASN.1-encoded signature of an already computed digest.
+func (k *ECDSAKey) SignDigest(_ context.Context, digest []byte) (signature []byte, err error) {
+	return ecdsa.SignASN1(rand.Reader, k.Key, digest)
+}
+
+func (k *ECDSAPublicKey) Verify(ctx context.Context, payload, signature []byte) error {
+	return k.VerifyDigest(ctx, hashBytes(HashFunc(k.Key), payload), signature)
 }
 
-func (k *ECDSAPublicKey) Verify(_ context.Context, payload, signature []byte) error {
-	h := sha256.Sum256(payload)
-	if !ecdsa.VerifyASN1(k.Key, h[:], signature) {
+func (k *ECDSAPublicKey) VerifyDigest(_ context.Context, digest, signature []byte) error {
+	if !ecdsa.VerifyASN1(k.Key, digest, signature) {
 		return errors.New("unable to verify signature")
 	}
 	return nil
@@ -134,9 +177,28 @@ func (k *ECDSAPublicKey) PublicKey(_ context.Context) (crypto.PublicKey, error)
 	return k.Key, nil
 }
 
+// KeyID returns the fingerprint of the key.
+func (k *ECDSAPublicKey) KeyID(_ context.Context) (string, error) {
+	return KeyFingerprint(k.Key)
+}
+
+func (k *ECDSAPublicKey) Algorithm() string {
+	return KeyAlgorithm(k.Key)
+}
+
 func WithECDSAKey(key *ecdsa.PrivateKey) *ECDSAKey {
 	return &ECDSAKey{
 		ECDSAPublicKey: ECDSAPublicKey{Key: &key.PublicKey},
 		Key:            key,
 	}
 }
+
+// KeyFingerprint returns the hex encoded SHA-256 digest of the DER encoded public key.
+func KeyFingerprint(pub crypto.PublicKey) (string, error) {
+	b, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:]), nil
+}
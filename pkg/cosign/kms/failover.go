@@ -0,0 +1,251 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// failoverCooldown is how long a replica that failed is skipped for, unless every replica has failed.
+const failoverCooldown = 30 * time.Second
+
+// ReplicaHealth is what a Failover knows about one of its replicas.
+type ReplicaHealth struct {
+	// Reference is the KMS reference of the replica.
+	Reference string
+	// Healthy is false while the replica is skipped after a failure.
+	Healthy bool
+	// LastError is the error of the replica's last failed call, and LastFailure when it happened.
+	LastError   error
+	LastFailure time.Time
+}
+
+type replica struct {
+	ref string
+	KMS
+	// checked is set once the replica's public key has been compared to the other replicas'.
+	checked     bool
+	lastErr     error
+	lastFailure time.Time
+}
+
+// Failover uses replicas of the same key, e.g. in several regions, in order. A replica that
+// fails is skipped for a while and the next one used instead, so signing and verifying keep
+// working through the outage of a provider or region.
+//
+// The replicas must all have the same key pair, which is checked before a replica is used.
+type Failover struct {
+	mu       sync.Mutex
+	replicas []*replica
+	// pub is the public key of the first replica that returned one.
+	pub crypto.PublicKey
+	now func() time.Time
+}
+
+// NewFailover returns a Failover of the keys refs refer to.
+func NewFailover(ctx context.Context, refs []string) (*Failover, error) {
+	f := &Failover{now: time.Now}
+	for _, ref := range refs {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			continue
+		}
+		k, err := get(ctx, ref)
+		if err != nil {
+			return nil, errors.Wrapf(err, "replica %s", ref)
+		}
+		f.replicas = append(f.replicas, &replica{ref: ref, KMS: k})
+	}
+	if len(f.replicas) == 0 {
+		return nil, errors.New("no KMS replicas given")
+	}
+	return f, nil
+}
+
+// Health reports the state of each replica, in the order they're tried.
+func (f *Failover) Health() []ReplicaHealth {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]ReplicaHealth, 0, len(f.replicas))
+	for _, r := range f.replicas {
+		out = append(out, ReplicaHealth{
+			Reference:   r.ref,
+			Healthy:     f.healthy(r),
+			LastError:   r.lastErr,
+			LastFailure: r.lastFailure,
+		})
+	}
+	return out
+}
+
+// Health returns the health of the replicas of k, if it is a Failover.
+func Health(k KMS) ([]ReplicaHealth, bool) {
+	if i, ok := k.(*instrumented); ok {
+		k = i.KMS
+	}
+	f, ok := k.(*Failover)
+	if !ok {
+		return nil, false
+	}
+	return f.Health(), true
+}
+
+func (f *Failover) healthy(r *replica) bool {
+	return r.lastFailure.IsZero() || f.now().Sub(r.lastFailure) >= failoverCooldown
+}
+
+// order returns the healthy replicas followed by the ones cooling down, so a call is still
+// attempted when every replica has failed recently.
+func (f *Failover) order() []*replica {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var healthy, cooling []*replica
+	for _, r := range f.replicas {
+		if f.healthy(r) {
+			healthy = append(healthy, r)
+		} else {
+			cooling = append(cooling, r)
+		}
+	}
+	return append(healthy, cooling...)
+}
+
+// do calls fn with each replica in turn until one succeeds.
+func (f *Failover) do(ctx context.Context, fn func(*replica) error) error {
+	var errs []string
+	for _, r := range f.order() {
+		err := f.check(ctx, r)
+		if err == nil {
+			err = fn(r)
+		}
+		if err == nil {
+			f.mu.Lock()
+			r.lastErr, r.lastFailure = nil, time.Time{}
+			f.mu.Unlock()
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		f.mu.Lock()
+		r.lastErr, r.lastFailure = err, f.now()
+		f.mu.Unlock()
+		errs = append(errs, fmt.Sprintf("%s: %v", r.ref, err))
+	}
+	return fmt.Errorf("all KMS replicas failed: %s", strings.Join(errs, "; "))
+}
+
+// check makes sure r has the same public key as the replicas used before it.
+func (f *Failover) check(ctx context.Context, r *replica) error {
+	f.mu.Lock()
+	checked := r.checked
+	f.mu.Unlock()
+	if checked {
+		return nil
+	}
+	pub, err := r.KMS.PublicKey(ctx)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.pub == nil {
+		f.pub = pub
+	} else if k, ok := f.pub.(interface{ Equal(crypto.PublicKey) bool }); !ok || !k.Equal(pub) {
+		return errors.New("replica has a different public key than the other replicas")
+	}
+	r.checked = true
+	return nil
+}
+
+// CreateKey isn't supported, the replicas have to be created with the same key pair by their provider.
+func (f *Failover) CreateKey(context.Context) (*ecdsa.PublicKey, error) {
+	return nil, errors.New("keys can't be created in several KMS replicas at once, create them one at a time")
+}
+
+// RotateKey isn't supported, rotating one replica would leave it with a different key than the others.
+func (f *Failover) RotateKey(context.Context) (*ecdsa.PublicKey, error) {
+	return nil, errors.New("keys can't be rotated in several KMS replicas at once")
+}
+
+func (f *Failover) Sign(ctx context.Context, payload []byte) (signature []byte, err error) {
+	err = f.do(ctx, func(r *replica) (err error) {
+		signature, err = r.Sign(ctx, payload)
+		return err
+	})
+	return signature, err
+}
+
+func (f *Failover) SignDigest(ctx context.Context, digest []byte) (signature []byte, err error) {
+	err = f.do(ctx, func(r *replica) (err error) {
+		signature, err = r.SignDigest(ctx, digest)
+		return err
+	})
+	return signature, err
+}
+
+// PublicKey returns the public key the replicas share.
+func (f *Failover) PublicKey(ctx context.Context) (crypto.PublicKey, error) {
+	if err := f.do(ctx, func(*replica) error { return nil }); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pub, nil
+}
+
+// Verify checks the signature with the replicas' public key, only fetching it needs a replica.
+func (f *Failover) Verify(ctx context.Context, payload, signature []byte) error {
+	h := sha256.Sum256(payload)
+	return f.VerifyDigest(ctx, h[:], signature)
+}
+
+func (f *Failover) VerifyDigest(ctx context.Context, digest, signature []byte) error {
+	pub, err := f.PublicKey(ctx)
+	if err != nil {
+		return errors.Wrap(err, "retrieving public key")
+	}
+	k, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("unknown public key type: %T", pub)
+	}
+	if !ecdsa.VerifyASN1(k, digest, signature) {
+		return errors.New("unable to verify signature")
+	}
+	return nil
+}
+
+// KeyID returns the key ID of the first healthy replica, provider key IDs like GCP's key version
+// names differ between replicas.
+func (f *Failover) KeyID(ctx context.Context) (id string, err error) {
+	err = f.do(ctx, func(r *replica) (err error) {
+		id, err = r.KeyID(ctx)
+		return err
+	})
+	return id, err
+}
+
+func (f *Failover) Algorithm() string {
+	return f.replicas[0].Algorithm()
+}
@@ -0,0 +1,140 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeKMS signs with priv, or fails with err if it's set.
+type fakeKMS struct {
+	priv  *ecdsa.PrivateKey
+	id    string
+	err   error
+	signs int
+}
+
+func (k *fakeKMS) CreateKey(context.Context) (*ecdsa.PublicKey, error) { return nil, k.err }
+func (k *fakeKMS) RotateKey(context.Context) (*ecdsa.PublicKey, error) { return nil, k.err }
+
+func (k *fakeKMS) Sign(ctx context.Context, payload []byte) ([]byte, error) {
+	h := sha256.Sum256(payload)
+	return k.SignDigest(ctx, h[:])
+}
+
+func (k *fakeKMS) SignDigest(_ context.Context, digest []byte) ([]byte, error) {
+	if k.err != nil {
+		return nil, k.err
+	}
+	k.signs++
+	return ecdsa.SignASN1(rand.Reader, k.priv, digest)
+}
+
+func (k *fakeKMS) PublicKey(context.Context) (crypto.PublicKey, error) {
+	if k.err != nil {
+		return nil, k.err
+	}
+	return &k.priv.PublicKey, nil
+}
+
+func (k *fakeKMS) Verify(context.Context, []byte, []byte) error       { return k.err }
+func (k *fakeKMS) VerifyDigest(context.Context, []byte, []byte) error { return k.err }
+func (k *fakeKMS) KeyID(context.Context) (string, error)              { return k.id, k.err }
+func (k *fakeKMS) Algorithm() string                                  { return "ecdsa-p256-sha256" }
+
+func testFailover(t *testing.T, replicas ...*fakeKMS) (*Failover, *time.Time) {
+	t.Helper()
+	now := time.Now()
+	f := &Failover{now: func() time.Time { return now }}
+	for _, k := range replicas {
+		f.replicas = append(f.replicas, &replica{ref: k.id, KMS: k})
+	}
+	return f, &now
+}
+
+func TestFailover(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	east := &fakeKMS{priv: priv, id: "us-east1", err: errors.New("unavailable")}
+	west := &fakeKMS{priv: priv, id: "us-west1"}
+	f, now := testFailover(t, east, west)
+	ctx := context.Background()
+
+	sig, err := f.Sign(ctx, []byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Verify(ctx, []byte("payload"), sig); err != nil {
+		t.Error(err)
+	}
+	if west.signs != 1 {
+		t.Errorf("us-west1 signed %d times, want 1", west.signs)
+	}
+	health, ok := Health(&instrumented{KMS: f})
+	if !ok || len(health) != 2 || health[0].Healthy || health[0].LastError == nil || !health[1].Healthy {
+		t.Errorf("Health() = %+v", health)
+	}
+
+	// us-east1 is skipped while it cools down, even once it's back.
+	east.err = nil
+	if id, err := f.KeyID(ctx); err != nil || id != "us-west1" {
+		t.Errorf("KeyID() = %s, %v, want us-west1", id, err)
+	}
+	*now = now.Add(failoverCooldown)
+	if id, err := f.KeyID(ctx); err != nil || id != "us-east1" {
+		t.Errorf("KeyID() after the cooldown = %s, %v, want us-east1", id, err)
+	}
+
+	east.err, west.err = errors.New("unavailable"), errors.New("unavailable")
+	if _, err := f.Sign(ctx, []byte("payload")); err == nil {
+		t.Error("Sign() with every replica failing, expected error")
+	}
+}
+
+func TestFailoverDifferentKeys(t *testing.T) {
+	priv1, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv2, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	east := &fakeKMS{priv: priv1, id: "us-east1"}
+	west := &fakeKMS{priv: priv2, id: "us-west1"}
+	f, _ := testFailover(t, east, west)
+	ctx := context.Background()
+
+	if _, err := f.PublicKey(ctx); err != nil {
+		t.Fatal(err)
+	}
+	east.err = errors.New("unavailable")
+	if _, err := f.Sign(ctx, []byte("payload")); err == nil {
+		t.Error("Sign() failing over to a replica with a different key, expected error")
+	}
+	if west.signs != 0 {
+		t.Error("replica with a different key was used to sign")
+	}
+}
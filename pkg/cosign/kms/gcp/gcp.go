@@ -24,11 +24,15 @@ import (
 	"fmt"
 	"hash/crc32"
 	"regexp"
+	"sync"
+	"time"
 
 	kms "cloud.google.com/go/kms/apiv1"
 	"github.com/pkg/errors"
 	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
 	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/sigstore/cosign/pkg/cosign/log"
 )
 
 type KMS struct {
@@ -38,14 +42,32 @@ type KMS struct {
 	locationID    string
 	keyRing       string
 	key           string
+	// version is the pinned key version, empty or LatestVersion to use the newest enabled one.
+	version string
+
+	mu sync.Mutex
+	// resolved is the key version found for an unpinned reference, so every signature made by
+	// one KMS, and the KeyID recorded with them, are of the same version.
+	resolved string
 }
 
 var (
-	ErrKMSReference = errors.New("kms specification should be in the format gcpkms://projects/[PROJECT_ID]/locations/[LOCATION]/keyRings/[KEY_RING]/cryptoKeys/[KEY]")
+	ErrKMSReference = errors.New("kms specification should be in the format gcpkms://projects/[PROJECT_ID]/locations/[LOCATION]/keyRings/[KEY_RING]/cryptoKeys/[KEY][/cryptoKeyVersions/[VERSION|latest]]")
 
-	re = regexp.MustCompile(`^gcpkms://projects/([^/]+)/locations/([^/]+)/keyRings/([^/]+)/cryptoKeys/([^/]+)$`)
+	re = regexp.MustCompile(`^gcpkms://projects/([^/]+)/locations/([^/]+)/keyRings/([^/]+)/cryptoKeys/([^/]+)(?:/cryptoKeyVersions/([0-9]+|latest))?$`)
 )
 
+// KeyVersionAnnotation is the payload annotation "cosign sign" records the gcpkms:// reference of
+// the key version that signed in, so the signature can be verified with that exact version.
+const KeyVersionAnnotation = "dev.sigstore.cosign/gcpkms-key-version"
+
+// LatestVersion can be given as the key version to use the newest enabled one, which is also
+// what references without a version get.
+const LatestVersion = "latest"
+
+// versionPollInterval is how often RotateKey checks whether a new key version has been generated.
+const versionPollInterval = time.Second
+
 // schemes for various KMS services are copied from https://github.com/google/go-cloud/tree/master/secrets
 const ReferenceScheme = "gcpkms://"
 
@@ -56,18 +78,20 @@ func ValidReference(ref string) error {
 	return nil
 }
 
-func parseReference(resourceID string) (projectID, locationID, keyRing, keyName string, err error) {
+func parseReference(resourceID string) (projectID, locationID, keyRing, keyName, version string, err error) {
 	v := re.FindStringSubmatch(resourceID)
-	if len(v) != 5 {
+	if len(v) != 6 {
 		err = errors.Errorf("invalid gcpkms format %q", resourceID)
 		return
 	}
-	projectID, locationID, keyRing, keyName = v[1], v[2], v[3], v[4]
+	projectID, locationID, keyRing, keyName, version = v[1], v[2], v[3], v[4], v[5]
 	return
 }
 
+// NewGCP returns the key keyResourceID refers to. References ending in /cryptoKeyVersions/N sign
+// and verify with that key version only, others with the newest enabled version.
 func NewGCP(ctx context.Context, keyResourceID string) (*KMS, error) {
-	projectID, locationID, keyRing, keyName, err := parseReference(keyResourceID)
+	projectID, locationID, keyRing, keyName, version, err := parseReference(keyResourceID)
 	if err != nil {
 		return nil, err
 	}
@@ -83,22 +107,24 @@ func NewGCP(ctx context.Context, keyResourceID string) (*KMS, error) {
 		locationID:    locationID,
 		keyRing:       keyRing,
 		key:           keyName,
+		version:       version,
 	}, nil
 }
 
 func (g *KMS) Sign(ctx context.Context, payload []byte) (signature []byte, err error) {
 	// Calculate the digest of the message.
-	digest := sha256.New()
-	_, err = digest.Write(payload)
-	if err != nil {
-		return
-	}
+	digest := sha256.Sum256(payload)
+	return g.SignDigest(ctx, digest[:])
+}
+
+// SignDigest signs an already computed SHA-256 digest with the key in KMS.
+func (g *KMS) SignDigest(ctx context.Context, digest []byte) (signature []byte, err error) {
 	// Optional but recommended: Compute digest's CRC32C.
 	crc32c := func(data []byte) uint32 {
 		t := crc32.MakeTable(crc32.Castagnoli)
 		return crc32.Checksum(data, t)
 	}
-	digestCRC32C := crc32c(digest.Sum(nil))
+	digestCRC32C := crc32c(digest)
 
 	name, err := g.keyVersionName(ctx)
 	if err != nil {
@@ -108,7 +134,7 @@ func (g *KMS) Sign(ctx context.Context, payload []byte) (signature []byte, err e
 		Name: name,
 		Digest: &kmspb.Digest{
 			Digest: &kmspb.Digest_Sha256{
-				Sha256: digest.Sum(nil),
+				Sha256: digest,
 			},
 		},
 		DigestCrc32C: wrapperspb.Int64(int64(digestCRC32C)),
@@ -155,6 +181,17 @@ func (g *KMS) PublicKey(ctx context.Context) (crypto.PublicKey, error) {
 	return publicKey, nil
 }
 
+// KeyID returns the resource name of the key version used for signing,
+// which changes when the key is rotated.
+func (g *KMS) KeyID(ctx context.Context) (string, error) {
+	return g.keyVersionName(ctx)
+}
+
+// Algorithm returns the signature algorithm, keys are always created as EC_SIGN_P256_SHA256.
+func (g *KMS) Algorithm() string {
+	return "ecdsa-p256-sha256"
+}
+
 func (g *KMS) ECDSAPublicKey(ctx context.Context) (*ecdsa.PublicKey, error) {
 	k, err := g.PublicKey(ctx)
 	if err != nil {
@@ -169,33 +206,56 @@ func (g *KMS) ECDSAPublicKey(ctx context.Context) (*ecdsa.PublicKey, error) {
 	return pub, nil
 }
 
-// keyVersionName returns the first key version found for a key in KMS
-// TODO: is there a better way to do this?
+// keyName returns the resource name of the crypto key.
+func (g *KMS) keyName() string {
+	return fmt.Sprintf("projects/%s/locations/%s/keyRings/%s/cryptoKeys/%s", g.projectID, g.locationID, g.keyRing, g.key)
+}
+
+// pinned reports whether the reference named a key version.
+func (g *KMS) pinned() bool {
+	return g.version != "" && g.version != LatestVersion
+}
+
+// keyVersionName returns the pinned key version, or else the newest enabled key version of the
+// key in KMS, so a version created by RotateKey is signed with from then on.
 func (g *KMS) keyVersionName(ctx context.Context) (string, error) {
+	if g.pinned() {
+		return g.keyName() + "/cryptoKeyVersions/" + g.version, nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resolved != "" {
+		return g.resolved, nil
+	}
 	req := &kmspb.ListCryptoKeyVersionsRequest{
-		Parent: fmt.Sprintf("projects/%s/locations/%s/keyRings/%s/cryptoKeys/%s", g.projectID, g.locationID, g.keyRing, g.key),
+		Parent: g.keyName(),
 	}
 	iterator := g.client.ListCryptoKeyVersions(ctx, req)
 
-	// pick the first key version that is enabled
-	var name string
+	var newest *kmspb.CryptoKeyVersion
 	for {
 		kv, err := iterator.Next()
 		if err != nil {
 			break
 		}
-		if kv.State == kmspb.CryptoKeyVersion_ENABLED {
-			name = kv.GetName()
-			break
+		if kv.State != kmspb.CryptoKeyVersion_ENABLED {
+			continue
+		}
+		if newest == nil || kv.GetCreateTime().AsTime().After(newest.GetCreateTime().AsTime()) {
+			newest = kv
 		}
 	}
-	if name == "" {
+	if newest == nil {
 		return "", errors.New("unable to find an enabled key version in GCP KMS, generate one via `cosign generate-key-pair`")
 	}
-	return name, nil
+	g.resolved = newest.GetName()
+	return g.resolved, nil
 }
 
 func (g *KMS) CreateKey(ctx context.Context) (*ecdsa.PublicKey, error) {
+	if g.pinned() {
+		return nil, errors.New("keys are created with their first version, leave the key version out of the reference")
+	}
 	if err := g.createKeyRing(ctx); err != nil {
 		return nil, errors.Wrap(err, "creating key ring")
 	}
@@ -207,7 +267,7 @@ func (g *KMS) createKeyRing(ctx context.Context) error {
 		Name: fmt.Sprintf("projects/%s/locations/%s/keyRings/%s", g.projectID, g.locationID, g.keyRing),
 	}
 	if result, err := g.client.GetKeyRing(ctx, getKeyRingRequest); err == nil {
-		fmt.Printf("Key ring %s already exists in GCP KMS, moving on to creating key.\n", result.GetName())
+		log.Infof("Key ring %s already exists in GCP KMS, moving on to creating key.", result.GetName())
 		// key ring already exists, no need to create
 		return err
 	}
@@ -217,17 +277,17 @@ func (g *KMS) createKeyRing(ctx context.Context) error {
 		KeyRingId: g.keyRing,
 	}
 	result, err := g.client.CreateKeyRing(ctx, createKeyRingRequest)
-	fmt.Printf("Created key ring %s in GCP KMS.\n", result.GetName())
+	log.Infof("Created key ring %s in GCP KMS.", result.GetName())
 	return err
 }
 
 func (g *KMS) createKey(ctx context.Context) (*ecdsa.PublicKey, error) {
-	name := fmt.Sprintf("projects/%s/locations/%s/keyRings/%s/cryptoKeys/%s", g.projectID, g.locationID, g.keyRing, g.key)
+	name := g.keyName()
 	getKeyRequest := &kmspb.GetCryptoKeyRequest{
 		Name: name,
 	}
 	if result, err := g.client.GetCryptoKey(ctx, getKeyRequest); err == nil {
-		fmt.Printf("Key %s already exists in GCP KMS, skipping creation.\n", result.GetName())
+		log.Infof("Key %s already exists in GCP KMS, skipping creation.", result.GetName())
 		pub, err := g.ECDSAPublicKey(ctx)
 		if err != nil {
 			return nil, errors.Wrap(err, "retrieving public key")
@@ -248,7 +308,7 @@ func (g *KMS) createKey(ctx context.Context) (*ecdsa.PublicKey, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "creating crypto key")
 	}
-	fmt.Printf("Created key %s in GCP KMS\n", result.GetName())
+	log.Infof("Created key %s in GCP KMS", result.GetName())
 	pub, err := g.ECDSAPublicKey(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "retrieving public key")
@@ -256,15 +316,54 @@ func (g *KMS) createKey(ctx context.Context) (*ecdsa.PublicKey, error) {
 	return pub, nil
 }
 
+// RotateKey creates a new version of the key, which is signed with from then on. Previous versions
+// are left enabled, so signatures they made can still be verified with their public keys.
+func (g *KMS) RotateKey(ctx context.Context) (*ecdsa.PublicKey, error) {
+	if g.pinned() {
+		return nil, errors.New("a pinned key version can't be rotated, leave the key version out of the reference")
+	}
+	kv, err := g.client.CreateCryptoKeyVersion(ctx, &kmspb.CreateCryptoKeyVersionRequest{
+		Parent:           g.keyName(),
+		CryptoKeyVersion: &kmspb.CryptoKeyVersion{},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating crypto key version")
+	}
+	// Asymmetric key versions are generated asynchronously, and can't sign until they're enabled.
+	for kv.State == kmspb.CryptoKeyVersion_PENDING_GENERATION {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(versionPollInterval):
+		}
+		if kv, err = g.client.GetCryptoKeyVersion(ctx, &kmspb.GetCryptoKeyVersionRequest{Name: kv.GetName()}); err != nil {
+			return nil, errors.Wrap(err, "getting crypto key version")
+		}
+	}
+	if kv.State != kmspb.CryptoKeyVersion_ENABLED {
+		return nil, fmt.Errorf("new key version %s is %s, not enabled", kv.GetName(), kv.State)
+	}
+	log.Infof("Created key version %s in GCP KMS", kv.GetName())
+	g.mu.Lock()
+	g.resolved = kv.GetName()
+	g.mu.Unlock()
+	return g.ECDSAPublicKey(ctx)
+}
+
 func (g *KMS) Verify(ctx context.Context, payload, signature []byte) error {
+	h := sha256.Sum256(payload)
+	return g.VerifyDigest(ctx, h[:], signature)
+}
+
+// VerifyDigest verifies the signature over an already computed SHA-256 digest.
+func (g *KMS) VerifyDigest(ctx context.Context, digest, signature []byte) error {
 	pub, err := g.PublicKey(ctx)
 	if err != nil {
 		return errors.Wrap(err, "retrieving public key")
 	}
 	switch k := pub.(type) {
 	case *ecdsa.PublicKey:
-		h := sha256.Sum256(payload)
-		if !ecdsa.VerifyASN1(k, h[:], signature) {
+		if !ecdsa.VerifyASN1(k, digest, signature) {
 			return errors.New("unable to verify signature")
 		}
 	default:
@@ -23,6 +23,7 @@ func TestParseReference(t *testing.T) {
 		wantLocationID string
 		wantKeyRing    string
 		wantKeyName    string
+		wantVersion    string
 		wantErr        bool
 	}{
 		{
@@ -33,6 +34,26 @@ func TestParseReference(t *testing.T) {
 			wantKeyName:    "kk",
 			wantErr:        false,
 		},
+		{
+			in:             "gcpkms://projects/pp/locations/ll/keyRings/rr/cryptoKeys/kk/cryptoKeyVersions/3",
+			wantProjectID:  "pp",
+			wantLocationID: "ll",
+			wantKeyRing:    "rr",
+			wantKeyName:    "kk",
+			wantVersion:    "3",
+		},
+		{
+			in:             "gcpkms://projects/pp/locations/ll/keyRings/rr/cryptoKeys/kk/cryptoKeyVersions/latest",
+			wantProjectID:  "pp",
+			wantLocationID: "ll",
+			wantKeyRing:    "rr",
+			wantKeyName:    "kk",
+			wantVersion:    LatestVersion,
+		},
+		{
+			in:      "gcpkms://projects/pp/locations/ll/keyRings/rr/cryptoKeys/kk/cryptoKeyVersions/first",
+			wantErr: true,
+		},
 		{
 			in:      "gcpkms://projects/p1/p2/locations/l1/l2/keyRings/r1/r2/cryptoKeys/k1/k2",
 			wantErr: true,
@@ -48,7 +69,7 @@ func TestParseReference(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.in, func(t *testing.T) {
-			gotProjectID, gotLocationID, gotKeyRing, gotKeyName, err := parseReference(tt.in)
+			gotProjectID, gotLocationID, gotKeyRing, gotKeyName, gotVersion, err := parseReference(tt.in)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseReference() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -65,6 +86,9 @@ func TestParseReference(t *testing.T) {
 			if gotKeyName != tt.wantKeyName {
 				t.Errorf("parseReference() gotKeyName = %v, want %v", gotKeyName, tt.wantKeyName)
 			}
+			if gotVersion != tt.wantVersion {
+				t.Errorf("parseReference() gotVersion = %v, want %v", gotVersion, tt.wantVersion)
+			}
 		})
 	}
 }
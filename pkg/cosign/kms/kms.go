@@ -19,8 +19,12 @@ import (
 	"crypto"
 	"crypto/ecdsa"
 	"fmt"
+	"strings"
 
 	"github.com/sigstore/cosign/pkg/cosign/kms/gcp"
+	"github.com/sigstore/cosign/pkg/cosign/kms/plugin"
+	"github.com/sigstore/cosign/pkg/cosign/kms/tpm"
+	"github.com/sigstore/cosign/pkg/cosign/telemetry"
 )
 
 type KMS interface {
@@ -28,20 +32,95 @@ type KMS interface {
 	// with the ECDSA algorithm on the P-256 Curve with a SHA-256 digest
 	CreateKey(context.Context) (*ecdsa.PublicKey, error)
 
+	// RotateKey replaces the key used for signing with a newly created one,
+	// returning its public key
+	RotateKey(context.Context) (*ecdsa.PublicKey, error)
+
 	// Sign is responsible for signing an image via the keys
 	// stored in KMS
 	Sign(ctx context.Context, payload []byte) (signature []byte, err error)
 
+	// SignDigest is responsible for signing a precomputed SHA-256 digest
+	// via the keys stored in KMS
+	SignDigest(ctx context.Context, digest []byte) (signature []byte, err error)
+
 	// PublicKey returns the public key stored in the KMS
 	PublicKey(ctx context.Context) (crypto.PublicKey, error)
 
 	// Verify the signature of the payload.
 	Verify(ctx context.Context, payload, signature []byte) error
+
+	// VerifyDigest verifies the signature over a precomputed SHA-256 digest.
+	VerifyDigest(ctx context.Context, digest, signature []byte) error
+
+	// KeyID identifies the key version used for signing
+	KeyID(ctx context.Context) (string, error)
+
+	// Algorithm names the signature algorithm of the key
+	Algorithm() string
 }
 
+// Get returns the KMS key keyResourceID refers to. A comma separated list of references are replicas
+// of the same key, signed with by a Failover.
 func Get(ctx context.Context, keyResourceID string) (KMS, error) {
-	if err := gcp.ValidReference(keyResourceID); err != nil {
-		return nil, fmt.Errorf("could not parse kms reference (only GCP supported for now): %w", err)
+	if strings.Contains(keyResourceID, ",") {
+		f, err := NewFailover(ctx, strings.Split(keyResourceID, ","))
+		if err != nil {
+			return nil, err
+		}
+		return &instrumented{KMS: f}, nil
+	}
+	k, err := get(ctx, keyResourceID)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumented{KMS: k}, nil
+}
+
+func get(ctx context.Context, keyResourceID string) (KMS, error) {
+	var (
+		k   KMS
+		err error
+	)
+	switch {
+	case strings.HasPrefix(keyResourceID, plugin.ReferenceScheme):
+		k, err = plugin.NewPlugin(ctx, keyResourceID)
+	case strings.HasPrefix(keyResourceID, tpm.ReferenceScheme):
+		if err := tpm.ValidReference(keyResourceID); err != nil {
+			return nil, fmt.Errorf("could not parse tpm reference: %w", err)
+		}
+		k, err = tpm.NewTPM(ctx, keyResourceID)
+	default:
+		if err := gcp.ValidReference(keyResourceID); err != nil {
+			return nil, fmt.Errorf("could not parse kms reference (only GCP, TPM and plugins supported for now): %w", err)
+		}
+		k, err = gcp.NewGCP(ctx, keyResourceID)
 	}
-	return gcp.NewGCP(ctx, keyResourceID)
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// instrumented reports the calls that reach the KMS to the telemetry hook.
+type instrumented struct {
+	KMS
+}
+
+func (k *instrumented) Sign(ctx context.Context, payload []byte) (_ []byte, err error) {
+	ctx, end := telemetry.Start(ctx, telemetry.OpKMSSign)
+	defer func() { end(err) }()
+	return k.KMS.Sign(ctx, payload)
+}
+
+func (k *instrumented) SignDigest(ctx context.Context, digest []byte) (_ []byte, err error) {
+	ctx, end := telemetry.Start(ctx, telemetry.OpKMSSign)
+	defer func() { end(err) }()
+	return k.KMS.SignDigest(ctx, digest)
+}
+
+func (k *instrumented) PublicKey(ctx context.Context) (_ crypto.PublicKey, err error) {
+	ctx, end := telemetry.Start(ctx, telemetry.OpKMSPublicKey)
+	defer func() { end(err) }()
+	return k.KMS.PublicKey(ctx)
 }
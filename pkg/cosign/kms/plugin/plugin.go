@@ -0,0 +1,313 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package plugin signs with external binaries, so cosign can use signing services and HSMs it has
+// no support for built in. cosign still makes the payloads and uploads the signatures, the plugin
+// only signs digests.
+//
+// A plugin is run once for every request, with the request as JSON on stdin, and must print its
+// Response as JSON on stdout. Anything it prints to stderr is passed through to the user.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ReferenceScheme starts the references of plugins, plugin://NAME runs cosign-signer-NAME from
+	// $PATH, and plugin:///PATH the binary at PATH.
+	ReferenceScheme = "plugin://"
+	// ProtocolVersion is sent with every request, plugins should fail requests of versions they
+	// don't know.
+	ProtocolVersion = "1"
+	// BinaryPrefix is prepended to plugin names to find their binary in $PATH.
+	BinaryPrefix = "cosign-signer-"
+)
+
+// Methods of the protocol.
+const (
+	// MethodPublicKey asks for the public key, Response.PublicKey, and optionally its KeyID and Algorithm.
+	MethodPublicKey = "publicKey"
+	// MethodSign asks for an ASN.1 ECDSA signature of Request.Digest, Response.Signature.
+	MethodSign = "sign"
+	// MethodCreateKey asks the plugin to create its key, for "cosign generate-key-pair". Plugins
+	// that can't should fail it.
+	MethodCreateKey = "createKey"
+	// MethodRotateKey asks the plugin to replace its key with a new one, for "cosign rotate-key".
+	// Plugins that can't should fail it.
+	MethodRotateKey = "rotateKey"
+)
+
+// Request is sent to a plugin on stdin.
+type Request struct {
+	ProtocolVersion string `json:"protocolVersion"`
+	Method          string `json:"method"`
+	// Digest and HashAlgorithm are set for MethodSign. The digest is of the payload, which is also
+	// sent so plugins can log or check what they sign.
+	Digest        []byte `json:"digest,omitempty"`
+	HashAlgorithm string `json:"hashAlgorithm,omitempty"`
+	Payload       []byte `json:"payload,omitempty"`
+}
+
+// Response is printed by a plugin on stdout. A plugin that fails sets Error and exits non-zero.
+type Response struct {
+	PublicKey string `json:"publicKey,omitempty"`
+	KeyID     string `json:"keyID,omitempty"`
+	Algorithm string `json:"algorithm,omitempty"`
+	Signature []byte `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+var ErrKMSReference = errors.New("plugin specification should be in the format plugin://NAME or plugin:///PATH")
+
+// maxResponseSize bounds how much of a plugin's output is read.
+const maxResponseSize = 1 << 20
+
+// defaultAlgorithm is what plugins sign with unless they say otherwise, the only key type cosign
+// verifies signatures of.
+const defaultAlgorithm = "ecdsa-p256-sha256"
+
+// ValidReference checks that ref names a plugin.
+func ValidReference(ref string) error {
+	name := strings.TrimPrefix(ref, ReferenceScheme)
+	if !strings.HasPrefix(ref, ReferenceScheme) || name == "" || (!strings.HasPrefix(name, "/") && strings.ContainsAny(name, `/\`)) {
+		return ErrKMSReference
+	}
+	return nil
+}
+
+// KMS signs by running a plugin.
+type KMS struct {
+	path string
+
+	mu   sync.Mutex
+	info *Response
+}
+
+// NewPlugin returns the plugin ref names, failing if its binary can't be found.
+func NewPlugin(_ context.Context, ref string) (*KMS, error) {
+	if err := ValidReference(ref); err != nil {
+		return nil, err
+	}
+	name := strings.TrimPrefix(ref, ReferenceScheme)
+	if strings.HasPrefix(name, "/") {
+		return &KMS{path: name}, nil
+	}
+	path, err := exec.LookPath(BinaryPrefix + name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "finding signer plugin %q", name)
+	}
+	return &KMS{path: path}, nil
+}
+
+// call runs the plugin with req, returning its response.
+func (k *KMS) call(ctx context.Context, req Request) (*Response, error) {
+	req.ProtocolVersion = ProtocolVersion
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, k.path) // nolint: gosec
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &limitedBuffer{Buffer: &out, left: maxResponseSize}
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+
+	resp := &Response{}
+	if err := json.Unmarshal(out.Bytes(), resp); err != nil {
+		if runErr != nil {
+			return nil, errors.Wrapf(runErr, "running signer plugin %s", k.path)
+		}
+		return nil, errors.Wrapf(err, "parsing the response of signer plugin %s", k.path)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("signer plugin %s: %s: %s", k.path, req.Method, resp.Error)
+	}
+	if runErr != nil {
+		return nil, errors.Wrapf(runErr, "running signer plugin %s", k.path)
+	}
+	return resp, nil
+}
+
+// describe returns the public key and its details, asking the plugin only once.
+func (k *KMS) describe(ctx context.Context) (*Response, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.info != nil {
+		return k.info, nil
+	}
+	resp, err := k.call(ctx, Request{Method: MethodPublicKey})
+	if err != nil {
+		return nil, err
+	}
+	if resp.PublicKey == "" {
+		return nil, fmt.Errorf("signer plugin %s returned no public key", k.path)
+	}
+	k.info = resp
+	return resp, nil
+}
+
+// CreateKey asks the plugin to create its key, and returns the public key.
+func (k *KMS) CreateKey(ctx context.Context) (*ecdsa.PublicKey, error) {
+	resp, err := k.call(ctx, Request{Method: MethodCreateKey})
+	if err != nil {
+		return nil, err
+	}
+	return parsePublicKey(resp.PublicKey)
+}
+
+// RotateKey asks the plugin to replace its key, and returns the new public key.
+func (k *KMS) RotateKey(ctx context.Context) (*ecdsa.PublicKey, error) {
+	resp, err := k.call(ctx, Request{Method: MethodRotateKey})
+	if err != nil {
+		return nil, err
+	}
+	pub, err := parsePublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	// The key described before is no longer the one signing.
+	k.mu.Lock()
+	k.info = nil
+	k.mu.Unlock()
+	return pub, nil
+}
+
+func (k *KMS) Sign(ctx context.Context, payload []byte) (signature []byte, err error) {
+	digest := sha256.Sum256(payload)
+	return k.signDigest(ctx, digest[:], payload)
+}
+
+// SignDigest has the plugin sign an already computed SHA-256 digest.
+func (k *KMS) SignDigest(ctx context.Context, digest []byte) (signature []byte, err error) {
+	return k.signDigest(ctx, digest, nil)
+}
+
+func (k *KMS) signDigest(ctx context.Context, digest, payload []byte) ([]byte, error) {
+	resp, err := k.call(ctx, Request{Method: MethodSign, Digest: digest, HashAlgorithm: "sha256", Payload: payload})
+	if err != nil {
+		return nil, err
+	}
+	// A signature the key doesn't verify would only be noticed once it has been uploaded.
+	if err := k.VerifyDigest(ctx, digest, resp.Signature); err != nil {
+		return nil, errors.Wrapf(err, "signer plugin %s returned a bad signature", k.path)
+	}
+	return resp.Signature, nil
+}
+
+func (k *KMS) PublicKey(ctx context.Context) (crypto.PublicKey, error) {
+	return k.ECDSAPublicKey(ctx)
+}
+
+func (k *KMS) ECDSAPublicKey(ctx context.Context) (*ecdsa.PublicKey, error) {
+	info, err := k.describe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return parsePublicKey(info.PublicKey)
+}
+
+func (k *KMS) Verify(ctx context.Context, payload, signature []byte) error {
+	h := sha256.Sum256(payload)
+	return k.VerifyDigest(ctx, h[:], signature)
+}
+
+// VerifyDigest verifies the signature over an already computed SHA-256 digest.
+func (k *KMS) VerifyDigest(ctx context.Context, digest, signature []byte) error {
+	pub, err := k.ECDSAPublicKey(ctx)
+	if err != nil {
+		return errors.Wrap(err, "retrieving public key")
+	}
+	if !ecdsa.VerifyASN1(pub, digest, signature) {
+		return errors.New("unable to verify signature")
+	}
+	return nil
+}
+
+// KeyID returns the key ID the plugin gave, or the SHA-256 of the public key if it gave none.
+func (k *KMS) KeyID(ctx context.Context) (string, error) {
+	info, err := k.describe(ctx)
+	if err != nil {
+		return "", err
+	}
+	if info.KeyID != "" {
+		return info.KeyID, nil
+	}
+	pub, err := parsePublicKey(info.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Algorithm returns the signature algorithm the plugin gave, by default ECDSA P-256 with SHA-256.
+func (k *KMS) Algorithm() string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.info != nil && k.info.Algorithm != "" {
+		return k.info.Algorithm
+	}
+	return defaultAlgorithm
+}
+
+func parsePublicKey(pemKey string) (*ecdsa.PublicKey, error) {
+	p, _ := pem.Decode([]byte(pemKey))
+	if p == nil {
+		return nil, errors.New("signer plugin returned a public key that isn't PEM encoded")
+	}
+	k, err := x509.ParsePKIXPublicKey(p.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing the public key of the signer plugin")
+	}
+	pub, ok := k.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key was not ECDSA: %#v", k)
+	}
+	return pub, nil
+}
+
+// limitedBuffer fails writes past left bytes, so a plugin can't make cosign buffer unbounded output.
+type limitedBuffer struct {
+	*bytes.Buffer
+	left int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if len(p) > b.left {
+		return 0, errors.New("signer plugin response is too large")
+	}
+	b.left -= len(p)
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,185 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The test binary doubles as a plugin signing with the key in $TEST_PLUGIN_KEY.
+func TestMain(m *testing.M) {
+	if der := os.Getenv("TEST_PLUGIN_KEY"); der != "" {
+		os.Exit(runTestPlugin(der))
+	}
+	os.Exit(m.Run())
+}
+
+func runTestPlugin(der string) int {
+	priv, err := x509.ParseECPrivateKey([]byte(der))
+	if err != nil {
+		return 2
+	}
+	req := Request{}
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		return 2
+	}
+	resp := Response{}
+	switch {
+	case req.ProtocolVersion != ProtocolVersion:
+		resp.Error = "unsupported protocol version " + req.ProtocolVersion
+	case req.Method == MethodPublicKey:
+		pub, _ := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+		resp.PublicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+		resp.KeyID = os.Getenv("TEST_PLUGIN_KEYID")
+	case req.Method == MethodSign:
+		digest := req.Digest
+		if os.Getenv("TEST_PLUGIN_BAD") != "" {
+			digest = make([]byte, len(digest))
+		}
+		resp.Signature, _ = ecdsa.SignASN1(rand.Reader, priv, digest)
+	default:
+		resp.Error = "unsupported method " + req.Method
+	}
+	_ = json.NewEncoder(os.Stdout).Encode(resp)
+	if resp.Error != "" {
+		return 1
+	}
+	return 0
+}
+
+func testPlugin(t *testing.T) (*KMS, *ecdsa.PrivateKey) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("TEST_PLUGIN_KEY", string(der))
+	self, err := filepath.Abs(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := NewPlugin(context.Background(), ReferenceScheme+self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k, priv
+}
+
+func TestPluginSign(t *testing.T) {
+	k, priv := testPlugin(t)
+	defer os.Unsetenv("TEST_PLUGIN_KEY")
+	ctx := context.Background()
+
+	payload := []byte(`{"critical":{}}`)
+	sig, err := k.Sign(ctx, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(payload)
+	if !ecdsa.VerifyASN1(&priv.PublicKey, digest[:], sig) {
+		t.Error("plugin signature doesn't verify")
+	}
+	if err := k.Verify(ctx, payload, sig); err != nil {
+		t.Error(err)
+	}
+
+	// Without a key ID from the plugin, it is the digest of the public key.
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := k.KeyID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := sha256.Sum256(der); id != hex.EncodeToString(sum[:]) {
+		t.Errorf("KeyID() = %s", id)
+	}
+	if k.Algorithm() != defaultAlgorithm {
+		t.Errorf("Algorithm() = %s", k.Algorithm())
+	}
+
+	if _, err := k.CreateKey(ctx); err == nil || !strings.Contains(err.Error(), "unsupported method createKey") {
+		t.Errorf("CreateKey() = %v, want the plugin's error", err)
+	}
+	if _, err := k.RotateKey(ctx); err == nil || !strings.Contains(err.Error(), "unsupported method rotateKey") {
+		t.Errorf("RotateKey() = %v, want the plugin's error", err)
+	}
+}
+
+func TestPluginBadSignature(t *testing.T) {
+	k, _ := testPlugin(t)
+	defer os.Unsetenv("TEST_PLUGIN_KEY")
+	os.Setenv("TEST_PLUGIN_BAD", "1")
+	defer os.Unsetenv("TEST_PLUGIN_BAD")
+
+	if _, err := k.Sign(context.Background(), []byte("payload")); err == nil {
+		t.Error("Sign() with a plugin returning bad signatures, expected error")
+	}
+}
+
+func TestValidReference(t *testing.T) {
+	for ref, valid := range map[string]bool{
+		"plugin://corp-signer":         true,
+		"plugin:///opt/signers/hsm":    true,
+		"plugin://":                    false,
+		"plugin://../bin/signer":       false,
+		"tpm://0x81000100":             false,
+		"gcpkms://projects/p/keyRings": false,
+	} {
+		if err := ValidReference(ref); (err == nil) != valid {
+			t.Errorf("ValidReference(%q) = %v, want valid %v", ref, err, valid)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "cosign-plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bin := filepath.Join(dir, BinaryPrefix+"corp")
+	if err := ioutil.WriteFile(bin, []byte("#!/bin/sh\n"), 0755); err != nil { // nolint: gosec
+		t.Fatal(err)
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir)
+	k, err := NewPlugin(context.Background(), "plugin://corp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.path != bin {
+		t.Errorf("plugin found at %s, want %s", k.path, bin)
+	}
+	if _, err := NewPlugin(context.Background(), "plugin://missing"); err == nil {
+		t.Error("NewPlugin() for a plugin that isn't installed, expected error")
+	}
+}
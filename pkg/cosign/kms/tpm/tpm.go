@@ -0,0 +1,234 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tpm
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// KMS signs with an ECDSA P-256 key that never leaves the local TPM 2.0.
+// Keys are stored at a persistent handle in the owner hierarchy.
+type KMS struct {
+	handle tpmutil.Handle
+}
+
+var (
+	ErrKMSReference = errors.New("tpm specification should be in the format tpm://[PERSISTENT_HANDLE], e.g. tpm://0x81000100")
+
+	re = regexp.MustCompile(`^tpm://(0x81[0-9a-fA-F]{6})$`)
+)
+
+const ReferenceScheme = "tpm://"
+
+// keyTemplate is an unrestricted signing key, so it can sign digests that weren't hashed by the TPM.
+var keyTemplate = tpm2.Public{
+	Type:       tpm2.AlgECC,
+	NameAlg:    tpm2.AlgSHA256,
+	Attributes: tpm2.FlagSign | tpm2.FlagFixedTPM | tpm2.FlagFixedParent | tpm2.FlagSensitiveDataOrigin | tpm2.FlagUserWithAuth,
+	ECCParameters: &tpm2.ECCParams{
+		Sign:    &tpm2.SigScheme{Alg: tpm2.AlgECDSA, Hash: tpm2.AlgSHA256},
+		CurveID: tpm2.CurveNISTP256,
+	},
+}
+
+// srkTemplate is the storage root key the signing key is created under.
+var srkTemplate = tpm2.Public{
+	Type:       tpm2.AlgECC,
+	NameAlg:    tpm2.AlgSHA256,
+	Attributes: tpm2.FlagStorageDefault,
+	ECCParameters: &tpm2.ECCParams{
+		Symmetric: &tpm2.SymScheme{Alg: tpm2.AlgAES, KeyBits: 128, Mode: tpm2.AlgCFB},
+		CurveID:   tpm2.CurveNISTP256,
+	},
+}
+
+func ValidReference(ref string) error {
+	if !re.MatchString(ref) {
+		return ErrKMSReference
+	}
+	return nil
+}
+
+func NewTPM(_ context.Context, keyResourceID string) (*KMS, error) {
+	v := re.FindStringSubmatch(keyResourceID)
+	if len(v) != 2 {
+		return nil, errors.Errorf("invalid tpm format %q", keyResourceID)
+	}
+	h, err := strconv.ParseUint(v[1], 0, 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing handle")
+	}
+	return &KMS{handle: tpmutil.Handle(h)}, nil
+}
+
+// CreateKey generates a new key in the TPM and persists it at the handle.
+// If there already is a key at the handle, its public key is returned instead.
+func (t *KMS) CreateKey(ctx context.Context) (*ecdsa.PublicKey, error) {
+	if pub, err := t.ECDSAPublicKey(ctx); err == nil {
+		log.Infof("Key already exists at TPM handle 0x%x, skipping creation.", uint32(t.handle))
+		return pub, nil
+	}
+
+	rw, err := tpm2.OpenTPM()
+	if err != nil {
+		return nil, errors.Wrap(err, "opening tpm")
+	}
+	defer rw.Close()
+
+	srk, _, err := tpm2.CreatePrimary(rw, tpm2.HandleOwner, tpm2.PCRSelection{}, "", "", srkTemplate)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating storage root key")
+	}
+	defer tpm2.FlushContext(rw, srk) // nolint: errcheck
+
+	private, public, _, _, _, err := tpm2.CreateKey(rw, srk, tpm2.PCRSelection{}, "", "", keyTemplate)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating key")
+	}
+	key, _, err := tpm2.Load(rw, srk, "", public, private)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading key")
+	}
+	defer tpm2.FlushContext(rw, key) // nolint: errcheck
+
+	if err := tpm2.EvictControl(rw, "", tpm2.HandleOwner, key, t.handle); err != nil {
+		return nil, errors.Wrap(err, "persisting key")
+	}
+	log.Infof("Created key at TPM handle 0x%x", uint32(t.handle))
+	return t.ECDSAPublicKey(ctx)
+}
+
+// RotateKey replaces the key at the handle with a newly generated one. The TPM has nowhere to keep
+// the previous key, so it is destroyed; keep its public key to verify the signatures it made.
+func (t *KMS) RotateKey(ctx context.Context) (*ecdsa.PublicKey, error) {
+	if _, err := t.ECDSAPublicKey(ctx); err == nil {
+		rw, err := tpm2.OpenTPM()
+		if err != nil {
+			return nil, errors.Wrap(err, "opening tpm")
+		}
+		err = tpm2.EvictControl(rw, "", tpm2.HandleOwner, t.handle, t.handle)
+		rw.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "evicting the previous key")
+		}
+		log.Infof("Evicted the previous key at TPM handle 0x%x", uint32(t.handle))
+	}
+	return t.CreateKey(ctx)
+}
+
+func (t *KMS) Sign(ctx context.Context, payload []byte) (signature []byte, err error) {
+	digest := sha256.Sum256(payload)
+	return t.SignDigest(ctx, digest[:])
+}
+
+// SignDigest signs an already computed SHA-256 digest with the key in the TPM.
+func (t *KMS) SignDigest(_ context.Context, digest []byte) (signature []byte, err error) {
+	rw, err := tpm2.OpenTPM()
+	if err != nil {
+		return nil, errors.Wrap(err, "opening tpm")
+	}
+	defer rw.Close()
+
+	sig, err := tpm2.Sign(rw, t.handle, "", digest, nil, &tpm2.SigScheme{Alg: tpm2.AlgECDSA, Hash: tpm2.AlgSHA256})
+	if err != nil {
+		return nil, errors.Wrap(err, "signing")
+	}
+	if sig.ECC == nil {
+		return nil, errors.New("tpm did not return an ECDSA signature")
+	}
+	// The TPM returns the raw r and s values, cosign uses ASN.1 signatures everywhere else.
+	return asn1.Marshal(struct {
+		R, S *big.Int
+	}{sig.ECC.R, sig.ECC.S})
+}
+
+func (t *KMS) PublicKey(_ context.Context) (crypto.PublicKey, error) {
+	pub, _, err := t.readPublic()
+	if err != nil {
+		return nil, err
+	}
+	return pub.Key()
+}
+
+func (t *KMS) ECDSAPublicKey(ctx context.Context) (*ecdsa.PublicKey, error) {
+	k, err := t.PublicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := k.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key was not ECDSA: %#v", k)
+	}
+	return pub, nil
+}
+
+func (t *KMS) Verify(ctx context.Context, payload, signature []byte) error {
+	h := sha256.Sum256(payload)
+	return t.VerifyDigest(ctx, h[:], signature)
+}
+
+// VerifyDigest verifies the signature over an already computed SHA-256 digest.
+func (t *KMS) VerifyDigest(ctx context.Context, digest, signature []byte) error {
+	pub, err := t.ECDSAPublicKey(ctx)
+	if err != nil {
+		return errors.Wrap(err, "retrieving public key")
+	}
+	if !ecdsa.VerifyASN1(pub, digest, signature) {
+		return errors.New("unable to verify signature")
+	}
+	return nil
+}
+
+// KeyID returns the TPM name of the key, which is a digest of its public area.
+func (t *KMS) KeyID(_ context.Context) (string, error) {
+	_, name, err := t.readPublic()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(name), nil
+}
+
+// Algorithm returns the signature algorithm, keys are always created as ECDSA P-256 with SHA-256.
+func (t *KMS) Algorithm() string {
+	return "ecdsa-p256-sha256"
+}
+
+func (t *KMS) readPublic() (tpm2.Public, []byte, error) {
+	rw, err := tpm2.OpenTPM()
+	if err != nil {
+		return tpm2.Public{}, nil, errors.Wrap(err, "opening tpm")
+	}
+	defer rw.Close()
+	pub, name, _, err := tpm2.ReadPublic(rw, t.handle)
+	if err != nil {
+		return tpm2.Public{}, nil, errors.Wrapf(err, "reading key at handle 0x%x", uint32(t.handle))
+	}
+	return pub, name, nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tpm
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReference(t *testing.T) {
+	tests := []struct {
+		ref     string
+		wantErr bool
+	}{
+		{ref: "tpm://0x81000100"},
+		{ref: "tpm://0x81ABCDEF"},
+		{ref: "tpm://0x80000001", wantErr: true},
+		{ref: "tpm://81000100", wantErr: true},
+		{ref: "tpm://", wantErr: true},
+		{ref: "gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/k", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			if err := ValidReference(tt.ref); (err != nil) != tt.wantErr {
+				t.Errorf("ValidReference() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			k, err := NewTPM(context.Background(), tt.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if k.handle>>24 != 0x81 {
+				t.Errorf("handle 0x%x is not persistent", uint32(k.handle))
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kubernetes reads keys from Kubernetes secrets, referred to as k8s://<namespace>/<secret>[/<key>],
+// so keys kept in a cluster never have to be written to disk. It talks to the cluster of the current
+// kubeconfig context, or to the cluster it runs in.
+package kubernetes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+const (
+	// KeyRefPrefix starts references to keys in secrets.
+	KeyRefPrefix = "k8s://"
+
+	// The keys of a secret holding a cosign key pair, as cosign generate-key-pair names the files.
+	PrivateKey = "cosign.key"
+	PublicKey  = "cosign.pub"
+	Password   = "cosign.password"
+
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// SecretRef is a parsed k8s:// reference.
+type SecretRef struct {
+	Namespace string
+	Name      string
+	// Key is the key of the secret's data to use, empty to use the default one.
+	Key string
+}
+
+// IsRef reports whether ref is a k8s:// reference.
+func IsRef(ref string) bool {
+	return strings.HasPrefix(ref, KeyRefPrefix)
+}
+
+// ParseRef parses a k8s://<namespace>/<secret>[/<key>] reference.
+func ParseRef(ref string) (*SecretRef, error) {
+	parts := strings.Split(strings.TrimPrefix(ref, KeyRefPrefix), "/")
+	if !IsRef(ref) || len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid secret reference %q, want %s<namespace>/<secret>[/<key>]", ref, KeyRefPrefix)
+	}
+	sr := &SecretRef{Namespace: parts[0], Name: parts[1]}
+	if len(parts) == 3 {
+		sr.Key = parts[2]
+	}
+	return sr, nil
+}
+
+// KeyOr returns the key the reference names, or def if it names none.
+func (sr *SecretRef) KeyOr(def string) string {
+	if sr.Key != "" {
+		return sr.Key
+	}
+	return def
+}
+
+// ReadKey returns the data of the key the reference names in its secret, or of defaultKey if it names none.
+func ReadKey(ctx context.Context, ref, defaultKey string) ([]byte, error) {
+	sr, err := ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	data, err := Secret(ctx, sr)
+	if err != nil {
+		return nil, err
+	}
+	key := sr.KeyOr(defaultKey)
+	b, ok := data[key]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s has no key %q", sr.Namespace, sr.Name, key)
+	}
+	return b, nil
+}
+
+// Secret returns the data of the secret the reference names.
+func Secret(ctx context.Context, sr *SecretRef) (map[string][]byte, error) {
+	c, err := NewClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.Secret(ctx, sr.Namespace, sr.Name)
+}
+
+// Client makes requests to the Kubernetes API server.
+type Client struct {
+	Server string
+	HTTP   *http.Client
+	// Token, if set, is sent as a bearer token.
+	Token string
+}
+
+// Secret returns the data of the named secret.
+func (c *Client) Secret(ctx context.Context, namespace, name string) (map[string][]byte, error) {
+	u := fmt.Sprintf("%s/api/v1/namespaces/%s/secrets/%s", strings.TrimSuffix(c.Server, "/"), url.PathEscape(namespace), url.PathEscape(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting secret %s/%s: %s", namespace, name, resp.Status)
+	}
+	secret := struct {
+		// encoding/json decodes the base64 values of the secret's data.
+		Data map[string][]byte `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, errors.Wrapf(err, "parsing secret %s/%s", namespace, name)
+	}
+	return secret.Data, nil
+}
+
+type kubeconfig struct {
+	CurrentContext string `json:"current-context"`
+	Contexts       []struct {
+		Name    string `json:"name"`
+		Context struct {
+			Cluster string `json:"cluster"`
+			User    string `json:"user"`
+		} `json:"context"`
+	} `json:"contexts"`
+	Clusters []struct {
+		Name    string `json:"name"`
+		Cluster struct {
+			Server                   string `json:"server"`
+			CertificateAuthority     string `json:"certificate-authority"`
+			CertificateAuthorityData []byte `json:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify"`
+		} `json:"cluster"`
+	} `json:"clusters"`
+	Users []struct {
+		Name string `json:"name"`
+		User struct {
+			Token                 string `json:"token"`
+			TokenFile             string `json:"tokenFile"`
+			ClientCertificate     string `json:"client-certificate"`
+			ClientCertificateData []byte `json:"client-certificate-data"`
+			ClientKey             string `json:"client-key"`
+			ClientKeyData         []byte `json:"client-key-data"`
+		} `json:"user"`
+	} `json:"users"`
+}
+
+// NewClient returns a client for the cluster of the current context of the kubeconfig in
+// $KUBECONFIG or ~/.kube/config, or, if there is none, of the cluster it runs in.
+// Credential plugins configured with exec aren't supported.
+func NewClient() (*Client, error) {
+	path := ""
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		// Like kubectl, take the first file of the list.
+		path = filepath.SplitList(env)[0]
+	} else if home, err := os.UserHomeDir(); err == nil {
+		path = filepath.Join(home, ".kube", "config")
+	}
+	if path != "" {
+		if _, err := os.Stat(path); err == nil {
+			return clientFromKubeconfig(path)
+		}
+	}
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return inClusterClient()
+	}
+	return nil, errors.New("no kubeconfig found, and not running in a cluster")
+}
+
+func clientFromKubeconfig(path string) (*Client, error) {
+	b, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	kc := kubeconfig{}
+	if err := yaml.Unmarshal(b, &kc); err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", path)
+	}
+	// Relative paths in a kubeconfig are relative to its directory.
+	dir := filepath.Dir(path)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+
+	var clusterName, userName string
+	for _, c := range kc.Contexts {
+		if c.Name == kc.CurrentContext {
+			clusterName, userName = c.Context.Cluster, c.Context.User
+		}
+	}
+	if clusterName == "" {
+		return nil, fmt.Errorf("%s: no current context", path)
+	}
+	c := &Client{}
+	tc := &tls.Config{}
+	found := false
+	for _, cl := range kc.Clusters {
+		if cl.Name != clusterName {
+			continue
+		}
+		found = true
+		c.Server = cl.Cluster.Server
+		tc.InsecureSkipVerify = cl.Cluster.InsecureSkipTLSVerify // nolint: gosec
+		ca := cl.Cluster.CertificateAuthorityData
+		if p := resolve(cl.Cluster.CertificateAuthority); p != "" {
+			if ca, err = ioutil.ReadFile(filepath.Clean(p)); err != nil {
+				return nil, err
+			}
+		}
+		if len(ca) > 0 {
+			tc.RootCAs = x509.NewCertPool()
+			if !tc.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("%s: invalid certificate authority for cluster %s", path, clusterName)
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%s: no cluster %s", path, clusterName)
+	}
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		c.Token = u.User.Token
+		if p := resolve(u.User.TokenFile); p != "" && c.Token == "" {
+			t, err := ioutil.ReadFile(filepath.Clean(p))
+			if err != nil {
+				return nil, err
+			}
+			c.Token = strings.TrimSpace(string(t))
+		}
+		cert, key := u.User.ClientCertificateData, u.User.ClientKeyData
+		if p := resolve(u.User.ClientCertificate); p != "" {
+			if cert, err = ioutil.ReadFile(filepath.Clean(p)); err != nil {
+				return nil, err
+			}
+		}
+		if p := resolve(u.User.ClientKey); p != "" {
+			if key, err = ioutil.ReadFile(filepath.Clean(p)); err != nil {
+				return nil, err
+			}
+		}
+		if len(cert) > 0 {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s: loading client certificate of user %s", path, userName)
+			}
+			tc.Certificates = []tls.Certificate{pair}
+		}
+	}
+	c.HTTP = &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tc}}
+	return c, nil
+}
+
+func inClusterClient() (*Client, error) {
+	token, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if err != nil {
+		return nil, err
+	}
+	ca, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid service account CA certificate")
+	}
+	host := os.Getenv("KUBERNETES_SERVICE_HOST")
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return &Client{
+		Server: "https://" + host + ":" + os.Getenv("KUBERNETES_SERVICE_PORT"),
+		HTTP:   &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}},
+		Token:  strings.TrimSpace(string(token)),
+	}, nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want *SecretRef
+	}{
+		{"k8s://ci/signing", &SecretRef{Namespace: "ci", Name: "signing"}},
+		{"k8s://ci/signing/release.key", &SecretRef{Namespace: "ci", Name: "signing", Key: "release.key"}},
+		{"k8s://ci", nil},
+		{"k8s://ci/", nil},
+		{"k8s://ci/signing/key/extra", nil},
+		{"cosign.key", nil},
+	}
+	for _, tt := range tests {
+		got, err := ParseRef(tt.ref)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("ParseRef(%s) = %+v, want error", tt.ref, got)
+			}
+			continue
+		}
+		if err != nil || *got != *tt.want {
+			t.Errorf("ParseRef(%s) = %+v, %v, want %+v", tt.ref, got, err, tt.want)
+		}
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cr3t" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v1/namespaces/ci/secrets/signing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"kind":"Secret","data":{"cosign.pub":%q,"release.pub":%q}}`,
+			base64.StdEncoding.EncodeToString([]byte("default key")), base64.StdEncoding.EncodeToString([]byte("release key")))
+	}))
+	defer s.Close()
+
+	td, err := ioutil.TempDir("", "cosign-kubernetes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+	if err := ioutil.WriteFile(filepath.Join(td, "ca.pem"), ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// The CA file path is relative to the kubeconfig.
+	config := fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: test
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+clusters:
+- name: test
+  cluster:
+    server: %s
+    certificate-authority: ca.pem
+users:
+- name: test
+  user:
+    token: s3cr3t
+`, s.URL)
+	kubeconfig := filepath.Join(td, "config")
+	if err := ioutil.WriteFile(kubeconfig, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old, ok := os.LookupEnv("KUBECONFIG")
+	defer func() {
+		if ok {
+			os.Setenv("KUBECONFIG", old)
+		} else {
+			os.Unsetenv("KUBECONFIG")
+		}
+	}()
+	os.Setenv("KUBECONFIG", kubeconfig)
+
+	ctx := context.Background()
+	for ref, want := range map[string]string{
+		"k8s://ci/signing":             "default key",
+		"k8s://ci/signing/release.pub": "release key",
+	} {
+		got, err := ReadKey(ctx, ref, PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("ReadKey(%s) = %q, want %q", ref, got, want)
+		}
+	}
+	if _, err := ReadKey(ctx, "k8s://ci/signing/missing.pub", PublicKey); err == nil {
+		t.Error("expected error reading a key the secret doesn't have")
+	}
+	if _, err := ReadKey(ctx, "k8s://ci/other", PublicKey); err == nil {
+		t.Error("expected error reading a secret that doesn't exist")
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pkg/errors"
+)
+
+// LayoutType is the _type of the layouts ParseLayout accepts.
+const LayoutType = "layout"
+
+// Layout is a supply chain policy in the style of an in-toto layout: the steps whose
+// attestations an image must carry, and who is allowed to perform each of them.
+type Layout struct {
+	Type    string    `json:"_type"`
+	Expires time.Time `json:"expires"`
+	// Keys maps the key IDs functionaries are referred to by to PEM encoded public keys.
+	Keys  map[string]string `json:"keys"`
+	Steps []Step            `json:"steps"`
+}
+
+// Step is one step of a Layout.
+type Step struct {
+	Name string `json:"name"`
+	// PredicateType is the type of the attestations for the step, a URI or one of the short
+	// names in PredicateTypes. Link attestations must also have the step's name.
+	PredicateType string `json:"predicateType"`
+	// PubKeys are the key IDs of the functionaries that can perform the step.
+	PubKeys []string `json:"pubkeys,omitempty"`
+	// Identities are the certificate emails, or SPIFFE IDs, of the functionaries that can perform
+	// the step with certificates.
+	Identities []string `json:"identities,omitempty"`
+	// Threshold is how many distinct functionaries must attest to the step, 1 if unset.
+	Threshold int `json:"threshold,omitempty"`
+}
+
+// StepResult records the functionaries whose attestations satisfied a step.
+type StepResult struct {
+	Step          string   `json:"step"`
+	Functionaries []string `json:"functionaries"`
+}
+
+// ParseLayout parses and checks a layout.
+func ParseLayout(b []byte) (*Layout, error) {
+	l := &Layout{}
+	if err := json.Unmarshal(b, l); err != nil {
+		return nil, errors.Wrap(err, "parsing layout")
+	}
+	if l.Type != LayoutType {
+		return nil, fmt.Errorf("unsupported layout type %q", l.Type)
+	}
+	if len(l.Steps) == 0 {
+		return nil, errors.New("layout has no steps")
+	}
+	names := map[string]bool{}
+	for i, s := range l.Steps {
+		if s.Name == "" || names[s.Name] {
+			return nil, fmt.Errorf("step %d must have a unique name", i)
+		}
+		names[s.Name] = true
+		if s.PredicateType == "" {
+			return nil, fmt.Errorf("step %s has no predicate type", s.Name)
+		}
+		for _, id := range s.PubKeys {
+			if _, ok := l.Keys[id]; !ok {
+				return nil, fmt.Errorf("step %s refers to unknown key %s", s.Name, id)
+			}
+		}
+		if n := len(s.PubKeys) + len(s.Identities); s.threshold() > n {
+			return nil, fmt.Errorf("step %s has a threshold of %d, but only %d functionaries", s.Name, s.threshold(), n)
+		}
+	}
+	return l, nil
+}
+
+// Verify checks that the attestations on the image described by desc satisfy every step of the layout.
+// Fulcio certificates are checked against co.Roots, the rest of co is ignored.
+func (l *Layout) Verify(ctx context.Context, desc *v1.Descriptor, atts []Attestation, co CheckOpts, now time.Time) ([]StepResult, error) {
+	if !l.Expires.IsZero() && now.After(l.Expires) {
+		return nil, fmt.Errorf("layout expired at %s", l.Expires.Format(time.RFC3339))
+	}
+	keys := map[string]PublicKey{}
+	for id, pem := range l.Keys {
+		k, err := ParsePublicKeyPem([]byte(pem))
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing key %s", id)
+		}
+		keys[id] = k
+	}
+
+	results := []StepResult{}
+	failures := []string{}
+	for _, s := range l.Steps {
+		res := s.verify(ctx, desc, atts, keys, co)
+		if len(res.Functionaries) < s.threshold() {
+			failures = append(failures, fmt.Sprintf("step %s: %d of %d required functionaries attested", s.Name, len(res.Functionaries), s.threshold()))
+			continue
+		}
+		results = append(results, res)
+	}
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("layout not satisfied:\n %s", strings.Join(failures, "\n "))
+	}
+	return results, nil
+}
+
+func (s *Step) threshold() int {
+	if s.Threshold < 1 {
+		return 1
+	}
+	return s.Threshold
+}
+
+// verify returns the distinct functionaries of the step that made a valid attestation for it.
+func (s *Step) verify(ctx context.Context, desc *v1.Descriptor, atts []Attestation, keys map[string]PublicKey, co CheckOpts) StepResult {
+	res := StepResult{Step: s.Name, Functionaries: []string{}}
+	seen := map[string]bool{}
+	attested := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			res.Functionaries = append(res.Functionaries, id)
+		}
+	}
+	for _, a := range FilterAttestations(atts, s.PredicateType) {
+		if !s.matches(a) {
+			continue
+		}
+		for _, id := range s.PubKeys {
+			if _, err := verifyAttestation(ctx, desc, a, CheckOpts{Keys: []PublicKey{keys[id]}}); err == nil {
+				attested(id)
+			}
+		}
+		if a.Cert == nil || len(s.Identities) == 0 {
+			continue
+		}
+		if _, err := verifyAttestation(ctx, desc, a, CheckOpts{Roots: co.Roots}); err != nil {
+			continue
+		}
+		for _, id := range s.Identities {
+			if HasIdentity(a.Cert, id) {
+				attested(id)
+			}
+		}
+	}
+	return res
+}
+
+// matches reports whether a is for the step. Link attestations name the step they record,
+// other predicates are matched on their type alone.
+func (s *Step) matches(a Attestation) bool {
+	if PredicateTypeURI(s.PredicateType) != PredicateTypes["link"] {
+		return true
+	}
+	st, err := a.Statement()
+	if err != nil {
+		return false
+	}
+	link := struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.Unmarshal(st.Predicate, &link); err != nil {
+		return false
+	}
+	return link.Name == s.Name
+}
@@ -0,0 +1,122 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestParseLayout(t *testing.T) {
+	tests := []struct {
+		desc    string
+		layout  string
+		wantErr bool
+	}{{
+		desc:   "valid",
+		layout: `{"_type":"layout","keys":{"k":"pem"},"steps":[{"name":"build","predicateType":"slsaprovenance","pubkeys":["k"]}]}`,
+	}, {
+		desc:    "wrong type",
+		layout:  `{"_type":"link","steps":[{"name":"build","predicateType":"slsaprovenance","identities":["a@example.com"]}]}`,
+		wantErr: true,
+	}, {
+		desc:    "no steps",
+		layout:  `{"_type":"layout"}`,
+		wantErr: true,
+	}, {
+		desc:    "duplicate step",
+		layout:  `{"_type":"layout","steps":[{"name":"a","predicateType":"vuln","identities":["a@example.com"]},{"name":"a","predicateType":"vuln","identities":["a@example.com"]}]}`,
+		wantErr: true,
+	}, {
+		desc:    "unknown key",
+		layout:  `{"_type":"layout","steps":[{"name":"build","predicateType":"slsaprovenance","pubkeys":["k"]}]}`,
+		wantErr: true,
+	}, {
+		desc:    "threshold too high",
+		layout:  `{"_type":"layout","steps":[{"name":"build","predicateType":"slsaprovenance","identities":["a@example.com"],"threshold":2}]}`,
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := ParseLayout([]byte(tt.layout))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseLayout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLayoutVerify(t *testing.T) {
+	ctx := context.Background()
+	signers := map[string]*ECDSAKey{}
+	keys := map[string]string{}
+	for _, id := range []string{"builder", "scanner1", "scanner2"} {
+		priv, err := GeneratePrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		signers[id] = WithECDSAKey(priv)
+		pem, err := KeyToPem(&priv.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[id] = string(pem)
+	}
+	b, err := json.Marshal(Layout{
+		Type: LayoutType,
+		Keys: keys,
+		Steps: []Step{
+			{Name: "build", PredicateType: "slsaprovenance", PubKeys: []string{"builder"}},
+			{Name: "scan", PredicateType: "vuln", PubKeys: []string{"scanner1", "scanner2"}, Threshold: 2},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	layout, err := ParseLayout(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	desc := &v1.Descriptor{Digest: v1.Hash{Algorithm: "sha256", Hex: "abc"}}
+	build := testAttestation(t, signers["builder"], desc.Digest, PredicateTypes["slsaprovenance"])
+	scan1 := testAttestation(t, signers["scanner1"], desc.Digest, PredicateTypes["vuln"])
+	scan2 := testAttestation(t, signers["scanner2"], desc.Digest, PredicateTypes["vuln"])
+	// The builder isn't a scanner, so its scan doesn't count.
+	builderScan := testAttestation(t, signers["builder"], desc.Digest, PredicateTypes["vuln"])
+
+	results, err := layout.Verify(ctx, desc, []Attestation{build, scan1, scan2}, CheckOpts{}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || len(results[1].Functionaries) != 2 {
+		t.Errorf("Verify() = %+v, want both steps with both scanners", results)
+	}
+	if _, err := layout.Verify(ctx, desc, []Attestation{build, scan1, builderScan}, CheckOpts{}, time.Now()); err == nil {
+		t.Error("expected error when the scan threshold isn't met")
+	}
+	if _, err := layout.Verify(ctx, desc, []Attestation{scan1, scan2}, CheckOpts{}, time.Now()); err == nil {
+		t.Error("expected error when the build step is missing")
+	}
+
+	layout.Expires = time.Now().Add(-time.Hour)
+	if _, err := layout.Verify(ctx, desc, []Attestation{build, scan1, scan2}, CheckOpts{}, time.Now()); err == nil {
+		t.Error("expected error for an expired layout")
+	}
+}
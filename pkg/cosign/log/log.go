@@ -0,0 +1,144 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package log is the leveled logger cosign reports progress and diagnostics with, so they
+// can be silenced, made more verbose or emitted as JSON for automation.
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a message.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
+// Logger writes the messages at or above its level.
+type Logger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level Level
+	json  bool
+	now   func() time.Time
+}
+
+// New returns a logger writing info and above to w as text.
+func New(w io.Writer) *Logger {
+	return &Logger{w: w, level: LevelInfo, now: time.Now}
+}
+
+// SetLevel sets the least severe level that is written.
+func (l *Logger) SetLevel(level Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+}
+
+// SetJSON makes messages be written as one JSON object per line, with time, level and msg fields.
+func (l *Logger) SetJSON(enabled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.json = enabled
+}
+
+// SetOutput sets where messages are written.
+func (l *Logger) SetOutput(w io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w = w
+}
+
+// Enabled reports whether messages at level are written, to skip work only needed to log them.
+func (l *Logger) Enabled(level Level) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return level >= l.level
+}
+
+// Logf writes the message at level. As text, info messages are written as they are, and
+// others are prefixed with their level.
+func (l *Logger) Logf(level Level, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level < l.level {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	if l.json {
+		b, err := json.Marshal(struct {
+			Time  string `json:"time"`
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+		}{l.now().UTC().Format(time.RFC3339Nano), level.String(), msg})
+		if err == nil {
+			fmt.Fprintln(l.w, string(b))
+		}
+		return
+	}
+	if level != LevelInfo {
+		msg = level.String() + ": " + msg
+	}
+	fmt.Fprintln(l.w, msg)
+}
+
+// std is the logger used by the package functions, it writes to stderr.
+var std = New(os.Stderr)
+
+// SetLevel sets the least severe level the standard logger writes.
+func SetLevel(level Level) { std.SetLevel(level) }
+
+// SetJSON makes the standard logger write JSON.
+func SetJSON(enabled bool) { std.SetJSON(enabled) }
+
+// SetOutput sets where the standard logger writes.
+func SetOutput(w io.Writer) { std.SetOutput(w) }
+
+// Enabled reports whether the standard logger writes messages at level.
+func Enabled(level Level) bool { return std.Enabled(level) }
+
+// Debugf logs diagnostics, like network round trips and payload digests.
+func Debugf(format string, args ...interface{}) { std.Logf(LevelDebug, format, args...) }
+
+// Infof logs progress, which -q silences.
+func Infof(format string, args ...interface{}) { std.Logf(LevelInfo, format, args...) }
+
+// Warnf logs problems that don't stop the command.
+func Warnf(format string, args ...interface{}) { std.Logf(LevelWarn, format, args...) }
+
+// Errorf logs errors.
+func Errorf(format string, args ...interface{}) { std.Logf(LevelError, format, args...) }
@@ -0,0 +1,45 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	b := &bytes.Buffer{}
+	l := New(b)
+	l.Logf(LevelDebug, "hidden")
+	l.Logf(LevelInfo, "Pushing signature to: %s", "example.com/demo")
+	l.Logf(LevelWarn, "webhook returned %d", 500)
+	if want := "Pushing signature to: example.com/demo\nwarning: webhook returned 500\n"; b.String() != want {
+		t.Errorf("text output = %q, want %q", b.String(), want)
+	}
+
+	b.Reset()
+	l.SetLevel(LevelWarn)
+	l.SetJSON(true)
+	l.now = func() time.Time { return time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC) }
+	l.Logf(LevelInfo, "hidden")
+	l.Logf(LevelError, "failed")
+	if want := `{"time":"2021-04-01T12:00:00Z","level":"error","msg":"failed"}` + "\n"; b.String() != want {
+		t.Errorf("JSON output = %q, want %q", b.String(), want)
+	}
+	if l.Enabled(LevelInfo) || !l.Enabled(LevelWarn) {
+		t.Error("Enabled() doesn't match the level")
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package minisign verifies signatures made by minisign and OpenBSD signify,
+// so artifacts signed with those tools can be checked with cosign.
+package minisign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	untrustedPrefix = "untrusted comment: "
+	trustedPrefix   = "trusted comment: "
+)
+
+var (
+	// Both tools use "Ed" for signatures over the raw message.
+	algEd25519 = [2]byte{'E', 'd'}
+	// minisign uses "ED" for signatures over the BLAKE2b-512 hash of the message.
+	algHashedEd25519 = [2]byte{'E', 'D'}
+)
+
+type PublicKey struct {
+	KeyID [8]byte
+	Key   ed25519.PublicKey
+}
+
+type Signature struct {
+	Algorithm [2]byte
+	KeyID     [8]byte
+	Signature []byte
+	// TrustedComment and GlobalSignature are only set for minisign signatures.
+	TrustedComment  string
+	GlobalSignature []byte
+}
+
+// ParsePublicKey parses a minisign or signify public key file.
+// The bare base64 encoded key, as passed to minisign -P, is also accepted.
+func ParsePublicKey(b []byte) (*PublicKey, error) {
+	lines := contentLines(b)
+	if len(lines) == 0 {
+		return nil, errors.New("empty public key")
+	}
+	raw, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding public key")
+	}
+	if len(raw) != 2+8+ed25519.PublicKeySize || !bytes.Equal(raw[:2], algEd25519[:]) {
+		return nil, errors.New("not a minisign or signify public key")
+	}
+	pk := &PublicKey{Key: ed25519.PublicKey(raw[10:])}
+	copy(pk.KeyID[:], raw[2:10])
+	return pk, nil
+}
+
+// ParseSignature parses a minisign or signify signature file.
+func ParseSignature(b []byte) (*Signature, error) {
+	lines := contentLines(b)
+	if len(lines) == 0 {
+		return nil, errors.New("empty signature")
+	}
+	raw, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding signature")
+	}
+	if len(raw) != 2+8+ed25519.SignatureSize {
+		return nil, errors.New("not a minisign or signify signature")
+	}
+	sig := &Signature{Signature: raw[10:]}
+	copy(sig.Algorithm[:], raw[:2])
+	copy(sig.KeyID[:], raw[2:10])
+	if sig.Algorithm != algEd25519 && sig.Algorithm != algHashedEd25519 {
+		return nil, fmt.Errorf("unsupported signature algorithm: %q", sig.Algorithm[:])
+	}
+
+	// minisign adds a trusted comment, and a global signature covering it.
+	if len(lines) == 1 {
+		return sig, nil
+	}
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], trustedPrefix) {
+		return nil, errors.New("malformed trusted comment")
+	}
+	sig.TrustedComment = strings.TrimPrefix(lines[1], trustedPrefix)
+	sig.GlobalSignature, err = base64.StdEncoding.DecodeString(lines[2])
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding global signature")
+	}
+	return sig, nil
+}
+
+// Verify checks that sig is a valid signature by pk over the message read from r.
+func (pk *PublicKey) Verify(r io.Reader, sig *Signature) error {
+	if pk.KeyID != sig.KeyID {
+		return fmt.Errorf("signature was made by key %X, not %X", reverse(sig.KeyID), reverse(pk.KeyID))
+	}
+
+	var msg []byte
+	switch sig.Algorithm {
+	case algHashedEd25519:
+		h, err := blake2b.New512(nil)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(h, r); err != nil {
+			return err
+		}
+		msg = h.Sum(nil)
+	default:
+		// Legacy signatures cover the message itself, so it all has to be read.
+		var err error
+		msg, err = ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+	}
+	if !ed25519.Verify(pk.Key, msg, sig.Signature) {
+		return errors.New("unable to verify signature")
+	}
+
+	if sig.GlobalSignature != nil {
+		global := append(append([]byte{}, sig.Signature...), sig.TrustedComment...)
+		if !ed25519.Verify(pk.Key, global, sig.GlobalSignature) {
+			return errors.New("unable to verify trusted comment")
+		}
+	}
+	return nil
+}
+
+// contentLines returns the non-empty lines of b, skipping the untrusted comment.
+func contentLines(b []byte) []string {
+	lines := []string{}
+	for _, l := range strings.Split(string(b), "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, untrustedPrefix) {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// reverse returns the key ID in the byte order minisign prints it in.
+func reverse(id [8]byte) [8]byte {
+	for i, j := 0, len(id)-1; i < j; i, j = i+1, j-1 {
+		id[i], id[j] = id[j], id[i]
+	}
+	return id
+}
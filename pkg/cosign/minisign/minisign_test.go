@@ -0,0 +1,101 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package minisign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+var keyID = []byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+func encodePublicKey(pub ed25519.PublicKey) []byte {
+	raw := append(append([]byte("Ed"), keyID...), pub...)
+	return []byte("untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(raw) + "\n")
+}
+
+func sign(priv ed25519.PrivateKey, alg string, msg []byte, comment string) []byte {
+	if alg == "ED" {
+		h := blake2b.Sum512(msg)
+		msg = h[:]
+	}
+	sig := ed25519.Sign(priv, msg)
+	raw := append(append([]byte(alg), keyID...), sig...)
+	out := "untrusted comment: signature\n" + base64.StdEncoding.EncodeToString(raw) + "\n"
+	if comment != "" {
+		global := ed25519.Sign(priv, append(sig, comment...))
+		out += fmt.Sprintf("trusted comment: %s\n%s\n", comment, base64.StdEncoding.EncodeToString(global))
+	}
+	return []byte(out)
+}
+
+func TestVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, err := ParsePublicKey(encodePublicKey(pub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("release.tar.gz contents")
+
+	tests := []struct {
+		name    string
+		sig     []byte
+		msg     []byte
+		wantErr bool
+	}{
+		{name: "signify", sig: sign(priv, "Ed", msg, ""), msg: msg},
+		{name: "minisign legacy", sig: sign(priv, "Ed", msg, "timestamp:1617235200"), msg: msg},
+		{name: "minisign prehashed", sig: sign(priv, "ED", msg, "timestamp:1617235200"), msg: msg},
+		{name: "wrong message", sig: sign(priv, "ED", msg, "timestamp:1617235200"), msg: []byte("evil"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig, err := ParseSignature(tt.sig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := pk.Verify(bytes.NewReader(tt.msg), sig); (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Tampering with the trusted comment invalidates the global signature.
+	sig, err := ParseSignature(sign(priv, "ED", msg, "timestamp:1617235200"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig.TrustedComment = "timestamp:0"
+	if err := pk.Verify(bytes.NewReader(msg), sig); err == nil {
+		t.Error("expected error verifying modified trusted comment")
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	for _, bad := range []string{"", "untrusted comment: nothing\n", "bm90IGEga2V5"} {
+		if _, err := ParsePublicKey([]byte(bad)); err == nil {
+			t.Errorf("ParsePublicKey(%q) expected error", bad)
+		}
+	}
+}
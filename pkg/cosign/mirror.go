@@ -0,0 +1,130 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/sigstore/cosign/pkg/cosign/log"
+)
+
+// MirrorsEnv lists registry mirrors, separated by commas, in the form ParseRegistryMirrors takes.
+const MirrorsEnv = "COSIGN_REGISTRY_MIRRORS"
+
+type mirrorsKey struct{}
+
+// WithRegistryMirrors returns a context under which images and their signatures are looked up in
+// the mirrors of their registry first, falling back to the registry itself, overriding $COSIGN_REGISTRY_MIRRORS.
+// mirrors maps a registry to its mirrors, see ParseRegistryMirrors. Signatures are still pushed to the registry.
+func WithRegistryMirrors(ctx context.Context, mirrors map[string][]string) context.Context {
+	return context.WithValue(ctx, mirrorsKey{}, mirrors)
+}
+
+// ParseRegistryMirrors parses mirrors written as "<registry>=<mirror>", or just "<mirror>" for a mirror of
+// Docker Hub, like the docker daemon's registry-mirrors. A registry's mirrors are tried in the order given.
+func ParseRegistryMirrors(specs []string) (map[string][]string, error) {
+	mirrors := map[string][]string{}
+	for _, s := range specs {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		src, mirror := name.DefaultRegistry, s
+		if parts := strings.SplitN(s, "=", 2); len(parts) == 2 {
+			src, mirror = parts[0], parts[1]
+		}
+		// Mirrors are often configured as URLs, an http:// one is reached over plain HTTP.
+		mirror = strings.TrimSuffix(strings.TrimPrefix(mirror, "https://"), "/")
+		reg, err := name.NewRegistry(src)
+		if err != nil {
+			return nil, err
+		}
+		host := strings.TrimPrefix(mirror, "http://")
+		if _, err := name.NewRegistry(host); err != nil || strings.Contains(host, "/") {
+			return nil, fmt.Errorf("invalid mirror %q of %s", mirror, reg.Name())
+		}
+		mirrors[reg.Name()] = append(mirrors[reg.Name()], mirror)
+	}
+	return mirrors, nil
+}
+
+// registryMirrors returns the mirrors configured under ctx, or in $COSIGN_REGISTRY_MIRRORS.
+func registryMirrors(ctx context.Context) map[string][]string {
+	if m, ok := ctx.Value(mirrorsKey{}).(map[string][]string); ok {
+		return m
+	}
+	env := os.Getenv(MirrorsEnv)
+	if env == "" {
+		return nil
+	}
+	m, err := ParseRegistryMirrors(strings.Split(env, ","))
+	if err != nil {
+		log.Warnf("ignoring $%s: %v", MirrorsEnv, err)
+		return nil
+	}
+	return m
+}
+
+// mirrorRefs returns ref in each mirror of its registry, followed by ref itself.
+func mirrorRefs(ctx context.Context, ref name.Reference) []name.Reference {
+	refs := []name.Reference{}
+	for _, mirror := range registryMirrors(ctx)[ref.Context().RegistryStr()] {
+		sep := ":"
+		if _, ok := ref.(name.Digest); ok {
+			sep = "@"
+		}
+		var opts []name.Option
+		if host := strings.TrimPrefix(mirror, "http://"); host != mirror {
+			mirror, opts = host, []name.Option{name.Insecure}
+		}
+		m, err := name.ParseReference(mirror+"/"+ref.Context().RepositoryStr()+sep+ref.Identifier(), opts...)
+		if err != nil {
+			log.Warnf("skipping mirror %s: %v", mirror, err)
+			continue
+		}
+		refs = append(refs, m)
+	}
+	return append(refs, ref)
+}
+
+// withMirrors calls fn with ref in each mirror of its registry until it succeeds, and finally with ref
+// itself. The error is the last one, from the registry itself.
+func withMirrors(ctx context.Context, ref name.Reference, fn func(name.Reference) error) error {
+	var err error
+	for _, r := range mirrorRefs(ctx, ref) {
+		if err = fn(r); err == nil {
+			return nil
+		}
+		if r != ref {
+			log.Debugf("%s: %v, falling back", r, err)
+		}
+	}
+	return err
+}
+
+// mirroredDescriptor is headDescriptor, trying the mirrors of ref's registry first.
+func mirroredDescriptor(ctx context.Context, ref name.Reference, opts []remote.Option) (desc *remote.Descriptor, err error) {
+	err = withMirrors(ctx, ref, func(r name.Reference) (err error) {
+		desc, err = headDescriptor(r, opts)
+		return err
+	})
+	return desc, err
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestParseRegistryMirrors(t *testing.T) {
+	got, err := ParseRegistryMirrors([]string{"https://mirror.example.com/", "gcr.io=gcr-mirror.example.com", "gcr.io=http://localhost:5000", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"index.docker.io": {"mirror.example.com"},
+		"gcr.io":          {"gcr-mirror.example.com", "http://localhost:5000"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseRegistryMirrors() = %v, want %v", got, want)
+	}
+	if _, err := ParseRegistryMirrors([]string{"gcr.io=mirror.example.com/gcr"}); err == nil {
+		t.Error("ParseRegistryMirrors() with a path, expected error")
+	}
+}
+
+func TestFetchSignaturesFromMirror(t *testing.T) {
+	source := httptest.NewServer(registry.New())
+	defer source.Close()
+	mirror := httptest.NewServer(registry.New())
+	defer mirror.Close()
+	sourceHost, mirrorHost := strings.TrimPrefix(source.URL, "http://"), strings.TrimPrefix(mirror.URL, "http://")
+	ctx := WithRegistryMirrors(context.Background(), map[string][]string{sourceHost: {mirrorHost}})
+
+	img, err := random.Image(100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(sourceHost + "/acme/app:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mirrored, err := name.ParseReference(mirrorHost + "/acme/app:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []name.Reference{ref, mirrored} {
+		if err := remote.Write(r, img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	desc, err := remote.Head(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload := func(r name.Reference, sig string) {
+		t.Helper()
+		if err := Upload(ctx, []byte(sig), []byte("payload"), r.Context().Tag(Munge(*desc)), SignatureMetadata{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The mirror doesn't have the signature yet, it's looked up in the source.
+	upload(ref, "c291cmNl")
+	sps, _, err := FetchSignatures(ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sps) != 1 || sps[0].Base64Signature != "c291cmNl" {
+		t.Errorf("FetchSignatures() = %+v, want the source's signature", sps)
+	}
+
+	// Only the mirror is reachable.
+	upload(mirrored, "bWlycm9y")
+	source.Close()
+	sps, _, err = FetchSignatures(ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sps) != 1 || sps[0].Base64Signature != "bWlycm9y" {
+		t.Errorf("FetchSignatures() = %+v, want the mirror's signature", sps)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pkg/errors"
+)
+
+// ArtifactKind is a kind of artifact stored next to an image, under a tag named after the image digest.
+type ArtifactKind string
+
+const (
+	// SignatureKind is the tag the signatures of an image are stored under.
+	SignatureKind ArtifactKind = "cosign"
+	// AttestationKind is the tag the attestations of an image are stored under.
+	AttestationKind ArtifactKind = "att"
+	// CountersignatureKind is the tag the countersignatures of an image's signatures are stored under.
+	CountersignatureKind ArtifactKind = "countersign"
+	// SBOMKind is the tag SBOMs attached to an image as they are, rather than as attestations, are stored under.
+	SBOMKind ArtifactKind = "sbom"
+)
+
+// ArtifactKinds are all the kinds of artifact the naming schemes know about.
+var ArtifactKinds = []ArtifactKind{SignatureKind, AttestationKind, CountersignatureKind, SBOMKind}
+
+func (k ArtifactKind) known() bool {
+	for _, kind := range ArtifactKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// TagScheme is a version of the convention artifact tags are named by. Tools that read the tags
+// should check for the schemes they understand, so that a new one can be introduced without them
+// mistaking its tags.
+type TagScheme int
+
+const (
+	// TagSchemeV1 names tags <algorithm>-<hex>.<kind> after the image digest, for example
+	// sha256-87ef60f5….cosign for the signatures of the image sha256:87ef60f5….
+	TagSchemeV1 TagScheme = 1
+)
+
+// DefaultTagScheme is the scheme this version of cosign writes and reads.
+const DefaultTagScheme = TagSchemeV1
+
+// hexLengths are the lengths of the digests the schemes accept, by algorithm.
+var hexLengths = map[string]int{"sha256": 64, "sha512": 128}
+
+// Tag returns the tag the kind artifact of the image with digest is stored under.
+func (s TagScheme) Tag(digest v1.Hash, kind ArtifactKind) (string, error) {
+	if s != TagSchemeV1 {
+		return "", errors.Errorf("unknown tag scheme %d", s)
+	}
+	return tagV1(digest, kind), nil
+}
+
+func tagV1(digest v1.Hash, kind ArtifactKind) string {
+	return fmt.Sprintf("%s-%s.%s", digest.Algorithm, digest.Hex, kind)
+}
+
+// ParseTag returns the image digest and kind of the artifact tag, or an error if tag isn't one.
+func (s TagScheme) ParseTag(tag string) (v1.Hash, ArtifactKind, error) {
+	if s != TagSchemeV1 {
+		return v1.Hash{}, "", errors.Errorf("unknown tag scheme %d", s)
+	}
+	i := strings.LastIndex(tag, ".")
+	if i < 0 {
+		return v1.Hash{}, "", errors.Errorf("%q is not an artifact tag", tag)
+	}
+	digest, kind := tag[:i], ArtifactKind(tag[i+1:])
+	if !kind.known() {
+		return v1.Hash{}, "", errors.Errorf("%q is not an artifact tag: unknown kind %q", tag, kind)
+	}
+	parts := strings.SplitN(digest, "-", 2)
+	if len(parts) != 2 || hexLengths[parts[0]] != len(parts[1]) || strings.Trim(parts[1], "0123456789abcdef") != "" {
+		return v1.Hash{}, "", errors.Errorf("%q is not an artifact tag: invalid digest", tag)
+	}
+	return v1.Hash{Algorithm: parts[0], Hex: parts[1]}, kind, nil
+}
+
+// ArtifactTag returns the tag the kind artifact of the image with digest is stored under, in the
+// default scheme.
+func ArtifactTag(digest v1.Hash, kind ArtifactKind) string {
+	return tagV1(digest, kind)
+}
+
+// ParseArtifactTag returns the image digest and kind of the artifact tag, in the default scheme.
+func ParseArtifactTag(tag string) (v1.Hash, ArtifactKind, error) {
+	return DefaultTagScheme.ParseTag(tag)
+}
+
+// ArtifactRef returns where the kind artifact of the image with digest is stored in repo. It is
+// the repository of the image, or the one from $COSIGN_REPOSITORY, see DestinationRef.
+func ArtifactRef(repo name.Repository, digest v1.Hash, kind ArtifactKind) name.Tag {
+	return repo.Tag(ArtifactTag(digest, kind))
+}
+
+// SubjectRef returns the image the artifact at tag is for, in the same repository, along with the
+// kind of artifact it is. Artifacts stored in another repository with $COSIGN_REPOSITORY don't
+// say which repository their image is in.
+func SubjectRef(tag name.Tag) (name.Digest, ArtifactKind, error) {
+	digest, kind, err := ParseArtifactTag(tag.TagStr())
+	if err != nil {
+		return name.Digest{}, "", err
+	}
+	return tag.Context().Digest(digest.String()), kind, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+const testHex = "87ef60f558bad79beea6425a3b28989f01dd417164150ab3baab98dcbf04def8"
+
+func TestArtifactTag(t *testing.T) {
+	digest := v1.Hash{Algorithm: "sha256", Hex: testHex}
+	for kind, want := range map[ArtifactKind]string{
+		SignatureKind:        "sha256-" + testHex + ".cosign",
+		AttestationKind:      "sha256-" + testHex + ".att",
+		CountersignatureKind: "sha256-" + testHex + ".countersign",
+		SBOMKind:             "sha256-" + testHex + ".sbom",
+	} {
+		tag, err := TagSchemeV1.Tag(digest, kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag != want || ArtifactTag(digest, kind) != want {
+			t.Errorf("Tag(%s) = %s, want %s", kind, tag, want)
+		}
+		gotDigest, gotKind, err := ParseArtifactTag(tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotDigest != digest || gotKind != kind {
+			t.Errorf("ParseArtifactTag(%s) = %s, %s", tag, gotDigest, gotKind)
+		}
+	}
+
+	// The tags existing signatures are stored under don't change.
+	if got := Munge(v1.Descriptor{Digest: digest}); got != "sha256-"+testHex+".cosign" {
+		t.Errorf("Munge() = %s", got)
+	}
+	if _, err := TagScheme(2).Tag(digest, SignatureKind); err == nil {
+		t.Error("Tag() with an unknown scheme, expected error")
+	}
+}
+
+func TestParseArtifactTagInvalid(t *testing.T) {
+	for _, tag := range []string{
+		"latest",
+		"v1.cosign",
+		"sha256-abc.cosign",
+		"sha256-" + testHex + ".sig",
+		"sha256-" + testHex,
+		"md5-" + testHex + ".cosign",
+		"sha256-" + testHex[:63] + "G.cosign",
+	} {
+		if _, _, err := ParseArtifactTag(tag); err == nil {
+			t.Errorf("ParseArtifactTag(%q) expected error", tag)
+		}
+	}
+}
+
+func TestSubjectRef(t *testing.T) {
+	repo, err := name.NewRepository("gcr.io/test/image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := v1.Hash{Algorithm: "sha256", Hex: testHex}
+	tag := ArtifactRef(repo, digest, AttestationKind)
+	if want := "gcr.io/test/image:sha256-" + testHex + ".att"; tag.String() != want {
+		t.Errorf("ArtifactRef() = %s, want %s", tag, want)
+	}
+	subject, kind, err := SubjectRef(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "gcr.io/test/image@sha256:" + testHex; subject.String() != want || kind != AttestationKind {
+		t.Errorf("SubjectRef() = %s, %s, want %s", subject, kind, want)
+	}
+}
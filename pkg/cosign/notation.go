@@ -0,0 +1,523 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// NotationArtifactType is the artifact type of Notary v2 (notation) signatures.
+	NotationArtifactType types.MediaType = "application/vnd.cncf.notary.signature"
+	// NotationJWSMediaType is the media type of the JWS envelopes notation signatures are stored in.
+	NotationJWSMediaType types.MediaType = "application/jose+json"
+
+	notationPayloadType   = "application/vnd.cncf.notary.payload.v1+json"
+	notationSigningScheme = "io.cncf.notary.signingScheme"
+	notationSigningTime   = "io.cncf.notary.signingTime"
+	notationAuthenticTime = "io.cncf.notary.authenticSigningTime"
+	notationExpiry        = "io.cncf.notary.expiry"
+	// notationSchemeX509 certificates must be valid when the signature is verified, and
+	// notationSchemeAuthority ones when the signing authority says the signature was made.
+	notationSchemeX509      = "notary.x509"
+	notationSchemeAuthority = "notary.x509.signingAuthority"
+)
+
+// NotationPayload is what a notation signature signs: the descriptor of the signed manifest.
+type NotationPayload struct {
+	TargetArtifact v1.Descriptor `json:"targetArtifact"`
+}
+
+type jwsEnvelope struct {
+	Payload   string         `json:"payload"`
+	Protected string         `json:"protected"`
+	Header    jwsUnprotected `json:"header"`
+	Signature string         `json:"signature"`
+}
+
+type jwsUnprotected struct {
+	// CertChain is the DER certificates, leaf first, which encoding/json writes as standard base64 like x5c requires.
+	CertChain    [][]byte `json:"x5c"`
+	SigningAgent string   `json:"io.cncf.notary.signingAgent,omitempty"`
+}
+
+type jwsProtected struct {
+	Algorithm     string     `json:"alg"`
+	Critical      []string   `json:"crit"`
+	ContentType   string     `json:"cty"`
+	SigningScheme string     `json:"io.cncf.notary.signingScheme"`
+	SigningTime   *time.Time `json:"io.cncf.notary.signingTime,omitempty"`
+	AuthenticTime *time.Time `json:"io.cncf.notary.authenticSigningTime,omitempty"`
+	Expiry        *time.Time `json:"io.cncf.notary.expiry,omitempty"`
+}
+
+// jwsAlgorithms maps the JWS algorithms notation signs with to their hash, and for ECDSA, to the
+// byte length of r and s.
+var jwsAlgorithms = map[string]struct {
+	hash    crypto.Hash
+	ecdsaSz int
+}{
+	"ES256": {crypto.SHA256, 32},
+	"ES384": {crypto.SHA384, 48},
+	"ES512": {crypto.SHA512, 66},
+	"PS256": {crypto.SHA256, 0},
+	"PS384": {crypto.SHA384, 0},
+	"PS512": {crypto.SHA512, 0},
+}
+
+// cosignJWSAlgorithms names the JWS algorithm of each signature algorithm cosign can sign notation signatures with.
+var cosignJWSAlgorithms = map[string]string{
+	AlgorithmECDSAP256SHA256: "ES256",
+	AlgorithmECDSAP384SHA384: "ES384",
+	AlgorithmECDSAP521SHA512: "ES512",
+}
+
+// NotationSignature returns a notation JWS envelope signing subject with signer, whose certificate
+// is the first in chain. Only ECDSA keys are supported.
+func NotationSignature(ctx context.Context, signer SignerVerifier, chain []*x509.Certificate, subject v1.Descriptor, signingTime time.Time) ([]byte, error) {
+	alg, ok := cosignJWSAlgorithms[signer.Algorithm()]
+	if !ok {
+		return nil, fmt.Errorf("notation signatures can't be made with %s keys", signer.Algorithm())
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("notation signatures need the signing certificate")
+	}
+	pub, err := signer.PublicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !samePublicKey(pub, chain[0].PublicKey) {
+		return nil, errors.New("the certificate isn't for the signing key")
+	}
+
+	payload, err := json.Marshal(NotationPayload{TargetArtifact: v1.Descriptor{
+		MediaType: subject.MediaType,
+		Digest:    subject.Digest,
+		Size:      subject.Size,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	signingTime = signingTime.UTC().Truncate(time.Second)
+	protected, err := json.Marshal(jwsProtected{
+		Algorithm:     alg,
+		Critical:      []string{notationSigningScheme},
+		ContentType:   notationPayloadType,
+		SigningScheme: notationSchemeX509,
+		SigningTime:   &signingTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+	env := jwsEnvelope{
+		Payload:   base64.RawURLEncoding.EncodeToString(payload),
+		Protected: base64.RawURLEncoding.EncodeToString(protected),
+		Header:    jwsUnprotected{SigningAgent: "cosign"},
+	}
+	for _, c := range chain {
+		env.Header.CertChain = append(env.Header.CertChain, c.Raw)
+	}
+	der, err := signer.Sign(ctx, []byte(env.Protected+"."+env.Payload))
+	if err != nil {
+		return nil, err
+	}
+	// JWS wants r and s concatenated, where cosign signs ASN.1.
+	var rs struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &rs); err != nil {
+		return nil, errors.Wrap(err, "parsing ECDSA signature")
+	}
+	sz := jwsAlgorithms[alg].ecdsaSz
+	raw := make([]byte, 2*sz)
+	rs.R.FillBytes(raw[:sz])
+	rs.S.FillBytes(raw[sz:])
+	env.Signature = base64.RawURLEncoding.EncodeToString(raw)
+	return json.Marshal(env)
+}
+
+// VerifyNotationSignature checks that envelope is a notation signature of subject, by a certificate
+// for code signing issued by roots, and returns the certificate.
+func VerifyNotationSignature(envelope []byte, subject v1.Descriptor, roots *x509.CertPool) (*x509.Certificate, error) {
+	env := jwsEnvelope{}
+	if err := json.Unmarshal(envelope, &env); err != nil {
+		return nil, errors.Wrap(err, "parsing JWS envelope")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(env.Protected)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding protected header")
+	}
+	hdr := jwsProtected{}
+	if err := json.Unmarshal(b, &hdr); err != nil {
+		return nil, errors.Wrap(err, "parsing protected header")
+	}
+	if hdr.ContentType != notationPayloadType {
+		return nil, fmt.Errorf("unexpected payload type %q", hdr.ContentType)
+	}
+	if err := checkNotationCritical(hdr); err != nil {
+		return nil, err
+	}
+	verifyTime := time.Now()
+	switch hdr.SigningScheme {
+	case notationSchemeX509:
+	case notationSchemeAuthority:
+		if hdr.AuthenticTime == nil {
+			return nil, fmt.Errorf("%s signature without %s", notationSchemeAuthority, notationAuthenticTime)
+		}
+		verifyTime = *hdr.AuthenticTime
+	default:
+		return nil, fmt.Errorf("unsupported signing scheme %q", hdr.SigningScheme)
+	}
+	if hdr.Expiry != nil && time.Now().After(*hdr.Expiry) {
+		return nil, fmt.Errorf("signature expired at %s", hdr.Expiry)
+	}
+
+	if len(env.Header.CertChain) == 0 {
+		return nil, errors.New("no certificate chain")
+	}
+	certs := []*x509.Certificate{}
+	for _, der := range env.Header.CertChain {
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing certificate chain")
+		}
+		certs = append(certs, c)
+	}
+	leaf, intermediates := certs[0], x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	if err := CheckFIPSKey(leaf.PublicKey); err != nil {
+		return nil, err
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   verifyTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return nil, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(env.Signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding signature")
+	}
+	if err := verifyJWS(hdr.Algorithm, leaf.PublicKey, []byte(env.Protected+"."+env.Payload), sig); err != nil {
+		return nil, err
+	}
+
+	b, err = base64.RawURLEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding payload")
+	}
+	p := NotationPayload{}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, errors.Wrap(err, "parsing payload")
+	}
+	if p.TargetArtifact.Digest != subject.Digest {
+		return nil, &ClaimError{Claim: ClaimDigest, Want: subject.Digest.String(), Got: p.TargetArtifact.Digest.String()}
+	}
+	if p.TargetArtifact.Size != subject.Size {
+		return nil, fmt.Errorf("signature is for a manifest of %d bytes, not %d", p.TargetArtifact.Size, subject.Size)
+	}
+	return leaf, nil
+}
+
+// checkNotationCritical checks that the signing scheme is marked critical, like notation requires,
+// and that every critical header is one this verifier checks.
+func checkNotationCritical(hdr jwsProtected) error {
+	known := map[string]bool{notationSigningScheme: true, notationAuthenticTime: true, notationExpiry: true}
+	hasScheme := false
+	for _, c := range hdr.Critical {
+		if !known[c] {
+			return fmt.Errorf("unsupported critical header %q", c)
+		}
+		hasScheme = hasScheme || c == notationSigningScheme
+	}
+	if !hasScheme {
+		return fmt.Errorf("%s isn't a critical header", notationSigningScheme)
+	}
+	return nil
+}
+
+func verifyJWS(alg string, pub crypto.PublicKey, signed, sig []byte) error {
+	a, ok := jwsAlgorithms[alg]
+	if !ok {
+		return fmt.Errorf("unsupported signature algorithm %q", alg)
+	}
+	digest := hashBytes(a.hash, signed)
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		if a.ecdsaSz == 0 || (pub.Curve.Params().BitSize+7)/8 != a.ecdsaSz || len(sig) != 2*a.ecdsaSz {
+			return fmt.Errorf("%s signature from a %s key", alg, pub.Curve.Params().Name)
+		}
+		r, s := new(big.Int).SetBytes(sig[:a.ecdsaSz]), new(big.Int).SetBytes(sig[a.ecdsaSz:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		if a.ecdsaSz != 0 {
+			return fmt.Errorf("%s signature from an RSA key", alg)
+		}
+		return rsa.VerifyPSS(pub, a.hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	}
+	return fmt.Errorf("unsupported certificate key type %T", pub)
+}
+
+func samePublicKey(a, b crypto.PublicKey) bool {
+	ab, err := x509.MarshalPKIXPublicKey(a)
+	if err != nil {
+		return false
+	}
+	bb, err := x509.MarshalPKIXPublicKey(b)
+	return err == nil && bytes.Equal(ab, bb)
+}
+
+// Referrer is a descriptor in a referrers list, which also has the referrer's artifact type.
+type Referrer struct {
+	v1.Descriptor
+	ArtifactType types.MediaType `json:"artifactType,omitempty"`
+}
+
+type referrersIndex struct {
+	SchemaVersion int64           `json:"schemaVersion"`
+	MediaType     types.MediaType `json:"mediaType"`
+	Manifests     []Referrer      `json:"manifests"`
+}
+
+// ReferrersTag is the tag registries without the referrers API keep the referrers of the
+// manifest with the digest in, following the OCI distribution spec.
+func ReferrersTag(repo name.Repository, digest v1.Hash) name.Tag {
+	return repo.Tag(fmt.Sprint(digest.Algorithm, "-", digest.Hex))
+}
+
+// Referrers lists the manifests whose subject is the manifest with the digest. It asks the referrers
+// API through rt, an authenticated transport for the repository, and falls back to the referrers
+// tag if rt is nil or the registry doesn't have the API. The second result says whether it did.
+func Referrers(ctx context.Context, repo name.Repository, digest v1.Hash, rt http.RoundTripper, opts ...remote.Option) ([]Referrer, bool, error) {
+	if rt != nil {
+		u := url.URL{
+			Scheme: repo.Registry.Scheme(),
+			Host:   repo.RegistryStr(),
+			Path:   fmt.Sprintf("/v2/%s/referrers/%s", repo.RepositoryStr(), digest),
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, false, err
+		}
+		resp, err := (&http.Client{Transport: rt}).Do(req)
+		if err != nil {
+			return nil, false, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			idx := referrersIndex{}
+			if err := json.NewDecoder(resp.Body).Decode(&idx); err != nil {
+				return nil, true, errors.Wrap(err, "parsing referrers")
+			}
+			return idx.Manifests, true, nil
+		}
+	}
+	idx, err := referrersFromTag(ReferrersTag(repo, digest), registryOpts(ctx, opts))
+	if err != nil {
+		return nil, false, err
+	}
+	return idx.Manifests, false, nil
+}
+
+// referrersFromTag returns the index at the referrers tag, or an empty one if there is none.
+func referrersFromTag(tag name.Tag, opts []remote.Option) (*referrersIndex, error) {
+	idx := &referrersIndex{SchemaVersion: 2, MediaType: types.OCIImageIndex, Manifests: []Referrer{}}
+	desc, err := remote.Get(tag, opts...)
+	if err != nil {
+		var te *transport.Error
+		if errors.As(err, &te) && te.StatusCode == http.StatusNotFound {
+			return idx, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(desc.Manifest, idx); err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", tag)
+	}
+	return idx, nil
+}
+
+// FetchNotationSignatures returns the descriptor of the image and the JWS envelopes of the notation
+// signatures referring to it. rt is as for Referrers.
+func FetchNotationSignatures(ctx context.Context, ref name.Reference, rt http.RoundTripper, opts ...remote.Option) (v1.Descriptor, [][]byte, error) {
+	opts = registryOpts(ctx, opts)
+	desc, err := remote.Get(ref, opts...)
+	if err != nil {
+		return v1.Descriptor{}, nil, err
+	}
+	subject := desc.Descriptor
+	referrers, _, err := Referrers(ctx, ref.Context(), subject.Digest, rt, opts...)
+	if err != nil {
+		return subject, nil, err
+	}
+	envelopes := [][]byte{}
+	for _, r := range referrers {
+		if r.ArtifactType != "" && r.ArtifactType != NotationArtifactType {
+			continue
+		}
+		img, err := remote.Image(ref.Context().Digest(r.Digest.String()), opts...)
+		if err != nil {
+			return subject, nil, err
+		}
+		m, err := img.Manifest()
+		if err != nil {
+			return subject, nil, err
+		}
+		if m.Config.MediaType != NotationArtifactType {
+			continue
+		}
+		layers, err := img.Layers()
+		if err != nil {
+			return subject, nil, err
+		}
+		for i, l := range layers {
+			if m.Layers[i].MediaType != NotationJWSMediaType {
+				continue
+			}
+			rc, err := l.Compressed()
+			if err != nil {
+				return subject, nil, err
+			}
+			b, err := ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return subject, nil, err
+			}
+			envelopes = append(envelopes, b)
+		}
+	}
+	return subject, envelopes, nil
+}
+
+// UploadNotationSignature pushes the JWS envelope as a notation signature of subject, in repo, and
+// returns its digest. Registries without the referrers API find it through the referrers tag,
+// which is updated for them. rt is as for Referrers.
+func UploadNotationSignature(ctx context.Context, repo name.Repository, subject v1.Descriptor, envelope []byte, rt http.RoundTripper, opts ...remote.Option) (v1.Hash, error) {
+	opts = registryOpts(ctx, opts)
+	img, err := mutate.Append(mutate.MediaType(empty.Image, types.OCIManifestSchema1), mutate.Addendum{
+		Layer: &staticLayer{b: envelope, mt: NotationJWSMediaType},
+	})
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	sig := &notationArtifact{
+		artifact: &artifact{Image: img, configMediaType: NotationArtifactType, config: []byte("{}")},
+		subject:  v1.Descriptor{MediaType: subject.MediaType, Digest: subject.Digest, Size: subject.Size},
+	}
+	digest, err := sig.Digest()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	if err := remote.Write(repo.Digest(digest.String()), sig, opts...); err != nil {
+		return v1.Hash{}, errors.Wrap(err, "pushing notation signature")
+	}
+
+	_, hasAPI, err := Referrers(ctx, repo, subject.Digest, rt, opts...)
+	if err != nil || hasAPI {
+		return digest, err
+	}
+	tag := ReferrersTag(repo, subject.Digest)
+	idx, err := referrersFromTag(tag, opts)
+	if err != nil {
+		return digest, err
+	}
+	for _, r := range idx.Manifests {
+		if r.Digest == digest {
+			return digest, nil
+		}
+	}
+	raw, err := sig.RawManifest()
+	if err != nil {
+		return digest, err
+	}
+	idx.Manifests = append(idx.Manifests, Referrer{
+		Descriptor:   v1.Descriptor{MediaType: types.OCIManifestSchema1, Digest: digest, Size: int64(len(raw))},
+		ArtifactType: NotationArtifactType,
+	})
+	b, err := json.Marshal(idx)
+	if err != nil {
+		return digest, err
+	}
+	if err := remote.Tag(tag, &rawManifest{b: b, mt: types.OCIImageIndex}, opts...); err != nil {
+		return digest, errors.Wrapf(err, "updating %s", tag)
+	}
+	return digest, nil
+}
+
+// notationArtifact is an artifact whose manifest also names its artifact type and subject,
+// which v1.Manifest doesn't have fields for yet.
+type notationArtifact struct {
+	*artifact
+	subject v1.Descriptor
+}
+
+func (a *notationArtifact) RawManifest() ([]byte, error) {
+	m, err := a.artifact.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		*v1.Manifest
+		ArtifactType types.MediaType `json:"artifactType"`
+		Subject      v1.Descriptor   `json:"subject"`
+	}{m, NotationArtifactType, a.subject})
+}
+
+func (a *notationArtifact) Digest() (v1.Hash, error) {
+	return partial.Digest(a)
+}
+
+// rawManifest is a manifest pushed as is.
+type rawManifest struct {
+	b  []byte
+	mt types.MediaType
+}
+
+func (r *rawManifest) RawManifest() ([]byte, error) {
+	return r.b, nil
+}
+
+func (r *rawManifest) MediaType() (types.MediaType, error) {
+	return r.mt, nil
+}
@@ -0,0 +1,227 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// notationCerts returns a root CA, and a code signing certificate it issued for pub.
+func notationCerts(t *testing.T, pub crypto.PublicKey) (*x509.CertPool, *x509.Certificate) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "notation test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "release"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTmpl, ca, pub, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(leafDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	return roots, leaf
+}
+
+func TestNotationSignature(t *testing.T) {
+	ctx := context.Background()
+	subject := v1.Descriptor{
+		MediaType: "application/vnd.oci.image.manifest.v1+json",
+		Digest:    v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("a", 64)},
+		Size:      1234,
+	}
+	other := subject
+	other.Digest.Hex = strings.Repeat("b", 64)
+
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots, leaf := notationCerts(t, &priv.PublicKey)
+		env, err := NotationSignature(ctx, WithECDSAKey(priv), []*x509.Certificate{leaf}, subject, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := VerifyNotationSignature(env, subject, roots)
+		if err != nil {
+			t.Fatalf("%s: %v", curve.Params().Name, err)
+		}
+		if got.Subject.CommonName != "release" {
+			t.Errorf("signer = %s, want release", got.Subject)
+		}
+		if _, err := VerifyNotationSignature(env, other, roots); err == nil {
+			t.Error("expected error verifying the signature of another manifest")
+		}
+		otherRoots, _ := notationCerts(t, &priv.PublicKey)
+		if _, err := VerifyNotationSignature(env, subject, otherRoots); err == nil {
+			t.Error("expected error verifying against untrusted roots")
+		}
+	}
+
+	// The certificate must be for the signing key.
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, leaf := notationCerts(t, &priv.PublicKey)
+	otherPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NotationSignature(ctx, WithECDSAKey(otherPriv), []*x509.Certificate{leaf}, subject, time.Now()); err == nil {
+		t.Error("expected error signing with a key the certificate isn't for")
+	}
+}
+
+// TestVerifyNotationRSA checks signatures like notation makes with its default RSA keys.
+func TestVerifyNotationRSA(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots, leaf := notationCerts(t, &priv.PublicKey)
+	subject := v1.Descriptor{
+		MediaType: "application/vnd.docker.distribution.manifest.v2+json",
+		Digest:    v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("c", 64)},
+		Size:      527,
+	}
+	envelope := func(crit string) []byte {
+		protected := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"PS256","crit":[` + crit + `],"cty":"application/vnd.cncf.notary.payload.v1+json","io.cncf.notary.signingScheme":"notary.x509","io.cncf.notary.signingTime":"2022-08-24T10:00:00Z"}`))
+		payload, err := json.Marshal(NotationPayload{TargetArtifact: subject})
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded := base64.RawURLEncoding.EncodeToString(payload)
+		sig, err := rsa.SignPSS(rand.Reader, priv, crypto.SHA256, hashBytes(crypto.SHA256, []byte(protected+"."+encoded)), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.Marshal(jwsEnvelope{
+			Payload:   encoded,
+			Protected: protected,
+			Header:    jwsUnprotected{CertChain: [][]byte{leaf.Raw}},
+			Signature: base64.RawURLEncoding.EncodeToString(sig),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	if _, err := VerifyNotationSignature(envelope(`"io.cncf.notary.signingScheme"`), subject, roots); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyNotationSignature(envelope(`"io.cncf.notary.signingScheme","io.cncf.notary.unknown"`), subject, roots); err == nil {
+		t.Error("expected error verifying a signature with an unknown critical header")
+	}
+	if _, err := VerifyNotationSignature(envelope(""), subject, roots); err == nil {
+		t.Error("expected error verifying a signature whose signing scheme isn't critical")
+	}
+}
+
+func TestUploadNotationSignature(t *testing.T) {
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	ref, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/notation:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	desc, err := remote.Get(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots, leaf := notationCerts(t, &priv.PublicKey)
+	env, err := NotationSignature(ctx, WithECDSAKey(priv), []*x509.Certificate{leaf}, desc.Descriptor, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Uploading the same signature twice lists it once.
+	for i := 0; i < 2; i++ {
+		if _, err := UploadNotationSignature(ctx, ref.Context(), desc.Descriptor, env, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	subject, envelopes, err := FetchNotationSignatures(ctx, ref, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(envelopes) != 1 {
+		t.Fatalf("got %d notation signatures, want 1", len(envelopes))
+	}
+	if _, err := VerifyNotationSignature(envelopes[0], subject, roots); err != nil {
+		t.Error(err)
+	}
+}
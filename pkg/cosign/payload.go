@@ -15,27 +15,95 @@
 package cosign
 
 import (
+	"bytes"
 	"encoding/json"
+	"os"
+	"strconv"
+	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pkg/errors"
 )
 
+// SourceDateEpochEnv sets the creation time recorded in signatures, as seconds since the epoch,
+// for reproducible builds. See https://reproducible-builds.org/specs/source-date-epoch/.
+const SourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
 type ImagePayload struct {
 	Img         v1.Descriptor
 	Annotations map[string]string
+	// Reference is the repository the image is signed in, recorded as the docker-reference claim.
+	Reference string
 }
 
+// MarshalJSON returns the simple signing payload for the image. Its fields and annotations are
+// always in the same order, so the same image and annotations always give the same payload.
 func (p *ImagePayload) MarshalJSON() ([]byte, error) {
+	annotations := p.Annotations
+	if len(annotations) == 0 {
+		annotations = nil
+	}
 	simpleSigning := SimpleSigning{
 		Critical: Critical{
+			Identity: Identity{
+				DockerReference: p.Reference,
+			},
 			Image: Image{
 				DockerManifestDigest: p.Img.Digest.String(),
 			},
 			Type: "cosign container signature",
 		},
-		Optional: p.Annotations,
+		Optional: annotations,
 	}
 	return json.Marshal(simpleSigning)
 }
 
 //TODO: Unmarshal JSON
+
+// ValidatePayload checks that a payload provided instead of a generated one is a simple signing
+// payload whose docker-manifest-digest claim is digest. Anything else in it is left to the user,
+// like extra critical fields expected by other policy engines.
+func ValidatePayload(payload []byte, digest v1.Hash) error {
+	ss := &SimpleSigning{}
+	if err := json.Unmarshal(payload, ss); err != nil {
+		return errors.Wrap(err, "payload is not a simple signing payload")
+	}
+	if got := ss.Critical.Image.DockerManifestDigest; got != digest.String() {
+		return &ClaimError{Claim: ClaimDigest, Want: digest.String(), Got: got}
+	}
+	return nil
+}
+
+// CanonicalJSON re-encodes the JSON document b with object keys sorted, no insignificant
+// whitespace and numbers kept as written, so equal documents always have the same bytes.
+func CanonicalJSON(b []byte) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, errors.New("unexpected data after the JSON document")
+	}
+	buf := &bytes.Buffer{}
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// CreationTime returns the time to record in signatures: $SOURCE_DATE_EPOCH if it is set, or now.
+func CreationTime() (time.Time, error) {
+	epoch := os.Getenv(SourceDateEpochEnv)
+	if epoch == "" {
+		return time.Now(), nil
+	}
+	sec, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "parsing $%s", SourceDateEpochEnv)
+	}
+	return time.Unix(sec, 0), nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	want := `{"a":[1,2.50,{"c":"<d>","e":null}],"b":true}`
+	for _, in := range []string{
+		want,
+		`{"b": true, "a": [1, 2.50, {"e": null, "c": "<d>"}]}`,
+		"{\n  \"a\": [\n    1,\n    2.50,\n    {\"c\": \"\\u003cd\\u003e\", \"e\": null}\n  ],\n  \"b\": true\n}\n",
+	} {
+		got, err := CanonicalJSON([]byte(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("CanonicalJSON(%q) = %s, want %s", in, got, want)
+		}
+	}
+	for _, bad := range []string{"", "{", `{"a":1} {"b":2}`} {
+		if _, err := CanonicalJSON([]byte(bad)); err == nil {
+			t.Errorf("CanonicalJSON(%q) expected error", bad)
+		}
+	}
+}
+
+func TestImagePayloadDeterministic(t *testing.T) {
+	digest, err := v1.NewHash("sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := v1.Descriptor{Digest: digest}
+	empty, err := json.Marshal(&ImagePayload{Img: img, Annotations: map[string]string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	none, err := json.Marshal(&ImagePayload{Img: img})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(empty) != string(none) {
+		t.Errorf("payload with empty annotations = %s, want %s", empty, none)
+	}
+
+	annotations := map[string]string{}
+	for _, k := range []string{"z", "a", "m", "b", "y"} {
+		annotations[k] = k
+	}
+	first, err := json.Marshal(&ImagePayload{Img: img, Annotations: annotations})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		got, err := json.Marshal(&ImagePayload{Img: img, Annotations: annotations})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(first) {
+			t.Fatalf("payload = %s, want %s", got, first)
+		}
+	}
+}
+
+func TestCreationTime(t *testing.T) {
+	old, ok := os.LookupEnv(SourceDateEpochEnv)
+	defer func() {
+		if ok {
+			os.Setenv(SourceDateEpochEnv, old)
+		} else {
+			os.Unsetenv(SourceDateEpochEnv)
+		}
+	}()
+
+	os.Setenv(SourceDateEpochEnv, "1617278400")
+	got, err := CreationTime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("CreationTime() = %v, want %v", got, want)
+	}
+
+	os.Setenv(SourceDateEpochEnv, "yesterday")
+	if _, err := CreationTime(); err == nil {
+		t.Error("expected error for an invalid $SOURCE_DATE_EPOCH")
+	}
+
+	os.Unsetenv(SourceDateEpochEnv)
+	if got, err := CreationTime(); err != nil || time.Since(got) > time.Minute {
+		t.Errorf("CreationTime() = %v, %v, want now", got, err)
+	}
+}
+
+func TestValidatePayload(t *testing.T) {
+	digest, err := v1.NewHash("sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Extra critical fields, as containers/image policies may expect, are allowed.
+	good := `{"critical":{"identity":{"docker-reference":"example.com/demo"},"image":{"docker-manifest-digest":"sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},"type":"atomic container signature","extra":"value"},"optional":{"creator":"ci"}}`
+	if err := ValidatePayload([]byte(good), digest); err != nil {
+		t.Errorf("ValidatePayload() = %v", err)
+	}
+	for _, bad := range []string{
+		"not json",
+		`{"critical":{"image":{"docker-manifest-digest":"sha256:abc"}}}`,
+		`{"optional":{"creator":"ci"}}`,
+	} {
+		if err := ValidatePayload([]byte(bad), digest); err == nil {
+			t.Errorf("ValidatePayload(%s) expected error", bad)
+		}
+	}
+}
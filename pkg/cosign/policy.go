@@ -0,0 +1,158 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+)
+
+const (
+	// PolicyRepository is the repository a signed policy is stored in, under the repository or
+	// namespace it applies to, e.g. gcr.io/acme/cosign-policy for everything in gcr.io/acme.
+	PolicyRepository = "cosign-policy"
+	// PolicyTag is the tag of the signed policy in PolicyRepository.
+	PolicyTag = "v1"
+
+	policyMediaType = "application/vnd.dev.cosign.policy.v1+json"
+)
+
+// ErrNoPolicy is returned by FetchPolicy when no policy applies to a repository.
+var ErrNoPolicy = errors.New("no signed policy found")
+
+// PolicyDocument is the signed payload of a policy.
+type PolicyDocument struct {
+	// Scope is the repository or namespace the policy applies to, so a signed policy can't be
+	// copied to another one.
+	Scope  string       `json:"scope"`
+	Policy TrustProfile `json:"policy"`
+}
+
+// PolicyRef returns where the policy for the repositories in scope is stored.
+func PolicyRef(scope name.Repository) (name.Tag, error) {
+	return name.NewTag(fmt.Sprintf("%s/%s:%s", scope.Name(), PolicyRepository, PolicyTag), sameScheme(scope.Registry)...)
+}
+
+// sameScheme keeps using plain HTTP for references in reg if it was allowed to.
+func sameScheme(reg name.Registry) []name.Option {
+	if reg.Scheme() == "http" {
+		return []name.Option{name.Insecure}
+	}
+	return nil
+}
+
+// PolicyScopes returns the scopes whose policy could apply to repo, most specific first: repo
+// itself, then each namespace it is in.
+func PolicyScopes(repo name.Repository) []name.Repository {
+	scopes := []name.Repository{repo}
+	path := repo.RepositoryStr()
+	for i := strings.LastIndex(path, "/"); i > 0; i = strings.LastIndex(path, "/") {
+		path = path[:i]
+		scope, err := name.NewRepository(repo.RegistryStr()+"/"+path, sameScheme(repo.Registry)...)
+		if err != nil {
+			break
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes
+}
+
+// SignPolicy signs tp as the policy for scope and stores it at PolicyRef(scope), replacing the
+// policy that was there.
+func SignPolicy(ctx context.Context, signer SignerVerifier, scope name.Repository, tp *TrustProfile, opts ...remote.Option) (name.Tag, error) {
+	dst, err := PolicyRef(scope)
+	if err != nil {
+		return dst, err
+	}
+	payload, err := json.Marshal(PolicyDocument{Scope: scope.Name(), Policy: *tp})
+	if err != nil {
+		return dst, err
+	}
+	signature, err := signer.Sign(ctx, payload)
+	if err != nil {
+		return dst, errors.Wrap(err, "signing policy")
+	}
+	annotations := map[string]string{sigkey: base64.StdEncoding.EncodeToString(signature)}
+	if keyID, err := signer.KeyID(ctx); err == nil {
+		annotations[keyidkey] = keyID
+	}
+	if alg := signer.Algorithm(); alg != "" {
+		annotations[algkey] = alg
+	}
+	img, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:       &staticLayer{b: payload, mt: policyMediaType},
+		Annotations: annotations,
+	})
+	if err != nil {
+		return dst, err
+	}
+	return dst, remote.Write(dst, img, registryOpts(ctx, opts)...)
+}
+
+// FetchPolicy returns the signed policy of the most specific scope of repo that has one, see
+// PolicyScopes. It must be signed by one of keys for that scope, a policy that isn't fails rather
+// than falling back to a less specific one. The error wraps ErrNoPolicy if there is none.
+func FetchPolicy(ctx context.Context, repo name.Repository, keys []PublicKey, opts ...remote.Option) (*TrustProfile, error) {
+	opts = registryOpts(ctx, opts)
+	for _, scope := range PolicyScopes(repo) {
+		ref, err := PolicyRef(scope)
+		if err != nil {
+			return nil, err
+		}
+		sps, err := fetchSignedPayloads(ctx, ref, opts)
+		if errors.Is(err, ErrNoSignatures) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "fetching policy %s", ref)
+		}
+		return verifyPolicy(ctx, ref, scope, sps, keys)
+	}
+	return nil, fmt.Errorf("%s: %w", repo, ErrNoPolicy)
+}
+
+// verifyPolicy returns the policy in sps signed by one of keys for scope.
+func verifyPolicy(ctx context.Context, ref name.Tag, scope name.Repository, sps []SignedPayload, keys []PublicKey) (*TrustProfile, error) {
+	for _, sp := range sps {
+		sig, err := base64.StdEncoding.DecodeString(sp.Base64Signature)
+		if err != nil {
+			continue
+		}
+		for _, k := range keys {
+			if k.Verify(ctx, sp.Payload, sig) != nil {
+				continue
+			}
+			doc := PolicyDocument{}
+			if err := json.Unmarshal(sp.Payload, &doc); err != nil {
+				return nil, errors.Wrapf(err, "parsing policy %s", ref)
+			}
+			if doc.Scope != scope.Name() {
+				return nil, fmt.Errorf("policy %s was signed for %s, not %s", ref, doc.Scope, scope.Name())
+			}
+			doc.Policy.Name = ref.String()
+			return &doc.Policy, nil
+		}
+	}
+	return nil, fmt.Errorf("policy %s is not signed by a trusted key", ref)
+}
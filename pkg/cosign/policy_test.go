@@ -0,0 +1,120 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestPolicyScopes(t *testing.T) {
+	repo, err := name.NewRepository("gcr.io/acme/team/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range PolicyScopes(repo) {
+		ref, err := PolicyRef(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ref.String())
+	}
+	want := []string{
+		"gcr.io/acme/team/app/cosign-policy:v1",
+		"gcr.io/acme/team/cosign-policy:v1",
+		"gcr.io/acme/cosign-policy:v1",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("policy locations = %v, want %v", got, want)
+	}
+}
+
+func TestSignedPolicy(t *testing.T) {
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+	org, err := name.NewRepository(host + "/acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := name.NewRepository(host + "/acme/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := WithECDSAKey(priv)
+	keys := []PublicKey{&ECDSAPublicKey{&priv.PublicKey}}
+
+	if _, err := FetchPolicy(ctx, app, keys); !errors.Is(err, ErrNoPolicy) {
+		t.Errorf("FetchPolicy() without a policy = %v, want ErrNoPolicy", err)
+	}
+
+	// The org's policy applies to its repositories.
+	tp := &TrustProfile{Identities: []string{"builder@acme.example"}}
+	if _, err := SignPolicy(ctx, signer, org, tp); err != nil {
+		t.Fatal(err)
+	}
+	got, err := FetchPolicy(ctx, app, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Identities) != 1 || got.Identities[0] != "builder@acme.example" {
+		t.Errorf("FetchPolicy() = %+v", got)
+	}
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FetchPolicy(ctx, app, []PublicKey{&ECDSAPublicKey{&other.PublicKey}}); err == nil || errors.Is(err, ErrNoPolicy) {
+		t.Errorf("FetchPolicy() with an untrusted key = %v, want a verification error", err)
+	}
+
+	// A policy copied from the org to the repository isn't accepted for it.
+	orgRef, err := PolicyRef(org)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appRef, err := PolicyRef(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := remote.Image(orgRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(appRef, img); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FetchPolicy(ctx, app, keys); err == nil || !strings.Contains(err.Error(), "was signed for") {
+		t.Errorf("FetchPolicy() with a copied policy = %v, want a scope error", err)
+	}
+}
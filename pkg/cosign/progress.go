@@ -0,0 +1,79 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ProgressFunc is told, as a blob is transferred, how many of its total bytes are done.
+// It may be called from several goroutines at once, for different blobs.
+type ProgressFunc func(name string, done, total int64)
+
+type progressKey struct{}
+
+// WithProgress returns a context under which pushing and pulling files and attestations reports to p.
+func WithProgress(ctx context.Context, p ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, p)
+}
+
+// withProgress returns l, reporting reads of its contents as name to the ProgressFunc of ctx, if there is one.
+func withProgress(ctx context.Context, l v1.Layer, name string) v1.Layer {
+	p, ok := ctx.Value(progressKey{}).(ProgressFunc)
+	if !ok || p == nil {
+		return l
+	}
+	return &progressLayer{Layer: l, name: name, report: p}
+}
+
+// progressLayer reports how much of the layer has been read, through uploads and downloads alike.
+type progressLayer struct {
+	v1.Layer
+	name   string
+	report ProgressFunc
+}
+
+// Compressed returns the contents of the layer, as stored in the registry.
+func (l *progressLayer) Compressed() (io.ReadCloser, error) {
+	size, err := l.Layer.Size()
+	if err != nil {
+		return nil, err
+	}
+	rc, err := l.Layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	l.report(l.name, 0, size)
+	return &progressReader{ReadCloser: rc, name: l.name, total: size, report: l.report}, nil
+}
+
+type progressReader struct {
+	io.ReadCloser
+	name        string
+	done, total int64
+	report      ProgressFunc
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.done += int64(n)
+		r.report(r.name, r.done, r.total)
+	}
+	return n, err
+}
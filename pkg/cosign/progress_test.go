@@ -0,0 +1,48 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+)
+
+func TestWithProgress(t *testing.T) {
+	l := &staticLayer{b: []byte("attestation"), mt: AttestationMediaType}
+	if got := withProgress(context.Background(), l, "att"); got != l {
+		t.Error("layer was wrapped without a ProgressFunc")
+	}
+
+	var updates [][2]int64
+	ctx := WithProgress(context.Background(), func(name string, done, total int64) {
+		if name != "att" {
+			t.Errorf("progress reported for %q", name)
+		}
+		updates = append(updates, [2]int64{done, total})
+	})
+	rc, err := withProgress(ctx, l, "att").Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if _, err := ioutil.ReadAll(rc); err != nil {
+		t.Fatal(err)
+	}
+	size := int64(len(l.b))
+	if len(updates) < 2 || updates[0] != [2]int64{0, size} || updates[len(updates)-1] != [2]int64{size, size} {
+		t.Errorf("updates = %v, want from 0 to %d", updates, size)
+	}
+}
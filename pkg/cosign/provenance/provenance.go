@@ -0,0 +1,241 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package provenance generates SLSA provenance of the build cosign runs in, from what the CI
+// system tells its jobs in environment variables and event payloads.
+package provenance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+const (
+	// GitHubRecipeType is the recipe type of GitHub Actions workflow runs.
+	GitHubRecipeType = "https://github.com/Attestations/GitHubActionsWorkflow@v1"
+	// GitHubHostedBuilder is the builder ID of GitHub Actions, for runners that don't set $GITHUB_WORKFLOW_REF.
+	GitHubHostedBuilder = "https://github.com/Attestations/GitHubHostedActions@v1"
+	// GitLabRecipeType is the recipe type of GitLab CI jobs.
+	GitLabRecipeType = "https://cosign.sigstore.dev/recipe/gitlab-ci/v1"
+	// TektonRecipeType is the recipe type of Tekton TaskRuns.
+	TektonRecipeType = "https://cosign.sigstore.dev/recipe/tekton-taskrun/v1"
+	// TektonBuilder is the builder ID of Tekton TaskRuns that don't set $TEKTON_BUILDER_ID.
+	TektonBuilder = "https://tekton.dev/pipelines"
+)
+
+// generators make the provenance of the build running in each CI system.
+var generators = map[string]func(getenv func(string) string) (*cosign.SLSAProvenance, error){
+	"github": gitHub,
+	"gitlab": gitLab,
+	"tekton": tekton,
+}
+
+// Systems returns the names of the CI systems provenance can be generated in, sorted.
+func Systems() []string {
+	names := make([]string, 0, len(generators))
+	for n := range generators {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Generate returns the SLSA provenance of the build running in the CI system, one of Systems,
+// from the environment variables getenv (usually os.Getenv) returns.
+func Generate(system string, getenv func(string) string) (*cosign.SLSAProvenance, error) {
+	g, ok := generators[system]
+	if !ok {
+		return nil, fmt.Errorf("unsupported CI system %q, use one of %s", system, strings.Join(Systems(), ", "))
+	}
+	return g(getenv)
+}
+
+// required returns the values of the variables, failing if any of them isn't set.
+func required(system string, getenv func(string) string, names ...string) ([]string, error) {
+	values := make([]string, 0, len(names))
+	missing := []string{}
+	for _, n := range names {
+		v := getenv(n)
+		if v == "" {
+			missing = append(missing, "$"+n)
+		}
+		values = append(values, v)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("not running in %s: %s not set", system, strings.Join(missing, ", "))
+	}
+	return values, nil
+}
+
+// environment returns the variables that are set as a JSON object, with their names in lower case.
+func environment(getenv func(string) string, names ...string) (json.RawMessage, error) {
+	env := map[string]string{}
+	for _, n := range names {
+		if v := getenv(n); v != "" {
+			env[strings.ToLower(n)] = v
+		}
+	}
+	return json.Marshal(env)
+}
+
+// payloadField returns field of the JSON event payload at path, or nil if the payload doesn't have it.
+func payloadField(path, field string) (json.RawMessage, error) {
+	b, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading event payload")
+	}
+	payload := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &payload); err != nil {
+		return nil, errors.Wrapf(err, "parsing event payload %s", path)
+	}
+	if v := payload[field]; len(v) > 0 && string(v) != "null" {
+		return v, nil
+	}
+	return nil, nil
+}
+
+// gitMaterial is the source checked out at ref, at commit.
+func gitMaterial(url, ref, commit string) cosign.SLSAMaterial {
+	m := cosign.SLSAMaterial{URI: "git+" + url}
+	if ref != "" {
+		m.URI += "@" + ref
+	}
+	if commit != "" {
+		m.Digest = map[string]string{"sha1": commit}
+	}
+	return m
+}
+
+// gitHub is the provenance of a GitHub Actions workflow run. The builder is the workflow, so
+// -trusted-builders can name the workflows allowed to build an image. The arguments are the
+// inputs of workflow_dispatch events, the only runs with arguments.
+func gitHub(getenv func(string) string) (*cosign.SLSAProvenance, error) {
+	v, err := required("GitHub Actions", getenv, "GITHUB_REPOSITORY", "GITHUB_SHA", "GITHUB_RUN_ID")
+	if err != nil {
+		return nil, err
+	}
+	repo, sha, runID := v[0], v[1], v[2]
+	server := getenv("GITHUB_SERVER_URL")
+	if server == "" {
+		server = "https://github.com"
+	}
+	attempt := getenv("GITHUB_RUN_ATTEMPT")
+	if attempt == "" {
+		attempt = "1"
+	}
+
+	p := &cosign.SLSAProvenance{}
+	p.Builder.ID = GitHubHostedBuilder
+	p.Recipe.Type = GitHubRecipeType
+	p.Recipe.EntryPoint = getenv("GITHUB_WORKFLOW")
+	// owner/repo/.github/workflows/build.yml@refs/heads/main
+	if wf := getenv("GITHUB_WORKFLOW_REF"); wf != "" {
+		p.Builder.ID = server + "/" + wf
+		p.Recipe.EntryPoint = strings.TrimPrefix(strings.SplitN(wf, "@", 2)[0], repo+"/")
+	}
+	if path := getenv("GITHUB_EVENT_PATH"); path != "" {
+		if p.Recipe.Arguments, err = payloadField(path, "inputs"); err != nil {
+			return nil, err
+		}
+		p.Metadata.Completeness.Arguments = true
+	}
+	if p.Recipe.Environment, err = environment(getenv, "GITHUB_EVENT_NAME", "GITHUB_REF", "GITHUB_ACTOR",
+		"GITHUB_RUN_ID", "GITHUB_RUN_ATTEMPT", "RUNNER_OS", "RUNNER_ARCH"); err != nil {
+		return nil, err
+	}
+	p.Metadata.BuildInvocationID = fmt.Sprintf("%s/%s/actions/runs/%s/attempts/%s", server, repo, runID, attempt)
+	p.Materials = []cosign.SLSAMaterial{gitMaterial(server+"/"+repo, getenv("GITHUB_REF"), sha)}
+	return p, nil
+}
+
+// gitLab is the provenance of a GitLab CI job. The builder is the runner that ran it. Only
+// pipelines started by a trigger have arguments that can be recorded, the variables of their
+// $TRIGGER_PAYLOAD.
+func gitLab(getenv func(string) string) (*cosign.SLSAProvenance, error) {
+	v, err := required("GitLab CI", getenv, "CI_PROJECT_URL", "CI_COMMIT_SHA", "CI_JOB_URL", "CI_RUNNER_ID")
+	if err != nil {
+		return nil, err
+	}
+	project, sha, jobURL, runner := v[0], v[1], v[2], v[3]
+
+	p := &cosign.SLSAProvenance{}
+	p.Builder.ID = project + "/-/runners/" + runner
+	p.Recipe.Type = GitLabRecipeType
+	p.Recipe.EntryPoint = getenv("CI_CONFIG_PATH")
+	if p.Recipe.EntryPoint == "" {
+		p.Recipe.EntryPoint = ".gitlab-ci.yml"
+	}
+	if path := getenv("TRIGGER_PAYLOAD"); path != "" {
+		if p.Recipe.Arguments, err = payloadField(path, "variables"); err != nil {
+			return nil, err
+		}
+		p.Metadata.Completeness.Arguments = true
+	}
+	if p.Recipe.Environment, err = environment(getenv, "CI_JOB_NAME", "CI_JOB_ID", "CI_PIPELINE_ID",
+		"CI_PIPELINE_SOURCE", "CI_COMMIT_REF_NAME", "GITLAB_USER_LOGIN", "CI_RUNNER_DESCRIPTION"); err != nil {
+		return nil, err
+	}
+	p.Metadata.BuildInvocationID = jobURL
+	p.Materials = []cosign.SLSAMaterial{gitMaterial(project, getenv("CI_COMMIT_REF_NAME"), sha)}
+	return p, nil
+}
+
+// tekton is the provenance of a Tekton TaskRun. Tekton doesn't set any variables in its steps,
+// so the step running cosign maps the TaskRun's context, and the source it built, to these:
+//
+//	TEKTON_TASKRUN_NAME       $(context.taskRun.name)
+//	TEKTON_TASKRUN_NAMESPACE  $(context.taskRun.namespace)
+//	TEKTON_TASKRUN_UID        $(context.taskRun.uid)
+//	TEKTON_TASK_NAME          $(context.task.name)
+//	TEKTON_PIPELINERUN_NAME   $(context.pipelineRun.name), in a pipeline
+//	TEKTON_GIT_URL            the repository the source was cloned from
+//	TEKTON_GIT_COMMIT         the commit that was cloned
+//	TEKTON_BUILDER_ID         the builder ID, TektonBuilder by default
+//
+// The TaskRun's parameters aren't recorded.
+func tekton(getenv func(string) string) (*cosign.SLSAProvenance, error) {
+	v, err := required("Tekton", getenv, "TEKTON_TASKRUN_NAME", "TEKTON_TASKRUN_NAMESPACE")
+	if err != nil {
+		return nil, err
+	}
+	name, namespace := v[0], v[1]
+
+	p := &cosign.SLSAProvenance{}
+	p.Builder.ID = getenv("TEKTON_BUILDER_ID")
+	if p.Builder.ID == "" {
+		p.Builder.ID = TektonBuilder
+	}
+	p.Recipe.Type = TektonRecipeType
+	p.Recipe.EntryPoint = getenv("TEKTON_TASK_NAME")
+	if p.Recipe.Environment, err = environment(getenv, "TEKTON_TASKRUN_NAME", "TEKTON_TASKRUN_NAMESPACE",
+		"TEKTON_PIPELINERUN_NAME"); err != nil {
+		return nil, err
+	}
+	p.Metadata.BuildInvocationID = getenv("TEKTON_TASKRUN_UID")
+	if p.Metadata.BuildInvocationID == "" {
+		p.Metadata.BuildInvocationID = "namespaces/" + namespace + "/taskruns/" + name
+	}
+	if url := getenv("TEKTON_GIT_URL"); url != "" {
+		p.Materials = []cosign.SLSAMaterial{gitMaterial(url, "", getenv("TEKTON_GIT_COMMIT"))}
+	}
+	return p, nil
+}
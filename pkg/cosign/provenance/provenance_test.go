@@ -0,0 +1,168 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provenance
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+// env returns a getenv func for the variables.
+func env(vars map[string]string) func(string) string {
+	return func(k string) string { return vars[k] }
+}
+
+func TestGenerate(t *testing.T) {
+	td := t.TempDir()
+	event := filepath.Join(td, "event.json")
+	if err := ioutil.WriteFile(event, []byte(`{"inputs":{"version":"1.2.3"},"ref":"refs/heads/main"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	trigger := filepath.Join(td, "trigger.json")
+	if err := ioutil.WriteFile(trigger, []byte(`{"ref":"main"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		system       string
+		vars         map[string]string
+		builder      string
+		recipeType   string
+		entryPoint   string
+		invocation   string
+		arguments    string
+		material     cosign.SLSAMaterial
+		level        int
+		wantEnvField string
+	}{{
+		system: "github",
+		vars: map[string]string{
+			"GITHUB_SERVER_URL":   "https://github.com",
+			"GITHUB_REPOSITORY":   "acme/app",
+			"GITHUB_SHA":          "0123456789abcdef0123456789abcdef01234567",
+			"GITHUB_REF":          "refs/heads/main",
+			"GITHUB_RUN_ID":       "42",
+			"GITHUB_RUN_ATTEMPT":  "2",
+			"GITHUB_WORKFLOW":     "Release",
+			"GITHUB_WORKFLOW_REF": "acme/app/.github/workflows/release.yml@refs/heads/main",
+			"GITHUB_EVENT_NAME":   "workflow_dispatch",
+			"GITHUB_EVENT_PATH":   event,
+		},
+		builder:      "https://github.com/acme/app/.github/workflows/release.yml@refs/heads/main",
+		recipeType:   GitHubRecipeType,
+		entryPoint:   ".github/workflows/release.yml",
+		invocation:   "https://github.com/acme/app/actions/runs/42/attempts/2",
+		arguments:    `{"version":"1.2.3"}`,
+		material:     cosign.SLSAMaterial{URI: "git+https://github.com/acme/app@refs/heads/main", Digest: map[string]string{"sha1": "0123456789abcdef0123456789abcdef01234567"}},
+		level:        2,
+		wantEnvField: "github_event_name",
+	}, {
+		system: "gitlab",
+		vars: map[string]string{
+			"CI_PROJECT_URL":     "https://gitlab.com/acme/app",
+			"CI_COMMIT_SHA":      "0123456789abcdef0123456789abcdef01234567",
+			"CI_COMMIT_REF_NAME": "main",
+			"CI_JOB_URL":         "https://gitlab.com/acme/app/-/jobs/7",
+			"CI_JOB_NAME":        "release",
+			"CI_RUNNER_ID":       "12",
+			"TRIGGER_PAYLOAD":    trigger,
+		},
+		builder:      "https://gitlab.com/acme/app/-/runners/12",
+		recipeType:   GitLabRecipeType,
+		entryPoint:   ".gitlab-ci.yml",
+		invocation:   "https://gitlab.com/acme/app/-/jobs/7",
+		material:     cosign.SLSAMaterial{URI: "git+https://gitlab.com/acme/app@main", Digest: map[string]string{"sha1": "0123456789abcdef0123456789abcdef01234567"}},
+		level:        2,
+		wantEnvField: "ci_job_name",
+	}, {
+		system: "tekton",
+		vars: map[string]string{
+			"TEKTON_TASKRUN_NAME":      "build-abcde",
+			"TEKTON_TASKRUN_NAMESPACE": "ci",
+			"TEKTON_TASK_NAME":         "build",
+			"TEKTON_GIT_URL":           "https://github.com/acme/app",
+			"TEKTON_GIT_COMMIT":        "0123456789abcdef0123456789abcdef01234567",
+		},
+		builder:      TektonBuilder,
+		recipeType:   TektonRecipeType,
+		entryPoint:   "build",
+		invocation:   "namespaces/ci/taskruns/build-abcde",
+		material:     cosign.SLSAMaterial{URI: "git+https://github.com/acme/app", Digest: map[string]string{"sha1": "0123456789abcdef0123456789abcdef01234567"}},
+		level:        2,
+		wantEnvField: "tekton_taskrun_name",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.system, func(t *testing.T) {
+			p, err := Generate(tt.system, env(tt.vars))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Builder.ID != tt.builder {
+				t.Errorf("builder = %q, want %q", p.Builder.ID, tt.builder)
+			}
+			if p.Recipe.Type != tt.recipeType || p.Recipe.EntryPoint != tt.entryPoint {
+				t.Errorf("recipe = %q %q, want %q %q", p.Recipe.Type, p.Recipe.EntryPoint, tt.recipeType, tt.entryPoint)
+			}
+			if string(p.Recipe.Arguments) != tt.arguments {
+				t.Errorf("arguments = %s, want %s", p.Recipe.Arguments, tt.arguments)
+			}
+			if p.Metadata.BuildInvocationID != tt.invocation {
+				t.Errorf("invocation = %q, want %q", p.Metadata.BuildInvocationID, tt.invocation)
+			}
+			if len(p.Materials) != 1 || !reflect.DeepEqual(p.Materials[0], tt.material) {
+				t.Errorf("materials = %+v, want %+v", p.Materials, tt.material)
+			}
+			environment := map[string]string{}
+			if err := json.Unmarshal(p.Recipe.Environment, &environment); err != nil {
+				t.Fatal(err)
+			}
+			if environment[tt.wantEnvField] == "" {
+				t.Errorf("environment = %s, missing %s", p.Recipe.Environment, tt.wantEnvField)
+			}
+			if got := p.Level().Level; got != tt.level {
+				t.Errorf("SLSA level = %d, want %d", got, tt.level)
+			}
+
+			// The provenance reads back as the predicate of a provenance attestation.
+			b, err := json.Marshal(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := cosign.ParseSLSAProvenance(&cosign.Statement{PredicateType: cosign.PredicateTypes["slsaprovenance"], Predicate: b})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Builder.ID != tt.builder {
+				t.Errorf("parsed builder = %q, want %q", got.Builder.ID, tt.builder)
+			}
+		})
+	}
+}
+
+func TestGenerateOutsideCI(t *testing.T) {
+	for _, system := range Systems() {
+		if _, err := Generate(system, env(nil)); err == nil {
+			t.Errorf("Generate(%q) outside of CI, expected error", system)
+		}
+	}
+	if _, err := Generate("jenkins", env(nil)); err == nil {
+		t.Error("Generate() of an unsupported system, expected error")
+	}
+}
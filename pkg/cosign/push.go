@@ -0,0 +1,64 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+// DefaultParallelism is how many blobs are pushed at once, unless PushOpts say otherwise.
+const DefaultParallelism = 4
+
+// PushOpts control how the blobs of files and attestations are pushed.
+type PushOpts struct {
+	// Parallelism is how many blobs are pushed at once, DefaultParallelism if it isn't set.
+	Parallelism int
+	// Chunked, if set, pushes blobs in chunks so interrupted uploads can be resumed.
+	Chunked *ChunkedUploader
+}
+
+func (po PushOpts) parallelism() int {
+	if po.Parallelism <= 0 {
+		return DefaultParallelism
+	}
+	return po.Parallelism
+}
+
+// pushLayers pushes the layers to repo ahead of the manifest that refers to them, po.Parallelism
+// at a time. Writing the manifest then finds the blobs in the registry and doesn't push them again.
+func pushLayers(ctx context.Context, repo name.Repository, layers []v1.Layer, po PushOpts, opts []remote.Option) error {
+	g, ctx := errgroup.WithContext(ctx)
+	sem := semaphore.NewWeighted(int64(po.parallelism()))
+	for _, l := range layers {
+		l := l
+		g.Go(func() error {
+			if err := sem.Acquire(ctx, 1); err != nil {
+				return err
+			}
+			defer sem.Release(1)
+			if po.Chunked != nil {
+				return po.Chunked.Upload(ctx, repo, l)
+			}
+			return remote.WriteLayer(repo, l, registryOpts(ctx, opts)...)
+		})
+	}
+	return g.Wait()
+}
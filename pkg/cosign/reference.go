@@ -0,0 +1,94 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// ReferenceEquivalent makes the repositories under A and B interchangeable in the docker-reference
+// claim, e.g. a registry mirror and the registry it mirrors, or a repository before and after it
+// was renamed. Each is a registry, a namespace or a repository, standing for every repository
+// under it, and normalized like the claim: docker.io is index.docker.io.
+type ReferenceEquivalent struct {
+	A string `json:"a"`
+	B string `json:"b"`
+}
+
+// ParseReferenceEquivalent parses an equivalence written as "<a>=<b>", e.g.
+// mirror.example.com=index.docker.io or gcr.io/acme/old-name=gcr.io/acme/new-name.
+// A value without a / is a registry if it looks like a host name, as in docker references.
+func ParseReferenceEquivalent(s string) (ReferenceEquivalent, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return ReferenceEquivalent{}, fmt.Errorf("reference equivalent %q is not <a>=<b>", s)
+	}
+	a, err := normalizeReferencePrefix(parts[0])
+	if err != nil {
+		return ReferenceEquivalent{}, err
+	}
+	b, err := normalizeReferencePrefix(parts[1])
+	if err != nil {
+		return ReferenceEquivalent{}, err
+	}
+	return ReferenceEquivalent{A: a, B: b}, nil
+}
+
+func normalizeReferencePrefix(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") && (strings.ContainsAny(s, ".:") || s == "localhost") {
+		reg, err := name.NewRegistry(s)
+		if err != nil {
+			return "", err
+		}
+		return reg.Name(), nil
+	}
+	repo, err := name.NewRepository(s)
+	if err != nil {
+		return "", err
+	}
+	return repo.Name(), nil
+}
+
+// equivalentReferences reports whether the normalized repositories got and want are the same,
+// or the same once one prefix of an equivalence is replaced by the other.
+func equivalentReferences(got, want string, equivalents []ReferenceEquivalent) bool {
+	if got == want {
+		return true
+	}
+	for _, eq := range equivalents {
+		if rest, ok := underPrefix(got, eq.A); ok && eq.B+rest == want {
+			return true
+		}
+		if rest, ok := underPrefix(got, eq.B); ok && eq.A+rest == want {
+			return true
+		}
+	}
+	return false
+}
+
+// underPrefix returns what follows prefix in repo, if repo is prefix or under it.
+func underPrefix(repo, prefix string) (string, bool) {
+	if repo == prefix {
+		return "", true
+	}
+	if strings.HasPrefix(repo, prefix+"/") {
+		return strings.TrimPrefix(repo, prefix), true
+	}
+	return "", false
+}
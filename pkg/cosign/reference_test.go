@@ -0,0 +1,37 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import "testing"
+
+func TestParseReferenceEquivalent(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ReferenceEquivalent
+		wantErr bool
+	}{
+		{"mirror.example.com=docker.io", ReferenceEquivalent{A: "mirror.example.com", B: "index.docker.io"}, false},
+		{"mirror.example.com/library/nginx = nginx", ReferenceEquivalent{A: "mirror.example.com/library/nginx", B: "index.docker.io/library/nginx"}, false},
+		{"gcr.io/acme/old=gcr.io/acme/new", ReferenceEquivalent{A: "gcr.io/acme/old", B: "gcr.io/acme/new"}, false},
+		{"gcr.io/acme/old", ReferenceEquivalent{}, true},
+		{"gcr.io/acme/Old=gcr.io/acme/new", ReferenceEquivalent{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseReferenceEquivalent(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseReferenceEquivalent(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
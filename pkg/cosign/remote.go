@@ -16,7 +16,9 @@ package cosign
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -29,10 +31,22 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/sigstore/cosign/pkg/cosign/fulcio"
+	"github.com/sigstore/cosign/pkg/cosign/telemetry"
 )
 
-func Descriptors(ref name.Reference) ([]v1.Descriptor, error) {
-	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+// registryOpts returns opts bound to ctx. If there are none, the docker config and
+// credential helpers are used to authenticate.
+func registryOpts(ctx context.Context, opts []remote.Option) []remote.Option {
+	if len(opts) == 0 {
+		opts = []remote.Option{remote.WithAuthFromKeychain(authn.DefaultKeychain)}
+	}
+	return append(opts[:len(opts):len(opts)], remote.WithContext(ctx))
+}
+
+func Descriptors(ctx context.Context, ref name.Reference, opts ...remote.Option) ([]v1.Descriptor, error) {
+	img, err := remote.Image(ref, registryOpts(ctx, opts)...)
 	if err != nil {
 		return nil, err
 	}
@@ -44,12 +58,64 @@ func Descriptors(ref name.Reference) ([]v1.Descriptor, error) {
 	return m.Layers, nil
 }
 
-func Upload(signature, payload []byte, dstTag name.Reference, cert, chain string) error {
-	l := &staticLayer{
-		b:  payload,
-		mt: "application/vnd.dev.cosign.simplesigning.v1+json",
+// SignatureMetadata is recorded in annotations alongside a signature in the registry.
+type SignatureMetadata struct {
+	Cert      string
+	Chain     string
+	KeyID     string
+	Algorithm string
+	// SCT is the certificate transparency log's timestamp for Cert, see fulcio.AddChain.
+	SCT *fulcio.SCT
+	// HardwareAttestation is the PEM encoded chain attesting the key was generated on a hardware device,
+	// see VerifyHardwareAttestation.
+	HardwareAttestation string
+	// Compress stores the payload zstd compressed, for large payloads such as SBOM attestations.
+	// It isn't recorded in an annotation: the layer's media type gets a +zstd suffix instead.
+	Compress bool
+}
+
+// simpleSigningMediaType is the media type of the layers signature payloads are stored in.
+const simpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+
+func Upload(ctx context.Context, signature, payload []byte, dstTag name.Reference, md SignatureMetadata, opts ...remote.Option) (err error) {
+	ctx, end := telemetry.Start(ctx, telemetry.OpUploadSignature)
+	defer func() { end(err) }()
+	opts = registryOpts(ctx, opts)
+	l, err := payloadLayer(payload, simpleSigningMediaType, md.Compress)
+	if err != nil {
+		return err
+	}
+	annotations := map[string]string{
+		sigkey: base64.StdEncoding.EncodeToString(signature),
+	}
+	if md.Cert != "" {
+		annotations[certkey] = md.Cert
+		annotations[chainkey] = md.Chain
+	}
+	if md.KeyID != "" {
+		annotations[keyidkey] = md.KeyID
+	}
+	if md.Algorithm != "" {
+		annotations[algkey] = md.Algorithm
 	}
-	base, err := remote.Image(dstTag, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if md.SCT != nil {
+		b, err := json.Marshal(md.SCT)
+		if err != nil {
+			return err
+		}
+		annotations[sctkey] = string(b)
+	}
+	if md.HardwareAttestation != "" {
+		annotations[hwattkey] = md.HardwareAttestation
+	}
+	return appendLayer(dstTag, l, annotations, opts)
+}
+
+// appendLayer adds l to the image at dstTag, creating it if it doesn't exist yet.
+// If an identical layer with the same annotations is already there, nothing is pushed,
+// so re-uploading the same signature doesn't keep growing the image.
+func appendLayer(dstTag name.Reference, l v1.Layer, annotations map[string]string, opts []remote.Option) error {
+	base, err := remote.Image(dstTag, opts...)
 	if err != nil {
 		if te, ok := err.(*transport.Error); ok {
 			if te.StatusCode != http.StatusNotFound {
@@ -60,13 +126,12 @@ func Upload(signature, payload []byte, dstTag name.Reference, cert, chain string
 			return err
 		}
 	}
-
-	annotations := map[string]string{
-		sigkey: base64.StdEncoding.EncodeToString(signature),
+	exists, err := hasLayer(base, l, annotations)
+	if err != nil {
+		return err
 	}
-	if cert != "" {
-		annotations[certkey] = cert
-		annotations[chainkey] = chain
+	if exists {
+		return nil
 	}
 	img, err := mutate.Append(base, mutate.Addendum{
 		Layer:       l,
@@ -76,12 +141,41 @@ func Upload(signature, payload []byte, dstTag name.Reference, cert, chain string
 		return err
 	}
 
-	if err := remote.Write(dstTag, img, remote.WithAuthFromKeychain(authn.DefaultKeychain)); err != nil {
+	if err := remote.Write(dstTag, img, opts...); err != nil {
 		return err
 	}
 	return nil
 }
 
+func hasLayer(img v1.Image, l v1.Layer, annotations map[string]string) (bool, error) {
+	digest, err := l.Digest()
+	if err != nil {
+		return false, err
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		return false, err
+	}
+	for _, desc := range m.Layers {
+		if desc.Digest == digest && sameAnnotations(desc.Annotations, annotations) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func sameAnnotations(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
 type staticLayer struct {
 	b  []byte
 	mt types.MediaType
@@ -0,0 +1,222 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"golang.org/x/sync/singleflight"
+)
+
+// ResultCache remembers the signatures that verified for an image digest under a policy, so admission
+// controllers and other servers checking the same hot images don't verify them against the registry and
+// the transparency log every time. Only successful verifications are kept, for the TTL; failures are
+// always checked again. Concurrent verifications of the same image under the same policy are made once.
+// A nil *ResultCache caches nothing. It is safe for concurrent use.
+type ResultCache struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+	group      singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru holds *resultEntry, the most recently used first.
+	lru *list.List
+}
+
+type resultEntry struct {
+	key      string
+	verified []VerifiedSignature
+	expires  time.Time
+}
+
+// NewResultCache returns a cache keeping results for ttl. Once it holds maxEntries results, the least
+// recently used are dropped; zero means no limit.
+func NewResultCache(ttl time.Duration, maxEntries int) *ResultCache {
+	return &ResultCache{ttl: ttl, maxEntries: maxEntries, now: time.Now, entries: map[string]*list.Element{}, lru: list.New()}
+}
+
+// VerifyImage is Verify, returning the signatures that verified for the image's digest under the same checks
+// before, if that was less than the TTL ago. Tags are resolved to digests first, with a single HEAD request.
+// See Verify for policy.
+func (c *ResultCache) VerifyImage(ctx context.Context, ref name.Reference, co CheckOpts, policy string) ([]VerifiedSignature, error) {
+	digest, ok := ref.(name.Digest)
+	if !ok {
+		desc, err := remote.Head(ref, registryOpts(ctx, co.RegistryClientOpts)...)
+		if err != nil {
+			return nil, err
+		}
+		digest = ref.Context().Digest(desc.Digest.String())
+	}
+	h, err := v1.NewHash(digest.DigestStr())
+	if err != nil {
+		return nil, err
+	}
+	return c.Verify(ctx, h, co, policy, func() ([]VerifiedSignature, error) {
+		return Verify(ctx, digest, co)
+	})
+}
+
+// Verify returns the signatures that verified for the image with digest under co before, if that was less
+// than the TTL ago. Otherwise it calls verify, and keeps what it returns if it succeeds.
+// The keys, roots, identities and other checks in co are part of the cache key, so changing them is a miss.
+// policy should identify anything else the result depends on, e.g. a hash of the policy document co was
+// built from, since roots can only be told apart by their subjects.
+// The returned signatures are shared, and must not be modified.
+func (c *ResultCache) Verify(ctx context.Context, digest v1.Hash, co CheckOpts, policy string, verify func() ([]VerifiedSignature, error)) ([]VerifiedSignature, error) {
+	if c == nil {
+		return verify()
+	}
+	ph, err := policyHash(ctx, &co)
+	if err != nil {
+		return nil, err
+	}
+	key := digest.String() + "\n" + ph + "\n" + policy
+	if verified, ok := c.get(key); ok {
+		return verified, nil
+	}
+	v, err, _ := c.group.Do(key, func() (interface{}, error) {
+		verified, err := verify()
+		if err == nil {
+			c.put(key, verified)
+		}
+		return verified, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]VerifiedSignature), nil
+}
+
+func (c *ResultCache) get(key string) ([]VerifiedSignature, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*resultEntry)
+	if !c.now().Before(e.expires) {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return e.verified, true
+}
+
+func (c *ResultCache) put(key string, verified []VerifiedSignature) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.lru.Remove(el)
+	}
+	c.entries[key] = c.lru.PushFront(&resultEntry{key: key, verified: verified, expires: c.now().Add(c.ttl)})
+	for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*resultEntry).key)
+	}
+}
+
+// checkPolicy is what of CheckOpts decides whether a signature verifies, in a form that can be hashed.
+type checkPolicy struct {
+	Annotations              map[string]string
+	ClaimVerification        bool
+	SkipDigestClaim          bool
+	ReferenceClaim           string
+	ReferenceEquivalents     []ReferenceEquivalent
+	TLog                     bool
+	RekorURL                 string
+	Keys                     [][]byte
+	RootSubjects             [][]byte
+	Identities               []string
+	CTLog                    bool
+	CTLogURL                 string
+	HardwareAttestationRoots [][]byte
+	Threshold                int
+	Countersigners           *checkPolicy
+	RequiredApprovals        int
+	Approvers                *checkPolicy
+}
+
+// policyHash returns a hash of the checks in co.
+func policyHash(ctx context.Context, co *CheckOpts) (string, error) {
+	p, err := newCheckPolicy(ctx, co)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:]), nil
+}
+
+func newCheckPolicy(ctx context.Context, co *CheckOpts) (*checkPolicy, error) {
+	if co == nil {
+		return nil, nil
+	}
+	p := &checkPolicy{
+		Annotations:          co.Annotations,
+		ClaimVerification:    co.ClaimVerification,
+		SkipDigestClaim:      co.SkipDigestClaim,
+		ReferenceClaim:       co.ReferenceClaim,
+		ReferenceEquivalents: co.ReferenceEquivalents,
+		TLog:                 co.TLog,
+		Identities:           co.Identities,
+		CTLog:                co.CTLog,
+		Threshold:            co.Threshold,
+		RequiredApprovals:    co.RequiredApprovals,
+	}
+	if co.TLog {
+		p.RekorURL = co.rekorURL()
+	}
+	if co.CTLog {
+		p.CTLogURL = co.ctLogURL()
+	}
+	for _, k := range co.Keys {
+		pem, err := PublicKeyPem(ctx, k)
+		if err != nil {
+			return nil, err
+		}
+		p.Keys = append(p.Keys, pem)
+	}
+	if co.Roots != nil {
+		p.RootSubjects = co.Roots.Subjects()
+	}
+	for _, cert := range co.HardwareAttestationRoots {
+		p.HardwareAttestationRoots = append(p.HardwareAttestationRoots, cert.Raw)
+	}
+	var err error
+	if p.Countersigners, err = newCheckPolicy(ctx, co.Countersigners); err != nil {
+		return nil, err
+	}
+	if p.Approvers, err = newCheckPolicy(ctx, co.Approvers); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
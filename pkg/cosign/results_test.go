@@ -0,0 +1,105 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestResultCache(t *testing.T) {
+	ctx := context.Background()
+	c := NewResultCache(time.Minute, 2)
+	now := time.Unix(0, 0)
+	c.now = func() time.Time { return now }
+
+	calls := 0
+	verify := func() ([]VerifiedSignature, error) {
+		calls++
+		return []VerifiedSignature{{SignedPayload: SignedPayload{Base64Signature: "sig"}}}, nil
+	}
+	digest := func(hex string) v1.Hash {
+		return v1.Hash{Algorithm: "sha256", Hex: hex}
+	}
+	co := CheckOpts{ClaimVerification: true, Identities: []string{"jane@example.com"}}
+
+	for i := 0; i < 3; i++ {
+		verified, err := c.Verify(ctx, digest("a"), co, "", verify)
+		if err != nil || len(verified) != 1 {
+			t.Fatalf("Verify() = %v, %v", verified, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("verified %d times, want the result to be reused", calls)
+	}
+
+	// Other checks, policies and images are verified on their own.
+	other := co
+	other.Identities = []string{"john@example.com"}
+	if _, err := c.Verify(ctx, digest("a"), other, "", verify); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Verify(ctx, digest("a"), co, "v2", verify); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("verified %d times, want different checks not to share results", calls)
+	}
+
+	// Only 2 results are kept: "a" with co was used least recently, and dropped.
+	if _, err := c.Verify(ctx, digest("a"), co, "", verify); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 4 {
+		t.Errorf("verified %d times, want the least recently used result to be evicted", calls)
+	}
+
+	// Results expire after the TTL.
+	now = now.Add(time.Minute)
+	if _, err := c.Verify(ctx, digest("a"), co, "", verify); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 5 {
+		t.Errorf("verified %d times, want the expired result to be checked again", calls)
+	}
+
+	// Failures aren't cached.
+	failures := 0
+	fail := func() ([]VerifiedSignature, error) {
+		failures++
+		return nil, errors.New("no matching signatures")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.Verify(ctx, digest("b"), co, "", fail); err == nil {
+			t.Fatal("expected the failure to be returned")
+		}
+	}
+	if failures != 2 {
+		t.Errorf("failed verification ran %d times, want 2", failures)
+	}
+
+	// A nil cache always verifies.
+	var nilCache *ResultCache
+	if _, err := nilCache.Verify(ctx, digest("a"), co, "", verify); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 6 {
+		t.Error("nil cache didn't verify")
+	}
+}
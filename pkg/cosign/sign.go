@@ -16,7 +16,11 @@ package cosign
 
 import (
 	"context"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
@@ -31,9 +35,39 @@ const (
 	sigkey   = "dev.cosignproject.cosign/signature"
 	certkey  = "dev.sigstore.cosign/certificate"
 	chainkey = "dev.sigstore.cosign/chain"
+	keyidkey = "dev.sigstore.cosign/keyid"
+	algkey   = "dev.sigstore.cosign/algorithm"
+	sctkey   = "dev.sigstore.cosign/sct"
+	hwattkey = "dev.sigstore.cosign/hardware-attestation"
 )
 
+// LoadPrivateKey decrypts a private key in any of the supported formats:
+// an ENCRYPTED COSIGN PRIVATE KEY, an age encrypted PKCS #8 key or an OpenSSH ECDSA P-256 key.
 func LoadPrivateKey(key []byte, pass []byte) (*ECDSAKey, error) {
+	var pk crypto.PrivateKey
+	var err error
+	switch {
+	case isAgeEncrypted(key):
+		pk, err = parsePKCS8(decryptAgeKey(key, pass))
+	case isOpenSSHKey(key):
+		pk, err = parseOpenSSHKey(key, pass)
+	default:
+		pk, err = loadCosignKey(key, pass)
+	}
+	if err != nil {
+		return nil, err
+	}
+	epk, ok := pk.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid private key")
+	}
+	if KeyAlgorithm(&epk.PublicKey) == "" {
+		return nil, errors.New("only ECDSA P-256, P-384 and P-521 keys are supported")
+	}
+	return WithECDSAKey(epk), nil
+}
+
+func loadCosignKey(key []byte, pass []byte) (crypto.PrivateKey, error) {
 	// Decrypt first
 	p, _ := pem.Decode(key)
 	if p == nil {
@@ -42,21 +76,18 @@ func LoadPrivateKey(key []byte, pass []byte) (*ECDSAKey, error) {
 	if p.Type != pemType {
 		return nil, fmt.Errorf("unsupported pem type: %s", p.Type)
 	}
+	return parsePKCS8(encrypted.Decrypt(p.Bytes, pass))
+}
 
-	x509Encoded, err := encrypted.Decrypt(p.Bytes, pass)
+func parsePKCS8(x509Encoded []byte, err error) (crypto.PrivateKey, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "decrypt")
 	}
-
 	pk, err := x509.ParsePKCS8PrivateKey(x509Encoded)
 	if err != nil {
 		return nil, errors.Wrap(err, "parsing private key")
 	}
-	epk, ok := pk.(*ecdsa.PrivateKey)
-	if !ok {
-		return nil, fmt.Errorf("invalid private key")
-	}
-	return WithECDSAKey(epk), nil
+	return pk, nil
 }
 
 type SimpleSigning struct {
@@ -82,6 +113,46 @@ type Signer interface {
 	Sign(ctx context.Context, payload []byte) (signature []byte, err error)
 }
 
+// Signature algorithms reported by SignerVerifier.Algorithm.
+const (
+	AlgorithmECDSAP256SHA256 = "ecdsa-p256-sha256"
+	AlgorithmECDSAP384SHA384 = "ecdsa-p384-sha384"
+	AlgorithmECDSAP521SHA512 = "ecdsa-p521-sha512"
+	AlgorithmRSASHA256       = "rsa-pkcs1v15-sha256"
+	AlgorithmEd25519         = "ed25519"
+)
+
+// SignerVerifier is a Signer that can describe its key, so signatures can record which key produced them.
+type SignerVerifier interface {
+	Signer
+	PublicKey
+	// KeyID identifies the key, e.g. by its fingerprint or KMS key version.
+	KeyID(ctx context.Context) (string, error)
+	// Algorithm names the signature algorithm, e.g. AlgorithmECDSAP256SHA256.
+	Algorithm() string
+}
+
+// KeyAlgorithm returns the name of the algorithm cosign signs with for the given public key,
+// or an empty string if the key type isn't supported.
+func KeyAlgorithm(pub crypto.PublicKey) string {
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return AlgorithmECDSAP256SHA256
+		case elliptic.P384():
+			return AlgorithmECDSAP384SHA384
+		case elliptic.P521():
+			return AlgorithmECDSAP521SHA512
+		}
+	case *rsa.PublicKey:
+		return AlgorithmRSASHA256
+	case ed25519.PublicKey:
+		return AlgorithmEd25519
+	}
+	return ""
+}
+
 func PayloadSignature(ctx context.Context, signer Signer, payload []byte) (signature []byte, err error) {
 	signature, err = signer.Sign(ctx, payload)
 	if err != nil {
@@ -0,0 +1,244 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signservice signs with a remote signing service, so keys can be held centrally while
+// cosign still builds the payloads locally. Only the digest of a payload is sent to the service,
+// which returns the signature and the certificate of the key that made it.
+//
+// The service has two endpoints, both of which exchange JSON:
+//
+//	GET  /api/v1/certificate  returns the current Certificate
+//	POST /api/v1/sign         takes a SignRequest and returns a SignResponse
+//
+// Requests carry the token as a bearer token if there is one, services can also authenticate
+// clients by their TLS certificate.
+package signservice
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/sigstore/cosign/pkg/cosign"
+)
+
+// TokenEnv holds the bearer token sent to the signing service, kept out of flags so it isn't
+// visible in the process list.
+const TokenEnv = "COSIGN_SIGNING_SERVICE_TOKEN"
+
+// maxResponseSize bounds how much of a response is read.
+const maxResponseSize = 1 << 20
+
+// Certificate is the certificate of the service's key, and the chain up to its root.
+type Certificate struct {
+	Certificate string `json:"certificate"`
+	Chain       string `json:"chain,omitempty"`
+}
+
+// SignRequest asks the service to sign Digest, computed with HashAlgorithm, e.g. "sha256".
+type SignRequest struct {
+	Digest        []byte `json:"digest"`
+	HashAlgorithm string `json:"hashAlgorithm"`
+}
+
+// SignResponse is the signature over the digest, and the certificate of the key that made it.
+type SignResponse struct {
+	Signature []byte `json:"signature"`
+	Certificate
+}
+
+// Client talks to a signing service.
+type Client struct {
+	// Server is the base URL of the service, e.g. https://signer.example.com.
+	Server string
+	// Token is sent as a bearer token if it is set.
+	Token string
+	// Transport makes the requests, http.DefaultTransport if nil.
+	Transport http.RoundTripper
+}
+
+// Certificate returns the certificate the service currently signs with.
+func (c *Client) Certificate(ctx context.Context) (*Certificate, error) {
+	cert := &Certificate{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/certificate", nil, cert); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// Sign has the service sign digest.
+func (c *Client) Sign(ctx context.Context, digest []byte, h crypto.Hash) (*SignResponse, error) {
+	resp := &SignResponse{}
+	req := SignRequest{Digest: digest, HashAlgorithm: hashName(h)}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/sign", req, resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Signature) == 0 {
+		return nil, errors.New("signing service returned no signature")
+	}
+	return resp, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	u := strings.TrimSuffix(c.Server, "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	rt := c.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		if msg := strings.TrimSpace(string(b)); msg != "" {
+			return fmt.Errorf("%s %s: %s: %s", method, u, resp.Status, msg)
+		}
+		return fmt.Errorf("%s %s: %s", method, u, resp.Status)
+	}
+	return errors.Wrapf(json.Unmarshal(b, out), "parsing the response of %s", u)
+}
+
+// Signer signs with the key of a signing service, implementing cosign.SignerVerifier and
+// cosign.DigestSigner.
+type Signer struct {
+	client *Client
+	cert   *x509.Certificate
+	// Cert and Chain are the PEM certificate of the service's key, and its chain, for recording
+	// with the signatures.
+	Cert  string
+	Chain string
+}
+
+// NewSigner fetches the certificate of client's service, which its signatures are checked against.
+func NewSigner(ctx context.Context, client *Client) (*Signer, error) {
+	c, err := client.Certificate(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting the signing service certificate")
+	}
+	certs, err := cosign.LoadCerts(c.Certificate)
+	if err != nil || len(certs) == 0 {
+		return nil, errors.New("signing service returned no certificate")
+	}
+	if err := cosign.CheckFIPSKey(certs[0].PublicKey); err != nil {
+		return nil, err
+	}
+	if cosign.KeyAlgorithm(certs[0].PublicKey) == "" || cosign.KeyAlgorithm(certs[0].PublicKey) == cosign.AlgorithmEd25519 {
+		return nil, fmt.Errorf("signing service has an unsupported %T key", certs[0].PublicKey)
+	}
+	return &Signer{client: client, cert: certs[0], Cert: c.Certificate, Chain: c.Chain}, nil
+}
+
+func (s *Signer) Sign(ctx context.Context, payload []byte) ([]byte, error) {
+	h := cosign.HashFunc(s.cert.PublicKey).New()
+	h.Write(payload)
+	return s.SignDigest(ctx, h.Sum(nil))
+}
+
+// SignDigest sends the digest to the service. The signature must verify with the key of the
+// certificate NewSigner fetched, a service that has rotated its key since has to be asked again.
+func (s *Signer) SignDigest(ctx context.Context, digest []byte) ([]byte, error) {
+	resp, err := s.client.Sign(ctx, digest, cosign.HashFunc(s.cert.PublicKey))
+	if err != nil {
+		return nil, errors.Wrap(err, "signing with the signing service")
+	}
+	if resp.Certificate.Certificate != "" {
+		certs, err := cosign.LoadCerts(resp.Certificate.Certificate)
+		if err != nil || len(certs) == 0 || !certs[0].Equal(s.cert) {
+			return nil, errors.New("signing service signed with a different certificate than it announced")
+		}
+	}
+	if err := s.VerifyDigest(ctx, digest, resp.Signature); err != nil {
+		return nil, errors.Wrap(err, "signing service returned a bad signature")
+	}
+	return resp.Signature, nil
+}
+
+func (s *Signer) Verify(ctx context.Context, payload, signature []byte) error {
+	h := cosign.HashFunc(s.cert.PublicKey).New()
+	h.Write(payload)
+	return s.VerifyDigest(ctx, h.Sum(nil), signature)
+}
+
+func (s *Signer) VerifyDigest(_ context.Context, digest, signature []byte) error {
+	switch pub := s.cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest, signature) {
+			return errors.New("unable to verify signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, signature)
+	default:
+		return fmt.Errorf("unsupported key type: %T", pub)
+	}
+}
+
+func (s *Signer) PublicKey(_ context.Context) (crypto.PublicKey, error) {
+	return s.cert.PublicKey, nil
+}
+
+// KeyID returns the fingerprint of the service's key.
+func (s *Signer) KeyID(_ context.Context) (string, error) {
+	return cosign.KeyFingerprint(s.cert.PublicKey)
+}
+
+func (s *Signer) Algorithm() string {
+	return cosign.KeyAlgorithm(s.cert.PublicKey)
+}
+
+func hashName(h crypto.Hash) string {
+	switch h {
+	case crypto.SHA384:
+		return "sha384"
+	case crypto.SHA512:
+		return "sha512"
+	default:
+		return "sha256"
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signservice
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testCert(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// testServer signs with priv, corrupting its signatures if bad is set.
+func testServer(t *testing.T, priv *ecdsa.PrivateKey, cert string, bad *bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "bad token", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/certificate":
+			_ = json.NewEncoder(w).Encode(Certificate{Certificate: cert})
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/sign":
+			req := SignRequest{}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.HashAlgorithm != "sha256" {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			digest := req.Digest
+			if *bad {
+				digest = make([]byte, len(digest))
+			}
+			sig, err := ecdsa.SignASN1(rand.Reader, priv, digest)
+			if err != nil {
+				t.Error(err)
+			}
+			_ = json.NewEncoder(w).Encode(SignResponse{Signature: sig, Certificate: Certificate{Certificate: cert}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestSigner(t *testing.T) {
+	priv, cert := testCert(t)
+	bad := false
+	srv := testServer(t, priv, cert, &bad)
+	defer srv.Close()
+	ctx := context.Background()
+
+	if _, err := NewSigner(ctx, &Client{Server: srv.URL, Token: "wrong"}); err == nil {
+		t.Error("NewSigner() with the wrong token, expected error")
+	}
+
+	s, err := NewSigner(ctx, &Client{Server: srv.URL + "/", Token: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Cert != cert {
+		t.Errorf("Cert = %q, want the service's certificate", s.Cert)
+	}
+	payload := []byte(`{"critical":{}}`)
+	sig, err := s.Sign(ctx, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(payload)
+	if !ecdsa.VerifyASN1(&priv.PublicKey, digest[:], sig) {
+		t.Error("signature doesn't verify with the service's key")
+	}
+	if err := s.Verify(ctx, payload, sig); err != nil {
+		t.Error(err)
+	}
+
+	bad = true
+	if _, err := s.Sign(ctx, payload); err == nil {
+		t.Error("Sign() with a service returning bad signatures, expected error")
+	}
+}
+
+func TestSignerRotatedCertificate(t *testing.T) {
+	priv, cert := testCert(t)
+	_, other := testCert(t)
+	bad := false
+	srv := testServer(t, priv, cert, &bad)
+	defer srv.Close()
+	ctx := context.Background()
+
+	s, err := NewSigner(ctx, &Client{Server: srv.URL, Token: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The service now announces a different key than the one it signs with.
+	srv2 := testServer(t, priv, other, &bad)
+	defer srv2.Close()
+	s.client.Server = srv2.URL
+	if _, err := s.Sign(ctx, []byte("payload")); err == nil {
+		t.Error("Sign() returning a different certificate than announced, expected error")
+	}
+}
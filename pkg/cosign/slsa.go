@@ -0,0 +1,154 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MaxSLSALevel is the highest SLSA level provenance can be evaluated at.
+const MaxSLSALevel = 4
+
+// SLSAProvenance is the predicate of a SLSA provenance v0.1 attestation.
+type SLSAProvenance struct {
+	Builder struct {
+		ID string `json:"id"`
+	} `json:"builder"`
+	Recipe struct {
+		Type        string          `json:"type"`
+		EntryPoint  string          `json:"entryPoint,omitempty"`
+		Arguments   json.RawMessage `json:"arguments,omitempty"`
+		Environment json.RawMessage `json:"environment,omitempty"`
+	} `json:"recipe"`
+	Metadata struct {
+		BuildInvocationID string `json:"buildInvocationId,omitempty"`
+		Completeness      struct {
+			Arguments   bool `json:"arguments"`
+			Environment bool `json:"environment"`
+			Materials   bool `json:"materials"`
+		} `json:"completeness"`
+		Reproducible bool `json:"reproducible"`
+	} `json:"metadata"`
+	Materials []SLSAMaterial `json:"materials,omitempty"`
+}
+
+// SLSAMaterial is an input of a build, such as its source.
+type SLSAMaterial struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// SLSALevel is the level provenance achieves, and what it is missing for the next one.
+type SLSALevel struct {
+	Level   int      `json:"level"`
+	Missing []string `json:"missing,omitempty"`
+}
+
+// ParseSLSAProvenance returns the provenance predicate of the statement.
+func ParseSLSAProvenance(st *Statement) (*SLSAProvenance, error) {
+	if st.PredicateType != PredicateTypes["slsaprovenance"] {
+		return nil, fmt.Errorf("predicate type %s is not SLSA provenance", st.PredicateType)
+	}
+	p := &SLSAProvenance{}
+	if err := json.Unmarshal(st.Predicate, p); err != nil {
+		return nil, errors.Wrap(err, "parsing SLSA provenance")
+	}
+	return p, nil
+}
+
+// Level evaluates the SLSA level the provenance claims, from what it records. The provenance
+// is assumed to be signed and verified, but whether the builder itself meets the requirements
+// of the level is a matter of trusting it, which provenance can't show.
+//
+// Level 1 needs the builder to be identified, level 2 the build type and source (by digest)
+// too, level 3 a complete record of the arguments and environment, and level 4 a parameterless,
+// hermetic build that records all of its materials.
+func (p *SLSAProvenance) Level() SLSALevel {
+	requirements := [][]struct {
+		met  bool
+		desc string
+	}{
+		{
+			{p.Builder.ID != "", "builder.id is set"},
+		},
+		{
+			{p.Recipe.Type != "", "recipe.type is set"},
+			{p.hasDigestedMaterial(), "a material with a digest identifies the source"},
+		},
+		{
+			{p.Metadata.Completeness.Arguments, "metadata.completeness.arguments is true"},
+			{p.Metadata.Completeness.Environment, "metadata.completeness.environment is true"},
+		},
+		{
+			{p.parameterless(), "recipe.arguments is empty (parameterless build)"},
+			{p.Metadata.Completeness.Materials, "metadata.completeness.materials is true (hermetic build)"},
+		},
+	}
+	level := SLSALevel{}
+	for _, reqs := range requirements {
+		for _, r := range reqs {
+			if !r.met {
+				level.Missing = append(level.Missing, r.desc)
+			}
+		}
+		if len(level.Missing) > 0 {
+			return level
+		}
+		level.Level++
+	}
+	return level
+}
+
+func (p *SLSAProvenance) hasDigestedMaterial() bool {
+	for _, m := range p.Materials {
+		if len(m.Digest) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *SLSAProvenance) parameterless() bool {
+	args := bytes.TrimSpace(p.Recipe.Arguments)
+	switch string(args) {
+	case "", "null", "{}", "[]":
+		return true
+	}
+	return false
+}
+
+// TrustedBuilder reports whether the provenance was made by one of the trusted builders.
+// A trusted builder ending in * matches any builder ID with that prefix, e.g. any ref of
+// a reusable workflow.
+func (p *SLSAProvenance) TrustedBuilder(trusted []string) bool {
+	if p.Builder.ID == "" {
+		return false
+	}
+	for _, t := range trusted {
+		if prefix := strings.TrimSuffix(t, "*"); prefix != t {
+			if strings.HasPrefix(p.Builder.ID, prefix) {
+				return true
+			}
+		} else if p.Builder.ID == t {
+			return true
+		}
+	}
+	return false
+}
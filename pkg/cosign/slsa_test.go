@@ -0,0 +1,94 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSLSALevel(t *testing.T) {
+	tests := []struct {
+		desc        string
+		predicate   string
+		wantLevel   int
+		wantMissing int
+	}{{
+		desc:        "no builder",
+		predicate:   `{}`,
+		wantLevel:   0,
+		wantMissing: 1,
+	}, {
+		desc:        "builder only",
+		predicate:   `{"builder":{"id":"https://ci.example.com"}}`,
+		wantLevel:   1,
+		wantMissing: 2,
+	}, {
+		desc:        "source recorded",
+		predicate:   `{"builder":{"id":"ci"},"recipe":{"type":"make"},"materials":[{"uri":"git+https://example.com/repo","digest":{"sha1":"abc"}}]}`,
+		wantLevel:   2,
+		wantMissing: 2,
+	}, {
+		desc:        "complete, with arguments",
+		predicate:   `{"builder":{"id":"ci"},"recipe":{"type":"make","arguments":{"target":"all"}},"metadata":{"completeness":{"arguments":true,"environment":true,"materials":true}},"materials":[{"uri":"git+https://example.com/repo","digest":{"sha1":"abc"}}]}`,
+		wantLevel:   3,
+		wantMissing: 1,
+	}, {
+		desc:      "parameterless and hermetic",
+		predicate: `{"builder":{"id":"ci"},"recipe":{"type":"make","arguments":null},"metadata":{"completeness":{"arguments":true,"environment":true,"materials":true}},"materials":[{"uri":"git+https://example.com/repo","digest":{"sha1":"abc"}}]}`,
+		wantLevel: 4,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			st := &Statement{PredicateType: PredicateTypes["slsaprovenance"], Predicate: json.RawMessage(tt.predicate)}
+			p, err := ParseSLSAProvenance(st)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := p.Level()
+			if got.Level != tt.wantLevel || len(got.Missing) != tt.wantMissing {
+				t.Errorf("Level() = %+v, want level %d missing %d requirements", got, tt.wantLevel, tt.wantMissing)
+			}
+		})
+	}
+
+	if _, err := ParseSLSAProvenance(&Statement{PredicateType: PredicateTypes["vuln"], Predicate: json.RawMessage(`{}`)}); err == nil {
+		t.Error("expected error parsing a vuln predicate as provenance")
+	}
+}
+
+func TestTrustedBuilder(t *testing.T) {
+	trusted := []string{
+		"https://tekton.example.com/chains/sa/build-bot",
+		"https://github.com/my-org/workflows/.github/workflows/build.yml@*",
+	}
+	tests := []struct {
+		builder string
+		want    bool
+	}{
+		{"https://tekton.example.com/chains/sa/build-bot", true},
+		{"https://tekton.example.com/chains/sa/build-bot2", false},
+		{"https://github.com/my-org/workflows/.github/workflows/build.yml@refs/tags/v1", true},
+		{"https://github.com/my-org/workflows/.github/workflows/other.yml@refs/tags/v1", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		p := &SLSAProvenance{}
+		p.Builder.ID = tt.builder
+		if got := p.TrustedBuilder(trusted); got != tt.want {
+			t.Errorf("TrustedBuilder() for %q = %v, want %v", tt.builder, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package spiffe fetches the X.509-SVID of a workload from the SPIFFE Workload API, so workloads can
+// sign with the identity SPIRE, or another SPIFFE implementation, already issues them, instead of a
+// key that has to be distributed and rotated separately.
+package spiffe
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// SocketEnv is the standard variable holding the address of the Workload API,
+	// e.g. unix:///tmp/spire-agent/public/api.sock.
+	SocketEnv = "SPIFFE_ENDPOINT_SOCKET"
+
+	fetchX509SVID = "/SpiffeWorkloadAPI/FetchX509SVID"
+	// The Workload API rejects requests without this header, so it can't be reached through a proxy by accident.
+	securityHeader = "workload.spiffe.io"
+)
+
+// SVID is the X.509 identity of a workload.
+type SVID struct {
+	// ID is the SPIFFE ID, e.g. spiffe://example.org/ns/ci/sa/builder.
+	ID string
+	// Certificates are the SVID, leaf first, followed by any intermediates.
+	Certificates []*x509.Certificate
+	PrivateKey   crypto.Signer
+	// Bundle is the trust bundle of the workload's trust domain, the roots SVIDs are verified against.
+	Bundle []*x509.Certificate
+}
+
+// Socket returns the Workload API address in $SPIFFE_ENDPOINT_SOCKET.
+func Socket() (string, error) {
+	addr := os.Getenv(SocketEnv)
+	if addr == "" {
+		return "", fmt.Errorf("$%s is not set, it must hold the address of the SPIFFE Workload API", SocketEnv)
+	}
+	return addr, nil
+}
+
+// FetchX509SVID returns the default X.509-SVID of the workload from the Workload API at addr,
+// a unix:// or tcp:// address.
+func FetchX509SVID(ctx context.Context, addr string) (*SVID, error) {
+	network, address, err := parseAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.DialContext(ctx, "passthrough:///workload-api",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		}))
+	if err != nil {
+		return nil, errors.Wrapf(err, "connecting to the Workload API at %s", addr)
+	}
+	defer conn.Close()
+
+	// The response is a stream that stays open to deliver rotated SVIDs, only the first one is needed.
+	ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(ctx, securityHeader, "true"))
+	defer cancel()
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, fetchX509SVID, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetching X.509-SVID from the Workload API at %s", addr)
+	}
+	// X509SVIDRequest has no fields, so it encodes to nothing.
+	if err := stream.SendMsg([]byte{}); err != nil {
+		return nil, errors.Wrapf(err, "fetching X.509-SVID from the Workload API at %s", addr)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	var resp []byte
+	if err := stream.RecvMsg(&resp); err != nil {
+		return nil, errors.Wrapf(err, "fetching X.509-SVID from the Workload API at %s", addr)
+	}
+	return ParseX509SVIDResponse(resp)
+}
+
+func parseAddr(addr string) (string, string, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "parsing Workload API address %q", addr)
+	}
+	switch {
+	case u.Scheme == "unix" && u.Path != "" && u.Host == "":
+		return "unix", u.Path, nil
+	case u.Scheme == "tcp" && u.Host != "" && strings.Trim(u.Path, "/") == "":
+		return "tcp", u.Host, nil
+	default:
+		return "", "", fmt.Errorf("invalid Workload API address %q, want unix:///<path> or tcp://<ip>:<port>", addr)
+	}
+}
+
+// ParseX509SVIDResponse decodes the protobuf X509SVIDResponse message of the Workload API, returning
+// the first SVID, which is the workload's default one.
+func ParseX509SVIDResponse(b []byte) (*SVID, error) {
+	var svid *SVID
+	err := walkFields(b, func(num protowire.Number, v []byte) error {
+		if num != 1 || svid != nil {
+			return nil
+		}
+		var err error
+		svid, err = parseX509SVID(v)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing X509SVIDResponse")
+	}
+	if svid == nil {
+		return nil, errors.New("the Workload API returned no X.509-SVID")
+	}
+	return svid, nil
+}
+
+func parseX509SVID(b []byte) (*SVID, error) {
+	svid := &SVID{}
+	var der, key, bundle []byte
+	err := walkFields(b, func(num protowire.Number, v []byte) error {
+		switch num {
+		case 1:
+			svid.ID = string(v)
+		case 2:
+			der = v
+		case 3:
+			key = v
+		case 4:
+			bundle = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if svid.Certificates, err = x509.ParseCertificates(der); err != nil || len(svid.Certificates) == 0 {
+		return nil, fmt.Errorf("invalid certificates in X.509-SVID %s", svid.ID)
+	}
+	if svid.Bundle, err = x509.ParseCertificates(bundle); err != nil {
+		return nil, errors.Wrapf(err, "parsing the trust bundle of %s", svid.ID)
+	}
+	k, err := x509.ParsePKCS8PrivateKey(key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing the private key of %s", svid.ID)
+	}
+	signer, ok := k.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported X.509-SVID key type %T", k)
+	}
+	svid.PrivateKey = signer
+	leaf := svid.Certificates[0]
+	if pub, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(leaf.PublicKey) {
+		return nil, fmt.Errorf("the private key of %s doesn't match its certificate", svid.ID)
+	}
+	if id, err := ID(leaf); err != nil || id != svid.ID {
+		return nil, fmt.Errorf("the certificate of %s isn't for its SPIFFE ID", svid.ID)
+	}
+	return svid, nil
+}
+
+// walkFields calls f with each length-delimited field of the message b, skipping other field types.
+func walkFields(b []byte, f func(protowire.Number, []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		if err := f(num, v); err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// ID returns the SPIFFE ID of an X.509-SVID, its only URI SAN.
+func ID(cert *x509.Certificate) (string, error) {
+	if len(cert.URIs) != 1 || cert.URIs[0].Scheme != "spiffe" {
+		return "", errors.New("certificate has no SPIFFE ID")
+	}
+	return cert.URIs[0].String(), nil
+}
+
+// rawCodec passes messages through as their protobuf encoding, which saves generating code for two
+// messages that are simple to decode by hand.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	*b = append([]byte(nil), data...)
+	return nil
+}
+
+// Name is the content subtype, which must be proto for the Workload API to accept the request.
+func (rawCodec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,188 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spiffe
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const testID = "spiffe://example.org/ns/ci/sa/builder"
+
+// testSVID returns a trust bundle and an SVID issued by it, as the Workload API encodes them.
+func testSVID(t *testing.T, id string) (bundle, certs, key []byte) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"SPIFFE"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	bundle, err = x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		URIs:         []*url.URL{u},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	certs, err = x509.CreateCertificate(rand.Reader, tmpl, caTmpl, &priv.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err = x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bundle, certs, key
+}
+
+func encodeResponse(id string, certs, key, bundle []byte) []byte {
+	var svid []byte
+	svid = protowire.AppendTag(svid, 1, protowire.BytesType)
+	svid = protowire.AppendString(svid, id)
+	svid = protowire.AppendTag(svid, 2, protowire.BytesType)
+	svid = protowire.AppendBytes(svid, certs)
+	svid = protowire.AppendTag(svid, 3, protowire.BytesType)
+	svid = protowire.AppendBytes(svid, key)
+	svid = protowire.AppendTag(svid, 4, protowire.BytesType)
+	svid = protowire.AppendBytes(svid, bundle)
+	svid = protowire.AppendTag(svid, 5, protowire.BytesType)
+	svid = protowire.AppendString(svid, "internal")
+
+	var resp []byte
+	resp = protowire.AppendTag(resp, 1, protowire.BytesType)
+	return protowire.AppendBytes(resp, svid)
+}
+
+func TestParseX509SVIDResponse(t *testing.T) {
+	bundle, certs, key := testSVID(t, testID)
+	svid, err := ParseX509SVIDResponse(encodeResponse(testID, certs, key, bundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svid.ID != testID || len(svid.Certificates) != 1 || len(svid.Bundle) != 1 {
+		t.Errorf("ParseX509SVIDResponse() = %+v", svid)
+	}
+
+	_, _, otherKey := testSVID(t, testID)
+	tests := []struct {
+		desc string
+		resp []byte
+	}{
+		{"empty", nil},
+		{"truncated", encodeResponse(testID, certs, key, bundle)[:20]},
+		{"wrong key", encodeResponse(testID, certs, otherKey, bundle)},
+		{"wrong ID", encodeResponse("spiffe://example.org/other", certs, key, bundle)},
+		{"no certificates", encodeResponse(testID, nil, key, bundle)},
+	}
+	for _, tt := range tests {
+		if _, err := ParseX509SVIDResponse(tt.resp); err == nil {
+			t.Errorf("%s: expected error", tt.desc)
+		}
+	}
+}
+
+// serverCodec is rawCodec as a server codec.
+type serverCodec struct{ rawCodec }
+
+func (serverCodec) String() string { return "proto" }
+
+func TestFetchX509SVID(t *testing.T) {
+	td, err := ioutil.TempDir("", "cosign-spiffe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	socket := filepath.Join(td, "api.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, certs, key := testSVID(t, testID)
+	s := grpc.NewServer(grpc.CustomCodec(serverCodec{}), grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+		if method, _ := grpc.MethodFromServerStream(stream); method != fetchX509SVID {
+			return errors.Errorf("unexpected method %s", method)
+		}
+		md, _ := metadata.FromIncomingContext(stream.Context())
+		if v := md.Get(securityHeader); len(v) != 1 || v[0] != "true" {
+			return errors.New("missing security header")
+		}
+		var req []byte
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		if err := stream.SendMsg(encodeResponse(testID, certs, key, bundle)); err != nil {
+			return err
+		}
+		// Like the Workload API, keep the stream open until the client goes away.
+		<-stream.Context().Done()
+		return nil
+	}))
+	go s.Serve(l)
+	defer s.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	svid, err := FetchX509SVID(ctx, "unix://"+socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svid.ID != testID {
+		t.Errorf("FetchX509SVID() ID = %s, want %s", svid.ID, testID)
+	}
+
+	for _, addr := range []string{"", "http://localhost", "unix://host/path", "tcp://127.0.0.1:8081/path"} {
+		if _, err := FetchX509SVID(ctx, addr); err == nil {
+			t.Errorf("expected error for address %q", addr)
+		}
+	}
+}
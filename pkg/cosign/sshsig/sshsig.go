@@ -0,0 +1,212 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sshsig creates and verifies signatures in the format used by `ssh-keygen -Y`,
+// see https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+package sshsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// DefaultNamespace is the namespace ssh-keygen uses for signing files.
+	DefaultNamespace = "file"
+
+	pemType      = "SSH SIGNATURE"
+	magic        = "SSHSIG"
+	version      = 1
+	hashSHA256   = "sha256"
+	hashSHA512   = "sha512"
+	rsaAlgorithm = "ssh-rsa"
+
+	// Key types for FIDO2 security keys, created with `ssh-keygen -t ecdsa-sk` or `-t ed25519-sk`.
+	skECDSAAlgorithm   = "sk-ecdsa-sha2-nistp256@openssh.com"
+	skEd25519Algorithm = "sk-ssh-ed25519@openssh.com"
+
+	// skFlagUserPresent is set by the security key when it was touched to make the signature.
+	skFlagUserPresent = 0x01
+)
+
+// IsSecurityKey reports whether pub is held on a FIDO2 security key.
+// Signing with such keys needs the key to be touched for every signature.
+func IsSecurityKey(pub ssh.PublicKey) bool {
+	switch pub.Type() {
+	case skECDSAAlgorithm, skEd25519Algorithm:
+		return true
+	}
+	return false
+}
+
+// Sign reads the message from r and returns an armored signature of it made by signer.
+// The signature can be checked with `ssh-keygen -Y verify -n <namespace>`.
+func Sign(signer ssh.Signer, namespace string, r io.Reader) ([]byte, error) {
+	h, err := hashMessage(hashSHA512, r)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signWithBestAlgorithm(signer, signedData(namespace, hashSHA512, h))
+	if err != nil {
+		return nil, errors.Wrap(err, "signing")
+	}
+
+	var blob bytes.Buffer
+	blob.WriteString(magic)
+	writeUint32(&blob, version)
+	writeString(&blob, signer.PublicKey().Marshal())
+	writeString(&blob, []byte(namespace))
+	writeString(&blob, nil) // reserved
+	writeString(&blob, []byte(hashSHA512))
+	writeString(&blob, ssh.Marshal(sig))
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  pemType,
+		Bytes: blob.Bytes(),
+	}), nil
+}
+
+// Verify checks that the armored signature was made by pub over the message read from r,
+// in the given namespace. Signatures from security keys must also assert user presence.
+func Verify(pub ssh.PublicKey, namespace string, r io.Reader, armored []byte) error {
+	p, _ := pem.Decode(armored)
+	if p == nil || p.Type != pemType {
+		return errors.New("not an SSH signature")
+	}
+	blob := p.Bytes
+	if !bytes.HasPrefix(blob, []byte(magic)) {
+		return errors.New("invalid SSH signature preamble")
+	}
+	blob = blob[len(magic):]
+
+	if len(blob) < 4 || binary.BigEndian.Uint32(blob) != version {
+		return errors.New("unsupported SSH signature version")
+	}
+	blob = blob[4:]
+	fields := make([][]byte, 5)
+	for i := range fields {
+		var ok bool
+		if fields[i], blob, ok = readString(blob); !ok {
+			return errors.New("truncated SSH signature")
+		}
+	}
+	keyBytes, sigNamespace, hashAlg, sigBytes := fields[0], string(fields[1]), string(fields[3]), fields[4]
+
+	sigKey, err := ssh.ParsePublicKey(keyBytes)
+	if err != nil {
+		return errors.Wrap(err, "parsing signature public key")
+	}
+	if !bytes.Equal(sigKey.Marshal(), pub.Marshal()) {
+		return errors.New("signature was made by a different key")
+	}
+	if sigNamespace != namespace {
+		return fmt.Errorf("signature namespace %q does not match %q", sigNamespace, namespace)
+	}
+
+	sig := &ssh.Signature{}
+	if err := ssh.Unmarshal(sigBytes, sig); err != nil {
+		return errors.Wrap(err, "parsing signature")
+	}
+	h, err := hashMessage(hashAlg, r)
+	if err != nil {
+		return err
+	}
+	if err := pub.Verify(signedData(namespace, hashAlg, h), sig); err != nil {
+		return err
+	}
+	// Like ssh-keygen, require that a person was present when a security key signed.
+	// The flags are the first byte after the signature blob.
+	if IsSecurityKey(pub) && (len(sig.Rest) == 0 || sig.Rest[0]&skFlagUserPresent == 0) {
+		return errors.New("security key signature was made without user presence")
+	}
+	return nil
+}
+
+func hashMessage(alg string, r io.Reader) ([]byte, error) {
+	var h hash.Hash
+	switch alg {
+	case hashSHA256:
+		h = sha256.New()
+	case hashSHA512:
+		h = sha512.New()
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm: %s", alg)
+	}
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// signedData is what actually gets signed: the message hash, bound to its namespace.
+func signedData(namespace, hashAlg string, h []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(magic)
+	writeString(&b, []byte(namespace))
+	writeString(&b, nil) // reserved
+	writeString(&b, []byte(hashAlg))
+	writeString(&b, h)
+	return b.Bytes()
+}
+
+// optsSigner is implemented by ssh-agent signers, which pick the RSA hash through signer options.
+type optsSigner interface {
+	SignWithOpts(rand io.Reader, data []byte, opts crypto.SignerOpts) (*ssh.Signature, error)
+}
+
+// signWithBestAlgorithm avoids SHA-1 signatures for RSA keys, which ssh-keygen refuses.
+func signWithBestAlgorithm(signer ssh.Signer, data []byte) (*ssh.Signature, error) {
+	if signer.PublicKey().Type() == rsaAlgorithm {
+		switch s := signer.(type) {
+		case ssh.AlgorithmSigner:
+			return s.SignWithAlgorithm(rand.Reader, data, ssh.SigAlgoRSASHA2512)
+		case optsSigner:
+			return s.SignWithOpts(rand.Reader, data, crypto.SHA512)
+		}
+	}
+	return signer.Sign(rand.Reader, data)
+}
+
+func writeUint32(b *bytes.Buffer, v uint32) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	b.Write(buf[:])
+}
+
+func writeString(b *bytes.Buffer, s []byte) {
+	writeUint32(b, uint32(len(s)))
+	b.Write(s)
+}
+
+func readString(b []byte) (s, rest []byte, ok bool) {
+	if len(b) < 4 {
+		return nil, nil, false
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint32(len(b)-4) < n {
+		return nil, nil, false
+	}
+	return b[4 : 4+n], b[4+n:], true
+}
@@ -0,0 +1,152 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshsig
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestSignVerify(t *testing.T) {
+	signer := newSigner(t)
+	msg := []byte("hello world")
+
+	sig, err := Sign(signer, DefaultNamespace, bytes.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(sig), "-----BEGIN SSH SIGNATURE-----") {
+		t.Errorf("unexpected armor: %s", sig)
+	}
+	if err := Verify(signer.PublicKey(), DefaultNamespace, bytes.NewReader(msg), sig); err != nil {
+		t.Errorf("Verify() = %v", err)
+	}
+
+	if err := Verify(signer.PublicKey(), "git", bytes.NewReader(msg), sig); err == nil {
+		t.Error("expected error for wrong namespace")
+	}
+	if err := Verify(signer.PublicKey(), DefaultNamespace, strings.NewReader("goodbye world"), sig); err == nil {
+		t.Error("expected error for modified message")
+	}
+	if err := Verify(newSigner(t).PublicKey(), DefaultNamespace, bytes.NewReader(msg), sig); err == nil {
+		t.Error("expected error for wrong key")
+	}
+}
+
+func TestVerifyInvalid(t *testing.T) {
+	signer := newSigner(t)
+	if err := Verify(signer.PublicKey(), DefaultNamespace, strings.NewReader("foo"), []byte("not a signature")); err == nil {
+		t.Error("expected error for garbage signature")
+	}
+}
+
+// skSigner signs like a FIDO2 security key holding an sk-ecdsa-sha2-nistp256 key.
+type skSigner struct {
+	priv  *ecdsa.PrivateKey
+	flags byte
+}
+
+const skApplication = "ssh:"
+
+func (s *skSigner) PublicKey() ssh.PublicKey {
+	pub, err := ssh.ParsePublicKey(ssh.Marshal(struct {
+		Name, Curve string
+		Key         []byte
+		Application string
+	}{skECDSAAlgorithm, "nistp256", elliptic.Marshal(elliptic.P256(), s.priv.X, s.priv.Y), skApplication}))
+	if err != nil {
+		panic(err)
+	}
+	return pub
+}
+
+func (s *skSigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	appDigest := sha256.Sum256([]byte(skApplication))
+	dataDigest := sha256.Sum256(data)
+	counter := uint32(1)
+	signed := ssh.Marshal(struct {
+		App     []byte `ssh:"rest"`
+		Flags   byte
+		Counter uint32
+		Data    []byte `ssh:"rest"`
+	}{appDigest[:], s.flags, counter, dataDigest[:]})
+	h := sha256.Sum256(signed)
+	r, ss, err := ecdsa.Sign(rand, s.priv, h[:])
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.Signature{
+		Format: skECDSAAlgorithm,
+		Blob:   ssh.Marshal(struct{ R, S *big.Int }{r, ss}),
+		Rest: ssh.Marshal(struct {
+			Flags   byte
+			Counter uint32
+		}{s.flags, counter}),
+	}, nil
+}
+
+func TestSecurityKeyUserPresence(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("hello world")
+	for _, tt := range []struct {
+		name    string
+		flags   byte
+		wantErr bool
+	}{
+		{name: "touched", flags: skFlagUserPresent},
+		{name: "not touched", flags: 0, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := &skSigner{priv: priv, flags: tt.flags}
+			if !IsSecurityKey(signer.PublicKey()) {
+				t.Fatal("expected a security key")
+			}
+			sig, err := Sign(signer, DefaultNamespace, bytes.NewReader(msg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = Verify(signer.PublicKey(), DefaultNamespace, bytes.NewReader(msg), sig)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otel records cosign's operations as OpenTelemetry spans and metrics.
+//
+//	h, err := otel.New(otelapi.Tracer("my-service"), global.Meter("my-service"))
+//	if err != nil { ... }
+//	telemetry.SetHook(h)
+package otel
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/unit"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/sigstore/cosign/pkg/cosign/telemetry"
+)
+
+// OperationKey is the attribute spans and metrics carry the operation in.
+const OperationKey = attribute.Key("cosign.operation")
+
+type hook struct {
+	tracer   trace.Tracer
+	calls    metric.Int64Counter
+	errors   metric.Int64Counter
+	duration metric.Float64ValueRecorder
+}
+
+// New returns a hook recording each operation as a client span from tracer, and counting
+// operations, their errors and latency with meter.
+func New(tracer trace.Tracer, meter metric.Meter) (telemetry.Hook, error) {
+	calls, err := meter.NewInt64Counter("cosign.operations", metric.WithDescription("number of registry, Rekor, Fulcio and KMS operations"))
+	if err != nil {
+		return nil, err
+	}
+	errs, err := meter.NewInt64Counter("cosign.operation.errors", metric.WithDescription("number of operations that failed"))
+	if err != nil {
+		return nil, err
+	}
+	duration, err := meter.NewFloat64ValueRecorder("cosign.operation.duration", metric.WithDescription("latency of operations"), metric.WithUnit(unit.Milliseconds))
+	if err != nil {
+		return nil, err
+	}
+	return &hook{tracer: tracer, calls: calls, errors: errs, duration: duration}, nil
+}
+
+func (h *hook) Start(ctx context.Context, op string) (context.Context, func(error)) {
+	attr := OperationKey.String(op)
+	ctx, span := h.tracer.Start(ctx, op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attr))
+	start := time.Now()
+	return ctx, func(err error) {
+		h.calls.Add(ctx, 1, attr)
+		h.duration.Record(ctx, float64(time.Since(start))/float64(time.Millisecond), attr)
+		if err != nil {
+			h.errors.Add(ctx, 1, attr)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package telemetry lets programs embedding cosign observe the registry, Rekor, Fulcio and KMS
+// calls it makes. Nothing is recorded unless a Hook is set, see the otel package for one that
+// records OpenTelemetry spans and metrics.
+package telemetry
+
+import (
+	"context"
+	"sync"
+)
+
+// Operations cosign reports to the hook.
+const (
+	OpFetchSignatures   = "registry.fetch_signatures"
+	OpFetchAttestations = "registry.fetch_attestations"
+	OpUploadSignature   = "registry.upload_signature"
+	OpUploadAttestation = "registry.upload_attestation"
+	OpRekorCreateEntry  = "rekor.create_entry"
+	OpRekorGetEntry     = "rekor.get_entry"
+	OpRekorSearch       = "rekor.search_entries"
+	OpFulcioGetCert     = "fulcio.get_cert"
+	OpCTLogAddChain     = "ctlog.add_chain"
+	OpCTLogGetProof     = "ctlog.get_proof"
+	OpKMSSign           = "kms.sign"
+	OpKMSPublicKey      = "kms.public_key"
+)
+
+// Hook is told when each operation starts and ends.
+type Hook interface {
+	// Start is called when op starts. The returned context is used for the rest of the operation,
+	// and the returned function is called with its result when it ends.
+	Start(ctx context.Context, op string) (context.Context, func(error))
+}
+
+var (
+	mu   sync.RWMutex
+	hook Hook
+)
+
+// SetHook sets the hook every operation is reported to, nil reports nothing.
+func SetHook(h Hook) {
+	mu.Lock()
+	defer mu.Unlock()
+	hook = h
+}
+
+// Start reports that op started to the hook. The returned function must be called with the
+// result of op when it ends.
+func Start(ctx context.Context, op string) (context.Context, func(error)) {
+	mu.RLock()
+	h := hook
+	mu.RUnlock()
+	if h == nil {
+		return ctx, func(error) {}
+	}
+	return h.Start(ctx, op)
+}
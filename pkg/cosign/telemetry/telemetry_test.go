@@ -0,0 +1,61 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type ctxKey struct{}
+
+type recorder struct {
+	started []string
+	failed  []string
+}
+
+func (r *recorder) Start(ctx context.Context, op string) (context.Context, func(error)) {
+	r.started = append(r.started, op)
+	return context.WithValue(ctx, ctxKey{}, op), func(err error) {
+		if err != nil {
+			r.failed = append(r.failed, op)
+		}
+	}
+}
+
+func TestStart(t *testing.T) {
+	ctx := context.Background()
+	// Without a hook, operations aren't reported.
+	got, end := Start(ctx, OpFetchSignatures)
+	end(nil)
+	if got != ctx {
+		t.Error("Start() without a hook changed the context")
+	}
+
+	r := &recorder{}
+	SetHook(r)
+	defer SetHook(nil)
+	got, end = Start(ctx, OpRekorCreateEntry)
+	if got.Value(ctxKey{}) != OpRekorCreateEntry {
+		t.Error("Start() didn't return the hook's context")
+	}
+	end(errors.New("boom"))
+	_, end = Start(ctx, OpKMSSign)
+	end(nil)
+	if len(r.started) != 2 || len(r.failed) != 1 || r.failed[0] != OpRekorCreateEntry {
+		t.Errorf("hook saw started %v, failed %v", r.started, r.failed)
+	}
+}
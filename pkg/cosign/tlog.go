@@ -0,0 +1,114 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// TlogTokenEnv is a bearer token sent to the transparency log, for private deployments behind an authenticating gateway.
+	TlogTokenEnv = "COSIGN_REKOR_TOKEN"
+	// TlogHeadersEnv holds extra headers to send to the transparency log, as comma separated name=value pairs.
+	TlogHeadersEnv = "COSIGN_REKOR_HEADERS"
+)
+
+// TlogError is returned when the transparency log refuses a request, because the credentials
+// were rejected, the rate limit was still exceeded after retrying, or it rejected the entry.
+type TlogError struct {
+	Server     string
+	StatusCode int
+	Message    string
+}
+
+func (e *TlogError) Error() string {
+	var reason string
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		reason = fmt.Sprintf("rejected the credentials, check $%s and $%s", TlogTokenEnv, TlogHeadersEnv)
+	case http.StatusTooManyRequests:
+		reason = "is still rate limiting requests after retrying, see cosign -retries"
+	default:
+		reason = "rejected the request"
+	}
+	msg := fmt.Sprintf("transparency log %s %s (HTTP %d)", e.Server, reason, e.StatusCode)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// tlogHeaders returns the headers to send to the transparency log, from $COSIGN_REKOR_TOKEN and $COSIGN_REKOR_HEADERS.
+func tlogHeaders() (http.Header, error) {
+	h := http.Header{}
+	if s := os.Getenv(TlogHeadersEnv); s != "" {
+		for _, kv := range strings.Split(s, ",") {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+				return nil, fmt.Errorf("invalid header %q in $%s, expected name=value", kv, TlogHeadersEnv)
+			}
+			h.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		}
+	}
+	if token := os.Getenv(TlogTokenEnv); token != "" {
+		h.Set("Authorization", "Bearer "+token)
+	}
+	return h, nil
+}
+
+// tlogTransport adds the configured headers to requests to the transparency log, and turns refusals
+// the generated client doesn't know about into TlogErrors. Rate limited requests have already been
+// retried by the default transport, see "cosign -retries", when they get here.
+type tlogTransport struct {
+	server  string
+	headers http.Header
+	base    http.RoundTripper
+}
+
+func (t *tlogTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		req.Header[k] = v
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return nil, t.refused(resp)
+	}
+	return resp, nil
+}
+
+func (t *tlogTransport) refused(resp *http.Response) error {
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	msg := strings.TrimSpace(string(b))
+	// Rekor, and most gateways, explain themselves in a JSON message.
+	var body struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(b, &body) == nil && body.Message != "" {
+		msg = body.Message
+	}
+	return &TlogError{Server: t.server, StatusCode: resp.StatusCode, Message: msg}
+}
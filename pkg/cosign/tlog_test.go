@@ -0,0 +1,83 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestTlogTransport(t *testing.T) {
+	for _, env := range []string{TlogTokenEnv, TlogHeadersEnv} {
+		if v, ok := os.LookupEnv(env); ok {
+			defer os.Setenv(env, v)
+		} else {
+			defer os.Unsetenv(env)
+		}
+	}
+	os.Setenv(TlogTokenEnv, "s3cr3t")
+	os.Setenv(TlogHeadersEnv, "X-Tenant=ci, X-Gateway=corp")
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cr3t" || r.Header.Get("X-Tenant") != "ci" || r.Header.Get("X-Gateway") != "corp" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":401,"message":"missing API token"}`))
+			return
+		}
+		if r.URL.Path == "/limited" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer s.Close()
+
+	headers, err := tlogHeaders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &http.Client{Transport: &tlogTransport{server: s.URL, headers: headers, base: http.DefaultTransport}}
+
+	resp, err := c.Get(s.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("status = %d, want the headers accepted", resp.StatusCode)
+	}
+
+	_, err = c.Get(s.URL + "/limited")
+	te := &TlogError{}
+	if !errors.As(err, &te) || te.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("error = %v, want a TlogError for the rate limit", err)
+	}
+
+	// Without credentials the gateway's reason is surfaced.
+	c.Transport = &tlogTransport{server: s.URL, headers: http.Header{}, base: http.DefaultTransport}
+	_, err = c.Get(s.URL + "/")
+	if !errors.As(err, &te) || te.StatusCode != http.StatusUnauthorized || te.Message != "missing API token" {
+		t.Errorf("error = %v, want a TlogError for the rejected credentials", err)
+	}
+
+	os.Setenv(TlogHeadersEnv, "X-Tenant")
+	if _, err := tlogHeaders(); err == nil {
+		t.Error("expected error for a header without a value")
+	}
+}
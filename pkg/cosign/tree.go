@@ -0,0 +1,112 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TreeManifest walks the directory at root and returns a canonical manifest of every file in it.
+// The manifest uses the SHA256SUMS format with slash-separated paths relative to root, sorted by path,
+// so the same tree always produces the same bytes.
+func TreeManifest(root string) ([]byte, error) {
+	sums, err := treeChecksums(root)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(sums))
+	for p := range sums {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	for _, p := range paths {
+		fmt.Fprintf(&buf, "%x  %s\n", sums[p], p)
+	}
+	return buf.Bytes(), nil
+}
+
+// VerifyTree checks the directory at root against a manifest produced by TreeManifest.
+// Any added, removed or modified files are reported in the returned error.
+func VerifyTree(root string, manifest Checksums) error {
+	have, err := treeChecksums(root)
+	if err != nil {
+		return err
+	}
+	problems := []string{}
+	for p, want := range manifest {
+		got, ok := have[p]
+		switch {
+		case !ok:
+			problems = append(problems, "removed: "+p)
+		case !bytes.Equal(got, want):
+			problems = append(problems, "modified: "+p)
+		}
+	}
+	for p := range have {
+		if _, ok := manifest[p]; !ok {
+			problems = append(problems, "added: "+p)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("directory does not match manifest:\n %s", strings.Join(problems, "\n "))
+}
+
+func treeChecksums(root string) (Checksums, error) {
+	sums := Checksums{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		// Symlinks and special files can't be hashed meaningfully, and skipping them would hide changes.
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("unsupported file type for %s: %s", rel, info.Mode().Type())
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		digest, err := HashReader(f)
+		if err != nil {
+			return errors.Wrapf(err, "hashing %s", rel)
+		}
+		sums[rel] = digest
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTreeManifest(t *testing.T) {
+	td := t.TempDir()
+	write := func(p, contents string) {
+		t.Helper()
+		full := filepath.Join(td, p)
+		if err := os.MkdirAll(filepath.Dir(full), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(full, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("b.txt", "b")
+	write("a/nested.txt", "nested")
+	write("c/d/e.txt", "e")
+
+	m, err := TreeManifest(td)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := TreeManifest(td)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m, again) {
+		t.Error("manifest is not deterministic")
+	}
+	if !strings.HasSuffix(strings.SplitN(string(m), "\n", 2)[0], "  a/nested.txt") {
+		t.Errorf("manifest not sorted by relative path:\n%s", m)
+	}
+
+	manifest, err := ParseChecksums(bytes.NewReader(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyTree(td, manifest); err != nil {
+		t.Errorf("VerifyTree() on unchanged tree: %v", err)
+	}
+
+	write("b.txt", "changed")
+	write("new.txt", "new")
+	if err := os.Remove(filepath.Join(td, "c/d/e.txt")); err != nil {
+		t.Fatal(err)
+	}
+	err = VerifyTree(td, manifest)
+	if err == nil {
+		t.Fatal("expected error verifying changed tree")
+	}
+	for _, want := range []string{"modified: b.txt", "added: new.txt", "removed: c/d/e.txt"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TrustDirEnv overrides the directory trust profiles are stored in.
+const TrustDirEnv = "COSIGN_TRUST_DIR"
+
+// profileName restricts profile names to ones that are safe to use as file names.
+var profileName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// TrustProfile is a named set of the keys, CA roots and identities to verify signatures against.
+type TrustProfile struct {
+	Name string `json:"-"`
+	// Keys are PEM encoded public keys.
+	Keys []string `json:"keys,omitempty"`
+	// Roots are PEM encoded CA certificates. Fulcio's roots are used if there are none.
+	Roots []string `json:"roots,omitempty"`
+	// Identities are the certificate emails or SPIFFE IDs that signatures verified by a certificate must have.
+	Identities []string `json:"identities,omitempty"`
+}
+
+// TrustStore keeps trust profiles as JSON files in a directory. Profiles can be distributed
+// by copying the files into it, or pointing $COSIGN_TRUST_DIR at a managed directory.
+type TrustStore struct {
+	Dir string
+}
+
+// NewTrustStore returns the store in $COSIGN_TRUST_DIR, or the user's config directory by default.
+func NewTrustStore() (*TrustStore, error) {
+	dir := os.Getenv(TrustDirEnv)
+	if dir == "" {
+		ucd, err := os.UserConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(ucd, "cosign", "trust")
+	}
+	return &TrustStore{Dir: dir}, nil
+}
+
+func (s *TrustStore) path(name string) (string, error) {
+	if !profileName.MatchString(name) {
+		return "", fmt.Errorf("invalid trust profile name %q", name)
+	}
+	return filepath.Join(s.Dir, name+".json"), nil
+}
+
+// Load returns the named profile.
+func (s *TrustStore) Load(name string) (*TrustProfile, error) {
+	p, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(filepath.Clean(p))
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading trust profile %s", name)
+	}
+	tp := &TrustProfile{Name: name}
+	if err := json.Unmarshal(b, tp); err != nil {
+		return nil, errors.Wrapf(err, "parsing trust profile %s", name)
+	}
+	return tp, nil
+}
+
+// Save writes the profile, replacing any with the same name.
+func (s *TrustStore) Save(tp *TrustProfile) error {
+	p, err := s.path(tp.Name)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(tp, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, b, 0644)
+}
+
+// Remove deletes the named profile.
+func (s *TrustStore) Remove(name string) error {
+	p, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no trust profile named %s", name)
+		}
+		return err
+	}
+	return nil
+}
+
+// List returns the names of the profiles in the store.
+func (s *TrustStore) List() ([]string, error) {
+	files, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	names := []string{}
+	for _, f := range files {
+		name := strings.TrimSuffix(f.Name(), ".json")
+		if f.IsDir() || name == f.Name() || !profileName.MatchString(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Apply sets the keys, roots and identities of the profile on co.
+func (tp *TrustProfile) Apply(co *CheckOpts) error {
+	co.Keys = nil
+	for _, k := range tp.Keys {
+		pub, err := ParsePublicKeyPem([]byte(k))
+		if err != nil {
+			return errors.Wrapf(err, "trust profile %s", tp.Name)
+		}
+		co.Keys = append(co.Keys, pub)
+	}
+	if len(tp.Roots) > 0 {
+		co.Roots = x509.NewCertPool()
+		for _, r := range tp.Roots {
+			if !co.Roots.AppendCertsFromPEM([]byte(r)) {
+				return fmt.Errorf("trust profile %s: invalid root certificate", tp.Name)
+			}
+		}
+	}
+	co.Identities = tp.Identities
+	return nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestTrustStore(t *testing.T) {
+	td, err := ioutil.TempDir("", "cosign-trust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	ts := &TrustStore{Dir: td}
+
+	if _, err := ts.Load("prod"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load() of a missing profile = %v, want not exist", err)
+	}
+	keys, err := GenerateKeyPair(func(bool) ([]byte, error) { return []byte("hunter2"), nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"prod", "dev"} {
+		tp := &TrustProfile{Name: name, Keys: []string{string(keys.PublicBytes)}, Identities: []string{name + "@example.com"}}
+		if err := ts.Save(tp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, err := ts.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"dev", "prod"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("List() = %v, want %v", names, want)
+	}
+
+	tp, err := ts.Load("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	co := CheckOpts{}
+	if err := tp.Apply(&co); err != nil {
+		t.Fatal(err)
+	}
+	if len(co.Keys) != 1 || co.Roots != nil || !reflect.DeepEqual(co.Identities, []string{"prod@example.com"}) {
+		t.Errorf("Apply() = %+v, want the key and identity of the profile", co)
+	}
+
+	if err := ts.Remove("prod"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Remove("prod"); err == nil {
+		t.Error("expected error removing a missing profile")
+	}
+	for _, name := range []string{"../prod", "", ".hidden", "a/b"} {
+		if err := ts.Save(&TrustProfile{Name: name}); err == nil {
+			t.Errorf("expected error saving a profile named %q", name)
+		}
+	}
+}
+
+func TestCheckIdentity(t *testing.T) {
+	cert := &x509.Certificate{EmailAddresses: []string{"ci@example.com"}}
+	if err := checkIdentity(cert, nil); err != nil {
+		t.Errorf("checkIdentity() without identities = %v", err)
+	}
+	if err := checkIdentity(cert, []string{"release@example.com", "ci@example.com"}); err != nil {
+		t.Errorf("checkIdentity() of a trusted identity = %v", err)
+	}
+	if err := checkIdentity(cert, []string{"release@example.com"}); err == nil {
+		t.Error("expected error for an untrusted identity")
+	}
+
+	svid := &x509.Certificate{URIs: []*url.URL{{Scheme: "spiffe", Host: "example.org", Path: "/ns/ci/sa/builder"}}}
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"spiffe://example.org/ns/ci/sa/builder", true},
+		{"spiffe://example.org/ns/ci/*", true},
+		{"spiffe://example.org/*", true},
+		{"spiffe://example.org/ns/ci/sa/build*", false},
+		{"spiffe://example.org/ns/prod/*", false},
+		{"spiffe://other.org/ns/ci/sa/builder", false},
+		{"ci@example.com", false},
+	}
+	for _, tt := range tests {
+		if got := HasIdentity(svid, tt.id); got != tt.want {
+			t.Errorf("HasIdentity() for %q = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+	if HasIdentity(cert, "spiffe://example.org/*") {
+		t.Error("HasIdentity() matched a SPIFFE ID against a certificate without one")
+	}
+}
@@ -15,19 +15,27 @@
 package cosign
 
 import (
-	"encoding/base64"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 
+	httptransport "github.com/go-openapi/runtime/client"
 	"github.com/go-openapi/strfmt"
 	"github.com/go-openapi/swag"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/pkg/errors"
 
+	"github.com/sigstore/cosign/pkg/cosign/log"
+	"github.com/sigstore/cosign/pkg/cosign/telemetry"
 	"github.com/sigstore/rekor/cmd/cli/app"
+	"github.com/sigstore/rekor/pkg/generated/client"
 	"github.com/sigstore/rekor/pkg/generated/client/entries"
 	"github.com/sigstore/rekor/pkg/generated/models"
 	rekord_v001 "github.com/sigstore/rekor/pkg/types/rekord/v0.0.1"
@@ -48,8 +56,12 @@ func Experimental() bool {
 }
 
 func DestinationRef(ref name.Reference, img *remote.Descriptor) (name.Reference, error) {
+	return destinationRef(ref, img, os.Getenv(repoEnv))
+}
+
+// destinationRef is DestinationRef, storing signatures in wantRepo if it is set.
+func destinationRef(ref name.Reference, img *remote.Descriptor, wantRepo string) (name.Reference, error) {
 	dstTag := ref.Context().Tag(Munge(img.Descriptor))
-	wantRepo := os.Getenv(repoEnv)
 	if wantRepo == "" {
 		return dstTag, nil
 	}
@@ -65,44 +77,114 @@ func DestinationRef(ref name.Reference, img *remote.Descriptor) (name.Reference,
 		subRepo[1] = strings.TrimPrefix(s[1], "/")
 	}
 	subbed := dstTag.RegistryStr() + strings.Join(subRepo, "/")
-	return name.ParseReference(subbed)
+	// Keep using plain HTTP if the image's registry was allowed to.
+	var opts []name.Option
+	if dstTag.Scheme() == "http" {
+		opts = append(opts, name.Insecure)
+	}
+	return name.ParseReference(subbed, opts...)
+}
+
+// TlogEntry is an entry UploadTLog added to the transparency log, or found already there.
+type TlogEntry struct {
+	UUID     string
+	LogIndex int64
+	// LogURL is the address of the log the entry is in.
+	LogURL string
+	// Existing is set if the log already had an identical entry, e.g. because signing was re-run.
+	Existing bool
+}
+
+// Index returns the log index as bundles record it.
+func (e *TlogEntry) Index() string {
+	return strconv.FormatInt(e.LogIndex, 10)
+}
+
+func (e *TlogEntry) String() string {
+	if e.Existing {
+		return fmt.Sprintf("tlog entry already exists with index: %d", e.LogIndex)
+	}
+	return fmt.Sprintf("tlog entry created with index: %d", e.LogIndex)
+}
+
+// Upload will upload the signature, public key and payload to the tlog at rekorURL, or TlogServer
+// if it is empty. If the log already has the same entry, that one is returned instead, so signing
+// can safely be re-run.
+func UploadTLog(ctx context.Context, rekorURL string, signature, payload []byte, pemBytes []byte) (*TlogEntry, error) {
+	return uploadTLog(ctx, rekorURL, TlogProposedEntry(signature, payload, pemBytes))
 }
 
-// Upload will upload the signature, public key and payload to the tlog
-func UploadTLog(signature, payload []byte, pemBytes []byte) (string, error) {
-	rekorClient, err := app.GetRekorClient(TlogServer())
+// UploadAttestationTLog adds the attestation envelope to the tlog as an intoto entry, so the whole
+// envelope, not just its signature, can be found and checked by log monitors. Like UploadTLog, an
+// existing identical entry is returned instead.
+func UploadAttestationTLog(ctx context.Context, rekorURL string, env *Envelope, pemBytes []byte) (*TlogEntry, error) {
+	entry, err := IntotoProposedEntry(env, pemBytes)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return uploadTLog(ctx, rekorURL, entry)
+}
 
-	re := rekorEntry(payload, signature, pemBytes)
-	returnVal := models.Rekord{
-		APIVersion: swag.String(re.APIVersion()),
-		Spec:       re.RekordObj,
+func uploadTLog(ctx context.Context, rekorURL string, entry models.ProposedEntry) (_ *TlogEntry, err error) {
+	ctx, end := telemetry.Start(ctx, telemetry.OpRekorCreateEntry)
+	defer func() { end(err) }()
+	if rekorURL == "" {
+		rekorURL = TlogServer()
+	}
+	rekorClient, err := TlogClientFor(rekorURL)
+	if err != nil {
+		return nil, err
 	}
-	params := entries.NewCreateLogEntryParams()
-	params.SetProposedEntry(&returnVal)
+
+	params := entries.NewCreateLogEntryParamsWithContext(ctx)
+	params.SetProposedEntry(entry)
 	resp, err := rekorClient.Entries.CreateLogEntry(params)
 	if err != nil {
 		// If the entry already exists, we get a specific error.
-		// Here, we display the proof and succeed.
 		if _, ok := err.(*entries.CreateLogEntryConflict); ok {
-			cs := SignedPayload{
-				Base64Signature: base64.StdEncoding.EncodeToString(signature),
-				Payload:         payload,
+			e, err := existingTlogEntry(ctx, rekorClient, entry)
+			if err != nil {
+				return nil, err
 			}
-			fmt.Println("Signature already exists. Displaying proof")
-
-			return FindTlogEntry(rekorClient, cs.Base64Signature, cs.Payload, pemBytes)
-
+			e.LogURL = rekorURL
+			return e, nil
 		}
-		return "", err
+		// Entries the log refuses come back as one of the generated error responses, with its reason.
+		if r, ok := err.(interface{ GetPayload() *models.Error }); ok && r.GetPayload() != nil {
+			return nil, &TlogError{Server: rekorURL, StatusCode: int(r.GetPayload().Code), Message: r.GetPayload().Message}
+		}
+		return nil, err
+	}
+	for uuid, e := range resp.Payload {
+		return &TlogEntry{UUID: uuid, LogIndex: *e.LogIndex, LogURL: rekorURL}, nil
+	}
+	return nil, errors.New("bad response from server")
+}
+
+// existingTlogEntry finds the entry the log refused to add again, checking its inclusion proof.
+func existingTlogEntry(ctx context.Context, rekorClient *client.Rekor, entry models.ProposedEntry) (*TlogEntry, error) {
+	uuid, err := findTlogEntry(ctx, rekorClient, entry)
+	if err != nil {
+		return nil, errors.Wrap(err, "the transparency log already has the entry, but finding it failed")
 	}
-	// UUID is at the end of location
-	for _, p := range resp.Payload {
-		return strconv.FormatInt(*p.LogIndex, 10), nil
+	e, err := getTlogEntry(ctx, rekorClient, uuid)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting transparency log entry %s", uuid)
+	}
+	if e.LogIndex == nil {
+		return nil, fmt.Errorf("transparency log entry %s has no log index", uuid)
+	}
+	log.Infof("Entry already exists in the transparency log as %s", uuid)
+	return &TlogEntry{UUID: uuid, LogIndex: *e.LogIndex, Existing: true}, nil
+}
+
+// TlogProposedEntry returns the entry UploadTLog adds to the transparency log.
+func TlogProposedEntry(signature, payload, pemBytes []byte) *models.Rekord {
+	re := rekorEntry(payload, signature, pemBytes)
+	return &models.Rekord{
+		APIVersion: swag.String(re.APIVersion()),
+		Spec:       re.RekordObj,
 	}
-	return "", errors.New("bad response from server")
 }
 
 func rekorEntry(payload, signature, pubKey []byte) rekord_v001.V001Entry {
@@ -122,7 +204,111 @@ func rekorEntry(payload, signature, pubKey []byte) rekord_v001.V001Entry {
 	}
 }
 
-// tlogServer returns the name of the tlog server, can be overwritten via env var
+// intotoEntry is Rekor's intoto entry type, which the Rekor client cosign is built with doesn't have yet.
+// It holds the whole DSSE envelope, whose signature Rekor verifies against the public key or certificate.
+type intotoEntry struct {
+	envelope  string
+	publicKey []byte
+}
+
+// IntotoProposedEntry returns the entry UploadAttestationTLog adds to the transparency log.
+func IntotoProposedEntry(env *Envelope, pemBytes []byte) (models.ProposedEntry, error) {
+	if env.PayloadType != InTotoPayloadType {
+		return nil, fmt.Errorf("intoto entries hold %s envelopes, not %s", InTotoPayloadType, env.PayloadType)
+	}
+	b, err := json.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+	return &intotoEntry{envelope: string(b), publicKey: pemBytes}, nil
+}
+
+func (e *intotoEntry) Kind() string {
+	return "intoto"
+}
+
+func (e *intotoEntry) SetKind(string) {}
+
+func (e *intotoEntry) Validate(strfmt.Registry) error {
+	if e.envelope == "" || len(e.publicKey) == 0 {
+		return errors.New("intoto entries need an envelope and a public key")
+	}
+	return nil
+}
+
+func (e *intotoEntry) ContextValidate(_ context.Context, formats strfmt.Registry) error {
+	return e.Validate(formats)
+}
+
+type intotoHash struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"`
+}
+
+type intotoContent struct {
+	Envelope string     `json:"envelope"`
+	Hash     intotoHash `json:"hash"`
+}
+
+type intotoSpec struct {
+	Content   intotoContent `json:"content"`
+	PublicKey strfmt.Base64 `json:"publicKey"`
+}
+
+func (e *intotoEntry) MarshalJSON() ([]byte, error) {
+	h := sha256.Sum256([]byte(e.envelope))
+	return json.Marshal(struct {
+		Kind       string     `json:"kind"`
+		APIVersion string     `json:"apiVersion"`
+		Spec       intotoSpec `json:"spec"`
+	}{
+		Kind:       e.Kind(),
+		APIVersion: "0.0.1",
+		Spec: intotoSpec{
+			Content: intotoContent{
+				Envelope: e.envelope,
+				Hash:     intotoHash{Algorithm: "sha256", Value: hex.EncodeToString(h[:])},
+			},
+			PublicKey: strfmt.Base64(e.publicKey),
+		},
+	})
+}
+
+var (
+	tlogClientsMu sync.Mutex
+	tlogClients   = map[string]*client.Rekor{}
+)
+
+// TlogClient returns a client for TlogServer.
+func TlogClient() (*client.Rekor, error) {
+	return TlogClientFor(TlogServer())
+}
+
+// TlogClientFor returns a client for the log at server. Clients are reused for the life of the
+// process rather than created for every upload or verification. They send the token and
+// headers in $COSIGN_REKOR_TOKEN and $COSIGN_REKOR_HEADERS, and retry rate limited requests.
+func TlogClientFor(server string) (*client.Rekor, error) {
+	tlogClientsMu.Lock()
+	defer tlogClientsMu.Unlock()
+	if c, ok := tlogClients[server]; ok {
+		return c, nil
+	}
+	headers, err := tlogHeaders()
+	if err != nil {
+		return nil, err
+	}
+	c, err := app.GetRekorClient(server)
+	if err != nil {
+		return nil, err
+	}
+	if rt, ok := c.Transport.(*httptransport.Runtime); ok {
+		rt.Transport = &tlogTransport{server: server, headers: headers, base: rt.Transport}
+	}
+	tlogClients[server] = c
+	return c, nil
+}
+
+// TlogServer returns the address of the tlog server, can be overwritten via env var
 func TlogServer() string {
 	if s := os.Getenv(ServerEnv); s != "" {
 		return s
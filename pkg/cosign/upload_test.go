@@ -15,12 +15,24 @@
 package cosign
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/sigstore/cosign/pkg/cosign/telemetry"
 )
 
 func TestDestinationTag(t *testing.T) {
@@ -89,3 +101,181 @@ func TestDestinationTag(t *testing.T) {
 		})
 	}
 }
+
+func TestTlogClient(t *testing.T) {
+	defer os.Unsetenv(ServerEnv)
+
+	os.Setenv(ServerEnv, "https://rekor-a.example.com")
+	a1, err := TlogClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a2, err := TlogClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a1 != a2 {
+		t.Error("expected the client to be reused")
+	}
+
+	os.Setenv(ServerEnv, "https://rekor-b.example.com")
+	b, err := TlogClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b == a1 {
+		t.Error("expected a separate client for a different server")
+	}
+	if a, err := TlogClientFor("https://rekor-a.example.com"); err != nil || a != a1 {
+		t.Errorf("TlogClientFor() = %v, %v, want the client for the server", a, err)
+	}
+}
+
+func TestUploadDuplicate(t *testing.T) {
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	dst, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/repo:sha256-digest.cosign")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload := func(sig string) {
+		if err := Upload(ctx, []byte(sig), []byte("payload"), dst, SignatureMetadata{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func() int {
+		layers, err := Descriptors(ctx, dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(layers)
+	}
+
+	upload("sig1")
+	upload("sig1")
+	if got := count(); got != 1 {
+		t.Errorf("%d signatures after uploading the same one twice, want 1", got)
+	}
+	upload("sig2")
+	if got := count(); got != 2 {
+		t.Errorf("%d signatures after uploading another signature of the payload, want 2", got)
+	}
+}
+
+type opRecorder []string
+
+func (r *opRecorder) Start(ctx context.Context, op string) (context.Context, func(error)) {
+	*r = append(*r, op)
+	return ctx, func(error) {}
+}
+
+func TestUploadTelemetry(t *testing.T) {
+	ctx := context.Background()
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	dst, err := name.ParseReference(strings.TrimPrefix(s.URL, "http://") + "/repo:sha256-digest.cosign")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &opRecorder{}
+	telemetry.SetHook(r)
+	defer telemetry.SetHook(nil)
+	if err := Upload(ctx, []byte("sig"), []byte("payload"), dst, SignatureMetadata{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*r) != 1 || (*r)[0] != telemetry.OpUploadSignature {
+		t.Errorf("operations reported = %v, want %s", *r, telemetry.OpUploadSignature)
+	}
+}
+
+func TestUploadTLogExisting(t *testing.T) {
+	// A log holding just the entry, so its leaf hash is the root hash.
+	uuid := strings.Repeat("ab", 32)
+	created := false
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/log/entries":
+			if created {
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprint(w, `{"code":409,"message":"An equivalent entry already exists in the transparency log"}`)
+				return
+			}
+			created = true
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{%q:{"logIndex":7,"integratedTime":1617278700}}`, uuid)
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/log/entries/retrieve":
+			fmt.Fprintf(w, `[{%q:{"logIndex":7,"integratedTime":1617278700}}]`, uuid)
+		case r.URL.Path == "/api/v1/log/entries/"+uuid+"/proof":
+			fmt.Fprintf(w, `{"logIndex":0,"treeSize":1,"rootHash":%q,"hashes":[]}`, uuid)
+		case r.URL.Path == "/api/v1/log/entries/"+uuid:
+			fmt.Fprintf(w, `{%q:{"logIndex":7,"integratedTime":1617278700}}`, uuid)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	ctx := context.Background()
+	first, err := UploadTLog(ctx, s.URL, []byte("sig"), []byte("payload"), []byte("pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Existing || first.UUID != uuid || first.Index() != "7" || first.LogURL != s.URL {
+		t.Errorf("first upload = %+v, want a new entry", first)
+	}
+	// Signing again proposes the same entry, which is found instead of failing.
+	again, err := UploadTLog(ctx, s.URL, []byte("sig"), []byte("payload"), []byte("pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Existing || again.UUID != uuid || again.LogIndex != 7 {
+		t.Errorf("second upload = %+v, want the existing entry", again)
+	}
+}
+
+func TestIntotoProposedEntry(t *testing.T) {
+	env := &Envelope{PayloadType: InTotoPayloadType, Payload: "e30=", Signatures: []EnvelopeSignature{{Sig: "c2ln"}}}
+	entry, err := IntotoProposedEntry(env, []byte("pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Kind string `json:"kind"`
+		Spec struct {
+			Content struct {
+				Envelope string `json:"envelope"`
+				Hash     struct {
+					Value string `json:"value"`
+				} `json:"hash"`
+			} `json:"content"`
+			PublicKey string `json:"publicKey"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	envBytes, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := sha256.Sum256(envBytes)
+	if got.Kind != "intoto" || got.Spec.Content.Envelope != string(envBytes) || got.Spec.Content.Hash.Value != hex.EncodeToString(h[:]) {
+		t.Errorf("entry = %s, want an intoto entry holding the envelope", b)
+	}
+	if got.Spec.PublicKey != base64.StdEncoding.EncodeToString([]byte("pem")) {
+		t.Errorf("publicKey = %q, want the base64 PEM", got.Spec.PublicKey)
+	}
+
+	env.PayloadType = "text/plain"
+	if _, err := IntotoProposedEntry(env, []byte("pem")); err == nil {
+		t.Error("expected error for an envelope that isn't an in-toto statement")
+	}
+}
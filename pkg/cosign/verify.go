@@ -18,6 +18,7 @@ import (
 	"context"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
@@ -26,51 +27,72 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/go-openapi/swag"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/trillian/merkle/logverifier"
 	"github.com/google/trillian/merkle/rfc6962/hasher"
 	"github.com/pkg/errors"
+	"golang.org/x/sync/semaphore"
 
-	"github.com/sigstore/rekor/cmd/cli/app"
 	"github.com/sigstore/rekor/pkg/generated/client"
 	"github.com/sigstore/rekor/pkg/generated/client/entries"
 	"github.com/sigstore/rekor/pkg/generated/models"
 
+	"github.com/sigstore/cosign/pkg/cosign/fulcio"
 	"github.com/sigstore/cosign/pkg/cosign/kms"
+	"github.com/sigstore/cosign/pkg/cosign/kubernetes"
+	"github.com/sigstore/cosign/pkg/cosign/log"
+	"github.com/sigstore/cosign/pkg/cosign/telemetry"
 )
 
 const pubKeyPemType = "PUBLIC KEY"
 
+// spiffeIDPrefix starts identities that are SPIFFE IDs rather than emails.
+const spiffeIDPrefix = "spiffe://"
+
 type Verifier interface {
 	Verify(ctx context.Context, payload, signature []byte) error
 }
 
 type PublicKey interface {
 	Verifier
+	DigestVerifier
 	PublicKey(ctx context.Context) (crypto.PublicKey, error)
 }
 
 func LoadPublicKey(ctx context.Context, keyRef string) (PublicKey, error) {
 	// The key could be plaintext or in a file.
 	// First check if the file exists.
-	var pubBytes []byte
-
 	if kmsKey, err := kms.Get(ctx, keyRef); err == nil {
 		// KMS specified
 		return kmsKey, nil
 	}
+	if kubernetes.IsRef(keyRef) {
+		b, err := kubernetes.ReadKey(ctx, keyRef, kubernetes.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		return ParsePublicKeyPem(b)
+	}
 
 	// PEM encoded file.
 	b, err := ioutil.ReadFile(filepath.Clean(keyRef))
 	if err != nil {
 		return nil, err
 	}
+	return ParsePublicKeyPem(b)
+}
+
+// ParsePublicKeyPem parses a PEM encoded ECDSA public key.
+func ParsePublicKeyPem(b []byte) (PublicKey, error) {
 	p, _ := pem.Decode(b)
 	if p == nil {
 		return nil, errors.New("pem.Decode failed")
@@ -78,9 +100,7 @@ func LoadPublicKey(ctx context.Context, keyRef string) (PublicKey, error) {
 	if p.Type != pubKeyPemType {
 		return nil, fmt.Errorf("not public: %q", p.Type)
 	}
-	pubBytes = p.Bytes
-
-	pub, err := x509.ParsePKIXPublicKey(pubBytes)
+	pub, err := x509.ParsePKIXPublicKey(p.Bytes)
 	if err != nil {
 		return nil, err
 	}
@@ -91,8 +111,10 @@ func LoadPublicKey(ctx context.Context, keyRef string) (PublicKey, error) {
 	return &ECDSAPublicKey{ed}, nil
 }
 
-func getTlogEntry(rekorClient *client.Rekor, uuid string) (*models.LogEntryAnon, error) {
-	params := entries.NewGetLogEntryByUUIDParams()
+func getTlogEntry(ctx context.Context, rekorClient *client.Rekor, uuid string) (_ *models.LogEntryAnon, err error) {
+	ctx, end := telemetry.Start(ctx, telemetry.OpRekorGetEntry)
+	defer func() { end(err) }()
+	params := entries.NewGetLogEntryByUUIDParamsWithContext(ctx)
 	params.SetEntryUUID(uuid)
 	resp, err := rekorClient.Entries.GetLogEntryByUUID(params)
 	if err != nil {
@@ -104,19 +126,30 @@ func getTlogEntry(rekorClient *client.Rekor, uuid string) (*models.LogEntryAnon,
 	return nil, errors.New("empty response")
 }
 
-func FindTlogEntry(rekorClient *client.Rekor, b64Sig string, payload, pubKey []byte) (string, error) {
-	params := entries.NewGetLogEntryProofParams()
-	searchParams := entries.NewSearchLogQueryParams()
-	searchLogQuery := models.SearchLogQuery{}
+func FindTlogEntry(ctx context.Context, rekorClient *client.Rekor, b64Sig string, payload, pubKey []byte) (string, error) {
 	signature, err := base64.StdEncoding.DecodeString(b64Sig)
 	if err != nil {
 		return "", errors.Wrap(err, "decoding base64 signature")
 	}
-	re := rekorEntry(payload, signature, pubKey)
-	entry := &models.Rekord{
-		APIVersion: swag.String(re.APIVersion()),
-		Spec:       re.RekordObj,
+	return findTlogEntry(ctx, rekorClient, TlogProposedEntry(signature, payload, pubKey))
+}
+
+// FindAttestationTlogEntry returns the UUID of the intoto entry UploadAttestationTLog added for the envelope,
+// after verifying its inclusion in the log.
+func FindAttestationTlogEntry(ctx context.Context, rekorClient *client.Rekor, env *Envelope, pubKey []byte) (string, error) {
+	entry, err := IntotoProposedEntry(env, pubKey)
+	if err != nil {
+		return "", err
 	}
+	return findTlogEntry(ctx, rekorClient, entry)
+}
+
+func findTlogEntry(ctx context.Context, rekorClient *client.Rekor, entry models.ProposedEntry) (_ string, err error) {
+	ctx, end := telemetry.Start(ctx, telemetry.OpRekorSearch)
+	defer func() { end(err) }()
+	params := entries.NewGetLogEntryProofParamsWithContext(ctx)
+	searchParams := entries.NewSearchLogQueryParamsWithContext(ctx)
+	searchLogQuery := models.SearchLogQuery{}
 
 	entries := []models.ProposedEntry{entry}
 	searchLogQuery.SetEntries(entries)
@@ -160,125 +193,417 @@ func FindTlogEntry(rekorClient *client.Rekor, b64Sig string, payload, pubKey []b
 	return params.EntryUUID, nil
 }
 
+// CheckOpts are the options for checking signatures. Each signature must be signed by
+// one of Keys or, if no keys are given, carry a certificate that chains up to Roots.
 // There are only payloads. Some have certs, some don't.
 type CheckOpts struct {
+	// Annotations must all be present in the payload. They are only checked with ClaimVerification.
 	Annotations map[string]string
-	Claims      bool
-	Tlog        bool
-	PubKey      PublicKey
-	Roots       *x509.CertPool
+	// ClaimVerification checks that the payload refers to the image being verified: its
+	// docker-manifest-digest claim, unless SkipDigestClaim, and its docker-reference claim if
+	// ReferenceClaim is set.
+	ClaimVerification bool
+	SkipDigestClaim   bool
+	// ReferenceClaim is the repository the docker-reference claim must name, or one of its
+	// ReferenceEquivalents, e.g. the registry it was mirrored from.
+	ReferenceClaim       string
+	ReferenceEquivalents []ReferenceEquivalent
+	// TLog requires the signatures to be present in the transparency log.
+	TLog bool
+	// RekorURL is the address of the transparency log, TlogServer if it is empty.
+	RekorURL string
+	Keys     []PublicKey
+	Roots    *x509.CertPool
+	// Identities, if set, are the certificate emails or SPIFFE IDs allowed to sign, when signatures are verified
+	// against Roots. See HasIdentity.
+	Identities []string
+	// CTLog requires the certificates of signatures verified against Roots to be in the certificate transparency
+	// log at CTLogURL, fulcio.CTLogServer if it is empty. Signatures must carry the log's SCT for their certificate.
+	CTLog    bool
+	CTLogURL string
+	// HardwareAttestationRoots, if set, requires each signature to carry an attestation that its key was
+	// generated on a hardware device, chaining up to one of these vendor roots. See VerifyHardwareAttestation.
+	HardwareAttestationRoots []*x509.Certificate
+	// Threshold, if set, stops verification once that many signatures have been verified.
+	// By default every signature is checked.
+	Threshold int
+	// Countersigners, if set, only accepts signatures with a countersignature that passes its checks,
+	// see "cosign countersign". Its cache, log and registry options are taken from these options.
+	Countersigners *CheckOpts
+	// RequiredApprovals, if set, is how many approvals from distinct identities passing the checks
+	// in Approvers the image must have, see "cosign approve".
+	RequiredApprovals int
+	Approvers         *CheckOpts
+	// Cache, if set, keeps fetched signatures and transparency log entries for reuse.
+	Cache *Cache
+	// RegistryClientOpts configure how images and signatures are fetched.
+	// By default credentials come from the docker config and credential helpers.
+	RegistryClientOpts []remote.Option
+}
+
+// rekorURL returns the address of the transparency log signatures are looked up in.
+func (co *CheckOpts) rekorURL() string {
+	if co.RekorURL != "" {
+		return co.RekorURL
+	}
+	return TlogServer()
+}
+
+// ctLogURL returns the address of the certificate transparency log certificates are looked up in.
+func (co *CheckOpts) ctLogURL() string {
+	if co.CTLogURL != "" {
+		return co.CTLogURL
+	}
+	return fulcio.CTLogServer()
+}
+
+// VerifiedSignature is a signature that passed all of the checks in CheckOpts,
+// along with what was learned about it while verifying.
+type VerifiedSignature struct {
+	SignedPayload
+	// Key is the one from CheckOpts.Keys that verified the signature, or nil if it was verified by its certificate.
+	Key PublicKey `json:"-"`
+	// Claims is the parsed payload, set when claims were verified.
+	Claims *SimpleSigning `json:",omitempty"`
+	// TlogEntryUUID identifies the transparency log entry, set when the log was checked.
+	TlogEntryUUID string `json:",omitempty"`
+	// Hardware describes the device the key was generated on, set when CheckOpts.HardwareAttestationRoots was checked.
+	Hardware *HardwareAttestation `json:",omitempty"`
+	// Countersignatures endorsing the signature, set when CheckOpts.Countersigners was checked.
+	Countersignatures []VerifiedSignature `json:",omitempty"`
 }
 
-// Verify does all the main cosign checks in a loop, returning validated payloads.
+// Verify does all the main cosign checks in a loop, returning validated signatures.
 // If there were no payloads, we return an error.
-func Verify(ctx context.Context, ref name.Reference, co CheckOpts) ([]SignedPayload, error) {
+func Verify(ctx context.Context, ref name.Reference, co CheckOpts) ([]VerifiedSignature, error) {
 	// Enforce this up front.
-	if co.Roots == nil && co.PubKey == nil {
+	if co.Roots == nil && len(co.Keys) == 0 {
 		return nil, errors.New("one of public key or cert roots is required")
 	}
-	// TODO: Figure out if we'll need a client before creating one.
-	rekorClient, err := app.GetRekorClient(TlogServer())
+
+	// These are all the signatures attached to our image that we know how to parse.
+	allSignatures, desc, err := fetchSignatures(ctx, ref, co.Cache, co.RegistryClientOpts)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching signatures")
+	}
+	verified, err := VerifyPayloads(ctx, desc, allSignatures, co)
 	if err != nil {
 		return nil, err
 	}
+	return VerifyEndorsements(ctx, ref, desc, verified, co)
+}
 
-	// These are all the signatures attached to our image that we know how to parse.
-	allSignatures, desc, err := FetchSignatures(ctx, ref)
+// VerifyBundle runs the same checks as Verify over the image signature in a bundle instead of
+// the signatures stored in the registry.
+func VerifyBundle(ctx context.Context, ref name.Reference, bundle *Bundle, co CheckOpts) ([]VerifiedSignature, error) {
+	sp, err := bundle.SignedPayload()
+	if err != nil {
+		return nil, err
+	}
+	desc, err := mirroredDescriptor(ctx, ref, registryOpts(ctx, co.RegistryClientOpts))
+	if err != nil {
+		return nil, err
+	}
+	verified, err := VerifyPayloads(ctx, &desc.Descriptor, []SignedPayload{sp}, co)
+	if err != nil {
+		return nil, err
+	}
+	return VerifyEndorsements(ctx, ref, &desc.Descriptor, verified, co)
+}
+
+// VerifyDescriptor runs the same checks as Verify over the signatures stored in ref's repository for the
+// image with desc, which doesn't have to be in the registry, for example an image in the local docker
+// daemon that hasn't been pushed yet.
+func VerifyDescriptor(ctx context.Context, ref name.Reference, desc v1.Descriptor, co CheckOpts) ([]VerifiedSignature, error) {
+	if co.Roots == nil && len(co.Keys) == 0 {
+		return nil, errors.New("one of public key or cert roots is required")
+	}
+	allSignatures, err := FetchSignaturesFor(ctx, ref, desc, co.RegistryClientOpts...)
 	if err != nil {
 		return nil, errors.Wrap(err, "fetching signatures")
 	}
+	verified, err := VerifyPayloads(ctx, &desc, allSignatures, co)
+	if err != nil {
+		return nil, err
+	}
+	return VerifyEndorsements(ctx, ref, &desc, verified, co)
+}
 
-	validationErrs := []string{}
-	checkedSignatures := []SignedPayload{}
-	for _, sp := range allSignatures {
-		switch {
-		// We have a public key to check against.
-		case co.PubKey != nil:
-			if err := sp.VerifyKey(ctx, co.PubKey); err != nil {
-				validationErrs = append(validationErrs, err.Error())
-				continue
-			}
-		// If we don't have a public key to check against, we can try a root cert.
-		case co.Roots != nil:
-			// There might be signatures with a public key instead of a cert, though
-			if sp.Cert == nil {
-				validationErrs = append(validationErrs, "no certificate found on signature")
-				continue
+// VerifyDetached runs the same checks as Verify over signatures of the image at ref that were fetched
+// earlier, for example by "cosign download signature", without contacting the registry. Countersignatures
+// and approvals are only stored in the registry, so co can't ask for them.
+func VerifyDetached(ctx context.Context, ref name.Digest, allSignatures []SignedPayload, co CheckOpts) ([]VerifiedSignature, error) {
+	if co.Countersigners != nil || co.RequiredApprovals > 0 {
+		return nil, errors.New("countersignatures and approvals can't be verified without the registry")
+	}
+	digest, err := v1.NewHash(ref.DigestStr())
+	if err != nil {
+		return nil, err
+	}
+	return VerifyPayloads(ctx, &v1.Descriptor{Digest: digest}, allSignatures, co)
+}
+
+// VerifyEndorsements checks the countersignatures and approvals co asks for, beyond the signatures
+// of ref that VerifyPayloads verified.
+func VerifyEndorsements(ctx context.Context, ref name.Reference, desc *v1.Descriptor, verified []VerifiedSignature, co CheckOpts) ([]VerifiedSignature, error) {
+	if co.Countersigners != nil {
+		var err error
+		if verified, err = verifyCountersigned(ctx, ref, desc, verified, co); err != nil {
+			return nil, err
+		}
+	}
+	if co.RequiredApprovals > 0 {
+		if _, err := VerifyApprovals(ctx, ref, desc, co); err != nil {
+			return nil, err
+		}
+	}
+	return verified, nil
+}
+
+// VerifyPayloads runs the same checks as Verify over signatures that were obtained some other way.
+// desc is the descriptor of the image the signatures should cover.
+func VerifyPayloads(parent context.Context, desc *v1.Descriptor, allSignatures []SignedPayload, co CheckOpts) ([]VerifiedSignature, error) {
+	if co.Roots == nil && len(co.Keys) == 0 {
+		return nil, errors.New("one of public key or cert roots is required")
+	}
+	// TODO: Figure out if we'll need a client before creating one.
+	rekorClient, err := TlogClientFor(co.rekorURL())
+	if err != nil {
+		return nil, err
+	}
+
+	// Signatures are verified concurrently, each one can take several round trips to the log.
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	results := make([]*VerifiedSignature, len(allSignatures))
+	errs := make([]error, len(allSignatures))
+	var verifiedCount int64
+	var wg sync.WaitGroup
+	sem := semaphore.NewWeighted(int64(runtime.NumCPU()))
+	for i, sp := range allSignatures {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, sp SignedPayload) {
+			defer wg.Done()
+			defer sem.Release(1)
+			vs, err := verifySignature(ctx, desc, sp, co, rekorClient)
+			if err != nil {
+				errs[i] = err
+				return
 			}
-			pub := &ECDSAPublicKey{sp.Cert.PublicKey.(*ecdsa.PublicKey)}
-			// Now verify the signature, then the cert.
-			if err := sp.VerifyKey(ctx, pub); err != nil {
-				validationErrs = append(validationErrs, err.Error())
-				continue
+			results[i] = vs
+			// Stop the others once enough signatures have been found.
+			if n := atomic.AddInt64(&verifiedCount, 1); co.Threshold > 0 && n >= int64(co.Threshold) {
+				cancel()
 			}
-			if err := sp.TrustedCert(co.Roots); err != nil {
-				validationErrs = append(validationErrs, err.Error())
-				continue
+		}(i, sp)
+	}
+	wg.Wait()
+
+	checkedSignatures := []VerifiedSignature{}
+	for _, vs := range results {
+		if vs != nil {
+			checkedSignatures = append(checkedSignatures, *vs)
+		}
+	}
+	if co.Threshold > 0 && len(checkedSignatures) >= co.Threshold {
+		return checkedSignatures, nil
+	}
+	// Anything else that stopped us early is the caller's cancellation.
+	if err := parent.Err(); err != nil {
+		return nil, err
+	}
+	if len(checkedSignatures) == 0 {
+		nm := &NoMatchingSignaturesError{}
+		for _, err := range errs {
+			if err != nil {
+				nm.Errs = append(nm.Errs, err)
 			}
 		}
+		return nil, nm
+	}
+	return checkedSignatures, nil
+}
+
+// NoMatchingSignaturesError is returned when none of the signatures passed the checks, with why
+// each of them failed.
+type NoMatchingSignaturesError struct {
+	Errs []error
+}
+
+func (e *NoMatchingSignaturesError) Error() string {
+	validationErrs := []string{}
+	for _, err := range e.Errs {
+		validationErrs = append(validationErrs, err.Error())
+	}
+	return fmt.Sprintf("no matching signatures:\n%s", strings.Join(validationErrs, "\n "))
+}
 
-		// We can't check annotations without claims, both require unmarshalling the payload.
-		if co.Claims {
-			ss := &SimpleSigning{}
-			if err := json.Unmarshal(sp.Payload, ss); err != nil {
-				validationErrs = append(validationErrs, err.Error())
-				continue
+// verifySignature runs all of the checks in co over a single signature.
+func verifySignature(ctx context.Context, desc *v1.Descriptor, sp SignedPayload, co CheckOpts, rekorClient *client.Rekor) (*VerifiedSignature, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if log.Enabled(log.LevelDebug) {
+		log.Debugf("verifying signature over payload sha256:%x of %s", sha256.Sum256(sp.Payload), desc.Digest)
+	}
+	vs := &VerifiedSignature{SignedPayload: sp}
+	switch {
+	// We have public keys to check against.
+	case len(co.Keys) > 0:
+		var err error
+		vs.Key, err = sp.verifyKeys(ctx, co.Keys)
+		if err != nil {
+			return nil, err
+		}
+	// If we don't have a public key to check against, we can try a root cert.
+	case co.Roots != nil:
+		// There might be signatures with a public key instead of a cert, though
+		if sp.Cert == nil {
+			return nil, errors.New("no certificate found on signature")
+		}
+		certKey, ok := sp.Cert.PublicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, errors.New("unsupported certificate key type")
+		}
+		// Now verify the signature, then the cert.
+		if err := sp.VerifyKey(ctx, &ECDSAPublicKey{certKey}); err != nil {
+			return nil, err
+		}
+		if err := sp.TrustedCert(co.Roots); err != nil {
+			return nil, err
+		}
+		if err := checkIdentity(sp.Cert, co.Identities); err != nil {
+			return nil, err
+		}
+		if co.CTLog {
+			if err := sp.VerifyCTInclusion(ctx, co.ctLogURL()); err != nil {
+				return nil, err
 			}
+		}
+	}
 
+	if len(co.HardwareAttestationRoots) > 0 {
+		pub, err := vs.publicKey(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if vs.Hardware, err = VerifyHardwareAttestation(pub, sp.HardwareAttestation, co.HardwareAttestationRoots); err != nil {
+			return nil, err
+		}
+	}
+
+	// We can't check annotations without claims, both require unmarshalling the payload.
+	if co.ClaimVerification {
+		ss := &SimpleSigning{}
+		if err := json.Unmarshal(sp.Payload, ss); err != nil {
+			return nil, err
+		}
+
+		if !co.SkipDigestClaim {
 			if err := sp.VerifyClaims(desc, ss); err != nil {
-				validationErrs = append(validationErrs, err.Error())
-				continue
+				return nil, err
 			}
+		}
+		if co.ReferenceClaim != "" {
+			if err := sp.VerifyReferenceClaim(co.ReferenceClaim, ss, co.ReferenceEquivalents...); err != nil {
+				return nil, err
+			}
+		}
 
-			if co.Annotations != nil {
-				if !correctAnnotations(co.Annotations, ss.Optional) {
-					validationErrs = append(validationErrs, "missing or incorrect annotation")
-					continue
-				}
+		if co.Annotations != nil {
+			if !correctAnnotations(co.Annotations, ss.Optional) {
+				return nil, errors.New("missing or incorrect annotation")
 			}
 		}
+		vs.Claims = ss
+	}
 
-		if co.Tlog {
-			// Get the right public key to use (key or cert)
-			var pemBytes []byte
-			if co.PubKey != nil {
-				pemBytes, err = PublicKeyPem(ctx, co.PubKey)
-				if err != nil {
-					validationErrs = append(validationErrs, err.Error())
-					continue
-				}
-			} else {
-				pemBytes = CertToPem(sp.Cert)
+	if co.TLog {
+		// Get the right public key to use (key or cert)
+		var pemBytes []byte
+		if vs.Key != nil {
+			var err error
+			pemBytes, err = PublicKeyPem(ctx, vs.Key)
+			if err != nil {
+				return nil, err
 			}
-			// Find the uuid then the entry.
-			uuid, err := sp.VerifyTlog(rekorClient, pemBytes)
+		} else {
+			pemBytes = CertToPem(sp.Cert)
+		}
+		// Find the uuid then the entry.
+		uuid, err := co.Cache.tlogEntryUUID(ctx, rekorClient, co.rekorURL(), sp, pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		// if we have a cert, we should check expiry
+		if sp.Cert != nil {
+			it, err := co.Cache.tlogIntegratedTime(ctx, rekorClient, co.rekorURL(), uuid)
 			if err != nil {
-				validationErrs = append(validationErrs, err.Error())
-				continue
+				return nil, err
 			}
-			// if we have a cert, we should check expiry
-			if sp.Cert != nil {
-				e, err := getTlogEntry(rekorClient, uuid)
-				if err != nil {
-					validationErrs = append(validationErrs, err.Error())
-					continue
-				}
-				// Expiry check is only enabled with Tlog support
-				if err := checkExpiry(sp.Cert, time.Unix(e.IntegratedTime, 0)); err != nil {
-					validationErrs = append(validationErrs, err.Error())
-					continue
-				}
+			// Expiry check is only enabled with Tlog support
+			if err := checkExpiry(sp.Cert, time.Unix(it, 0)); err != nil {
+				return nil, err
 			}
 		}
+		vs.TlogEntryUUID = uuid
+	}
+
+	// Phew, we made it.
+	return vs, nil
+}
 
-		// Phew, we made it.
-		checkedSignatures = append(checkedSignatures, sp)
+// publicKey returns the key that verified the signature, from CheckOpts.Keys or its certificate.
+func (vs *VerifiedSignature) publicKey(ctx context.Context) (crypto.PublicKey, error) {
+	if vs.Key != nil {
+		return vs.Key.PublicKey(ctx)
 	}
-	if len(checkedSignatures) == 0 {
-		return nil, fmt.Errorf("no matching signatures:\n%s", strings.Join(validationErrs, "\n "))
+	return vs.Cert.PublicKey, nil
+}
+
+// checkIdentity checks that the certificate is for one of the identities, if there are any.
+func checkIdentity(cert *x509.Certificate, identities []string) error {
+	if len(identities) == 0 {
+		return nil
 	}
-	return checkedSignatures, nil
+	for _, id := range identities {
+		if HasIdentity(cert, id) {
+			return nil
+		}
+	}
+	ids := append([]string{}, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		ids = append(ids, u.String())
+	}
+	return fmt.Errorf("certificate identity %v is not trusted", ids)
 }
+
+// HasIdentity reports whether the certificate is for the identity, an email or a SPIFFE ID.
+// SPIFFE IDs are matched against the certificate's URIs, and may end in /* to match every
+// workload under a path, e.g. spiffe://example.org/ns/ci/*.
+func HasIdentity(cert *x509.Certificate, id string) bool {
+	if strings.HasPrefix(id, spiffeIDPrefix) {
+		prefix := strings.TrimSuffix(id, "*")
+		wildcard := prefix != id && strings.HasSuffix(prefix, "/")
+		for _, u := range cert.URIs {
+			uri := u.String()
+			if uri == id || (wildcard && strings.HasPrefix(uri, prefix)) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, email := range cert.EmailAddresses {
+		if email == id {
+			return true
+		}
+	}
+	return false
+}
+
 func checkExpiry(cert *x509.Certificate, it time.Time) error {
 	ft := func(t time.Time) string {
 		return t.Format(time.RFC3339)
@@ -295,6 +620,15 @@ func checkExpiry(cert *x509.Certificate, it time.Time) error {
 }
 
 func (sp *SignedPayload) VerifyKey(ctx context.Context, pubKey PublicKey) error {
+	if FIPSMode() {
+		pub, err := pubKey.PublicKey(ctx)
+		if err != nil {
+			return err
+		}
+		if err := CheckFIPSKey(pub); err != nil {
+			return err
+		}
+	}
 	signature, err := base64.StdEncoding.DecodeString(sp.Base64Signature)
 	if err != nil {
 		return err
@@ -302,39 +636,162 @@ func (sp *SignedPayload) VerifyKey(ctx context.Context, pubKey PublicKey) error
 	return pubKey.Verify(ctx, sp.Payload, signature)
 }
 
+// verifyKeys returns the first of keys that verifies the signature.
+func (sp *SignedPayload) verifyKeys(ctx context.Context, keys []PublicKey) (PublicKey, error) {
+	var err error
+	for _, k := range keys {
+		if err = sp.VerifyKey(ctx, k); err == nil {
+			return k, nil
+		}
+	}
+	return nil, err
+}
+
+// Claims of simple signing payloads, as reported in ClaimError.
+const (
+	ClaimDigest    = "docker-manifest-digest"
+	ClaimReference = "docker-reference"
+)
+
+// ClaimError reports a claim of a payload that doesn't match the image being verified.
+type ClaimError struct {
+	Claim string `json:"claim"`
+	Want  string `json:"want"`
+	Got   string `json:"got"`
+}
+
+func (e *ClaimError) Error() string {
+	return fmt.Sprintf("invalid or missing %s in claim: %q, want %q", e.Claim, e.Got, e.Want)
+}
+
+// ClaimErrors returns the failed claim checks in err, as returned by VerifyPayloads.
+func ClaimErrors(err error) []ClaimError {
+	var errs []error
+	var nm *NoMatchingSignaturesError
+	if errors.As(err, &nm) {
+		errs = nm.Errs
+	} else {
+		errs = []error{err}
+	}
+	out := []ClaimError{}
+	for _, err := range errs {
+		var ce *ClaimError
+		if errors.As(err, &ce) {
+			out = append(out, *ce)
+		}
+	}
+	return out
+}
+
+// VerifyClaims checks that the docker-manifest-digest claim is the digest of d.
 func (sp *SignedPayload) VerifyClaims(d *v1.Descriptor, ss *SimpleSigning) error {
 	foundDgst := ss.Critical.Image.DockerManifestDigest
 	if foundDgst != d.Digest.String() {
-		return fmt.Errorf("invalid or missing digest in claim: %s", foundDgst)
+		return &ClaimError{Claim: ClaimDigest, Want: d.Digest.String(), Got: foundDgst}
+	}
+	return nil
+}
+
+// VerifyReferenceClaim checks that the docker-reference claim names the repository repo, or a
+// repository equivalent to it. Both are normalized, so "ubuntu" matches "index.docker.io/library/ubuntu".
+func (sp *SignedPayload) VerifyReferenceClaim(repo string, ss *SimpleSigning, equivalents ...ReferenceEquivalent) error {
+	found := ss.Critical.Identity.DockerReference
+	want, err := name.NewRepository(repo)
+	if err != nil {
+		return err
+	}
+	if got, err := name.NewRepository(found); err != nil || !equivalentReferences(got.Name(), want.Name(), equivalents) {
+		return &ClaimError{Claim: ClaimReference, Want: want.Name(), Got: found}
 	}
 	return nil
 }
 
-func (sp *SignedPayload) VerifyTlog(rc *client.Rekor, publicKeyPem []byte) (string, error) {
-	return FindTlogEntry(rc, sp.Base64Signature, sp.Payload, publicKeyPem)
+func (sp *SignedPayload) VerifyTlog(ctx context.Context, rc *client.Rekor, publicKeyPem []byte) (string, error) {
+	return FindTlogEntry(ctx, rc, sp.Base64Signature, sp.Payload, publicKeyPem)
+}
+
+// VerifyCTInclusion checks that the signature's certificate is in the certificate transparency log at ctURL,
+// using the SCT recorded with the signature to find it.
+func (sp *SignedPayload) VerifyCTInclusion(ctx context.Context, ctURL string) error {
+	if sp.SCT == nil {
+		return errors.New("signature has no SCT to find its certificate in the certificate transparency log")
+	}
+	return fulcio.VerifyCTInclusion(ctx, ctURL, sp.Cert, sp.SCT)
 }
 
 func (sp *SignedPayload) TrustedCert(roots *x509.CertPool) error {
-	return TrustedCert(sp.Cert, roots)
+	return TrustedCert(sp.Cert, roots, sp.Chain...)
 }
 
-func TrustedCert(cert *x509.Certificate, roots *x509.CertPool) error {
-	if _, err := cert.Verify(x509.VerifyOptions{
+// TrustedCert checks that cert chains up to one of the roots, through the intermediates if it
+// isn't issued by a root directly, as SPIFFE SVIDs from a SPIRE server with an upstream CA aren't.
+// The certificate must be issued for signing, see CheckCertUsage, and certificates issued by Fulcio
+// must have the extensions Fulcio puts in them, see fulcio.CheckCert.
+func TrustedCert(cert *x509.Certificate, roots *x509.CertPool, intermediates ...*x509.Certificate) error {
+	if err := CheckFIPSKey(cert.PublicKey); err != nil {
+		return err
+	}
+	if err := CheckCertUsage(cert); err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	for _, c := range intermediates {
+		pool.AddCert(c)
+	}
+	// The intermediates must allow the leaf's usage too. SVIDs were checked above, the X.509-SVID
+	// spec doesn't constrain the extended key usage of their CAs.
+	usage := x509.ExtKeyUsageCodeSigning
+	if isSVID(cert) {
+		usage = x509.ExtKeyUsageAny
+	}
+	chains, err := cert.Verify(x509.VerifyOptions{
 		// THIS IS IMPORTANT: WE DO NOT CHECK TIMES HERE
 		// THE CERTIFICATE IS TREATED AS TRUSTED FOREVER
 		// WE CHECK THAT THE SIGNATURES WERE CREATED DURING THIS WINDOW
-		CurrentTime: cert.NotBefore,
-		Roots:       roots,
-		KeyUsages: []x509.ExtKeyUsage{
-			x509.ExtKeyUsage(x509.KeyUsageDigitalSignature),
-			x509.ExtKeyUsageCodeSigning,
-		},
-	}); err != nil {
+		CurrentTime:   cert.NotBefore,
+		Roots:         roots,
+		Intermediates: pool,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	})
+	if err != nil {
 		return err
 	}
+	for _, chain := range chains {
+		if fulcio.IsRoot(chain[len(chain)-1]) {
+			return fulcio.CheckCert(cert)
+		}
+	}
 	return nil
 }
 
+// CheckCertUsage checks that cert was issued for signing: its key usage must allow digital signatures,
+// and its extended key usage must include code signing. SPIFFE X.509-SVIDs are issued for TLS, so any
+// extended key usage is accepted for them, as the X.509-SVID spec allows.
+func CheckCertUsage(cert *x509.Certificate) error {
+	if cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return errors.New("certificate key usage doesn't allow digital signatures")
+	}
+	if isSVID(cert) {
+		return nil
+	}
+	for _, u := range cert.ExtKeyUsage {
+		if u == x509.ExtKeyUsageCodeSigning {
+			return nil
+		}
+	}
+	return errors.New("certificate extended key usage doesn't include code signing")
+}
+
+// isSVID reports whether cert is a SPIFFE X.509-SVID, identified by a SPIFFE ID.
+func isSVID(cert *x509.Certificate) bool {
+	for _, u := range cert.URIs {
+		if strings.HasPrefix(u.String(), spiffeIDPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
 func correctAnnotations(wanted, have map[string]string) bool {
 	for k, v := range wanted {
 		if have[k] != v {
@@ -0,0 +1,313 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestVerifyPayloads(t *testing.T) {
+	ctx := context.Background()
+	newKey := func() *ECDSAKey {
+		priv, err := GeneratePrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return WithECDSAKey(priv)
+	}
+	signer, other := newKey(), newKey()
+
+	desc := &v1.Descriptor{
+		Digest: v1.Hash{Algorithm: "sha256", Hex: "4e6d18b4d1b2a1b3b0e4c1e0f2a5b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4"},
+	}
+	payload, err := json.Marshal(&ImagePayload{Img: *desc, Annotations: map[string]string{"env": "prod"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := signer.Sign(ctx, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sps := []SignedPayload{{Base64Signature: base64.StdEncoding.EncodeToString(sig), Payload: payload}}
+
+	verified, err := VerifyPayloads(ctx, desc, sps, CheckOpts{
+		Keys:              []PublicKey{other, signer},
+		ClaimVerification: true,
+		Annotations:       map[string]string{"env": "prod"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(verified) != 1 {
+		t.Fatalf("got %d verified signatures, want 1", len(verified))
+	}
+	if verified[0].Key != PublicKey(signer) {
+		t.Error("expected the signing key to be reported")
+	}
+	if verified[0].Claims == nil || verified[0].Claims.Critical.Image.DockerManifestDigest != desc.Digest.String() {
+		t.Errorf("unexpected claims: %+v", verified[0].Claims)
+	}
+
+	if _, err := VerifyPayloads(ctx, desc, sps, CheckOpts{Keys: []PublicKey{other}}); err == nil {
+		t.Error("expected error for wrong key")
+	}
+	if _, err := VerifyPayloads(ctx, desc, sps, CheckOpts{
+		Keys:              []PublicKey{signer},
+		ClaimVerification: true,
+		Annotations:       map[string]string{"env": "dev"},
+	}); err == nil {
+		t.Error("expected error for wrong annotation")
+	}
+	if _, err := VerifyPayloads(ctx, desc, sps, CheckOpts{}); err == nil {
+		t.Error("expected error without keys or roots")
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := VerifyPayloads(canceled, desc, sps, CheckOpts{Keys: []PublicKey{signer}}); err != context.Canceled {
+		t.Errorf("VerifyPayloads() with canceled context = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestVerifyPayloadsConcurrent(t *testing.T) {
+	ctx := context.Background()
+	priv, err := GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := WithECDSAKey(priv)
+	desc := &v1.Descriptor{
+		Digest: v1.Hash{Algorithm: "sha256", Hex: "4e6d18b4d1b2a1b3b0e4c1e0f2a5b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4"},
+	}
+
+	// Every third signature is bad.
+	sps := []SignedPayload{}
+	for i := 0; i < 30; i++ {
+		payload, err := json.Marshal(&ImagePayload{Img: *desc, Annotations: map[string]string{"n": fmt.Sprint(i)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err := signer.Sign(ctx, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%3 == 0 {
+			sig[len(sig)-1]++
+		}
+		sps = append(sps, SignedPayload{Base64Signature: base64.StdEncoding.EncodeToString(sig), Payload: payload})
+	}
+
+	verified, err := VerifyPayloads(ctx, desc, sps, CheckOpts{Keys: []PublicKey{signer}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(verified) != 20 {
+		t.Fatalf("got %d verified signatures, want 20", len(verified))
+	}
+	// Results keep the order of the input.
+	want := []SignedPayload{}
+	for i, sp := range sps {
+		if i%3 != 0 {
+			want = append(want, sp)
+		}
+	}
+	for i := range verified {
+		if string(verified[i].Payload) != string(want[i].Payload) {
+			t.Fatalf("verified[%d] = %s, want %s", i, verified[i].Payload, want[i].Payload)
+		}
+	}
+
+	verified, err = VerifyPayloads(ctx, desc, sps, CheckOpts{Keys: []PublicKey{signer}, Threshold: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(verified) == 0 {
+		t.Error("expected at least one verified signature with a threshold")
+	}
+}
+
+func TestVerifyClaimChecks(t *testing.T) {
+	ctx := context.Background()
+	priv, err := GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := WithECDSAKey(priv)
+	desc := &v1.Descriptor{
+		Digest: v1.Hash{Algorithm: "sha256", Hex: "4e6d18b4d1b2a1b3b0e4c1e0f2a5b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4"},
+	}
+	other := &v1.Descriptor{
+		Digest: v1.Hash{Algorithm: "sha256", Hex: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+	}
+	payload, err := json.Marshal(&ImagePayload{Img: *desc, Reference: "ubuntu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := signer.Sign(ctx, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sps := []SignedPayload{{Base64Signature: base64.StdEncoding.EncodeToString(sig), Payload: payload}}
+
+	tests := []struct {
+		desc       string
+		img        *v1.Descriptor
+		co         CheckOpts
+		wantClaims []string
+	}{
+		{"matching digest", desc, CheckOpts{}, nil},
+		{"matching reference", desc, CheckOpts{ReferenceClaim: "index.docker.io/library/ubuntu"}, nil},
+		{"other image", other, CheckOpts{}, []string{ClaimDigest}},
+		{"digest not checked", other, CheckOpts{SkipDigestClaim: true}, nil},
+		{"other repository", desc, CheckOpts{ReferenceClaim: "gcr.io/example/ubuntu"}, []string{ClaimReference}},
+		{"mirrored repository", desc, CheckOpts{ReferenceClaim: "mirror.example.com/library/ubuntu", ReferenceEquivalents: []ReferenceEquivalent{{A: "mirror.example.com", B: "index.docker.io"}}}, nil},
+		{"renamed repository", desc, CheckOpts{ReferenceClaim: "gcr.io/example/ubuntu", ReferenceEquivalents: []ReferenceEquivalent{{A: "gcr.io/example/ubuntu", B: "index.docker.io/library/ubuntu"}}}, nil},
+		{"unrelated equivalent", desc, CheckOpts{ReferenceClaim: "gcr.io/example/ubuntu", ReferenceEquivalents: []ReferenceEquivalent{{A: "gcr.io/example", B: "index.docker.io/example"}}}, []string{ClaimReference}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			co := tt.co
+			co.Keys = []PublicKey{signer}
+			co.ClaimVerification = true
+			_, err := VerifyPayloads(ctx, tt.img, sps, co)
+			if len(tt.wantClaims) == 0 {
+				if err != nil {
+					t.Errorf("VerifyPayloads() = %v", err)
+				}
+				return
+			}
+			got := []string{}
+			for _, ce := range ClaimErrors(err) {
+				got = append(got, ce.Claim)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantClaims) {
+				t.Errorf("failed claims = %v, want %v (error %v)", got, tt.wantClaims, err)
+			}
+		})
+	}
+}
+
+func TestTrustedCert(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svid, err := url.Parse("spiffe://example.org/ns/ci/sa/builder")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc        string
+		keyUsage    x509.KeyUsage
+		extKeyUsage []x509.ExtKeyUsage
+		uris        []*url.URL
+		wantErr     string
+	}{{
+		desc:        "code signing",
+		keyUsage:    x509.KeyUsageDigitalSignature,
+		extKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}, {
+		desc:        "no digital signature key usage",
+		keyUsage:    x509.KeyUsageKeyEncipherment,
+		extKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		wantErr:     "key usage",
+	}, {
+		desc:        "TLS server certificate",
+		keyUsage:    x509.KeyUsageDigitalSignature,
+		extKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		wantErr:     "code signing",
+	}, {
+		desc:     "no extended key usage",
+		keyUsage: x509.KeyUsageDigitalSignature,
+		wantErr:  "code signing",
+	}, {
+		desc:        "SPIFFE SVID",
+		keyUsage:    x509.KeyUsageDigitalSignature,
+		extKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		uris:        []*url.URL{svid},
+	}, {
+		desc:        "SPIFFE SVID without digital signature key usage",
+		keyUsage:    x509.KeyUsageKeyAgreement,
+		extKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		uris:        []*url.URL{svid},
+		wantErr:     "key usage",
+	}}
+	for i, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			tmpl := &x509.Certificate{
+				SerialNumber: big.NewInt(int64(i + 2)),
+				NotBefore:    time.Now().Add(-time.Hour),
+				NotAfter:     time.Now().Add(time.Hour),
+				KeyUsage:     tt.keyUsage,
+				ExtKeyUsage:  tt.extKeyUsage,
+				URIs:         tt.uris,
+			}
+			der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &leafKey.PublicKey, caKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = TrustedCert(cert, roots)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("TrustedCert() = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("TrustedCert() = %v, want error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxScanClockSkew is how far in the future a scan may be recorded to have run, for scanners whose
+// clock is ahead. Scans further in the future would stay fresh for too long.
+const maxScanClockSkew = 5 * time.Minute
+
+// VulnScan is the predicate of a vulnerability scan attestation, as "cosign attest -type vuln" records.
+type VulnScan struct {
+	Scanner struct {
+		URI     string `json:"uri"`
+		Version string `json:"version"`
+	} `json:"scanner"`
+	Metadata struct {
+		ScanStartedOn  *time.Time `json:"scanStartedOn,omitempty"`
+		ScanFinishedOn *time.Time `json:"scanFinishedOn,omitempty"`
+	} `json:"metadata"`
+}
+
+// ParseVulnScan returns the vulnerability scan predicate of the statement.
+func ParseVulnScan(st *Statement) (*VulnScan, error) {
+	if st.PredicateType != PredicateTypes["vuln"] {
+		return nil, fmt.Errorf("predicate type %s is not a vulnerability scan", st.PredicateType)
+	}
+	v := &VulnScan{}
+	if err := json.Unmarshal(st.Predicate, v); err != nil {
+		return nil, errors.Wrap(err, "parsing vulnerability scan")
+	}
+	return v, nil
+}
+
+// ScannedAt returns when the scan finished, or when it started if the scanner didn't record its end.
+func (v *VulnScan) ScannedAt() (time.Time, error) {
+	switch {
+	case v.Metadata.ScanFinishedOn != nil:
+		return *v.Metadata.ScanFinishedOn, nil
+	case v.Metadata.ScanStartedOn != nil:
+		return *v.Metadata.ScanStartedOn, nil
+	}
+	return time.Time{}, errors.New("the vulnerability scan doesn't record when it ran")
+}
+
+// CheckAge returns an error unless the scan ran at most maxAge before now. Stale scans miss the
+// vulnerabilities found since, so they shouldn't vouch for an image indefinitely.
+func (v *VulnScan) CheckAge(maxAge time.Duration, now time.Time) error {
+	at, err := v.ScannedAt()
+	if err != nil {
+		return err
+	}
+	if at.After(now.Add(maxScanClockSkew)) {
+		return fmt.Errorf("the vulnerability scan is dated %s, in the future", at.Format(time.RFC3339))
+	}
+	if age := now.Sub(at); age > maxAge {
+		return fmt.Errorf("the vulnerability scan from %s is %s old, more than %s", at.Format(time.RFC3339), age.Round(time.Minute), maxAge)
+	}
+	return nil
+}
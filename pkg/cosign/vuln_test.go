@@ -0,0 +1,68 @@
+// Copyright 2021 The Rekor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestVulnScanCheckAge(t *testing.T) {
+	now := time.Date(2021, 8, 10, 12, 0, 0, 0, time.UTC)
+	week := 7 * 24 * time.Hour
+	tests := []struct {
+		desc      string
+		predicate string
+		wantErr   bool
+	}{{
+		desc:      "fresh",
+		predicate: `{"scanner":{"uri":"pkg:github/aquasecurity/trivy"},"metadata":{"scanStartedOn":"2021-08-01T11:59:00Z","scanFinishedOn":"2021-08-05T12:00:00Z"}}`,
+	}, {
+		desc:      "only the start recorded",
+		predicate: `{"metadata":{"scanStartedOn":"2021-08-04T12:00:00Z"}}`,
+	}, {
+		desc:      "stale",
+		predicate: `{"metadata":{"scanFinishedOn":"2021-08-01T12:00:00Z"}}`,
+		wantErr:   true,
+	}, {
+		desc:      "undated",
+		predicate: `{"scanner":{"uri":"pkg:github/aquasecurity/trivy"}}`,
+		wantErr:   true,
+	}, {
+		desc:      "in the future",
+		predicate: `{"metadata":{"scanFinishedOn":"2021-09-01T12:00:00Z"}}`,
+		wantErr:   true,
+	}, {
+		desc:      "slightly ahead",
+		predicate: `{"metadata":{"scanFinishedOn":"2021-08-10T12:01:00Z"}}`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			st := &Statement{PredicateType: PredicateTypes["vuln"], Predicate: json.RawMessage(tt.predicate)}
+			v, err := ParseVulnScan(st)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := v.CheckAge(week, now); (err != nil) != tt.wantErr {
+				t.Errorf("CheckAge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := ParseVulnScan(&Statement{PredicateType: PredicateTypes["spdx"]}); err == nil {
+		t.Error("ParseVulnScan() of an SBOM, expected error")
+	}
+}
@@ -75,20 +75,20 @@ func TestSignVerify(t *testing.T) {
 	// Verify should fail at first
 	mustErr(verify(pubKeyPath, imgName, true, nil), t)
 	// So should download
-	mustErr(cli.DownloadCmd(ctx, imgName), t)
+	mustErr(cli.DownloadCmd(ctx, imgName, cli.RegistryOpts{}), t)
 
 	// Now sign the image
-	must(cli.SignCmd(ctx, privKeyPath, imgName, true, "", nil, "", passFunc, false), t)
+	must(cli.SignCmd(ctx, cli.SignOpts{KeyRef: privKeyPath, Upload: true}, imgName, passFunc), t)
 
 	// Now verify and download should work!
 	must(verify(pubKeyPath, imgName, true, nil), t)
-	must(cli.DownloadCmd(ctx, imgName), t)
+	must(cli.DownloadCmd(ctx, imgName, cli.RegistryOpts{}), t)
 
 	// Look for a specific annotation
 	mustErr(verify(pubKeyPath, imgName, true, map[string]string{"foo": "bar"}), t)
 
 	// Sign the image with an annotation
-	must(cli.SignCmd(ctx, privKeyPath, imgName, true, "", map[string]string{"foo": "bar"}, "", passFunc, false), t)
+	must(cli.SignCmd(ctx, cli.SignOpts{KeyRef: privKeyPath, Upload: true, Annotations: map[string]string{"foo": "bar"}}, imgName, passFunc), t)
 
 	// It should match this time.
 	must(verify(pubKeyPath, imgName, true, map[string]string{"foo": "bar"}), t)
@@ -130,13 +130,13 @@ func TestMultipleSignatures(t *testing.T) {
 	mustErr(verify(pub2, imgName, true, nil), t)
 
 	// Now sign the image with one key
-	must(cli.SignCmd(ctx, priv1, imgName, true, "", nil, "", passFunc, false), t)
+	must(cli.SignCmd(ctx, cli.SignOpts{KeyRef: priv1, Upload: true}, imgName, passFunc), t)
 	// Now verify should work with that one, but not the other
 	must(verify(pub1, imgName, true, nil), t)
 	mustErr(verify(pub2, imgName, true, nil), t)
 
 	// Now sign with the other key too
-	must(cli.SignCmd(ctx, priv2, imgName, true, "", nil, "", passFunc, false), t)
+	must(cli.SignCmd(ctx, cli.SignOpts{KeyRef: priv2, Upload: true}, imgName, passFunc), t)
 
 	// Now verify should work with both
 	must(verify(pub1, imgName, true, nil), t)
@@ -168,7 +168,7 @@ func TestSignBlob(t *testing.T) {
 	mustErr(cli.VerifyBlobCmd(ctx, pubKeyPath2, "", "", "badsig", blob), t)
 
 	// Now sign the blob with one key
-	sig, err := cli.SignBlobCmd(ctx, privKeyPath1, "", bp, true, passFunc)
+	sig, err := cli.SignBlobCmd(ctx, privKeyPath1, "", bp, cli.SignBlobOpts{Base64: true}, passFunc)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -187,7 +187,7 @@ func TestGenerate(t *testing.T) {
 
 	// Generate the payload for the image, and check the digest.
 	b := bytes.Buffer{}
-	must(cli.GenerateCmd(context.Background(), imgName, nil, &b), t)
+	must(cli.GenerateCmd(context.Background(), imgName, nil, &b, cli.RegistryOpts{}), t)
 	ss := cosign.SimpleSigning{}
 	must(json.Unmarshal(b.Bytes(), &ss), t)
 
@@ -196,7 +196,7 @@ func TestGenerate(t *testing.T) {
 	// Now try with some annotations.
 	b.Reset()
 	a := map[string]string{"foo": "bar"}
-	must(cli.GenerateCmd(context.Background(), imgName, a, &b), t)
+	must(cli.GenerateCmd(context.Background(), imgName, a, &b, cli.RegistryOpts{}), t)
 	must(json.Unmarshal(b.Bytes(), &ss), t)
 
 	equals(desc.Digest.String(), ss.Critical.Image.DockerManifestDigest, t)
@@ -268,7 +268,7 @@ func TestUploadDownload(t *testing.T) {
 			}
 
 			// Upload it!
-			err := cli.UploadCmd(ctx, sigRef, payloadPath, imgName)
+			err := cli.UploadCmd(ctx, sigRef, payloadPath, imgName, cli.RegistryOpts{})
 			if testCase.expectedErr {
 				mustErr(err, t)
 			} else {
@@ -328,7 +328,7 @@ func TestTlog(t *testing.T) {
 	mustErr(verify(pubKeyPath, imgName, true, nil), t)
 
 	// Now sign the image without the tlog
-	must(cli.SignCmd(ctx, privKeyPath, imgName, true, "", nil, "", passFunc, false), t)
+	must(cli.SignCmd(ctx, cli.SignOpts{KeyRef: privKeyPath, Upload: true}, imgName, passFunc), t)
 
 	// Now verify should work!
 	must(verify(pubKeyPath, imgName, true, nil), t)
@@ -340,7 +340,7 @@ func TestTlog(t *testing.T) {
 	mustErr(verify(pubKeyPath, imgName, true, nil), t)
 
 	// Sign again with the tlog env var on
-	must(cli.SignCmd(ctx, privKeyPath, imgName, true, "", nil, "", passFunc, false), t)
+	must(cli.SignCmd(ctx, cli.SignOpts{KeyRef: privKeyPath, Upload: true}, imgName, passFunc), t)
 	// And now verify works!
 	must(verify(pubKeyPath, imgName, true, nil), t)
 }